@@ -24,6 +24,7 @@ import (
 	"tg_cloud_server/internal/cron"
 	"tg_cloud_server/internal/events"
 	"tg_cloud_server/internal/handlers"
+	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/repository"
 	"tg_cloud_server/internal/routes"
 	"tg_cloud_server/internal/scheduler"
@@ -99,24 +100,69 @@ func main() {
 	accountRepo := repository.NewAccountRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
 	proxyRepo := repository.NewProxyRepository(db)
+	batchRepo := repository.NewBatchRepository(db)
+	adminRepo := repository.NewAdminRepository(db)
+	agentMemoryRepo := repository.NewAgentMemoryRepository(db)
+	peerAccessHashRepo := repository.NewPeerAccessHashRepository(db)
 
 	verifyCodeRepo := repository.NewVerifyCodeRepository(db)
 
+	// 2FA 密码加密密钥：已有密文记录时必须配置，否则无法读取密码
+	models.SetTwoFASecretKey(cfg.Telegram.Auto2FA.EncryptionKey)
+	if cfg.Telegram.Auto2FA.EncryptionKey == "" {
+		encrypted, err := accountRepo.CountEncryptedTwoFAPasswords()
+		if err != nil {
+			logger.Fatal("Failed to check encrypted 2FA passwords", zap.Error(err))
+		}
+		if encrypted > 0 {
+			logger.Fatal("Encrypted 2FA passwords exist but telegram.auto_2fa.encryption_key is not configured",
+				zap.Int64("accounts", encrypted))
+		}
+	}
+
 	// 初始化Telegram连接池
 	connectionPool := telegram.NewConnectionPool(
 		cfg.Telegram.APIID,
 		cfg.Telegram.APIHash,
-		cfg.Telegram.ConnectionPool.IdleTimeout,
+		telegram.ConnectionPoolConfig{
+			MaxIdle:               cfg.Telegram.ConnectionPool.IdleTimeout,
+			MaxReconnectAttempts:  cfg.Telegram.ConnectionPool.MaxReconnectAttempts,
+			InitialReconnectDelay: cfg.Telegram.ConnectionPool.InitialReconnectDelay,
+			MaxReconnectDelay:     cfg.Telegram.ConnectionPool.MaxReconnectDelay,
+		},
 		accountRepo,
 		proxyRepo,
 	)
+	connectionPool.SetShutdownFlushTimeout(cfg.Telegram.ConnectionPool.ShutdownFlushTimeout)
+	connectionPool.SetConnectionStatusDebounce(cfg.Telegram.ConnectionPool.StatusDebounce)
+	connectionPool.SetAPICallMetrics(cfg.Telegram.ConnectionPool.APIMetrics, cfg.Telegram.ConnectionPool.SlowCallThreshold)
+	connectionPool.SetAlwaysRecreateOnConfigUpdate(cfg.Telegram.ConnectionPool.AlwaysRecreate)
+	connectionPool.SetTaskSlotWait(cfg.Telegram.ConnectionPool.TaskSlotWait)
+	connectionPool.SetAutoAcceptTOS(cfg.Telegram.AutoAcceptTOS)
+	connectionPool.SetWarmPool(cfg.Telegram.ConnectionPool.WarmTarget, cfg.Telegram.ConnectionPool.WarmInterval)
+	connectionPool.SetReconnectJitter(cfg.Telegram.ConnectionPool.ReconnectJitterPercent)
+	connectionPool.SetPeerCacheTTL(cfg.Telegram.ConnectionPool.PeerCacheTTL)
+	connectionPool.SetFloodWaitRetry(cfg.Telegram.ConnectionPool.FloodWaitRetryThreshold)
+	connectionPool.SetPeerAccessHashStore(peerAccessHashRepo)
+	connectionPool.SetDefaultDevice(telegram.DeviceConfig{
+		DeviceModel:   cfg.Telegram.Device.DeviceModel,
+		SystemVersion: cfg.Telegram.Device.SystemVersion,
+		AppVersion:    cfg.Telegram.Device.AppVersion,
+		LangCode:      cfg.Telegram.Device.LangCode,
+	})
+	if err := metrics.RegisterConnectionPool(connectionPool); err != nil {
+		logger.Warn("Failed to register connection pool metrics", zap.Error(err))
+	}
 	logger.Info("Connection pool initialized",
 		zap.Int("api_id", cfg.Telegram.APIID),
 		zap.Duration("idle_timeout", cfg.Telegram.ConnectionPool.IdleTimeout))
 
 	// 初始化AI服务
 	var aiProvider services.AIProvider
-	aiConfig := map[string]interface{}{}
+	aiConfig := map[string]interface{}{
+		"decision_json_retries": cfg.AI.DecisionJSONRetries,
+		"image_quota_cooldown":  cfg.AI.ImageQuotaCooldown,
+	}
 
 	switch cfg.AI.Provider {
 	case "deepseek":
@@ -139,6 +185,7 @@ func main() {
 
 	// 初始化通知服务
 	notificationService := services.NewNotificationService(eventService)
+	notificationService.SetBatchWindow(cfg.Server.Notification.BatchWindow)
 	if err := notificationService.Start(); err != nil {
 		logger.Fatal("Failed to start notification service", zap.Error(err))
 	}
@@ -159,9 +206,43 @@ func main() {
 
 	// 设置风控服务到任务调度器
 	taskScheduler.SetRiskControlService(riskControlService)
+	connectionPool.SetAccountErrorHandler(riskControlService)
+	if cfg.RiskControl.CircuitBreaker.Enabled {
+		taskScheduler.SetCircuitBreaker(scheduler.NewCircuitBreaker(scheduler.CircuitBreakerConfig{
+			FailureThreshold: cfg.RiskControl.CircuitBreaker.FailureThreshold,
+			Window:           cfg.RiskControl.CircuitBreaker.Window,
+			Cooldown:         cfg.RiskControl.CircuitBreaker.Cooldown,
+		}))
+	}
+	taskScheduler.SetTaskRetry(cfg.Telegram.TaskRetry.MaxRetries, cfg.Telegram.TaskRetry.Delay)
+	taskScheduler.SetPriorityAging(cfg.Telegram.TaskQueue.PriorityAging)
+	taskScheduler.SetMaxConcurrentPerAccount(cfg.Telegram.TaskQueue.MaxConcurrentPerAccount)
+	taskScheduler.SetScheduledWarmUp(cfg.Telegram.TaskQueue.WarmUpLead)
+	taskScheduler.SetResultRetention(cfg.Telegram.TaskResult.MaxBytes, cfg.Telegram.TaskResult.MaxBytesByType, cfg.Telegram.TaskResult.SampleSize)
+	taskScheduler.SetBroadcastStagger(cfg.Telegram.Broadcast.StaggerBase, cfg.Telegram.Broadcast.StaggerJitter)
+	taskScheduler.SetGroupSendLimiter(telegram.NewGroupSendLimiter(redisClient, cfg.Telegram.Broadcast.MaxMessagesPerGroupPerDay))
+	telegram.SetBroadcastMediaDir(cfg.Telegram.Broadcast.MediaDir)
+	taskScheduler.SetJoinRetryPolicy(telegram.JoinRetryPolicy{
+		Interval:   cfg.Telegram.Broadcast.JoinInterval,
+		MaxRetries: cfg.Telegram.Broadcast.JoinFloodMaxRetries,
+		MaxWait:    cfg.Telegram.Broadcast.JoinFloodMaxWait,
+	})
+	taskScheduler.SetScenarioTriggerQueueSize(cfg.Telegram.Scenario.TriggerQueueSize)
+	taskScheduler.SetScenarioDecisionConcurrency(cfg.Telegram.Scenario.MaxConcurrentDecisions, cfg.Telegram.Scenario.DecisionQueueWait)
+	taskScheduler.SetScenarioMessageCache(telegram.NewMessageCache(cfg.Telegram.Scenario.MessageCacheMax))
+	taskScheduler.SetAgentMemory(agentMemoryRepo, cfg.Telegram.Scenario.MemoryMaxChars, cfg.Telegram.Scenario.MemoryTTL)
 	accountService := services.NewAccountService(accountRepo, proxyRepo, connectionPool)
 	proxyService := services.NewProxyService(proxyRepo)
+	proxyService.SetDeletePolicy(cfg.Telegram.Proxy.DeletePolicy)
+	proxyService.SetNotificationService(notificationService)
+	proxyService.SetConnectionPool(connectionPool)
+	proxyService.SetMaxAccountsPerProxy(cfg.Telegram.Proxy.MaxAccounts)
+	models.SetProxySecretPolicy(models.ProxySecretPolicy{
+		SecretsDir:  cfg.Telegram.Proxy.SecretsDir,
+		AllowedEnvs: cfg.Telegram.Proxy.AllowedPasswordEnvs,
+	})
 	taskService := services.NewTaskService(taskRepo, accountRepo)
+	taskService.SetScenarioLimits(cfg.Telegram.Scenario.MaxAgents, cfg.Telegram.Scenario.MaxTotalActiveRate)
 
 	// 将任务调度器设置到任务服务中
 	taskService.SetTaskScheduler(taskScheduler)
@@ -169,9 +250,13 @@ func main() {
 
 	// 初始化验证码服务
 	verifyCodeService := services.NewVerifyCodeService(accountRepo, userRepo, verifyCodeRepo, connectionPool, logger)
+	verifyCodeService.SetRedisClient(redisClient)
 	logger.Info("Verify code service initialized")
 
 	statsService := services.NewStatsService(userRepo, accountRepo, taskRepo, proxyRepo)
+	adminService := services.NewAdminService(adminRepo, connectionPool, taskScheduler)
+	batchService := services.NewBatchService(batchRepo, taskRepo, proxyRepo, accountService, taskService)
+	batchService.SetExportStorage(cfg.Server.Export.Dir, cfg.Server.Export.TTL)
 
 	// 初始化定时任务服务
 	cronService := cron.NewCronService(taskService, accountService, riskControlService, userRepo, taskRepo, accountRepo)
@@ -181,6 +266,7 @@ func main() {
 	// 初始化处理器
 	authHandler := handlers.NewAuthHandler(authService)
 	accountHandler := handlers.NewAccountHandler(accountService)
+	accountHandler.SetBatchService(batchService)
 	taskHandler := handlers.NewTaskHandler(taskService)
 	taskHandler.SetTaskLogService(taskLogService) // 注入任务日志服务
 	proxyHandler := handlers.NewProxyHandler(proxyService)
@@ -189,7 +275,9 @@ func main() {
 
 	aiHandler := handlers.NewAIHandler(aiService)
 	statsHandler := handlers.NewStatsHandler(statsService)
+	adminHandler := handlers.NewAdminHandler(adminService)
 	settingsHandler := handlers.NewSettingsHandler(riskControlService)
+	batchHandler := handlers.NewBatchHandler(batchService)
 
 	// 设置Gin模式
 	if cfg.Logging.Level == "debug" {
@@ -212,8 +300,10 @@ func main() {
 
 	// 注册路由
 	routes.RegisterAuthRoutes(router, authHandler)
-	routes.RegisterAPIRoutes(router, accountHandler, taskHandler, proxyHandler, moduleHandler, statsHandler, settingsHandler, aiHandler, authService, cfg)
+	routes.RegisterAPIRoutes(router, accountHandler, taskHandler, proxyHandler, moduleHandler, statsHandler, settingsHandler, aiHandler, authService, redisClient, cfg)
 	routes.SetupVerifyCodeRoutes(router, verifyCodeHandler, authService)
+	routes.SetupBatchRoutes(router, batchHandler, authService)
+	routes.SetupAdminRoutes(router, adminHandler, authService)
 	routes.RegisterWebSocketRoutes(router, redisClient, authService, notificationService)
 
 	// 注册指标端点
@@ -299,6 +389,13 @@ func main() {
 	taskScheduler.Stop()
 	logger.Info("Task scheduler stopped")
 
+	// 停止导出文件清理
+	batchService.Stop()
+
+	// 关闭连接池（先等待执行中的任务完成，再持久化Session）
+	connectionPool.Close(ctx)
+	logger.Info("Connection pool closed")
+
 	// 停止通知服务
 	if err := notificationService.Stop(); err != nil {
 		logger.Error("Failed to stop notification service", zap.Error(err))
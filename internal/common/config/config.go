@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -22,6 +23,19 @@ type Config struct {
 type ServerConfig struct {
 	WebAPI ServiceConfig `mapstructure:"web_api"`
 	// 注意：TGManager、TaskScheduler、AIService 已废弃，所有功能集成在 WebAPI 中
+	Notification NotificationConfig `mapstructure:"notification"`
+	Export       ExportConfig       `mapstructure:"export"`
+}
+
+// ExportConfig 数据导出文件配置
+type ExportConfig struct {
+	Dir string        `mapstructure:"dir"` // 导出文件目录，为空时使用系统临时目录
+	TTL time.Duration `mapstructure:"ttl"` // 导出文件保留时间，过期后自动删除
+}
+
+// NotificationConfig WebSocket 通知推送配置
+type NotificationConfig struct {
+	BatchWindow time.Duration `mapstructure:"batch_window"` // 任务日志合并推送窗口，0 表示逐条推送
 }
 
 // ServiceConfig 单个服务配置
@@ -74,13 +88,80 @@ type TelegramConfig struct {
 	APIHash        string               `mapstructure:"api_hash"`
 	ConnectionPool ConnectionPoolConfig `mapstructure:"connection_pool"`
 	RateLimit      RateLimitConfig      `mapstructure:"rate_limit"`
+	Scenario       ScenarioConfig       `mapstructure:"scenario"`
+	Proxy          ProxyConfig          `mapstructure:"proxy"`
+	Heartbeat      HeartbeatConfig      `mapstructure:"heartbeat"`
+	Auto2FA        Auto2FAConfig        `mapstructure:"auto_2fa"`
+	Device         DeviceConfig         `mapstructure:"device"`
+	TaskResult     TaskResultConfig     `mapstructure:"task_result"`
+	TaskRetry      TaskRetryConfig      `mapstructure:"task_retry"`
+	TaskQueue      TaskQueueConfig      `mapstructure:"task_queue"`
+	Broadcast      BroadcastConfig      `mapstructure:"broadcast"`
+	AutoAcceptTOS  bool                 `mapstructure:"auto_accept_tos"` // 连接/检查时自动接受待接受的服务条款，关闭时仅在健康报告中提示
+}
+
+// BroadcastConfig 多账号群发配置，任务可通过 stagger_base_seconds / stagger_jitter_seconds 覆盖
+type BroadcastConfig struct {
+	StaggerBase   time.Duration `mapstructure:"stagger_base"`   // 相邻账号的启动间隔，第 i 个账号在任务开始 i*stagger_base 后启动
+	StaggerJitter time.Duration `mapstructure:"stagger_jitter"` // 每个账号启动偏移的随机抖动上限，两者均为0表示不错峰
+	// MaxMessagesPerGroupPerDay 单个账号每天向同一群组发送的上限（Redis 计数，跨任务共享），0 表示不限制
+	MaxMessagesPerGroupPerDay int `mapstructure:"max_messages_per_group_per_day"`
+	// 自动加群时邀请链接加群的节奏，与发送限制分开；任务可通过 join_interval_seconds 覆盖间隔
+	JoinInterval        time.Duration `mapstructure:"join_interval"`          // 相邻两次加群的最小间隔
+	JoinFloodMaxRetries int           `mapstructure:"join_flood_max_retries"` // 加群遇到 FLOOD_WAIT 时的最大重试次数
+	JoinFloodMaxWait    time.Duration `mapstructure:"join_flood_max_wait"`    // 单次 FLOOD_WAIT 超过该时长时放弃该群组
+	// MediaDir 群发媒体文件目录，任务的 media[].file 只能引用该目录下的相对路径，为空时不支持 file
+	MediaDir string `mapstructure:"media_dir"`
+}
+
+// TaskResultConfig 任务结果保存配置，超过上限时将最大的明细字段摘要为统计和样本，完整明细写入任务日志
+type TaskResultConfig struct {
+	MaxBytes       int            `mapstructure:"max_bytes"`         // 默认上限（JSON 字节数），0 表示不限制
+	MaxBytesByType map[string]int `mapstructure:"max_bytes_by_type"` // 按任务类型覆盖上限
+	SampleSize     int            `mapstructure:"sample_size"`       // 摘要中保留的明细条数
+}
+
+// DeviceConfig 连接默认上报的设备信息，为空的字段按账号从常见设备中固定挑选
+type DeviceConfig struct {
+	DeviceModel   string `mapstructure:"device_model"`
+	SystemVersion string `mapstructure:"system_version"`
+	AppVersion    string `mapstructure:"app_version"`
+	LangCode      string `mapstructure:"lang_code"`
+}
+
+// TaskRetryConfig 所有账号均执行失败时整个任务的自动重试配置，与单账号重试相互独立
+type TaskRetryConfig struct {
+	MaxRetries int           `mapstructure:"max_retries"` // 最大重试次数，0 表示不重试
+	Delay      time.Duration `mapstructure:"delay"`       // 每次重试前的等待时间
+}
+
+// TaskQueueConfig 任务队列配置，按优先级出队，同优先级先提交先执行
+type TaskQueueConfig struct {
+	PriorityAging           time.Duration `mapstructure:"priority_aging"`             // 排队每满该时长优先级加 1，避免低优先级任务饿死，0 表示不老化
+	MaxConcurrentPerAccount int           `mapstructure:"max_concurrent_per_account"` // 单个账号同时参与的任务数上限，0 表示不限制
+	WarmUpLead              time.Duration `mapstructure:"warm_up_lead"`               // 定时任务在计划执行前该时长内预热账号连接，0 表示不预热
 }
 
 // ConnectionPoolConfig 连接池配置
 type ConnectionPoolConfig struct {
-	MaxConnections  int           `mapstructure:"max_connections"`
-	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
-	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+	MaxConnections       int           `mapstructure:"max_connections"`
+	IdleTimeout          time.Duration `mapstructure:"idle_timeout"`
+	CleanupInterval      time.Duration `mapstructure:"cleanup_interval"`
+	ShutdownFlushTimeout time.Duration `mapstructure:"shutdown_flush_timeout"` // 关闭时等待Session落盘的时间
+	StatusDebounce       time.Duration `mapstructure:"status_debounce"`        // 在线状态需稳定多久才写入数据库，0 表示立即写入
+	APIMetrics           bool          `mapstructure:"api_metrics"`            // 是否统计每次 MTProto 调用的耗时
+	SlowCallThreshold    time.Duration `mapstructure:"slow_call_threshold"`    // 超过该耗时的调用记录警告日志，0 表示不记录
+	AlwaysRecreate       bool          `mapstructure:"always_recreate"`        // 账号配置更新时总是重建连接，默认只在代理/Session/手机号变化时重建
+	TaskSlotWait         time.Duration `mapstructure:"task_slot_wait"`         // 账号忙碌（如场景任务发言中）时任务排队等待的最长时间，0 表示立即失败
+	WarmTarget           int           `mapstructure:"warm_target"`            // 按使用热度保持连接的账号数，0 表示不预热
+	WarmInterval         time.Duration `mapstructure:"warm_interval"`          // 预热连接的维护间隔
+
+	ReconnectJitterPercent  int           `mapstructure:"reconnect_jitter_percent"`   // 重连延迟的随机抖动百分比，0 表示不抖动
+	PeerCacheTTL            time.Duration `mapstructure:"peer_cache_ttl"`             // 每个账号解析目标（用户名/链接/数字ID）结果的缓存时间
+	MaxReconnectAttempts    int           `mapstructure:"max_reconnect_attempts"`     // 连接断开后的最大重连次数
+	InitialReconnectDelay   time.Duration `mapstructure:"initial_reconnect_delay"`    // 第一次重连前的等待时间，之后按指数退避
+	MaxReconnectDelay       time.Duration `mapstructure:"max_reconnect_delay"`        // 重连等待时间上限
+	FloodWaitRetryThreshold time.Duration `mapstructure:"flood_wait_retry_threshold"` // 不超过该时长的 FLOOD_WAIT 自动等待后重试，0 表示不重试
 }
 
 // RateLimitConfig 速率限制配置
@@ -90,12 +171,69 @@ type RateLimitConfig struct {
 	CooldownDuration  time.Duration `mapstructure:"cooldown_duration"`
 }
 
+// ScenarioConfig 智能体场景配置
+type ScenarioConfig struct {
+	MaxAgents          int     `mapstructure:"max_agents"`            // 单个场景最大智能体数量
+	MaxTotalActiveRate float64 `mapstructure:"max_total_active_rate"` // 所有智能体活跃度之和上限
+	TriggerQueueSize   int     `mapstructure:"trigger_queue_size"`    // 消息触发队列容量
+	MessageCacheMax    int     `mapstructure:"message_cache_max"`     // 所有场景共享的消息缓存总条数上限，超出按 LRU 淘汰
+
+	MaxConcurrentDecisions int           `mapstructure:"max_concurrent_decisions"` // 单个场景同时进行的智能体决策上限，场景可单独覆盖
+	DecisionQueueWait      time.Duration `mapstructure:"decision_queue_wait"`      // 决策名额已满时触发的最长排队时间，超时丢弃，0 表示立即丢弃
+
+	MemoryMaxChars int           `mapstructure:"memory_max_chars"` // 智能体记忆摘要最大字符数，超出时丢弃最早的内容
+	MemoryTTL      time.Duration `mapstructure:"memory_ttl"`       // 智能体记忆有效期，超过未更新的记忆会被清除
+}
+
+// HeartbeatConfig 账号在线心跳配置，仅对开启 heartbeat_enabled 的账号生效
+type HeartbeatConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`      // 是否启用心跳定时任务
+	Interval    time.Duration `mapstructure:"interval"`     // 平均心跳间隔
+	Jitter      time.Duration `mapstructure:"jitter"`       // 间隔随机浮动范围，避免所有账号同时上线
+	MaxPerRun   int           `mapstructure:"max_per_run"`  // 每轮最多处理的账号数
+	MaxStagger  time.Duration `mapstructure:"max_stagger"`  // 同一轮内账号之间的最大随机间隔
+	ActiveHours []int         `mapstructure:"active_hours"` // 允许心跳的小时范围 [开始, 结束)，为空表示全天
+}
+
+// Auto2FAConfig 为未开启 2FA 的账号自动设置密码，会修改账号的安全设置，
+// 只处理在风控设置中开启 auto_2fa_enabled 的用户的账号，且必须配置 encryption_key
+type Auto2FAConfig struct {
+	Password      string        `mapstructure:"password"`       // 统一使用的密码，为空时每个账号随机生成
+	Hint          string        `mapstructure:"hint"`           // 密码提示
+	EncryptionKey string        `mapstructure:"encryption_key"` // 2FA 密码加密保存使用的密钥
+	Interval      time.Duration `mapstructure:"interval"`       // 检查间隔
+	MaxPerRun     int           `mapstructure:"max_per_run"`    // 每轮最多处理的账号数
+}
+
+// ProxyConfig 代理管理配置
+type ProxyConfig struct {
+	DeletePolicy    string `mapstructure:"delete_policy"`    // 删除仍绑定账号的代理时: block 拒绝删除, unbind 解除绑定并通知
+	CleanupDangling bool   `mapstructure:"cleanup_dangling"` // 定时任务发现失效绑定时是否自动解除，否则只报告
+	MaxAccounts     int    `mapstructure:"max_accounts"`     // 单个代理最多绑定的账号数（代理迁移时校验），0 表示不限制
+	// SecretsDir password_ref 的 file: 引用只能读取该目录下的文件，为空时禁用 file: 引用
+	SecretsDir string `mapstructure:"secrets_dir"`
+	// AllowedPasswordEnvs password_ref 的 env: 引用允许读取的环境变量，为空时禁用 env: 引用
+	AllowedPasswordEnvs []string `mapstructure:"allowed_password_envs"`
+}
+
 // AIConfig AI服务配置
 type AIConfig struct {
 	Provider string         `mapstructure:"provider"` // openai, gemini, deepseek
 	OpenAI   OpenAIConfig   `mapstructure:"openai"`
 	Gemini   GeminiConfig   `mapstructure:"gemini"`
 	DeepSeek DeepSeekConfig `mapstructure:"deepseek"`
+
+	RateLimit AIRateLimitConfig `mapstructure:"rate_limit"`
+
+	DecisionJSONRetries int `mapstructure:"decision_json_retries"` // 智能体决策返回非法 JSON 时的重试次数，0 表示不重试
+
+	ImageQuotaCooldown time.Duration `mapstructure:"image_quota_cooldown"` // 图片生成额度用完后直接拒绝请求的时长，0 表示每次都请求接口
+}
+
+// AIRateLimitConfig AI 接口按用户限流配置
+type AIRateLimitConfig struct {
+	Requests int           `mapstructure:"requests"` // 每个时间窗口内单个用户允许的请求数，<=0 表示不限流
+	Window   time.Duration `mapstructure:"window"`   // 时间窗口
 }
 
 // OpenAIConfig OpenAI配置
@@ -127,11 +265,20 @@ type DeepSeekConfig struct {
 
 // RiskControlConfig 风控配置
 type RiskControlConfig struct {
+	Enabled          bool                 `mapstructure:"enabled"`
+	CheckInterval    time.Duration        `mapstructure:"check_interval"`
+	MaxFailures      int                  `mapstructure:"max_failures"`
+	CooldownDuration time.Duration        `mapstructure:"cooldown_duration"`
+	HealthThreshold  float64              `mapstructure:"health_threshold"`
+	CircuitBreaker   CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+// CircuitBreakerConfig 账号熔断配置
+type CircuitBreakerConfig struct {
 	Enabled          bool          `mapstructure:"enabled"`
-	CheckInterval    time.Duration `mapstructure:"check_interval"`
-	MaxFailures      int           `mapstructure:"max_failures"`
-	CooldownDuration time.Duration `mapstructure:"cooldown_duration"`
-	HealthThreshold  float64       `mapstructure:"health_threshold"`
+	FailureThreshold int           `mapstructure:"failure_threshold"` // 窗口内连续失败次数阈值
+	Window           time.Duration `mapstructure:"window"`            // 统计窗口
+	Cooldown         time.Duration `mapstructure:"cooldown"`          // 熔断时长
 }
 
 // LoggingConfig 日志配置
@@ -213,6 +360,9 @@ func setDefaults() {
 	// 注意：所有功能已集成在 web_api 服务中，只需一个端口
 	viper.SetDefault("server.web_api.host", "0.0.0.0")
 	viper.SetDefault("server.web_api.port", 8080)
+	viper.SetDefault("server.notification.batch_window", "500ms")
+	viper.SetDefault("server.export.dir", "")
+	viper.SetDefault("server.export.ttl", "24h")
 
 	// 数据库默认配置
 	viper.SetDefault("database.mysql.host", "localhost")
@@ -230,16 +380,78 @@ func setDefaults() {
 	viper.SetDefault("telegram.connection_pool.max_connections", 1000)
 	viper.SetDefault("telegram.connection_pool.idle_timeout", "30m")
 	viper.SetDefault("telegram.connection_pool.cleanup_interval", "5m")
+	viper.SetDefault("telegram.connection_pool.shutdown_flush_timeout", "5s")
+	viper.SetDefault("telegram.connection_pool.status_debounce", "3s")
+	viper.SetDefault("telegram.connection_pool.api_metrics", true)
+	viper.SetDefault("telegram.connection_pool.slow_call_threshold", "3s")
+	viper.SetDefault("telegram.connection_pool.always_recreate", false)
+	viper.SetDefault("telegram.connection_pool.task_slot_wait", "30s")
+	viper.SetDefault("telegram.connection_pool.warm_target", 0)
+	viper.SetDefault("telegram.auto_accept_tos", false)
+	viper.SetDefault("telegram.connection_pool.warm_interval", "1m")
+	viper.SetDefault("telegram.connection_pool.reconnect_jitter_percent", 20)
+	viper.SetDefault("telegram.connection_pool.peer_cache_ttl", "5m")
+	viper.SetDefault("telegram.connection_pool.max_reconnect_attempts", 3)
+	viper.SetDefault("telegram.connection_pool.initial_reconnect_delay", "10s")
+	viper.SetDefault("telegram.connection_pool.max_reconnect_delay", "30s")
+	viper.SetDefault("telegram.connection_pool.flood_wait_retry_threshold", "60s")
 
 	viper.SetDefault("telegram.rate_limit.messages_per_minute", 30)
 	viper.SetDefault("telegram.rate_limit.burst_size", 5)
 	viper.SetDefault("telegram.rate_limit.cooldown_duration", "1m")
 
+	viper.SetDefault("telegram.scenario.max_agents", 10)
+	viper.SetDefault("telegram.scenario.max_total_active_rate", 3.0)
+	viper.SetDefault("telegram.scenario.trigger_queue_size", 100)
+	viper.SetDefault("telegram.scenario.message_cache_max", 5000)
+	viper.SetDefault("telegram.scenario.max_concurrent_decisions", 3)
+	viper.SetDefault("telegram.scenario.decision_queue_wait", "5s")
+	viper.SetDefault("telegram.scenario.memory_max_chars", 2000)
+	viper.SetDefault("telegram.scenario.memory_ttl", "720h")
+
+	viper.SetDefault("telegram.proxy.delete_policy", "unbind")
+	viper.SetDefault("telegram.proxy.cleanup_dangling", false)
+	viper.SetDefault("telegram.proxy.max_accounts", 0)
+	viper.SetDefault("telegram.proxy.secrets_dir", "")
+	viper.SetDefault("telegram.proxy.allowed_password_envs", []string{})
+	viper.SetDefault("telegram.task_retry.max_retries", 0)
+	viper.SetDefault("telegram.task_retry.delay", "2m")
+	viper.SetDefault("telegram.task_queue.priority_aging", "5m")
+	viper.SetDefault("telegram.task_queue.max_concurrent_per_account", 3)
+	viper.SetDefault("telegram.task_queue.warm_up_lead", "2m")
+	viper.SetDefault("telegram.heartbeat.enabled", true)
+	viper.SetDefault("telegram.heartbeat.interval", "30m")
+	viper.SetDefault("telegram.heartbeat.jitter", "15m")
+	viper.SetDefault("telegram.heartbeat.max_per_run", 50)
+	viper.SetDefault("telegram.heartbeat.max_stagger", "20s")
+	viper.SetDefault("telegram.heartbeat.active_hours", []int{8, 24})
+
+	viper.SetDefault("telegram.auto_2fa.interval", "30m")
+	viper.SetDefault("telegram.auto_2fa.max_per_run", 10)
+	viper.SetDefault("telegram.device.device_model", "")
+	viper.SetDefault("telegram.device.system_version", "")
+	viper.SetDefault("telegram.device.app_version", "")
+	viper.SetDefault("telegram.device.lang_code", "")
+	viper.SetDefault("telegram.task_result.max_bytes", 0)
+	viper.SetDefault("telegram.task_result.max_bytes_by_type", map[string]int{})
+	viper.SetDefault("telegram.task_result.sample_size", 20)
+	viper.SetDefault("telegram.broadcast.stagger_base", "0s")
+	viper.SetDefault("telegram.broadcast.stagger_jitter", "0s")
+	viper.SetDefault("telegram.broadcast.join_interval", "5s")
+	viper.SetDefault("telegram.broadcast.join_flood_max_retries", 2)
+	viper.SetDefault("telegram.broadcast.join_flood_max_wait", "2m")
+	viper.SetDefault("telegram.broadcast.max_messages_per_group_per_day", 0)
+	viper.SetDefault("telegram.broadcast.media_dir", "")
+
 	// AI默认配置
 	viper.SetDefault("ai.openai.model", "gpt-3.5-turbo")
 	viper.SetDefault("ai.openai.max_tokens", 1000)
 	viper.SetDefault("ai.openai.temperature", 0.7)
 	viper.SetDefault("ai.openai.timeout", "30s")
+	viper.SetDefault("ai.rate_limit.requests", 0)
+	viper.SetDefault("ai.rate_limit.window", "1m")
+	viper.SetDefault("ai.decision_json_retries", 1)
+	viper.SetDefault("ai.image_quota_cooldown", "10m")
 
 	// 风控默认配置
 	viper.SetDefault("risk_control.enabled", true)
@@ -247,6 +459,10 @@ func setDefaults() {
 	viper.SetDefault("risk_control.max_failures", 3)
 	viper.SetDefault("risk_control.cooldown_duration", "30m")
 	viper.SetDefault("risk_control.health_threshold", 0.3)
+	viper.SetDefault("risk_control.circuit_breaker.enabled", false)
+	viper.SetDefault("risk_control.circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("risk_control.circuit_breaker.window", "30m")
+	viper.SetDefault("risk_control.circuit_breaker.cooldown", "1h")
 
 	// 日志默认配置
 	viper.SetDefault("logging.level", "info")
@@ -304,3 +520,43 @@ func (c *Config) GetServiceAddr(service string) string {
 	}
 	return ""
 }
+
+// sensitiveKeyParts 配置键中包含这些片段时视为敏感信息
+var sensitiveKeyParts = []string{"password", "secret", "api_key", "api_hash", "encryption_key"}
+
+// maskedValue 敏感配置的掩码
+const maskedValue = "******"
+
+// EffectiveSettings 返回当前生效的配置（默认值、配置文件和环境变量合并后），密码/密钥等敏感值已掩码
+func EffectiveSettings() map[string]interface{} {
+	return maskSettings(viper.AllSettings())
+}
+
+// maskSettings 递归掩码敏感配置项
+func maskSettings(settings map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			masked[key] = maskSettings(v)
+		default:
+			if isSensitiveKey(key) && fmt.Sprint(v) != "" {
+				masked[key] = maskedValue
+			} else {
+				masked[key] = v
+			}
+		}
+	}
+	return masked
+}
+
+// isSensitiveKey 判断配置键是否为敏感信息
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -73,6 +73,11 @@ func autoMigrate(db *gorm.DB) error {
 		&models.ProxyIP{},
 		&models.RiskLog{},
 		&models.VerifyCodeSession{},
+		&models.BatchJob{},
+		&models.AgentMemory{},
+		&models.AccountStatusHistory{},
+		&models.ProxyBindingHistory{},
+		&models.PeerAccessHash{},
 	)
 }
 
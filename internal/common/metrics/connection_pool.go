@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Telegram连接池指标
+var (
+	TelegramReconnectAttemptsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_reconnect_attempts_total",
+			Help: "Total number of Telegram reconnect attempts",
+		},
+		[]string{"account_id", "result"}, // result: scheduled 已安排重连 / gave_up 超过最大重连次数
+	)
+
+	TelegramConnectDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "telegram_connect_duration_seconds",
+			Help:    "Time from starting a Telegram client until the connection is established",
+			Buckets: []float64{0.5, 1.0, 2.5, 5.0, 10.0, 20.0, 30.0, 60.0},
+		},
+		[]string{"route"}, // route: proxy 经代理 / direct 直连
+	)
+
+	TelegramPoolTaskDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "telegram_pool_task_duration_seconds",
+			Help:    "Duration of tasks executed through the Telegram connection pool, including connection wait",
+			Buckets: []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0, 120.0, 300.0},
+		},
+		[]string{"task_type", "status"},
+	)
+)
+
+// ConnectionPoolStats 连接池统计来源，由 telegram.ConnectionPool 实现（避免循环依赖）
+type ConnectionPoolStats interface {
+	GetStats() map[string]interface{}
+}
+
+// connectionPoolCollector 抓取时读取连接池当前统计
+type connectionPoolCollector struct {
+	pool     ConnectionPoolStats
+	byStatus *prometheus.Desc
+	active   *prometheus.Desc
+	busy     *prometheus.Desc
+}
+
+// RegisterConnectionPool 注册连接池指标：按状态的连接数、活跃连接数和执行任务中的连接数
+func RegisterConnectionPool(pool ConnectionPoolStats) error {
+	return prometheus.Register(&connectionPoolCollector{
+		pool: pool,
+		byStatus: prometheus.NewDesc("telegram_pool_connections",
+			"Number of Telegram connections in the pool by status", []string{"status"}, nil),
+		active: prometheus.NewDesc("telegram_pool_connections_active",
+			"Number of active Telegram connections in the pool", nil, nil),
+		busy: prometheus.NewDesc("telegram_pool_connections_busy",
+			"Number of Telegram connections currently running a task", nil, nil),
+	})
+}
+
+// Describe 实现 prometheus.Collector
+func (c *connectionPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.byStatus
+	ch <- c.active
+	ch <- c.busy
+}
+
+// Collect 实现 prometheus.Collector
+func (c *connectionPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.GetStats()
+
+	if byStatus, ok := stats["connections_by_status"].(map[string]int); ok {
+		for status, count := range byStatus {
+			ch <- prometheus.MustNewConstMetric(c.byStatus, prometheus.GaugeValue, float64(count), status)
+		}
+	}
+	if active, ok := stats["active_connections"].(int); ok {
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(active))
+	}
+	if busy, ok := stats["busy_connections"].(int); ok {
+		ch <- prometheus.MustNewConstMetric(c.busy, prometheus.GaugeValue, float64(busy))
+	}
+}
@@ -87,7 +87,7 @@ var (
 			Help:    "Telegram API call duration in seconds",
 			Buckets: []float64{0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
 		},
-		[]string{"method"},
+		[]string{"method", "route"}, // route: proxy 经代理 / direct 直连
 	)
 
 	// 代理相关指标
@@ -132,6 +132,36 @@ var (
 		[]string{"cache_type"},
 	)
 
+	// 智能体消息缓存指标
+	AgentMessageCacheSize = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "agent_message_cache_messages",
+			Help: "Number of chat messages held in the agent message cache",
+		},
+	)
+
+	AgentMessageCacheEvictions = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "agent_message_cache_evictions_total",
+			Help: "Total number of chat messages evicted from the agent message cache",
+		},
+	)
+
+	// 智能体决策并发指标
+	AgentDecisionsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "agent_decisions_in_flight",
+			Help: "Number of agent decisions currently running across all scenarios",
+		},
+	)
+
+	AgentDecisionsDropped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "agent_decisions_dropped_total",
+			Help: "Total number of message triggers dropped because the scenario decision limit was reached",
+		},
+	)
+
 	// 数据库相关指标
 	DatabaseConnectionsActive = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -208,9 +238,9 @@ func (m *MetricsService) UpdateTelegramConnections(accountID uint64, connections
 }
 
 // RecordTelegramAPICall 记录Telegram API调用
-func (m *MetricsService) RecordTelegramAPICall(method, status string, duration float64) {
+func (m *MetricsService) RecordTelegramAPICall(method, route, status string, duration float64) {
 	TelegramAPICallsTotal.WithLabelValues(method, status).Inc()
-	TelegramAPICallDuration.WithLabelValues(method).Observe(duration)
+	TelegramAPICallDuration.WithLabelValues(method, route).Observe(duration)
 }
 
 // UpdateProxyCount 更新代理数量
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/common/response"
+)
+
+// AIRateLimit AI 接口按用户限流中间件，计数存放在 Redis 以便多实例共享
+// 超限时返回 HTTP 429 和 Retry-After，正常请求附带剩余额度响应头；limit<=0 表示不限流
+func AIRateLimit(redisClient *redis.Client, limit int, window time.Duration) gin.HandlerFunc {
+	log := logger.Get().Named("ai_rate_limit")
+
+	return func(c *gin.Context) {
+		if limit <= 0 || window <= 0 {
+			c.Next()
+			return
+		}
+
+		var key string
+		if userID, ok := c.Get("user_id"); ok {
+			key = fmt.Sprintf("rate_limit:ai:user:%v", userID)
+		} else {
+			key = fmt.Sprintf("rate_limit:ai:ip:%s", c.ClientIP())
+		}
+
+		ctx := context.Background()
+
+		// 先自增再判断，避免多实例并发时读后写的竞争
+		current, err := redisClient.Incr(ctx, key).Result()
+		if err != nil {
+			log.Error("Failed to update AI rate limit in Redis",
+				zap.String("key", key),
+				zap.Error(err))
+			// Redis出错时允许请求继续
+			c.Next()
+			return
+		}
+		if current == 1 {
+			redisClient.Expire(ctx, key, window)
+		}
+
+		ttl, err := redisClient.TTL(ctx, key).Result()
+		if err != nil || ttl < 0 {
+			// 过期时间丢失时重新设置，防止计数永不过期
+			redisClient.Expire(ctx, key, window)
+			ttl = window
+		}
+		resetAt := time.Now().Add(ttl)
+
+		remaining := int64(limit) - current
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+
+		if current > int64(limit) {
+			retryAfter := int(ttl.Seconds() + 0.999)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			log.Warn("AI rate limit exceeded",
+				zap.String("key", key),
+				zap.String("path", c.FullPath()),
+				zap.Int64("current", current),
+				zap.Int("limit", limit),
+				zap.Int("retry_after", retryAfter))
+
+			response.TooManyRequestsWithRetry(c,
+				fmt.Sprintf("AI 接口调用过于频繁，每 %s 最多 %d 次，请 %d 秒后重试", window, limit, retryAfter),
+				retryAfter)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
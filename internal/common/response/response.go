@@ -3,6 +3,7 @@ package response
 import (
 	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
 
 	"tg_cloud_server/internal/common/errors"
@@ -170,6 +171,16 @@ func TooManyRequests(c *gin.Context, msg ...string) {
 	Error(c, CodeRateLimit, message)
 }
 
+// TooManyRequestsWithRetry 限流响应，返回 HTTP 429 并通过 Retry-After 告知重试等待秒数
+func TooManyRequestsWithRetry(c *gin.Context, msg string, retryAfter int) {
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, &APIResponse{
+		Code: CodeRateLimit,
+		Msg:  msg,
+		Data: map[string]interface{}{"retry_after": retryAfter},
+	})
+}
+
 // UserExists 用户已存在
 func UserExists(c *gin.Context) {
 	Error(c, CodeUserExists, "用户已存在")
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// PhoneInfo 手机号归属信息
+type PhoneInfo struct {
+	CountryCode string // ISO 3166-1 国家代码，如 CN、US
+	Region      string // 地区描述
+	Carrier     string // 运营商
+}
+
+// LookupPhone 解析手机号的国家、地区和运营商，无法解析或号码无效时返回空信息
+func LookupPhone(phone string) PhoneInfo {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return PhoneInfo{}
+	}
+	// 账号手机号通常不带 +，按国际格式解析
+	if !strings.HasPrefix(phone, "+") {
+		phone = "+" + phone
+	}
+
+	num, err := phonenumbers.Parse(phone, "")
+	if err != nil || !phonenumbers.IsValidNumber(num) {
+		return PhoneInfo{}
+	}
+
+	info := PhoneInfo{CountryCode: phonenumbers.GetRegionCodeForNumber(num)}
+	if region, err := phonenumbers.GetGeocodingForNumber(num, "en"); err == nil {
+		info.Region = region
+	}
+	if carrier, err := phonenumbers.GetCarrierForNumber(num, "en"); err == nil {
+		info.Carrier = carrier
+	}
+	return info
+}
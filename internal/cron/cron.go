@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -40,6 +42,8 @@ type CronService struct {
 	taskRepo           repository.TaskRepository
 	accountRepo        repository.AccountRepository
 
+	heartbeatRunning atomic.Bool // 上一轮心跳尚未结束时跳过本轮
+
 	// 连接池接口（可选，用于连接检查）
 	connectionPool interface {
 		GetConnectionStatus(accountID string) ConnectionStatus
@@ -116,6 +120,18 @@ func (s *CronService) Start() error {
 		return err
 	}
 
+	if err := s.addDanglingProxyBindingJob(); err != nil {
+		return err
+	}
+
+	if err := s.addHeartbeatJob(); err != nil {
+		return err
+	}
+
+	if err := s.addAuto2FAJob(); err != nil {
+		return err
+	}
+
 	// 启动cron调度器
 	s.cron.Start()
 	s.logger.Info("Cron service started successfully")
@@ -576,17 +592,7 @@ func (s *CronService) updateAccountStatuses(ctx context.Context) {
 	for _, account := range accounts {
 		needsUpdate := false
 
-		// 检查冷却状态是否应该恢复
-		if account.Status == models.AccountStatusCooling {
-			// 如果账号在冷却状态超过1小时，尝试恢复为正常状态
-			if account.LastCheckAt != nil && now.Sub(*account.LastCheckAt) > 1*time.Hour {
-				account.Status = models.AccountStatusNormal
-				needsUpdate = true
-				s.logger.Info("Account recovered from cooling status",
-					zap.Uint64("account_id", account.ID),
-					zap.String("phone", account.Phone))
-			}
-		}
+		// 冷却状态由风控服务按 cooling_until 恢复（ProcessCoolingRecovery），这里不处理
 
 		// 检查警告状态是否应该恢复
 		if account.Status == models.AccountStatusWarning {
@@ -806,6 +812,212 @@ func (s *CronService) addRiskControlRecoveryJob() error {
 	return nil
 }
 
+// addDanglingProxyBindingJob 添加失效代理绑定检查任务
+func (s *CronService) addDanglingProxyBindingJob() error {
+	// 每小时检查一次指向已删除代理的账号绑定
+	_, err := s.cron.AddFunc("0 30 * * * *", func() {
+		s.logger.Debug("Running dangling proxy binding check job")
+		s.checkDanglingProxyBindings()
+	})
+
+	if err != nil {
+		s.logger.Error("Failed to add dangling proxy binding job", zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Dangling proxy binding job added successfully")
+	return nil
+}
+
+// checkDanglingProxyBindings 检查并报告失效的代理绑定，按配置自动解除
+func (s *CronService) checkDanglingProxyBindings() {
+	accounts, err := s.accountRepo.GetDanglingProxyBindings()
+	if err != nil {
+		s.logger.Error("Failed to get dangling proxy bindings", zap.Error(err))
+		return
+	}
+	if len(accounts) == 0 {
+		return
+	}
+
+	histories := make([]*models.ProxyBindingHistory, 0, len(accounts))
+	byUser := make(map[uint64]int)
+	for _, account := range accounts {
+		histories = append(histories, &models.ProxyBindingHistory{
+			AccountID:  account.ID,
+			UserID:     account.UserID,
+			OldProxyID: account.ProxyID,
+			Reason:     models.ProxyBindingReasonDangling,
+			Actor:      models.ProxyBindingActorSystem,
+		})
+		byUser[account.UserID]++
+		s.logger.Warn("Account bound to missing proxy",
+			zap.Uint64("account_id", account.ID),
+			zap.Uint64("user_id", account.UserID),
+			zap.String("phone", account.Phone),
+			zap.Uint64p("proxy_id", account.ProxyID))
+	}
+
+	s.logger.Warn("Dangling proxy bindings detected",
+		zap.Int("account_count", len(accounts)),
+		zap.Int("user_count", len(byUser)))
+
+	if !s.config.Telegram.Proxy.CleanupDangling {
+		return
+	}
+
+	if err := s.accountRepo.UpdateProxyBindingsWithHistory(histories); err != nil {
+		s.logger.Error("Failed to clear dangling proxy bindings", zap.Error(err))
+		return
+	}
+	s.logger.Info("Dangling proxy bindings cleared", zap.Int("account_count", len(histories)))
+}
+
+// addHeartbeatJob 添加账号在线心跳任务
+func (s *CronService) addHeartbeatJob() error {
+	if !s.config.Telegram.Heartbeat.Enabled {
+		s.logger.Info("Account heartbeat job disabled")
+		return nil
+	}
+
+	// 每分钟检查一次到期的账号，实际心跳间隔由配置的间隔和随机浮动决定
+	_, err := s.cron.AddFunc("0 * * * * *", func() {
+		if !s.heartbeatRunning.CompareAndSwap(false, true) {
+			s.logger.Debug("Previous heartbeat round still running, skipping")
+			return
+		}
+		defer s.heartbeatRunning.Store(false)
+		s.sendAccountHeartbeats()
+	})
+
+	if err != nil {
+		s.logger.Error("Failed to add heartbeat job", zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Account heartbeat job added successfully")
+	return nil
+}
+
+// sendAccountHeartbeats 为到期的账号发送在线心跳，间隔和账号间的先后顺序均随机化，模拟真人上线
+func (s *CronService) sendAccountHeartbeats() {
+	cfg := s.config.Telegram.Heartbeat
+	now := time.Now()
+	if !inActiveHours(cfg.ActiveHours, now.Hour()) {
+		return
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 30 * time.Minute
+	}
+	jitter := cfg.Jitter
+	if jitter >= interval {
+		jitter = interval / 2
+	}
+
+	accounts, err := s.accountRepo.GetHeartbeatAccounts(now.Add(-(interval - jitter)), cfg.MaxPerRun)
+	if err != nil {
+		s.logger.Error("Failed to get heartbeat accounts", zap.Error(err))
+		return
+	}
+
+	sent, failed := 0, 0
+	for _, account := range accounts {
+		// 每个账号的间隔在 [interval-jitter, interval+jitter] 内随机，未到期的留到后续轮次
+		if account.LastHeartbeatAt != nil && jitter > 0 {
+			threshold := interval + time.Duration((rand.Float64()*2-1)*float64(jitter))
+			if time.Since(*account.LastHeartbeatAt) < threshold {
+				continue
+			}
+		}
+
+		if cfg.MaxStagger > 0 && sent+failed > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(cfg.MaxStagger))))
+		}
+
+		if err := s.accountService.SendHeartbeat(account.ID); err != nil {
+			failed++
+			s.logger.Warn("Account heartbeat failed",
+				zap.Uint64("account_id", account.ID),
+				zap.String("phone", account.Phone),
+				zap.Error(err))
+			continue
+		}
+		sent++
+		s.logger.Debug("Account heartbeat sent", zap.Uint64("account_id", account.ID))
+	}
+
+	if sent+failed > 0 {
+		s.logger.Info("Account heartbeat round completed",
+			zap.Int("sent", sent),
+			zap.Int("failed", failed))
+	}
+}
+
+// addAuto2FAJob 添加自动设置 2FA 任务，需配置加密密钥，只处理用户自行开启的账号
+func (s *CronService) addAuto2FAJob() error {
+	cfg := s.config.Telegram.Auto2FA
+	if cfg.EncryptionKey == "" {
+		s.logger.Info("Auto 2FA job disabled, encryption_key is empty")
+		return nil
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 30 * time.Minute
+	}
+	_, err := s.cron.AddFunc(fmt.Sprintf("@every %s", interval), s.setupMissing2FA)
+	if err != nil {
+		s.logger.Error("Failed to add auto 2FA job", zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Auto 2FA job added successfully", zap.Duration("interval", interval))
+	return nil
+}
+
+// setupMissing2FA 为开启自动 2FA 的用户下未开启 2FA 的账号设置密码
+func (s *CronService) setupMissing2FA() {
+	cfg := s.config.Telegram.Auto2FA
+	accounts, err := s.accountRepo.GetAccountsWithout2FA(cfg.MaxPerRun)
+	if err != nil {
+		s.logger.Error("Failed to get accounts without 2FA", zap.Error(err))
+		return
+	}
+
+	secured, failed := 0, 0
+	for _, account := range accounts {
+		if err := s.accountService.AutoSetup2FA(account.ID, cfg.Password, cfg.Hint); err != nil {
+			failed++
+			s.logger.Warn("Auto 2FA setup failed",
+				zap.Uint64("account_id", account.ID),
+				zap.String("phone", account.Phone),
+				zap.Error(err))
+			continue
+		}
+		secured++
+	}
+
+	if secured+failed > 0 {
+		s.logger.Info("Auto 2FA round completed",
+			zap.Int("secured", secured),
+			zap.Int("failed", failed))
+	}
+}
+
+// inActiveHours 判断当前小时是否在 [开始, 结束) 范围内，支持跨零点（如 [22, 6]），未配置时全天有效
+func inActiveHours(hours []int, hour int) bool {
+	if len(hours) != 2 || hours[0] == hours[1] {
+		return true
+	}
+	start, end := hours[0], hours[1]
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
 // addTaskLogCleanupJob 添加任务日志清理任务
 func (s *CronService) addTaskLogCleanupJob() error {
 	// 每天凌晨3点执行任务日志清理
@@ -4,12 +4,15 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,9 +28,15 @@ import (
 type AccountHandler struct {
 	accountService *services.AccountService
 	accountParser  *services.AccountParser
+	batchService   services.BatchService
 	logger         *zap.Logger
 }
 
+// SetBatchService 设置批量任务服务，用于异步上传账号文件
+func (h *AccountHandler) SetBatchService(batchService services.BatchService) {
+	h.batchService = batchService
+}
+
 // NewAccountHandler 创建账号管理处理器
 func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
 	return &AccountHandler{
@@ -97,7 +106,8 @@ func (h *AccountHandler) CreateAccount(c *gin.Context) {
 // @Param page query int false "页码" default(1)
 // @Param limit query int false "每页数量" default(20)
 // @Param status query string false "账号状态过滤"
-// @Param search query string false "搜索关键词（手机号或备注）"
+// @Param search query string false "搜索关键词：手机号、用户名前缀（@开头仅匹配用户名）或 tg_user_id 前缀"
+// @Param fields query string false "返回字段，逗号分隔，如 id,phone,status；未知字段忽略"
 // @Success 200 {object} models.PaginationResponse "账号列表"
 // @Failure 401 {object} map[string]string "未授权"
 // @Failure 500 {object} map[string]string "服务器错误"
@@ -113,14 +123,16 @@ func (h *AccountHandler) GetAccounts(c *gin.Context) {
 	limit := h.getIntParam(c, "limit", 20)
 	status := c.Query("status")
 	search := c.Query("search")
+	countryCode := strings.ToUpper(c.Query("country_code"))
 
 	// 构建过滤器
 	filter := &services.AccountFilter{
-		UserID: userID,
-		Status: status,
-		Search: search,
-		Page:   page,
-		Limit:  limit,
+		UserID:      userID,
+		Status:      status,
+		Search:      search,
+		CountryCode: countryCode,
+		Page:        page,
+		Limit:       limit,
 	}
 
 	// 获取账号列表
@@ -133,9 +145,78 @@ func (h *AccountHandler) GetAccounts(c *gin.Context) {
 		return
 	}
 
+	// 指定了返回字段时只返回这些字段，减小列表响应体积
+	if fields := parseAccountFields(c.Query("fields")); len(fields) > 0 {
+		projected, err := projectAccountSummaries(accounts, fields)
+		if err != nil {
+			h.logger.Error("Failed to project account fields",
+				zap.Uint64("user_id", userID),
+				zap.Error(err))
+			response.InternalError(c, "获取账号列表失败")
+			return
+		}
+		response.Paginated(c, projected, page, limit, total)
+		return
+	}
+
 	response.Paginated(c, accounts, page, limit, total)
 }
 
+// accountListFields 账号列表允许按需返回的字段（对应 AccountSummary 的 JSON 字段名）
+var accountListFields = map[string]bool{
+	"id": true, "phone": true, "status": true, "is_online": true, "proxy_id": true,
+	"country_code": true, "region": true, "is_bidirectional": true, "frozen_until": true,
+	"has_2fa": true, "two_fa_password": true, "consecutive_failures": true, "cooling_until": true,
+	"tg_user_id": true, "username": true, "first_name": true, "last_name": true, "bio": true, "photo_url": true,
+	"last_used_at": true, "last_check_at": true, "created_at": true, "task_count": true, "proxy_name": true,
+	"proxy_ip": true, "proxy_port": true, "proxy_username": true, "proxy_password": true, "proxy_protocol": true,
+	"matched_field": true,
+}
+
+// parseAccountFields 解析 fields 参数，忽略不在白名单中的字段
+func parseAccountFields(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if !accountListFields[field] || seen[field] {
+			continue
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// projectAccountSummaries 将账号摘要投影为只包含指定字段的对象
+func projectAccountSummaries(accounts []*models.AccountSummary, fields []string) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0, len(accounts))
+	for _, account := range accounts {
+		data, err := json.Marshal(account)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal account summary: %w", err)
+		}
+
+		var full map[string]interface{}
+		if err := json.Unmarshal(data, &full); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account summary: %w", err)
+		}
+
+		item := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				item[field] = value
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
 // GetAccount 获取账号详情
 // @Summary 获取账号详情
 // @Description 获取指定TG账号的详细信息
@@ -434,6 +515,205 @@ func (h *AccountHandler) BindProxy(c *gin.Context) {
 	response.SuccessWithMessage(c, "代理绑定成功", account)
 }
 
+// PreviewRecipients 预览发送目标
+// @Summary 预览发送目标
+// @Description 使用指定账号解析目标列表，返回每个目标的解析状态和成员关系，不发送任何消息
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "账号ID"
+// @Param request body models.PreviewRecipientsRequest true "目标列表"
+// @Success 200 {array} models.RecipientPreview "解析结果"
+// @Router /api/v1/accounts/{id}/preview-recipients [post]
+func (h *AccountHandler) PreviewRecipients(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	accountID := h.getIDParam(c, "id")
+	if accountID == 0 {
+		return
+	}
+
+	var req models.PreviewRecipientsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.InvalidParam(c, "请求参数无效："+err.Error())
+		return
+	}
+
+	results, err := h.accountService.PreviewRecipients(userID, accountID, req.Targets)
+	if err != nil {
+		if err == services.ErrAccountNotFound {
+			response.AccountNotFound(c)
+			return
+		}
+		if strings.Contains(err.Error(), "busy") {
+			response.AccountBusy(c)
+			return
+		}
+		h.logger.Error("Failed to preview recipients",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		response.ConnectionFailed(c, "目标预览失败："+err.Error())
+		return
+	}
+
+	response.Success(c, results)
+}
+
+// RefreshAccountInfo 刷新账号资料
+// @Summary 刷新账号资料
+// @Description 使用指定账号重新获取用户名、姓名、简介和头像并保存，不执行完整的账号检查；批量刷新可创建 refresh_info 任务
+// @Tags 账号管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "账号ID"
+// @Success 200 {object} models.TGAccount "更新后的账号"
+// @Router /api/v1/accounts/{id}/refresh-info [post]
+func (h *AccountHandler) RefreshAccountInfo(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	accountID := h.getIDParam(c, "id")
+	if accountID == 0 {
+		return
+	}
+
+	account, err := h.accountService.RefreshAccountInfo(userID, accountID)
+	if err != nil {
+		if err == services.ErrAccountNotFound {
+			response.AccountNotFound(c)
+			return
+		}
+		if strings.Contains(err.Error(), "busy") {
+			response.AccountBusy(c)
+			return
+		}
+		h.logger.Error("Failed to refresh account info",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		response.ConnectionFailed(c, "刷新账号资料失败："+err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(c, "账号资料已刷新", account)
+}
+
+// GetConversation 读取账号会话消息
+// @Summary 读取账号会话消息
+// @Description 使用指定账号读取与某个用户/群组/频道的最近消息（新消息在前），通过 offset_id 向前翻页；账号无权访问时返回 restricted
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "账号ID"
+// @Param peer query string true "会话目标：用户名、公开链接或已加入群组的邀请链接"
+// @Param limit query int false "返回条数，最多100" default(50)
+// @Param offset_id query int false "从该消息ID之前开始读取，0 表示最新"
+// @Success 200 {object} models.Conversation "会话消息"
+// @Failure 400 {object} map[string]string "请求错误"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 404 {object} map[string]string "账号不存在"
+// @Router /api/v1/accounts/{id}/conversation [get]
+func (h *AccountHandler) GetConversation(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	accountID := h.getIDParam(c, "id")
+	if accountID == 0 {
+		return
+	}
+
+	peer := strings.TrimSpace(c.Query("peer"))
+	if peer == "" {
+		response.InvalidParam(c, "peer 不能为空")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offsetID, err := strconv.Atoi(c.DefaultQuery("offset_id", "0"))
+	if err != nil || offsetID < 0 {
+		response.InvalidParam(c, "offset_id 无效")
+		return
+	}
+
+	conversation, err := h.accountService.GetConversation(userID, accountID, peer, limit, offsetID)
+	if err != nil {
+		if err == services.ErrAccountNotFound {
+			response.AccountNotFound(c)
+			return
+		}
+		if strings.Contains(err.Error(), "busy") {
+			response.AccountBusy(c)
+			return
+		}
+		h.logger.Error("Failed to get conversation",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("account_id", accountID),
+			zap.String("peer", peer),
+			zap.Error(err))
+		response.ConnectionFailed(c, "读取会话失败："+err.Error())
+		return
+	}
+
+	response.Success(c, conversation)
+}
+
+// TestAccountAcrossProxies 多代理连通性测试
+// @Summary 多代理连通性测试
+// @Description 使用账号会话依次通过候选代理建立临时连接，按连接成功与延迟排名返回，不修改账号的代理绑定
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "账号ID"
+// @Param request body models.ProxyProbeRequest true "候选代理ID列表（最多10个）"
+// @Success 200 {array} models.ProxyProbeResult "排名后的测试结果"
+// @Router /api/v1/accounts/{id}/proxy-test [post]
+func (h *AccountHandler) TestAccountAcrossProxies(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	accountID := h.getIDParam(c, "id")
+	if accountID == 0 {
+		return
+	}
+
+	var req models.ProxyProbeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.InvalidParam(c, "请求参数无效："+err.Error())
+		return
+	}
+
+	results, err := h.accountService.TestAccountAcrossProxies(userID, accountID, req.ProxyIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAccountNotFound):
+			response.AccountNotFound(c)
+		case errors.Is(err, services.ErrProxyNotFound):
+			response.ProxyNotFound(c)
+		default:
+			h.logger.Error("Failed to test account across proxies",
+				zap.Uint64("user_id", userID),
+				zap.Uint64("account_id", accountID),
+				zap.Error(err))
+			response.InternalError(c, "代理测试失败："+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, results)
+}
+
 // 辅助方法
 
 // getUserID 从上下文获取用户ID
@@ -489,6 +769,8 @@ func (h *AccountHandler) getIntParam(c *gin.Context, param string, defaultValue
 // @Param file formData file false "账号文件（zip、.session或tdata文件夹）"
 // @Param request body models.BatchUploadAccountRequest false "批量账号信息（JSON格式，与file二选一）"
 // @Param proxy_id formData string false "代理ID"
+// @Param validate_only formData bool false "只校验不创建，返回每个账号的校验结论（valid/invalid/duplicate）"
+// @Param async formData bool false "异步解析和创建（仅文件上传），立即返回批量任务ID，通过 /api/v1/batch-jobs/{id} 查询进度"
 // @Success 200 {object} map[string]interface{} "上传结果"
 // @Failure 400 {object} map[string]string "请求错误"
 // @Failure 401 {object} map[string]string "未授权"
@@ -508,12 +790,18 @@ func (h *AccountHandler) UploadAccountFiles(c *gin.Context) {
 		}
 	}
 
+	// 只校验模式：解析并检查重复，但不创建账号
+	validateOnly := c.PostForm("validate_only") == "true" || c.Query("validate_only") == "true"
+
+	// 异步模式：大文件解析和创建在批量任务中进行，避免请求超时
+	async := c.PostForm("async") == "true" || c.Query("async") == "true"
+
 	// 检查是否是文件上传
 	file, header, err := c.Request.FormFile("file")
 	if err == nil {
 		// 文件上传模式
 		defer file.Close()
-		h.handleFileUpload(c, userID, file, header, proxyID)
+		h.handleFileUpload(c, userID, file, header, proxyID, validateOnly, async && !validateOnly)
 		return
 	}
 
@@ -535,6 +823,11 @@ func (h *AccountHandler) UploadAccountFiles(c *gin.Context) {
 		req.ProxyID = proxyID
 	}
 
+	if req.ValidateOnly || validateOnly {
+		h.validateUpload(c, userID, req.Accounts, req.ProxyID)
+		return
+	}
+
 	// 批量创建账号
 	createdAccounts, errors, err := h.accountService.CreateAccountsFromUploadData(userID, req.Accounts, req.ProxyID)
 	if err != nil {
@@ -705,6 +998,148 @@ func (h *AccountHandler) BatchDeleteAccounts(c *gin.Context) {
 	})
 }
 
+// BatchUpdateStatus 批量修改账号状态
+// @Summary 批量修改账号状态
+// @Description 批量将账号设置为指定状态（如代理迁移前设为维护），变更在同一事务中写入并记录状态历史
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body models.BatchUpdateStatusRequest true "账号ID列表及目标状态"
+// @Success 200 {object} models.BatchUpdateStatusResult "操作结果"
+// @Failure 400 {object} map[string]string "请求错误"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /api/v1/accounts/batch/status [post]
+func (h *AccountHandler) BatchUpdateStatus(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	var req models.BatchUpdateStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn("Invalid batch update status request", zap.Error(err))
+		response.InvalidParam(c, "请求参数无效："+err.Error())
+		return
+	}
+
+	h.logger.Info("Batch updating account status",
+		zap.Uint64("user_id", userID),
+		zap.Int("account_count", len(req.AccountIDs)),
+		zap.String("status", string(req.Status)))
+
+	result, err := h.accountService.BatchUpdateStatus(userID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAccountStatus) {
+			response.InvalidParam(c, fmt.Sprintf("不支持设置为该状态: %s", req.Status))
+			return
+		}
+		h.logger.Error("Failed to batch update account status",
+			zap.Uint64("user_id", userID),
+			zap.Int("account_count", len(req.AccountIDs)),
+			zap.Error(err))
+		response.InternalError(c, "批量修改账号状态失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, fmt.Sprintf("成功修改 %d 个账号的状态，失败 %d 个", result.SuccessCount, result.FailedCount), result)
+}
+
+// GetStatusHistory 获取账号状态变更记录
+// @Summary 获取账号状态变更记录
+// @Description 获取指定账号最近的状态变更记录（最新在前）
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "账号ID"
+// @Param limit query int false "返回条数" default(50)
+// @Success 200 {array} models.AccountStatusHistory "状态变更记录"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 404 {object} map[string]string "账号不存在"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /api/v1/accounts/{id}/status-history [get]
+func (h *AccountHandler) GetStatusHistory(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	accountID := h.getIDParam(c, "id")
+	if accountID == 0 {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	histories, err := h.accountService.GetStatusHistory(userID, accountID, limit)
+	if err != nil {
+		if err == services.ErrAccountNotFound {
+			response.AccountNotFound(c)
+			return
+		}
+		h.logger.Error("Failed to get account status history",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		response.InternalError(c, "获取状态变更记录失败")
+		return
+	}
+
+	response.Success(c, histories)
+}
+
+// GetProxyHistory 获取账号代理绑定变更记录
+// @Summary 获取账号代理绑定变更记录
+// @Description 获取指定账号最近的代理绑定变更记录（最新在前），包括手动/批量绑定、代理迁移、代理删除和失效绑定清理
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "账号ID"
+// @Param limit query int false "返回条数" default(50)
+// @Success 200 {array} models.ProxyBindingHistory "代理绑定变更记录"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 404 {object} map[string]string "账号不存在"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /api/v1/accounts/{id}/proxy-history [get]
+func (h *AccountHandler) GetProxyHistory(c *gin.Context) {
+	userID := h.getUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	accountID := h.getIDParam(c, "id")
+	if accountID == 0 {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	histories, err := h.accountService.GetProxyBindingHistory(userID, accountID, limit)
+	if err != nil {
+		if err == services.ErrAccountNotFound {
+			response.AccountNotFound(c)
+			return
+		}
+		h.logger.Error("Failed to get account proxy history",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		response.InternalError(c, "获取代理绑定记录失败")
+		return
+	}
+
+	response.Success(c, histories)
+}
+
 // BatchBindProxy 批量绑定/解绑代理
 // @Summary 批量绑定/解绑代理
 // @Description 批量为账号绑定或解绑代理，proxy_id为null时表示解绑
@@ -774,7 +1209,7 @@ func (h *AccountHandler) BatchBindProxy(c *gin.Context) {
 }
 
 // handleFileUpload 处理文件上传
-func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file multipart.File, header *multipart.FileHeader, proxyID *uint64) {
+func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file multipart.File, header *multipart.FileHeader, proxyID *uint64, validateOnly, async bool) {
 	h.logger.Info("Processing file upload",
 		zap.Uint64("user_id", userID),
 		zap.String("filename", header.Filename),
@@ -800,7 +1235,13 @@ func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file mu
 		response.InternalError(c, "创建临时目录失败")
 		return
 	}
-	defer os.RemoveAll(tempDir)
+	// 异步模式下临时目录交由批量任务在结束后删除
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			os.RemoveAll(tempDir)
+		}
+	}()
 
 	// 保存上传的文件
 	fileName := header.Filename
@@ -821,6 +1262,25 @@ func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file mu
 		return
 	}
 
+	if async && h.batchService != nil {
+		handedOff = true
+		job, err := h.batchService.UploadAccountFile(c.Request.Context(), userID, tempDir, tempFilePath, proxyID)
+		if err != nil {
+			h.logger.Error("创建异步上传任务失败", zap.Error(err))
+			response.InternalError(c, "创建上传任务失败: "+err.Error())
+			return
+		}
+		h.logger.Info("账号文件异步上传已提交",
+			zap.Uint64("user_id", userID),
+			zap.String("file", fileName),
+			zap.Uint64("job_id", job.ID))
+		response.SuccessWithMessage(c, "上传任务已创建，可通过批量任务接口查询进度", gin.H{
+			"job_id": job.ID,
+			"status": job.Status,
+		})
+		return
+	}
+
 	// 解析账号文件
 	parsedAccounts, err := h.accountParser.ParseAccountFiles(tempFilePath)
 	if err != nil {
@@ -836,16 +1296,24 @@ func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file mu
 
 	// 转换为上传数据格式
 	var uploadItems []models.AccountUploadItem
+	var uploadSources []string
 	var parseErrors []string
+	var invalidVerdicts []*models.AccountUploadVerdict
 
 	for _, account := range parsedAccounts {
 		if account.Error != "" {
 			parseErrors = append(parseErrors, fmt.Sprintf("账号 %s: %s", account.Phone, account.Error))
+			invalidVerdicts = append(invalidVerdicts, &models.AccountUploadVerdict{
+				Phone: account.Phone, Source: account.Source, Verdict: models.UploadVerdictInvalid, Reason: account.Error,
+			})
 			continue
 		}
 
 		if account.Phone == "" || account.SessionData == "" {
 			parseErrors = append(parseErrors, fmt.Sprintf("账号数据不完整: Phone=%s", account.Phone))
+			invalidVerdicts = append(invalidVerdicts, &models.AccountUploadVerdict{
+				Phone: account.Phone, Source: account.Source, Verdict: models.UploadVerdictInvalid, Reason: "账号数据不完整",
+			})
 			continue
 		}
 
@@ -853,6 +1321,20 @@ func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file mu
 			Phone:       account.Phone,
 			SessionData: account.SessionData,
 		})
+		uploadSources = append(uploadSources, account.Source)
+	}
+
+	if validateOnly {
+		verdicts, err := h.accountService.ValidateUploadData(userID, uploadItems, proxyID)
+		if err != nil {
+			response.InvalidParam(c, err.Error())
+			return
+		}
+		for i, verdict := range verdicts {
+			verdict.Source = uploadSources[i]
+		}
+		h.respondUploadValidation(c, userID, append(invalidVerdicts, verdicts...))
+		return
 	}
 
 	if len(uploadItems) == 0 {
@@ -896,6 +1378,46 @@ func (h *AccountHandler) handleFileUpload(c *gin.Context, userID uint64, file mu
 	response.SuccessWithMessage(c, fmt.Sprintf("成功创建 %d 个账号，失败 %d 个", len(createdAccounts), len(allErrors)), result)
 }
 
+// validateUpload 只校验 JSON 上传的账号数据
+func (h *AccountHandler) validateUpload(c *gin.Context, userID uint64, accounts []models.AccountUploadItem, proxyID *uint64) {
+	verdicts, err := h.accountService.ValidateUploadData(userID, accounts, proxyID)
+	if err != nil {
+		response.InvalidParam(c, err.Error())
+		return
+	}
+	h.respondUploadValidation(c, userID, verdicts)
+}
+
+// respondUploadValidation 汇总并返回上传校验结果
+func (h *AccountHandler) respondUploadValidation(c *gin.Context, userID uint64, verdicts []*models.AccountUploadVerdict) {
+	counts := map[string]int{
+		models.UploadVerdictValid:     0,
+		models.UploadVerdictInvalid:   0,
+		models.UploadVerdictDuplicate: 0,
+	}
+	for _, verdict := range verdicts {
+		counts[verdict.Verdict]++
+	}
+
+	h.logger.Info("账号上传校验完成",
+		zap.Uint64("user_id", userID),
+		zap.Int("total", len(verdicts)),
+		zap.Int("valid", counts[models.UploadVerdictValid]),
+		zap.Int("invalid", counts[models.UploadVerdictInvalid]),
+		zap.Int("duplicate", counts[models.UploadVerdictDuplicate]))
+
+	response.SuccessWithMessage(c, fmt.Sprintf("校验完成：可导入 %d 个，无效 %d 个，重复 %d 个",
+		counts[models.UploadVerdictValid], counts[models.UploadVerdictInvalid], counts[models.UploadVerdictDuplicate]),
+		gin.H{
+			"validate_only": true,
+			"total":         len(verdicts),
+			"valid":         counts[models.UploadVerdictValid],
+			"invalid":       counts[models.UploadVerdictInvalid],
+			"duplicate":     counts[models.UploadVerdictDuplicate],
+			"accounts":      verdicts,
+		})
+}
+
 // ExportAccounts 导出账号
 // @Summary 导出账号
 // @Description 导出选中的账号为zip文件，每个账号一个文件夹，包含session文件
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/common/response"
+	"tg_cloud_server/internal/services"
+)
+
+// AdminHandler 管理员处理器
+type AdminHandler struct {
+	adminService services.AdminService
+	logger       *zap.Logger
+}
+
+// NewAdminHandler 创建管理员处理器
+func NewAdminHandler(adminService services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		logger:       logger.Get().Named("admin_handler"),
+	}
+}
+
+// GetOverview 获取系统总览
+// @Summary 获取系统总览（管理员）
+// @Description 跨所有用户汇总账号/任务/代理总数、调度器队列、连接池状态、最近的错误和风控事件
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminOverview "系统总览"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 403 {object} map[string]string "需要管理员权限"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /api/v1/admin/overview [get]
+func (h *AdminHandler) GetOverview(c *gin.Context) {
+	overview, err := h.adminService.GetOverview(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to get admin overview", zap.Error(err))
+		response.InternalError(c, "获取系统总览失败")
+		return
+	}
+
+	response.Success(c, overview)
+}
+
+// GetEffectiveConfig 获取当前生效的配置
+// @Summary 获取当前生效的配置（管理员）
+// @Description 返回默认值、配置文件和环境变量合并后的配置，以及调度器和连接池实际使用的设置，密码和密钥已掩码
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminEffectiveConfig "生效配置"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 403 {object} map[string]string "需要管理员权限"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /api/v1/admin/config [get]
+func (h *AdminHandler) GetEffectiveConfig(c *gin.Context) {
+	effective, err := h.adminService.GetEffectiveConfig(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to get effective config", zap.Error(err))
+		response.InternalError(c, "获取生效配置失败")
+		return
+	}
+
+	response.Success(c, effective)
+}
@@ -7,6 +7,7 @@ import (
 	"tg_cloud_server/internal/common/logger"
 	"tg_cloud_server/internal/common/response"
 	"tg_cloud_server/internal/common/utils"
+	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/services"
 )
 
@@ -49,6 +50,10 @@ func (h *AIHandler) GenerateGroupChatResponse(c *gin.Context) {
 		response.InvalidParam(c, err.Error())
 		return
 	}
+	if err := config.AISampling.Validate(); err != nil {
+		response.InvalidParam(c, err.Error())
+		return
+	}
 
 	// 设置默认值
 	if config.MaxLength == 0 {
@@ -103,6 +108,10 @@ func (h *AIHandler) GeneratePrivateMessage(c *gin.Context) {
 		response.InvalidParam(c, err.Error())
 		return
 	}
+	if err := config.AISampling.Validate(); err != nil {
+		response.InvalidParam(c, err.Error())
+		return
+	}
 
 	// 设置默认值
 	if config.MaxLength == 0 {
@@ -244,11 +253,16 @@ func (h *AIHandler) GenerateVariations(c *gin.Context) {
 	var req struct {
 		Template string `json:"template" binding:"required"`
 		Count    int    `json:"count"`
+		models.AISampling
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.InvalidParam(c, err.Error())
 		return
 	}
+	if err := req.AISampling.Validate(); err != nil {
+		response.InvalidParam(c, err.Error())
+		return
+	}
 
 	// 设置默认值和限制
 	if req.Count == 0 {
@@ -263,7 +277,7 @@ func (h *AIHandler) GenerateVariations(c *gin.Context) {
 		return
 	}
 
-	variations, err := h.aiService.GenerateVariations(c.Request.Context(), req.Template, req.Count)
+	variations, err := h.aiService.GenerateVariations(c.Request.Context(), req.Template, req.Count, req.AISampling)
 	if err != nil {
 		h.logger.Error("Failed to generate variations", zap.Error(err))
 		response.InternalError(c, "生成模板变体失败")
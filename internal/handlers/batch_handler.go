@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/common/response"
+	"tg_cloud_server/internal/common/utils"
+	"tg_cloud_server/internal/services"
+)
+
+// BatchHandler 批量任务处理器
+type BatchHandler struct {
+	batchService services.BatchService
+	logger       *zap.Logger
+}
+
+// NewBatchHandler 创建批量任务处理器
+func NewBatchHandler(batchService services.BatchService) *BatchHandler {
+	return &BatchHandler{
+		batchService: batchService,
+		logger:       logger.Get().Named("batch_handler"),
+	}
+}
+
+// GetBatchJobs 获取批量任务列表
+func (h *BatchHandler) GetBatchJobs(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	jobs, total, err := h.batchService.GetBatchJobs(c.Request.Context(), userID, page, limit)
+	if err != nil {
+		h.logger.Error("Failed to get batch jobs",
+			zap.Uint64("user_id", userID),
+			zap.Error(err))
+		response.InternalError(c, "获取批量任务列表失败")
+		return
+	}
+
+	response.Paginated(c, jobs, page, limit, total)
+}
+
+// GetBatchJob 获取批量任务详情
+func (h *BatchHandler) GetBatchJob(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.InvalidParam(c, "无效的批量任务ID")
+		return
+	}
+
+	job, err := h.batchService.GetBatchJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		if errors.Is(err, services.ErrBatchJobNotFound) {
+			response.NotFound(c, "批量任务不存在")
+			return
+		}
+		h.logger.Error("Failed to get batch job",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("job_id", jobID),
+			zap.Error(err))
+		response.InternalError(c, "获取批量任务失败")
+		return
+	}
+
+	response.Success(c, job)
+}
+
+// CancelBatchJob 取消等待中或运行中的批量任务
+func (h *BatchHandler) CancelBatchJob(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.InvalidParam(c, "无效的批量任务ID")
+		return
+	}
+
+	if err := h.batchService.CancelBatchJob(c.Request.Context(), userID, jobID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrBatchJobNotFound):
+			response.NotFound(c, "批量任务不存在")
+		case errors.Is(err, services.ErrBatchJobFinished):
+			response.Conflict(c, "批量任务已结束，无法取消")
+		default:
+			h.logger.Error("Failed to cancel batch job",
+				zap.Uint64("user_id", userID),
+				zap.Uint64("job_id", jobID),
+				zap.Error(err))
+			response.InternalError(c, "取消批量任务失败")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "批量任务已取消", nil)
+}
+
+// ExportData 创建数据导出任务
+func (h *BatchHandler) ExportData(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+
+	var req services.ExportDataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.InvalidParam(c, err.Error())
+		return
+	}
+
+	job, err := h.batchService.ExportData(c.Request.Context(), userID, &req)
+	if err != nil {
+		h.logger.Error("Failed to start data export",
+			zap.Uint64("user_id", userID),
+			zap.String("data_type", req.DataType),
+			zap.Error(err))
+		response.InternalError(c, "创建导出任务失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "导出任务已创建", job)
+}
+
+// DownloadExport 下载导出文件
+func (h *BatchHandler) DownloadExport(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.InvalidParam(c, "无效的批量任务ID")
+		return
+	}
+
+	file, filename, err := h.batchService.GetExportFile(c.Request.Context(), userID, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBatchJobNotFound):
+			response.NotFound(c, "批量任务不存在")
+		case errors.Is(err, services.ErrNotExportJob):
+			c.JSON(http.StatusBadRequest, &response.APIResponse{
+				Code: response.CodeInvalidParam,
+				Msg:  "该批量任务不是导出任务",
+			})
+		case errors.Is(err, services.ErrExportNotReady):
+			c.JSON(http.StatusAccepted, &response.APIResponse{
+				Code: response.CodeSuccess,
+				Msg:  "导出任务尚未完成，请稍后重试",
+			})
+		case errors.Is(err, services.ErrExportExpired):
+			c.JSON(http.StatusGone, &response.APIResponse{
+				Code: response.CodeNotFound,
+				Msg:  "导出文件已过期，请重新导出",
+			})
+		default:
+			h.logger.Error("Failed to get export file",
+				zap.Uint64("user_id", userID),
+				zap.Uint64("job_id", jobID),
+				zap.Error(err))
+			response.InternalError(c, err.Error())
+		}
+		return
+	}
+
+	defer file.Close()
+
+	size := int64(-1)
+	if f, ok := file.(*os.File); ok {
+		if info, err := f.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	c.DataFromReader(http.StatusOK, size, services.ExportContentType(filename), file, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s", filename),
+	})
+}
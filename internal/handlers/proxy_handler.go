@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -43,6 +44,10 @@ func (h *ProxyHandler) CreateProxy(c *gin.Context) {
 
 	proxy, err := h.proxyService.CreateProxy(userID, &req)
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidPasswordRef) {
+			response.InvalidParam(c, err.Error())
+			return
+		}
 		h.logger.Error("Failed to create proxy",
 			zap.Uint64("user_id", userID),
 			zap.Error(err))
@@ -69,6 +74,10 @@ func (h *ProxyHandler) BatchCreateProxy(c *gin.Context) {
 
 	proxies, err := h.proxyService.BatchCreateProxy(userID, &req)
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidPasswordRef) {
+			response.InvalidParam(c, err.Error())
+			return
+		}
 		h.logger.Error("Failed to batch create proxies",
 			zap.Uint64("user_id", userID),
 			zap.Error(err))
@@ -94,6 +103,11 @@ func (h *ProxyHandler) BatchDeleteProxy(c *gin.Context) {
 	}
 
 	if err := h.proxyService.BatchDeleteProxy(userID, req.ProxyIDs); err != nil {
+		var inUse *services.ProxyInUseError
+		if errors.As(err, &inUse) {
+			response.ErrorWithData(c, response.CodeConflict, inUse.Error(), gin.H{"bound_accounts": inUse.BoundAccounts})
+			return
+		}
 		h.logger.Error("Failed to batch delete proxies",
 			zap.Uint64("user_id", userID),
 			zap.Error(err))
@@ -104,6 +118,47 @@ func (h *ProxyHandler) BatchDeleteProxy(c *gin.Context) {
 	response.SuccessWithMessage(c, "批量删除代理成功", nil)
 }
 
+// MigrateProxy 将源代理上的所有账号迁移到目标代理
+func (h *ProxyHandler) MigrateProxy(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+
+	var req models.MigrateProxyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.InvalidParam(c, err.Error())
+		return
+	}
+
+	result, err := h.proxyService.MigrateProxy(userID, req.FromProxyID, req.ToProxyID)
+	if err != nil {
+		if err == services.ErrProxyNotFound {
+			response.ProxyNotFound(c)
+			return
+		}
+		var capacityErr *services.ProxyCapacityError
+		if errors.As(err, &capacityErr) {
+			response.ErrorWithData(c, response.CodeConflict, capacityErr.Error(), gin.H{
+				"capacity":  capacityErr.Capacity,
+				"bound":     capacityErr.Bound,
+				"migrating": capacityErr.Migrating,
+			})
+			return
+		}
+		h.logger.Error("Failed to migrate proxy",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("from_proxy_id", req.FromProxyID),
+			zap.Uint64("to_proxy_id", req.ToProxyID),
+			zap.Error(err))
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(c, "代理迁移成功", result)
+}
+
 // BatchTestProxy 批量测试代理
 func (h *ProxyHandler) BatchTestProxy(c *gin.Context) {
 	userID, err := utils.GetUserID(c)
@@ -214,6 +269,10 @@ func (h *ProxyHandler) UpdateProxy(c *gin.Context) {
 
 	proxy, err := h.proxyService.UpdateProxy(userID, proxyID, &req)
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidPasswordRef) {
+			response.InvalidParam(c, err.Error())
+			return
+		}
 		if err == services.ErrProxyNotFound {
 			response.ProxyNotFound(c)
 			return
@@ -247,6 +306,11 @@ func (h *ProxyHandler) DeleteProxy(c *gin.Context) {
 			response.ProxyNotFound(c)
 			return
 		}
+		var inUse *services.ProxyInUseError
+		if errors.As(err, &inUse) {
+			response.ErrorWithData(c, response.CodeConflict, inUse.Error(), gin.H{"bound_accounts": inUse.BoundAccounts})
+			return
+		}
 		h.logger.Error("Failed to delete proxy",
 			zap.Uint64("user_id", userID),
 			zap.Uint64("proxy_id", proxyID),
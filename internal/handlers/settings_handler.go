@@ -55,6 +55,7 @@ func (h *SettingsHandler) UpdateRiskSettings(c *gin.Context) {
 	settings := &models.UserRiskSettings{
 		MaxConsecutiveFailures: req.MaxConsecutiveFailures,
 		CoolingDurationMinutes: req.CoolingDurationMinutes,
+		Auto2FAEnabled:         req.Auto2FAEnabled,
 	}
 
 	if err := h.riskControlService.UpdateUserRiskSettings(c.Request.Context(), userID, settings); err != nil {
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
@@ -126,3 +128,64 @@ func (h *StatsHandler) GetUserDashboard(c *gin.Context) {
 
 	response.Success(c, dashboard)
 }
+
+// GetTaskTypeStats 获取按任务类型划分的统计
+// @Summary 获取任务类型统计
+// @Description 按任务类型统计完成、失败、运行中的数量及平均执行时长
+// @Tags 统计
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param from query string false "开始日期 (YYYY-MM-DD)，默认7天前"
+// @Param to query string false "结束日期 (YYYY-MM-DD，含当天)，默认今天"
+// @Success 200 {object} models.TaskTypeStatsReport "任务类型统计"
+// @Failure 400 {object} map[string]string "参数错误"
+// @Failure 401 {object} map[string]string "未授权"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /api/v1/stats/tasks/by-type [get]
+func (h *StatsHandler) GetTaskTypeStats(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := today.AddDate(0, 0, -7)
+	to := today.AddDate(0, 0, 1).Add(-time.Second)
+
+	if value := c.Query("from"); value != "" {
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			response.InvalidParam(c, "无效的开始日期格式，请使用 YYYY-MM-DD")
+			return
+		}
+		from = t
+	}
+	if value := c.Query("to"); value != "" {
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			response.InvalidParam(c, "无效的结束日期格式，请使用 YYYY-MM-DD")
+			return
+		}
+		to = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	if to.Before(from) {
+		response.InvalidParam(c, "结束日期不能早于开始日期")
+		return
+	}
+
+	report, err := h.statsService.GetTaskTypeStats(c.Request.Context(), userID, from, to)
+	if err != nil {
+		h.logger.Error("Failed to get task type stats",
+			zap.Uint64("user_id", userID),
+			zap.Time("from", from),
+			zap.Time("to", to),
+			zap.Error(err))
+		response.InternalError(c, "获取任务类型统计失败")
+		return
+	}
+
+	response.Success(c, report)
+}
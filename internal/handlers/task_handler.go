@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -66,6 +67,10 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 			zap.Uint64("user_id", userID),
 			zap.String("task_type", string(req.TaskType)),
 			zap.Error(err))
+		if errors.Is(err, services.ErrInvalidTaskConfig) {
+			response.InvalidParam(c, err.Error())
+			return
+		}
 		response.InternalError(c, err.Error())
 		return
 	}
@@ -187,6 +192,10 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 			response.TaskNotFound(c)
 			return
 		}
+		if errors.Is(err, services.ErrInvalidTaskConfig) {
+			response.InvalidParam(c, err.Error())
+			return
+		}
 		h.logger.Error("Failed to update task",
 			zap.Uint64("user_id", userID),
 			zap.Uint64("task_id", taskID),
@@ -286,6 +295,75 @@ func (h *TaskHandler) RetryTask(c *gin.Context) {
 	response.SuccessWithMessage(c, "任务重试已调度", task)
 }
 
+// RequeueTask 重新排队已结束的任务
+func (h *TaskHandler) RequeueTask(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.InvalidParam(c, "无效的任务ID")
+		return
+	}
+
+	task, err := h.taskService.RequeueTask(userID, taskID)
+	if err != nil {
+		if err == services.ErrTaskNotFound {
+			response.TaskNotFound(c)
+			return
+		}
+		if errors.Is(err, services.ErrTaskNotFinished) {
+			response.InvalidParam(c, "只有已完成、失败或已取消的任务可以重新排队")
+			return
+		}
+		h.logger.Error("Failed to requeue task",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("task_id", taskID),
+			zap.Error(err))
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(c, "任务已重新排队", task)
+}
+
+// DownloadTaskReport 下载任务报告（汇总目标、成功失败情况、时间线和账号统计）
+func (h *TaskHandler) DownloadTaskReport(c *gin.Context) {
+	userID, err := utils.GetUserID(c)
+	if err != nil {
+		response.Unauthorized(c, err.Error())
+		return
+	}
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.InvalidParam(c, "无效的任务ID")
+		return
+	}
+
+	report, err := h.taskService.GenerateReport(userID, taskID, c.DefaultQuery("format", services.ReportFormatHTML))
+	if err != nil {
+		if err == services.ErrTaskNotFound {
+			response.TaskNotFound(c)
+			return
+		}
+		if errors.Is(err, services.ErrUnsupportedReportFormat) {
+			response.InvalidParam(c, "暂只支持 html 格式的报告，可在浏览器中打开后打印为 PDF")
+			return
+		}
+		h.logger.Error("Failed to generate task report",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("task_id", taskID),
+			zap.Error(err))
+		response.InternalError(c, "生成任务报告失败")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", report.FileName))
+	c.Data(200, report.ContentType, report.Content)
+}
+
 // GetTaskLogs 获取任务日志（支持分页和过滤）
 func (h *TaskHandler) GetTaskLogs(c *gin.Context) {
 	userID, err := utils.GetUserID(c)
@@ -597,7 +675,11 @@ func (h *TaskHandler) ControlTask(c *gin.Context) {
 	switch req.Action {
 	case "start":
 		controlErr = h.taskService.StartTask(userID, taskID)
-	case "pause", "stop":
+	case "pause":
+		controlErr = h.taskService.PauseTask(userID, taskID)
+	case "resume":
+		controlErr = h.taskService.ResumeTask(userID, taskID)
+	case "stop":
 		controlErr = h.taskService.StopTask(userID, taskID)
 	default:
 		h.logger.Warn("Unsupported task control action",
@@ -675,7 +757,11 @@ func getActionName(action string) string {
 	switch action {
 	case "start":
 		return "启动"
-	case "pause", "stop":
+	case "pause":
+		return "暂停"
+	case "resume":
+		return "恢复"
+	case "stop":
 		return "停止"
 	case "cancel":
 		return "取消"
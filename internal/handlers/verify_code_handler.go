@@ -201,6 +201,7 @@ func (h *VerifyCodeHandler) ListSessions(c *gin.Context) {
 // @Failure 400 {object} map[string]string "请求错误"
 // @Failure 404 {object} map[string]string "访问码无效"
 // @Failure 408 {object} models.VerifyCodeResponse "验证码接收超时"
+// @Failure 409 {object} map[string]string "该账号已有验证码监听正在进行"
 // @Failure 500 {object} map[string]string "服务器错误"
 // @Router /api/v1/verify-code/{code} [get]
 func (h *VerifyCodeHandler) GetVerifyCode(c *gin.Context) {
@@ -235,6 +236,8 @@ func (h *VerifyCodeHandler) GetVerifyCode(c *gin.Context) {
 			switch verifyErr.Code {
 			case "CODE_NOT_FOUND", "CODE_EXPIRED":
 				response.NotFound(c, verifyErr.Message)
+			case "VERIFY_IN_PROGRESS":
+				response.Conflict(c, verifyErr.Message)
 			case "VERIFY_TIMEOUT":
 				// 使用 ErrorsFrom 或直接 Error，保持 200 OK
 				response.ErrorWithData(c, response.CodeInternalError, verifyErr.Message, verifyResult)
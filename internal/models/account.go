@@ -59,6 +59,11 @@ type TGAccount struct {
 	Status      AccountStatus `json:"status" gorm:"type:enum('new','normal','warning','restricted','dead','cooling','maintenance','frozen');default:'new'"`
 	IsOnline    bool          `json:"is_online" gorm:"default:false"` // 是否在线
 
+	// 手机号归属信息（创建时根据手机号解析）
+	CountryCode string `json:"country_code" gorm:"size:5;index"` // 国家代码 (ISO 3166-1)
+	Region      string `json:"region" gorm:"size:100"`           // 地区
+	Carrier     string `json:"carrier" gorm:"size:100"`          // 运营商
+
 	// Telegram 账号信息（从 Telegram 获取并存储）
 	TgUserID  *int64  `json:"tg_user_id" gorm:"index"`        // Telegram 用户ID
 	Username  *string `json:"username" gorm:"size:100;index"` // Telegram 用户名
@@ -69,9 +74,11 @@ type TGAccount struct {
 
 	// 2FA 信息
 	Has2FA        bool   `json:"has_2fa" gorm:"column:has_2fa;default:false"`               // 是否开启2FA
-	TwoFAPassword string `json:"two_fa_password" gorm:"column:two_fa_password;size:100"`    // 2FA密码
+	TwoFAPassword string `json:"two_fa_password" gorm:"column:two_fa_password;size:255"`    // 2FA密码（配置密钥时加密保存）
 	Is2FACorrect  bool   `json:"is_2fa_correct" gorm:"column:is_2fa_correct;default:false"` // 2FA密码是否正确
 
+	twoFAPasswordCipher string // 无法解密的 2FA 密文，整行保存时原样写回
+
 	// 双向限制状态（独立字段，可与其他状态同时存在）
 	IsBidirectional bool    `json:"is_bidirectional" gorm:"default:false"`            // 是否双向限制
 	FrozenUntil     *string `json:"frozen_until" gorm:"column:frozen_until;size:100"` // 冻结结束时间
@@ -80,6 +87,23 @@ type TGAccount struct {
 	ConsecutiveFailures uint32     `json:"consecutive_failures" gorm:"default:0"` // 连续失败次数
 	CoolingUntil        *time.Time `json:"cooling_until"`                         // 冷却结束时间
 
+	// 设备信息（建立连接时上报给 Telegram，为空时使用连接池默认值或按账号固定挑选的常见设备）
+	DeviceModel   string `json:"device_model" gorm:"size:100"`
+	SystemVersion string `json:"system_version" gorm:"size:100"`
+	AppVersion    string `json:"app_version" gorm:"size:50"`
+	LangCode      string `json:"lang_code" gorm:"size:10"`
+
+	// 在线心跳（按账号开启，定时将账号设为在线）
+	HeartbeatEnabled bool       `json:"heartbeat_enabled" gorm:"default:false;index"` // 是否开启在线心跳
+	LastHeartbeatAt  *time.Time `json:"last_heartbeat_at"`                            // 最近一次心跳时间
+
+	// 服务条款（有待接受的服务条款时部分操作会被拒绝）
+	TOSPending    bool       `json:"tos_pending" gorm:"column:tos_pending;default:false"` // 是否有待手动接受的服务条款
+	TOSAcceptedAt *time.Time `json:"tos_accepted_at" gorm:"column:tos_accepted_at"`       // 最近一次自动接受服务条款的时间
+
+	// 频道数量上限（CHANNELS_TOO_MUCH），加群任务在一段时间内跳过该账号
+	ChannelLimitAt *time.Time `json:"channel_limit_at" gorm:"column:channel_limit_at"` // 最近一次达到频道数量上限的时间
+
 	LastCheckAt *time.Time `json:"last_check_at"`
 	LastUsedAt  *time.Time `json:"last_used_at"`
 	CreatedAt   time.Time  `json:"created_at"`
@@ -147,6 +171,10 @@ type AccountSummary struct {
 	IsOnline bool          `json:"is_online"`
 	ProxyID  *uint64       `json:"proxy_id,omitempty"`
 
+	// 手机号归属信息
+	CountryCode string `json:"country_code,omitempty"`
+	Region      string `json:"region,omitempty"`
+
 	// 双向限制状态（独立字段）
 	IsBidirectional bool    `json:"is_bidirectional"`
 	FrozenUntil     *string `json:"frozen_until,omitempty" gorm:"column:frozen_until"`
@@ -179,6 +207,9 @@ type AccountSummary struct {
 	ProxyUsername string `json:"proxy_username,omitempty"`
 	ProxyPassword string `json:"proxy_password,omitempty"`
 	ProxyProtocol string `json:"proxy_protocol,omitempty"`
+
+	// 搜索命中的字段: phone / username / tg_user_id，便于前端高亮
+	MatchedField string `json:"matched_field,omitempty" gorm:"-"`
 }
 
 // AccountAvailability 账号可用性信息
@@ -201,6 +232,9 @@ type ValidationResult struct {
 	Warnings  []string `json:"warnings"`
 	Errors    []string `json:"errors"`
 	QueueSize int      `json:"queue_size"`
+
+	CoolingUntil             *time.Time `json:"cooling_until,omitempty"`              // 冷却结束时间
+	CooldownRemainingSeconds int64      `json:"cooldown_remaining_seconds,omitempty"` // 冷却剩余秒数，到期后可重试
 }
 
 // CreateAccountRequest 创建账号请求
@@ -212,8 +246,9 @@ type CreateAccountRequest struct {
 
 // BatchUploadAccountRequest 批量上传账号请求
 type BatchUploadAccountRequest struct {
-	Accounts []AccountUploadItem `json:"accounts" binding:"required,min=1"`
-	ProxyID  *uint64             `json:"proxy_id"`
+	Accounts     []AccountUploadItem `json:"accounts" binding:"required,min=1"`
+	ProxyID      *uint64             `json:"proxy_id"`
+	ValidateOnly bool                `json:"validate_only"` // 只校验不创建
 }
 
 // AccountUploadItem 单个账号上传项
@@ -222,11 +257,33 @@ type AccountUploadItem struct {
 	SessionData string `json:"session_data" binding:"required"`
 }
 
+// 上传校验结论
+const (
+	UploadVerdictValid     = "valid"     // 可以导入
+	UploadVerdictInvalid   = "invalid"   // 数据不完整或解析失败
+	UploadVerdictDuplicate = "duplicate" // 账号已存在或本次上传中重复
+)
+
+// AccountUploadVerdict 单个账号的上传校验结果
+type AccountUploadVerdict struct {
+	Phone   string `json:"phone"`
+	Source  string `json:"source,omitempty"` // 来源文件
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+}
+
 // UpdateAccountRequest 更新账号请求
 type UpdateAccountRequest struct {
-	Phone   string         `json:"phone"`
-	Status  *AccountStatus `json:"status"`
-	ProxyID *uint64        `json:"proxy_id"`
+	Phone            string         `json:"phone"`
+	Status           *AccountStatus `json:"status"`
+	ProxyID          *uint64        `json:"proxy_id"`
+	HeartbeatEnabled *bool          `json:"heartbeat_enabled"` // 是否开启在线心跳
+
+	// 设备信息，传空字符串表示恢复默认
+	DeviceModel   *string `json:"device_model"`
+	SystemVersion *string `json:"system_version"`
+	AppVersion    *string `json:"app_version"`
+	LangCode      *string `json:"lang_code" binding:"omitempty,max=10"`
 }
 
 // BatchSet2FARequest 批量设置2FA密码请求（仅更新本地记录）
@@ -314,3 +371,66 @@ type TelegramAccountInfo struct {
 	Bio       *string `json:"bio,omitempty"`
 	PhotoURL  *string `json:"photo_url,omitempty"`
 }
+
+// PreviewRecipientsRequest 预览发送目标请求
+type PreviewRecipientsRequest struct {
+	Targets []string `json:"targets" binding:"required,min=1,max=500"`
+}
+
+// ProxyProbeRequest 账号多代理连通性测试请求
+type ProxyProbeRequest struct {
+	ProxyIDs []uint64 `json:"proxy_ids" binding:"required,min=1,max=10"`
+}
+
+// ProxyProbeResult 账号通过单个代理的连通性测试结果
+type ProxyProbeResult struct {
+	Rank      int    `json:"rank"` // 按成功优先、延迟升序排名，从1开始
+	ProxyID   uint64 `json:"proxy_id"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	Country   string `json:"country"`
+	IsCurrent bool   `json:"is_current"` // 是否为账号当前绑定的代理
+	Success   bool   `json:"success"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ConversationMessage 会话中的单条消息
+type ConversationMessage struct {
+	ID         int    `json:"id"`
+	SenderID   int64  `json:"sender_id,omitempty"`
+	SenderName string `json:"sender_name,omitempty"`
+	Out        bool   `json:"out"` // 是否为账号自己发送
+	Text       string `json:"text"`
+	Date       int64  `json:"date"`
+	HasMedia   bool   `json:"has_media"`
+	Service    bool   `json:"service,omitempty"` // 入群、置顶等服务消息
+}
+
+// Conversation 账号与指定会话的消息记录（新消息在前）
+type Conversation struct {
+	Peer         string                `json:"peer"`
+	PeerType     string                `json:"peer_type,omitempty"` // user, chat, channel
+	Title        string                `json:"title,omitempty"`
+	Messages     []ConversationMessage `json:"messages"`
+	NextOffsetID int                   `json:"next_offset_id,omitempty"` // 下一页的 offset_id，0 表示没有更多
+	Restricted   bool                  `json:"restricted"`               // 账号无权访问该会话
+	Reason       string                `json:"reason,omitempty"`
+}
+
+// 目标解析状态
+const (
+	RecipientStatusResolved  = "resolved"   // 已解析（用户/机器人，无成员关系）
+	RecipientStatusInvalid   = "invalid"    // 无法解析
+	RecipientStatusMember    = "member"     // 账号已在群组/频道中
+	RecipientStatusNotMember = "not_member" // 群组/频道存在但账号未加入
+)
+
+// RecipientPreview 单个目标的解析结果
+type RecipientPreview struct {
+	Target   string `json:"target"`
+	Status   string `json:"status"`
+	PeerType string `json:"peer_type,omitempty"` // user, chat, channel
+	Title    string `json:"title,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
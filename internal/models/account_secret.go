@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"tg_cloud_server/internal/common/utils"
+)
+
+// 2FA 密码加密：配置密钥后所有写入路径保存密文，所有读取路径返回明文；
+// 未配置密钥时按明文保存，已有的历史明文记录读取时原样返回；
+// 无法解密的密文保留在数据库中，不会被整行保存覆盖
+var (
+	twoFASecretMu  sync.RWMutex
+	twoFASecretKey string
+)
+
+// SetTwoFASecretKey 设置 2FA 密码的加密密钥，启动时调用一次
+func SetTwoFASecretKey(key string) {
+	twoFASecretMu.Lock()
+	defer twoFASecretMu.Unlock()
+	twoFASecretKey = key
+}
+
+// HasTwoFASecretKey 是否已配置 2FA 密码的加密密钥
+func HasTwoFASecretKey() bool {
+	twoFASecretMu.RLock()
+	defer twoFASecretMu.RUnlock()
+	return twoFASecretKey != ""
+}
+
+// EncryptTwoFAPassword 返回保存到数据库的 2FA 密码，未配置密钥或已是密文时原样返回
+func EncryptTwoFAPassword(password string) (string, error) {
+	twoFASecretMu.RLock()
+	key := twoFASecretKey
+	twoFASecretMu.RUnlock()
+	if key == "" || password == "" || utils.IsEncryptedSecret(password) {
+		return password, nil
+	}
+	return utils.EncryptSecret(key, password)
+}
+
+// DecryptTwoFAPassword 返回 2FA 密码明文；密钥缺失或不匹配导致解密失败时返回错误
+func DecryptTwoFAPassword(stored string) (string, error) {
+	if !utils.IsEncryptedSecret(stored) {
+		return stored, nil
+	}
+	twoFASecretMu.RLock()
+	key := twoFASecretKey
+	twoFASecretMu.RUnlock()
+	if key == "" {
+		return "", fmt.Errorf("2fa password is encrypted but no encryption key is configured")
+	}
+	password, err := utils.DecryptSecret(key, stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt 2fa password: %w", err)
+	}
+	return password, nil
+}
+
+// decryptTwoFAPassword 解密内存中的 2FA 密码；解密失败时对外不暴露密文，
+// 密文保留在 twoFAPasswordCipher 中，整行保存时原样写回，避免覆盖为空
+func (a *TGAccount) decryptTwoFAPassword() {
+	password, err := DecryptTwoFAPassword(a.TwoFAPassword)
+	if err != nil {
+		a.twoFAPasswordCipher = a.TwoFAPassword
+		a.TwoFAPassword = ""
+		return
+	}
+	a.twoFAPasswordCipher = ""
+	a.TwoFAPassword = password
+}
+
+// BeforeSave 保存前加密 2FA 密码
+func (a *TGAccount) BeforeSave(tx *gorm.DB) error {
+	if a.TwoFAPassword == "" && a.twoFAPasswordCipher != "" {
+		a.TwoFAPassword = a.twoFAPasswordCipher
+		return nil
+	}
+	encrypted, err := EncryptTwoFAPassword(a.TwoFAPassword)
+	if err != nil {
+		return err
+	}
+	a.TwoFAPassword = encrypted
+	return nil
+}
+
+// AfterSave 保存后恢复内存中的 2FA 密码明文
+func (a *TGAccount) AfterSave(tx *gorm.DB) error {
+	a.decryptTwoFAPassword()
+	return nil
+}
+
+// AfterFind 查询后解密 2FA 密码
+func (a *TGAccount) AfterFind(tx *gorm.DB) error {
+	a.decryptTwoFAPassword()
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// 账号状态变更来源
+const (
+	StatusChangeSourceManual = "manual" // 单个账号手动修改
+	StatusChangeSourceBatch  = "batch"  // 批量修改
+)
+
+// AccountStatusHistory 账号状态变更记录
+type AccountStatusHistory struct {
+	ID         uint64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID  uint64        `gorm:"not null;index" json:"account_id"`
+	UserID     uint64        `gorm:"not null;index" json:"user_id"`
+	FromStatus AccountStatus `gorm:"size:20" json:"from_status"`
+	ToStatus   AccountStatus `gorm:"size:20" json:"to_status"`
+	Source     string        `gorm:"size:20" json:"source"`  // manual, batch
+	Reason     string        `gorm:"size:255" json:"reason"` // 操作说明
+	CreatedAt  time.Time     `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AccountStatusHistory) TableName() string {
+	return "account_status_histories"
+}
+
+// BatchUpdateStatusRequest 批量修改账号状态请求
+type BatchUpdateStatusRequest struct {
+	AccountIDs []uint64      `json:"account_ids" binding:"required,min=1"`
+	Status     AccountStatus `json:"status" binding:"required"`
+	Reason     string        `json:"reason" binding:"max=255"` // 可选，记录在状态历史中
+}
+
+// BatchUpdateStatusResult 批量修改账号状态结果
+type BatchUpdateStatusResult struct {
+	SuccessCount int               `json:"success_count"`
+	FailedCount  int               `json:"failed_count"`
+	Failures     map[uint64]string `json:"failures,omitempty"` // 账号ID -> 失败原因
+}
+
+// CanTransitionTo 判断账号能否被手动修改为目标状态
+// new 仅用于新建账号，cooling 由风控自动设置并带有结束时间，dead 为终态
+func (s AccountStatus) CanTransitionTo(target AccountStatus) bool {
+	switch target {
+	case AccountStatusNormal, AccountStatusWarning, AccountStatusRestricted,
+		AccountStatusDead, AccountStatusMaintenance, AccountStatusFrozen:
+	default:
+		return false
+	}
+	return s != AccountStatusDead || target == AccountStatusDead
+}
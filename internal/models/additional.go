@@ -33,6 +33,9 @@ type QueueInfo struct {
 	PendingTasks      int64  `json:"pending_tasks"`
 	RunningTasks      int64  `json:"running_tasks"`
 	EstimatedWaitTime int64  `json:"estimated_wait_time"` // 秒
+
+	NextScheduledAt *time.Time `json:"next_scheduled_at,omitempty"` // 队列中最早的定时任务计划执行时间
+	NextScheduledIn int64      `json:"next_scheduled_in,omitempty"` // 距最早的定时任务开始的秒数，用于倒计时
 }
 
 // BatchOperation 批量操作类型
@@ -47,6 +50,7 @@ const (
 	BatchOperationCancelTasks    BatchOperation = "cancel_tasks"
 	BatchOperationImportUsers    BatchOperation = "import_users"
 	BatchOperationExportData     BatchOperation = "export_data"
+	BatchOperationUploadAccounts BatchOperation = "upload_accounts"
 )
 
 // BatchJobStatus 批量任务状态
@@ -62,19 +66,24 @@ const (
 
 // BatchJob 批量任务
 type BatchJob struct {
-	ID             uint64                 `json:"id"`
-	UserID         uint64                 `json:"user_id"`
-	Operation      BatchOperation         `json:"operation"`
-	Status         BatchJobStatus         `json:"status"`
+	ID             uint64                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         uint64                 `json:"user_id" gorm:"not null;index"`
+	Operation      BatchOperation         `json:"operation" gorm:"size:50;not null"`
+	Status         BatchJobStatus         `json:"status" gorm:"size:20;not null;index"`
 	TotalItems     int                    `json:"total_items"`
 	ProcessedItems int                    `json:"processed_items"`
 	SuccessItems   int                    `json:"success_items"`
 	FailedItems    int                    `json:"failed_items"`
 	Progress       float64                `json:"progress"`
-	ErrorMessages  []string               `json:"error_messages,omitempty"`
-	Result         map[string]interface{} `json:"result,omitempty"`
+	ErrorMessages  []string               `json:"error_messages,omitempty" gorm:"type:json;serializer:json"`
+	Result         map[string]interface{} `json:"result,omitempty" gorm:"type:longtext;serializer:json"`
 	StartedAt      *time.Time             `json:"started_at,omitempty"`
 	CompletedAt    *time.Time             `json:"completed_at,omitempty"`
 	CreatedAt      time.Time              `json:"created_at"`
 	UpdatedAt      time.Time              `json:"updated_at"`
 }
+
+// TableName 指定表名
+func (BatchJob) TableName() string {
+	return "batch_jobs"
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// AdminOverview 管理员系统总览，统计范围为所有用户
+type AdminOverview struct {
+	TotalUsers    int64 `json:"total_users"`
+	TotalAccounts int64 `json:"total_accounts"`
+	TotalTasks    int64 `json:"total_tasks"`
+	TotalProxies  int64 `json:"total_proxies"`
+
+	AccountsByStatus map[string]int64 `json:"accounts_by_status"`
+	TasksByStatus    map[string]int64 `json:"tasks_by_status"`
+
+	Scheduler      map[string]interface{} `json:"scheduler"`       // 调度器队列状态
+	ConnectionPool map[string]interface{} `json:"connection_pool"` // 连接池统计
+
+	RecentErrors []*AdminErrorLog `json:"recent_errors"` // 最近的任务错误日志
+	RecentEvents []*RiskLog       `json:"recent_events"` // 最近的风控事件
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// AdminEffectiveConfig 当前生效的运行配置，敏感值已掩码
+type AdminEffectiveConfig struct {
+	Config  map[string]interface{} `json:"config"`  // 默认值、配置文件和环境变量合并后的配置
+	Runtime map[string]interface{} `json:"runtime"` // 调度器和连接池实际使用的设置，包含启动后的调整
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// AdminErrorLog 总览中的任务错误日志
+type AdminErrorLog struct {
+	ID        uint64    `json:"id"`
+	TaskID    uint64    `json:"task_id"`
+	UserID    uint64    `json:"user_id"`
+	AccountID *uint64   `json:"account_id"`
+	Action    string    `json:"action"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// AgentMemory 智能体人设记忆，按账号+群组保存历次场景的互动摘要
+type AgentMemory struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID uint64    `gorm:"uniqueIndex:idx_agent_memory_account_group;not null" json:"account_id"`
+	GroupKey  string    `gorm:"uniqueIndex:idx_agent_memory_account_group;size:255;not null" json:"group_key"` // 群组标识（场景 topic 规范化后）
+	Summary   string    `gorm:"type:text" json:"summary"`                                                      // 历次互动摘要
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime;index" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AgentMemory) TableName() string {
+	return "agent_memories"
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"time"
 )
 
 // AgentScenario 智能体场景配置
@@ -12,6 +14,117 @@ type AgentScenario struct {
 	Topic       string        `json:"topic"`    // 全局话题/目标
 	Duration    int           `json:"duration"` // 运行持续时间 (秒)
 	Agents      []AgentConfig `json:"agents"`   // 参与的智能体
+
+	// 活跃时间窗口，为空表示全程活跃；窗口外保持连接和监听，但不触发发言
+	ActiveWindows []TimeWindow `json:"active_windows,omitempty"`
+	Timezone      string       `json:"timezone,omitempty"` // 时间窗口所用时区，如 Asia/Shanghai，默认服务器本地时区
+
+	ParseMode string `json:"parse_mode,omitempty"` // 发言格式解析模式: none/markdown/html
+
+	EnableMemory bool `json:"enable_memory,omitempty"` // 是否为每个智能体保留跨场景的记忆（按账号+群组）
+
+	// 入群阶段：有限并发，每个智能体入群前随机等待，避免同一时刻集中入群
+	JoinConcurrency  int     `json:"join_concurrency,omitempty"`   // 同时入群的智能体数量，默认3
+	JoinDelayMin     float64 `json:"join_delay_min,omitempty"`     // 入群前随机等待下限 (秒)
+	JoinDelayMax     float64 `json:"join_delay_max,omitempty"`     // 入群前随机等待上限 (秒)，与下限均为0时使用默认 1-5 秒
+	JoinOrder        string  `json:"join_order,omitempty"`         // 入群顺序: listed(按配置顺序)/random，默认 random
+	SkipJoinedAgents bool    `json:"skip_joined_agents,omitempty"` // 先检查成员身份，已在群内的智能体跳过入群和等待
+
+	MaxConcurrentDecisions int `json:"max_concurrent_decisions,omitempty"` // 同时进行的智能体决策数量上限，0 使用系统配置
+
+	// 目标完成检测：AI 决策可返回 goal_complete，达成后该智能体不再发言；所有设置了目标的智能体都达成时场景提前结束
+	GoalDetection bool `json:"goal_detection,omitempty"`
+
+	// 发言内容的后处理步骤，如 strip_links、append:<text>、max_length:<n>，按顺序执行
+	PostProcess AIPostProcess `json:"post_process,omitempty"`
+
+	AISampling // 场景级 AI 采样参数覆盖
+}
+
+// 入群顺序
+const (
+	JoinOrderListed = "listed"
+	JoinOrderRandom = "random"
+)
+
+// 入群阶段默认值
+const (
+	defaultJoinConcurrency = 3
+	defaultJoinDelayMin    = 1.0
+	defaultJoinDelayMax    = 5.0
+)
+
+// JoinParallelism 返回入群并发数
+func (as *AgentScenario) JoinParallelism() int {
+	if as.JoinConcurrency > 0 {
+		return as.JoinConcurrency
+	}
+	return defaultJoinConcurrency
+}
+
+// JoinDelayRange 返回入群前随机等待的范围
+func (as *AgentScenario) JoinDelayRange() (time.Duration, time.Duration) {
+	min, max := as.JoinDelayMin, as.JoinDelayMax
+	if min == 0 && max == 0 {
+		min, max = defaultJoinDelayMin, defaultJoinDelayMax
+	}
+	if max < min {
+		max = min
+	}
+	return time.Duration(min * float64(time.Second)), time.Duration(max * float64(time.Second))
+}
+
+// TimeWindow 每日时间窗口，格式 HH:MM，End 小于 Start 表示跨天
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// parseClock 解析 HH:MM 为当天分钟数
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式错误 %q，应为 HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains 判断时间点是否落在窗口内
+func (w TimeWindow) Contains(t time.Time) bool {
+	start, err1 := parseClock(w.Start)
+	end, err2 := parseClock(w.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end // 跨天
+}
+
+// Location 返回时间窗口所用时区
+func (as *AgentScenario) Location() *time.Location {
+	if as.Timezone != "" {
+		if loc, err := time.LoadLocation(as.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// InActiveWindow 判断当前是否处于活跃窗口
+func (as *AgentScenario) InActiveWindow(t time.Time) bool {
+	if len(as.ActiveWindows) == 0 {
+		return true
+	}
+	t = t.In(as.Location())
+	for _, w := range as.ActiveWindows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
 }
 
 // AgentConfig 智能体配置
@@ -33,6 +146,108 @@ type Persona struct {
 	Beliefs    []string `json:"beliefs"` // 核心观点
 }
 
+// ParseAgentScenario 从任务配置解析场景
+func ParseAgentScenario(config TaskConfig) (*AgentScenario, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task config: %w", err)
+	}
+
+	var scenario AgentScenario
+	if err := json.Unmarshal(configBytes, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse agent scenario: %w", err)
+	}
+	return &scenario, nil
+}
+
+// Validate 校验场景配置
+// maxAgents 为单个场景允许的最大智能体数量，maxTotalActiveRate 为所有智能体活跃度之和的上限
+// （活跃度之和约等于每条新消息触发的平均发言数）。小于等于0表示不限制。
+func (as *AgentScenario) Validate(maxAgents int, maxTotalActiveRate float64) error {
+	if len(as.Agents) == 0 {
+		return fmt.Errorf("场景至少需要一个智能体")
+	}
+	if maxAgents > 0 && len(as.Agents) > maxAgents {
+		return fmt.Errorf("智能体数量 %d 超过上限 %d，请减少参与账号或拆分为多个场景", len(as.Agents), maxAgents)
+	}
+
+	if err := as.AISampling.Validate(); err != nil {
+		return err
+	}
+	if err := as.PostProcess.Validate(); err != nil {
+		return err
+	}
+
+	if as.Timezone != "" {
+		if _, err := time.LoadLocation(as.Timezone); err != nil {
+			return fmt.Errorf("无效的时区 %q", as.Timezone)
+		}
+	}
+	for i, w := range as.ActiveWindows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return fmt.Errorf("第 %d 个活跃窗口: %v", i+1, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return fmt.Errorf("第 %d 个活跃窗口: %v", i+1, err)
+		}
+		if start == end {
+			return fmt.Errorf("第 %d 个活跃窗口开始和结束时间相同", i+1)
+		}
+	}
+
+	if as.JoinConcurrency < 0 {
+		return fmt.Errorf("join_concurrency 不能为负数")
+	}
+	if as.MaxConcurrentDecisions < 0 {
+		return fmt.Errorf("max_concurrent_decisions 不能为负数")
+	}
+	if as.JoinDelayMin < 0 || as.JoinDelayMax < 0 {
+		return fmt.Errorf("join_delay_min/join_delay_max 不能为负数")
+	}
+	if as.JoinDelayMax > 0 && as.JoinDelayMax < as.JoinDelayMin {
+		return fmt.Errorf("join_delay_max 不能小于 join_delay_min")
+	}
+	switch as.JoinOrder {
+	case "", JoinOrderListed, JoinOrderRandom:
+	default:
+		return fmt.Errorf("无效的入群顺序 %q，可选 listed/random", as.JoinOrder)
+	}
+
+	seen := make(map[uint64]bool, len(as.Agents))
+	totalRate := 0.0
+	for i, agent := range as.Agents {
+		if agent.AccountID == 0 {
+			return fmt.Errorf("第 %d 个智能体未指定 account_id", i+1)
+		}
+		if seen[agent.AccountID] {
+			return fmt.Errorf("账号 %d 在场景中重复出现", agent.AccountID)
+		}
+		seen[agent.AccountID] = true
+
+		if agent.ActiveRate < 0 || agent.ActiveRate > 1 {
+			return fmt.Errorf("账号 %d 的 active_rate 必须在 0-1 之间，当前为 %.2f", agent.AccountID, agent.ActiveRate)
+		}
+		totalRate += agent.ActiveRate
+	}
+
+	if maxTotalActiveRate > 0 && totalRate > maxTotalActiveRate {
+		return fmt.Errorf("活跃度总和 %.2f（%d 个智能体）超过安全上限 %.2f，每条消息平均会触发过多发言，请降低 active_rate 或减少智能体数量",
+			totalRate, len(as.Agents), maxTotalActiveRate)
+	}
+	return nil
+}
+
+// AccountIDs 返回场景中所有智能体的账号ID
+func (as *AgentScenario) AccountIDs() []uint64 {
+	ids := make([]uint64, 0, len(as.Agents))
+	for _, agent := range as.Agents {
+		ids = append(ids, agent.AccountID)
+	}
+	return ids
+}
+
 // Scan 实现 sql.Scanner 接口
 func (as *AgentScenario) Scan(value interface{}) error {
 	if value == nil {
@@ -56,9 +271,14 @@ type AgentDecisionRequest struct {
 	AgentPersona    string                 `json:"agent_persona"`
 	AgentGoal       string                 `json:"agent_goal"`
 	ChatHistory     []ChatMessage          `json:"chat_history"`
+	Memory          string                 `json:"memory,omitempty"` // 该智能体在此群组的历史记忆摘要
 	ImagePool       []string               `json:"image_pool"`
 	ImageGenEnabled bool                   `json:"image_gen_enabled"`
 	Context         map[string]interface{} `json:"context"`
+	GoalDetection   bool                   `json:"goal_detection,omitempty"` // 要求 AI 判断目标是否已达成
+	PostProcess     AIPostProcess          `json:"post_process,omitempty"`   // 发言内容的后处理步骤
+
+	AISampling
 }
 
 // AgentDecisionResponse 智能体决策响应
@@ -71,4 +291,39 @@ type AgentDecisionResponse struct {
 	ImagePrompt  string `json:"image_prompt,omitempty"`
 	ReplyToMsgID int64  `json:"reply_to_msg_id,omitempty"`
 	DelaySeconds int    `json:"delay_seconds"`
+	GoalComplete bool   `json:"goal_complete,omitempty"` // 目标已达成，之后不再触发该智能体
+}
+
+// AISampling AI 采样参数，未设置的字段使用 AI 服务默认值
+type AISampling struct {
+	Temperature *float64 `json:"temperature,omitempty"` // 0-2，越低输出越确定
+	TopP        *float64 `json:"top_p,omitempty"`       // (0,1]
+}
+
+// Validate 校验采样参数取值范围
+func (p AISampling) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature 必须在 0-2 之间，当前为 %.2f", *p.Temperature)
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p 必须在 (0,1] 之间，当前为 %.2f", *p.TopP)
+	}
+	return nil
+}
+
+// AISamplingFromConfig 从任务配置中读取 temperature/top_p 覆盖值
+func AISamplingFromConfig(config map[string]interface{}) (AISampling, error) {
+	var p AISampling
+	for key, target := range map[string]**float64{"temperature": &p.Temperature, "top_p": &p.TopP} {
+		raw, exists := config[key]
+		if !exists || raw == nil {
+			continue
+		}
+		value, ok := raw.(float64)
+		if !ok {
+			return AISampling{}, fmt.Errorf("%s 必须是数字", key)
+		}
+		*target = &value
+	}
+	return p, p.Validate()
 }
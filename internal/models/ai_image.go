@@ -0,0 +1,30 @@
+package models
+
+// PlaceholderImageURL 未配置图片生成 key 时可使用的占位图地址
+const PlaceholderImageURL = "https://via.placeholder.com/1024x1024.png?text=AI+Generated+Image"
+
+// ImageGenerationError 图片生成错误类型，调用方据此决定是否改为发送文字
+type ImageGenerationError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ImageGenerationError) Error() string {
+	return e.Message
+}
+
+// 预定义图片生成错误，服务端返回的详细信息通过 %w 包装
+var (
+	ErrImageNoAPIKey = &ImageGenerationError{
+		Code:    "IMAGE_NO_API_KEY",
+		Message: "未配置图片生成 API key",
+	}
+	ErrImageQuotaExceeded = &ImageGenerationError{
+		Code:    "IMAGE_QUOTA_EXCEEDED",
+		Message: "图片生成额度已用完",
+	}
+	ErrImageContentPolicy = &ImageGenerationError{
+		Code:    "IMAGE_CONTENT_POLICY",
+		Message: "图片描述违反内容政策",
+	}
+)
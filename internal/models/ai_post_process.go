@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AIPostProcess AI 输出的后处理步骤，按顺序执行，支持:
+//
+//	strip_links       去除链接（http/https、t.me、www 开头）
+//	strip_hashtags    去除 #话题 标签
+//	strip_mentions    去除 @用户名
+//	strip_emoji       去除表情符号
+//	append:<text>     在末尾追加文本（如签名）
+//	prepend:<text>    在开头插入文本
+//	max_length:<n>    截断到 n 个字符
+type AIPostProcess []string
+
+var (
+	postProcessLinkRe    = regexp.MustCompile(`(?i)(https?://\S+|www\.\S+|t\.me/\S+)`)
+	postProcessHashtagRe = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+	postProcessMentionRe = regexp.MustCompile(`@[A-Za-z0-9_]{3,}`)
+	postProcessEmojiRe   = regexp.MustCompile(`[\x{1F000}-\x{1FAFF}\x{2600}-\x{27BF}\x{FE0F}\x{200D}]`)
+	postProcessSpaceRe   = regexp.MustCompile(`[ \t]{2,}`)
+)
+
+// postProcessStep 解析后的单个后处理步骤
+type postProcessStep func(text string) string
+
+// parse 解析后处理步骤，名称未知或参数无效时返回错误
+func (p AIPostProcess) parse() ([]postProcessStep, error) {
+	steps := make([]postProcessStep, 0, len(p))
+	for i, raw := range p {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(raw), ":")
+		var step postProcessStep
+		switch name {
+		case "strip_links":
+			step = regexStripper(postProcessLinkRe)
+		case "strip_hashtags":
+			step = regexStripper(postProcessHashtagRe)
+		case "strip_mentions":
+			step = regexStripper(postProcessMentionRe)
+		case "strip_emoji":
+			step = regexStripper(postProcessEmojiRe)
+		case "append":
+			if !hasArg || arg == "" {
+				return nil, fmt.Errorf("post_process[%d]: append 需要指定文本，如 append:签名", i)
+			}
+			step = func(text string) string { return text + arg }
+		case "prepend":
+			if !hasArg || arg == "" {
+				return nil, fmt.Errorf("post_process[%d]: prepend 需要指定文本", i)
+			}
+			step = func(text string) string { return arg + text }
+		case "max_length":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("post_process[%d]: max_length 需要正整数，如 max_length:200", i)
+			}
+			step = func(text string) string {
+				if runes := []rune(text); len(runes) > n {
+					return strings.TrimSpace(string(runes[:n]))
+				}
+				return text
+			}
+		default:
+			return nil, fmt.Errorf("post_process[%d]: 不支持的处理步骤 %q", i, name)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// regexStripper 去除匹配的内容并合并多余的空格
+func regexStripper(re *regexp.Regexp) postProcessStep {
+	return func(text string) string {
+		text = re.ReplaceAllString(text, "")
+		return strings.TrimSpace(postProcessSpaceRe.ReplaceAllString(text, " "))
+	}
+}
+
+// Validate 校验后处理步骤
+func (p AIPostProcess) Validate() error {
+	_, err := p.parse()
+	return err
+}
+
+// Apply 按顺序执行后处理步骤，步骤无效时返回原文
+func (p AIPostProcess) Apply(text string) string {
+	steps, err := p.parse()
+	if err != nil {
+		return text
+	}
+	for _, step := range steps {
+		text = step(text)
+	}
+	return text
+}
+
+// AIPostProcessFromConfig 从任务配置中读取 post_process 步骤列表
+func AIPostProcessFromConfig(config map[string]interface{}) (AIPostProcess, error) {
+	raw, exists := config["post_process"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("post_process 必须是字符串列表")
+	}
+	p := make(AIPostProcess, 0, len(list))
+	for i, item := range list {
+		step, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("post_process[%d] 必须是字符串", i)
+		}
+		p = append(p, step)
+	}
+	return p, p.Validate()
+}
@@ -10,3 +10,15 @@ type ChatMessage struct {
 	Timestamp time.Time `json:"timestamp"`
 	IsBot     bool      `json:"is_bot"`
 }
+
+// GroupChatReplyRequest AI炒群任务请求 AI 生成群聊回复的参数
+type GroupChatReplyRequest struct {
+	GroupName    string
+	GroupTopic   string
+	Persona      string        // 人设描述
+	ResponseType string        // casual, professional, humorous
+	MaxLength    int           // 回复最大字数
+	ChatHistory  []ChatMessage // 按时间先后排列
+	PostProcess  AIPostProcess
+	AISampling
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReplaceTemplateVariables 将消息中的 {{name}} 占位符替换为变量值，未提供的占位符保持原样
+func ReplaceTemplateVariables(message string, variables map[string]string) string {
+	result := message
+	for key, value := range variables {
+		placeholder := fmt.Sprintf("{{%s}}", key)
+		result = strings.ReplaceAll(result, placeholder, value)
+	}
+	return result
+}
+
+// MessageTarget 私信目标，配置为字符串时只有用户名；配置为 {"username": "...", "vars": {...}} 时
+// 消息中的 {{变量名}} 按该目标的变量替换
+type MessageTarget struct {
+	Username string
+	Vars     map[string]string
+}
+
+// MessageTargetFromConfig 解析单个私信目标
+func MessageTargetFromConfig(item interface{}) (MessageTarget, error) {
+	switch v := item.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return MessageTarget{}, fmt.Errorf("用户名不能为空")
+		}
+		return MessageTarget{Username: v}, nil
+	case map[string]interface{}:
+		username, _ := v["username"].(string)
+		if strings.TrimSpace(username) == "" {
+			return MessageTarget{}, fmt.Errorf("username 不能为空")
+		}
+		target := MessageTarget{Username: username}
+		if raw, exists := v["vars"]; exists && raw != nil {
+			vars, ok := raw.(map[string]interface{})
+			if !ok {
+				return MessageTarget{}, fmt.Errorf("vars 必须是对象")
+			}
+			target.Vars = make(map[string]string, len(vars))
+			for key, value := range vars {
+				switch val := value.(type) {
+				case string:
+					target.Vars[key] = val
+				case float64:
+					target.Vars[key] = strconv.FormatFloat(val, 'f', -1, 64)
+				case bool:
+					target.Vars[key] = strconv.FormatBool(val)
+				default:
+					return MessageTarget{}, fmt.Errorf("vars.%s 必须是字符串或数字", key)
+				}
+			}
+		}
+		return target, nil
+	default:
+		return MessageTarget{}, fmt.Errorf("必须是用户名或 {username, vars} 对象")
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Peer 类型
+const (
+	PeerTypeUser    = "user"
+	PeerTypeChannel = "channel"
+)
+
+// PeerAccessHash 账号解析过的用户/频道的 access hash，access hash 与账号绑定，
+// 持久化后按数字ID发送时无需重新解析，服务重启后仍然有效
+type PeerAccessHash struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID  uint64    `gorm:"uniqueIndex:idx_peer_access_hash_account_peer;not null" json:"account_id"`
+	PeerType   string    `gorm:"uniqueIndex:idx_peer_access_hash_account_peer;size:16;not null" json:"peer_type"` // user/channel
+	PeerID     int64     `gorm:"uniqueIndex:idx_peer_access_hash_account_peer;not null" json:"peer_id"`
+	AccessHash int64     `gorm:"not null" json:"access_hash"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PeerAccessHash) TableName() string {
+	return "peer_access_hashes"
+}
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -37,6 +42,7 @@ type ProxyIP struct {
 	Protocol    ProxyProtocol `json:"protocol" gorm:"type:enum('http','https','socks5');not null"`
 	Username    string        `json:"username" gorm:"size:100"`                                                                     // 代理用户名
 	Password    string        `json:"-" gorm:"size:100"`                                                                            // 代理密码（隐藏）
+	PasswordRef string        `json:"password_ref" gorm:"size:255"`                                                                 // 密码引用，连接时从环境变量/密钥文件解析，优先于 Password
 	Country     string        `json:"country" gorm:"size:10"`                                                                       // 国家代码
 	Status      ProxyStatus   `json:"status" gorm:"type:enum('active','inactive','error','testing','untested');default:'untested'"` // 代理状态
 	IsActive    bool          `json:"is_active" gorm:"default:true"`                                                                // 是否启用
@@ -63,12 +69,94 @@ func (p *ProxyIP) GetAddress() string {
 
 // GetAuthAddress 获取带认证的代理地址
 func (p *ProxyIP) GetAuthAddress() string {
-	if p.Username != "" && p.Password != "" {
-		return fmt.Sprintf("%s://%s:%s@%s:%d", p.Protocol, p.Username, p.Password, p.IP, p.Port)
+	if password, err := p.ResolvePassword(); err == nil && p.Username != "" && password != "" {
+		return fmt.Sprintf("%s://%s:%s@%s:%d", p.Protocol, p.Username, password, p.IP, p.Port)
 	}
 	return p.GetAddress()
 }
 
+// ErrInvalidPasswordRef 代理密码引用不在管理员允许的范围内
+var ErrInvalidPasswordRef = errors.New("invalid proxy password reference")
+
+// ProxySecretPolicy 代理密码引用的允许范围，由管理员配置
+type ProxySecretPolicy struct {
+	SecretsDir  string   // file: 引用只能读取该目录下的文件，为空时禁用 file: 引用
+	AllowedEnvs []string // env: 引用允许的环境变量名，为空时禁用 env: 引用
+}
+
+var (
+	proxySecretPolicyMu sync.RWMutex
+	proxySecretPolicy   ProxySecretPolicy
+)
+
+// SetProxySecretPolicy 设置代理密码引用的允许范围
+func SetProxySecretPolicy(policy ProxySecretPolicy) {
+	proxySecretPolicyMu.Lock()
+	defer proxySecretPolicyMu.Unlock()
+	proxySecretPolicy = policy
+}
+
+// ValidatePasswordRef 校验代理密码引用，只允许 "env:NAME"（NAME 在允许列表中）和 "file:name"（密钥目录下的文件名）
+func ValidatePasswordRef(ref string) error {
+	_, _, err := parsePasswordRef(ref)
+	return err
+}
+
+// parsePasswordRef 解析密码引用，返回引用类型（env/file）和环境变量名或密钥文件路径
+func parsePasswordRef(ref string) (kind, target string, err error) {
+	proxySecretPolicyMu.RLock()
+	policy := proxySecretPolicy
+	proxySecretPolicyMu.RUnlock()
+
+	ref = strings.TrimSpace(ref)
+	if name, ok := strings.CutPrefix(ref, "env:"); ok {
+		for _, allowed := range policy.AllowedEnvs {
+			if name != "" && name == allowed {
+				return "env", name, nil
+			}
+		}
+		return "", "", fmt.Errorf("%w: env %s is not allowed", ErrInvalidPasswordRef, name)
+	}
+	if name, ok := strings.CutPrefix(ref, "file:"); ok {
+		if policy.SecretsDir == "" {
+			return "", "", fmt.Errorf("%w: file references are disabled", ErrInvalidPasswordRef)
+		}
+		// 只接受密钥目录下的文件名，拒绝绝对路径和 ..
+		if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+			return "", "", fmt.Errorf("%w: file %s must be a file name inside the secrets dir", ErrInvalidPasswordRef, name)
+		}
+		return "file", filepath.Join(policy.SecretsDir, name), nil
+	}
+	return "", "", fmt.Errorf("%w: must start with env: or file:", ErrInvalidPasswordRef)
+}
+
+// ResolvePassword 解析代理密码
+// PasswordRef 支持 "env:NAME" 和 "file:name"，范围受 ProxySecretPolicy 限制；未设置时使用存储的 Password
+func (p *ProxyIP) ResolvePassword() (string, error) {
+	if strings.TrimSpace(p.PasswordRef) == "" {
+		return p.Password, nil
+	}
+
+	kind, target, err := parsePasswordRef(p.PasswordRef)
+	if err != nil {
+		return "", err
+	}
+
+	if kind == "file" {
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read proxy password secret %s: %w", filepath.Base(target), err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	value, ok := os.LookupEnv(target)
+	if !ok {
+		return "", fmt.Errorf("proxy password secret %s is not set", target)
+	}
+	return value, nil
+}
+
 // IsHealthy 检查代理是否健康
 func (p *ProxyIP) IsHealthy() bool {
 	return p.IsActive && p.SuccessRate >= 80.0 && p.AvgLatency < 5000
@@ -117,13 +205,14 @@ type ProxyConfig struct {
 
 // CreateProxyRequest 创建代理请求
 type CreateProxyRequest struct {
-	Name     string        `json:"name" binding:"required"`
-	IP       string        `json:"ip" binding:"required,ip"`
-	Port     int           `json:"port" binding:"required,min=1,max=65535"`
-	Protocol ProxyProtocol `json:"protocol" binding:"required,oneof=http https socks5"`
-	Username string        `json:"username"`
-	Password string        `json:"password"`
-	Country  string        `json:"country"`
+	Name        string        `json:"name" binding:"required"`
+	IP          string        `json:"ip" binding:"required,ip"`
+	Port        int           `json:"port" binding:"required,min=1,max=65535"`
+	Protocol    ProxyProtocol `json:"protocol" binding:"required,oneof=http https socks5"`
+	Username    string        `json:"username"`
+	Password    string        `json:"password"`
+	PasswordRef string        `json:"password_ref"` // 密码引用，如 env:PROXY_PASS 或 file:proxy_pass
+	Country     string        `json:"country"`
 }
 
 // BatchCreateProxyRequest 批量创建代理请求
@@ -138,14 +227,15 @@ type BatchDeleteProxyRequest struct {
 
 // UpdateProxyRequest 更新代理请求
 type UpdateProxyRequest struct {
-	Name     string        `json:"name"`
-	IP       string        `json:"ip"`
-	Port     int           `json:"port"`
-	Protocol ProxyProtocol `json:"protocol"`
-	Username string        `json:"username"`
-	Password string        `json:"password"`
-	Country  string        `json:"country"`
-	IsActive *bool         `json:"is_active"`
+	Name        string        `json:"name"`
+	IP          string        `json:"ip"`
+	Port        int           `json:"port"`
+	Protocol    ProxyProtocol `json:"protocol"`
+	Username    string        `json:"username"`
+	Password    string        `json:"password"`
+	PasswordRef *string       `json:"password_ref"` // 密码引用，如 env:PROXY_PASS 或 file:proxy_pass；传空字符串清除引用，改用 Password
+	Country     string        `json:"country"`
+	IsActive    *bool         `json:"is_active"`
 }
 
 // ProxyTestResult 代理测试结果
@@ -176,6 +266,19 @@ type BatchProxyTestRequest struct {
 	ProxyIDs []uint64 `json:"proxy_ids" binding:"required"`
 }
 
+// MigrateProxyRequest 代理迁移请求：将源代理上的所有账号改绑到目标代理
+type MigrateProxyRequest struct {
+	FromProxyID uint64 `json:"from_proxy_id" binding:"required"`
+	ToProxyID   uint64 `json:"to_proxy_id" binding:"required"`
+}
+
+// MigrateProxyResult 代理迁移结果
+type MigrateProxyResult struct {
+	Migrated    int      `json:"migrated"`    // 改绑的账号数
+	Reconnected int      `json:"reconnected"` // 已断开、将通过新代理重新连接的在线连接数
+	AccountIDs  []uint64 `json:"account_ids"`
+}
+
 // BindProxyRequest 绑定代理请求
 type BindProxyRequest struct {
 	AccountID uint64  `json:"account_id" binding:"required"`
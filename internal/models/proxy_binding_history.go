@@ -0,0 +1,48 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 代理绑定变更原因
+const (
+	ProxyBindingReasonManual       = "manual"           // 单个账号手动绑定/解绑
+	ProxyBindingReasonBatch        = "batch"            // 批量绑定/解绑
+	ProxyBindingReasonMigration    = "migration"        // 代理迁移
+	ProxyBindingReasonProxyDeleted = "proxy_deleted"    // 代理被删除后自动解绑
+	ProxyBindingReasonDangling     = "dangling_cleanup" // 定时任务清理指向已删除代理的绑定
+)
+
+// ProxyBindingActorSystem 系统自动操作的操作者标识
+const ProxyBindingActorSystem = "system"
+
+// ProxyBindingActorUser 用户操作的操作者标识
+func ProxyBindingActorUser(userID uint64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// ProxyBindingHistory 账号代理绑定变更记录，代理ID为空表示未绑定
+type ProxyBindingHistory struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID  uint64    `gorm:"not null;index" json:"account_id"`
+	UserID     uint64    `gorm:"not null;index" json:"user_id"`
+	OldProxyID *uint64   `json:"old_proxy_id"`
+	NewProxyID *uint64   `json:"new_proxy_id"`
+	Reason     string    `gorm:"size:30" json:"reason"` // manual, batch, migration, proxy_deleted, dangling_cleanup
+	Actor      string    `gorm:"size:64" json:"actor"`  // user:<id> 或 system
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (ProxyBindingHistory) TableName() string {
+	return "proxy_binding_histories"
+}
+
+// Changed 判断绑定是否实际发生变化
+func (h *ProxyBindingHistory) Changed() bool {
+	if h.OldProxyID == nil || h.NewProxyID == nil {
+		return h.OldProxyID != h.NewProxyID
+	}
+	return *h.OldProxyID != *h.NewProxyID
+}
@@ -176,3 +176,21 @@ type TimeSeriesPoint struct {
 	Value     float64   `json:"value"`
 	Label     string    `json:"label,omitempty"`
 }
+
+// TaskTypeStats 按任务类型聚合的统计
+type TaskTypeStats struct {
+	TaskType           string  `json:"task_type"`
+	Total              int64   `json:"total"`
+	Completed          int64   `json:"completed"`
+	Failed             int64   `json:"failed"`
+	Running            int64   `json:"running"`
+	AvgDurationSeconds float64 `json:"avg_duration_seconds"` // 已结束任务的平均执行时长
+}
+
+// TaskTypeStatsReport 任务类型统计报告
+type TaskTypeStatsReport struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Types       []*TaskTypeStats `json:"types"`
+	GeneratedAt time.Time        `json:"generated_at"`
+}
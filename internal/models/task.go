@@ -25,6 +25,12 @@ const (
 	TaskTypeForceAdd          TaskType = "force_add_group"    // 强拉进群
 	TaskTypeTerminateSessions TaskType = "terminate_sessions" // 踢出其他设备
 	TaskTypeUpdate2FA         TaskType = "update_2fa"         // 修改2FA密码
+	TaskTypeSecureAccount     TaskType = "secure_account"     // 一键加固账号
+	TaskTypeClearHistory      TaskType = "clear_history"      // 清空对话记录
+	TaskTypeBotInteraction    TaskType = "bot_interaction"    // 机器人交互
+	TaskTypeForwardMessage    TaskType = "forward_message"    // 消息转发
+	TaskTypeMemberMessage     TaskType = "member_message"     // 群成员私信
+	TaskTypeRefreshInfo       TaskType = "refresh_info"       // 刷新账号资料
 )
 
 // TaskStatus 任务状态枚举
@@ -45,7 +51,7 @@ type Task struct {
 	ID          uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID      uint64     `json:"user_id" gorm:"not null;index"`
 	AccountIDs  string     `json:"account_ids" gorm:"type:text;not null"` // 账号ID列表（逗号分隔，如 "1,2,3"）
-	TaskType    TaskType   `json:"task_type" gorm:"type:enum('check','private_message','broadcast','verify_code','group_chat','join_group','scenario','force_add_group','terminate_sessions','update_2fa');not null"`
+	TaskType    TaskType   `json:"task_type" gorm:"type:enum('check','private_message','broadcast','verify_code','group_chat','join_group','scenario','force_add_group','terminate_sessions','update_2fa','secure_account','clear_history','bot_interaction','forward_message','member_message','refresh_info');not null"`
 	Status      TaskStatus `json:"status" gorm:"type:enum('pending','queued','running', 'paused', 'completed','failed','cancelled');default:'pending'"`
 	Priority    int        `json:"priority" gorm:"default:5"` // 优先级 1-10
 	Config      TaskConfig `json:"config" gorm:"type:json"`   // 任务配置（JSON格式）
@@ -98,6 +104,50 @@ func (t *Task) SetAccountIDList(ids []uint64) {
 	t.AccountIDs = strings.Join(strIDs, ",")
 }
 
+// ExcludeAccountIDsFromConfig 读取任务配置中的 exclude_account_ids，未配置时返回 nil
+func ExcludeAccountIDsFromConfig(config TaskConfig) ([]uint64, error) {
+	raw, exists := config["exclude_account_ids"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("exclude_account_ids must be a list of account IDs")
+	}
+	ids := make([]uint64, 0, len(list))
+	for i, item := range list {
+		id, ok := item.(float64)
+		if !ok || id <= 0 || id != float64(uint64(id)) {
+			return nil, fmt.Errorf("exclude_account_ids[%d] is not a valid account ID", i)
+		}
+		ids = append(ids, uint64(id))
+	}
+	return ids, nil
+}
+
+// SplitExcludedAccounts 从账号列表中去除 exclude_account_ids 中的账号，返回执行账号和被排除的账号
+func (t *Task) SplitExcludedAccounts() (accountIDs, excluded []uint64) {
+	accountIDs = t.GetAccountIDList()
+	excludeIDs, _ := ExcludeAccountIDsFromConfig(t.Config)
+	if len(excludeIDs) == 0 {
+		return accountIDs, nil
+	}
+
+	excludeSet := make(map[uint64]bool, len(excludeIDs))
+	for _, id := range excludeIDs {
+		excludeSet[id] = true
+	}
+	kept := make([]uint64, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		if excludeSet[id] {
+			excluded = append(excluded, id)
+		} else {
+			kept = append(kept, id)
+		}
+	}
+	return kept, excluded
+}
+
 // GetFirstAccountID 获取第一个账号ID（用于显示）
 func (t *Task) GetFirstAccountID() uint64 {
 	ids := t.GetAccountIDList()
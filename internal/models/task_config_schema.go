@@ -0,0 +1,355 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// TaskConfigFieldError 任务配置字段错误，Field 为出错的配置键
+type TaskConfigFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *TaskConfigFieldError) Error() string {
+	return fmt.Sprintf("config.%s: %s", e.Field, e.Message)
+}
+
+// taskConfigSchema 任务类型的配置结构，解析后检查必填项和取值范围
+type taskConfigSchema interface {
+	validate() error
+}
+
+// taskConfigSchemas 按任务类型注册的配置结构，未注册的类型不做结构校验
+// 场景任务由 AgentScenario 单独校验
+var taskConfigSchemas = map[TaskType]func() taskConfigSchema{
+	TaskTypePrivate:        func() taskConfigSchema { return &PrivateMessageConfig{} },
+	TaskTypeBroadcast:      func() taskConfigSchema { return &BroadcastConfig{} },
+	TaskTypeGroupChat:      func() taskConfigSchema { return &GroupChatConfig{} },
+	TaskTypeJoinGroup:      func() taskConfigSchema { return &JoinGroupConfig{} },
+	TaskTypeForceAdd:       func() taskConfigSchema { return &ForceAddConfig{} },
+	TaskTypeUpdate2FA:      func() taskConfigSchema { return &Update2FAConfig{} },
+	TaskTypeClearHistory:   func() taskConfigSchema { return &ClearHistoryConfig{} },
+	TaskTypeBotInteraction: func() taskConfigSchema { return &BotInteractionConfig{} },
+	TaskTypeForwardMessage: func() taskConfigSchema { return &ForwardMessageConfig{} },
+	TaskTypeMemberMessage:  func() taskConfigSchema { return &MemberMessageConfig{} },
+}
+
+// ValidateTaskConfig 按任务类型解析并校验配置，返回的错误为 *TaskConfigFieldError
+func ValidateTaskConfig(taskType TaskType, config TaskConfig) error {
+	newSchema, ok := taskConfigSchemas[taskType]
+	if !ok {
+		return nil
+	}
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task config: %w", err)
+	}
+
+	schema := newSchema()
+	if err := json.Unmarshal(configBytes, schema); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &TaskConfigFieldError{Field: typeErr.Field, Message: fmt.Sprintf("应为 %s 类型，实际为 %s", typeErr.Type, typeErr.Value)}
+		}
+		return fmt.Errorf("failed to parse task config: %w", err)
+	}
+	return schema.validate()
+}
+
+// 通用字段校验
+func requireList(field string, list []interface{}) error {
+	if len(list) == 0 {
+		return &TaskConfigFieldError{Field: field, Message: "不能为空"}
+	}
+	return nil
+}
+
+func requireString(field, value string) error {
+	if value == "" {
+		return &TaskConfigFieldError{Field: field, Message: "不能为空"}
+	}
+	return nil
+}
+
+func nonNegative(field string, value *float64) error {
+	if value != nil && *value < 0 {
+		return &TaskConfigFieldError{Field: field, Message: "不能为负数"}
+	}
+	return nil
+}
+
+// validateMessagePool 消息池中的每条消息都不能为空
+func validateMessagePool(pool []string) error {
+	for i, message := range pool {
+		if message == "" {
+			return &TaskConfigFieldError{Field: fmt.Sprintf("message_pool[%d]", i), Message: "不能为空"}
+		}
+	}
+	return nil
+}
+
+// MediaConfig 群发媒体，url 为外部链接，file 为服务器上已上传文件的路径，二者填一个
+type MediaConfig struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	File    string `json:"file"`
+	Caption string `json:"caption"`
+}
+
+// validateMedia 校验媒体类型和来源，相册最多10个媒体
+func validateMedia(media []MediaConfig) error {
+	if len(media) > 10 {
+		return &TaskConfigFieldError{Field: "media", Message: "最多10个媒体"}
+	}
+	for i, m := range media {
+		switch m.Type {
+		case "", "photo", "document":
+		default:
+			return &TaskConfigFieldError{Field: fmt.Sprintf("media[%d].type", i), Message: fmt.Sprintf("不支持的取值 %q，可选 photo/document", m.Type)}
+		}
+		if (m.URL == "") == (m.File == "") {
+			return &TaskConfigFieldError{Field: fmt.Sprintf("media[%d]", i), Message: "url 和 file 需填写且只能填写一个"}
+		}
+		if m.File != "" && !IsValidMediaFileName(m.File) {
+			return &TaskConfigFieldError{Field: fmt.Sprintf("media[%d].file", i), Message: "只能填写媒体目录下的相对路径，不能是绝对路径或包含 .."}
+		}
+	}
+	return nil
+}
+
+// IsValidMediaFileName 判断媒体文件名是否为媒体目录下的相对路径（非绝对路径且不包含 ..）
+func IsValidMediaFileName(name string) bool {
+	if name == "" || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// firstError 返回第一个非空错误
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrivateMessageConfig 私信任务配置，未配置 sequence 和 message_pool 时 message 必填
+type PrivateMessageConfig struct {
+	Targets               []interface{}   `json:"targets"`
+	Message               string          `json:"message"`
+	MessagePool           []string        `json:"message_pool"`
+	Sequence              json.RawMessage `json:"sequence"`
+	ParseMode             string          `json:"parse_mode"`
+	IntervalSeconds       *float64        `json:"interval_seconds"`
+	IntervalJitterSeconds *float64        `json:"interval_jitter_seconds"` // 每次间隔在 interval ± jitter 内随机
+	RandomOrder           bool            `json:"random_order"`            // 发送前打乱目标顺序
+	DryRun                bool            `json:"dry_run"`                 // 只解析目标，不发送
+}
+
+func (c *PrivateMessageConfig) validate() error {
+	if err := requireList("targets", c.Targets); err != nil {
+		return err
+	}
+	for i, item := range c.Targets {
+		if _, err := MessageTargetFromConfig(item); err != nil {
+			return &TaskConfigFieldError{Field: fmt.Sprintf("targets[%d]", i), Message: err.Error()}
+		}
+	}
+	if err := validateMessagePool(c.MessagePool); err != nil {
+		return err
+	}
+	if (len(c.Sequence) == 0 || string(c.Sequence) == "null") && len(c.MessagePool) == 0 {
+		if err := requireString("message", c.Message); err != nil {
+			return err
+		}
+	}
+	return firstError(
+		nonNegative("interval_seconds", c.IntervalSeconds),
+		nonNegative("interval_jitter_seconds", c.IntervalJitterSeconds),
+	)
+}
+
+// BroadcastConfig 群发任务配置，配置 message_pool 时 message 可为空
+type BroadcastConfig struct {
+	Groups                    []interface{} `json:"groups"`
+	Message                   string        `json:"message"`
+	MessagePool               []string      `json:"message_pool"`
+	Media                     []MediaConfig `json:"media"`
+	VariationMode             string        `json:"variation_mode"`
+	ParseMode                 string        `json:"parse_mode"`
+	AutoJoin                  bool          `json:"auto_join"`
+	DryRun                    bool          `json:"dry_run"` // 只检查群组能否发送，不加群、不发送
+	VerifyCanPost             *bool         `json:"verify_can_post"`
+	LimitPerAccount           *float64      `json:"limit_per_account"`
+	IntervalSeconds           *float64      `json:"interval_seconds"`
+	IntervalJitterSeconds     *float64      `json:"interval_jitter_seconds"` // 每次间隔在 interval ± jitter 内随机
+	RandomOrder               bool          `json:"random_order"`            // 发送前打乱当前账号负责的群组顺序
+	MaxMessagesPerGroupPerDay *float64      `json:"max_messages_per_group_per_day"`
+	JoinIntervalSeconds       *float64      `json:"join_interval_seconds"`
+}
+
+func (c *BroadcastConfig) validate() error {
+	switch c.VariationMode {
+	case "", "none", "spintax", "ai":
+	default:
+		return &TaskConfigFieldError{Field: "variation_mode", Message: fmt.Sprintf("不支持的取值 %q，可选 none/spintax/ai", c.VariationMode)}
+	}
+	if err := validateMessagePool(c.MessagePool); err != nil {
+		return err
+	}
+	if err := validateMedia(c.Media); err != nil {
+		return err
+	}
+	if len(c.MessagePool) == 0 && len(c.Media) == 0 {
+		if err := requireString("message", c.Message); err != nil {
+			return err
+		}
+	}
+	return firstError(
+		requireList("groups", c.Groups),
+		nonNegative("limit_per_account", c.LimitPerAccount),
+		nonNegative("interval_seconds", c.IntervalSeconds),
+		nonNegative("interval_jitter_seconds", c.IntervalJitterSeconds),
+		nonNegative("max_messages_per_group_per_day", c.MaxMessagesPerGroupPerDay),
+		nonNegative("join_interval_seconds", c.JoinIntervalSeconds),
+	)
+}
+
+// GroupChatConfig AI炒群任务配置，group_id 与 group_name 至少填一个
+type GroupChatConfig struct {
+	GroupID                *float64               `json:"group_id"`
+	GroupName              string                 `json:"group_name"`
+	AIConfig               map[string]interface{} `json:"ai_config"`
+	MonitorDurationSeconds *float64               `json:"monitor_duration_seconds"`
+}
+
+func (c *GroupChatConfig) validate() error {
+	if (c.GroupID == nil || *c.GroupID == 0) && c.GroupName == "" {
+		return &TaskConfigFieldError{Field: "group_name", Message: "group_id 和 group_name 至少需要填写一个"}
+	}
+	return nonNegative("monitor_duration_seconds", c.MonitorDurationSeconds)
+}
+
+// JoinGroupConfig 批量加群任务配置
+type JoinGroupConfig struct {
+	Groups          []interface{} `json:"groups"`
+	IntervalSeconds *float64      `json:"interval_seconds"`
+}
+
+func (c *JoinGroupConfig) validate() error {
+	return firstError(
+		requireList("groups", c.Groups),
+		nonNegative("interval_seconds", c.IntervalSeconds),
+	)
+}
+
+// ForceAddConfig 强拉进群任务配置
+type ForceAddConfig struct {
+	Targets         []interface{} `json:"targets"`
+	GroupName       string        `json:"group_name"`
+	AutoJoin        bool          `json:"auto_join"`
+	IntervalSeconds *float64      `json:"interval_seconds"`
+	LimitPerAccount *float64      `json:"limit_per_account"`
+}
+
+func (c *ForceAddConfig) validate() error {
+	return firstError(
+		requireList("targets", c.Targets),
+		requireString("group_name", c.GroupName),
+		nonNegative("interval_seconds", c.IntervalSeconds),
+		nonNegative("limit_per_account", c.LimitPerAccount),
+	)
+}
+
+// Update2FAConfig 修改2FA密码任务配置
+type Update2FAConfig struct {
+	NewPassword string `json:"new_password"`
+	OldPassword string `json:"old_password"`
+	Hint        string `json:"hint"`
+}
+
+func (c *Update2FAConfig) validate() error {
+	return nil
+}
+
+// ClearHistoryConfig 清空对话记录任务配置
+type ClearHistoryConfig struct {
+	Revoke          bool          `json:"revoke"`
+	IntervalSeconds *float64      `json:"interval_seconds"`
+	PreservePeers   []interface{} `json:"preserve_peers"`
+}
+
+func (c *ClearHistoryConfig) validate() error {
+	return nonNegative("interval_seconds", c.IntervalSeconds)
+}
+
+// BotInteractionConfig 机器人交互任务配置
+type BotInteractionConfig struct {
+	BotUsername    string        `json:"bot_username"`
+	StartParam     string        `json:"start_param"`
+	Buttons        []interface{} `json:"buttons"`
+	TimeoutSeconds *float64      `json:"timeout_seconds"`
+}
+
+func (c *BotInteractionConfig) validate() error {
+	return firstError(
+		requireString("bot_username", c.BotUsername),
+		nonNegative("timeout_seconds", c.TimeoutSeconds),
+	)
+}
+
+// ForwardMessageConfig 消息转发任务配置
+type ForwardMessageConfig struct {
+	Source            string        `json:"source"`
+	MessageIDs        []float64     `json:"message_ids"`
+	Targets           []interface{} `json:"targets"`
+	DropAuthor        bool          `json:"drop_author"`
+	DropMediaCaptions bool          `json:"drop_media_captions"`
+	IntervalSeconds   *float64      `json:"interval_seconds"`
+}
+
+func (c *ForwardMessageConfig) validate() error {
+	if len(c.MessageIDs) == 0 {
+		return &TaskConfigFieldError{Field: "message_ids", Message: "不能为空"}
+	}
+	for i, id := range c.MessageIDs {
+		if id <= 0 || id != float64(int64(id)) {
+			return &TaskConfigFieldError{Field: fmt.Sprintf("message_ids[%d]", i), Message: "应为正整数"}
+		}
+	}
+	return firstError(
+		requireString("source", c.Source),
+		requireList("targets", c.Targets),
+		nonNegative("interval_seconds", c.IntervalSeconds),
+	)
+}
+
+// MemberMessageConfig 群成员私信任务配置
+type MemberMessageConfig struct {
+	Group           string   `json:"group"`
+	Message         string   `json:"message"`
+	ParseMode       string   `json:"parse_mode"`
+	MaxMembers      *float64 `json:"max_members"`
+	IntervalSeconds *float64 `json:"interval_seconds"`
+}
+
+func (c *MemberMessageConfig) validate() error {
+	return firstError(
+		requireString("group", c.Group),
+		requireString("message", c.Message),
+		nonNegative("max_members", c.MaxMembers),
+		nonNegative("interval_seconds", c.IntervalSeconds),
+	)
+}
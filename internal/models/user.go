@@ -117,6 +117,7 @@ type UserProfile struct {
 	Username    string     `json:"username"`
 	Email       string     `json:"email"`
 	Role        UserRole   `json:"role"`
+	IsAdmin     bool       `json:"is_admin"`
 	IsActive    bool       `json:"is_active"`
 	IsExpired   bool       `json:"is_expired"`
 	ExpiresAt   *time.Time `json:"expires_at"`
@@ -192,8 +193,9 @@ func NewUserExpiredError(user *User) *UserExpiredError {
 
 // UserRiskSettings 用户风控配置
 type UserRiskSettings struct {
-	MaxConsecutiveFailures int `json:"max_consecutive_failures"` // 连续失败次数阈值，默认5，范围3-10
-	CoolingDurationMinutes int `json:"cooling_duration_minutes"` // 冷却时长（分钟），默认30，范围10-120
+	MaxConsecutiveFailures int  `json:"max_consecutive_failures"` // 连续失败次数阈值，默认5，范围3-10
+	CoolingDurationMinutes int  `json:"cooling_duration_minutes"` // 冷却时长（分钟），默认30，范围10-120
+	Auto2FAEnabled         bool `json:"auto_2fa_enabled"`         // 是否允许定时任务为未开启 2FA 的账号自动设置密码，默认关闭
 }
 
 // GetDefaultRiskSettings 获取默认风控配置
@@ -221,6 +223,7 @@ func (s *UserRiskSettings) Validate() {
 
 // UpdateRiskSettingsRequest 更新风控配置请求
 type UpdateRiskSettingsRequest struct {
-	MaxConsecutiveFailures int `json:"max_consecutive_failures" binding:"min=3,max=10"`
-	CoolingDurationMinutes int `json:"cooling_duration_minutes" binding:"min=10,max=120"`
+	MaxConsecutiveFailures int  `json:"max_consecutive_failures" binding:"min=3,max=10"`
+	CoolingDurationMinutes int  `json:"cooling_duration_minutes" binding:"min=10,max=120"`
+	Auto2FAEnabled         bool `json:"auto_2fa_enabled"`
 }
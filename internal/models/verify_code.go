@@ -107,4 +107,8 @@ var (
 		Code:    "TELEGRAM_CONNECTION_ERROR",
 		Message: "Telegram连接失败，请检查账号状态",
 	}
+	ErrVerifyInProgress = &VerifyCodeError{
+		Code:    "VERIFY_IN_PROGRESS",
+		Message: "该账号已有验证码监听正在进行，请等待其结束后重试",
+	}
 )
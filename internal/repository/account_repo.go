@@ -2,6 +2,10 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -21,15 +25,29 @@ type AccountRepository interface {
 	GetByUserID(userID uint64, offset, limit int) ([]*models.TGAccount, int64, error)
 	Update(account *models.TGAccount) error
 	UpdateProxyID(id uint64, proxyID *uint64) error
+	GetDanglingProxyBindings() ([]*models.TGAccount, error)
+	ClearProxyBindings(accountIDs []uint64) error
 	UpdateStatus(id uint64, status models.AccountStatus) error
+	UpdateStatusesWithHistory(histories []*models.AccountStatusHistory) error
+	GetStatusHistory(accountID uint64, limit int) ([]*models.AccountStatusHistory, error)
+	UpdateProxyBindingsWithHistory(histories []*models.ProxyBindingHistory) error
+	GetProxyBindingHistory(accountID uint64, limit int) ([]*models.ProxyBindingHistory, error)
 	Delete(id uint64) error
 	GetAccountsByStatus(status models.AccountStatus) ([]*models.TGAccount, error)
 	CountByUserID(userID uint64) (int64, error)
 	CountActiveByUserID(userID uint64) (int64, error)
-	GetAccountSummaries(userID uint64, page, limit int, search, status string) ([]*models.AccountSummary, int64, error)
+	GetAccountSummaries(userID uint64, page, limit int, search, status, countryCode string, timeRange AccountTimeRange) ([]*models.AccountSummary, int64, error)
 	GetAll() ([]*models.TGAccount, error)
 	UpdateSessionData(accountID uint64, sessionData []byte) error
 	UpdateConnectionStatus(id uint64, isOnline bool) error
+	UpdateLastUsed(id uint64) error
+	GetHeartbeatAccounts(before time.Time, limit int) ([]*models.TGAccount, error)
+	GetAccountsWithout2FA(limit int) ([]*models.TGAccount, error)
+	CountEncryptedTwoFAPasswords() (int64, error)
+	GetRecentlyUsedAccounts(limit int) ([]*models.TGAccount, error)
+	UpdateTOSStatus(id uint64, pending bool, acceptedAt *time.Time) error
+	UpdateChannelLimit(id uint64, reachedAt *time.Time) error
+	UpdateLastHeartbeat(id uint64, at time.Time) error
 	Update2FAStatus(id uint64, has2FA bool, password string) error
 	UpdateRestrictionStatus(id uint64, status models.AccountStatus, isBidirectional bool, frozenUntil *string) error
 	GetStatusDistribution(userID uint64) (map[string]int64, error)
@@ -174,6 +192,30 @@ func (r *accountRepository) Update(account *models.TGAccount) error {
 	return r.db.Save(account).Error
 }
 
+// GetDanglingProxyBindings 获取绑定的代理已不存在的账号
+func (r *accountRepository) GetDanglingProxyBindings() ([]*models.TGAccount, error) {
+	var accounts []*models.TGAccount
+	err := r.db.Model(&models.TGAccount{}).
+		Select("tg_accounts.id, tg_accounts.user_id, tg_accounts.phone, tg_accounts.proxy_id").
+		Joins("LEFT JOIN proxy_ips ON proxy_ips.id = tg_accounts.proxy_id").
+		Where("tg_accounts.proxy_id IS NOT NULL AND proxy_ips.id IS NULL").
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// ClearProxyBindings 解除账号的代理绑定
+func (r *accountRepository) ClearProxyBindings(accountIDs []uint64) error {
+	if len(accountIDs) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.TGAccount{}).
+		Where("id IN ?", accountIDs).
+		Updates(map[string]interface{}{
+			"proxy_id":   nil,
+			"updated_at": time.Now(),
+		}).Error
+}
+
 // UpdateProxyID 更新账号的代理ID（支持设置为NULL）
 func (r *accountRepository) UpdateProxyID(id uint64, proxyID *uint64) error {
 	return r.db.Model(&models.TGAccount{}).
@@ -194,6 +236,70 @@ func (r *accountRepository) UpdateStatus(id uint64, status models.AccountStatus)
 		}).Error
 }
 
+// UpdateStatusesWithHistory 在同一事务中更新多个账号状态并写入状态变更记录
+func (r *accountRepository) UpdateStatusesWithHistory(histories []*models.AccountStatusHistory) error {
+	if len(histories) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, history := range histories {
+			err := tx.Model(&models.TGAccount{}).
+				Where("id = ?", history.AccountID).
+				Updates(map[string]interface{}{
+					"status":     history.ToStatus,
+					"updated_at": now,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(&histories).Error
+	})
+}
+
+// GetStatusHistory 获取账号最近的状态变更记录
+func (r *accountRepository) GetStatusHistory(accountID uint64, limit int) ([]*models.AccountStatusHistory, error) {
+	var histories []*models.AccountStatusHistory
+	err := r.db.Where("account_id = ?", accountID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&histories).Error
+	return histories, err
+}
+
+// UpdateProxyBindingsWithHistory 在同一事务中更新多个账号的代理绑定并写入绑定变更记录
+func (r *accountRepository) UpdateProxyBindingsWithHistory(histories []*models.ProxyBindingHistory) error {
+	if len(histories) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, history := range histories {
+			err := tx.Model(&models.TGAccount{}).
+				Where("id = ?", history.AccountID).
+				Updates(map[string]interface{}{
+					"proxy_id":   history.NewProxyID,
+					"updated_at": now,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(&histories).Error
+	})
+}
+
+// GetProxyBindingHistory 获取账号最近的代理绑定变更记录
+func (r *accountRepository) GetProxyBindingHistory(accountID uint64, limit int) ([]*models.ProxyBindingHistory, error) {
+	var histories []*models.ProxyBindingHistory
+	err := r.db.Where("account_id = ?", accountID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&histories).Error
+	return histories, err
+}
+
 // Delete 删除账号
 func (r *accountRepository) Delete(id uint64) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
@@ -283,8 +389,16 @@ func (r *accountRepository) GetAccountsWithFilters(filters map[string]interface{
 	return accounts, total, err
 }
 
+// AccountTimeRange 账号时间范围过滤条件，未设置的一端不限制
+type AccountTimeRange struct {
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	LastUsedFrom *time.Time
+	LastUsedTo   *time.Time
+}
+
 // GetAccountSummaries 获取账号摘要列表（分页）
-func (r *accountRepository) GetAccountSummaries(userID uint64, page, limit int, search, status string) ([]*models.AccountSummary, int64, error) {
+func (r *accountRepository) GetAccountSummaries(userID uint64, page, limit int, search, status, countryCode string, timeRange AccountTimeRange) ([]*models.AccountSummary, int64, error) {
 	var summaries []*models.AccountSummary
 	var total int64
 
@@ -293,9 +407,10 @@ func (r *accountRepository) GetAccountSummaries(userID uint64, page, limit int,
 	// 构建查询
 	query := r.db.Model(&models.TGAccount{}).Where("tg_accounts.user_id = ?", userID)
 
-	// 添加搜索条件（仅搜索手机号）
+	// 添加搜索条件：手机号模糊匹配，用户名和 tg_user_id 精确/前缀匹配（可走索引）
+	search = strings.TrimSpace(search)
 	if search != "" {
-		query = query.Where("tg_accounts.phone LIKE ?", "%"+search+"%")
+		query = query.Where(accountSearchCondition(r.db, search))
 	}
 
 	// 添加状态过滤条件
@@ -303,6 +418,25 @@ func (r *accountRepository) GetAccountSummaries(userID uint64, page, limit int,
 		query = query.Where("tg_accounts.status = ?", status)
 	}
 
+	// 添加国家过滤条件
+	if countryCode != "" {
+		query = query.Where("tg_accounts.country_code = ?", countryCode)
+	}
+
+	// 添加时间范围过滤条件
+	if timeRange.CreatedFrom != nil {
+		query = query.Where("tg_accounts.created_at >= ?", *timeRange.CreatedFrom)
+	}
+	if timeRange.CreatedTo != nil {
+		query = query.Where("tg_accounts.created_at <= ?", *timeRange.CreatedTo)
+	}
+	if timeRange.LastUsedFrom != nil {
+		query = query.Where("tg_accounts.last_used_at >= ?", *timeRange.LastUsedFrom)
+	}
+	if timeRange.LastUsedTo != nil {
+		query = query.Where("tg_accounts.last_used_at <= ?", *timeRange.LastUsedTo)
+	}
+
 	// 获取总数
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -310,11 +444,11 @@ func (r *accountRepository) GetAccountSummaries(userID uint64, page, limit int,
 
 	// 获取摘要数据（包含 Telegram 信息、代理信息和风控字段）
 	err := query.
-		Select("tg_accounts.id, tg_accounts.user_id, tg_accounts.phone, tg_accounts.status, tg_accounts.is_online, tg_accounts.proxy_id, tg_accounts.frozen_until, tg_accounts.has_2fa, tg_accounts.two_fa_password, tg_accounts.consecutive_failures, tg_accounts.cooling_until, tg_accounts.tg_user_id, tg_accounts.username, tg_accounts.first_name, tg_accounts.last_name, tg_accounts.bio, tg_accounts.photo_url, tg_accounts.last_used_at, tg_accounts.created_at, proxy_ips.name as proxy_name, proxy_ips.ip as proxy_ip, proxy_ips.port as proxy_port, proxy_ips.username as proxy_username, proxy_ips.password as proxy_password, proxy_ips.protocol as proxy_protocol").
+		Select("tg_accounts.id, tg_accounts.user_id, tg_accounts.phone, tg_accounts.status, tg_accounts.is_online, tg_accounts.proxy_id, tg_accounts.country_code, tg_accounts.region, tg_accounts.frozen_until, tg_accounts.has_2fa, tg_accounts.two_fa_password, tg_accounts.consecutive_failures, tg_accounts.cooling_until, tg_accounts.tg_user_id, tg_accounts.username, tg_accounts.first_name, tg_accounts.last_name, tg_accounts.bio, tg_accounts.photo_url, tg_accounts.last_used_at, tg_accounts.created_at, proxy_ips.name as proxy_name, proxy_ips.ip as proxy_ip, proxy_ips.port as proxy_port, proxy_ips.username as proxy_username, proxy_ips.password as proxy_password, proxy_ips.protocol as proxy_protocol").
 		Joins("LEFT JOIN proxy_ips ON proxy_ips.id = tg_accounts.proxy_id").
 		Offset(offset).
 		Limit(limit).
-		Order("tg_accounts.created_at DESC").
+		Order("tg_accounts.created_at DESC, tg_accounts.id DESC").
 		Scan(&summaries).Error
 
 	// 确保返回空数组而不是 nil
@@ -322,9 +456,80 @@ func (r *accountRepository) GetAccountSummaries(userID uint64, page, limit int,
 		summaries = []*models.AccountSummary{}
 	}
 
+	for _, summary := range summaries {
+		// 无法解密时不返回密文
+		summary.TwoFAPassword, _ = models.DecryptTwoFAPassword(summary.TwoFAPassword)
+		if search != "" {
+			summary.MatchedField = matchedAccountField(summary, search)
+		}
+	}
+
 	return summaries, total, err
 }
 
+// accountSearchCondition 构建账号搜索条件，以 @ 开头时只匹配用户名
+func accountSearchCondition(db *gorm.DB, search string) *gorm.DB {
+	if strings.HasPrefix(search, "@") {
+		return db.Where("tg_accounts.username LIKE ?", escapeLike(strings.TrimPrefix(search, "@"))+"%")
+	}
+
+	cond := db.Where("tg_accounts.phone LIKE ?", "%"+escapeLike(search)+"%").
+		Or("tg_accounts.username LIKE ?", escapeLike(search)+"%")
+
+	// 纯数字时按 tg_user_id 精确或前缀匹配，前缀转换为若干数值区间以利用索引
+	if _, err := strconv.ParseUint(search, 10, 64); err == nil {
+		for _, r := range userIDPrefixRanges(search) {
+			cond = cond.Or("tg_accounts.tg_user_id BETWEEN ? AND ?", r[0], r[1])
+		}
+	}
+	return cond
+}
+
+// userIDPrefixRanges 将数字前缀转换为数值区间，如前缀 12 对应 12、120-129、1200-1299 ...
+func userIDPrefixRanges(prefix string) [][2]int64 {
+	if strings.HasPrefix(prefix, "0") {
+		return nil
+	}
+	low, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || low <= 0 {
+		return nil
+	}
+
+	var ranges [][2]int64
+	span := int64(1)
+	for {
+		ranges = append(ranges, [2]int64{low, low + span - 1})
+		if low > math.MaxInt64/10 {
+			return ranges
+		}
+		low, span = low*10, span*10
+		if low > math.MaxInt64-(span-1) {
+			// 最后一段截断到 int64 上限
+			return append(ranges, [2]int64{low, math.MaxInt64})
+		}
+	}
+}
+
+// matchedAccountField 判断搜索词命中的字段
+func matchedAccountField(summary *models.AccountSummary, search string) string {
+	keyword := strings.ToLower(strings.TrimPrefix(search, "@"))
+	if !strings.HasPrefix(search, "@") && strings.Contains(summary.Phone, search) {
+		return "phone"
+	}
+	if summary.Username != nil && strings.HasPrefix(strings.ToLower(*summary.Username), keyword) {
+		return "username"
+	}
+	if summary.TgUserID != nil && strings.HasPrefix(strconv.FormatInt(*summary.TgUserID, 10), search) {
+		return "tg_user_id"
+	}
+	return ""
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
 // GetAll 获取所有账号
 func (r *accountRepository) GetAll() ([]*models.TGAccount, error) {
 	var accounts []*models.TGAccount
@@ -346,6 +551,82 @@ func (r *accountRepository) UpdateConnectionStatus(id uint64, isOnline bool) err
 		Update("is_online", isOnline).Error
 }
 
+// GetHeartbeatAccounts 获取开启在线心跳、状态可用且上次心跳早于 before 的账号，最久未心跳的优先
+func (r *accountRepository) GetHeartbeatAccounts(before time.Time, limit int) ([]*models.TGAccount, error) {
+	var accounts []*models.TGAccount
+	err := r.db.Model(&models.TGAccount{}).
+		Select("id, user_id, phone, status, last_heartbeat_at").
+		Where("heartbeat_enabled = ?", true).
+		Where("status NOT IN ?", []models.AccountStatus{
+			models.AccountStatusDead,
+			models.AccountStatusCooling,
+			models.AccountStatusMaintenance,
+			models.AccountStatusFrozen,
+		}).
+		Where("last_heartbeat_at IS NULL OR last_heartbeat_at < ?", before).
+		Order("last_heartbeat_at IS NOT NULL, last_heartbeat_at ASC").
+		Limit(limit).
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// CountEncryptedTwoFAPasswords 统计以密文保存 2FA 密码的账号数
+func (r *accountRepository) CountEncryptedTwoFAPasswords() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TGAccount{}).
+		Where("two_fa_password LIKE ?", "enc:%").
+		Count(&count).Error
+	return count, err
+}
+
+// GetAccountsWithout2FA 获取开启自动 2FA 的用户下未开启 2FA 且状态可用的账号，最早添加的优先
+func (r *accountRepository) GetAccountsWithout2FA(limit int) ([]*models.TGAccount, error) {
+	optedIn := r.db.Model(&models.User{}).
+		Select("id").
+		Where("JSON_EXTRACT(risk_settings, '$.auto_2fa_enabled') = TRUE")
+
+	var accounts []*models.TGAccount
+	err := r.db.Model(&models.TGAccount{}).
+		Select("id, user_id, phone, status").
+		Where("has_2fa = ?", false).
+		Where("user_id IN (?)", optedIn).
+		Where("status NOT IN ?", []models.AccountStatus{
+			models.AccountStatusDead,
+			models.AccountStatusCooling,
+			models.AccountStatusMaintenance,
+			models.AccountStatusFrozen,
+		}).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// GetRecentlyUsedAccounts 获取最近使用过且状态可用的账号，最近使用的优先
+func (r *accountRepository) GetRecentlyUsedAccounts(limit int) ([]*models.TGAccount, error) {
+	var accounts []*models.TGAccount
+	err := r.db.Model(&models.TGAccount{}).
+		Select("id, user_id, phone, status, last_used_at").
+		Where("last_used_at IS NOT NULL").
+		Where("status NOT IN ?", []models.AccountStatus{
+			models.AccountStatusDead,
+			models.AccountStatusCooling,
+			models.AccountStatusMaintenance,
+			models.AccountStatusFrozen,
+		}).
+		Order("last_used_at DESC").
+		Limit(limit).
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// UpdateLastHeartbeat 记录最近一次在线心跳时间
+func (r *accountRepository) UpdateLastHeartbeat(id uint64, at time.Time) error {
+	return r.db.Model(&models.TGAccount{}).
+		Where("id = ?", id).
+		Update("last_heartbeat_at", at).Error
+}
+
 // Update2FAStatus 更新账号2FA状态
 func (r *accountRepository) Update2FAStatus(id uint64, has2FA bool, password string) error {
 	updates := map[string]interface{}{
@@ -353,13 +634,37 @@ func (r *accountRepository) Update2FAStatus(id uint64, has2FA bool, password str
 		"updated_at": time.Now(),
 	}
 	if password != "" {
-		updates["two_fa_password"] = password
+		encrypted, err := models.EncryptTwoFAPassword(password)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt 2FA password: %w", err)
+		}
+		updates["two_fa_password"] = encrypted
 	}
 	return r.db.Model(&models.TGAccount{}).
 		Where("id = ?", id).
 		Updates(updates).Error
 }
 
+// UpdateTOSStatus 更新服务条款状态，acceptedAt 为空时保留上次接受时间
+func (r *accountRepository) UpdateTOSStatus(id uint64, pending bool, acceptedAt *time.Time) error {
+	updates := map[string]interface{}{
+		"tos_pending": pending,
+	}
+	if acceptedAt != nil {
+		updates["tos_accepted_at"] = acceptedAt
+	}
+	return r.db.Model(&models.TGAccount{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+// UpdateChannelLimit 记录账号达到频道数量上限的时间，reachedAt 为空时清除标记
+func (r *accountRepository) UpdateChannelLimit(id uint64, reachedAt *time.Time) error {
+	return r.db.Model(&models.TGAccount{}).
+		Where("id = ?", id).
+		Update("channel_limit_at", reachedAt).Error
+}
+
 // UpdateRestrictionStatus 更新账号限制状态（状态和双向限制）
 func (r *accountRepository) UpdateRestrictionStatus(id uint64, status models.AccountStatus, isBidirectional bool, frozenUntil *string) error {
 	updates := map[string]interface{}{
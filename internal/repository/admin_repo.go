@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"tg_cloud_server/internal/models"
+)
+
+// AdminRepository 管理员跨用户统计仓库接口
+type AdminRepository interface {
+	CountAll() (users, accounts, tasks, proxies int64, err error)
+	CountAccountsByStatus() (map[string]int64, error)
+	CountTasksByStatus() (map[string]int64, error)
+	GetRecentErrorLogs(limit int) ([]*models.AdminErrorLog, error)
+	GetRecentRiskLogs(limit int) ([]*models.RiskLog, error)
+}
+
+// adminRepository GORM实现
+type adminRepository struct {
+	db *gorm.DB
+}
+
+// NewAdminRepository 创建管理员统计仓库
+func NewAdminRepository(db *gorm.DB) AdminRepository {
+	return &adminRepository{db: db}
+}
+
+// CountAll 统计所有用户的用户、账号、任务、代理总数
+func (r *adminRepository) CountAll() (users, accounts, tasks, proxies int64, err error) {
+	if err = r.db.Model(&models.User{}).Count(&users).Error; err != nil {
+		return
+	}
+	if err = r.db.Model(&models.TGAccount{}).Count(&accounts).Error; err != nil {
+		return
+	}
+	if err = r.db.Model(&models.Task{}).Count(&tasks).Error; err != nil {
+		return
+	}
+	err = r.db.Model(&models.ProxyIP{}).Count(&proxies).Error
+	return
+}
+
+// CountAccountsByStatus 按状态统计所有账号
+func (r *adminRepository) CountAccountsByStatus() (map[string]int64, error) {
+	return r.countByStatus(&models.TGAccount{})
+}
+
+// CountTasksByStatus 按状态统计所有任务
+func (r *adminRepository) CountTasksByStatus() (map[string]int64, error) {
+	return r.countByStatus(&models.Task{})
+}
+
+func (r *adminRepository) countByStatus(model interface{}) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(model).
+		Select("status, COUNT(*) as count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// GetRecentErrorLogs 获取最近的任务错误日志，最新的优先
+func (r *adminRepository) GetRecentErrorLogs(limit int) ([]*models.AdminErrorLog, error) {
+	var logs []*models.AdminErrorLog
+	err := r.db.Table("task_logs").
+		Select("task_logs.id, task_logs.task_id, tasks.user_id, task_logs.account_id, task_logs.action, task_logs.message, task_logs.created_at").
+		Joins("LEFT JOIN tasks ON tasks.id = task_logs.task_id").
+		Where("task_logs.level = ?", "error").
+		Order("task_logs.created_at DESC").
+		Limit(limit).
+		Scan(&logs).Error
+	return logs, err
+}
+
+// GetRecentRiskLogs 获取最近的风控事件，最新的优先
+func (r *adminRepository) GetRecentRiskLogs(limit int) ([]*models.RiskLog, error) {
+	var logs []*models.RiskLog
+	err := r.db.Order("created_at DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
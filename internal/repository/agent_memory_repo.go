@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"tg_cloud_server/internal/models"
+)
+
+// AgentMemoryRepository 智能体记忆仓库接口
+type AgentMemoryRepository interface {
+	Get(accountID uint64, groupKey string) (*models.AgentMemory, error)
+	Save(memory *models.AgentMemory) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// agentMemoryRepository 智能体记忆仓库实现
+type agentMemoryRepository struct {
+	db *gorm.DB
+}
+
+// NewAgentMemoryRepository 创建智能体记忆仓库
+func NewAgentMemoryRepository(db *gorm.DB) AgentMemoryRepository {
+	return &agentMemoryRepository{db: db}
+}
+
+// Get 获取账号在指定群组的记忆，不存在时返回 nil
+func (r *agentMemoryRepository) Get(accountID uint64, groupKey string) (*models.AgentMemory, error) {
+	var memory models.AgentMemory
+	err := r.db.Where("account_id = ? AND group_key = ?", accountID, groupKey).First(&memory).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &memory, nil
+}
+
+// Save 保存记忆，账号+群组已存在时覆盖摘要
+func (r *agentMemoryRepository) Save(memory *models.AgentMemory) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "group_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"summary", "updated_at"}),
+	}).Create(memory).Error
+}
+
+// DeleteExpired 删除指定时间之前未更新的记忆
+func (r *agentMemoryRepository) DeleteExpired(before time.Time) (int64, error) {
+	result := r.db.Where("updated_at < ?", before).Delete(&models.AgentMemory{})
+	return result.RowsAffected, result.Error
+}
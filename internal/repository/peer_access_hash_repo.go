@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"tg_cloud_server/internal/models"
+)
+
+// PeerAccessHashRepository access hash 仓库接口
+type PeerAccessHashRepository interface {
+	Get(accountID uint64, peerType string, peerID int64) (*models.PeerAccessHash, error)
+	Save(entry *models.PeerAccessHash) error
+	Delete(accountID uint64, peerType string, peerID int64) error
+}
+
+// peerAccessHashRepository access hash 仓库实现
+type peerAccessHashRepository struct {
+	db *gorm.DB
+}
+
+// NewPeerAccessHashRepository 创建 access hash 仓库
+func NewPeerAccessHashRepository(db *gorm.DB) PeerAccessHashRepository {
+	return &peerAccessHashRepository{db: db}
+}
+
+// Get 获取账号保存的 access hash，不存在时返回 nil
+func (r *peerAccessHashRepository) Get(accountID uint64, peerType string, peerID int64) (*models.PeerAccessHash, error) {
+	var entry models.PeerAccessHash
+	err := r.db.Where("account_id = ? AND peer_type = ? AND peer_id = ?", accountID, peerType, peerID).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Save 保存 access hash，已存在时覆盖
+func (r *peerAccessHashRepository) Save(entry *models.PeerAccessHash) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "peer_type"}, {Name: "peer_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access_hash", "updated_at"}),
+	}).Create(entry).Error
+}
+
+// Delete 删除失效的 access hash
+func (r *peerAccessHashRepository) Delete(accountID uint64, peerType string, peerID int64) error {
+	return r.db.Where("account_id = ? AND peer_type = ? AND peer_id = ?", accountID, peerType, peerID).
+		Delete(&models.PeerAccessHash{}).Error
+}
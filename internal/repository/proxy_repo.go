@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"tg_cloud_server/internal/models"
@@ -19,6 +21,7 @@ type ProxyRepository interface {
 	// 代理查询
 	GetAvailableProxies(userID uint64) ([]*models.Proxy, error)
 	GetProxiesByStatus(userID uint64, status string) ([]*models.Proxy, error)
+	ListForExport(userID uint64, conditions map[string]interface{}, from, to *time.Time, offset, limit int) ([]*models.ProxyIP, int64, error)
 
 	// 代理统计
 	GetProxyStats(userID uint64) (*models.ProxyStats, error)
@@ -27,8 +30,12 @@ type ProxyRepository interface {
 
 	// 批量操作
 	BatchCreate(proxies []*models.ProxyIP) error
-	BatchDelete(ids []uint64) error
+	BatchDelete(userID uint64, ids []uint64, actor string) ([]uint64, error)
 	BulkUpdateStatus(proxyIDs []uint64, status string) error
+
+	// 账号绑定
+	CountBoundAccounts(userID uint64, ids []uint64) (int64, error)
+	MigrateBindings(fromID, toID uint64, actor string) ([]uint64, error)
 }
 
 // proxyRepository GORM实现
@@ -94,7 +101,12 @@ func (r *proxyRepository) Update(proxy *models.Proxy) error {
 
 // Delete 删除代理
 func (r *proxyRepository) Delete(id uint64) error {
-	return r.db.Delete(&models.Proxy{}, id).Error
+	proxy, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.BatchDelete(proxy.UserID, []uint64{id}, models.ProxyBindingActorSystem)
+	return err
 }
 
 // GetAvailableProxies 获取可用代理
@@ -115,6 +127,37 @@ func (r *proxyRepository) GetProxiesByStatus(userID uint64, status string) ([]*m
 	return proxies, err
 }
 
+// ListForExport 分页获取用户代理用于导出，按 created_at 过滤时间范围
+func (r *proxyRepository) ListForExport(userID uint64, conditions map[string]interface{}, from, to *time.Time, offset, limit int) ([]*models.ProxyIP, int64, error) {
+	var proxies []*models.ProxyIP
+	var total int64
+
+	query := r.db.Model(&models.ProxyIP{}).Where("user_id = ?", userID)
+	if len(conditions) > 0 {
+		query = query.Where(conditions)
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at <= ?", *to)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 按主键排序，保证分页稳定
+	err := query.Order("id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&proxies).Error
+	if proxies == nil {
+		proxies = []*models.ProxyIP{}
+	}
+	return proxies, total, err
+}
+
 // GetProxyStats 获取代理统计
 func (r *proxyRepository) GetProxyStats(userID uint64) (*models.ProxyStats, error) {
 	var stats models.ProxyStats
@@ -246,17 +289,101 @@ func (r *proxyRepository) BatchCreate(proxies []*models.ProxyIP) error {
 	})
 }
 
-// BatchDelete 批量删除代理（使用事务）
-func (r *proxyRepository) BatchDelete(ids []uint64) error {
+// BatchDelete 批量删除代理（使用事务），被解绑的账号写入代理绑定变更记录
+func (r *proxyRepository) BatchDelete(userID uint64, ids []uint64, actor string) ([]uint64, error) {
 	if len(ids) == 0 {
-		return nil
+		return nil, nil
 	}
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	var unbound []uint64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 只处理属于该用户的代理
+		var owned []uint64
+		if err := tx.Model(&models.ProxyIP{}).Where("user_id = ? AND id IN ?", userID, ids).Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		if len(owned) == 0 {
+			return nil
+		}
+
+		var bound []*models.TGAccount
+		if err := tx.Model(&models.TGAccount{}).Select("id, user_id, proxy_id").Where("proxy_id IN ?", owned).Find(&bound).Error; err != nil {
+			return err
+		}
+
 		// 先解除账号与代理的绑定
-		if err := tx.Model(&models.TGAccount{}).Where("proxy_id IN ?", ids).Update("proxy_id", nil).Error; err != nil {
+		if err := tx.Model(&models.TGAccount{}).Where("proxy_id IN ?", owned).Update("proxy_id", nil).Error; err != nil {
 			return err
 		}
+		if len(bound) > 0 {
+			histories := make([]*models.ProxyBindingHistory, 0, len(bound))
+			for _, account := range bound {
+				unbound = append(unbound, account.ID)
+				histories = append(histories, &models.ProxyBindingHistory{
+					AccountID:  account.ID,
+					UserID:     account.UserID,
+					OldProxyID: account.ProxyID,
+					Reason:     models.ProxyBindingReasonProxyDeleted,
+					Actor:      actor,
+				})
+			}
+			if err := tx.Create(&histories).Error; err != nil {
+				return err
+			}
+		}
 		// 再删除代理
-		return tx.Delete(&models.ProxyIP{}, ids).Error
+		return tx.Delete(&models.ProxyIP{}, owned).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unbound, nil
+}
+
+// CountBoundAccounts 统计用户绑定到指定代理的账号数量
+func (r *proxyRepository) CountBoundAccounts(userID uint64, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := r.db.Model(&models.TGAccount{}).Where("user_id = ? AND proxy_id IN ?", userID, ids).Count(&count).Error
+	return count, err
+}
+
+// MigrateBindings 将绑定到 fromID 的账号全部改绑到 toID（使用事务），写入代理绑定变更记录，返回被迁移的账号ID
+func (r *proxyRepository) MigrateBindings(fromID, toID uint64, actor string) ([]uint64, error) {
+	var accountIDs []uint64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var bound []*models.TGAccount
+		if err := tx.Model(&models.TGAccount{}).Select("id, user_id").Where("proxy_id = ?", fromID).Find(&bound).Error; err != nil {
+			return err
+		}
+		if len(bound) == 0 {
+			return nil
+		}
+
+		histories := make([]*models.ProxyBindingHistory, 0, len(bound))
+		for _, account := range bound {
+			accountIDs = append(accountIDs, account.ID)
+			histories = append(histories, &models.ProxyBindingHistory{
+				AccountID:  account.ID,
+				UserID:     account.UserID,
+				OldProxyID: &fromID,
+				NewProxyID: &toID,
+				Reason:     models.ProxyBindingReasonMigration,
+				Actor:      actor,
+			})
+		}
+
+		err := tx.Model(&models.TGAccount{}).
+			Where("id IN ?", accountIDs).
+			Updates(map[string]interface{}{
+				"proxy_id":   toID,
+				"updated_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&histories).Error
 	})
+	return accountIDs, err
 }
@@ -27,6 +27,7 @@ type TaskRepository interface {
 	GetPendingTasks(limit int) ([]*models.Task, error)
 	GetTasksByStatus(status models.TaskStatus) ([]*models.Task, error)
 	GetTasksByAccountID(accountID uint64, statuses []string) ([]*models.Task, error)
+	ListForExport(userID uint64, conditions map[string]interface{}, from, to *time.Time, offset, limit int) ([]*models.Task, int64, error)
 
 	// 任务日志
 	GetTaskLogs(taskID uint64) ([]*models.TaskLog, error)
@@ -34,6 +35,7 @@ type TaskRepository interface {
 
 	// 任务统计
 	GetTaskStatsByUserID(userID uint64, startTime, endTime time.Time) (*models.TaskStats, error)
+	GetTaskStats(userID uint64, from, to time.Time) ([]*models.TaskTypeStats, error)
 	GetQueueInfoByAccountID(accountID uint64) (*models.QueueInfo, error)
 
 	// 批量操作
@@ -284,6 +286,38 @@ func (r *taskRepository) GetTasksByAccountID(accountID uint64, statuses []string
 	return tasks, err
 }
 
+// ListForExport 分页获取用户任务用于导出，按 created_at 过滤时间范围，不加载执行结果
+func (r *taskRepository) ListForExport(userID uint64, conditions map[string]interface{}, from, to *time.Time, offset, limit int) ([]*models.Task, int64, error) {
+	var tasks []*models.Task
+	var total int64
+
+	query := r.db.Model(&models.Task{}).Where("user_id = ?", userID)
+	if len(conditions) > 0 {
+		query = query.Where(conditions)
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at <= ?", *to)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 按主键排序，保证分页稳定
+	err := query.Omit("result").
+		Order("id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&tasks).Error
+	if tasks == nil {
+		tasks = []*models.Task{}
+	}
+	return tasks, total, err
+}
+
 // GetTaskLogs 获取任务日志
 func (r *taskRepository) GetTaskLogs(taskID uint64) ([]*models.TaskLog, error) {
 	var logs []*models.TaskLog
@@ -351,6 +385,32 @@ func (r *taskRepository) GetTaskStatsByUserID(userID uint64, startTime, endTime
 	return &stats, nil
 }
 
+// GetTaskStats 按任务类型聚合统计指定时间范围内创建的任务
+func (r *taskRepository) GetTaskStats(userID uint64, from, to time.Time) ([]*models.TaskTypeStats, error) {
+	var stats []*models.TaskTypeStats
+
+	query := r.db.Model(&models.Task{}).
+		Select(`task_type,
+			COUNT(*) AS total,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS completed,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS running,
+			COALESCE(AVG(CASE WHEN started_at IS NOT NULL AND completed_at IS NOT NULL
+				THEN TIMESTAMPDIFF(SECOND, started_at, completed_at) END), 0) AS avg_duration_seconds`,
+			models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusRunning).
+		Where("user_id = ?", userID)
+
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at <= ?", to)
+	}
+
+	err := query.Group("task_type").Order("total DESC").Scan(&stats).Error
+	return stats, err
+}
+
 // GetQueueInfoByAccountID 获取账号队列信息（搜索包含该账号的任务）
 func (r *taskRepository) GetQueueInfoByAccountID(accountID uint64) (*models.QueueInfo, error) {
 	var info models.QueueInfo
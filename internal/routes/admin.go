@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tg_cloud_server/internal/common/middleware"
+	"tg_cloud_server/internal/handlers"
+	"tg_cloud_server/internal/services"
+)
+
+// SetupAdminRoutes 设置管理员路由，仅管理员角色可访问
+func SetupAdminRoutes(router *gin.Engine, adminHandler *handlers.AdminHandler, authService *services.AuthService) {
+	adminGroup := router.Group("/api/v1/admin")
+	adminGroup.Use(middleware.JWTAuthMiddleware(authService))
+	adminGroup.Use(middleware.RequireAdmin())
+	{
+		adminGroup.GET("/overview", adminHandler.GetOverview)      // 系统总览
+		adminGroup.GET("/config", adminHandler.GetEffectiveConfig) // 当前生效的配置
+	}
+}
@@ -2,7 +2,9 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 
+	"tg_cloud_server/internal/common/config"
 	"tg_cloud_server/internal/common/middleware"
 	"tg_cloud_server/internal/handlers"
 	"tg_cloud_server/internal/services"
@@ -13,21 +15,26 @@ func SetupAIRoutes(
 	router *gin.RouterGroup,
 	aiHandler *handlers.AIHandler,
 	authService *services.AuthService,
+	redisClient *redis.Client,
+	rateLimit config.AIRateLimitConfig,
 ) {
 	// AI服务路由组
 	aiGroup := router.Group("/ai")
 	aiGroup.Use(middleware.JWTAuthMiddleware(authService))
 
+	// 调用 AI 提供商的接口按用户限流，防止单个用户耗尽共享的 API 额度
+	limited := middleware.AIRateLimit(redisClient, rateLimit.Requests, rateLimit.Window)
+
 	// 内容生成
-	aiGroup.POST("/group-chat", aiHandler.GenerateGroupChatResponse)   // 生成群聊回复
-	aiGroup.POST("/private-message", aiHandler.GeneratePrivateMessage) // 生成私信内容
+	aiGroup.POST("/group-chat", limited, aiHandler.GenerateGroupChatResponse)   // 生成群聊回复
+	aiGroup.POST("/private-message", limited, aiHandler.GeneratePrivateMessage) // 生成私信内容
 
 	// 文本分析
-	aiGroup.POST("/analyze-sentiment", aiHandler.AnalyzeSentiment)     // 情感分析
-	aiGroup.POST("/extract-keywords", aiHandler.ExtractKeywords)       // 关键词提取
-	aiGroup.POST("/generate-variations", aiHandler.GenerateVariations) // 生成变体
+	aiGroup.POST("/analyze-sentiment", limited, aiHandler.AnalyzeSentiment)     // 情感分析
+	aiGroup.POST("/extract-keywords", limited, aiHandler.ExtractKeywords)       // 关键词提取
+	aiGroup.POST("/generate-variations", limited, aiHandler.GenerateVariations) // 生成变体
 
 	// 服务管理
-	aiGroup.GET("/config", aiHandler.GetAIConfig)  // 获取AI配置
-	aiGroup.POST("/test", aiHandler.TestAIService) // 测试AI服务
+	aiGroup.GET("/config", aiHandler.GetAIConfig)           // 获取AI配置
+	aiGroup.POST("/test", limited, aiHandler.TestAIService) // 测试AI服务
 }
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 
 	"tg_cloud_server/internal/common/config"
 	"tg_cloud_server/internal/common/middleware"
@@ -20,6 +21,7 @@ func RegisterAPIRoutes(
 	settingsHandler *handlers.SettingsHandler,
 	aiHandler *handlers.AIHandler,
 	authService *services.AuthService,
+	redisClient *redis.Client,
 	config *config.Config,
 ) {
 	// 注册各模块路由
@@ -49,22 +51,29 @@ func RegisterAPIRoutes(
 	// 账号管理路由
 	accounts := api.Group("/accounts")
 	{
-		accounts.POST("", accountHandler.CreateAccount)                          // 创建账号
-		accounts.GET("", accountHandler.GetAccounts)                             // 获取账号列表
-		accounts.GET("/:id", accountHandler.GetAccount)                          // 获取账号详情
-		accounts.POST("/:id/update", accountHandler.UpdateAccount)               // 更新账号
-		accounts.POST("/:id/delete", accountHandler.DeleteAccount)               // 删除账号
-		accounts.GET("/:id/health", accountHandler.CheckAccountHealth)           // 检查健康度
-		accounts.GET("/:id/availability", accountHandler.GetAccountAvailability) // 获取可用性
-		accounts.POST("/:id/bind-proxy", accountHandler.BindProxy)               // 绑定代理
-		accounts.POST("/upload", accountHandler.UploadAccountFiles)              // 上传并解析账号文件
-		accounts.POST("/export", accountHandler.ExportAccounts)                  // 导出账号
+		accounts.POST("", accountHandler.CreateAccount)                            // 创建账号
+		accounts.GET("", accountHandler.GetAccounts)                               // 获取账号列表
+		accounts.GET("/:id", accountHandler.GetAccount)                            // 获取账号详情
+		accounts.POST("/:id/update", accountHandler.UpdateAccount)                 // 更新账号
+		accounts.POST("/:id/delete", accountHandler.DeleteAccount)                 // 删除账号
+		accounts.GET("/:id/health", accountHandler.CheckAccountHealth)             // 检查健康度
+		accounts.GET("/:id/availability", accountHandler.GetAccountAvailability)   // 获取可用性
+		accounts.POST("/:id/bind-proxy", accountHandler.BindProxy)                 // 绑定代理
+		accounts.POST("/:id/preview-recipients", accountHandler.PreviewRecipients) // 预览发送目标
+		accounts.POST("/:id/refresh-info", accountHandler.RefreshAccountInfo)      // 刷新账号资料
+		accounts.POST("/:id/proxy-test", accountHandler.TestAccountAcrossProxies)  // 多代理连通性测试
+		accounts.GET("/:id/conversation", accountHandler.GetConversation)          // 读取会话消息
+		accounts.GET("/:id/status-history", accountHandler.GetStatusHistory)       // 状态变更记录
+		accounts.GET("/:id/proxy-history", accountHandler.GetProxyHistory)         // 代理绑定变更记录
+		accounts.POST("/upload", accountHandler.UploadAccountFiles)                // 上传并解析账号文件
+		accounts.POST("/export", accountHandler.ExportAccounts)                    // 导出账号
 
 		// 批量操作
 		accounts.POST("/batch/bind-proxy", accountHandler.BatchBindProxy)  // 批量绑定/解绑代理
 		accounts.POST("/batch/set-2fa", accountHandler.BatchSet2FA)        // 批量设置2FA
 		accounts.POST("/batch/update-2fa", accountHandler.BatchUpdate2FA)  // 批量修改2FA
 		accounts.POST("/batch/delete", accountHandler.BatchDeleteAccounts) // 批量删除账号
+		accounts.POST("/batch/status", accountHandler.BatchUpdateStatus)   // 批量修改状态
 	}
 
 	// 模块功能路由（五大核心模块）- 需要基础权限
@@ -79,17 +88,18 @@ func RegisterAPIRoutes(
 	}
 
 	// AI服务路由
-	SetupAIRoutes(api, aiHandler, authService)
+	SetupAIRoutes(api, aiHandler, authService, redisClient, config.AI.RateLimit)
 
 	// 统计和监控路由（需要标准用户权限）
 	stats := api.Group("/stats")
 	stats.Use(middleware.RequirePermission("basic_features"))
 	{
-		stats.GET("/overview", statsHandler.GetOverview)       // 系统统计概览
-		stats.GET("/accounts", statsHandler.GetAccountStats)   // 账号统计详情
-		stats.GET("/dashboard", statsHandler.GetUserDashboard) // 用户仪表盘
-		stats.GET("/tasks", taskHandler.GetTaskStats)          // 任务统计
-		stats.GET("/proxies", proxyHandler.GetProxyStats)      // 代理统计
+		stats.GET("/overview", statsHandler.GetOverview)           // 系统统计概览
+		stats.GET("/accounts", statsHandler.GetAccountStats)       // 账号统计详情
+		stats.GET("/dashboard", statsHandler.GetUserDashboard)     // 用户仪表盘
+		stats.GET("/tasks", taskHandler.GetTaskStats)              // 任务统计
+		stats.GET("/tasks/by-type", statsHandler.GetTaskTypeStats) // 按任务类型统计
+		stats.GET("/proxies", proxyHandler.GetProxyStats)          // 代理统计
 	}
 
 	// 设置路由
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tg_cloud_server/internal/common/middleware"
+	"tg_cloud_server/internal/handlers"
+	"tg_cloud_server/internal/services"
+)
+
+// SetupBatchRoutes 设置批量任务相关路由
+func SetupBatchRoutes(router *gin.Engine, batchHandler *handlers.BatchHandler, authService *services.AuthService) {
+	batchGroup := router.Group("/api/v1/batch-jobs")
+	batchGroup.Use(middleware.JWTAuthMiddleware(authService))
+	{
+		batchGroup.GET("", batchHandler.GetBatchJobs)                // 获取批量任务列表
+		batchGroup.POST("/export", batchHandler.ExportData)          // 创建数据导出任务
+		batchGroup.GET("/:id", batchHandler.GetBatchJob)             // 获取批量任务详情
+		batchGroup.GET("/:id/download", batchHandler.DownloadExport) // 下载导出文件
+		batchGroup.POST("/:id/cancel", batchHandler.CancelBatchJob)  // 取消批量任务
+	}
+}
@@ -31,5 +31,6 @@ func SetupProxyRoutes(router *gin.Engine, proxyHandler *handlers.ProxyHandler, a
 		// 批量操作
 		proxyGroup.POST("/batch/delete", proxyHandler.BatchDeleteProxy) // 批量删除代理
 		proxyGroup.POST("/batch/test", proxyHandler.BatchTestProxy)     // 批量测试代理
+		proxyGroup.POST("/migrate", proxyHandler.MigrateProxy)          // 迁移账号到另一个代理
 	}
 }
@@ -23,9 +23,11 @@ func SetupTaskRoutes(router *gin.Engine, taskHandler *handlers.TaskHandler, auth
 		taskGroup.POST("/:id/cancel", taskHandler.CancelTask) // 取消任务
 
 		// 任务操作
-		taskGroup.POST("/:id/retry", taskHandler.RetryTask)     // 重试任务
-		taskGroup.POST("/:id/control", taskHandler.ControlTask) // 控制任务执行（启动、暂停、停止、恢复）
-		taskGroup.GET("/:id/logs", taskHandler.GetTaskLogs)     // 获取任务日志
+		taskGroup.POST("/:id/retry", taskHandler.RetryTask)          // 重试任务
+		taskGroup.POST("/:id/requeue", taskHandler.RequeueTask)      // 重新排队已结束的任务
+		taskGroup.POST("/:id/control", taskHandler.ControlTask)      // 控制任务执行（启动、暂停、停止、恢复）
+		taskGroup.GET("/:id/logs", taskHandler.GetTaskLogs)          // 获取任务日志
+		taskGroup.GET("/:id/report", taskHandler.DownloadTaskReport) // 下载任务报告
 
 		// 批量操作（需要高级用户权限）
 		taskGroup.POST("/batch/cancel", middleware.RequirePermission("advanced_features"), taskHandler.BatchCancel)        // 批量取消任务
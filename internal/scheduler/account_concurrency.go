@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"time"
+
+	"tg_cloud_server/internal/models"
+)
+
+// SetMaxConcurrentPerAccount 设置单个账号同时参与执行的任务数上限，全局并发仍受 maxConcurrent 限制，0 表示不限制
+// 任务有账号已达到上限时跳过该任务，先执行队列中后面可执行的任务
+func (ts *TaskScheduler) SetMaxConcurrentPerAccount(n int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.maxPerAccount = n
+}
+
+// nextEligibleIndex 返回队列中第一个已到计划执行时间、且所有账号都未达到并发上限的任务下标，没有时返回 -1，调用方需持有锁
+func (ts *TaskScheduler) nextEligibleIndex(now time.Time) int {
+	for i, task := range ts.taskQueue {
+		if isScheduledLater(task, now) {
+			continue
+		}
+		if ts.accountsWithinLimit(task) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isScheduledLater 任务的计划执行时间是否还未到
+func isScheduledLater(task *models.Task, now time.Time) bool {
+	return task.ScheduledAt != nil && task.ScheduledAt.After(now)
+}
+
+// accountsWithinLimit 任务的所有账号是否都未达到并发上限，执行后每个账号都会占用一个名额，
+// 因此只要有一个账号已满就不能执行；未设置上限或任务没有账号时视为可执行
+func (ts *TaskScheduler) accountsWithinLimit(task *models.Task) bool {
+	if ts.maxPerAccount <= 0 {
+		return true
+	}
+	accountIDs, _ := task.SplitExcludedAccounts()
+	for _, accountID := range accountIDs {
+		if ts.accountRunning[accountID] >= ts.maxPerAccount {
+			return false
+		}
+	}
+	return true
+}
+
+// acquireAccounts 记录任务占用的账号，返回的账号列表用于任务结束后释放，调用方需持有锁
+func (ts *TaskScheduler) acquireAccounts(task *models.Task) []uint64 {
+	accountIDs, _ := task.SplitExcludedAccounts()
+	for _, accountID := range accountIDs {
+		ts.accountRunning[accountID]++
+	}
+	return accountIDs
+}
+
+// releaseAccounts 释放任务占用的账号，调用方需持有锁
+func (ts *TaskScheduler) releaseAccounts(accountIDs []uint64) {
+	for _, accountID := range accountIDs {
+		if ts.accountRunning[accountID] <= 1 {
+			delete(ts.accountRunning, accountID)
+		} else {
+			ts.accountRunning[accountID]--
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"math/rand"
+	"time"
+
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/telegram"
+)
+
+// SetBroadcastStagger 设置多账号群发的错峰启动：第 i 个账号在任务开始 i*base 后启动，再叠加 [0, jitter) 的随机抖动
+// 任务配置 stagger_base_seconds / stagger_jitter_seconds 可覆盖，均为0表示不错峰
+func (ts *TaskScheduler) SetBroadcastStagger(base, jitter time.Duration) {
+	ts.staggerBase = base
+	ts.staggerJitter = jitter
+}
+
+// SetGroupSendLimiter 设置群发的单群每日发送次数限制，任务配置 max_messages_per_group_per_day 可覆盖默认上限
+func (ts *TaskScheduler) SetGroupSendLimiter(limiter *telegram.GroupSendLimiter) {
+	ts.groupSendLimiter = limiter
+}
+
+// SetJoinRetryPolicy 设置群发自动加群时邀请链接加群的间隔和 FLOOD_WAIT 重试策略
+func (ts *TaskScheduler) SetJoinRetryPolicy(policy telegram.JoinRetryPolicy) {
+	ts.joinRetryPolicy = &policy
+}
+
+// startOffsets 计算各账号相对任务开始时间的启动偏移，非群发任务或未启用错峰时返回 nil
+func (ts *TaskScheduler) startOffsets(task *models.Task, count int) []time.Duration {
+	if task.TaskType != models.TaskTypeBroadcast || count <= 1 {
+		return nil
+	}
+
+	base, jitter := ts.staggerBase, ts.staggerJitter
+	if v, ok := task.Config["stagger_base_seconds"].(float64); ok && v >= 0 {
+		base = time.Duration(v * float64(time.Second))
+	}
+	if v, ok := task.Config["stagger_jitter_seconds"].(float64); ok && v >= 0 {
+		jitter = time.Duration(v * float64(time.Second))
+	}
+	if base <= 0 && jitter <= 0 {
+		return nil
+	}
+
+	// 第一个账号立即开始，后续账号依次后移
+	offsets := make([]time.Duration, count)
+	for i := 1; i < count; i++ {
+		offsets[i] = time.Duration(i) * base
+		if jitter > 0 {
+			offsets[i] += time.Duration(rand.Int63n(int64(jitter)))
+		}
+	}
+	return offsets
+}
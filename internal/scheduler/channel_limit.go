@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// channelLimitRecheckAfter 账号达到频道数量上限后，加群任务跳过该账号的时长，之后重新尝试
+const channelLimitRecheckAfter = 7 * 24 * time.Hour
+
+// atChannelLimit 判断加群任务是否应跳过该账号（近期已达到频道数量上限）
+func atChannelLimit(task *models.Task, account *models.TGAccount) bool {
+	if task.TaskType != models.TaskTypeJoinGroup || account.ChannelLimitAt == nil {
+		return false
+	}
+	return time.Since(*account.ChannelLimitAt) < channelLimitRecheckAfter
+}
+
+// recordChannelLimit 保存账号的频道数量上限标记：本次达到上限时记录时间，
+// 加群任务未再触发上限时清除旧标记
+func (ts *TaskScheduler) recordChannelLimit(task *models.Task, account *models.TGAccount, limited bool) {
+	var reachedAt *time.Time
+	switch {
+	case limited:
+		now := time.Now()
+		reachedAt = &now
+	case task.TaskType == models.TaskTypeJoinGroup && account.ChannelLimitAt != nil:
+	default:
+		return
+	}
+	if err := ts.accountRepo.UpdateChannelLimit(account.ID, reachedAt); err != nil {
+		ts.logger.Error("Failed to update account channel limit",
+			zap.Uint64("account_id", account.ID),
+			zap.Bool("limited", limited),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// breakerState 熔断器状态
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 正常
+	breakerOpen                         // 熔断中，跳过账号
+	breakerHalfOpen                     // 半开，放行一个任务试探
+)
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // 窗口内连续失败次数阈值
+	Window           time.Duration // 统计窗口
+	Cooldown         time.Duration // 熔断时长
+}
+
+// accountBreaker 单个账号的熔断状态
+type accountBreaker struct {
+	state        breakerState
+	failures     []time.Time // 窗口内的连续失败时间
+	openedAt     time.Time
+	probeStarted time.Time // 半开状态下试探任务的开始时间
+}
+
+// CircuitBreaker 账号级熔断器
+// 账号在窗口内连续失败达到阈值后熔断，冷却结束进入半开状态放行一个任务，成功则恢复，失败则重新熔断
+type CircuitBreaker struct {
+	config   CircuitBreakerConfig
+	breakers map[uint64]*accountBreaker
+	mu       sync.Mutex
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.Window <= 0 {
+		config.Window = 30 * time.Minute
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = time.Hour
+	}
+	return &CircuitBreaker{
+		config:   config,
+		breakers: make(map[uint64]*accountBreaker),
+	}
+}
+
+// Allow 判断账号是否允许执行任务，不允许时返回原因
+func (cb *CircuitBreaker) Allow(accountID uint64) (bool, string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b, exists := cb.breakers[accountID]
+	if !exists {
+		return true, ""
+	}
+
+	now := time.Now()
+	switch b.state {
+	case breakerOpen:
+		remaining := b.openedAt.Add(cb.config.Cooldown).Sub(now)
+		if remaining > 0 {
+			return false, fmt.Sprintf("账号连续失败 %d 次已熔断，%s 后重试", cb.config.FailureThreshold, remaining.Round(time.Second))
+		}
+		b.state = breakerHalfOpen
+		b.probeStarted = now
+		return true, ""
+	case breakerHalfOpen:
+		// 试探任务未返回结果（例如在执行前被跳过）超过冷却时长，允许重新试探
+		if now.Sub(b.probeStarted) > cb.config.Cooldown {
+			b.probeStarted = now
+			return true, ""
+		}
+		return false, "账号熔断恢复中，正在等待试探任务结果"
+	default:
+		return true, ""
+	}
+}
+
+// RecordSuccess 记录成功，恢复账号
+func (cb *CircuitBreaker) RecordSuccess(accountID uint64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	delete(cb.breakers, accountID)
+}
+
+// RecordFailure 记录失败，返回账号是否因此熔断
+func (cb *CircuitBreaker) RecordFailure(accountID uint64) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	b, exists := cb.breakers[accountID]
+	if !exists {
+		b = &accountBreaker{}
+		cb.breakers[accountID] = b
+	}
+
+	// 半开状态试探失败，重新熔断
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = now
+		return true
+	}
+
+	// 丢弃窗口外的失败记录
+	cutoff := now.Add(-cb.config.Window)
+	kept := b.failures[:0]
+	for _, t := range b.failures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	b.failures = append(kept, now)
+
+	if len(b.failures) >= cb.config.FailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = now
+		b.failures = nil
+		return true
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// defaultResultSampleSize 摘要中保留的明细条数
+const defaultResultSampleSize = 20
+
+// SetResultRetention 设置任务结果的保存上限（JSON 字节数），超过上限时将最大的明细字段摘要为统计和样本，
+// 完整明细写入任务日志。byType 按任务类型覆盖默认上限，上限小于等于0表示不限制
+func (ts *TaskScheduler) SetResultRetention(defaultMaxBytes int, byType map[string]int, sampleSize int) {
+	ts.resultMaxBytes = defaultMaxBytes
+	ts.resultMaxBytesByType = byType
+	ts.resultSampleSize = sampleSize
+	if ts.resultSampleSize <= 0 {
+		ts.resultSampleSize = defaultResultSampleSize
+	}
+}
+
+// resultLimit 返回任务类型的结果大小上限
+func (ts *TaskScheduler) resultLimit(taskType models.TaskType) int {
+	if limit, ok := ts.resultMaxBytesByType[string(taskType)]; ok {
+		return limit
+	}
+	return ts.resultMaxBytes
+}
+
+// compactTaskResult 结果超过上限时，依次将最大的明细字段替换为摘要，直到满足上限或没有可摘要的字段
+func (ts *TaskScheduler) compactTaskResult(task *models.Task) {
+	limit := ts.resultLimit(task.TaskType)
+	if limit <= 0 || task.Result == nil {
+		return
+	}
+
+	size := resultSize(task.Result)
+	if size <= limit {
+		return
+	}
+	originalSize := size
+
+	var summarized []string
+	for size > limit {
+		path, value := ts.largestDetail(task.Result)
+		if path == nil {
+			break
+		}
+		field := strings.Join(path, ".")
+
+		// 完整明细写入任务日志，结果中只保留摘要
+		ts.createTaskLog(task.ID, nil, "result_detail", fmt.Sprintf("结果字段 %s 过大，已在任务结果中摘要，完整明细见本日志", field), map[string]interface{}{
+			"field":  field,
+			"detail": value,
+		})
+		setResultPath(task.Result, path, ts.summarizeDetail(value))
+		summarized = append(summarized, field)
+		size = resultSize(task.Result)
+	}
+
+	if len(summarized) > 0 {
+		task.Result["result_summarized"] = summarized
+		ts.logger.Info("Task result summarized",
+			zap.Uint64("task_id", task.ID),
+			zap.String("task_type", string(task.TaskType)),
+			zap.Int("original_bytes", originalSize),
+			zap.Int("compacted_bytes", size),
+			zap.Int("limit_bytes", limit),
+			zap.Strings("fields", summarized))
+	}
+}
+
+// maxResultDetailDepth 查找可摘要字段的最大层级（如 account_results.<账号>.failed_groups）
+const maxResultDetailDepth = 3
+
+// largestDetail 查找结果中最大的可摘要字段（条目数超过样本数的 map/数组）
+func (ts *TaskScheduler) largestDetail(result models.TaskResult) ([]string, interface{}) {
+	var bestPath []string
+	var bestValue interface{}
+	bestSize := 0
+
+	var walk func(path []string, value interface{})
+	walk = func(path []string, value interface{}) {
+		if ts.summarizable(value) {
+			if size := resultSize(value); size > bestSize {
+				bestPath, bestValue, bestSize = append([]string(nil), path...), value, size
+			}
+		}
+		nested, ok := value.(map[string]interface{})
+		if !ok || isSummary(nested) || len(path) >= maxResultDetailDepth {
+			return
+		}
+		for key, sub := range nested {
+			walk(append(path, key), sub)
+		}
+	}
+
+	for key, value := range result {
+		walk([]string{key}, value)
+	}
+	return bestPath, bestValue
+}
+
+// summarizable 判断字段是否为可摘要的明细
+func (ts *TaskScheduler) summarizable(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return !isSummary(v) && len(v) > ts.resultSampleSize
+	case []interface{}:
+		return len(v) > ts.resultSampleSize
+	case []string:
+		return len(v) > ts.resultSampleSize
+	}
+	return false
+}
+
+// summarizeDetail 生成明细摘要：条目数、按状态统计，以及优先包含失败条目的样本
+func (ts *TaskScheduler) summarizeDetail(value interface{}) map[string]interface{} {
+	summary := map[string]interface{}{"summarized": true}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		// 失败条目优先进入样本
+		sort.SliceStable(keys, func(i, j int) bool {
+			return isFailureEntry(v[keys[i]]) && !isFailureEntry(v[keys[j]])
+		})
+
+		statusCounts := make(map[string]int)
+		failures := 0
+		for _, k := range keys {
+			if status := entryStatus(v[k]); status != "" {
+				statusCounts[status]++
+			}
+			if isFailureEntry(v[k]) {
+				failures++
+			}
+		}
+
+		sample := make(map[string]interface{})
+		for _, k := range keys[:ts.resultSampleSize] {
+			sample[k] = v[k]
+		}
+		summary["count"] = len(v)
+		summary["failure_count"] = failures
+		if len(statusCounts) > 0 {
+			summary["by_status"] = statusCounts
+		}
+		summary["sample"] = sample
+	case []interface{}:
+		summary["count"] = len(v)
+		summary["sample"] = v[:ts.resultSampleSize]
+	case []string:
+		summary["count"] = len(v)
+		summary["sample"] = v[:ts.resultSampleSize]
+	}
+	return summary
+}
+
+// isSummary 判断字段是否已被摘要
+func isSummary(value map[string]interface{}) bool {
+	summarized, _ := value["summarized"].(bool)
+	return summarized
+}
+
+// isFailureEntry 判断明细条目是否表示失败（包含错误信息、success 为 false 或状态为失败）
+func isFailureEntry(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		// 失败原因映射（如 failed_groups）的值为错误描述
+		return v != ""
+	case map[string]interface{}:
+		if errMsg, ok := v["error"].(string); ok && errMsg != "" {
+			return true
+		}
+		if success, ok := v["success"].(bool); ok && !success {
+			return true
+		}
+		if status, ok := v["status"].(string); ok && status == "failed" {
+			return true
+		}
+	}
+	return false
+}
+
+// entryStatus 返回明细条目的状态，用于按状态统计
+func entryStatus(value interface{}) string {
+	v, ok := value.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if status, ok := v["status"].(string); ok {
+		return status
+	}
+	if success, ok := v["success"].(bool); ok {
+		if success {
+			return "success"
+		}
+		return "failed"
+	}
+	return ""
+}
+
+// setResultPath 按路径替换结果字段
+func setResultPath(result models.TaskResult, path []string, value interface{}) {
+	current := map[string]interface{}(result)
+	for _, key := range path[:len(path)-1] {
+		nested, ok := current[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = nested
+	}
+	current[path[len(path)-1]] = value
+}
+
+// resultSize 结果序列化后的字节数
+func resultSize(value interface{}) int {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
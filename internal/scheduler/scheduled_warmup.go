@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SetScheduledWarmUp 设置定时任务的连接预热提前量：计划执行时间在 lead 之内的任务提前为其账号建立连接，
+// 到点执行时无需再等待建连，0 表示不预热
+func (ts *TaskScheduler) SetScheduledWarmUp(lead time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.warmUpLead = lead
+}
+
+// warmUpScheduledTasks 为即将到达计划执行时间的排队任务预热账号连接，每个任务只预热一次
+func (ts *TaskScheduler) warmUpScheduledTasks() {
+	ts.mu.Lock()
+	lead := ts.warmUpLead
+	if lead <= 0 || ts.connectionPool == nil {
+		ts.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	queued := make(map[uint64]bool, len(ts.taskQueue))
+	due := make(map[uint64][]uint64)
+	for _, task := range ts.taskQueue {
+		queued[task.ID] = true
+		if ts.warmedUp[task.ID] || !isScheduledLater(task, now) || task.ScheduledAt.Sub(now) > lead {
+			continue
+		}
+		ts.warmedUp[task.ID] = true
+		if accountIDs, _ := task.SplitExcludedAccounts(); len(accountIDs) > 0 {
+			due[task.ID] = accountIDs
+		}
+	}
+	// 已出队或已取消的任务不再需要记录
+	for taskID := range ts.warmedUp {
+		if !queued[taskID] {
+			delete(ts.warmedUp, taskID)
+		}
+	}
+	ts.mu.Unlock()
+
+	for taskID, accountIDs := range due {
+		go ts.warmUpTaskAccounts(taskID, accountIDs, lead)
+	}
+}
+
+// warmUpTaskAccounts 为任务的账号建立连接，最多等待 lead，失败只记录日志，执行时仍会按正常流程建连
+func (ts *TaskScheduler) warmUpTaskAccounts(taskID uint64, accountIDs []uint64, lead time.Duration) {
+	ctx, cancel := context.WithTimeout(ts.ctx, lead)
+	defer cancel()
+
+	results := ts.connectionPool.WarmUp(ctx, accountIDs)
+	failed := 0
+	for accountID, err := range results {
+		if err != nil {
+			failed++
+			ts.logger.Debug("Failed to warm up account for scheduled task",
+				zap.Uint64("task_id", taskID),
+				zap.Uint64("account_id", accountID),
+				zap.Error(err))
+		}
+	}
+	ts.logger.Info("Scheduled task accounts warmed up",
+		zap.Uint64("task_id", taskID),
+		zap.Int("accounts", len(results)),
+		zap.Int("failed", failed))
+}
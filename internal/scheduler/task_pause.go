@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/telegram"
+)
+
+// resumeAccountIndexKey 暂停时记录的下一个待执行账号下标，恢复时从该账号继续
+const resumeAccountIndexKey = "resume_account_index"
+
+// PauseTask 暂停任务：排队中的任务直接移出队列；执行中的任务不再启动后续账号，群发在当前群组发送后停止，
+// 进度（next_group_index、未发送的群组、下一个账号）保留在任务结果中，由 ResumeTask 继续
+func (ts *TaskScheduler) PauseTask(taskID uint64) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	for i, task := range ts.taskQueue {
+		if task.ID == taskID {
+			ts.taskQueue = append(ts.taskQueue[:i], ts.taskQueue[i+1:]...)
+			delete(ts.enqueuedAt, taskID)
+			task.Status = models.TaskStatusPaused
+			if err := ts.taskRepo.UpdateStatus(taskID, models.TaskStatusPaused); err != nil {
+				ts.logger.Error("Failed to update paused task status",
+					zap.Uint64("task_id", taskID),
+					zap.Error(err))
+			}
+			ts.pausedTasks[taskID] = task
+			ts.logger.Info("Queued task paused", zap.Uint64("task_id", taskID))
+			return true
+		}
+	}
+
+	if cancelFunc, running := ts.taskCancels[taskID]; running {
+		ts.pausing[taskID] = true
+		cancelFunc()
+		ts.logger.Info("Task pause signal sent", zap.Uint64("task_id", taskID))
+		return true
+	}
+
+	ts.logger.Warn("Task not found in queue or running tasks for pause",
+		zap.Uint64("task_id", taskID))
+	return false
+}
+
+// ResumeTask 恢复已暂停的任务，重新排队后从暂停时的进度继续执行
+func (ts *TaskScheduler) ResumeTask(taskID uint64) error {
+	ts.mu.Lock()
+	task, ok := ts.pausedTasks[taskID]
+	delete(ts.pausedTasks, taskID)
+	ts.mu.Unlock()
+
+	// 服务重启后内存中没有暂停的任务，从数据库加载
+	if !ok {
+		var err error
+		task, err = ts.taskRepo.GetByID(taskID)
+		if err != nil {
+			return fmt.Errorf("failed to get task: %w", err)
+		}
+	}
+	if task.Status != models.TaskStatusPaused {
+		return fmt.Errorf("task status %s cannot be resumed", task.Status)
+	}
+
+	ts.createTaskLog(taskID, nil, "task_resumed", "任务已恢复，将从暂停时的进度继续执行", nil)
+	return ts.SubmitTask(task)
+}
+
+// isPausing 执行中的任务是否收到了暂停信号
+func (ts *TaskScheduler) isPausing(taskID uint64) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.pausing[taskID]
+}
+
+// markTaskPaused 执行中的任务响应暂停：记录下一个待执行的账号并保存进度，状态置为已暂停
+func (ts *TaskScheduler) markTaskPaused(task *models.Task, nextAccountIndex, totalAccounts int) {
+	task.Result[resumeAccountIndexKey] = nextAccountIndex
+	task.Status = models.TaskStatusPaused
+
+	if err := ts.taskRepo.UpdateTask(task.ID, map[string]interface{}{
+		"status": models.TaskStatusPaused,
+		"result": task.Result,
+	}); err != nil {
+		ts.logger.Error("Failed to update paused task",
+			zap.Uint64("task_id", task.ID),
+			zap.Error(err))
+	}
+
+	ts.mu.Lock()
+	delete(ts.pausing, task.ID)
+	ts.pausedTasks[task.ID] = task
+	ts.mu.Unlock()
+
+	ts.logger.Info("Task paused",
+		zap.Uint64("task_id", task.ID),
+		zap.Int("next_account_index", nextAccountIndex),
+		zap.Int("total_accounts", totalAccounts))
+	ts.createTaskLog(task.ID, nil, "task_paused", fmt.Sprintf("任务已暂停，已处理 %d/%d 个账号", nextAccountIndex, totalAccounts), nil)
+}
+
+// takeResumeAccountIndex 取出暂停时记录的账号下标，非恢复执行时返回 0
+func takeResumeAccountIndex(task *models.Task) int {
+	index := 0
+	switch v := task.Result[resumeAccountIndexKey].(type) {
+	case int:
+		index = v
+	case float64:
+		index = int(v)
+	}
+	delete(task.Result, resumeAccountIndexKey)
+	return index
+}
+
+// pausedAccountIndex 暂停后恢复执行的账号下标：上一个账号的群发被中途暂停、仍有未发送的群组时重新执行该账号
+func pausedAccountIndex(task *models.Task, next int) int {
+	if next > 0 && telegram.HasPausedGroups(task) {
+		return next - 1
+	}
+	return next
+}
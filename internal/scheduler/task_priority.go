@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"tg_cloud_server/internal/models"
+)
+
+// SetPriorityAging 设置排队任务的优先级老化：每排队 interval 优先级加 1，避免低优先级任务被持续插队而饿死，0 表示不老化
+func (ts *TaskScheduler) SetPriorityAging(interval time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.priorityAging = interval
+}
+
+// effectivePriority 计算任务当前的有效优先级：任务优先级加上排队时间带来的老化加成
+// 定时任务从计划执行时间开始计算排队时间
+func (ts *TaskScheduler) effectivePriority(task *models.Task, now time.Time) int {
+	priority := task.Priority
+	if ts.priorityAging > 0 {
+		if enqueuedAt, ok := ts.enqueuedAt[task.ID]; ok {
+			if task.ScheduledAt != nil && task.ScheduledAt.After(enqueuedAt) {
+				enqueuedAt = *task.ScheduledAt
+			}
+			if waited := now.Sub(enqueuedAt); waited > 0 {
+				priority += int(waited / ts.priorityAging)
+			}
+		}
+	}
+	return priority
+}
+
+// sortQueue 按有效优先级从高到低排序队列，优先级相同时先提交的在前，调用方需持有写锁
+func (ts *TaskScheduler) sortQueue(now time.Time) {
+	sort.SliceStable(ts.taskQueue, func(i, j int) bool {
+		pi := ts.effectivePriority(ts.taskQueue[i], now)
+		pj := ts.effectivePriority(ts.taskQueue[j], now)
+		if pi != pj {
+			return pi > pj
+		}
+		return ts.enqueuedAt[ts.taskQueue[i].ID].Before(ts.enqueuedAt[ts.taskQueue[j].ID])
+	})
+}
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/telegram"
+)
+
+// SetTaskRetry 设置所有账号均执行失败时整个任务的自动重试次数和重试间隔，maxRetries 为0表示不重试
+func (ts *TaskScheduler) SetTaskRetry(maxRetries int, delay time.Duration) {
+	ts.taskRetryMax = maxRetries
+	ts.taskRetryDelay = delay
+}
+
+// recordTaskAttempt 记录一次整任务执行的结果
+func (ts *TaskScheduler) recordTaskAttempt(task *models.Task, startTime time.Time, successCount, failCount int, taskErr error) {
+	attempts, _ := task.Result["attempts"].([]interface{})
+	attempt := map[string]interface{}{
+		"attempt":       len(attempts) + 1,
+		"started_at":    startTime.Unix(),
+		"finished_at":   time.Now().Unix(),
+		"success_count": successCount,
+		"fail_count":    failCount,
+	}
+	if taskErr != nil {
+		attempt["error"] = taskErr.Error()
+	}
+	task.Result["attempts"] = append(attempts, attempt)
+}
+
+// transientErrorMarkers 错误信息包含这些片段时视为短暂故障（网络、代理、服务端内部错误、限流）
+var transientErrorMarkers = []string{
+	"TIMEOUT", "DEADLINE EXCEEDED", "CONNECTION RESET", "CONNECTION REFUSED", "BROKEN PIPE",
+	"NO SUCH HOST", "NETWORK IS UNREACHABLE", "EOF", "SOCKS",
+	"RPC_CALL_FAIL", "RPC_MCGET_FAIL", "INTERNAL", "WORKER_BUSY",
+	"CONNECTION FAILED", "ENGINE WAS CLOSED", "NOT CONNECTED",
+}
+
+// isTransientTaskError 判断任务失败是否由短暂故障引起，只有这类失败整体重试才有意义
+func isTransientTaskError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := telegram.FloodWaitDuration(err); ok {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if rpcErr, ok := tgerr.As(err); ok && rpcErr.Code >= 500 {
+		return true
+	}
+
+	errStr := strings.ToUpper(err.Error())
+	for _, marker := range transientErrorMarkers {
+		if strings.Contains(errStr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryWholeTask 在重试次数内等待后重新执行整个任务，返回 false 表示不再重试、由调用方标记失败
+// 只有短暂故障才重试；等待期间任务仍占用运行槽位，可被 StopTask 取消或被暂停
+func (ts *TaskScheduler) retryWholeTask(ctx context.Context, task *models.Task, taskErr error) bool {
+	attempts, _ := task.Result["attempts"].([]interface{})
+	if ts.taskRetryMax <= 0 || len(attempts) > ts.taskRetryMax || ctx.Err() != nil {
+		return false
+	}
+	if !isTransientTaskError(taskErr) {
+		ts.logger.Info("Task failure is not transient, skipping whole task retry",
+			zap.Uint64("task_id", task.ID),
+			zap.Error(taskErr))
+		return false
+	}
+
+	retry := len(attempts)
+	// 因 FLOOD_WAIT 失败时至少等待服务端要求的时间再重试
+	delay := ts.taskRetryDelay
+	if wait, ok := telegram.FloodWaitDuration(taskErr); ok && wait > delay {
+		delay = wait
+	}
+	ts.logger.Warn("All accounts failed, scheduling whole task retry",
+		zap.Uint64("task_id", task.ID),
+		zap.Int("retry", retry),
+		zap.Int("max_retries", ts.taskRetryMax),
+		zap.Duration("delay", delay),
+		zap.Error(taskErr))
+	ts.createTaskLog(task.ID, nil, "task_retry_scheduled",
+		fmt.Sprintf("所有账号均执行失败，%s 后进行第 %d/%d 次整体重试: %v", delay, retry, ts.taskRetryMax, taskErr),
+		map[string]interface{}{"retry": retry, "max_retries": ts.taskRetryMax, "delay_seconds": int(delay.Seconds()), "error": taskErr.Error()})
+
+	task.Status = models.TaskStatusQueued
+	if err := ts.taskRepo.UpdateTask(task.ID, map[string]interface{}{
+		"status": models.TaskStatusQueued,
+		"result": task.Result,
+	}); err != nil {
+		ts.logger.Error("Failed to update task status for retry",
+			zap.Uint64("task_id", task.ID),
+			zap.Error(err))
+	}
+
+	select {
+	case <-ctx.Done():
+		ts.stopRetryWait(task)
+		return true
+	case <-time.After(delay):
+	}
+
+	// 重置执行进度，仅保留历次尝试记录
+	task.Result = models.TaskResult{"attempts": task.Result["attempts"]}
+	ts.executeTaskWithContext(ctx, task)
+	return true
+}
+
+// stopRetryWait 等待整体重试期间任务被暂停或取消：暂停时从第一个账号恢复，否则置为已取消，不停留在 queued 状态
+func (ts *TaskScheduler) stopRetryWait(task *models.Task) {
+	task.Result = models.TaskResult{"attempts": task.Result["attempts"]}
+	if ts.isPausing(task.ID) {
+		ts.markTaskPaused(task, 0, len(ts.resolveTaskAccounts(task)))
+		return
+	}
+
+	now := time.Now()
+	task.Status = models.TaskStatusCancelled
+	task.CompletedAt = &now
+	if err := ts.taskRepo.UpdateTask(task.ID, map[string]interface{}{
+		"status":       models.TaskStatusCancelled,
+		"completed_at": now,
+		"result":       task.Result,
+	}); err != nil {
+		ts.logger.Error("Failed to update task cancelled during retry wait",
+			zap.Uint64("task_id", task.ID),
+			zap.Error(err))
+	}
+	ts.createTaskLog(task.ID, nil, "task_cancelled", "任务在等待整体重试期间被取消", nil)
+}
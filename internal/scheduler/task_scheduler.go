@@ -21,20 +21,45 @@ import (
 
 // TaskScheduler 任务调度器
 type TaskScheduler struct {
-	taskQueue          []*models.Task                // 任务队列
-	runningTasks       map[uint64]bool               // 正在运行的任务 (taskID -> true)
-	taskCancels        map[uint64]context.CancelFunc // 任务取消函数 (taskID -> cancelFunc)
-	connectionPool     *telegram.ConnectionPool      // 连接池引用
-	accountRepo        repository.AccountRepository  // 账号仓库
-	taskRepo           repository.TaskRepository     // 任务仓库
-	aiService          services.AIService            // AI服务
-	riskControlService services.RiskControlService   // 风控服务
-	taskLogService     services.TaskLogService       // 任务日志服务
-	logger             *zap.Logger
-	mu                 sync.RWMutex
-	ctx                context.Context
-	cancel             context.CancelFunc
-	maxConcurrent      int // 最大并发任务数
+	taskQueue            []*models.Task                   // 任务队列，按有效优先级排序
+	enqueuedAt           map[uint64]time.Time             // 任务入队时间，用于同优先级排序和优先级老化
+	priorityAging        time.Duration                    // 排队每满该时长优先级加 1，0 表示不老化
+	runningTasks         map[uint64]bool                  // 正在运行的任务 (taskID -> true)
+	taskCancels          map[uint64]context.CancelFunc    // 任务取消函数 (taskID -> cancelFunc)
+	connectionPool       *telegram.ConnectionPool         // 连接池引用
+	accountRepo          repository.AccountRepository     // 账号仓库
+	taskRepo             repository.TaskRepository        // 任务仓库
+	aiService            services.AIService               // AI服务
+	riskControlService   services.RiskControlService      // 风控服务
+	taskLogService       services.TaskLogService          // 任务日志服务
+	circuitBreaker       *CircuitBreaker                  // 账号熔断器，nil 表示禁用
+	triggerQueueSize     int                              // 场景任务消息触发队列容量
+	messageCache         *telegram.MessageCache           // 场景任务共享的消息缓存
+	maxDecisions         int                              // 单个场景同时进行的智能体决策上限
+	decisionQueueWait    time.Duration                    // 决策名额已满时触发的最长排队时间
+	agentMemoryRepo      repository.AgentMemoryRepository // 智能体记忆仓库，nil 表示禁用
+	memoryMaxChars       int                              // 智能体记忆摘要最大字符数
+	memoryTTL            time.Duration                    // 智能体记忆有效期
+	taskRetryMax         int                              // 所有账号均失败时整个任务的最大重试次数
+	taskRetryDelay       time.Duration                    // 整体重试前的等待时间
+	resultMaxBytes       int                              // 任务结果保存上限（字节），超过时摘要明细字段
+	resultMaxBytesByType map[string]int                   // 按任务类型覆盖的结果保存上限
+	resultSampleSize     int                              // 摘要中保留的明细条数
+	staggerBase          time.Duration                    // 多账号群发时相邻账号的启动间隔
+	staggerJitter        time.Duration                    // 每个账号启动偏移的随机抖动上限
+	groupSendLimiter     *telegram.GroupSendLimiter       // 群发时单账号每日同一群组发送次数限制
+	joinRetryPolicy      *telegram.JoinRetryPolicy        // 群发自动加群的邀请链接限流处理，nil 时使用默认值
+	logger               *zap.Logger
+	mu                   sync.RWMutex
+	ctx                  context.Context
+	cancel               context.CancelFunc
+	maxConcurrent        int                     // 最大并发任务数
+	maxPerAccount        int                     // 单个账号同时参与的任务数上限，0 表示不限制
+	accountRunning       map[uint64]int          // 各账号正在参与执行的任务数
+	pausing              map[uint64]bool         // 已发出暂停信号、尚未停下的执行中任务
+	pausedTasks          map[uint64]*models.Task // 已暂停的任务，恢复时重新排队
+	warmUpLead           time.Duration           // 定时任务提前预热账号连接的时间，0 表示不预热
+	warmedUp             map[uint64]bool         // 已预热过账号连接的排队任务
 }
 
 // NewTaskScheduler 创建新的任务调度器
@@ -49,6 +74,11 @@ func NewTaskScheduler(
 
 	ts := &TaskScheduler{
 		taskQueue:      make([]*models.Task, 0),
+		enqueuedAt:     make(map[uint64]time.Time),
+		accountRunning: make(map[uint64]int),
+		pausing:        make(map[uint64]bool),
+		pausedTasks:    make(map[uint64]*models.Task),
+		warmedUp:       make(map[uint64]bool),
 		runningTasks:   make(map[uint64]bool),
 		taskCancels:    make(map[uint64]context.CancelFunc),
 		connectionPool: connectionPool,
@@ -73,6 +103,34 @@ func (ts *TaskScheduler) SetRiskControlService(riskControlService services.RiskC
 	ts.riskControlService = riskControlService
 }
 
+// SetCircuitBreaker 设置账号熔断器
+func (ts *TaskScheduler) SetCircuitBreaker(circuitBreaker *CircuitBreaker) {
+	ts.circuitBreaker = circuitBreaker
+}
+
+// SetScenarioTriggerQueueSize 设置场景任务的消息触发队列容量
+func (ts *TaskScheduler) SetScenarioTriggerQueueSize(size int) {
+	ts.triggerQueueSize = size
+}
+
+// SetScenarioDecisionConcurrency 设置单个场景的智能体决策并发上限及排队等待时间
+func (ts *TaskScheduler) SetScenarioDecisionConcurrency(max int, wait time.Duration) {
+	ts.maxDecisions = max
+	ts.decisionQueueWait = wait
+}
+
+// SetScenarioMessageCache 设置场景任务共享的消息缓存
+func (ts *TaskScheduler) SetScenarioMessageCache(cache *telegram.MessageCache) {
+	ts.messageCache = cache
+}
+
+// SetAgentMemory 设置场景任务的智能体记忆存储及其大小、有效期
+func (ts *TaskScheduler) SetAgentMemory(repo repository.AgentMemoryRepository, maxChars int, ttl time.Duration) {
+	ts.agentMemoryRepo = repo
+	ts.memoryMaxChars = maxChars
+	ts.memoryTTL = ttl
+}
+
 // Stop 停止任务调度器
 func (ts *TaskScheduler) Stop() {
 	ts.logger.Info("Stopping task scheduler...")
@@ -107,10 +165,17 @@ func (ts *TaskScheduler) StopTask(taskID uint64) bool {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
+	// 已暂停的任务不在队列中，停止后不再恢复
+	if _, paused := ts.pausedTasks[taskID]; paused {
+		delete(ts.pausedTasks, taskID)
+		return true
+	}
+
 	// 1. 尝试从队列中移除
 	for i, task := range ts.taskQueue {
 		if task.ID == taskID {
 			ts.taskQueue = append(ts.taskQueue[:i], ts.taskQueue[i+1:]...)
+			delete(ts.enqueuedAt, taskID)
 			ts.logger.Info("Task removed from queue",
 				zap.Uint64("task_id", taskID))
 			return true
@@ -139,7 +204,7 @@ func (ts *TaskScheduler) SubmitTask(task *models.Task) error {
 	}
 
 	// 验证任务有账号
-	accountIDs := task.GetAccountIDList()
+	accountIDs := ts.resolveTaskAccounts(task)
 	if len(accountIDs) == 0 {
 		return fmt.Errorf("task has no accounts assigned")
 	}
@@ -164,10 +229,13 @@ func (ts *TaskScheduler) SubmitTask(task *models.Task) error {
 		return fmt.Errorf("failed to update task status: %w", err)
 	}
 
-	// 添加任务到队列
+	// 添加任务到队列，按优先级重新排序
 	ts.mu.Lock()
 	task.Status = models.TaskStatusQueued
+	now := time.Now()
+	ts.enqueuedAt[task.ID] = now
 	ts.taskQueue = append(ts.taskQueue, task)
+	ts.sortQueue(now)
 	queueSize := len(ts.taskQueue)
 	ts.mu.Unlock()
 
@@ -179,11 +247,24 @@ func (ts *TaskScheduler) SubmitTask(task *models.Task) error {
 		zap.String("task_type", string(task.TaskType)),
 		zap.Int("priority", task.Priority),
 		zap.Int("queue_size", queueSize),
+		zap.Any("scheduled_at", task.ScheduledAt),
 		zap.Time("submitted_at", time.Now()))
 
 	return nil
 }
 
+// resolveTaskAccounts 获取任务的执行账号，去除 exclude_account_ids 中的账号并记录日志
+func (ts *TaskScheduler) resolveTaskAccounts(task *models.Task) []uint64 {
+	accountIDs, excluded := task.SplitExcludedAccounts()
+	if len(excluded) > 0 {
+		ts.logger.Info("Accounts excluded from task",
+			zap.Uint64("task_id", task.ID),
+			zap.Any("excluded_account_ids", excluded),
+			zap.Int("remaining_count", len(accountIDs)))
+	}
+	return accountIDs
+}
+
 // ValidateAccount 验证账号可用性
 func (ts *TaskScheduler) ValidateAccount(accountID string) error {
 	// 从缓存或数据库获取账号信息
@@ -264,9 +345,22 @@ func (ts *TaskScheduler) ValidateAccountForTask(accountID string, taskType model
 	}
 
 	if account.Status == models.AccountStatusCooling {
-		result.IsValid = false
-		result.Errors = append(result.Errors, "账号处于冷却期，暂时无法执行任务")
-		return result, nil
+		// 冷却已到期、等待定时任务恢复状态时不阻止执行
+		if account.CoolingUntil != nil && !account.CoolingUntil.After(time.Now()) {
+			result.Warnings = append(result.Warnings, "账号冷却已结束，状态将自动恢复")
+		} else {
+			result.IsValid = false
+			if account.CoolingUntil != nil {
+				remaining := time.Until(*account.CoolingUntil)
+				result.CoolingUntil = account.CoolingUntil
+				result.CooldownRemainingSeconds = int64(remaining.Seconds())
+				result.Errors = append(result.Errors, fmt.Sprintf("账号处于冷却期，剩余 %s，%s 后可重试",
+					remaining.Round(time.Second), account.CoolingUntil.Format("2006-01-02 15:04:05")))
+			} else {
+				result.Errors = append(result.Errors, "账号处于冷却期，暂时无法执行任务")
+			}
+			return result, nil
+		}
 	}
 
 	if account.Status == models.AccountStatusRestricted {
@@ -297,6 +391,7 @@ func (ts *TaskScheduler) schedulingLoop() {
 		case <-ts.ctx.Done():
 			return
 		case <-ticker.C:
+			ts.warmUpScheduledTasks()
 			ts.processQueues()
 		}
 	}
@@ -318,13 +413,24 @@ func (ts *TaskScheduler) processQueues() {
 		return
 	}
 
-	// 获取下一个任务（按优先级排序，优先级高的先执行）
-	// 简单实现：取第一个任务
-	task := ts.taskQueue[0]
-	ts.taskQueue = ts.taskQueue[1:]
+	// 取有效优先级最高的任务，排队较久的任务通过老化加成逐步提前
+	// 未到计划执行时间或账号都已达到并发上限的任务留在队列中，不阻塞其他任务
+	now := time.Now()
+	ts.sortQueue(now)
+	index := ts.nextEligibleIndex(now)
+	if index < 0 {
+		ts.mu.Unlock()
+		return
+	}
+	task := ts.taskQueue[index]
+	ts.taskQueue = append(ts.taskQueue[:index], ts.taskQueue[index+1:]...)
+	effectivePriority := ts.effectivePriority(task, now)
+	waited := now.Sub(ts.enqueuedAt[task.ID])
+	delete(ts.enqueuedAt, task.ID)
 
 	// 标记任务为运行中
 	ts.runningTasks[task.ID] = true
+	acquiredAccounts := ts.acquireAccounts(task)
 	runningCount := len(ts.runningTasks)
 	queueSize := len(ts.taskQueue)
 
@@ -334,6 +440,8 @@ func (ts *TaskScheduler) processQueues() {
 		zap.Uint64("task_id", task.ID),
 		zap.String("task_type", string(task.TaskType)),
 		zap.Int("priority", task.Priority),
+		zap.Int("effective_priority", effectivePriority),
+		zap.Duration("queued_for", waited),
 		zap.Int("running_tasks", runningCount),
 		zap.Int("remaining_queue_size", queueSize))
 
@@ -352,6 +460,8 @@ func (ts *TaskScheduler) processQueues() {
 			ts.mu.Lock()
 			delete(ts.runningTasks, task.ID)
 			delete(ts.taskCancels, task.ID)
+			delete(ts.pausing, task.ID)
+			ts.releaseAccounts(acquiredAccounts)
 			ts.mu.Unlock()
 
 			// 处理panic
@@ -378,7 +488,7 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 	}
 
 	// 获取账号ID列表
-	accountIDs := task.GetAccountIDList()
+	accountIDs := ts.resolveTaskAccounts(task)
 
 	// 更新任务状态为运行中
 	task.Status = models.TaskStatusRunning
@@ -406,21 +516,65 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 	if task.Result == nil {
 		task.Result = make(models.TaskResult)
 	}
-	task.Result["account_results"] = make(map[string]interface{})
-	accountResults := task.Result["account_results"].(map[string]interface{})
+	// 从暂停恢复时跳过已处理的账号，并保留它们的执行结果
+	resumeFrom := takeResumeAccountIndex(task)
+	accountResults, _ := task.Result["account_results"].(map[string]interface{})
+	if resumeFrom == 0 || accountResults == nil {
+		accountResults = make(map[string]interface{})
+	}
+	task.Result["account_results"] = accountResults
 
 	// 依次使用每个账号执行任务
 	successCount := 0
 	failCount := 0
 	var lastError error
 
+	// stop_on_error: 任一账号失败即停止处理剩余账号，默认继续执行所有账号
+	stopOnError, _ := task.Config["stop_on_error"].(bool)
+	var stoppedByAccount string
+	stoppedRemaining := 0
+
+	// 多账号群发错峰启动，避免所有账号同时开始发送
+	offsets := ts.startOffsets(task, len(accountIDs))
+
 	// 记录任务开始日志
 	ts.createTaskLog(task.ID, nil, "task_started", fmt.Sprintf("任务开始执行，共 %d 个账号待处理", len(accountIDs)), nil)
 
 	for i, accountID := range accountIDs {
-		// 检查任务是否被取消
+		if i < resumeFrom {
+			continue
+		}
+
+		// 上一个账号失败且开启了 stop_on_error，剩余账号全部跳过
+		if stopOnError && failCount > 0 {
+			stoppedByAccount = fmt.Sprintf("%d", accountIDs[i-1])
+			stoppedRemaining = len(accountIDs) - i
+			for _, remaining := range accountIDs[i:] {
+				accountResults[fmt.Sprintf("%d", remaining)] = map[string]interface{}{
+					"status": "skipped",
+					"reason": fmt.Sprintf("账号 %s 执行失败，已停止处理剩余账号 (stop_on_error)", stoppedByAccount),
+				}
+			}
+			break
+		}
+
+		// 未到该账号的启动时间则等待，前面的账号已耗时更久时不再额外等待
+		if offsets != nil {
+			if wait := time.Until(startTime.Add(offsets[i])); wait > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		// 检查任务是否被暂停或取消
 		select {
 		case <-ctx.Done():
+			if ts.isPausing(task.ID) {
+				ts.markTaskPaused(task, pausedAccountIndex(task, i), len(accountIDs))
+				return
+			}
 			logger.LogTask(zapcore.InfoLevel, "Task cancelled by user",
 				zap.Uint64("task_id", task.ID),
 				zap.Int("completed_accounts", i),
@@ -483,6 +637,32 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			continue
 		}
 
+		// 检查账号熔断状态
+		if ts.circuitBreaker != nil {
+			if allowed, reason := ts.circuitBreaker.Allow(accountID); !allowed {
+				ts.logger.Info("Skipping account with open circuit breaker",
+					zap.Uint64("task_id", task.ID),
+					zap.Uint64("account_id", accountID),
+					zap.String("reason", reason))
+				accountResults[accountIDStr] = map[string]interface{}{
+					"status": "skipped",
+					"reason": reason,
+				}
+				ts.createTaskLog(task.ID, &accountID, "account_skipped", fmt.Sprintf("账号 %s 已熔断: %s", accountPhone, reason), nil)
+				continue
+			}
+		}
+
+		// 近期已达到频道数量上限的账号不参与加群
+		if atChannelLimit(task, account) {
+			accountResults[accountIDStr] = map[string]interface{}{
+				"status": "skipped",
+				"reason": "channel_limit",
+			}
+			ts.createTaskLog(task.ID, &accountID, "account_skipped", fmt.Sprintf("账号 %s 已达到频道数量上限，跳过加群", accountPhone), nil)
+			continue
+		}
+
 		// 执行风控检查
 		if err := ts.performRiskControlCheck(task, accountIDStr); err != nil {
 			ts.logger.Warn("Risk control check failed for account",
@@ -509,6 +689,9 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 
 		// 创建任务执行器
 		taskExecutor, err := ts.createTaskExecutor(task, accountID)
+		if broadcast, ok := taskExecutor.(*telegram.BroadcastTask); ok {
+			broadcast.SetInterruptContext(ctx)
+		}
 		if err != nil {
 			ts.logger.Error("Failed to create task executor for account",
 				zap.Uint64("task_id", task.ID),
@@ -525,14 +708,18 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			continue
 		}
 
-		// 执行任务
+		// 执行任务，任务被停止或暂停时立即放弃等待任务槽位并取消执行
 		accountStartTime := time.Now()
-		err = ts.connectionPool.ExecuteTask(accountIDStr, taskExecutor)
+		err = ts.connectionPool.ExecuteTaskWithContext(ctx, accountIDStr, taskExecutor)
 		accountDuration := time.Since(accountStartTime)
 
 		// 保存该账号的执行结果（从 task.Result 中提取）
 		accountResult := make(map[string]interface{})
 		accountResult["duration"] = accountDuration.String()
+		if offsets != nil {
+			accountResult["planned_start_offset_ms"] = offsets[i].Milliseconds()
+			accountResult["start_offset_ms"] = accountStartTime.Sub(startTime).Milliseconds()
+		}
 
 		// 复制任务执行器写入的结果
 		for key, value := range task.Result {
@@ -541,6 +728,17 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			}
 		}
 
+		// 账号加固任务设置了新的2FA密码，即使其他步骤失败也要同步到账号信息
+		if task.TaskType == models.TaskTypeSecureAccount {
+			if password, ok := accountResult["two_fa_password"].(string); ok && password != "" {
+				if err := ts.accountRepo.Update2FAStatus(accountID, true, password); err != nil {
+					ts.logger.Error("Failed to update 2FA status",
+						zap.Uint64("account_id", accountID),
+						zap.Error(err))
+				}
+			}
+		}
+
 		if err != nil {
 			logger.LogTask(zapcore.ErrorLevel, "Task execution failed for account",
 				zap.Uint64("task_id", task.ID),
@@ -556,6 +754,9 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			if ts.riskControlService != nil {
 				ts.riskControlService.ReportTaskResult(ts.ctx, accountID, false, err)
 			}
+			if ts.circuitBreaker != nil && ts.circuitBreaker.RecordFailure(accountID) {
+				ts.createTaskLog(task.ID, &accountID, "circuit_breaker_open", fmt.Sprintf("账号 %s 连续失败过多，已熔断", accountPhone), nil)
+			}
 
 			failCount++
 			lastError = err
@@ -586,11 +787,28 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 							message = fmt.Sprintf("发送给 %s 失败: %s", targetName, errorMsg)
 						}
 
-						ts.createTaskLog(task.ID, &accountID, fmt.Sprintf("target_%s", status), message, nil)
+						var extra interface{}
+						if reason, ok := resultMap["reason"].(string); ok && reason != "" {
+							extra = map[string]interface{}{"reason": reason}
+						}
+						ts.createTaskLog(task.ID, &accountID, fmt.Sprintf("target_%s", status), message, extra)
 					}
 				}
 			}
 
+			limited, _ := accountResult["channel_limit_reached"].(bool)
+			ts.recordChannelLimit(task, account, limited)
+			if limited {
+				message := fmt.Sprintf("账号 %s 已达到频道数量上限，未加入的群组已转交其他账号", accountPhone)
+				if task.TaskType == models.TaskTypeJoinGroup {
+					message = fmt.Sprintf("账号 %s 已达到频道数量上限，剩余群组未加入", accountPhone)
+				}
+				ts.createTaskLog(task.ID, &accountID, "channel_limit_reached", message, nil)
+			}
+			if restricted, _ := accountResult["write_restricted"].(bool); restricted {
+				ts.createTaskLog(task.ID, &accountID, "write_restricted", fmt.Sprintf("账号 %s 已被限制发言，已停止使用该账号，剩余群组转交其他账号", accountPhone), nil)
+			}
+
 			// 记录执行成功日志
 			logMessage := fmt.Sprintf("账号 %s 执行成功，耗时 %s", accountPhone, accountDuration)
 			if task.TaskType == models.TaskTypeCheck {
@@ -602,6 +820,9 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			if ts.riskControlService != nil {
 				ts.riskControlService.ReportTaskResult(ts.ctx, accountID, true, nil)
 			}
+			if ts.circuitBreaker != nil {
+				ts.circuitBreaker.RecordSuccess(accountID)
+			}
 
 			successCount++
 
@@ -664,6 +885,22 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 							zap.Error(err))
 					}
 				}
+
+				// 更新服务条款状态，自动接受时记录接受时间
+				if tosPending, ok := accountResult["tos_pending"].(bool); ok {
+					var acceptedAt *time.Time
+					if accepted, _ := accountResult["tos_accepted"].(bool); accepted {
+						now := time.Now()
+						acceptedAt = &now
+						ts.createTaskLog(task.ID, &accountID, "tos_accepted",
+							fmt.Sprintf("账号 %s 已自动接受新的服务条款", accountPhone), nil)
+					}
+					if err := ts.accountRepo.UpdateTOSStatus(accountID, tosPending, acceptedAt); err != nil {
+						ts.logger.Error("Failed to update terms of service status",
+							zap.Uint64("account_id", accountID),
+							zap.Error(err))
+					}
+				}
 			}
 		}
 
@@ -674,14 +911,75 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 		task.Result["account_results"] = accountResults
 	}
 
+	// 最后一个账号执行期间收到暂停信号
+	if ts.isPausing(task.ID) {
+		if next := pausedAccountIndex(task, len(accountIDs)); next < len(accountIDs) {
+			ts.markTaskPaused(task, next, len(accountIDs))
+			return
+		}
+	}
+
+	// 所有账号都达到频道上限时，剩余群组无法发送
+	if unsent, ok := task.Result["channel_limit_deferred_groups"].([]interface{}); ok && len(unsent) > 0 {
+		task.Result["unsent_groups"] = unsent
+		delete(task.Result, "channel_limit_deferred_groups")
+		ts.createTaskLog(task.ID, nil, "channel_limit_unsent", fmt.Sprintf("%d 个群组因所有账号均达到频道数量上限而未发送", len(unsent)), nil)
+	}
+
+	// 被禁言账号留下的群组没有健康账号接手（如单账号任务），提前结束并标记为部分完成
+	if unsent, ok := task.Result["write_restricted_deferred_groups"].([]interface{}); ok && len(unsent) > 0 {
+		delete(task.Result, "write_restricted_deferred_groups")
+		existing, _ := task.Result["unsent_groups"].([]interface{})
+		task.Result["unsent_groups"] = append(existing, unsent...)
+		reason := fmt.Sprintf("账号被限制发言 (CHAT_WRITE_FORBIDDEN)，没有可接手的健康账号，%d 个群组未发送", len(unsent))
+		task.Result["partial"] = true
+		task.Result["partial_reason"] = reason
+		ts.createTaskLog(task.ID, nil, "write_restricted_unsent", reason, nil)
+	}
+
+	// 金丝雀检查未通过，剩余群组全部中止
+	if unsent, ok := task.Result["canary_aborted_groups"].([]interface{}); ok && len(unsent) > 0 {
+		delete(task.Result, "canary_aborted_groups")
+		existing, _ := task.Result["unsent_groups"].([]interface{})
+		task.Result["unsent_groups"] = append(existing, unsent...)
+		reason := fmt.Sprintf("金丝雀检查发现账号出现新的限制，已中止群发，%d 个群组未发送", len(unsent))
+		task.Result["partial"] = true
+		task.Result["partial_reason"] = reason
+		ts.createTaskLog(task.ID, nil, "canary_aborted", reason, nil)
+	}
+
 	// 更新任务结果
 	task.Result["success_count"] = successCount
 	task.Result["fail_count"] = failCount
 	task.Result["total_accounts"] = len(accountIDs)
 
+	// 最后一个账号失败时循环已结束，同样按 stop_on_error 处理
+	if stopOnError && failCount > 0 && stoppedByAccount == "" {
+		stoppedByAccount = fmt.Sprintf("%d", accountIDs[len(accountIDs)-1])
+	}
+
 	// 完成任务
 	duration := time.Since(startTime)
-	if successCount == 0 {
+	if stoppedByAccount != "" {
+		// 开启 stop_on_error 且有账号失败，无论其他账号结果如何任务都标记为失败
+		logger.LogTask(zapcore.ErrorLevel, "Task stopped on first account failure",
+			zap.Uint64("task_id", task.ID),
+			zap.String("failed_account_id", stoppedByAccount),
+			zap.Int("success_count", successCount),
+			zap.Int("total_accounts", len(accountIDs)),
+			zap.Duration("duration", duration),
+			zap.Error(lastError))
+		task.Result["stopped_on_error"] = true
+		task.Result["failed_account_id"] = stoppedByAccount
+		ts.createTaskLog(task.ID, nil, "task_stopped_on_error", fmt.Sprintf("账号 %s 执行失败，已停止处理剩余 %d 个账号，耗时 %s", stoppedByAccount, stoppedRemaining, duration), nil)
+		taskErr := fmt.Errorf("stopped after account %s failed: %w", stoppedByAccount, lastError)
+		ts.recordTaskAttempt(task, startTime, successCount, failCount, taskErr)
+		// 已有账号执行成功时不整体重试，避免重复发送
+		if successCount == 0 && ts.retryWholeTask(ctx, task, taskErr) {
+			return
+		}
+		ts.completeTaskWithError(task, taskErr)
+	} else if successCount == 0 {
 		// 所有账号都失败
 		logger.LogTask(zapcore.ErrorLevel, "Task execution failed for all accounts",
 			zap.Uint64("task_id", task.ID),
@@ -689,7 +987,12 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			zap.Duration("duration", duration),
 			zap.Error(lastError))
 		ts.createTaskLog(task.ID, nil, "task_failed", fmt.Sprintf("任务失败，%d 个账号全部执行失败，耗时 %s", len(accountIDs), duration), nil)
-		ts.completeTaskWithError(task, fmt.Errorf("all %d accounts failed, last error: %w", len(accountIDs), lastError))
+		taskErr := fmt.Errorf("all %d accounts failed, last error: %w", len(accountIDs), lastError)
+		ts.recordTaskAttempt(task, startTime, successCount, failCount, taskErr)
+		if ts.retryWholeTask(ctx, task, taskErr) {
+			return
+		}
+		ts.completeTaskWithError(task, taskErr)
 	} else if failCount > 0 {
 		// 部分成功
 		logger.LogTask(zapcore.WarnLevel, "Task execution partially succeeded",
@@ -699,6 +1002,7 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			zap.Int("total_accounts", len(accountIDs)),
 			zap.Duration("duration", duration))
 		ts.createTaskLog(task.ID, nil, "task_partial_success", fmt.Sprintf("任务部分完成: %d 成功, %d 失败，耗时 %s", successCount, failCount, duration), nil)
+		ts.recordTaskAttempt(task, startTime, successCount, failCount, nil)
 		ts.completeTaskWithSuccess(task)
 	} else {
 		// 全部成功
@@ -707,6 +1011,7 @@ func (ts *TaskScheduler) executeTaskWithContext(ctx context.Context, task *model
 			zap.Int("total_accounts", len(accountIDs)),
 			zap.Duration("duration", duration))
 		ts.createTaskLog(task.ID, nil, "task_completed", fmt.Sprintf("任务完成，%d 个账号全部成功，耗时 %s", len(accountIDs), duration), nil)
+		ts.recordTaskAttempt(task, startTime, successCount, failCount, nil)
 		ts.completeTaskWithSuccess(task)
 	}
 }
@@ -717,9 +1022,11 @@ func (ts *TaskScheduler) completeTaskWithSuccess(task *models.Task) {
 	completedTime := time.Now()
 	task.CompletedAt = &completedTime
 
+	ts.compactTaskResult(task)
 	if err := ts.taskRepo.UpdateTask(task.ID, map[string]interface{}{
 		"status":       models.TaskStatusCompleted,
 		"completed_at": completedTime,
+		"result":       task.Result,
 	}); err != nil {
 		ts.logger.Error("Failed to update completed task",
 			zap.Uint64("task_id", task.ID),
@@ -779,8 +1086,8 @@ func (ts *TaskScheduler) performRiskControlCheck(task *models.Task, accountID st
 		return fmt.Errorf("failed to get account: %w", err)
 	}
 
-	// 检查账号是否忙碌
-	if ts.connectionPool.IsAccountBusy(accountID) {
+	// 检查账号是否忙碌；配置了槽位等待时交由 ExecuteTask 排队，与场景任务的短调用串行执行
+	if ts.connectionPool.IsAccountBusy(accountID) && ts.connectionPool.TaskSlotWait() <= 0 {
 		ts.logger.Warn("Account is busy with another task",
 			zap.Uint64("task_id", task.ID),
 			zap.String("account_id", accountID),
@@ -842,6 +1149,7 @@ func (ts *TaskScheduler) completeTaskWithError(task *models.Task, taskErr error)
 	}
 	task.Result["error"] = taskErr.Error()
 
+	ts.compactTaskResult(task)
 	if err := ts.taskRepo.UpdateTask(task.ID, map[string]interface{}{
 		"status":       models.TaskStatusFailed,
 		"completed_at": completedTime,
@@ -868,25 +1176,54 @@ func (ts *TaskScheduler) completeTaskWithError(task *models.Task, taskErr error)
 
 // createTaskExecutor 创建任务执行器
 func (ts *TaskScheduler) createTaskExecutor(task *models.Task, accountID uint64) (telegram.TaskInterface, error) {
+	accountIDStr := strconv.FormatUint(accountID, 10)
 	switch task.TaskType {
 	case models.TaskTypeCheck:
-		return telegram.NewAccountCheckTask(task), nil
+		check := telegram.NewAccountCheckTask(task)
+		check.SetAutoAcceptTOS(ts.connectionPool.AutoAcceptTOS())
+		return check, nil
 	case models.TaskTypePrivate:
-		return telegram.NewPrivateMessageTask(task), nil
+		private := telegram.NewPrivateMessageTask(task)
+		private.SetPeerResolver(ts.connectionPool.PeerResolver(), accountIDStr)
+		return private, nil
 	case models.TaskTypeBroadcast:
-		return telegram.NewBroadcastTask(task), nil
+		broadcast := telegram.NewBroadcastTask(task, ts.aiService)
+		broadcast.SetPeerResolver(ts.connectionPool.PeerResolver(), accountIDStr)
+		if ts.groupSendLimiter != nil {
+			broadcast.SetGroupSendLimiter(ts.groupSendLimiter, accountID)
+		}
+		if ts.joinRetryPolicy != nil {
+			broadcast.SetJoinRetryPolicy(*ts.joinRetryPolicy)
+		}
+		return broadcast, nil
 	case models.TaskTypeVerify:
-		return telegram.NewVerifyCodeTask(task), nil
+		verify := telegram.NewVerifyCodeTask(task)
+		verify.SetUpdateSource(ts.connectionPool, accountIDStr)
+		return verify, nil
 	case models.TaskTypeGroupChat:
-		return telegram.NewGroupChatTask(task), nil
+		groupChat := telegram.NewGroupChatTask(task, ts.aiService)
+		groupChat.SetPeerResolver(ts.connectionPool.PeerResolver(), accountIDStr)
+		return groupChat, nil
 	case models.TaskTypeJoinGroup:
-		return telegram.NewJoinGroupTask(task), nil
+		return telegram.NewJoinGroupTask(task, accountID), nil
 	case models.TaskTypeForceAdd:
 		return telegram.NewForceAddGroupTask(task, accountID), nil
 	case models.TaskTypeTerminateSessions:
 		return telegram.NewTerminateSessionsTask(task), nil
 	case models.TaskTypeUpdate2FA:
 		return telegram.NewUpdate2FATask(task), nil
+	case models.TaskTypeSecureAccount:
+		return telegram.NewSecureAccountTask(task), nil
+	case models.TaskTypeClearHistory:
+		return telegram.NewClearHistoryTask(task), nil
+	case models.TaskTypeBotInteraction:
+		return telegram.NewBotInteractionTask(task), nil
+	case models.TaskTypeForwardMessage:
+		return telegram.NewForwardMessageTask(task), nil
+	case models.TaskTypeMemberMessage:
+		return telegram.NewMemberMessageTask(task), nil
+	case models.TaskTypeRefreshInfo:
+		return telegram.NewRefreshInfoTask(task, ts.connectionPool, accountID), nil
 	default:
 		return nil, fmt.Errorf("unsupported task type: %s", task.TaskType)
 	}
@@ -928,6 +1265,9 @@ func (ts *TaskScheduler) generateRecommendations(account *models.TGAccount, avai
 	case models.AccountStatusCooling:
 		availability.Warnings = append(availability.Warnings, "账号冷却中")
 		availability.Recommendation = "等待冷却期结束"
+		if account.CoolingUntil != nil && account.CoolingUntil.After(time.Now()) {
+			availability.Recommendation = fmt.Sprintf("等待冷却期结束（剩余 %s）", time.Until(*account.CoolingUntil).Round(time.Second))
+		}
 	case models.AccountStatusMaintenance:
 		availability.Warnings = append(availability.Warnings, "账号维护中")
 		availability.Recommendation = "暂时无法使用"
@@ -957,14 +1297,103 @@ func (ts *TaskScheduler) GetQueueStatus(accountID string) *models.QueueInfo {
 		}
 	}
 
-	// 实现队列状态获取逻辑
-	// 这里应该查询数据库获取更完整的统计信息
-	return &models.QueueInfo{
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	// 统计队列中包含该账号的任务，以及其中最早的计划执行时间
+	info := &models.QueueInfo{
 		AccountID:         accountIDUint,
-		PendingTasks:      int64(ts.getQueueSize()),
-		RunningTasks:      0, // 需要实现
+		RunningTasks:      int64(ts.accountRunning[accountIDUint]),
 		EstimatedWaitTime: 0, // 需要实现
 	}
+	now := time.Now()
+	for _, task := range ts.taskQueue {
+		accountIDs, _ := task.SplitExcludedAccounts()
+		if !containsAccount(accountIDs, accountIDUint) {
+			continue
+		}
+		info.PendingTasks++
+		if isScheduledLater(task, now) && (info.NextScheduledAt == nil || task.ScheduledAt.Before(*info.NextScheduledAt)) {
+			scheduledAt := *task.ScheduledAt
+			info.NextScheduledAt = &scheduledAt
+		}
+	}
+	if info.NextScheduledAt != nil {
+		info.NextScheduledIn = int64(time.Until(*info.NextScheduledAt).Seconds())
+	}
+	return info
+}
+
+// containsAccount 账号列表中是否包含指定账号
+func containsAccount(accountIDs []uint64, accountID uint64) bool {
+	for _, id := range accountIDs {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSchedulerStats 获取调度器整体状态：排队与运行中的任务数
+func (ts *TaskScheduler) GetSchedulerStats() map[string]interface{} {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	queuedByType := make(map[string]int)
+	scheduledTasks := 0
+	var nextScheduledAt *time.Time
+	now := time.Now()
+	for _, task := range ts.taskQueue {
+		queuedByType[string(task.TaskType)]++
+		if isScheduledLater(task, now) {
+			scheduledTasks++
+			if nextScheduledAt == nil || task.ScheduledAt.Before(*nextScheduledAt) {
+				nextScheduledAt = task.ScheduledAt
+			}
+		}
+	}
+	stats := map[string]interface{}{
+		"queue_size":      len(ts.taskQueue),
+		"queued_by_type":  queuedByType,
+		"running_tasks":   len(ts.runningTasks),
+		"scheduled_tasks": scheduledTasks,
+	}
+	if nextScheduledAt != nil {
+		stats["next_scheduled_at"] = *nextScheduledAt
+	}
+	return stats
+}
+
+// GetRuntimeSettings 获取调度器实际使用的设置
+func (ts *TaskScheduler) GetRuntimeSettings() map[string]interface{} {
+	settings := map[string]interface{}{
+		"max_concurrent":              ts.maxConcurrent,
+		"circuit_breaker_enabled":     ts.circuitBreaker != nil,
+		"scenario_trigger_queue_size": ts.triggerQueueSize,
+		"scenario_max_decisions":      ts.maxDecisions,
+		"decision_queue_wait":         ts.decisionQueueWait.String(),
+		"agent_memory_enabled":        ts.agentMemoryRepo != nil,
+		"memory_max_chars":            ts.memoryMaxChars,
+		"memory_ttl":                  ts.memoryTTL.String(),
+		"priority_aging":              ts.priorityAging.String(),
+		"max_concurrent_per_account":  ts.maxPerAccount,
+		"task_retry_max":              ts.taskRetryMax,
+		"task_retry_delay":            ts.taskRetryDelay.String(),
+		"result_max_bytes":            ts.resultMaxBytes,
+		"result_max_bytes_by_type":    ts.resultMaxBytesByType,
+		"result_sample_size":          ts.resultSampleSize,
+		"stagger_base":                ts.staggerBase.String(),
+		"stagger_jitter":              ts.staggerJitter.String(),
+		"group_send_limit_enabled":    ts.groupSendLimiter != nil,
+	}
+	if ts.joinRetryPolicy != nil {
+		settings["join_retry_policy"] = map[string]interface{}{
+			"interval":    ts.joinRetryPolicy.Interval.String(),
+			"max_retries": ts.joinRetryPolicy.MaxRetries,
+			"max_wait":    ts.joinRetryPolicy.MaxWait.String(),
+		}
+	}
+	return settings
 }
 
 // Close 关闭调度器
@@ -1095,6 +1524,20 @@ func (ts *TaskScheduler) executeScenarioTaskWithContext(ctx context.Context, tas
 		ts.completeTaskWithError(task, err)
 		return
 	}
+	runner.SetTriggerQueueSize(ts.triggerQueueSize)
+	runner.SetDecisionConcurrency(ts.maxDecisions, ts.decisionQueueWait)
+	runner.SetMessageCache(ts.messageCache)
+	if ts.agentMemoryRepo != nil {
+		// 顺带清理过期记忆
+		if ts.memoryTTL > 0 {
+			if deleted, err := ts.agentMemoryRepo.DeleteExpired(time.Now().Add(-ts.memoryTTL)); err != nil {
+				ts.logger.Warn("Failed to delete expired agent memories", zap.Error(err))
+			} else if deleted > 0 {
+				ts.logger.Info("Expired agent memories deleted", zap.Int64("count", deleted))
+			}
+		}
+		runner.SetMemoryStore(ts.agentMemoryRepo, ts.memoryMaxChars, ts.memoryTTL)
+	}
 
 	// 记录智能体信息
 	if agents, ok := task.Config["agents"].([]interface{}); ok {
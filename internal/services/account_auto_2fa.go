@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/utils"
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/telegram"
+)
+
+// autoGenerated2FALength 自动生成的 2FA 密码长度
+const autoGenerated2FALength = 16
+
+// AutoSetup2FA 为未开启 2FA 的账号设置密码，password 为空时随机生成，成功后加密保存到账号记录
+// 账号实际已有 2FA（本地记录未同步）时不修改密码，只将账号标记为已开启
+func (s *AccountService) AutoSetup2FA(accountID uint64, password, hint string) error {
+	if !models.HasTwoFASecretKey() {
+		return fmt.Errorf("encryption key is not configured, refusing to store 2FA password")
+	}
+	if password == "" {
+		generated, err := utils.GenerateSecret(autoGenerated2FALength)
+		if err != nil {
+			return fmt.Errorf("failed to generate 2FA password: %w", err)
+		}
+		password = generated
+	}
+	task := &models.Task{
+		TaskType: models.TaskTypeUpdate2FA,
+		Config:   models.TaskConfig{"new_password": password, "hint": hint},
+		Result:   make(models.TaskResult),
+	}
+	err := s.connectionPool.ExecuteTask(fmt.Sprintf("%d", accountID), telegram.NewUpdate2FATask(task))
+	if err != nil && strings.Contains(err.Error(), "old_password is required") {
+		s.logger.Info("Account already has 2FA on Telegram, syncing local status only",
+			zap.Uint64("account_id", accountID))
+		return s.accountRepo.Update2FAStatus(accountID, true, "")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set 2FA password: %w", err)
+	}
+
+	if err := s.accountRepo.Update2FAStatus(accountID, true, password); err != nil {
+		// 密码已在 Telegram 生效，保存失败需人工处理
+		s.logger.Error("2FA password set on Telegram but failed to save",
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		return fmt.Errorf("failed to save 2FA password: %w", err)
+	}
+
+	s.logger.Info("2FA password set automatically", zap.Uint64("account_id", accountID))
+	return nil
+}
@@ -1,13 +1,17 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
 	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/common/utils"
 	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/repository"
 	"tg_cloud_server/internal/telegram"
@@ -17,6 +21,14 @@ var (
 	ErrAccountExists   = errors.New("account already exists")
 	ErrAccountNotFound = errors.New("account not found")
 	ErrProxyNotFound   = errors.New("proxy not found")
+
+	ErrInvalidAccountStatus = errors.New("invalid target account status")
+)
+
+// 多代理连通性测试限制：逐个测试，单次最多10个代理，每个代理最多等待20秒
+const (
+	maxProxyProbeCount = 10
+	proxyProbeTimeout  = 20 * time.Second
 )
 
 // AccountService 账号管理服务
@@ -39,11 +51,16 @@ func NewAccountService(accountRepo repository.AccountRepository, proxyRepo repos
 
 // AccountFilter 账号过滤器
 type AccountFilter struct {
-	UserID uint64
-	Status string
-	Search string
-	Page   int
-	Limit  int
+	UserID       uint64
+	Status       string
+	Search       string
+	CountryCode  string
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	LastUsedFrom *time.Time
+	LastUsedTo   *time.Time
+	Page         int
+	Limit        int
 }
 
 // CreateAccount 创建账号
@@ -59,6 +76,7 @@ func (s *AccountService) CreateAccount(userID uint64, req *models.CreateAccountR
 		Phone:  req.Phone,
 		Status: models.AccountStatusNew,
 	}
+	applyPhoneInfo(account)
 
 	// 如果提供了session数据，设置它
 	if req.SessionData != "" {
@@ -95,7 +113,20 @@ func (s *AccountService) CreateAccount(userID uint64, req *models.CreateAccountR
 
 // GetAccounts 获取账号列表
 func (s *AccountService) GetAccounts(filter *AccountFilter) ([]*models.AccountSummary, int64, error) {
-	return s.accountRepo.GetAccountSummaries(filter.UserID, filter.Page, filter.Limit, filter.Search, filter.Status)
+	return s.accountRepo.GetAccountSummaries(filter.UserID, filter.Page, filter.Limit, filter.Search, filter.Status, filter.CountryCode, repository.AccountTimeRange{
+		CreatedFrom:  filter.CreatedFrom,
+		CreatedTo:    filter.CreatedTo,
+		LastUsedFrom: filter.LastUsedFrom,
+		LastUsedTo:   filter.LastUsedTo,
+	})
+}
+
+// applyPhoneInfo 根据手机号填充国家、地区和运营商，无效号码保持为空
+func applyPhoneInfo(account *models.TGAccount) {
+	info := utils.LookupPhone(account.Phone)
+	account.CountryCode = info.CountryCode
+	account.Region = info.Region
+	account.Carrier = info.Carrier
 }
 
 // GetAccount 获取账号详情
@@ -115,6 +146,7 @@ func (s *AccountService) UpdateAccount(userID, accountID uint64, req *models.Upd
 	}
 
 	// 更新代理绑定
+	oldProxyID := account.ProxyID
 	if req.ProxyID != nil {
 		if *req.ProxyID == 0 {
 			// 解除代理绑定
@@ -133,10 +165,28 @@ func (s *AccountService) UpdateAccount(userID, accountID uint64, req *models.Upd
 	}
 
 	// 更新状态
+	oldStatus := account.Status
 	if req.Status != nil {
 		account.Status = *req.Status
 	}
 
+	if req.HeartbeatEnabled != nil {
+		account.HeartbeatEnabled = *req.HeartbeatEnabled
+	}
+
+	if req.DeviceModel != nil {
+		account.DeviceModel = strings.TrimSpace(*req.DeviceModel)
+	}
+	if req.SystemVersion != nil {
+		account.SystemVersion = strings.TrimSpace(*req.SystemVersion)
+	}
+	if req.AppVersion != nil {
+		account.AppVersion = strings.TrimSpace(*req.AppVersion)
+	}
+	if req.LangCode != nil {
+		account.LangCode = strings.ToLower(strings.TrimSpace(*req.LangCode))
+	}
+
 	if err := s.accountRepo.Update(account); err != nil {
 		s.logger.Error("Failed to update account",
 			zap.Uint64("user_id", userID),
@@ -145,6 +195,49 @@ func (s *AccountService) UpdateAccount(userID, accountID uint64, req *models.Upd
 		return nil, fmt.Errorf("failed to update account: %w", err)
 	}
 
+	proxyHistory := &models.ProxyBindingHistory{
+		AccountID:  accountID,
+		UserID:     userID,
+		OldProxyID: oldProxyID,
+		NewProxyID: account.ProxyID,
+		Reason:     models.ProxyBindingReasonManual,
+		Actor:      models.ProxyBindingActorUser(userID),
+	}
+	if proxyHistory.Changed() {
+		if err := s.accountRepo.UpdateProxyBindingsWithHistory([]*models.ProxyBindingHistory{proxyHistory}); err != nil {
+			s.logger.Warn("Failed to record proxy binding history",
+				zap.Uint64("account_id", accountID),
+				zap.Error(err))
+		}
+	}
+
+	if account.Status != oldStatus {
+		history := &models.AccountStatusHistory{
+			AccountID:  accountID,
+			UserID:     userID,
+			FromStatus: oldStatus,
+			ToStatus:   account.Status,
+			Source:     models.StatusChangeSourceManual,
+		}
+		if err := s.accountRepo.UpdateStatusesWithHistory([]*models.AccountStatusHistory{history}); err != nil {
+			s.logger.Warn("Failed to record account status history",
+				zap.Uint64("account_id", accountID),
+				zap.Error(err))
+		}
+	}
+
+	// 账号被锁定（冻结/维护/死亡）时主动断开连接，避免后台继续重连
+	if req.Status != nil && !account.IsAvailable() && s.connectionPool != nil {
+		s.connectionPool.RemoveConnection(fmt.Sprintf("%d", accountID))
+	} else if s.connectionPool != nil && account.IsAvailable() {
+		// 同步连接池中的配置，只有代理等影响连接的字段变化时才会重建连接
+		if _, err := s.connectionPool.ReloadConfig(fmt.Sprintf("%d", accountID)); err != nil {
+			s.logger.Warn("Failed to reload connection config",
+				zap.Uint64("account_id", accountID),
+				zap.Error(err))
+		}
+	}
+
 	s.logger.Info("Account updated successfully",
 		zap.Uint64("user_id", userID),
 		zap.Uint64("account_id", accountID))
@@ -167,6 +260,10 @@ func (s *AccountService) DeleteAccount(userID, accountID uint64) error {
 		return fmt.Errorf("failed to delete account: %w", err)
 	}
 
+	if s.connectionPool != nil {
+		s.connectionPool.RemoveConnection(fmt.Sprintf("%d", accountID))
+	}
+
 	s.logger.Info("Account deleted successfully",
 		zap.Uint64("user_id", userID),
 		zap.Uint64("account_id", accountID),
@@ -248,6 +345,169 @@ func (s *AccountService) CheckAccountHealth(userID, accountID uint64) (*models.A
 	return report, nil
 }
 
+// PreviewRecipients 使用指定账号解析目标列表并检查成员关系（不发送消息、不创建任务）
+func (s *AccountService) PreviewRecipients(userID, accountID uint64, targets []string) ([]models.RecipientPreview, error) {
+	account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+
+	task := telegram.NewPreviewRecipientsTask(targets)
+	if err := s.connectionPool.ExecuteTask(fmt.Sprintf("%d", account.ID), task); err != nil {
+		s.logger.Warn("Failed to preview recipients",
+			zap.Uint64("account_id", accountID),
+			zap.Int("target_count", len(targets)),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Recipients previewed",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("account_id", accountID),
+		zap.Int("target_count", len(targets)))
+
+	return task.Results, nil
+}
+
+// RefreshAccountInfo 使用指定账号重新获取用户名、姓名、简介和头像，返回更新后的账号
+func (s *AccountService) RefreshAccountInfo(userID, accountID uint64) (*models.TGAccount, error) {
+	account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+
+	task := telegram.NewRefreshInfoTask(&models.Task{UserID: userID, TaskType: models.TaskTypeRefreshInfo}, s.connectionPool, account.ID)
+	if err := s.connectionPool.ExecuteTask(fmt.Sprintf("%d", account.ID), task); err != nil {
+		s.logger.Warn("Failed to refresh account info",
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Account info refreshed",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("account_id", accountID))
+
+	return task.Account, nil
+}
+
+// GetConversation 使用指定账号读取与某个用户/群组/频道的最近消息，offsetID 用于向前翻页
+func (s *AccountService) GetConversation(userID, accountID uint64, peer string, limit, offsetID int) (*models.Conversation, error) {
+	account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+
+	task := telegram.NewGetConversationTask(peer, limit, offsetID)
+	if err := s.connectionPool.ExecuteTask(fmt.Sprintf("%d", account.ID), task); err != nil {
+		s.logger.Warn("Failed to get conversation",
+			zap.Uint64("account_id", accountID),
+			zap.String("peer", peer),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Conversation fetched",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("account_id", accountID),
+		zap.String("peer", peer),
+		zap.Int("message_count", len(task.Result.Messages)),
+		zap.Bool("restricted", task.Result.Restricted))
+
+	return task.Result, nil
+}
+
+// TestAccountAcrossProxies 使用账号会话依次通过候选代理建立临时连接，按连接成功与延迟排名
+// 不修改账号的代理绑定，用于迁移代理服务商时挑选最合适的代理
+func (s *AccountService) TestAccountAcrossProxies(userID, accountID uint64, proxyIDs []uint64) ([]*models.ProxyProbeResult, error) {
+	account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+
+	// 去重并校验代理归属，全部通过后再开始测试
+	seen := make(map[uint64]bool, len(proxyIDs))
+	var proxies []*models.ProxyIP
+	for _, proxyID := range proxyIDs {
+		if seen[proxyID] {
+			continue
+		}
+		seen[proxyID] = true
+
+		proxy, err := s.proxyRepo.GetByUserIDAndID(userID, proxyID)
+		if err != nil {
+			return nil, ErrProxyNotFound
+		}
+		proxies = append(proxies, proxy)
+	}
+	if len(proxies) > maxProxyProbeCount {
+		return nil, fmt.Errorf("too many proxies: at most %d per test", maxProxyProbeCount)
+	}
+
+	results := make([]*models.ProxyProbeResult, 0, len(proxies))
+	for _, proxy := range proxies {
+		result := &models.ProxyProbeResult{
+			ProxyID:   proxy.ID,
+			Name:      proxy.Name,
+			Address:   proxy.GetAddress(),
+			Country:   proxy.Country,
+			IsCurrent: account.ProxyID != nil && *account.ProxyID == proxy.ID,
+		}
+		results = append(results, result)
+
+		password, err := proxy.ResolvePassword()
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to resolve proxy password: %v", err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), proxyProbeTimeout)
+		latency, err := s.connectionPool.ProbeAccountProxy(ctx, account.ID, &telegram.ProxyConfig{
+			Protocol: string(proxy.Protocol),
+			IP:       proxy.IP,
+			Port:     proxy.Port,
+			Username: proxy.Username,
+			Password: password,
+		})
+		cancel()
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Success = true
+		result.LatencyMs = latency.Milliseconds()
+	}
+
+	// 成功的排在前面，按延迟升序；失败的保持测试顺序
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Success != results[j].Success {
+			return results[i].Success
+		}
+		return results[i].Success && results[i].LatencyMs < results[j].LatencyMs
+	})
+	for i, result := range results {
+		result.Rank = i + 1
+	}
+
+	s.logger.Info("Account tested across proxies",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("account_id", accountID),
+		zap.Int("proxy_count", len(results)))
+
+	return results, nil
+}
+
+// SendHeartbeat 将账号设为在线并记录心跳时间
+func (s *AccountService) SendHeartbeat(accountID uint64) error {
+	if err := s.connectionPool.ExecuteTask(fmt.Sprintf("%d", accountID), telegram.NewHeartbeatTask()); err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	if err := s.accountRepo.UpdateLastHeartbeat(accountID, time.Now()); err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	return nil
+}
+
 // GetAccountAvailability 获取账号可用性
 func (s *AccountService) GetAccountAvailability(userID, accountID uint64) (*models.AccountAvailability, error) {
 	account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
@@ -317,10 +577,7 @@ func (s *AccountService) BindProxy(userID, accountID uint64, proxyID *uint64) (*
 		return nil, ErrAccountNotFound
 	}
 
-	if proxyID == nil {
-		// 解除代理绑定
-		account.ProxyID = nil
-	} else {
+	if proxyID != nil {
 		// 验证代理是否存在且属于该用户
 		proxy, err := s.proxyRepo.GetByUserIDAndID(userID, *proxyID)
 		if err != nil {
@@ -329,11 +586,21 @@ func (s *AccountService) BindProxy(userID, accountID uint64, proxyID *uint64) (*
 		if !proxy.IsActive {
 			return nil, errors.New("proxy is not active")
 		}
-		account.ProxyID = proxyID
 	}
 
-	// 使用 UpdateProxyID 方法来确保 nil 值能正确更新为 NULL
-	if err := s.accountRepo.UpdateProxyID(accountID, proxyID); err != nil {
+	// proxyID 为 nil 时解除绑定，同时写入绑定变更记录
+	history := &models.ProxyBindingHistory{
+		AccountID:  accountID,
+		UserID:     userID,
+		OldProxyID: account.ProxyID,
+		NewProxyID: proxyID,
+		Reason:     models.ProxyBindingReasonManual,
+		Actor:      models.ProxyBindingActorUser(userID),
+	}
+	if !history.Changed() {
+		return account, nil
+	}
+	if err := s.accountRepo.UpdateProxyBindingsWithHistory([]*models.ProxyBindingHistory{history}); err != nil {
 		s.logger.Error("Failed to bind proxy",
 			zap.Uint64("user_id", userID),
 			zap.Uint64("account_id", accountID),
@@ -371,6 +638,11 @@ func (s *AccountService) checkAccountStatus(account *models.TGAccount, report *m
 		report.Issues = append(report.Issues, "账号处于冷却期")
 		report.Suggestions = append(report.Suggestions, "等待冷却期结束后再使用")
 	}
+
+	if account.TOSPending {
+		report.Issues = append(report.Issues, "账号有待接受的服务条款")
+		report.Suggestions = append(report.Suggestions, "在官方客户端登录并接受服务条款，或开启 telegram.auto_accept_tos 自动接受")
+	}
 }
 
 // checkProxyStatus 检查代理状态
@@ -539,24 +811,8 @@ func (s *AccountService) CreateAccountsFromUploadData(userID uint64, accounts []
 	var validationErrors []string
 
 	// 如果指定了代理，先验证代理是否存在且属于该用户
-	if proxyID != nil {
-		proxy, err := s.proxyRepo.GetByUserIDAndID(userID, *proxyID)
-		if err != nil {
-			s.logger.Warn("Proxy not found for batch upload",
-				zap.Uint64("user_id", userID),
-				zap.Uint64("proxy_id", *proxyID),
-				zap.Error(err))
-			return nil, nil, fmt.Errorf("代理不存在")
-		}
-		if !proxy.IsActive {
-			s.logger.Warn("Proxy is not active for batch upload",
-				zap.Uint64("user_id", userID),
-				zap.Uint64("proxy_id", *proxyID))
-			return nil, nil, fmt.Errorf("代理未激活")
-		}
-		s.logger.Debug("Proxy validated for batch upload",
-			zap.Uint64("proxy_id", *proxyID),
-			zap.String("proxy_ip", proxy.IP))
+	if err := s.validateUploadProxy(userID, proxyID); err != nil {
+		return nil, nil, err
 	}
 
 	// 第一阶段：验证所有数据
@@ -585,6 +841,7 @@ func (s *AccountService) CreateAccountsFromUploadData(userID uint64, accounts []
 			Status:      models.AccountStatusNew,
 			ProxyID:     proxyID,
 		}
+		applyPhoneInfo(account)
 		accountsToCreate = append(accountsToCreate, account)
 	}
 
@@ -610,6 +867,70 @@ func (s *AccountService) CreateAccountsFromUploadData(userID uint64, accounts []
 	return accountsToCreate, validationErrors, nil
 }
 
+// ValidateUploadData 只校验上传数据，返回每个账号的校验结论，不创建任何账号
+func (s *AccountService) ValidateUploadData(userID uint64, accounts []models.AccountUploadItem, proxyID *uint64) ([]*models.AccountUploadVerdict, error) {
+	if err := s.validateUploadProxy(userID, proxyID); err != nil {
+		return nil, err
+	}
+
+	verdicts := make([]*models.AccountUploadVerdict, 0, len(accounts))
+	seen := make(map[string]bool, len(accounts))
+	for _, item := range accounts {
+		verdict := &models.AccountUploadVerdict{Phone: item.Phone, Verdict: models.UploadVerdictValid}
+		verdicts = append(verdicts, verdict)
+
+		switch {
+		case item.Phone == "":
+			verdict.Verdict = models.UploadVerdictInvalid
+			verdict.Reason = "手机号不能为空"
+		case item.SessionData == "":
+			verdict.Verdict = models.UploadVerdictInvalid
+			verdict.Reason = "session数据不能为空"
+		case seen[item.Phone]:
+			verdict.Verdict = models.UploadVerdictDuplicate
+			verdict.Reason = "本次上传中重复"
+		default:
+			if existingAccount, _ := s.accountRepo.GetByPhone(item.Phone); existingAccount != nil {
+				verdict.Verdict = models.UploadVerdictDuplicate
+				verdict.Reason = "账号已存在"
+			}
+		}
+		seen[item.Phone] = true
+	}
+
+	s.logger.Info("Upload data validated",
+		zap.Uint64("user_id", userID),
+		zap.Int("total_accounts", len(accounts)))
+
+	return verdicts, nil
+}
+
+// validateUploadProxy 验证上传指定的代理存在、属于该用户且已激活
+func (s *AccountService) validateUploadProxy(userID uint64, proxyID *uint64) error {
+	if proxyID == nil {
+		return nil
+	}
+
+	proxy, err := s.proxyRepo.GetByUserIDAndID(userID, *proxyID)
+	if err != nil {
+		s.logger.Warn("Proxy not found for batch upload",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("proxy_id", *proxyID),
+			zap.Error(err))
+		return fmt.Errorf("代理不存在")
+	}
+	if !proxy.IsActive {
+		s.logger.Warn("Proxy is not active for batch upload",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("proxy_id", *proxyID))
+		return fmt.Errorf("代理未激活")
+	}
+	s.logger.Debug("Proxy validated for batch upload",
+		zap.Uint64("proxy_id", *proxyID),
+		zap.String("proxy_ip", proxy.IP))
+	return nil
+}
+
 // BatchSet2FA 批量设置2FA密码（使用事务）
 func (s *AccountService) BatchSet2FA(userID uint64, req *models.BatchSet2FARequest) error {
 	// 先获取所有需要更新的账号
@@ -745,6 +1066,91 @@ func (s *AccountService) BatchDeleteAccounts(userID uint64, accountIDs []uint64)
 	return successCount, failedCount, nil
 }
 
+// BatchUpdateStatus 批量修改账号状态，所有合法的变更在同一事务中写入并记录状态历史
+func (s *AccountService) BatchUpdateStatus(userID uint64, req *models.BatchUpdateStatusRequest) (*models.BatchUpdateStatusResult, error) {
+	if !models.AccountStatusNormal.CanTransitionTo(req.Status) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAccountStatus, req.Status)
+	}
+
+	result := &models.BatchUpdateStatusResult{Failures: make(map[uint64]string)}
+	var histories []*models.AccountStatusHistory
+	seen := make(map[uint64]bool, len(req.AccountIDs))
+
+	for _, accountID := range req.AccountIDs {
+		if seen[accountID] {
+			continue
+		}
+		seen[accountID] = true
+
+		account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
+		if err != nil {
+			result.Failures[accountID] = "account not found"
+			continue
+		}
+		if account.Status == req.Status {
+			// 已是目标状态，无需变更
+			result.SuccessCount++
+			continue
+		}
+		if !account.Status.CanTransitionTo(req.Status) {
+			result.Failures[accountID] = fmt.Sprintf("cannot change status from %s to %s", account.Status, req.Status)
+			continue
+		}
+
+		histories = append(histories, &models.AccountStatusHistory{
+			AccountID:  accountID,
+			UserID:     userID,
+			FromStatus: account.Status,
+			ToStatus:   req.Status,
+			Source:     models.StatusChangeSourceBatch,
+			Reason:     req.Reason,
+		})
+	}
+
+	if err := s.accountRepo.UpdateStatusesWithHistory(histories); err != nil {
+		s.logger.Error("Failed to batch update account status",
+			zap.Uint64("user_id", userID),
+			zap.String("status", string(req.Status)),
+			zap.Int("account_count", len(histories)),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to update account status: %w", err)
+	}
+	result.SuccessCount += len(histories)
+	result.FailedCount = len(result.Failures)
+
+	// 账号被锁定（冻结/维护/死亡）时主动断开连接，避免后台继续重连
+	target := &models.TGAccount{Status: req.Status}
+	if !target.IsAvailable() && s.connectionPool != nil {
+		for _, history := range histories {
+			s.connectionPool.RemoveConnection(fmt.Sprintf("%d", history.AccountID))
+		}
+	}
+
+	s.logger.Info("Batch update account status completed",
+		zap.Uint64("user_id", userID),
+		zap.String("status", string(req.Status)),
+		zap.Int("success_count", result.SuccessCount),
+		zap.Int("failed_count", result.FailedCount))
+
+	return result, nil
+}
+
+// GetStatusHistory 获取账号状态变更记录
+func (s *AccountService) GetStatusHistory(userID, accountID uint64, limit int) ([]*models.AccountStatusHistory, error) {
+	if _, err := s.accountRepo.GetByUserIDAndID(userID, accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	return s.accountRepo.GetStatusHistory(accountID, limit)
+}
+
+// GetProxyBindingHistory 获取账号代理绑定变更记录
+func (s *AccountService) GetProxyBindingHistory(userID, accountID uint64, limit int) ([]*models.ProxyBindingHistory, error) {
+	if _, err := s.accountRepo.GetByUserIDAndID(userID, accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	return s.accountRepo.GetProxyBindingHistory(accountID, limit)
+}
+
 // BatchBindProxy 批量绑定/解绑代理
 func (s *AccountService) BatchBindProxy(userID uint64, accountIDs []uint64, proxyID *uint64) (successCount int, failedCount int, err error) {
 	action := "绑定"
@@ -771,7 +1177,7 @@ func (s *AccountService) BatchBindProxy(userID uint64, accountIDs []uint64, prox
 
 	for _, accountID := range accountIDs {
 		// 验证账号属于当前用户
-		_, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
+		account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
 		if err != nil {
 			s.logger.Warn("Account not found or not owned by user",
 				zap.Uint64("user_id", userID),
@@ -781,8 +1187,21 @@ func (s *AccountService) BatchBindProxy(userID uint64, accountIDs []uint64, prox
 			continue
 		}
 
-		// 更新代理ID
-		if err := s.accountRepo.UpdateProxyID(accountID, proxyID); err != nil {
+		history := &models.ProxyBindingHistory{
+			AccountID:  accountID,
+			UserID:     userID,
+			OldProxyID: account.ProxyID,
+			NewProxyID: proxyID,
+			Reason:     models.ProxyBindingReasonBatch,
+			Actor:      models.ProxyBindingActorUser(userID),
+		}
+		if !history.Changed() {
+			successCount++
+			continue
+		}
+
+		// 更新代理ID并记录绑定变更
+		if err := s.accountRepo.UpdateProxyBindingsWithHistory([]*models.ProxyBindingHistory{history}); err != nil {
 			s.logger.Error("Failed to update proxy for account",
 				zap.Uint64("user_id", userID),
 				zap.Uint64("account_id", accountID),
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/config"
+	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/repository"
+	"tg_cloud_server/internal/telegram"
+)
+
+// adminRecentLimit 总览中最近错误和事件的条数
+const adminRecentLimit = 20
+
+// SchedulerStatsProvider 提供调度器整体状态和实际使用的设置，由任务调度器实现
+type SchedulerStatsProvider interface {
+	GetSchedulerStats() map[string]interface{}
+	GetRuntimeSettings() map[string]interface{}
+}
+
+// AdminService 管理员服务接口，提供跨用户的系统视图
+type AdminService interface {
+	GetOverview(ctx context.Context) (*models.AdminOverview, error)
+	GetEffectiveConfig(ctx context.Context) (*models.AdminEffectiveConfig, error)
+}
+
+// adminService 管理员服务实现
+type adminService struct {
+	adminRepo      repository.AdminRepository
+	connectionPool *telegram.ConnectionPool
+	scheduler      SchedulerStatsProvider
+	logger         *zap.Logger
+}
+
+// NewAdminService 创建管理员服务
+func NewAdminService(adminRepo repository.AdminRepository, connectionPool *telegram.ConnectionPool, scheduler SchedulerStatsProvider) AdminService {
+	return &adminService{
+		adminRepo:      adminRepo,
+		connectionPool: connectionPool,
+		scheduler:      scheduler,
+		logger:         logger.Get().Named("admin_service"),
+	}
+}
+
+// GetOverview 汇总所有用户的账号/任务/代理统计、调度器队列、连接池状态及最近的错误和风控事件
+func (s *adminService) GetOverview(ctx context.Context) (*models.AdminOverview, error) {
+	users, accounts, tasks, proxies, err := s.adminRepo.CountAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count totals: %w", err)
+	}
+
+	overview := &models.AdminOverview{
+		TotalUsers:    users,
+		TotalAccounts: accounts,
+		TotalTasks:    tasks,
+		TotalProxies:  proxies,
+		GeneratedAt:   time.Now(),
+	}
+
+	// 分项统计失败不影响整体返回
+	if overview.AccountsByStatus, err = s.adminRepo.CountAccountsByStatus(); err != nil {
+		s.logger.Warn("Failed to count accounts by status", zap.Error(err))
+	}
+	if overview.TasksByStatus, err = s.adminRepo.CountTasksByStatus(); err != nil {
+		s.logger.Warn("Failed to count tasks by status", zap.Error(err))
+	}
+	if overview.RecentErrors, err = s.adminRepo.GetRecentErrorLogs(adminRecentLimit); err != nil {
+		s.logger.Warn("Failed to get recent error logs", zap.Error(err))
+	}
+	if overview.RecentEvents, err = s.adminRepo.GetRecentRiskLogs(adminRecentLimit); err != nil {
+		s.logger.Warn("Failed to get recent risk logs", zap.Error(err))
+	}
+
+	if s.scheduler != nil {
+		overview.Scheduler = s.scheduler.GetSchedulerStats()
+	}
+	if s.connectionPool != nil {
+		overview.ConnectionPool = s.connectionPool.GetStats()
+	}
+
+	return overview, nil
+}
+
+// GetEffectiveConfig 返回当前生效的配置及调度器、连接池的运行时设置，密码和密钥已掩码
+func (s *adminService) GetEffectiveConfig(ctx context.Context) (*models.AdminEffectiveConfig, error) {
+	runtime := make(map[string]interface{})
+	if s.scheduler != nil {
+		runtime["scheduler"] = s.scheduler.GetRuntimeSettings()
+	}
+	if s.connectionPool != nil {
+		runtime["connection_pool"] = s.connectionPool.GetRuntimeSettings()
+	}
+
+	return &models.AdminEffectiveConfig{
+		Config:      config.EffectiveSettings(),
+		Runtime:     runtime,
+		GeneratedAt: time.Now(),
+	}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -30,10 +31,11 @@ const (
 // AIService AI服务接口
 type AIService interface {
 	GenerateGroupChatResponse(ctx context.Context, config *GroupChatConfig) (string, error)
+	GenerateGroupChatReply(ctx context.Context, req *models.GroupChatReplyRequest) (string, error)
 	GeneratePrivateMessage(ctx context.Context, config *PrivateMessageConfig) (string, error)
 	AnalyzeSentiment(ctx context.Context, text string) (*SentimentAnalysis, error)
 	ExtractKeywords(ctx context.Context, text string) ([]string, error)
-	GenerateVariations(ctx context.Context, template string, count int) ([]string, error)
+	GenerateVariations(ctx context.Context, template string, count int, sampling models.AISampling) ([]string, error)
 	AgentDecision(ctx context.Context, req *models.AgentDecisionRequest) (*models.AgentDecisionResponse, error)
 	GenerateImage(ctx context.Context, prompt string) (string, error)
 }
@@ -51,6 +53,9 @@ type GroupChatConfig struct {
 	MaxLength    int                    `json:"max_length"`
 	Language     string                 `json:"language"`
 	Context      map[string]interface{} `json:"context"`
+	PostProcess  models.AIPostProcess   `json:"post_process"` // 生成后依次执行的后处理步骤
+
+	models.AISampling
 }
 
 // PrivateMessageConfig 私信AI配置
@@ -64,6 +69,9 @@ type PrivateMessageConfig struct {
 	MaxLength   int                    `json:"max_length"`
 	Language    string                 `json:"language"`
 	Context     map[string]interface{} `json:"context"`
+	PostProcess models.AIPostProcess   `json:"post_process"` // 生成后依次执行的后处理步骤
+
+	models.AISampling
 }
 
 // ChatMessage 聊天消息
@@ -120,8 +128,21 @@ type aiService struct {
 	temperature   float64
 	maxTokens     int
 	topP          float64
+
+	decisionJSONRetries int // 智能体决策返回非法 JSON 时的重试次数
+
+	// 图片生成额度预检: 额度用完后在冷却期内直接返回错误，不再请求接口
+	imageQuotaCooldown  time.Duration
+	imageQuotaExhausted time.Time
+	imageQuotaMu        sync.Mutex
 }
 
+// defaultDecisionJSONRetries 智能体决策 JSON 解析失败时默认重试一次
+const defaultDecisionJSONRetries = 1
+
+// decisionJSONReminder 重试时附加在 Prompt 末尾的提醒
+const decisionJSONReminder = "\n\n注意：上一次的回复不是合法的 JSON。请只输出一个合法的 JSON 对象，不要包含任何解释、Markdown 代码块或其他文字。"
+
 // NewAIService 创建AI服务
 func NewAIService(provider AIProvider, config map[string]interface{}) AIService {
 	service := &aiService{
@@ -135,6 +156,8 @@ func NewAIService(provider AIProvider, config map[string]interface{}) AIService
 		temperature:   0.7,
 		maxTokens:     1000,
 		topP:          1.0,
+
+		decisionJSONRetries: defaultDecisionJSONRetries,
 	}
 
 	// 从配置中加载API密钥
@@ -163,6 +186,12 @@ func NewAIService(provider AIProvider, config map[string]interface{}) AIService
 	if url, ok := config["custom_api_url"].(string); ok {
 		service.customAPIURL = url
 	}
+	if retries, ok := config["decision_json_retries"].(int); ok && retries >= 0 {
+		service.decisionJSONRetries = retries
+	}
+	if cooldown, ok := config["image_quota_cooldown"].(time.Duration); ok && cooldown >= 0 {
+		service.imageQuotaCooldown = cooldown
+	}
 
 	service.logger.Info("AI service created",
 		zap.String("provider", string(provider)),
@@ -181,11 +210,15 @@ func (s *aiService) GenerateGroupChatResponse(ctx context.Context, config *Group
 		zap.String("group_name", config.GroupName),
 		zap.String("ai_persona", config.AIPersona))
 
+	if err := config.PostProcess.Validate(); err != nil {
+		return "", err
+	}
+
 	// 构建上下文
 	contextPrompt := s.buildGroupChatContext(config)
 
 	// 生成回复
-	response, err := s.generateResponse(ctx, contextPrompt, config.MaxLength)
+	response, err := s.generateResponse(ctx, contextPrompt, config.MaxLength, config.AISampling)
 	if err != nil {
 		s.logger.Error("Failed to generate group chat response", zap.Error(err))
 		return "", err
@@ -193,6 +226,7 @@ func (s *aiService) GenerateGroupChatResponse(ctx context.Context, config *Group
 
 	// 后处理：确保回复符合群聊场景
 	processedResponse := s.postProcessGroupChatResponse(response, config)
+	processedResponse = config.PostProcess.Apply(processedResponse)
 
 	s.logger.Info("Group chat response generated successfully",
 		zap.Int("response_length", len(processedResponse)))
@@ -200,6 +234,20 @@ func (s *aiService) GenerateGroupChatResponse(ctx context.Context, config *Group
 	return processedResponse, nil
 }
 
+// GenerateGroupChatReply 为 AI炒群任务生成群聊回复
+func (s *aiService) GenerateGroupChatReply(ctx context.Context, req *models.GroupChatReplyRequest) (string, error) {
+	return s.GenerateGroupChatResponse(ctx, &GroupChatConfig{
+		GroupName:    req.GroupName,
+		GroupTopic:   req.GroupTopic,
+		ChatHistory:  req.ChatHistory,
+		AIPersona:    req.Persona,
+		ResponseType: req.ResponseType,
+		MaxLength:    req.MaxLength,
+		PostProcess:  req.PostProcess,
+		AISampling:   req.AISampling,
+	})
+}
+
 // GeneratePrivateMessage 生成私信消息
 func (s *aiService) GeneratePrivateMessage(ctx context.Context, config *PrivateMessageConfig) (string, error) {
 	s.logger.Info("Generating private message",
@@ -207,11 +255,15 @@ func (s *aiService) GeneratePrivateMessage(ctx context.Context, config *PrivateM
 		zap.String("industry", config.Industry),
 		zap.String("tone", config.Tone))
 
+	if err := config.PostProcess.Validate(); err != nil {
+		return "", err
+	}
+
 	// 构建消息上下文
 	contextPrompt := s.buildPrivateMessageContext(config)
 
 	// 生成消息
-	response, err := s.generateResponse(ctx, contextPrompt, config.MaxLength)
+	response, err := s.generateResponse(ctx, contextPrompt, config.MaxLength, config.AISampling)
 	if err != nil {
 		s.logger.Error("Failed to generate private message", zap.Error(err))
 		return "", err
@@ -219,6 +271,7 @@ func (s *aiService) GeneratePrivateMessage(ctx context.Context, config *PrivateM
 
 	// 变量替换
 	processedMessage := s.replaceVariables(response, config.Variables)
+	processedMessage = config.PostProcess.Apply(processedMessage)
 
 	s.logger.Info("Private message generated successfully",
 		zap.Int("message_length", len(processedMessage)))
@@ -253,7 +306,7 @@ func (s *aiService) ExtractKeywords(ctx context.Context, text string) ([]string,
 }
 
 // GenerateVariations 生成变体消息
-func (s *aiService) GenerateVariations(ctx context.Context, template string, count int) ([]string, error) {
+func (s *aiService) GenerateVariations(ctx context.Context, template string, count int, sampling models.AISampling) ([]string, error) {
 	s.logger.Info("Generating message variations",
 		zap.String("template_preview", template[:min(len(template), 50)]),
 		zap.Int("count", count))
@@ -263,7 +316,7 @@ func (s *aiService) GenerateVariations(ctx context.Context, template string, cou
 	for i := 0; i < count; i++ {
 		prompt := fmt.Sprintf("请基于以下模板生成一个不同的表达方式，保持相同的意思但使用不同的词汇和句式：\n%s", template)
 
-		variation, err := s.generateResponse(ctx, prompt, len(template)*2)
+		variation, err := s.generateResponse(ctx, prompt, len(template)*2, sampling)
 		if err != nil {
 			s.logger.Error("Failed to generate variation", zap.Int("index", i), zap.Error(err))
 			continue
@@ -284,35 +337,91 @@ func (s *aiService) AgentDecision(ctx context.Context, req *models.AgentDecision
 	// 构建Prompt
 	prompt := s.buildAgentDecisionPrompt(req)
 
-	// 调用AI生成决策
-	responseJSON, err := s.generateResponse(ctx, prompt, 1000)
-	if err != nil {
-		return nil, err
-	}
+	// 调用AI生成决策，返回非法 JSON 时附加提醒重试
+	for attempt := 0; ; attempt++ {
+		responseJSON, err := s.generateResponse(ctx, prompt, 1000, req.AISampling)
+		if err != nil {
+			return nil, err
+		}
 
-	// 解析JSON响应
-	// 这里假设AI返回的是合法的JSON字符串
-	// 实际生产中可能需要更鲁棒的解析逻辑，处理Markdown代码块等
-	cleanJSON := strings.TrimSpace(responseJSON)
-	if strings.HasPrefix(cleanJSON, "```json") {
-		cleanJSON = strings.TrimPrefix(cleanJSON, "```json")
-		cleanJSON = strings.TrimSuffix(cleanJSON, "```")
-	} else if strings.HasPrefix(cleanJSON, "```") {
-		cleanJSON = strings.TrimPrefix(cleanJSON, "```")
-		cleanJSON = strings.TrimSuffix(cleanJSON, "```")
-	}
-	cleanJSON = strings.TrimSpace(cleanJSON)
+		var decision models.AgentDecisionResponse
+		parseErr := json.Unmarshal([]byte(extractJSONObject(responseJSON)), &decision)
+		if parseErr == nil {
+			if decision.Content != "" {
+				decision.Content = req.PostProcess.Apply(decision.Content)
+			}
+			return &decision, nil
+		}
 
-	var decision models.AgentDecisionResponse
-	if err := json.Unmarshal([]byte(cleanJSON), &decision); err != nil {
-		s.logger.Error("Failed to parse agent decision",
-			zap.String("response", responseJSON),
-			zap.Error(err))
-		// 降级处理：如果不说话
-		return &models.AgentDecisionResponse{ShouldSpeak: false}, nil
+		s.logger.Warn("Failed to parse agent decision",
+			zap.Int("attempt", attempt+1),
+			zap.String("raw_response", responseJSON),
+			zap.Error(parseErr))
+
+		if attempt >= s.decisionJSONRetries {
+			// 降级处理：不说话
+			s.logger.Error("Agent decision is not valid JSON, giving up",
+				zap.Int("attempts", attempt+1),
+				zap.String("persona", req.AgentPersona))
+			return &models.AgentDecisionResponse{ShouldSpeak: false}, nil
+		}
+		if attempt == 0 {
+			prompt += decisionJSONReminder
+		}
 	}
+}
 
-	return &decision, nil
+// extractJSONObject 从模型输出中提取 JSON 对象：去除 Markdown 代码块，并定位第一个完整的 {...}
+func extractJSONObject(raw string) string {
+	text := strings.TrimSpace(raw)
+
+	// 去除代码块标记，代码块前后可能还有说明文字
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		if nl := strings.Index(body, "\n"); nl >= 0 && !strings.Contains(body[:nl], "{") {
+			body = body[nl+1:] // 跳过 ```json 等语言标记
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		text = strings.TrimSpace(body)
+	}
+
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return text
+	}
+
+	// 按括号深度找到与第一个 { 匹配的 }，忽略字符串中的括号
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return text[start : i+1]
+			}
+		}
+	}
+	return text[start:]
 }
 
 // OpenAI Image Generation Request
@@ -329,16 +438,24 @@ type openAIImageResponse struct {
 	} `json:"data"`
 	Error *struct {
 		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
 	} `json:"error"`
 }
 
 // GenerateImage 生成图片
+// 未配置 key 返回 models.ErrImageNoAPIKey（需要时可改用 models.PlaceholderImageURL），
+// 额度不足返回 models.ErrImageQuotaExceeded，描述违规返回 models.ErrImageContentPolicy
 func (s *aiService) GenerateImage(ctx context.Context, prompt string) (string, error) {
 	s.logger.Info("Generating image", zap.String("prompt", prompt))
 
 	if s.openAIKey == "" {
-		s.logger.Warn("OpenAI key is missing, using placeholder image")
-		return "https://via.placeholder.com/1024x1024.png?text=AI+Generated+Image", nil
+		s.logger.Warn("OpenAI key is missing, image generation unavailable")
+		return "", models.ErrImageNoAPIKey
+	}
+
+	if until, exhausted := s.imageQuotaExhaustedUntil(); exhausted {
+		return "", fmt.Errorf("%w: retry after %s", models.ErrImageQuotaExceeded, until.Format(time.RFC3339))
 	}
 
 	reqBody := openAIImageRequest{
@@ -373,7 +490,15 @@ func (s *aiService) GenerateImage(ctx context.Context, prompt string) (string, e
 	}
 
 	if result.Error != nil {
-		return "", fmt.Errorf("openai image api error: %s", result.Error.Message)
+		switch {
+		case result.Error.Code == "content_policy_violation":
+			return "", fmt.Errorf("%w: %s", models.ErrImageContentPolicy, result.Error.Message)
+		case result.Error.Code == "insufficient_quota" || result.Error.Code == "billing_hard_limit_reached" ||
+			result.Error.Type == "insufficient_quota":
+			s.markImageQuotaExhausted()
+			return "", fmt.Errorf("%w: %s", models.ErrImageQuotaExceeded, result.Error.Message)
+		}
+		return "", fmt.Errorf("openai image api error (status %d): %s", resp.StatusCode, result.Error.Message)
 	}
 
 	if len(result.Data) > 0 {
@@ -383,6 +508,26 @@ func (s *aiService) GenerateImage(ctx context.Context, prompt string) (string, e
 	return "", fmt.Errorf("no image generated")
 }
 
+// imageQuotaExhaustedUntil 预检额度状态，冷却期内返回冷却结束时间
+func (s *aiService) imageQuotaExhaustedUntil() (time.Time, bool) {
+	s.imageQuotaMu.Lock()
+	defer s.imageQuotaMu.Unlock()
+	if s.imageQuotaCooldown <= 0 || s.imageQuotaExhausted.IsZero() {
+		return time.Time{}, false
+	}
+	until := s.imageQuotaExhausted.Add(s.imageQuotaCooldown)
+	return until, time.Now().Before(until)
+}
+
+// markImageQuotaExhausted 记录额度用完的时间，冷却期内的请求不再调用接口
+func (s *aiService) markImageQuotaExhausted() {
+	s.imageQuotaMu.Lock()
+	s.imageQuotaExhausted = time.Now()
+	s.imageQuotaMu.Unlock()
+	s.logger.Warn("OpenAI image quota exhausted",
+		zap.Duration("cooldown", s.imageQuotaCooldown))
+}
+
 // buildAgentDecisionPrompt 构建智能体决策Prompt
 func (s *aiService) buildAgentDecisionPrompt(req *models.AgentDecisionRequest) string {
 	var sb strings.Builder
@@ -396,6 +541,13 @@ func (s *aiService) buildAgentDecisionPrompt(req *models.AgentDecisionRequest) s
 		sb.WriteString(fmt.Sprintf("你想达成的目标：%s\n", req.AgentGoal))
 	}
 
+	if req.Memory != "" {
+		sb.WriteString("\n【你的过往记忆】\n")
+		sb.WriteString("以下是你之前在这个群里说过的话，保持前后一致，不要重复：\n")
+		sb.WriteString(req.Memory)
+		sb.WriteString("\n")
+	}
+
 	sb.WriteString("\n【最近聊天】\n")
 	if len(req.ChatHistory) == 0 {
 		sb.WriteString("(群里还没人说话)\n")
@@ -407,11 +559,30 @@ func (s *aiService) buildAgentDecisionPrompt(req *models.AgentDecisionRequest) s
 
 	sb.WriteString("\n【决策要求】\n")
 	sb.WriteString("判断现在要不要说话，输出JSON格式：\n")
+	fields := []string{
+		"\"should_speak\": true/false,  // 要不要发言",
+		"\"thought\": \"简短理由\",",
+		"\"content\": \"发言内容\",  // should_speak=true时填写",
+	}
+	if req.ImageGenEnabled {
+		fields[2] = "\"content\": \"发言内容\",  // should_speak=true时填写，配图时作为图片说明"
+		fields = append(fields,
+			"\"action\": \"send_text\",  // send_text 只发文字；generate_photo 生成一张图片配合发言，偶尔使用",
+			"\"image_prompt\": \"\",  // action=generate_photo 时填写，用一句话描述要生成的图片",
+		)
+	}
+	fields = append(fields, "\"delay_seconds\": 3,  // 延迟几秒发送(2-8)")
+	if req.GoalDetection && req.AgentGoal != "" {
+		fields = append(fields, "\"goal_complete\": false,  // 根据聊天记录判断你的目标已经达成时为 true，之后你将不再发言")
+	}
+	// 最后一个字段去掉逗号
+	last := fields[len(fields)-1]
+	fields[len(fields)-1] = strings.Replace(last, ",  //", "  //", 1)
+
 	sb.WriteString("{\n")
-	sb.WriteString("  \"should_speak\": true/false,  // 要不要发言\n")
-	sb.WriteString("  \"thought\": \"简短理由\",\n")
-	sb.WriteString("  \"content\": \"发言内容\",  // should_speak=true时填写\n")
-	sb.WriteString("  \"delay_seconds\": 3  // 延迟几秒发送(2-8)\n")
+	for _, field := range fields {
+		sb.WriteString("  " + field + "\n")
+	}
 	sb.WriteString("}\n")
 
 	sb.WriteString("\n【说话风格】\n")
@@ -483,16 +654,31 @@ func (s *aiService) buildPrivateMessageContext(config *PrivateMessageConfig) str
 }
 
 // generateResponse 生成AI回复的核心方法
-func (s *aiService) generateResponse(ctx context.Context, prompt string, maxLength int) (string, error) {
+// sampling 中设置的 temperature/top_p 覆盖服务默认值
+func (s *aiService) generateResponse(ctx context.Context, prompt string, maxLength int, sampling models.AISampling) (string, error) {
+	temperature, topP := s.temperature, s.topP
+	if sampling.Temperature != nil {
+		temperature = *sampling.Temperature
+	}
+	if sampling.TopP != nil {
+		topP = *sampling.TopP
+	}
+
+	s.logger.Info("Generating AI response",
+		zap.String("provider", string(s.provider)),
+		zap.Float64("temperature", temperature),
+		zap.Float64("top_p", topP),
+		zap.Int("max_length", maxLength))
+
 	switch s.provider {
 	case ProviderOpenAI:
-		return s.generateOpenAIResponse(ctx, prompt, maxLength)
+		return s.generateOpenAIResponse(ctx, prompt, maxLength, temperature, topP)
 	case ProviderGemini:
-		return s.generateGeminiResponse(ctx, prompt, maxLength)
+		return s.generateGeminiResponse(ctx, prompt, maxLength, temperature, topP)
 	case ProviderClaude:
 		return s.generateClaudeResponse(ctx, prompt, maxLength)
 	case ProviderDeepSeek:
-		return s.generateDeepSeekResponse(ctx, prompt, maxLength)
+		return s.generateDeepSeekResponse(ctx, prompt, maxLength, temperature, topP)
 	case ProviderLocal:
 		return s.generateLocalResponse(ctx, prompt, maxLength)
 	case ProviderCustom:
@@ -507,6 +693,7 @@ type openAIChatRequest struct {
 	Model       string          `json:"model"`
 	Messages    []openAIMessage `json:"messages"`
 	Temperature float64         `json:"temperature"`
+	TopP        float64         `json:"top_p"`
 	MaxTokens   int             `json:"max_tokens"`
 }
 
@@ -527,7 +714,7 @@ type openAIChatResponse struct {
 }
 
 // generateOpenAIResponse 调用OpenAI API
-func (s *aiService) generateOpenAIResponse(ctx context.Context, prompt string, maxLength int) (string, error) {
+func (s *aiService) generateOpenAIResponse(ctx context.Context, prompt string, maxLength int, temperature, topP float64) (string, error) {
 	if s.openAIKey == "" {
 		return "", fmt.Errorf("OpenAI API key is not configured")
 	}
@@ -537,7 +724,8 @@ func (s *aiService) generateOpenAIResponse(ctx context.Context, prompt string, m
 		Messages: []openAIMessage{
 			{Role: "user", Content: prompt},
 		},
-		Temperature: s.temperature,
+		Temperature: temperature,
+		TopP:        topP,
 		MaxTokens:   maxLength,
 	}
 
@@ -591,7 +779,7 @@ type geminiPart struct {
 }
 
 type geminiGenerationConfig struct {
-	Temperature     float64 `json:"temperature,omitempty"`
+	Temperature     float64 `json:"temperature"` // 不能 omitempty，否则 0 会被当作未设置
 	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
 	TopP            float64 `json:"topP,omitempty"`
 }
@@ -611,7 +799,7 @@ type geminiResponse struct {
 }
 
 // generateGeminiResponse 调用Gemini API
-func (s *aiService) generateGeminiResponse(ctx context.Context, prompt string, maxLength int) (string, error) {
+func (s *aiService) generateGeminiResponse(ctx context.Context, prompt string, maxLength int, temperature, topP float64) (string, error) {
 	if s.geminiKey == "" {
 		return "", fmt.Errorf("Gemini API key is not configured")
 	}
@@ -625,9 +813,9 @@ func (s *aiService) generateGeminiResponse(ctx context.Context, prompt string, m
 			},
 		},
 		GenerationConfig: geminiGenerationConfig{
-			Temperature:     s.temperature,
+			Temperature:     temperature,
 			MaxOutputTokens: maxLength,
-			TopP:            s.topP,
+			TopP:            topP,
 		},
 	}
 
@@ -676,7 +864,7 @@ func (s *aiService) generateClaudeResponse(ctx context.Context, prompt string, m
 }
 
 // generateDeepSeekResponse 调用DeepSeek API (兼容OpenAI格式)
-func (s *aiService) generateDeepSeekResponse(ctx context.Context, prompt string, maxLength int) (string, error) {
+func (s *aiService) generateDeepSeekResponse(ctx context.Context, prompt string, maxLength int, temperature, topP float64) (string, error) {
 	if s.deepSeekKey == "" {
 		return "", fmt.Errorf("DeepSeek API key is not configured")
 	}
@@ -686,7 +874,8 @@ func (s *aiService) generateDeepSeekResponse(ctx context.Context, prompt string,
 		Messages: []openAIMessage{
 			{Role: "user", Content: prompt},
 		},
-		Temperature: s.temperature,
+		Temperature: temperature,
+		TopP:        topP,
 		MaxTokens:   maxLength,
 	}
 
@@ -780,12 +969,7 @@ func (s *aiService) postProcessGroupChatResponse(response string, config *GroupC
 }
 
 func (s *aiService) replaceVariables(message string, variables map[string]string) string {
-	result := message
-	for key, value := range variables {
-		placeholder := fmt.Sprintf("{{%s}}", key)
-		result = strings.ReplaceAll(result, placeholder, value)
-	}
-	return result
+	return models.ReplaceTemplateVariables(message, variables)
 }
 
 func (s *aiService) detectSentiment(text string) string {
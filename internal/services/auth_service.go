@@ -86,6 +86,7 @@ func (s *AuthService) Register(req *models.RegisterRequest) (*models.UserProfile
 		Username:    user.Username,
 		Email:       user.Email,
 		Role:        user.Role,
+		IsAdmin:     user.IsAdmin(),
 		IsActive:    user.IsActive,
 		IsExpired:   user.IsExpired(),
 		ExpiresAt:   user.ExpiresAt,
@@ -173,6 +174,7 @@ func (s *AuthService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 		Username:    user.Username,
 		Email:       user.Email,
 		Role:        user.Role,
+		IsAdmin:     user.IsAdmin(),
 		IsActive:    user.IsActive,
 		IsExpired:   user.IsExpired(),
 		ExpiresAt:   user.ExpiresAt,
@@ -216,6 +218,7 @@ func (s *AuthService) GetUserProfile(userID uint64) (*models.UserProfile, error)
 		Username:    user.Username,
 		Email:       user.Email,
 		Role:        user.Role,
+		IsAdmin:     user.IsAdmin(),
 		IsActive:    user.IsActive,
 		IsExpired:   user.IsExpired(),
 		ExpiresAt:   user.ExpiresAt,
@@ -335,6 +338,7 @@ func (s *AuthService) RefreshToken(refreshToken string) (*models.LoginResponse,
 		Username:    user.Username,
 		Email:       user.Email,
 		Role:        user.Role,
+		IsAdmin:     user.IsAdmin(),
 		IsActive:    user.IsActive,
 		IsExpired:   user.IsExpired(),
 		ExpiresAt:   user.ExpiresAt,
@@ -0,0 +1,533 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 导出格式
+const (
+	ExportFormatJSON  = "json"
+	ExportFormatCSV   = "csv"
+	ExportFormatExcel = "excel"
+)
+
+// xlsxContentType Excel 文件的 MIME 类型
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// exportFileExt 导出格式对应的文件扩展名，未知格式按 json 导出
+func exportFileExt(format string) string {
+	switch format {
+	case ExportFormatCSV:
+		return "csv"
+	case ExportFormatExcel:
+		return "xlsx"
+	default:
+		return "json"
+	}
+}
+
+// ExportContentType 按导出文件扩展名返回 MIME 类型
+func ExportContentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "text/csv; charset=utf-8"
+	case ".xlsx":
+		return xlsxContentType
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// exportWriter 逐条写入导出记录，Close 写入文件结尾
+type exportWriter interface {
+	Write(record interface{}, row []interface{}) error
+	Close() error
+}
+
+// newExportWriter 按导出格式创建写入器：json 格式写入原始记录，csv 和 excel 写入表格行
+func newExportWriter(w io.Writer, format, sheet string, headers []string) (exportWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVExportWriter(w, headers)
+	case ExportFormatExcel:
+		return newXLSXWriter(w, sheet, headers)
+	default:
+		return newJSONExportWriter(w)
+	}
+}
+
+// jsonExportWriter 逐条写入 JSON 数组，格式与整体缩进编码一致
+type jsonExportWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONExportWriter(w io.Writer) (*jsonExportWriter, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, fmt.Errorf("failed to write json: %w", err)
+	}
+	return &jsonExportWriter{w: w}, nil
+}
+
+func (j *jsonExportWriter) Write(record interface{}, _ []interface{}) error {
+	data, err := json.MarshalIndent(record, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode json: %w", err)
+	}
+	separator := ",\n  "
+	if j.count == 0 {
+		separator = "\n  "
+	}
+	j.count++
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	if _, err := j.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
+
+func (j *jsonExportWriter) Close() error {
+	end := "]\n"
+	if j.count > 0 {
+		end = "\n]\n"
+	}
+	if _, err := io.WriteString(j.w, end); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
+
+// csvExportWriter 写入 CSV，包含逗号、引号或换行的字段按 RFC 4180 加引号转义
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVExportWriter(out io.Writer, headers []string) (*csvExportWriter, error) {
+	w := csv.NewWriter(out)
+	if err := w.Write(headers); err != nil {
+		return nil, fmt.Errorf("failed to write csv header: %w", err)
+	}
+	return &csvExportWriter{w: w, record: make([]string, len(headers))}, nil
+}
+
+func (c *csvExportWriter) Write(_ interface{}, row []interface{}) error {
+	for i := range c.record {
+		c.record[i] = ""
+		if i < len(row) {
+			c.record[i] = csvCellValue(row[i])
+		}
+	}
+	if err := c.w.Write(c.record); err != nil {
+		return fmt.Errorf("failed to write csv row: %w", err)
+	}
+	return nil
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+// csvCellValue 单元格的文本形式，时间统一为 2006-01-02 15:04:05
+func csvCellValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// xlsx 单元格样式下标，对应 styles.xml 中 cellXfs 的顺序
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1 // 加粗、灰色底纹
+	xlsxStyleDate    = 2 // yyyy-mm-dd hh:mm:ss
+)
+
+// xlsxWriter 逐行生成单个工作表的 Excel 文件，表头加粗并冻结，数字和时间写为对应类型的单元格
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+// newXLSXWriter 写入工作簿结构和表头，之后通过 Write 逐行追加数据
+func newXLSXWriter(out io.Writer, sheetName string, headers []string) (*xlsxWriter, error) {
+	zw := zip.NewWriter(out)
+	name := xlsxSheetName(sheetName, 0)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			fmt.Sprintf(`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`, xmlEscape(name))},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`},
+		{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+			`<fill><patternFill patternType="solid"><fgColor rgb="FFD9D9D9"/><bgColor indexed="64"/></patternFill></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", f.name, err)
+		}
+		if _, err := io.WriteString(w, f.content); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+
+	sheetFile, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xl/worksheets/sheet1.xml: %w", err)
+	}
+	x := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(sheetFile), row: 1}
+
+	// 首行为表头并冻结
+	x.sheet.WriteString(xml.Header)
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	if len(headers) > 0 {
+		fmt.Fprintf(x.sheet, `<cols><col min="1" max="%d" width="20" customWidth="1"/></cols>`, len(headers))
+	}
+	x.sheet.WriteString(`<sheetData><row r="1">`)
+	for col, header := range headers {
+		writeXLSXCell(x.sheet, xlsxCellRef(col, 1), header, xlsxStyleHeader)
+	}
+	x.sheet.WriteString(`</row>`)
+	return x, nil
+}
+
+// Write 追加一行数据
+func (x *xlsxWriter) Write(_ interface{}, row []interface{}) error {
+	x.row++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for col, value := range row {
+		writeXLSXCell(x.sheet, xlsxCellRef(col, x.row), value, xlsxStyleDefault)
+	}
+	if _, err := x.sheet.WriteString(`</row>`); err != nil {
+		return fmt.Errorf("failed to write xlsx row: %w", err)
+	}
+	return nil
+}
+
+// Close 结束工作表并完成 zip 文件
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return fmt.Errorf("failed to write xlsx sheet: %w", err)
+	}
+	if err := x.zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish xlsx: %w", err)
+	}
+	return nil
+}
+
+// writeXLSXCell 按值的类型写入单元格：数字为数值，时间为带日期格式的序列值，其余为文本
+func writeXLSXCell(b io.Writer, ref string, value interface{}, style int) {
+	if t, ok := value.(*time.Time); ok {
+		if t == nil {
+			return
+		}
+		value = *t
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return
+	case time.Time:
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, strconv.FormatFloat(excelSerial(v), 'f', -1, 64))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%d</v></c>`, ref, style, v)
+	case float32:
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(float64(v), 'f', -1, 32))
+	case float64:
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		boolValue := 0
+		if v {
+			boolValue = 1
+		}
+		fmt.Fprintf(b, `<c r="%s" s="%d" t="b"><v>%d</v></c>`, ref, style, boolValue)
+	default:
+		fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(fmt.Sprintf("%v", v)))
+	}
+}
+
+// excelSerial 将时间转换为 Excel 日期序列值（1899-12-30 起的天数），按本地时区显示
+func excelSerial(t time.Time) float64 {
+	local := t.Local()
+	_, offset := local.Zone()
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return local.Add(time.Duration(offset)*time.Second).UTC().Sub(epoch).Hours() / 24
+}
+
+// xlsxCellRef 返回单元格坐标，如 (0, 1) -> A1
+func xlsxCellRef(col, row int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name + strconv.Itoa(row)
+}
+
+// xlsxSheetName 工作表名称不能为空、不超过31个字符且不能包含 []:*?/\
+func xlsxSheetName(name string, index int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", index+1)
+	}
+	return name
+}
+
+// xmlEscape 转义 XML 文本并去除 XML 不允许的控制字符
+func xmlEscape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// 导出文件存储默认值
+const (
+	defaultExportTTL = 24 * time.Hour
+	exportDirName    = "tg_cloud_exports"
+)
+
+// exportFileNamePattern 导出文件名格式 <数据类型>_<任务ID>_<时间戳>.<扩展名>，清理和下载只处理该格式的文件
+var exportFileNamePattern = regexp.MustCompile(`^[a-z]+_\d+_\d+\.(json|csv|xlsx)$`)
+
+// isExportFileName 判断文件名是否为导出文件
+func isExportFileName(name string) bool {
+	return exportFileNamePattern.MatchString(name)
+}
+
+// SetExportStorage 设置导出文件目录和保留时间，并启动过期文件清理。dir 为空时使用系统临时目录
+func (s *batchService) SetExportStorage(dir string, ttl time.Duration) {
+	if dir != "" {
+		s.exportDir = dir
+	}
+	if ttl > 0 {
+		s.exportTTL = ttl
+	}
+	if s.exportCleanupStop == nil {
+		s.exportCleanupStop = make(chan struct{})
+		go s.exportCleanupLoop(s.exportCleanupStop)
+	}
+}
+
+// Stop 停止导出文件清理
+func (s *batchService) Stop() {
+	if s.exportCleanupStop != nil {
+		close(s.exportCleanupStop)
+		s.exportCleanupStop = nil
+	}
+}
+
+// createExportFile 在导出目录中按任务创建文件，返回文件和相对导出目录的文件名
+func (s *batchService) createExportFile(jobID uint64, baseName, format string) (*os.File, string, error) {
+	if err := os.MkdirAll(s.exportDir, 0o750); err != nil {
+		return nil, "", fmt.Errorf("failed to create export dir: %w", err)
+	}
+	name := fmt.Sprintf("%s_%d_%d.%s", baseName, jobID, time.Now().Unix(), exportFileExt(format))
+	file, err := os.OpenFile(filepath.Join(s.exportDir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	return file, name, nil
+}
+
+// exportPage 读取第 page 页（从 1 开始）导出数据，返回原始记录（json）、对应的表格行（csv/excel）和匹配总数
+type exportPage func(page int) (records []interface{}, rows [][]interface{}, total int64, err error)
+
+// writeExportFile 分页读取数据并逐页写入导出文件，内存中只保留当前页；返回写入任务结果的文件信息（不包含数据本身）
+func (s *batchService) writeExportFile(ctx context.Context, jobID uint64, dataType, format, sheet string, headers []string, fetch exportPage) (map[string]interface{}, error) {
+	file, name, err := s.createExportFile(jobID, dataType, format)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.exportDir, name)
+
+	out := bufio.NewWriter(file)
+	exported, total, writeErr := s.streamExport(ctx, jobID, out, format, sheet, headers, fetch)
+	if writeErr == nil {
+		writeErr = out.Flush()
+	}
+	closeErr := file.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(path)
+		return nil, writeErr
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat export file: %w", err)
+	}
+	now := time.Now()
+	return map[string]interface{}{
+		"success":          true,
+		"data_type":        dataType,
+		"format":           format,
+		"exported_records": exported,
+		"total_records":    total,
+		"filename":         name,
+		"size":             info.Size(),
+		"download_url":     fmt.Sprintf("/api/v1/batch-jobs/%d/download", jobID),
+		"exported_at":      now,
+		"expires_at":       now.Add(s.exportTTL),
+	}, nil
+}
+
+// streamExport 逐页读取并写入导出数据，每页更新一次进度
+func (s *batchService) streamExport(ctx context.Context, jobID uint64, out io.Writer, format, sheet string, headers []string, fetch exportPage) (int, int64, error) {
+	w, err := newExportWriter(out, format, sheet, headers)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	exported := 0
+	var total int64
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return exported, total, err
+		}
+		records, rows, pageTotal, err := fetch(page)
+		if err != nil {
+			return exported, total, err
+		}
+		total = pageTotal
+		for i, record := range records {
+			if err := w.Write(record, rows[i]); err != nil {
+				return exported, total, err
+			}
+		}
+		exported += len(records)
+		s.updateExportProgress(ctx, jobID, page == 1, exported, total)
+		if len(records) < exportPageSize || int64(exported) >= total {
+			break
+		}
+	}
+	return exported, total, w.Close()
+}
+
+// exportCleanupLoop 定期删除超过保留时间的导出文件
+func (s *batchService) exportCleanupLoop(stop <-chan struct{}) {
+	interval := s.exportTTL / 4
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.cleanupExportFiles()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.cleanupExportFiles()
+		}
+	}
+}
+
+// cleanupExportFiles 删除导出目录中超过保留时间的导出文件，目录中的其他文件不受影响
+func (s *batchService) cleanupExportFiles() {
+	entries, err := os.ReadDir(s.exportDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Warn("Failed to read export dir", zap.String("dir", s.exportDir), zap.Error(err))
+		}
+		return
+	}
+
+	cutoff := time.Now().Add(-s.exportTTL)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !isExportFileName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.exportDir, entry.Name())); err != nil {
+			s.logger.Warn("Failed to remove expired export file", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		s.logger.Info("Expired export files removed", zap.Int("count", removed))
+	}
+}
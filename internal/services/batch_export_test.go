@@ -0,0 +1,149 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// xlsxTestSheet 工作表中读取测试需要的部分
+type xlsxTestSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Style  int    `xml:"s,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxTestStyles 样式表中读取测试需要的部分
+type xlsxTestStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+func readXLSXPart(t *testing.T, zr *zip.Reader, name string, v interface{}) {
+	t.Helper()
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+}
+
+func TestWriteXLSXTypedCells(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.Local)
+	headers := []string{"ID", "Phone", "Score", "Online", "Created At"}
+	rows := [][]interface{}{
+		{uint64(42), "+15550001, \"a\" <b>", 87.5, true, createdAt},
+		{uint64(43), "+15550002", 0.0, false, (*time.Time)(nil)},
+	}
+
+	var buf bytes.Buffer
+	w, err := newXLSXWriter(&buf, "accounts", headers)
+	if err != nil {
+		t.Fatalf("newXLSXWriter: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.Write(nil, row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("xlsx is not a zip archive: %v", err)
+	}
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if _, err := zr.Open(part); err != nil {
+			t.Fatalf("missing part %s: %v", part, err)
+		}
+	}
+
+	var styles xlsxTestStyles
+	readXLSXPart(t, zr, "xl/styles.xml", &styles)
+	var sheet xlsxTestSheet
+	readXLSXPart(t, zr, "xl/worksheets/sheet1.xml", &sheet)
+
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(sheet.Rows))
+	}
+	for i, cell := range sheet.Rows[0].Cells {
+		if cell.Type != "inlineStr" || cell.Inline != headers[i] {
+			t.Errorf("header %s = %q (t=%q), want %q", cell.Ref, cell.Inline, cell.Type, headers[i])
+		}
+	}
+
+	row := sheet.Rows[1].Cells
+	if len(row) != 5 {
+		t.Fatalf("got %d cells in first data row, want 5", len(row))
+	}
+	// 数字单元格没有类型属性，值可按数值解析
+	if row[0].Type != "" || row[0].Value != "42" {
+		t.Errorf("id cell = %q (t=%q), want numeric 42", row[0].Value, row[0].Type)
+	}
+	if row[1].Type != "inlineStr" || row[1].Inline != "+15550001, \"a\" <b>" {
+		t.Errorf("phone cell = %q (t=%q), want escaped text round trip", row[1].Inline, row[1].Type)
+	}
+	if score, err := strconv.ParseFloat(row[2].Value, 64); row[2].Type != "" || err != nil || score != 87.5 {
+		t.Errorf("score cell = %q (t=%q), want numeric 87.5", row[2].Value, row[2].Type)
+	}
+	if row[3].Type != "b" || row[3].Value != "1" {
+		t.Errorf("online cell = %q (t=%q), want boolean 1", row[3].Value, row[3].Type)
+	}
+
+	// 时间单元格为数值序列值，样式指向日期格式
+	dateCell := row[4]
+	if dateCell.Type != "" {
+		t.Fatalf("date cell type = %q, want numeric", dateCell.Type)
+	}
+	if dateCell.Style >= len(styles.CellXfs) {
+		t.Fatalf("date cell style %d out of range", dateCell.Style)
+	}
+	numFmtID := styles.CellXfs[dateCell.Style].NumFmtID
+	var formatCode string
+	for _, f := range styles.NumFmts {
+		if f.ID == numFmtID {
+			formatCode = f.Code
+		}
+	}
+	if formatCode != "yyyy-mm-dd hh:mm:ss" {
+		t.Errorf("date cell format = %q (numFmtId %d), want yyyy-mm-dd hh:mm:ss", formatCode, numFmtID)
+	}
+	serial, err := strconv.ParseFloat(dateCell.Value, 64)
+	if err != nil {
+		t.Fatalf("date cell value %q is not numeric: %v", dateCell.Value, err)
+	}
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	got := epoch.Add(time.Duration(math.Round(serial*24*3600)) * time.Second)
+	if want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("date cell reads back as %s, want %s", got, want)
+	}
+
+	// 空时间不写单元格
+	if n := len(sheet.Rows[2].Cells); n != 4 {
+		t.Errorf("got %d cells in second data row, want 4", n)
+	}
+}
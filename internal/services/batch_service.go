@@ -2,11 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"tg_cloud_server/internal/common/logger"
 	"tg_cloud_server/internal/models"
@@ -28,6 +34,7 @@ const (
 	BatchOperationCancelTasks    = models.BatchOperationCancelTasks
 	BatchOperationImportUsers    = models.BatchOperationImportUsers
 	BatchOperationExportData     = models.BatchOperationExportData
+	BatchOperationUploadAccounts = models.BatchOperationUploadAccounts
 )
 
 const (
@@ -38,6 +45,14 @@ const (
 	BatchJobStatusCancelled = models.BatchJobStatusCancelled
 )
 
+var (
+	ErrBatchJobNotFound = errors.New("batch job not found")
+	ErrExportNotReady   = errors.New("export job not ready")
+	ErrNotExportJob     = errors.New("batch job is not an export job")
+	ErrExportExpired    = errors.New("export file expired")
+	ErrBatchJobFinished = errors.New("batch job already finished")
+)
+
 // BatchAccountCreateRequest 批量创建账号请求
 type BatchAccountCreateRequest struct {
 	Accounts []models.CreateAccountRequest `json:"accounts" binding:"required"`
@@ -90,6 +105,7 @@ type ExportDataRequest struct {
 type DateRange struct {
 	StartDate time.Time `json:"start_date"`
 	EndDate   time.Time `json:"end_date"`
+	Field     string    `json:"field"` // 过滤字段：created_at（默认），账号导出还支持 last_used_at
 }
 
 // BatchService 批量操作服务接口
@@ -107,6 +123,7 @@ type BatchService interface {
 	BatchUpdateAccounts(ctx context.Context, userID uint64, req *BatchAccountUpdateRequest) (*BatchJob, error)
 	BatchDeleteAccounts(ctx context.Context, userID uint64, accountIDs []uint64) (*BatchJob, error)
 	BatchBindProxies(ctx context.Context, userID uint64, req *BatchProxyBindRequest) (*BatchJob, error)
+	UploadAccountFile(ctx context.Context, userID uint64, tempDir, filePath string, proxyID *uint64) (*BatchJob, error)
 
 	// 批量任务操作
 	BatchCreateTasks(ctx context.Context, userID uint64, req *BatchTaskCreateRequest) (*BatchJob, error)
@@ -115,6 +132,9 @@ type BatchService interface {
 	// 数据导入导出
 	ImportUsers(ctx context.Context, userID uint64, req *ImportUsersRequest) (*BatchJob, error)
 	ExportData(ctx context.Context, userID uint64, req *ExportDataRequest) (*BatchJob, error)
+	GetExportFile(ctx context.Context, userID uint64, jobID uint64) (io.ReadCloser, string, error)
+	SetExportStorage(dir string, ttl time.Duration)
+	Stop()
 
 	// 进度监控
 	GetJobProgress(ctx context.Context, userID uint64, jobID uint64) (float64, error)
@@ -124,6 +144,8 @@ type BatchService interface {
 // batchService 批量操作服务实现
 type batchService struct {
 	batchRepo      repository.BatchRepository
+	taskRepo       repository.TaskRepository
+	proxyRepo      repository.ProxyRepository
 	accountService *AccountService
 	taskService    *TaskService
 	logger         *zap.Logger
@@ -132,14 +154,24 @@ type batchService struct {
 	runningJobs      map[uint64]*BatchJob
 	runningJobsMutex sync.RWMutex
 
+	// 各任务执行协程的取消函数，CancelBatchJob 通过它通知协程停止
+	jobCancels map[uint64]context.CancelFunc
+
 	// 并发控制
 	maxConcurrency int
 	workerPool     chan struct{}
+
+	// 导出文件存储
+	exportDir         string
+	exportTTL         time.Duration
+	exportCleanupStop chan struct{}
 }
 
 // NewBatchService 创建批量操作服务
 func NewBatchService(
 	batchRepo repository.BatchRepository,
+	taskRepo repository.TaskRepository,
+	proxyRepo repository.ProxyRepository,
 	accountService *AccountService,
 	taskService *TaskService,
 ) BatchService {
@@ -147,12 +179,17 @@ func NewBatchService(
 
 	service := &batchService{
 		batchRepo:      batchRepo,
+		taskRepo:       taskRepo,
+		proxyRepo:      proxyRepo,
 		accountService: accountService,
 		taskService:    taskService,
 		logger:         logger.Get().Named("batch_service"),
 		runningJobs:    make(map[uint64]*BatchJob),
+		jobCancels:     make(map[uint64]context.CancelFunc),
 		maxConcurrency: maxConcurrency,
 		workerPool:     make(chan struct{}, maxConcurrency),
+		exportDir:      filepath.Join(os.TempDir(), exportDirName),
+		exportTTL:      defaultExportTTL,
 	}
 
 	// 初始化worker pool
@@ -201,7 +238,7 @@ func (s *batchService) BatchCreateAccounts(ctx context.Context, userID uint64, r
 	}
 
 	// 异步执行批量操作
-	go s.executeBatchCreateAccounts(ctx, job, req)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeBatchCreateAccounts(ctx, job, req) })
 
 	return job, nil
 }
@@ -209,10 +246,10 @@ func (s *batchService) BatchCreateAccounts(ctx context.Context, userID uint64, r
 // executeBatchCreateAccounts 执行批量创建账号
 func (s *batchService) executeBatchCreateAccounts(ctx context.Context, job *BatchJob, req *BatchAccountCreateRequest) {
 	// 获取worker
-	<-s.workerPool
-	defer func() {
-		s.workerPool <- struct{}{}
-	}()
+	if !s.acquireWorker(ctx) {
+		return
+	}
+	defer s.releaseWorker()
 
 	s.logger.Info("Starting batch account creation", zap.Uint64("job_id", job.ID))
 
@@ -236,10 +273,11 @@ func (s *batchService) executeBatchCreateAccounts(ctx context.Context, job *Batc
 		select {
 		case <-ctx.Done():
 			// 任务被取消
-			job.Status = BatchJobStatusCancelled
-			s.completeBatchJob(job, map[string]interface{}{
-				"cancelled_at": i,
-				"reason":       "context cancelled",
+			s.finishCancelledJob(ctx, job, map[string]interface{}{
+				"cancelled_at":     i,
+				"success_accounts": success,
+				"failed_accounts":  failed,
+				"error_messages":   errorMessages,
 			})
 			return
 		default:
@@ -275,7 +313,7 @@ func (s *batchService) executeBatchCreateAccounts(ctx context.Context, job *Batc
 		"error_messages":   errorMessages,
 	}
 
-	s.completeBatchJob(job, result)
+	s.completeBatchJob(ctx, job, result)
 	s.logger.Info("Batch account creation completed",
 		zap.Uint64("job_id", job.ID),
 		zap.Int("success", success),
@@ -290,17 +328,17 @@ func (s *batchService) BatchUpdateAccounts(ctx context.Context, userID uint64, r
 	}
 
 	// 异步执行
-	go s.executeBatchUpdateAccounts(ctx, job, req)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeBatchUpdateAccounts(ctx, job, req) })
 	return job, nil
 }
 
 // executeBatchUpdateAccounts 执行批量更新账号
 func (s *batchService) executeBatchUpdateAccounts(ctx context.Context, job *BatchJob, req *BatchAccountUpdateRequest) {
 	// 获取worker
-	<-s.workerPool
-	defer func() {
-		s.workerPool <- struct{}{}
-	}()
+	if !s.acquireWorker(ctx) {
+		return
+	}
+	defer s.releaseWorker()
 
 	s.logger.Info("Starting batch account update", zap.Uint64("job_id", job.ID))
 
@@ -322,10 +360,11 @@ func (s *batchService) executeBatchUpdateAccounts(ctx context.Context, job *Batc
 	for i, update := range req.Updates {
 		select {
 		case <-ctx.Done():
-			job.Status = BatchJobStatusCancelled
-			s.completeBatchJob(job, map[string]interface{}{
-				"cancelled_at": i,
-				"reason":       "context cancelled",
+			s.finishCancelledJob(ctx, job, map[string]interface{}{
+				"cancelled_at":    i,
+				"success_updates": success,
+				"failed_updates":  failed,
+				"error_messages":  errorMessages,
 			})
 			return
 		default:
@@ -355,7 +394,7 @@ func (s *batchService) executeBatchUpdateAccounts(ctx context.Context, job *Batc
 		"error_messages":  errorMessages,
 	}
 
-	s.completeBatchJob(job, result)
+	s.completeBatchJob(ctx, job, result)
 }
 
 // BatchDeleteAccounts 批量删除账号
@@ -366,16 +405,16 @@ func (s *batchService) BatchDeleteAccounts(ctx context.Context, userID uint64, a
 	}
 
 	// 异步执行
-	go s.executeBatchDeleteAccounts(ctx, job, accountIDs)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeBatchDeleteAccounts(ctx, job, accountIDs) })
 	return job, nil
 }
 
 // executeBatchDeleteAccounts 执行批量删除账号
 func (s *batchService) executeBatchDeleteAccounts(ctx context.Context, job *BatchJob, accountIDs []uint64) {
-	<-s.workerPool
-	defer func() {
-		s.workerPool <- struct{}{}
-	}()
+	if !s.acquireWorker(ctx) {
+		return
+	}
+	defer s.releaseWorker()
 
 	job.Status = BatchJobStatusRunning
 	now := time.Now()
@@ -391,7 +430,17 @@ func (s *batchService) executeBatchDeleteAccounts(ctx context.Context, job *Batc
 	failed := 0
 	var errorMessages []string
 
-	for _, accountID := range accountIDs {
+	for i, accountID := range accountIDs {
+		if ctx.Err() != nil {
+			s.finishCancelledJob(ctx, job, map[string]interface{}{
+				"cancelled_at":      i,
+				"success_deletions": success,
+				"failed_deletions":  failed,
+				"error_messages":    errorMessages,
+			})
+			return
+		}
+
 		err := s.accountService.DeleteAccount(job.UserID, accountID)
 		processed++
 
@@ -413,7 +462,7 @@ func (s *batchService) executeBatchDeleteAccounts(ctx context.Context, job *Batc
 		"error_messages":    errorMessages,
 	}
 
-	s.completeBatchJob(job, result)
+	s.completeBatchJob(ctx, job, result)
 }
 
 // BatchCreateTasks 批量创建任务
@@ -423,16 +472,16 @@ func (s *batchService) BatchCreateTasks(ctx context.Context, userID uint64, req
 		return nil, err
 	}
 
-	go s.executeBatchCreateTasks(ctx, job, req)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeBatchCreateTasks(ctx, job, req) })
 	return job, nil
 }
 
 // executeBatchCreateTasks 执行批量创建任务
 func (s *batchService) executeBatchCreateTasks(ctx context.Context, job *BatchJob, req *BatchTaskCreateRequest) {
-	<-s.workerPool
-	defer func() {
-		s.workerPool <- struct{}{}
-	}()
+	if !s.acquireWorker(ctx) {
+		return
+	}
+	defer s.releaseWorker()
 
 	job.Status = BatchJobStatusRunning
 	now := time.Now()
@@ -445,7 +494,22 @@ func (s *batchService) executeBatchCreateTasks(ctx context.Context, job *BatchJo
 	var errorMessages []string
 	var createdTaskIDs []uint64
 
+	s.runningJobsMutex.Lock()
+	s.runningJobs[job.ID] = job
+	s.runningJobsMutex.Unlock()
+
 	for i, taskReq := range req.Tasks {
+		if ctx.Err() != nil {
+			s.finishCancelledJob(ctx, job, map[string]interface{}{
+				"cancelled_at":     i,
+				"success_tasks":    success,
+				"failed_tasks":     failed,
+				"created_task_ids": createdTaskIDs,
+				"error_messages":   errorMessages,
+			})
+			return
+		}
+
 		task, err := s.taskService.CreateTask(job.UserID, &taskReq)
 		processed++
 
@@ -469,7 +533,7 @@ func (s *batchService) executeBatchCreateTasks(ctx context.Context, job *BatchJo
 		"error_messages":   errorMessages,
 	}
 
-	s.completeBatchJob(job, result)
+	s.completeBatchJob(ctx, job, result)
 }
 
 // 辅助方法
@@ -492,8 +556,13 @@ func (s *batchService) UpdateBatchJobProgress(ctx context.Context, jobID uint64,
 	return s.batchRepo.Update(job)
 }
 
-func (s *batchService) completeBatchJob(job *BatchJob, result map[string]interface{}) {
-	job.Status = BatchJobStatusCompleted
+// completeBatchJob 结束批量任务，任务上下文已取消（最后一项处理期间被取消）时记为已取消
+func (s *batchService) completeBatchJob(ctx context.Context, job *BatchJob, result map[string]interface{}) {
+	if ctx.Err() != nil {
+		job.Status = BatchJobStatusCancelled
+	} else {
+		job.Status = BatchJobStatusCompleted
+	}
 	job.Result = result
 	now := time.Now()
 	job.CompletedAt = &now
@@ -508,7 +577,14 @@ func (s *batchService) completeBatchJob(job *BatchJob, result map[string]interfa
 }
 
 func (s *batchService) GetBatchJob(ctx context.Context, userID uint64, jobID uint64) (*BatchJob, error) {
-	return s.batchRepo.GetByUserIDAndID(userID, jobID)
+	job, err := s.batchRepo.GetByUserIDAndID(userID, jobID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBatchJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
 }
 
 func (s *batchService) GetBatchJobs(ctx context.Context, userID uint64, page, limit int) ([]*BatchJob, int64, error) {
@@ -522,34 +598,89 @@ func (s *batchService) CompleteBatchJob(ctx context.Context, jobID uint64, resul
 	s.runningJobsMutex.RUnlock()
 
 	if exists {
-		s.completeBatchJob(job, result)
+		s.completeBatchJob(ctx, job, result)
 	}
 
 	return nil
 }
 
+// CancelBatchJob 取消等待中或运行中的批量任务，通知执行协程在处理完当前条目后停止
 func (s *batchService) CancelBatchJob(ctx context.Context, userID uint64, jobID uint64) error {
-	job, err := s.batchRepo.GetByUserIDAndID(userID, jobID)
+	job, err := s.GetBatchJob(ctx, userID, jobID)
 	if err != nil {
 		return err
 	}
 
-	if job.Status == BatchJobStatusRunning {
-		job.Status = BatchJobStatusCancelled
-		now := time.Now()
-		job.CompletedAt = &now
-		job.UpdatedAt = now
+	if job.Status != BatchJobStatusPending && job.Status != BatchJobStatusRunning {
+		return ErrBatchJobFinished
+	}
 
-		s.batchRepo.Update(job)
+	// 只通过上下文通知执行协程，协程结束时自行记为已取消；移出运行中任务后协程不再保存进度
+	s.runningJobsMutex.Lock()
+	if cancel, ok := s.jobCancels[jobID]; ok {
+		cancel()
+	}
+	delete(s.runningJobs, jobID)
+	s.runningJobsMutex.Unlock()
 
-		s.runningJobsMutex.Lock()
-		delete(s.runningJobs, jobID)
-		s.runningJobsMutex.Unlock()
+	job.Status = BatchJobStatusCancelled
+	now := time.Now()
+	job.CompletedAt = &now
+	job.UpdatedAt = now
+	if err := s.batchRepo.Update(job); err != nil {
+		return fmt.Errorf("failed to update batch job: %w", err)
 	}
 
+	s.logger.Info("Batch job cancelled",
+		zap.Uint64("job_id", jobID),
+		zap.Uint64("user_id", userID),
+		zap.Int("processed_items", job.ProcessedItems))
 	return nil
 }
 
+// runJob 以可取消的上下文异步执行批量任务，执行期间登记取消函数
+// 任务上下文与请求无关，不会因 HTTP 请求结束而被取消
+func (s *batchService) runJob(jobID uint64, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.runningJobsMutex.Lock()
+	s.jobCancels[jobID] = cancel
+	s.runningJobsMutex.Unlock()
+
+	go func() {
+		defer func() {
+			s.runningJobsMutex.Lock()
+			delete(s.jobCancels, jobID)
+			s.runningJobsMutex.Unlock()
+			cancel()
+		}()
+		fn(ctx)
+	}()
+}
+
+// acquireWorker 获取 worker，等待期间任务被取消时返回 false
+func (s *batchService) acquireWorker(ctx context.Context) bool {
+	select {
+	case <-s.workerPool:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseWorker 归还 worker
+func (s *batchService) releaseWorker() {
+	s.workerPool <- struct{}{}
+}
+
+// finishCancelledJob 记录被取消任务已处理部分的结果，ctx 已取消，任务记为已取消
+func (s *batchService) finishCancelledJob(ctx context.Context, job *BatchJob, result map[string]interface{}) {
+	s.completeBatchJob(ctx, job, result)
+	s.logger.Info("Batch job stopped after cancellation",
+		zap.Uint64("job_id", job.ID),
+		zap.Int("processed_items", job.ProcessedItems))
+}
+
 func (s *batchService) GetJobProgress(ctx context.Context, userID uint64, jobID uint64) (float64, error) {
 	job, err := s.batchRepo.GetByUserIDAndID(userID, jobID)
 	if err != nil {
@@ -579,7 +710,7 @@ func (s *batchService) BatchBindProxies(ctx context.Context, userID uint64, req
 	}
 
 	// 异步执行批量绑定
-	go s.executeBatchProxyBinding(context.Background(), job.ID, userID, req)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeBatchProxyBinding(ctx, job.ID, userID, req) })
 
 	return job, nil
 }
@@ -604,6 +735,10 @@ func (s *batchService) executeBatchProxyBinding(ctx context.Context, jobID, user
 	var errorMessages []string
 
 	for _, binding := range req.Bindings {
+		if ctx.Err() != nil {
+			return
+		}
+
 		// 验证账号归属
 		_, err := s.accountService.GetAccount(userID, binding.AccountID)
 		if err != nil {
@@ -661,7 +796,7 @@ func (s *batchService) BatchCancelTasks(ctx context.Context, userID uint64, task
 	}
 
 	// 异步执行批量取消
-	go s.executeBatchTaskCancellation(context.Background(), job.ID, userID, taskIDs)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeBatchTaskCancellation(ctx, job.ID, userID, taskIDs) })
 
 	return job, nil
 }
@@ -686,6 +821,10 @@ func (s *batchService) executeBatchTaskCancellation(ctx context.Context, jobID,
 	var errorMessages []string
 
 	for _, taskID := range taskIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
 		// 验证任务归属并取消
 		err := s.taskService.CancelTask(userID, taskID)
 		if err != nil {
@@ -723,7 +862,7 @@ func (s *batchService) ImportUsers(ctx context.Context, userID uint64, req *Impo
 	}
 
 	// 异步执行用户导入
-	go s.executeUserImport(context.Background(), job.ID, userID, req)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeUserImport(ctx, job.ID, userID, req) })
 
 	return job, nil
 }
@@ -749,6 +888,10 @@ func (s *batchService) executeUserImport(ctx context.Context, jobID, userID uint
 	var importedUsers []ImportedUserResult
 
 	for _, userData := range req.Users {
+		if ctx.Err() != nil {
+			return
+		}
+
 		// 验证用户数据
 		if userData.Username == "" {
 			errorMessages = append(errorMessages, fmt.Sprintf("用户 %s: 用户名不能为空", userData.Username))
@@ -835,17 +978,30 @@ func (s *batchService) ExportData(ctx context.Context, userID uint64, req *Expor
 	}
 
 	// 异步执行数据导出
-	go s.executeDataExport(context.Background(), job.ID, userID, req)
+	s.runJob(job.ID, func(ctx context.Context) { s.executeDataExport(ctx, job.ID, userID, req) })
 
 	return job, nil
 }
 
 // executeDataExport 执行数据导出
 func (s *batchService) executeDataExport(ctx context.Context, jobID, userID uint64, req *ExportDataRequest) {
-	s.runningJobsMutex.Lock()
-	if _, exists := s.runningJobs[jobID]; !exists {
-		s.runningJobs[jobID] = &BatchJob{ID: jobID}
+	job, err := s.batchRepo.GetByID(jobID)
+	if err != nil {
+		s.logger.Error("Failed to load export job", zap.Uint64("job_id", jobID), zap.Error(err))
+		return
+	}
+	// 开始前已被取消，CancelBatchJob 已记录状态
+	if ctx.Err() != nil {
+		return
 	}
+
+	now := time.Now()
+	job.Status = BatchJobStatusRunning
+	job.StartedAt = &now
+	s.batchRepo.Update(job)
+
+	s.runningJobsMutex.Lock()
+	s.runningJobs[jobID] = job
 	s.runningJobsMutex.Unlock()
 
 	defer func() {
@@ -855,230 +1011,284 @@ func (s *batchService) executeDataExport(ctx context.Context, jobID, userID uint
 	}()
 
 	var result map[string]interface{}
-	var err error
 
 	// 根据数据类型执行不同的导出逻辑
 	switch req.DataType {
 	case "accounts":
-		result, err = s.exportAccounts(ctx, userID, req)
+		result, err = s.exportAccounts(ctx, jobID, userID, req)
 	case "tasks":
-		result, err = s.exportTasks(ctx, userID, req)
+		result, err = s.exportTasks(ctx, jobID, userID, req)
 	case "proxies":
-		result, err = s.exportProxies(ctx, userID, req)
+		result, err = s.exportProxies(ctx, jobID, userID, req)
 	default:
 		err = fmt.Errorf("unsupported data type: %s", req.DataType)
 	}
 
+	// 导出期间被取消：记为已取消，已写出的文件不再提供下载
+	if ctx.Err() != nil {
+		if filename, _ := result["filename"].(string); isExportFileName(filename) {
+			os.Remove(filepath.Join(s.exportDir, filename))
+		}
+		s.finishCancelledJob(ctx, job, map[string]interface{}{
+			"success":   false,
+			"data_type": req.DataType,
+		})
+		return
+	}
+
 	if err != nil {
-		result = map[string]interface{}{
+		s.logger.Error("Data export failed",
+			zap.Uint64("job_id", jobID),
+			zap.String("data_type", req.DataType),
+			zap.Error(err))
+		s.UpdateBatchJobProgress(ctx, jobID, job.TotalItems, 0, job.TotalItems)
+		job.Status = BatchJobStatusFailed
+		job.ErrorMessages = append(job.ErrorMessages, err.Error())
+		job.Result = map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
 		}
+		completedAt := time.Now()
+		job.CompletedAt = &completedAt
+		s.batchRepo.Update(job)
+		return
 	}
 
 	// 更新进度和完成任务
-	s.UpdateBatchJobProgress(ctx, jobID, 1, 1, 0)
-	s.CompleteBatchJob(ctx, jobID, result)
+	s.UpdateBatchJobProgress(ctx, jobID, job.TotalItems, job.TotalItems, 0)
+	s.completeBatchJob(ctx, job, result)
 }
 
-// exportAccounts 导出账号数据
-func (s *batchService) exportAccounts(ctx context.Context, userID uint64, req *ExportDataRequest) (map[string]interface{}, error) {
-	// 简化实现，实际应该分页获取数据
-	filter := &AccountFilter{
-		UserID: userID,
-		Page:   1,
-		Limit:  1000,
-	}
-	accounts, total, err := s.accountService.GetAccounts(filter)
+// GetExportFile 打开已完成导出任务的文件，调用方负责关闭
+func (s *batchService) GetExportFile(ctx context.Context, userID uint64, jobID uint64) (io.ReadCloser, string, error) {
+	job, err := s.GetBatchJob(ctx, userID, jobID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get accounts: %w", err)
+		return nil, "", err
 	}
 
-	// 根据格式导出
-	var exportedData interface{}
-	var filename string
-
-	switch req.Format {
-	case "json", "":
-		exportedData = accounts
-		filename = fmt.Sprintf("accounts_%d.json", time.Now().Unix())
-	case "csv":
-		csvData := s.convertAccountsToCSV(accounts)
-		exportedData = csvData
-		filename = fmt.Sprintf("accounts_%d.csv", time.Now().Unix())
-	default:
-		exportedData = accounts
-		filename = fmt.Sprintf("accounts_%d.json", time.Now().Unix())
+	if job.Operation != BatchOperationExportData {
+		return nil, "", ErrNotExportJob
+	}
+	if job.Status == BatchJobStatusPending || job.Status == BatchJobStatusRunning {
+		return nil, "", ErrExportNotReady
+	}
+	if job.Status != BatchJobStatusCompleted || job.Result == nil {
+		return nil, "", fmt.Errorf("export job finished with status %s", job.Status)
 	}
 
-	result := map[string]interface{}{
-		"success":          true,
-		"data_type":        "accounts",
-		"format":           req.Format,
-		"total_records":    total,
-		"exported_records": len(accounts),
-		"filename":         filename,
-		"data":             exportedData,
-		"exported_at":      time.Now(),
+	// 结果中只保存文件名，防止路径穿越
+	filename, _ := job.Result["filename"].(string)
+	if !isExportFileName(filename) {
+		return nil, "", ErrExportExpired
+	}
+	file, err := os.Open(filepath.Join(s.exportDir, filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrExportExpired
+		}
+		return nil, "", fmt.Errorf("failed to open export file: %w", err)
 	}
 
-	return result, nil
+	return file, filename, nil
 }
 
-// exportTasks 导出任务数据
-func (s *batchService) exportTasks(ctx context.Context, userID uint64, req *ExportDataRequest) (map[string]interface{}, error) {
-	// 简化实现
-	tasks := []map[string]interface{}{
-		{
-			"id":         1,
-			"type":       "account_check",
-			"status":     "completed",
-			"created_at": time.Now().Format("2006-01-02 15:04:05"),
-		},
-	}
-
-	var exportedData interface{}
-	var filename string
-
-	switch req.Format {
-	case "json", "":
-		exportedData = tasks
-		filename = fmt.Sprintf("tasks_%d.json", time.Now().Unix())
-	case "csv":
-		csvData := s.convertTasksToCSV(tasks)
-		exportedData = csvData
-		filename = fmt.Sprintf("tasks_%d.csv", time.Now().Unix())
-	default:
-		exportedData = tasks
-		filename = fmt.Sprintf("tasks_%d.json", time.Now().Unix())
+// exportAccounts 导出账号数据，按 Filters 和 DateRange 分页读取全部匹配账号
+func (s *batchService) exportAccounts(ctx context.Context, jobID, userID uint64, req *ExportDataRequest) (map[string]interface{}, error) {
+	filter := &AccountFilter{
+		UserID:      userID,
+		Status:      exportFilterString(req.Filters, "status"),
+		Search:      exportFilterString(req.Filters, "search"),
+		CountryCode: exportFilterString(req.Filters, "country_code"),
+		Limit:       exportPageSize,
 	}
-
-	result := map[string]interface{}{
-		"success":          true,
-		"data_type":        "tasks",
-		"format":           req.Format,
-		"total_records":    int64(len(tasks)),
-		"exported_records": len(tasks),
-		"filename":         filename,
-		"data":             exportedData,
-		"exported_at":      time.Now(),
+	from, to := req.DateRange.bounds()
+	if req.DateRange != nil && req.DateRange.Field == "last_used_at" {
+		filter.LastUsedFrom, filter.LastUsedTo = from, to
+	} else {
+		filter.CreatedFrom, filter.CreatedTo = from, to
 	}
 
+	result, err := s.writeExportFile(ctx, jobID, "accounts", req.Format, "Accounts", accountExportHeaders,
+		func(page int) ([]interface{}, [][]interface{}, int64, error) {
+			filter.Page = page
+			accounts, total, err := s.accountService.GetAccounts(filter)
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("failed to get accounts: %w", err)
+			}
+			records := make([]interface{}, len(accounts))
+			rows := make([][]interface{}, len(accounts))
+			for i, account := range accounts {
+				records[i] = account
+				rows[i] = accountExportRow(account)
+			}
+			return records, rows, total, nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export accounts: %w", err)
+	}
 	return result, nil
 }
 
-// exportProxies 导出代理数据
-func (s *batchService) exportProxies(ctx context.Context, userID uint64, req *ExportDataRequest) (map[string]interface{}, error) {
-	// 简化实现
-	proxies := []map[string]interface{}{
-		{
-			"id":       1,
-			"name":     "代理1",
-			"host":     "127.0.0.1",
-			"port":     8080,
-			"protocol": "http",
-			"status":   "active",
-		},
-	}
-
-	var exportedData interface{}
-	var filename string
-
-	switch req.Format {
-	case "json", "":
-		exportedData = proxies
-		filename = fmt.Sprintf("proxies_%d.json", time.Now().Unix())
-	case "csv":
-		csvData := s.convertProxiesToCSV(proxies)
-		exportedData = csvData
-		filename = fmt.Sprintf("proxies_%d.csv", time.Now().Unix())
-	default:
-		exportedData = proxies
-		filename = fmt.Sprintf("proxies_%d.json", time.Now().Unix())
+// setBatchJobTotal 设置运行中任务的总项数，导出任务在读取第一页后得知总数
+func (s *batchService) setBatchJobTotal(jobID uint64, total int) {
+	s.runningJobsMutex.Lock()
+	defer s.runningJobsMutex.Unlock()
+	if job, exists := s.runningJobs[jobID]; exists {
+		job.TotalItems = total
 	}
+}
 
-	result := map[string]interface{}{
-		"success":          true,
-		"data_type":        "proxies",
-		"format":           req.Format,
-		"total_records":    int64(len(proxies)),
-		"exported_records": len(proxies),
-		"filename":         filename,
-		"data":             exportedData,
-		"exported_at":      time.Now(),
+// updateExportProgress 每读取一页更新导出进度，第一页时设置总数；总数为 0 时保持单项任务
+func (s *batchService) updateExportProgress(ctx context.Context, jobID uint64, firstPage bool, fetched int, total int64) {
+	if total <= 0 {
+		return
 	}
-
-	return result, nil
+	if firstPage {
+		s.setBatchJobTotal(jobID, int(total))
+	}
+	s.UpdateBatchJobProgress(ctx, jobID, fetched, fetched, 0)
 }
 
-// CSV转换辅助方法（简化实现）
-func (s *batchService) convertAccountsToCSV(accounts []*models.AccountSummary) string {
-	header := "ID,Phone,Status,Last Check At,Last Used At\n"
-	var rows []string
-	rows = append(rows, header)
+// exportPageSize 导出时每次查询的记录数
+const exportPageSize = 500
 
-	for _, account := range accounts {
-		var lastCheckDate string
-		if account.LastCheckAt != nil {
-			lastCheckDate = account.LastCheckAt.Format("2006-01-02")
-		} else {
-			lastCheckDate = ""
-		}
-
-		var lastUsedDate string
-		if account.LastUsedAt != nil {
-			lastUsedDate = account.LastUsedAt.Format("2006-01-02")
-		} else {
-			lastUsedDate = ""
-		}
-
-		row := fmt.Sprintf("%d,%s,%s,%s,%s\n",
-			account.ID,
-			account.Phone,
-			string(account.Status),
-			lastCheckDate,
-			lastUsedDate)
-		rows = append(rows, row)
-	}
+// 各数据类型允许通过 req.Filters 过滤的字段
+var (
+	taskExportFilterKeys  = []string{"status", "task_type", "priority"}
+	proxyExportFilterKeys = []string{"status", "protocol", "country", "is_active"}
+)
 
-	result := ""
-	for _, row := range rows {
-		result += row
+// exportTasks 导出任务数据
+func (s *batchService) exportTasks(ctx context.Context, jobID, userID uint64, req *ExportDataRequest) (map[string]interface{}, error) {
+	conditions := exportConditions(req.Filters, taskExportFilterKeys)
+	from, to := req.DateRange.bounds()
+
+	result, err := s.writeExportFile(ctx, jobID, "tasks", req.Format, "Tasks",
+		[]string{"ID", "Type", "Status", "Account IDs", "Priority", "Created At", "Started At", "Completed At"},
+		func(page int) ([]interface{}, [][]interface{}, int64, error) {
+			tasks, total, err := s.taskRepo.ListForExport(userID, conditions, from, to, (page-1)*exportPageSize, exportPageSize)
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("failed to get tasks: %w", err)
+			}
+			records := make([]interface{}, len(tasks))
+			rows := make([][]interface{}, len(tasks))
+			for i, task := range tasks {
+				records[i] = map[string]interface{}{
+					"id":           task.ID,
+					"task_type":    string(task.TaskType),
+					"status":       string(task.Status),
+					"account_ids":  task.AccountIDs,
+					"priority":     task.Priority,
+					"created_at":   task.CreatedAt,
+					"started_at":   task.StartedAt,
+					"completed_at": task.CompletedAt,
+				}
+				rows[i] = []interface{}{
+					task.ID, string(task.TaskType), string(task.Status), task.AccountIDs,
+					task.Priority, task.CreatedAt, task.StartedAt, task.CompletedAt,
+				}
+			}
+			return records, rows, total, nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export tasks: %w", err)
 	}
-	return result
+	return result, nil
 }
 
-func (s *batchService) convertTasksToCSV(tasks []map[string]interface{}) string {
-	header := "ID,Type,Status,Created At\n"
-	rows := []string{header}
+// exportProxies 导出代理数据，不包含代理密码
+func (s *batchService) exportProxies(ctx context.Context, jobID, userID uint64, req *ExportDataRequest) (map[string]interface{}, error) {
+	conditions := exportConditions(req.Filters, proxyExportFilterKeys)
+	from, to := req.DateRange.bounds()
 
-	for _, task := range tasks {
-		row := fmt.Sprintf("%v,%v,%v,%v\n",
-			task["id"], task["type"], task["status"], task["created_at"])
-		rows = append(rows, row)
+	result, err := s.writeExportFile(ctx, jobID, "proxies", req.Format, "Proxies",
+		[]string{"ID", "Name", "Host", "Port", "Protocol", "Username", "Country", "Status", "Active", "Success Rate", "Avg Latency (ms)", "Last Test At", "Created At"},
+		func(page int) ([]interface{}, [][]interface{}, int64, error) {
+			proxies, total, err := s.proxyRepo.ListForExport(userID, conditions, from, to, (page-1)*exportPageSize, exportPageSize)
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("failed to get proxies: %w", err)
+			}
+			records := make([]interface{}, len(proxies))
+			rows := make([][]interface{}, len(proxies))
+			for i, proxy := range proxies {
+				records[i] = map[string]interface{}{
+					"id":           proxy.ID,
+					"name":         proxy.Name,
+					"host":         proxy.IP,
+					"port":         proxy.Port,
+					"protocol":     string(proxy.Protocol),
+					"username":     proxy.Username,
+					"country":      proxy.Country,
+					"status":       string(proxy.Status),
+					"is_active":    proxy.IsActive,
+					"success_rate": proxy.SuccessRate,
+					"avg_latency":  proxy.AvgLatency,
+					"last_test_at": proxy.LastTestAt,
+					"created_at":   proxy.CreatedAt,
+				}
+				rows[i] = []interface{}{
+					proxy.ID, proxy.Name, proxy.IP, proxy.Port, string(proxy.Protocol), proxy.Username, proxy.Country,
+					string(proxy.Status), proxy.IsActive, proxy.SuccessRate, proxy.AvgLatency, proxy.LastTestAt, proxy.CreatedAt,
+				}
+			}
+			return records, rows, total, nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export proxies: %w", err)
 	}
+	return result, nil
+}
 
-	result := ""
-	for _, row := range rows {
-		result += row
+// exportConditions 从导出过滤条件中取出允许的字段，忽略空值和未知字段
+func exportConditions(filters map[string]interface{}, allowed []string) map[string]interface{} {
+	conditions := make(map[string]interface{})
+	for _, key := range allowed {
+		value, ok := filters[key]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		if values, ok := value.([]interface{}); ok && len(values) == 0 {
+			continue
+		}
+		conditions[key] = value
 	}
-	return result
+	return conditions
 }
 
-func (s *batchService) convertProxiesToCSV(proxies []map[string]interface{}) string {
-	header := "ID,Name,Host,Port,Protocol,Status\n"
-	rows := []string{header}
+// exportFilterString 读取字符串类型的导出过滤条件
+func exportFilterString(filters map[string]interface{}, key string) string {
+	value, _ := filters[key].(string)
+	return strings.TrimSpace(value)
+}
 
-	for _, proxy := range proxies {
-		row := fmt.Sprintf("%v,%v,%v,%v,%v,%v\n",
-			proxy["id"], proxy["name"], proxy["host"],
-			proxy["port"], proxy["protocol"], proxy["status"])
-		rows = append(rows, row)
+// bounds 返回时间范围的起止时间，未设置的一端为 nil；结束日期只有日期部分时包含当天全天
+func (r *DateRange) bounds() (from, to *time.Time) {
+	if r == nil {
+		return nil, nil
 	}
+	if !r.StartDate.IsZero() {
+		from = &r.StartDate
+	}
+	if !r.EndDate.IsZero() {
+		end := r.EndDate
+		if end.Equal(time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())) {
+			end = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		to = &end
+	}
+	return from, to
+}
 
-	result := ""
-	for _, row := range rows {
-		result += row
+// accountExportHeaders 账号导出表头
+var accountExportHeaders = []string{"ID", "Phone", "Status", "Last Check At", "Last Used At"}
+
+// accountExportRow 账号导出表格行，与 accountExportHeaders 对应
+func accountExportRow(account *models.AccountSummary) []interface{} {
+	return []interface{}{
+		account.ID,
+		account.Phone,
+		string(account.Status),
+		account.LastCheckAt,
+		account.LastUsedAt,
 	}
-	return result
 }
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/repository"
+)
+
+// memBatchRepo 内存批量任务仓库，保存副本以模拟数据库读写
+type memBatchRepo struct {
+	repository.BatchRepository
+
+	mu     sync.Mutex
+	nextID uint64
+	jobs   map[uint64]models.BatchJob
+}
+
+func newMemBatchRepo() *memBatchRepo {
+	return &memBatchRepo{jobs: make(map[uint64]models.BatchJob)}
+}
+
+func (r *memBatchRepo) Create(job *models.BatchJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	job.ID = r.nextID
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memBatchRepo) Update(job *models.BatchJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memBatchRepo) GetByID(jobID uint64) (*models.BatchJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+func (r *memBatchRepo) GetByUserIDAndID(userID, jobID uint64) (*models.BatchJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[jobID]
+	if !ok || job.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+// memAccountRepo 只实现批量创建和导出账号用到的方法
+type memAccountRepo struct {
+	repository.AccountRepository
+
+	summaryTotal int           // 导出时的账号总数
+	pageDelay    time.Duration // 每页查询耗时
+}
+
+func (r *memAccountRepo) GetAccountSummaries(userID uint64, page, limit int, search, status, countryCode string, timeRange repository.AccountTimeRange) ([]*models.AccountSummary, int64, error) {
+	time.Sleep(r.pageDelay)
+	var summaries []*models.AccountSummary
+	for i := (page - 1) * limit; i < page*limit && i < r.summaryTotal; i++ {
+		summaries = append(summaries, &models.AccountSummary{ID: uint64(i + 1), Phone: fmt.Sprintf("+1555000%04d", i)})
+	}
+	return summaries, int64(r.summaryTotal), nil
+}
+
+func (r *memAccountRepo) GetByPhone(phone string) (*models.TGAccount, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memAccountRepo) Create(account *models.TGAccount) error {
+	return nil
+}
+
+func newTestBatchService(batchRepo repository.BatchRepository, accountRepo *memAccountRepo) *batchService {
+	s := &batchService{
+		batchRepo: batchRepo,
+		accountService: &AccountService{
+			accountRepo: accountRepo,
+			logger:      zap.NewNop(),
+		},
+		logger:         zap.NewNop(),
+		runningJobs:    make(map[uint64]*BatchJob),
+		jobCancels:     make(map[uint64]context.CancelFunc),
+		maxConcurrency: 1,
+		workerPool:     make(chan struct{}, 1),
+	}
+	s.workerPool <- struct{}{}
+	return s
+}
+
+// waitBatchJobExit 等待执行协程在 since 之后一秒内退出（退出时会移除自己的取消函数）
+func waitBatchJobExit(t *testing.T, s *batchService, jobID uint64, since time.Time) {
+	t.Helper()
+	for {
+		s.runningJobsMutex.RLock()
+		_, running := s.jobCancels[jobID]
+		s.runningJobsMutex.RUnlock()
+		if !running {
+			return
+		}
+		if time.Since(since) > time.Second {
+			t.Fatal("batch job still running after one second")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelBatchJobStopsLongJob(t *testing.T) {
+	repo := newMemBatchRepo()
+	s := newTestBatchService(repo, &memAccountRepo{})
+	ctx := context.Background()
+	const userID = 1
+
+	req := &BatchAccountCreateRequest{}
+	for i := 0; i < 200; i++ {
+		req.Accounts = append(req.Accounts, models.CreateAccountRequest{Phone: fmt.Sprintf("+1555000%04d", i)})
+	}
+
+	job, err := s.BatchCreateAccounts(ctx, userID, req)
+	if err != nil {
+		t.Fatalf("BatchCreateAccounts: %v", err)
+	}
+
+	// 等待任务开始处理
+	time.Sleep(300 * time.Millisecond)
+
+	cancelledAt := time.Now()
+	if err := s.CancelBatchJob(ctx, userID, job.ID); err != nil {
+		t.Fatalf("CancelBatchJob: %v", err)
+	}
+
+	waitBatchJobExit(t, s, job.ID, cancelledAt)
+
+	stored, err := repo.GetByUserIDAndID(userID, job.ID)
+	if err != nil {
+		t.Fatalf("GetByUserIDAndID: %v", err)
+	}
+	if stored.Status != BatchJobStatusCancelled {
+		t.Fatalf("status = %q, want %q", stored.Status, BatchJobStatusCancelled)
+	}
+	if stored.ProcessedItems >= len(req.Accounts) {
+		t.Fatalf("processed %d items, want fewer than %d", stored.ProcessedItems, len(req.Accounts))
+	}
+
+	if _, err := s.GetBatchJob(ctx, userID, job.ID); err != nil {
+		t.Fatalf("GetBatchJob: %v", err)
+	}
+	if err := s.CancelBatchJob(ctx, userID, job.ID); err != ErrBatchJobFinished {
+		t.Fatalf("second cancel err = %v, want ErrBatchJobFinished", err)
+	}
+}
+
+func TestCancelBatchJobStopsExport(t *testing.T) {
+	repo := newMemBatchRepo()
+	s := newTestBatchService(repo, &memAccountRepo{summaryTotal: 100 * exportPageSize, pageDelay: 20 * time.Millisecond})
+	s.exportDir = t.TempDir()
+	ctx := context.Background()
+	const userID = 1
+
+	job, err := s.ExportData(ctx, userID, &ExportDataRequest{DataType: "accounts", Format: ExportFormatCSV})
+	if err != nil {
+		t.Fatalf("ExportData: %v", err)
+	}
+
+	// 等待导出读取若干页
+	time.Sleep(100 * time.Millisecond)
+
+	cancelledAt := time.Now()
+	if err := s.CancelBatchJob(ctx, userID, job.ID); err != nil {
+		t.Fatalf("CancelBatchJob: %v", err)
+	}
+	waitBatchJobExit(t, s, job.ID, cancelledAt)
+
+	stored, err := repo.GetByUserIDAndID(userID, job.ID)
+	if err != nil {
+		t.Fatalf("GetByUserIDAndID: %v", err)
+	}
+	if stored.Status != BatchJobStatusCancelled {
+		t.Fatalf("status = %q, want %q", stored.Status, BatchJobStatusCancelled)
+	}
+	if _, _, err := s.GetExportFile(ctx, userID, job.ID); err == nil {
+		t.Fatal("GetExportFile succeeded for a cancelled export")
+	}
+	entries, err := os.ReadDir(s.exportDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("export dir has %d files after cancel, want 0", len(entries))
+	}
+}
+
+func TestExportDataWritesAllPages(t *testing.T) {
+	const total = exportPageSize + 3
+	for _, format := range []string{ExportFormatJSON, ExportFormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			repo := newMemBatchRepo()
+			s := newTestBatchService(repo, &memAccountRepo{summaryTotal: total})
+			s.exportDir = t.TempDir()
+			ctx := context.Background()
+			const userID = 1
+
+			job, err := s.ExportData(ctx, userID, &ExportDataRequest{DataType: "accounts", Format: format})
+			if err != nil {
+				t.Fatalf("ExportData: %v", err)
+			}
+			waitBatchJobExit(t, s, job.ID, time.Now())
+
+			stored, err := repo.GetByUserIDAndID(userID, job.ID)
+			if err != nil {
+				t.Fatalf("GetByUserIDAndID: %v", err)
+			}
+			if stored.Status != BatchJobStatusCompleted || stored.TotalItems != total || stored.ProcessedItems != total {
+				t.Fatalf("job = %s %d/%d, want completed %d/%d", stored.Status, stored.ProcessedItems, stored.TotalItems, total, total)
+			}
+
+			file, _, err := s.GetExportFile(ctx, userID, job.ID)
+			if err != nil {
+				t.Fatalf("GetExportFile: %v", err)
+			}
+			defer file.Close()
+
+			var records int
+			switch format {
+			case ExportFormatJSON:
+				var accounts []models.AccountSummary
+				if err := json.NewDecoder(file).Decode(&accounts); err != nil {
+					t.Fatalf("decode json: %v", err)
+				}
+				records = len(accounts)
+			case ExportFormatCSV:
+				rows, err := csv.NewReader(file).ReadAll()
+				if err != nil {
+					t.Fatalf("read csv: %v", err)
+				}
+				records = len(rows) - 1
+			}
+			if records != total {
+				t.Fatalf("exported %d records, want %d", records, total)
+			}
+		})
+	}
+}
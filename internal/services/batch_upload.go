@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// uploadCreateChunkSize 异步上传时每批创建的账号数，每批完成后更新一次进度
+const uploadCreateChunkSize = 50
+
+// UploadAccountFile 异步解析上传的账号文件并创建账号，立即返回批量任务
+// tempDir 为上传文件所在的临时目录，由任务接管并在结束后删除；进度通过批量任务查询
+func (s *batchService) UploadAccountFile(ctx context.Context, userID uint64, tempDir, filePath string, proxyID *uint64) (*BatchJob, error) {
+	// 文件解析完成前总数未知，解析后再更新
+	job, err := s.CreateBatchJob(ctx, userID, BatchOperationUploadAccounts, 0)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+
+	s.runJob(job.ID, func(ctx context.Context) {
+		defer os.RemoveAll(tempDir)
+		s.executeAccountUpload(ctx, job, filePath, proxyID)
+	})
+
+	return job, nil
+}
+
+// executeAccountUpload 解析账号文件后分批创建账号，Result 中的 phase/parsed 反映当前阶段
+func (s *batchService) executeAccountUpload(ctx context.Context, job *BatchJob, filePath string, proxyID *uint64) {
+	if !s.acquireWorker(ctx) {
+		return
+	}
+	defer s.releaseWorker()
+
+	s.logger.Info("Starting async account upload",
+		zap.Uint64("job_id", job.ID),
+		zap.String("file", filePath))
+
+	job.Status = BatchJobStatusRunning
+	now := time.Now()
+	job.StartedAt = &now
+	job.Result = map[string]interface{}{"phase": "parsing"}
+	s.batchRepo.Update(job)
+
+	s.runningJobsMutex.Lock()
+	s.runningJobs[job.ID] = job
+	s.runningJobsMutex.Unlock()
+
+	parsedAccounts, err := NewAccountParser().ParseAccountFiles(filePath)
+	// 解析期间被取消时记为已取消，不因随后的解析错误改为失败
+	if ctx.Err() != nil {
+		s.finishCancelledJob(ctx, job, map[string]interface{}{"phase": "parsing"})
+		return
+	}
+	if err == nil && len(parsedAccounts) == 0 {
+		err = fmt.Errorf("未能从文件中解析出账号信息")
+	}
+	if err != nil {
+		s.failBatchJob(job, fmt.Sprintf("解析账号文件失败: %v", err))
+		return
+	}
+
+	var uploadItems []models.AccountUploadItem
+	var errorMessages []string
+	for _, account := range parsedAccounts {
+		switch {
+		case account.Error != "":
+			errorMessages = append(errorMessages, fmt.Sprintf("账号 %s: %s", account.Phone, account.Error))
+		case account.Phone == "" || account.SessionData == "":
+			errorMessages = append(errorMessages, fmt.Sprintf("账号数据不完整: Phone=%s", account.Phone))
+		default:
+			uploadItems = append(uploadItems, models.AccountUploadItem{Phone: account.Phone, SessionData: account.SessionData})
+		}
+	}
+
+	// 解析失败的条目直接计入失败
+	job.TotalItems = len(parsedAccounts)
+	job.Result = map[string]interface{}{"phase": "creating", "parsed": len(parsedAccounts)}
+	processed := len(errorMessages)
+	success := 0
+	failed := len(errorMessages)
+	s.UpdateBatchJobProgress(ctx, job.ID, processed, success, failed)
+
+	for start := 0; start < len(uploadItems); start += uploadCreateChunkSize {
+		select {
+		case <-ctx.Done():
+			s.finishCancelledJob(ctx, job, map[string]interface{}{
+				"parsed":           len(parsedAccounts),
+				"success_accounts": success,
+				"failed_accounts":  failed,
+				"error_messages":   errorMessages,
+			})
+			return
+		default:
+		}
+
+		end := start + uploadCreateChunkSize
+		if end > len(uploadItems) {
+			end = len(uploadItems)
+		}
+		chunk := uploadItems[start:end]
+
+		created, createErrors, err := s.accountService.CreateAccountsFromUploadData(job.UserID, chunk, proxyID)
+		if err != nil {
+			// 整批创建失败（如代理无效、数据库错误），该批全部计为失败
+			created = nil
+			createErrors = append(createErrors, err.Error())
+			s.logger.Error("Failed to create uploaded accounts chunk",
+				zap.Uint64("job_id", job.ID),
+				zap.Int("chunk_start", start),
+				zap.Error(err))
+		}
+		errorMessages = append(errorMessages, createErrors...)
+		processed += len(chunk)
+		success += len(created)
+		failed += len(chunk) - len(created)
+
+		s.UpdateBatchJobProgress(ctx, job.ID, processed, success, failed)
+	}
+
+	job.ErrorMessages = errorMessages
+	s.completeBatchJob(ctx, job, map[string]interface{}{
+		"phase":            "completed",
+		"parsed":           len(parsedAccounts),
+		"success_accounts": success,
+		"failed_accounts":  failed,
+		"error_messages":   errorMessages,
+	})
+	s.logger.Info("Async account upload completed",
+		zap.Uint64("job_id", job.ID),
+		zap.Int("parsed", len(parsedAccounts)),
+		zap.Int("success", success),
+		zap.Int("failed", failed))
+}
+
+// failBatchJob 将批量任务标记为失败
+func (s *batchService) failBatchJob(job *BatchJob, message string) {
+	job.Status = BatchJobStatusFailed
+	job.ErrorMessages = append(job.ErrorMessages, message)
+	now := time.Now()
+	job.CompletedAt = &now
+	job.UpdatedAt = now
+	s.batchRepo.Update(job)
+
+	s.runningJobsMutex.Lock()
+	delete(s.runningJobs, job.ID)
+	s.runningJobsMutex.Unlock()
+
+	s.logger.Warn("Batch job failed",
+		zap.Uint64("job_id", job.ID),
+		zap.String("error", message))
+}
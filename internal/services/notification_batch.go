@@ -0,0 +1,137 @@
+package services
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// taskLogBatch 合并窗口内同一任务待推送的日志
+type taskLogBatch struct {
+	logs  []*TaskLogEntry
+	timer *time.Timer
+}
+
+// isFinalTaskLog 任务结束类日志，推送时立即刷新该任务的批次
+func isFinalTaskLog(log *TaskLogEntry) bool {
+	switch log.Action {
+	case "task_completed", "task_failed", "task_cancelled", "task_partial_success",
+		"task_paused", "task_stopped_on_error",
+		"scenario_complete", "scenario_error", "scenario_cancelled":
+		return true
+	}
+	return false
+}
+
+// SetBatchWindow 设置任务日志推送的合并窗口，窗口内同一任务的日志合并为一条消息推送，0 表示逐条推送
+func (s *notificationService) SetBatchWindow(window time.Duration) {
+	s.taskLogBatchMutex.Lock()
+	s.batchWindow = window
+	s.taskLogBatchMutex.Unlock()
+}
+
+// enqueueTaskLog 将日志加入任务批次，批次的第一条日志启动刷新定时器
+func (s *notificationService) enqueueTaskLog(taskID uint64, log *TaskLogEntry) {
+	s.taskLogBatchMutex.Lock()
+	defer s.taskLogBatchMutex.Unlock()
+
+	batch, exists := s.taskLogBatches[taskID]
+	if !exists {
+		created := &taskLogBatch{}
+		created.timer = time.AfterFunc(s.batchWindow, func() {
+			s.flushTaskLogBatch(taskID, created)
+		})
+		batch = created
+		s.taskLogBatches[taskID] = batch
+	}
+	batch.logs = append(batch.logs, log)
+}
+
+// FlushTaskLogs 立即推送任务尚未推送的日志
+// 取出批次与推送在同一把锁内完成，保证定时刷新和任务结束刷新之间的顺序
+func (s *notificationService) FlushTaskLogs(taskID uint64) {
+	s.taskLogBatchMutex.Lock()
+	defer s.taskLogBatchMutex.Unlock()
+
+	batch, exists := s.taskLogBatches[taskID]
+	if !exists {
+		return
+	}
+	delete(s.taskLogBatches, taskID)
+	batch.timer.Stop()
+
+	s.sendTaskLogs(taskID, batch.logs)
+}
+
+// flushTaskLogBatch 定时器到期时推送创建它的批次；该批次已被提前刷新、任务又开始新批次时不处理
+func (s *notificationService) flushTaskLogBatch(taskID uint64, batch *taskLogBatch) {
+	s.taskLogBatchMutex.Lock()
+	defer s.taskLogBatchMutex.Unlock()
+
+	if s.taskLogBatches[taskID] != batch {
+		return
+	}
+	delete(s.taskLogBatches, taskID)
+
+	s.sendTaskLogs(taskID, batch.logs)
+}
+
+// flushAllTaskLogs 推送所有任务尚未推送的日志，服务停止时调用
+func (s *notificationService) flushAllTaskLogs() {
+	s.taskLogBatchMutex.Lock()
+	taskIDs := make([]uint64, 0, len(s.taskLogBatches))
+	for taskID := range s.taskLogBatches {
+		taskIDs = append(taskIDs, taskID)
+	}
+	s.taskLogBatchMutex.Unlock()
+
+	for _, taskID := range taskIDs {
+		s.FlushTaskLogs(taskID)
+	}
+}
+
+// sendTaskLogs 推送任务日志给订阅者，单条日志沿用 task_log 消息，多条合并为一条 task_logs 消息
+func (s *notificationService) sendTaskLogs(taskID uint64, logs []*TaskLogEntry) {
+	if len(logs) == 0 {
+		return
+	}
+
+	subscribers := s.hub.taskLogSubManager.GetSubscribers(taskID)
+	if len(subscribers) == 0 {
+		s.logger.Debug("No subscribers for task log",
+			zap.Uint64("task_id", taskID))
+		return
+	}
+
+	message := WSMessage{
+		Type: "task_log",
+		Data: map[string]interface{}{
+			"task_id": taskID,
+			"log":     logs[0],
+		},
+		Timestamp: time.Now(),
+	}
+	if len(logs) > 1 {
+		message.Type = "task_logs"
+		message.Data = map[string]interface{}{
+			"task_id": taskID,
+			"logs":    logs,
+			"count":   len(logs),
+		}
+	}
+
+	for _, conn := range subscribers {
+		select {
+		case conn.Send <- message:
+			s.logger.Debug("Task logs pushed to subscriber",
+				zap.Uint64("task_id", taskID),
+				zap.Uint64("user_id", conn.UserID),
+				zap.Int("count", len(logs)))
+		default:
+			s.logger.Warn("Failed to push task log: channel full",
+				zap.Uint64("task_id", taskID),
+				zap.Uint64("user_id", conn.UserID),
+				zap.Int("count", len(logs)))
+		}
+	}
+}
@@ -252,6 +252,8 @@ type NotificationService interface {
 	UnsubscribeTaskLogs(userID uint64, taskID uint64) error
 	GetTaskLogSubscribers(taskID uint64) []uint64
 	PushTaskLog(taskID uint64, log *TaskLogEntry)
+	SetBatchWindow(window time.Duration)
+	FlushTaskLogs(taskID uint64)
 
 	// 消息管理
 	GetUnreadNotifications(userID uint64) ([]*Notification, error)
@@ -276,15 +278,21 @@ type notificationService struct {
 	notifications      map[string]*Notification // 内存存储通知，实际应该用数据库
 	notificationsMutex sync.RWMutex
 	running            bool
+
+	// 任务日志推送合并
+	batchWindow       time.Duration
+	taskLogBatches    map[uint64]*taskLogBatch
+	taskLogBatchMutex sync.Mutex
 }
 
 // NewNotificationService 创建通知服务
 func NewNotificationService(eventService *events.EventService) NotificationService {
 	service := &notificationService{
-		eventService:  eventService,
-		logger:        logger.Get().Named("notification_service"),
-		notifications: make(map[string]*Notification),
-		running:       false,
+		eventService:   eventService,
+		logger:         logger.Get().Named("notification_service"),
+		notifications:  make(map[string]*Notification),
+		taskLogBatches: make(map[uint64]*taskLogBatch),
+		running:        false,
 	}
 
 	// 创建任务日志订阅管理器
@@ -333,6 +341,9 @@ func (s *notificationService) Stop() error {
 	s.logger.Info("Stopping notification service")
 	s.running = false
 
+	// 推送尚未发出的合并日志
+	s.flushAllTaskLogs()
+
 	// 关闭所有WebSocket连接
 	s.hub.mutex.Lock()
 	for _, client := range s.hub.clients {
@@ -1311,44 +1322,23 @@ func (s *notificationService) GetTaskLogSubscribers(taskID uint64) []uint64 {
 }
 
 // PushTaskLog 推送任务日志给订阅者
-// 实现 LogPusher 接口
+// 实现 LogPusher 接口；配置了合并窗口时先缓冲，任务结束日志会立即刷新
 func (s *notificationService) PushTaskLog(taskID uint64, log *TaskLogEntry) {
-	// 获取该任务的所有订阅者
-	subscribers := s.hub.taskLogSubManager.GetSubscribers(taskID)
-
 	s.logger.Debug("PushTaskLog called",
 		zap.Uint64("task_id", taskID),
-		zap.Uint64("log_id", log.ID),
-		zap.Int("subscriber_count", len(subscribers)))
+		zap.Uint64("log_id", log.ID))
 
-	if len(subscribers) == 0 {
-		s.logger.Debug("No subscribers for task log",
-			zap.Uint64("task_id", taskID))
-		return
-	}
+	s.taskLogBatchMutex.Lock()
+	window := s.batchWindow
+	s.taskLogBatchMutex.Unlock()
 
-	// 构建推送消息
-	message := WSMessage{
-		Type: "task_log",
-		Data: map[string]interface{}{
-			"task_id": taskID,
-			"log":     log,
-		},
-		Timestamp: time.Now(),
+	if window <= 0 {
+		s.sendTaskLogs(taskID, []*TaskLogEntry{log})
+		return
 	}
 
-	// 推送给所有订阅者
-	for _, conn := range subscribers {
-		select {
-		case conn.Send <- message:
-			s.logger.Debug("Task log pushed to subscriber",
-				zap.Uint64("task_id", taskID),
-				zap.Uint64("user_id", conn.UserID),
-				zap.Uint64("log_id", log.ID))
-		default:
-			s.logger.Warn("Failed to push task log: channel full",
-				zap.Uint64("task_id", taskID),
-				zap.Uint64("user_id", conn.UserID))
-		}
+	s.enqueueTaskLog(taskID, log)
+	if isFinalTaskLog(log) {
+		s.FlushTaskLogs(taskID)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,6 +13,7 @@ import (
 	"tg_cloud_server/internal/common/logger"
 	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/repository"
+	"tg_cloud_server/internal/telegram"
 )
 
 const (
@@ -32,20 +34,133 @@ type ProxyService interface {
 	DeleteProxy(userID, proxyID uint64) error
 	TestProxy(userID, proxyID uint64) (*models.ProxyTestResult, error)
 	GetProxyStats(userID uint64) (*models.ProxyStats, error)
+	MigrateProxy(userID, fromProxyID, toProxyID uint64) (*models.MigrateProxyResult, error)
+	SetDeletePolicy(policy string)
+	SetNotificationService(notificationService NotificationService)
+	SetConnectionPool(pool *telegram.ConnectionPool)
+	SetMaxAccountsPerProxy(max int)
+}
+
+// 删除仍绑定账号的代理时的处理策略
+const (
+	ProxyDeletePolicyBlock  = "block"  // 拒绝删除
+	ProxyDeletePolicyUnbind = "unbind" // 解除绑定后删除并通知用户
+)
+
+// ProxyInUseError 代理仍绑定账号，按 block 策略拒绝删除
+type ProxyInUseError struct {
+	BoundAccounts int64
+}
+
+func (e *ProxyInUseError) Error() string {
+	return fmt.Sprintf("代理仍绑定 %d 个账号，请先解除绑定", e.BoundAccounts)
+}
+
+// ProxyCapacityError 目标代理容量不足，无法承接迁移的账号
+type ProxyCapacityError struct {
+	Capacity  int
+	Bound     int64
+	Migrating int64
+}
+
+func (e *ProxyCapacityError) Error() string {
+	return fmt.Sprintf("目标代理容量不足：已绑定 %d 个账号，迁移 %d 个后将超过上限 %d", e.Bound, e.Migrating, e.Capacity)
 }
 
 // proxyService 代理服务实现
 type proxyService struct {
-	proxyRepo repository.ProxyRepository
-	logger    *zap.Logger
+	proxyRepo           repository.ProxyRepository
+	notificationService NotificationService
+	connectionPool      *telegram.ConnectionPool
+	deletePolicy        string
+	maxAccountsPerProxy int // 单个代理最多绑定的账号数，0 表示不限制
+	logger              *zap.Logger
 }
 
 // NewProxyService 创建代理服务
 func NewProxyService(proxyRepo repository.ProxyRepository) ProxyService {
 	return &proxyService{
-		proxyRepo: proxyRepo,
-		logger:    logger.Get().Named("proxy_service"),
+		proxyRepo:    proxyRepo,
+		deletePolicy: ProxyDeletePolicyUnbind,
+		logger:       logger.Get().Named("proxy_service"),
+	}
+}
+
+// SetDeletePolicy 设置删除仍绑定账号的代理时的处理策略
+func (s *proxyService) SetDeletePolicy(policy string) {
+	switch policy {
+	case ProxyDeletePolicyBlock, ProxyDeletePolicyUnbind:
+		s.deletePolicy = policy
+	default:
+		s.logger.Warn("Unknown proxy delete policy, using unbind", zap.String("policy", policy))
+		s.deletePolicy = ProxyDeletePolicyUnbind
+	}
+}
+
+// SetNotificationService 设置通知服务（可选），用于通知代理删除导致的解绑
+func (s *proxyService) SetNotificationService(notificationService NotificationService) {
+	s.notificationService = notificationService
+}
+
+// SetConnectionPool 设置连接池（可选），代理迁移后用于让在线连接切换到新代理
+func (s *proxyService) SetConnectionPool(pool *telegram.ConnectionPool) {
+	s.connectionPool = pool
+}
+
+// SetMaxAccountsPerProxy 设置单个代理最多绑定的账号数，0 表示不限制
+func (s *proxyService) SetMaxAccountsPerProxy(max int) {
+	s.maxAccountsPerProxy = max
+}
+
+// checkBoundAccounts 删除前检查代理绑定的账号，block 策略下有绑定时返回 ProxyInUseError
+func (s *proxyService) checkBoundAccounts(userID uint64, proxyIDs []uint64) (int64, error) {
+	count, err := s.proxyRepo.CountBoundAccounts(userID, proxyIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count bound accounts: %w", err)
+	}
+	if count > 0 && s.deletePolicy == ProxyDeletePolicyBlock {
+		return count, &ProxyInUseError{BoundAccounts: count}
 	}
+	return count, nil
+}
+
+// notifyUnbound 通知用户代理删除后有账号被解除绑定，并重新加载这些账号的连接配置使其不再使用已删除的代理
+func (s *proxyService) notifyUnbound(userID uint64, proxyIDs []uint64, accountIDs []uint64) {
+	if len(accountIDs) == 0 {
+		return
+	}
+	count := int64(len(accountIDs))
+
+	if s.connectionPool != nil {
+		for _, accountID := range accountIDs {
+			if _, err := s.connectionPool.ReloadConfig(fmt.Sprintf("%d", accountID)); err != nil {
+				s.logger.Warn("Failed to reload connection config after proxy deletion",
+					zap.Uint64("account_id", accountID),
+					zap.Error(err))
+			}
+		}
+	}
+
+	s.logger.Warn("Accounts unbound due to proxy deletion",
+		zap.Uint64("user_id", userID),
+		zap.Uint64s("proxy_ids", proxyIDs),
+		zap.Int64("account_count", count))
+
+	if s.notificationService == nil {
+		return
+	}
+	message := fmt.Sprintf("删除代理后已有 %d 个账号解除代理绑定，这些账号将以直连方式运行，请重新分配代理", count)
+	if err := s.notificationService.NotifySystemAlert(userID, "warning", message); err != nil {
+		s.logger.Warn("Failed to send proxy unbind notification", zap.Error(err))
+	}
+}
+
+// validatePasswordRef 校验用户提交的密码引用，为空表示直接使用密码
+func validatePasswordRef(ref string) error {
+	if strings.TrimSpace(ref) == "" {
+		return nil
+	}
+	return models.ValidatePasswordRef(ref)
 }
 
 // CreateProxy 创建代理
@@ -55,15 +170,20 @@ func (s *proxyService) CreateProxy(userID uint64, req *models.CreateProxyRequest
 		zap.String("name", req.Name),
 		zap.String("ip", req.IP))
 
+	if err := validatePasswordRef(req.PasswordRef); err != nil {
+		return nil, err
+	}
+
 	proxy := &models.ProxyIP{
-		UserID:   userID,
-		Name:     req.Name,
-		IP:       req.IP,
-		Port:     req.Port,
-		Username: req.Username,
-		Password: req.Password,
-		Protocol: req.Protocol,
-		Status:   models.StatusUntested,
+		UserID:      userID,
+		Name:        req.Name,
+		IP:          req.IP,
+		Port:        req.Port,
+		Username:    req.Username,
+		Password:    req.Password,
+		PasswordRef: req.PasswordRef,
+		Protocol:    req.Protocol,
+		Status:      models.StatusUntested,
 	}
 
 	if err := s.proxyRepo.Create(proxy); err != nil {
@@ -83,17 +203,21 @@ func (s *proxyService) BatchCreateProxy(userID uint64, req *models.BatchCreatePr
 
 	var proxies []*models.ProxyIP
 	for _, p := range req.Proxies {
+		if err := validatePasswordRef(p.PasswordRef); err != nil {
+			return nil, err
+		}
 		proxy := &models.ProxyIP{
-			UserID:   userID,
-			Name:     p.Name,
-			IP:       p.IP,
-			Port:     p.Port,
-			Protocol: p.Protocol,
-			Username: p.Username,
-			Password: p.Password,
-			Country:  p.Country,
-			Status:   models.StatusUntested,
-			IsActive: true,
+			UserID:      userID,
+			Name:        p.Name,
+			IP:          p.IP,
+			Port:        p.Port,
+			Protocol:    p.Protocol,
+			Username:    p.Username,
+			Password:    p.Password,
+			PasswordRef: p.PasswordRef,
+			Country:     p.Country,
+			Status:      models.StatusUntested,
+			IsActive:    true,
 		}
 		proxies = append(proxies, proxy)
 	}
@@ -114,17 +238,20 @@ func (s *proxyService) BatchDeleteProxy(userID uint64, proxyIDs []uint64) error
 		zap.Uint64("user_id", userID),
 		zap.Int("count", len(proxyIDs)))
 
-	// TODO: Add ownership check here or in repository
-	// For now, we assume the caller has verified ownership or we trust the IDs
-	// Ideally, repo.BatchDelete should accept userID or we filter IDs first.
+	if _, err := s.checkBoundAccounts(userID, proxyIDs); err != nil {
+		return err
+	}
 
-	if err := s.proxyRepo.BatchDelete(proxyIDs); err != nil {
+	// 仓库只删除属于该用户的代理
+	unbound, err := s.proxyRepo.BatchDelete(userID, proxyIDs, models.ProxyBindingActorUser(userID))
+	if err != nil {
 		s.logger.Error("Failed to batch delete proxies",
 			zap.Uint64("user_id", userID),
 			zap.Error(err))
 		return err
 	}
 
+	s.notifyUnbound(userID, proxyIDs, unbound)
 	return nil
 }
 
@@ -210,6 +337,13 @@ func (s *proxyService) UpdateProxy(userID, proxyID uint64, req *models.UpdatePro
 	if req.Password != "" {
 		proxy.Password = req.Password
 	}
+	if req.PasswordRef != nil {
+		// 空字符串清除引用，连接时改用 Password
+		if err := validatePasswordRef(*req.PasswordRef); err != nil {
+			return nil, err
+		}
+		proxy.PasswordRef = strings.TrimSpace(*req.PasswordRef)
+	}
 	if req.Protocol != "" {
 		proxy.Protocol = req.Protocol
 	}
@@ -239,7 +373,17 @@ func (s *proxyService) DeleteProxy(userID, proxyID uint64) error {
 		return err
 	}
 
-	return s.proxyRepo.Delete(proxyID)
+	if _, err := s.checkBoundAccounts(userID, []uint64{proxyID}); err != nil {
+		return err
+	}
+
+	unbound, err := s.proxyRepo.BatchDelete(userID, []uint64{proxyID}, models.ProxyBindingActorUser(userID))
+	if err != nil {
+		return err
+	}
+
+	s.notifyUnbound(userID, []uint64{proxyID}, unbound)
+	return nil
 }
 
 // TestProxy 测试代理连接
@@ -322,14 +466,19 @@ func (s *proxyService) GetProxyStats(userID uint64) (*models.ProxyStats, error)
 func (s *proxyService) testProxyConnection(p *models.ProxyIP) error {
 	var client *http.Client
 
+	password, err := p.ResolvePassword()
+	if err != nil {
+		return err
+	}
+
 	switch p.Protocol {
 	case models.ProxySOCKS5:
 		// SOCKS5 代理
 		var auth *proxy.Auth
-		if p.Username != "" && p.Password != "" {
+		if p.Username != "" && password != "" {
 			auth = &proxy.Auth{
 				User:     p.Username,
-				Password: p.Password,
+				Password: password,
 			}
 		}
 
@@ -349,9 +498,9 @@ func (s *proxyService) testProxyConnection(p *models.ProxyIP) error {
 	case models.ProxyHTTP, models.ProxyHTTPS:
 		// HTTP/HTTPS 代理
 		var proxyURLStr string
-		if p.Username != "" && p.Password != "" {
+		if p.Username != "" && password != "" {
 			proxyURLStr = fmt.Sprintf("http://%s:%s@%s:%d",
-				url.QueryEscape(p.Username), url.QueryEscape(p.Password), p.IP, p.Port)
+				url.QueryEscape(p.Username), url.QueryEscape(password), p.IP, p.Port)
 		} else {
 			proxyURLStr = fmt.Sprintf("http://%s:%d", p.IP, p.Port)
 		}
@@ -392,3 +541,74 @@ func (s *proxyService) testProxyConnection(p *models.ProxyIP) error {
 
 	return nil
 }
+
+// MigrateProxy 将源代理上的所有账号改绑到目标代理，并让在线连接通过新代理重新连接
+func (s *proxyService) MigrateProxy(userID, fromProxyID, toProxyID uint64) (*models.MigrateProxyResult, error) {
+	if fromProxyID == toProxyID {
+		return nil, fmt.Errorf("源代理和目标代理不能相同")
+	}
+	if _, err := s.proxyRepo.GetByUserIDAndID(userID, fromProxyID); err != nil {
+		return nil, ErrProxyNotFound
+	}
+	target, err := s.proxyRepo.GetByUserIDAndID(userID, toProxyID)
+	if err != nil {
+		return nil, ErrProxyNotFound
+	}
+	if !target.IsActive {
+		return nil, fmt.Errorf("目标代理未启用")
+	}
+
+	if s.maxAccountsPerProxy > 0 {
+		migrating, err := s.proxyRepo.CountBoundAccounts(userID, []uint64{fromProxyID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count bound accounts: %w", err)
+		}
+		bound, err := s.proxyRepo.CountBoundAccounts(userID, []uint64{toProxyID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count bound accounts: %w", err)
+		}
+		if bound+migrating > int64(s.maxAccountsPerProxy) {
+			return nil, &ProxyCapacityError{Capacity: s.maxAccountsPerProxy, Bound: bound, Migrating: migrating}
+		}
+	}
+
+	accountIDs, err := s.proxyRepo.MigrateBindings(fromProxyID, toProxyID, models.ProxyBindingActorUser(userID))
+	if err != nil {
+		s.logger.Error("Failed to migrate proxy bindings",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("from_proxy_id", fromProxyID),
+			zap.Uint64("to_proxy_id", toProxyID),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to migrate proxy bindings: %w", err)
+	}
+
+	result := &models.MigrateProxyResult{
+		Migrated:   len(accountIDs),
+		AccountIDs: accountIDs,
+	}
+
+	// 代理变化会关闭现有连接，下次使用时通过新代理建立
+	if s.connectionPool != nil {
+		for _, accountID := range accountIDs {
+			recreated, err := s.connectionPool.ReloadConfig(fmt.Sprintf("%d", accountID))
+			if err != nil {
+				s.logger.Warn("Failed to reload connection config after proxy migration",
+					zap.Uint64("account_id", accountID),
+					zap.Error(err))
+				continue
+			}
+			if recreated {
+				result.Reconnected++
+			}
+		}
+	}
+
+	s.logger.Info("Proxy bindings migrated",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("from_proxy_id", fromProxyID),
+		zap.Uint64("to_proxy_id", toProxyID),
+		zap.Int("migrated", result.Migrated),
+		zap.Int("reconnected", result.Reconnected))
+
+	return result, nil
+}
@@ -2,8 +2,6 @@ package services
 
 import (
 	"context"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +10,7 @@ import (
 	"tg_cloud_server/internal/common/logger"
 	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/repository"
+	"tg_cloud_server/internal/telegram"
 )
 
 // RiskControlService 风控服务接口
@@ -279,15 +278,10 @@ func (s *riskControlService) HandleTelegramError(ctx context.Context, accountID
 		zap.String("error", err.Error()))
 }
 
-// parseFloodWaitSeconds 解析 FLOOD_WAIT 错误中的等待秒数
+// parseFloodWaitSeconds 解析 FLOOD_WAIT 错误中的等待秒数，与连接层使用同一解析规则
 func (s *riskControlService) parseFloodWaitSeconds(errorStr string) int {
-	// 匹配 FLOOD_WAIT_123 或 FLOOD_WAIT (123) 等格式
-	re := regexp.MustCompile(`FLOOD_WAIT[_\s]*(\d+)`)
-	matches := re.FindStringSubmatch(errorStr)
-	if len(matches) >= 2 {
-		if seconds, err := strconv.Atoi(matches[1]); err == nil {
-			return seconds
-		}
+	if seconds, ok := telegram.ParseFloodWaitSeconds(errorStr); ok {
+		return seconds
 	}
 	// 默认返回 300 秒（5分钟）
 	return 300
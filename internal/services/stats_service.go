@@ -18,6 +18,7 @@ type StatsService interface {
 	GetSystemOverview(ctx context.Context, userID uint64, period string) (*models.SystemOverview, error)
 	GetAccountStatistics(ctx context.Context, userID uint64, period string, status string) (*models.AccountStatistics, error)
 	GetUserDashboard(ctx context.Context, userID uint64) (*models.UserDashboard, error)
+	GetTaskTypeStats(ctx context.Context, userID uint64, from, to time.Time) (*models.TaskTypeStatsReport, error)
 
 	// 实时统计
 	GetRealTimeStats(ctx context.Context, userID uint64) (map[string]interface{}, error)
@@ -236,6 +237,24 @@ func (s *statsService) GetUserDashboard(ctx context.Context, userID uint64) (*mo
 	return dashboard, nil
 }
 
+// GetTaskTypeStats 获取按任务类型划分的完成/失败/运行数量及平均耗时
+func (s *statsService) GetTaskTypeStats(ctx context.Context, userID uint64, from, to time.Time) (*models.TaskTypeStatsReport, error) {
+	types, err := s.taskRepo.GetTaskStats(userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task type stats: %w", err)
+	}
+	if types == nil {
+		types = []*models.TaskTypeStats{}
+	}
+
+	return &models.TaskTypeStatsReport{
+		From:        from,
+		To:          to,
+		Types:       types,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
 // GetRealTimeStats 获取实时统计
 func (s *statsService) GetRealTimeStats(ctx context.Context, userID uint64) (map[string]interface{}, error) {
 	stats := map[string]interface{}{
@@ -184,9 +184,9 @@ func (s *taskLogService) CreateLog(ctx context.Context, log *TaskLogEntry) error
 		zap.String("level", string(log.Level)),
 		zap.String("action", log.Action))
 
-	// 推送给订阅者（异步，不阻塞）
+	// 推送给订阅者（推送本身不阻塞，同步调用以保证日志顺序）
 	if s.logPusher != nil {
-		go s.logPusher.PushTaskLog(log.TaskID, log)
+		s.logPusher.PushTaskLog(log.TaskID, log)
 	} else {
 		s.logger.Warn("No log pusher configured, skipping push")
 	}
@@ -227,7 +227,7 @@ func (s *taskLogService) BatchCreateLogs(ctx context.Context, logs []*TaskLogEnt
 	// 推送给订阅者
 	if s.logPusher != nil {
 		for _, log := range logs {
-			go s.logPusher.PushTaskLog(log.TaskID, log)
+			s.logPusher.PushTaskLog(log.TaskID, log)
 		}
 	}
 
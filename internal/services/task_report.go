@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 任务报告格式
+const (
+	ReportFormatHTML = "html"
+	ReportFormatPDF  = "pdf"
+)
+
+// ErrUnsupportedReportFormat 不支持的报告格式
+var ErrUnsupportedReportFormat = errors.New("unsupported report format")
+
+// TaskReport 生成的任务报告文件
+type TaskReport struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// taskReportData 报告模板数据
+type taskReportData struct {
+	Task        *models.Task
+	GeneratedAt time.Time
+	Duration    string
+
+	TotalAccounts int
+	SuccessCount  int
+	FailCount     int
+	SkippedCount  int
+
+	TotalTargets int
+	SentTargets  int
+	Targets      []reportTarget
+	Accounts     []reportAccount
+	Timeline     []*models.TaskLog
+}
+
+// reportTarget 单个发送目标的结果
+type reportTarget struct {
+	Name   string
+	Status string
+}
+
+// reportAccount 单个账号的执行统计
+type reportAccount struct {
+	AccountID string
+	Phone     string
+	Status    string
+	Sent      int
+	Failed    int
+	Duration  string
+	Error     string
+}
+
+// GenerateReport 根据任务结果和日志生成可分享的任务报告
+// 目前仅支持 HTML 格式，需要 PDF 时可在浏览器中打开 HTML 报告后打印为 PDF
+func (s *TaskService) GenerateReport(userID, taskID uint64, format string) (*TaskReport, error) {
+	if format == "" {
+		format = ReportFormatHTML
+	}
+	if format != ReportFormatHTML {
+		return nil, fmt.Errorf("%w: %s (only html is supported, print the html report to get a pdf)", ErrUnsupportedReportFormat, format)
+	}
+
+	task, err := s.taskRepo.GetByUserIDAndID(userID, taskID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	logs, err := s.taskRepo.GetTaskLogs(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task logs: %w", err)
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].CreatedAt.Before(logs[j].CreatedAt)
+	})
+
+	data := s.buildReportData(task, logs)
+
+	var buf bytes.Buffer
+	if err := taskReportTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+
+	s.logger.Info("Task report generated",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("task_id", taskID),
+		zap.String("format", format),
+		zap.Int("size", buf.Len()))
+
+	return &TaskReport{
+		FileName:    fmt.Sprintf("task_%d_report_%s.html", task.ID, time.Now().Format("20060102_150405")),
+		ContentType: "text/html; charset=utf-8",
+		Content:     buf.Bytes(),
+	}, nil
+}
+
+// buildReportData 汇总任务结果：账号统计、目标发送情况和时间线
+func (s *TaskService) buildReportData(task *models.Task, logs []*models.TaskLog) *taskReportData {
+	data := &taskReportData{
+		Task:        task,
+		GeneratedAt: time.Now(),
+		Timeline:    logs,
+	}
+
+	if task.StartedAt != nil {
+		end := time.Now()
+		if task.CompletedAt != nil {
+			end = *task.CompletedAt
+		}
+		data.Duration = end.Sub(*task.StartedAt).Round(time.Second).String()
+	}
+
+	sent := make(map[string]bool)
+	accountResults, _ := task.Result["account_results"].(map[string]interface{})
+	for accountID, raw := range accountResults {
+		result, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		account := reportAccount{
+			AccountID: accountID,
+			Sent:      reportInt(result["sent_count"]),
+			Failed:    reportInt(result["failed_count"]),
+		}
+		account.Status, _ = result["status"].(string)
+		account.Duration, _ = result["duration"].(string)
+		account.Error, _ = result["error"].(string)
+		if account.Error == "" {
+			account.Error, _ = result["reason"].(string)
+		}
+		if id, err := strconv.ParseUint(accountID, 10, 64); err == nil {
+			if acc, err := s.accountRepo.GetByID(id); err == nil {
+				account.Phone = acc.Phone
+			}
+		}
+
+		switch account.Status {
+		case "success":
+			data.SuccessCount++
+		case "skipped":
+			data.SkippedCount++
+		default:
+			data.FailCount++
+		}
+
+		for _, key := range []string{"sent_groups", "sent_targets"} {
+			if list, ok := result[key].([]interface{}); ok {
+				for _, item := range list {
+					sent[reportTargetName(item)] = true
+				}
+			}
+		}
+		data.Accounts = append(data.Accounts, account)
+	}
+	sort.Slice(data.Accounts, func(i, j int) bool {
+		return data.Accounts[i].AccountID < data.Accounts[j].AccountID
+	})
+	data.TotalAccounts = len(task.GetAccountIDList())
+
+	unsent := make(map[string]bool)
+	if list, ok := task.Result["unsent_groups"].([]interface{}); ok {
+		for _, item := range list {
+			unsent[reportTargetName(item)] = true
+		}
+	}
+
+	var targets []interface{}
+	if list, ok := task.Config["groups"].([]interface{}); ok {
+		targets = list
+	} else if list, ok := task.Config["targets"].([]interface{}); ok {
+		targets = list
+	}
+	for _, item := range targets {
+		name := reportTargetName(item)
+		target := reportTarget{Name: name, Status: "failed"}
+		switch {
+		case sent[name]:
+			target.Status = "sent"
+			data.SentTargets++
+		case unsent[name]:
+			target.Status = "unsent"
+		}
+		data.Targets = append(data.Targets, target)
+	}
+	data.TotalTargets = len(targets)
+
+	return data
+}
+
+// reportInt 读取结果中的计数（JSON 反序列化后为 float64）
+func reportInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+var taskReportTemplate = template.Must(template.New("task_report").Funcs(template.FuncMap{
+	"fmtTime": func(t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			return v.Format("2006-01-02 15:04:05")
+		case *time.Time:
+			if v != nil {
+				return v.Format("2006-01-02 15:04:05")
+			}
+		}
+		return "-"
+	},
+	"inc": func(i int) int { return i + 1 },
+	"percent": func(part, total int) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>任务报告 #{{.Task.ID}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 32px; color: #222; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 17px; margin-top: 28px; border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+.meta { color: #666; font-size: 13px; }
+.cards { display: flex; gap: 12px; flex-wrap: wrap; margin-top: 16px; }
+.card { border: 1px solid #e3e3e3; border-radius: 6px; padding: 12px 16px; min-width: 120px; }
+.card .value { font-size: 22px; font-weight: 600; }
+.card .label { color: #666; font-size: 12px; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; margin-top: 8px; }
+th, td { border: 1px solid #e3e3e3; padding: 6px 8px; text-align: left; vertical-align: top; }
+th { background: #f6f6f6; }
+.success, .sent { color: #1a7f37; }
+.failed, .error { color: #cf222e; }
+.skipped, .unsent, .warn { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>任务报告 #{{.Task.ID}}</h1>
+<div class="meta">类型: {{.Task.TaskType}} · 状态: {{.Task.Status}} · 生成时间: {{fmtTime .GeneratedAt}}</div>
+
+<h2>概览</h2>
+<table>
+<tr><th>创建时间</th><td>{{fmtTime .Task.CreatedAt}}</td><th>开始时间</th><td>{{fmtTime .Task.StartedAt}}</td></tr>
+<tr><th>完成时间</th><td>{{fmtTime .Task.CompletedAt}}</td><th>耗时</th><td>{{if .Duration}}{{.Duration}}{{else}}-{{end}}</td></tr>
+</table>
+<div class="cards">
+<div class="card"><div class="value">{{.TotalAccounts}}</div><div class="label">账号数</div></div>
+<div class="card"><div class="value success">{{.SuccessCount}}</div><div class="label">成功账号</div></div>
+<div class="card"><div class="value failed">{{.FailCount}}</div><div class="label">失败账号</div></div>
+<div class="card"><div class="value skipped">{{.SkippedCount}}</div><div class="label">跳过账号</div></div>
+{{if .TotalTargets}}
+<div class="card"><div class="value">{{.SentTargets}}/{{.TotalTargets}}</div><div class="label">目标送达 ({{percent .SentTargets .TotalTargets}})</div></div>
+{{end}}
+</div>
+
+{{if .Targets}}
+<h2>目标</h2>
+<table>
+<tr><th>#</th><th>目标</th><th>结果</th></tr>
+{{range $i, $t := .Targets}}<tr><td>{{inc $i}}</td><td>{{$t.Name}}</td><td class="{{$t.Status}}">{{if eq $t.Status "sent"}}已发送{{else if eq $t.Status "unsent"}}未发送{{else}}失败{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>账号统计</h2>
+{{if .Accounts}}
+<table>
+<tr><th>账号ID</th><th>手机号</th><th>状态</th><th>成功</th><th>失败</th><th>耗时</th><th>说明</th></tr>
+{{range .Accounts}}<tr><td>{{.AccountID}}</td><td>{{if .Phone}}{{.Phone}}{{else}}-{{end}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Sent}}</td><td>{{.Failed}}</td><td>{{if .Duration}}{{.Duration}}{{else}}-{{end}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{else}}
+<p class="meta">暂无账号执行结果</p>
+{{end}}
+
+<h2>时间线</h2>
+{{if .Timeline}}
+<table>
+<tr><th>时间</th><th>级别</th><th>事件</th><th>内容</th></tr>
+{{range .Timeline}}<tr><td>{{fmtTime .CreatedAt}}</td><td class="{{.Level}}">{{.Level}}</td><td>{{.Action}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{else}}
+<p class="meta">暂无日志</p>
+{{end}}
+</body>
+</html>
+`))
+
+// reportTargetName 返回报告中目标的名称，私信目标可能是带 vars 的对象，取其用户名
+func reportTargetName(item interface{}) string {
+	if target, err := models.MessageTargetFromConfig(item); err == nil {
+		return target.Username
+	}
+	return fmt.Sprintf("%v", item)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -12,16 +13,21 @@ import (
 	"tg_cloud_server/internal/common/logger"
 	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/repository"
+	"tg_cloud_server/internal/telegram"
 )
 
 var (
-	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrInvalidTaskConfig = errors.New("invalid task config")
+	ErrTaskNotFinished   = errors.New("task is not in a terminal state")
 )
 
 // TaskSchedulerInterface 任务调度器接口
 type TaskSchedulerInterface interface {
 	SubmitTask(task *models.Task) error
-	StopTask(taskID uint64) bool // 停止任务，返回是否成功从队列或运行中移除
+	StopTask(taskID uint64) bool    // 停止任务，返回是否成功从队列或运行中移除
+	PauseTask(taskID uint64) bool   // 暂停任务并保留进度，返回是否找到排队或执行中的任务
+	ResumeTask(taskID uint64) error // 恢复已暂停的任务，从暂停时的进度继续
 }
 
 // TaskService 任务管理服务
@@ -30,6 +36,10 @@ type TaskService struct {
 	accountRepo repository.AccountRepository
 	scheduler   TaskSchedulerInterface
 	logger      *zap.Logger
+
+	// 场景任务限制
+	maxScenarioAgents  int
+	maxTotalActiveRate float64
 }
 
 // NewTaskService 创建任务管理服务
@@ -39,9 +49,18 @@ func NewTaskService(taskRepo repository.TaskRepository, accountRepo repository.A
 		accountRepo: accountRepo,
 		scheduler:   nil, // 稍后通过 SetTaskScheduler 设置
 		logger:      logger.Get().Named("task_service"),
+
+		maxScenarioAgents:  10,
+		maxTotalActiveRate: 3.0,
 	}
 }
 
+// SetScenarioLimits 设置场景任务的智能体数量和活跃度上限
+func (s *TaskService) SetScenarioLimits(maxAgents int, maxTotalActiveRate float64) {
+	s.maxScenarioAgents = maxAgents
+	s.maxTotalActiveRate = maxTotalActiveRate
+}
+
 // SetTaskScheduler 设置任务调度器
 func (s *TaskService) SetTaskScheduler(scheduler TaskSchedulerInterface) {
 	s.scheduler = scheduler
@@ -62,6 +81,14 @@ func (s *TaskService) loadPendingTasks() {
 		return
 	}
 
+	// 重启前已排队的任务（包括等待计划执行时间的定时任务）只保存在内存队列中，重新提交
+	queuedTasks, err := s.taskRepo.GetTasksByStatus(models.TaskStatusQueued)
+	if err != nil {
+		s.logger.Error("Failed to load queued tasks", zap.Error(err))
+	} else {
+		pendingTasks = append(pendingTasks, queuedTasks...)
+	}
+
 	submitted := 0
 	failed := 0
 
@@ -114,8 +141,60 @@ func (s *TaskService) CreateTask(userID uint64, req *models.CreateTaskRequest) (
 		return nil, err
 	}
 
+	// 按任务类型的配置结构校验，在入队前返回具体字段错误
+	if err := validateTaskConfig(req.TaskType, req.Config); err != nil {
+		s.logger.Warn("Task config validation failed",
+			zap.Uint64("user_id", userID),
+			zap.String("task_type", string(req.TaskType)),
+			zap.Error(err))
+		return nil, err
+	}
+
+	// 场景任务校验智能体配置
+	if req.TaskType == models.TaskTypeScenario {
+		if err := s.validateScenario(userID, req.Config); err != nil {
+			s.logger.Warn("Scenario validation failed",
+				zap.Uint64("user_id", userID),
+				zap.Error(err))
+			return nil, err
+		}
+	}
+
+	// 校验消息格式，避免任务运行时才发现标记错误
+	if err := validateMessageFormat(req.Config); err != nil {
+		s.logger.Warn("Message format validation failed",
+			zap.Uint64("user_id", userID),
+			zap.String("task_type", string(req.TaskType)),
+			zap.Error(err))
+		return nil, err
+	}
+
+	// 校验 AI 采样参数覆盖值
+	if err := validateAISampling(req.Config); err != nil {
+		s.logger.Warn("AI sampling validation failed",
+			zap.Uint64("user_id", userID),
+			zap.String("task_type", string(req.TaskType)),
+			zap.Error(err))
+		return nil, err
+	}
+
+	if err := validateSendOptions(req.Config); err != nil {
+		return nil, err
+	}
+
+	// 排除的账号不参与执行，无需校验可用性
+	excluded, err := excludedAccounts(req.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	// 验证所有账号是否属于用户且可用
+	remaining := 0
 	for _, accountID := range req.AccountIDs {
+		if excluded[accountID] {
+			continue
+		}
+		remaining++
 		account, err := s.accountRepo.GetByUserIDAndID(userID, accountID)
 		if err != nil {
 			s.logger.Warn("Account not found or not owned by user",
@@ -134,6 +213,9 @@ func (s *TaskService) CreateTask(userID uint64, req *models.CreateTaskRequest) (
 			return nil, fmt.Errorf("account %d is not available, status: %s", accountID, account.Status)
 		}
 	}
+	if len(excluded) > 0 && remaining == 0 {
+		return nil, errAllAccountsExcluded
+	}
 
 	// 确保 Config 不为 nil，如果是 nil 则初始化为空 map
 	config := req.Config
@@ -153,7 +235,12 @@ func (s *TaskService) CreateTask(userID uint64, req *models.CreateTaskRequest) (
 	// 设置账号ID列表
 	task.SetAccountIDList(req.AccountIDs)
 
-	if req.ScheduleAt != nil {
+	// 使用 Telegram 定时消息时由 Telegram 在 schedule_at 发送，任务本身立即执行
+	telegramScheduled, err := applyTelegramSchedule(req.TaskType, config, req.ScheduleAt)
+	if err != nil {
+		return nil, err
+	}
+	if req.ScheduleAt != nil && !telegramScheduled {
 		task.ScheduledAt = req.ScheduleAt
 	}
 
@@ -208,6 +295,126 @@ func (s *TaskService) CreateTask(userID uint64, req *models.CreateTaskRequest) (
 	return task, nil
 }
 
+// errAllAccountsExcluded exclude_account_ids 排除了任务的全部账号
+var errAllAccountsExcluded = fmt.Errorf("%w: all accounts are excluded by exclude_account_ids", ErrInvalidTaskConfig)
+
+// excludedAccounts 解析配置中的 exclude_account_ids
+func excludedAccounts(config models.TaskConfig) (map[uint64]bool, error) {
+	excludeIDs, err := models.ExcludeAccountIDsFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	excluded := make(map[uint64]bool, len(excludeIDs))
+	for _, id := range excludeIDs {
+		excluded[id] = true
+	}
+	return excluded, nil
+}
+
+// validateScenario 校验场景任务配置：智能体数量、账号归属和活跃度总和
+func (s *TaskService) validateScenario(userID uint64, config models.TaskConfig) error {
+	scenario, err := models.ParseAgentScenario(config)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+
+	if err := scenario.Validate(s.maxScenarioAgents, s.maxTotalActiveRate); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+
+	for _, accountID := range scenario.AccountIDs() {
+		if _, err := s.accountRepo.GetByUserIDAndID(userID, accountID); err != nil {
+			return fmt.Errorf("%w: 智能体账号 %d 不存在或不属于当前用户", ErrInvalidTaskConfig, accountID)
+		}
+	}
+	return nil
+}
+
+// validateTaskConfig 按任务类型注册的配置结构校验必填项、字段类型和取值范围
+func validateTaskConfig(taskType models.TaskType, config models.TaskConfig) error {
+	if err := models.ValidateTaskConfig(taskType, config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	return nil
+}
+
+// validateMessageFormat 校验 parse_mode 取值及 message / sequence 能否按该模式正确解析
+func validateMessageFormat(config models.TaskConfig) error {
+	parseMode, _ := config["parse_mode"].(string)
+	if err := telegram.ValidateParseMode(parseMode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	if message, ok := config["message"].(string); ok && message != "" {
+		if _, _, err := telegram.FormatMessage(message, parseMode); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+		}
+	}
+	if _, err := telegram.MessageSequenceFromConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	if _, err := telegram.BroadcastMediaFromConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	return nil
+}
+
+// applyTelegramSchedule 处理 use_telegram_schedule：将 schedule_at 写入配置的 telegram_schedule_date，
+// 由 Telegram 原生定时消息在该时间发送，服务端宕机也不影响；返回是否已按 Telegram 定时处理
+// 仅支持单条消息的私信和群发，schedule_at 须在 Telegram 允许的时间窗口内
+func applyTelegramSchedule(taskType models.TaskType, config models.TaskConfig, scheduleAt *time.Time) (bool, error) {
+	if enabled, _ := config["use_telegram_schedule"].(bool); !enabled {
+		return false, nil
+	}
+	if taskType != models.TaskTypePrivate && taskType != models.TaskTypeBroadcast {
+		return false, fmt.Errorf("%w: use_telegram_schedule only supports private and broadcast tasks", ErrInvalidTaskConfig)
+	}
+	if _, ok := config["sequence"]; ok {
+		return false, fmt.Errorf("%w: use_telegram_schedule does not support message sequences", ErrInvalidTaskConfig)
+	}
+
+	if scheduleAt == nil {
+		// 更新任务时未修改时间，沿用已有的定时时间
+		if _, err := telegram.TelegramScheduleDateFromConfig(config); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+		}
+		return true, nil
+	}
+
+	if err := telegram.ValidateTelegramScheduleDate(*scheduleAt); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	config["telegram_schedule_date"] = float64(scheduleAt.Unix())
+	return true, nil
+}
+
+// validateAISampling 校验任务配置中的 temperature/top_p 取值范围及 AI 输出后处理步骤
+func validateAISampling(config models.TaskConfig) error {
+	if _, err := models.AISamplingFromConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	if _, err := models.AIPostProcessFromConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	return nil
+}
+
+// validateSendOptions 校验群发账号被禁言时的处理策略、发送间隔分布及金丝雀配置
+func validateSendOptions(config models.TaskConfig) error {
+	policy, _ := config["write_forbidden_policy"].(string)
+	switch policy {
+	case "", telegram.WriteForbiddenPolicyStop, telegram.WriteForbiddenPolicyContinue:
+	default:
+		return fmt.Errorf("%w: invalid write_forbidden_policy: %s", ErrInvalidTaskConfig, policy)
+	}
+	if _, err := telegram.SendDelayFromConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	if _, err := telegram.BroadcastCanaryFromConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTaskConfig, err)
+	}
+	return nil
+}
+
 // GetTasks 获取任务列表
 func (s *TaskService) GetTasks(filter *TaskFilter) ([]*models.TaskSummary, int64, error) {
 	offset := (filter.Page - 1) * filter.Limit
@@ -250,12 +457,51 @@ func (s *TaskService) UpdateTask(userID, taskID uint64, req *models.UpdateTaskRe
 		task.Priority = req.Priority
 	}
 
-	if req.ScheduleAt != nil {
-		task.ScheduledAt = req.ScheduleAt
+	config := task.Config
+	if req.Config != nil {
+		if err := validateTaskConfig(task.TaskType, req.Config); err != nil {
+			return nil, err
+		}
+		if err := validateMessageFormat(req.Config); err != nil {
+			return nil, err
+		}
+		if err := validateAISampling(req.Config); err != nil {
+			return nil, err
+		}
+		if err := validateSendOptions(req.Config); err != nil {
+			return nil, err
+		}
+		// 场景任务重新校验智能体数量、活跃度和账号归属，与创建时一致
+		if task.TaskType == models.TaskTypeScenario {
+			if err := s.validateScenario(userID, req.Config); err != nil {
+				return nil, err
+			}
+		}
+		excluded, err := excludedAccounts(req.Config)
+		if err != nil {
+			return nil, err
+		}
+		if len(excluded) > 0 {
+			remaining := 0
+			for _, accountID := range task.GetAccountIDList() {
+				if !excluded[accountID] {
+					remaining++
+				}
+			}
+			if remaining == 0 {
+				return nil, errAllAccountsExcluded
+			}
+		}
+		config = req.Config
 	}
 
-	if req.Config != nil {
-		task.Config = req.Config
+	telegramScheduled, err := applyTelegramSchedule(task.TaskType, config, req.ScheduleAt)
+	if err != nil {
+		return nil, err
+	}
+	task.Config = config
+	if req.ScheduleAt != nil && !telegramScheduled {
+		task.ScheduledAt = req.ScheduleAt
 	}
 
 	if err := s.taskRepo.Update(task); err != nil {
@@ -398,6 +644,77 @@ func (s *TaskService) RetryTask(userID, taskID uint64) (*models.Task, error) {
 	return task, nil
 }
 
+// RequeueTask 将已结束的任务重新排队，从头开始执行
+// 上一次执行的状态和结果归档到任务日志中，任务本身的结果被清空
+func (s *TaskService) RequeueTask(userID, taskID uint64) (*models.Task, error) {
+	task, err := s.taskRepo.GetByUserIDAndID(userID, taskID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	if !task.IsCompleted() {
+		return nil, fmt.Errorf("%w: current status %s", ErrTaskNotFinished, task.Status)
+	}
+
+	if s.scheduler == nil {
+		return nil, fmt.Errorf("task scheduler not available")
+	}
+
+	previousStatus := task.Status
+
+	// 归档上一次执行
+	prior := map[string]interface{}{
+		"previous_status":       previousStatus,
+		"previous_started_at":   task.StartedAt,
+		"previous_completed_at": task.CompletedAt,
+		"previous_result":       task.Result,
+	}
+	extraData, err := json.Marshal(prior)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive previous result: %w", err)
+	}
+	if err := s.taskRepo.CreateTaskLog(&models.TaskLog{
+		TaskID:    taskID,
+		Level:     string(LogLevelInfo),
+		Action:    "task_requeued",
+		Message:   fmt.Sprintf("任务重新排队，上次执行状态: %s", previousStatus),
+		ExtraData: extraData,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to archive previous result: %w", err)
+	}
+
+	// 重置任务状态
+	task.Status = models.TaskStatusPending
+	task.StartedAt = nil
+	task.CompletedAt = nil
+	task.Result = make(models.TaskResult)
+
+	if err := s.taskRepo.Update(task); err != nil {
+		logger.LogTask(zapcore.ErrorLevel, "Failed to reset task for requeue",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("task_id", taskID),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	if err := s.scheduler.SubmitTask(task); err != nil {
+		logger.LogTask(zapcore.ErrorLevel, "Failed to submit requeued task",
+			zap.Uint64("user_id", userID),
+			zap.Uint64("task_id", taskID),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	logger.LogTask(zapcore.InfoLevel, "Task requeued",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("task_id", taskID),
+		zap.String("task_type", string(task.TaskType)),
+		zap.String("previous_status", string(previousStatus)),
+		zap.Any("account_ids", task.GetAccountIDList()))
+
+	return task, nil
+}
+
 // StartTask 启动任务
 func (s *TaskService) StartTask(userID, taskID uint64) error {
 	s.logger.Info("Starting task manually",
@@ -413,8 +730,13 @@ func (s *TaskService) StartTask(userID, taskID uint64) error {
 		return ErrTaskNotFound
 	}
 
+	// 已暂停的任务从暂停时的进度继续
+	if task.Status == models.TaskStatusPaused {
+		return s.ResumeTask(userID, taskID)
+	}
+
 	// 检查任务状态是否可以启动
-	if task.Status != models.TaskStatusPending && task.Status != models.TaskStatusPaused {
+	if task.Status != models.TaskStatusPending {
 		s.logger.Warn("Task cannot be started due to status",
 			zap.Uint64("task_id", taskID),
 			zap.String("current_status", string(task.Status)))
@@ -447,6 +769,59 @@ func (s *TaskService) StartTask(userID, taskID uint64) error {
 	return nil
 }
 
+// PauseTask 暂停排队中或执行中的任务，保留执行进度
+func (s *TaskService) PauseTask(userID, taskID uint64) error {
+	task, err := s.taskRepo.GetByUserIDAndID(userID, taskID)
+	if err != nil {
+		return ErrTaskNotFound
+	}
+
+	if task.Status != models.TaskStatusQueued && task.Status != models.TaskStatusRunning {
+		return fmt.Errorf("task status %s cannot be paused", task.Status)
+	}
+	// 场景任务没有可恢复的进度，只能停止
+	if task.TaskType == models.TaskTypeScenario {
+		return fmt.Errorf("scenario tasks cannot be paused, use stop instead")
+	}
+	if s.scheduler == nil {
+		return fmt.Errorf("task scheduler not available")
+	}
+	if !s.scheduler.PauseTask(taskID) {
+		return fmt.Errorf("task %d is not queued or running", taskID)
+	}
+
+	logger.LogTask(zapcore.InfoLevel, "Task paused",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("task_id", taskID),
+		zap.String("task_type", string(task.TaskType)),
+		zap.String("previous_status", string(task.Status)))
+	return nil
+}
+
+// ResumeTask 恢复已暂停的任务
+func (s *TaskService) ResumeTask(userID, taskID uint64) error {
+	task, err := s.taskRepo.GetByUserIDAndID(userID, taskID)
+	if err != nil {
+		return ErrTaskNotFound
+	}
+
+	if task.Status != models.TaskStatusPaused {
+		return fmt.Errorf("task status %s cannot be resumed", task.Status)
+	}
+	if s.scheduler == nil {
+		return fmt.Errorf("task scheduler not available")
+	}
+	if err := s.scheduler.ResumeTask(taskID); err != nil {
+		return fmt.Errorf("failed to resume task: %w", err)
+	}
+
+	logger.LogTask(zapcore.InfoLevel, "Task resumed",
+		zap.Uint64("user_id", userID),
+		zap.Uint64("task_id", taskID),
+		zap.String("task_type", string(task.TaskType)))
+	return nil
+}
+
 // StopTask 停止任务（真正中断执行）
 func (s *TaskService) StopTask(userID, taskID uint64) error {
 	s.logger.Info("Stopping task",
@@ -514,7 +889,11 @@ func (s *TaskService) BatchControlTasks(userID uint64, req *models.BatchTaskCont
 		switch req.Action {
 		case "start":
 			err = s.StartTask(userID, taskID)
-		case "pause", "stop":
+		case "pause":
+			err = s.PauseTask(userID, taskID)
+		case "resume":
+			err = s.ResumeTask(userID, taskID)
+		case "stop":
 			err = s.StopTask(userID, taskID)
 		case "cancel":
 			err = s.CancelTask(userID, taskID)
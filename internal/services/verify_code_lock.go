@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// verifyCodeLockMargin 锁的过期时间在监听超时之外预留的余量，防止进程异常退出后锁一直不释放
+const verifyCodeLockMargin = 30 * time.Second
+
+// releaseVerifyCodeLockScript 仅当锁仍由自己持有时删除，避免误删过期后被其他请求获取的锁
+var releaseVerifyCodeLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// SetRedisClient 设置 Redis 客户端，用于多实例间的验证码监听锁；未设置时只在本进程内加锁
+func (s *VerifyCodeService) SetRedisClient(client *redis.Client) {
+	s.redisClient = client
+}
+
+// verifyCodeLockKey 账号验证码监听锁的键
+func verifyCodeLockKey(accountID uint64) string {
+	return fmt.Sprintf("verify_code:listening:%d", accountID)
+}
+
+// acquireListenLock 获取账号的验证码监听锁，同一账号同一时间只允许一个监听
+// 返回释放函数；已有监听在进行时返回 models.ErrVerifyInProgress
+func (s *VerifyCodeService) acquireListenLock(ctx context.Context, accountID uint64, timeout time.Duration) (func(), error) {
+	s.listenMutex.Lock()
+	if s.listening[accountID] {
+		s.listenMutex.Unlock()
+		return nil, models.ErrVerifyInProgress
+	}
+	s.listening[accountID] = true
+	s.listenMutex.Unlock()
+
+	releaseLocal := func() {
+		s.listenMutex.Lock()
+		delete(s.listening, accountID)
+		s.listenMutex.Unlock()
+	}
+
+	if s.redisClient == nil {
+		return releaseLocal, nil
+	}
+
+	tokenBytes := make([]byte, 8)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		releaseLocal()
+		return nil, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+	key := verifyCodeLockKey(accountID)
+
+	acquired, err := s.redisClient.SetNX(ctx, key, token, timeout+verifyCodeLockMargin).Result()
+	if err != nil {
+		// Redis 不可用时退化为进程内锁，不阻断验证码获取
+		s.logger.Warn("Failed to acquire verify code lock from Redis, using local lock only",
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		return releaseLocal, nil
+	}
+	if !acquired {
+		releaseLocal()
+		return nil, models.ErrVerifyInProgress
+	}
+
+	return func() {
+		if err := releaseVerifyCodeLockScript.Run(context.Background(), s.redisClient, []string{key}, token).Err(); err != nil && err != redis.Nil {
+			s.logger.Warn("Failed to release verify code lock",
+				zap.Uint64("account_id", accountID),
+				zap.Error(err))
+		}
+		releaseLocal()
+	}, nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/gotd/td/tg"
 	"go.uber.org/zap"
 
@@ -23,6 +25,11 @@ type VerifyCodeService struct {
 	verifyCodeRepo repository.VerifyCodeRepository
 	connectionPool *telegram.ConnectionPool
 	logger         *zap.Logger
+
+	// 验证码监听锁：同一账号同一时间只允许一个监听，避免并发请求抢读同一条验证码消息
+	redisClient *redis.Client
+	listening   map[uint64]bool
+	listenMutex sync.Mutex
 }
 
 // NewVerifyCodeService 创建验证码服务
@@ -39,6 +46,7 @@ func NewVerifyCodeService(
 		verifyCodeRepo: verifyCodeRepo,
 		connectionPool: connectionPool,
 		logger:         logger.Named("verify_code_service"),
+		listening:      make(map[uint64]bool),
 	}
 
 	// 启动清理过期会话的协程
@@ -342,6 +350,26 @@ func (s *VerifyCodeService) GetVerifyCode(ctx context.Context, code string, time
 		timeoutSeconds = 300 // 最多5分钟
 	}
 
+	// 同一账号已有监听时直接拒绝
+	release, err := s.acquireListenLock(ctx, account.ID, time.Duration(timeoutSeconds)*time.Second)
+	if err != nil {
+		s.logger.Warn("Verification code listener already running for account",
+			zap.String("code", code),
+			zap.Uint64("account_id", account.ID),
+			zap.Error(err))
+		if verifyErr, ok := err.(*models.VerifyCodeError); ok {
+			return &models.VerifyCodeResponse{
+				Success: false,
+				Message: verifyErr.Message,
+			}, verifyErr
+		}
+		return &models.VerifyCodeResponse{
+			Success: false,
+			Message: "验证码获取失败",
+		}, err
+	}
+	defer release()
+
 	// 创建验证码获取任务
 	task := &verifyCodeTask{
 		timeoutSeconds: timeoutSeconds,
@@ -583,74 +611,5 @@ func (t *verifyCodeTask) searchVerifyCode(dialogs tg.MessagesDialogsClass, sende
 
 // extractVerificationCode 从消息文本中提取验证码
 func (t *verifyCodeTask) extractVerificationCode(message string) string {
-	// 常见的验证码模式
-	patterns := []string{
-		"code", "verification", "verify", "login", "telegram",
-		"验证码", "验证", "登录", "代码",
-	}
-
-	// 简单的数字提取逻辑 (4-8位数字)
-	var digits []rune
-	for _, char := range message {
-		if char >= '0' && char <= '9' {
-			digits = append(digits, char)
-		}
-	}
-
-	// 检查是否包含验证码关键词
-	messageContainsPattern := false
-	for _, pattern := range patterns {
-		if t.containsIgnoreCase(message, pattern) {
-			messageContainsPattern = true
-			break
-		}
-	}
-
-	// 如果包含关键词且数字长度合适
-	if messageContainsPattern && len(digits) >= 4 && len(digits) <= 8 {
-		return string(digits)
-	}
-
-	return ""
-}
-
-// containsIgnoreCase 不区分大小写的包含检查
-func (t *verifyCodeTask) containsIgnoreCase(text, pattern string) bool {
-	textLower := t.toLowerCase(text)
-	patternLower := t.toLowerCase(pattern)
-	return t.contains(textLower, patternLower)
-}
-
-// toLowerCase 转换为小写
-func (t *verifyCodeTask) toLowerCase(str string) string {
-	result := make([]rune, len(str))
-	for i, r := range str {
-		if r >= 'A' && r <= 'Z' {
-			result[i] = r + 32
-		} else {
-			result[i] = r
-		}
-	}
-	return string(result)
-}
-
-// contains 检查字符串是否包含子字符串
-func (t *verifyCodeTask) contains(str, substr string) bool {
-	if len(substr) > len(str) {
-		return false
-	}
-
-	for i := 0; i <= len(str)-len(substr); i++ {
-		match := true
-		for j := 0; j < len(substr); j++ {
-			if str[i+j] != substr[j] {
-				match = false
-				break
-			}
-		}
-		if match {
-			return true
-		}
-	}
-	return false
+	return telegram.ExtractVerificationCode(message)
 }
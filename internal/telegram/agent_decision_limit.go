@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/metrics"
+	"tg_cloud_server/internal/models"
+)
+
+// decisionLimit 计算场景的决策并发上限：场景配置 > 系统配置 > 默认值
+func decisionLimit(scenario *models.AgentScenario, configured int) int {
+	if scenario != nil && scenario.MaxConcurrentDecisions > 0 {
+		return scenario.MaxConcurrentDecisions
+	}
+	if configured > 0 {
+		return configured
+	}
+	return defaultMaxConcurrentDecisions
+}
+
+// SetDecisionConcurrency 设置决策并发上限及超出上限时的排队等待时间，需在 Run 之前调用
+// 场景配置了 max_concurrent_decisions 时以场景为准；wait 为0表示超出上限立即丢弃
+func (r *AgentRunner) SetDecisionConcurrency(max int, wait time.Duration) {
+	r.decisionSem = make(chan struct{}, decisionLimit(r.scenario, max))
+	if wait < 0 {
+		wait = 0
+	}
+	r.decisionWait = wait
+}
+
+// runLimitedDecision 获取决策名额后执行决策，等待超时仍无空位时丢弃该触发
+func (r *AgentRunner) runLimitedDecision(ctx context.Context, accountID string) {
+	if !r.acquireDecisionSlot(ctx) {
+		dropped := atomic.AddInt32(&r.decisionsDropped, 1)
+		metrics.AgentDecisionsDropped.Inc()
+		r.logger.Warn("Agent decision limit reached, dropping message trigger",
+			zap.String("account_id", accountID),
+			zap.Int("limit", cap(r.decisionSem)),
+			zap.Int32("dropped_total", dropped))
+		return
+	}
+
+	inFlight := atomic.AddInt32(&r.decisionsInFlight, 1)
+	metrics.AgentDecisionsInFlight.Inc()
+	for {
+		peak := atomic.LoadInt32(&r.decisionsPeak)
+		if inFlight <= peak || atomic.CompareAndSwapInt32(&r.decisionsPeak, peak, inFlight) {
+			break
+		}
+	}
+	r.logger.Debug("Agent decision started",
+		zap.String("account_id", accountID),
+		zap.Int32("in_flight", inFlight),
+		zap.Int("limit", cap(r.decisionSem)))
+
+	defer func() {
+		atomic.AddInt32(&r.decisionsInFlight, -1)
+		metrics.AgentDecisionsInFlight.Dec()
+		<-r.decisionSem
+	}()
+
+	r.triggerAgentDecision(ctx, accountID)
+}
+
+// acquireDecisionSlot 获取决策名额，最多等待 decisionWait
+func (r *AgentRunner) acquireDecisionSlot(ctx context.Context) bool {
+	select {
+	case r.decisionSem <- struct{}{}:
+		return true
+	default:
+	}
+	if r.decisionWait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(r.decisionWait)
+	defer timer.Stop()
+	select {
+	case r.decisionSem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// decisionStats 返回因并发上限丢弃的触发数及同时进行的决策数峰值
+func (r *AgentRunner) decisionStats() (int, int) {
+	return int(atomic.LoadInt32(&r.decisionsDropped)), int(atomic.LoadInt32(&r.decisionsPeak))
+}
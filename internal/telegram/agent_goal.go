@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// goalTracked 智能体是否参与目标完成检测：场景开启 goal_detection 且该智能体设置了目标
+func (r *AgentRunner) goalTracked(agent *models.AgentConfig) bool {
+	return r.scenario.GoalDetection && agent.Goal != ""
+}
+
+// goalCompleted 智能体是否已达成目标，达成后本场景内不再触发决策
+func (r *AgentRunner) goalCompleted(accountID string) bool {
+	r.goalMu.Lock()
+	defer r.goalMu.Unlock()
+	_, done := r.goalCompletedAt[accountID]
+	return done
+}
+
+// markGoalComplete 记录智能体达成目标的时间；所有设置了目标的智能体都达成时通知主循环结束场景
+func (r *AgentRunner) markGoalComplete(agent *models.AgentConfig) {
+	accountID := fmt.Sprintf("%d", agent.AccountID)
+
+	r.goalMu.Lock()
+	defer r.goalMu.Unlock()
+	if _, done := r.goalCompletedAt[accountID]; done {
+		return
+	}
+	r.goalCompletedAt[accountID] = time.Now()
+
+	r.logger.Info("Agent goal completed, no further triggers for this agent",
+		zap.Uint64("account_id", agent.AccountID),
+		zap.String("persona", agent.Persona.Name),
+		zap.String("goal", agent.Goal))
+
+	for i := range r.scenario.Agents {
+		if !r.goalTracked(&r.scenario.Agents[i]) {
+			continue
+		}
+		if _, done := r.goalCompletedAt[fmt.Sprintf("%d", r.scenario.Agents[i].AccountID)]; !done {
+			return
+		}
+	}
+	close(r.goalsDone)
+}
+
+// recordGoalCompletions 将各智能体的目标达成时间写入任务结果
+func (r *AgentRunner) recordGoalCompletions() {
+	if !r.scenario.GoalDetection {
+		return
+	}
+
+	r.goalMu.Lock()
+	completions := make(map[string]interface{}, len(r.goalCompletedAt))
+	for accountID, at := range r.goalCompletedAt {
+		completions[accountID] = at.Unix()
+	}
+	r.goalMu.Unlock()
+
+	if r.task.Result == nil {
+		r.task.Result = make(models.TaskResult)
+	}
+	r.task.Result["goal_completions"] = completions
+}
@@ -0,0 +1,187 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	gotd_telegram "github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 智能体入群结果
+const (
+	AgentJoinJoined        = "joined"
+	AgentJoinAlreadyMember = "already_member"
+	AgentJoinFailed        = "failed"
+	AgentJoinCancelled     = "cancelled"
+)
+
+// agentJoinJob 单个智能体的入群任务，等待时间在启动前统一生成
+type agentJoinJob struct {
+	accountID uint64
+	delay     time.Duration
+}
+
+// joinAgents 以有限并发让智能体加入目标群组，每个智能体入群前随机等待，结果写入 join_results
+func (r *AgentRunner) joinAgents(ctx context.Context) {
+	topic := r.scenario.Topic
+	concurrency := r.scenario.JoinParallelism()
+	minDelay, maxDelay := r.scenario.JoinDelayRange()
+
+	r.logger.Info("Ensuring all agents join the target group",
+		zap.String("topic", topic),
+		zap.Int("concurrency", concurrency),
+		zap.Duration("min_delay", minDelay),
+		zap.Duration("max_delay", maxDelay),
+		zap.Bool("skip_joined", r.scenario.SkipJoinedAgents))
+
+	// r.rnd 不是并发安全的，顺序和等待时间在启动工作协程前生成
+	jobs := make([]agentJoinJob, 0, len(r.scenario.Agents))
+	for _, agent := range r.scenario.Agents {
+		delay := minDelay
+		if maxDelay > minDelay {
+			delay += time.Duration(r.rnd.Int63n(int64(maxDelay - minDelay)))
+		}
+		jobs = append(jobs, agentJoinJob{accountID: agent.AccountID, delay: delay})
+	}
+	if r.scenario.JoinOrder != models.JoinOrderListed {
+		r.rnd.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+	}
+
+	results := make(map[string]interface{}, len(jobs))
+	counts := make(map[string]int)
+	var mu sync.Mutex
+	record := func(accountID uint64, outcome map[string]interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[fmt.Sprintf("%d", accountID)] = outcome
+		counts[outcome["status"].(string)]++
+	}
+
+	jobCh := make(chan agentJoinJob)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				record(job.accountID, r.joinAgent(ctx, job, topic))
+			}
+		}()
+	}
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	wg.Wait()
+
+	if r.task.Result == nil {
+		r.task.Result = make(models.TaskResult)
+	}
+	r.task.Result["join_results"] = results
+	r.task.Result["join_summary"] = counts
+
+	r.logger.Info("Agent join phase finished",
+		zap.String("topic", topic),
+		zap.Int("joined", counts[AgentJoinJoined]),
+		zap.Int("already_member", counts[AgentJoinAlreadyMember]),
+		zap.Int("failed", counts[AgentJoinFailed]),
+		zap.Int("cancelled", counts[AgentJoinCancelled]))
+}
+
+// joinAgent 执行单个智能体的入群，失败只记录结果，不中断其他智能体
+func (r *AgentRunner) joinAgent(ctx context.Context, job agentJoinJob, topic string) map[string]interface{} {
+	accountIDStr := fmt.Sprintf("%d", job.accountID)
+	outcome := map[string]interface{}{}
+
+	if r.scenario.SkipJoinedAgents {
+		joined, err := r.checkJoined(ctx, accountIDStr, topic)
+		if err != nil {
+			r.logger.Debug("Failed to check agent membership, joining anyway",
+				zap.Uint64("account_id", job.accountID),
+				zap.Error(err))
+		} else if joined {
+			outcome["status"] = AgentJoinAlreadyMember
+			outcome["skipped"] = true
+			outcome["at"] = time.Now().Unix()
+			return outcome
+		}
+	}
+
+	outcome["delay_ms"] = job.delay.Milliseconds()
+	select {
+	case <-ctx.Done():
+		outcome["status"] = AgentJoinCancelled
+		return outcome
+	case <-time.After(job.delay):
+	}
+
+	status, err := r.ensureJoinGroup(ctx, accountIDStr, topic)
+	outcome["status"] = status
+	outcome["at"] = time.Now().Unix()
+	if err != nil {
+		outcome["error"] = err.Error()
+		r.logger.Warn("Failed to join group for agent",
+			zap.Uint64("account_id", job.accountID),
+			zap.String("topic", topic),
+			zap.Error(err))
+		return outcome
+	}
+
+	r.logger.Info("Agent joined group successfully",
+		zap.Uint64("account_id", job.accountID),
+		zap.String("topic", topic),
+		zap.String("status", status))
+	return outcome
+}
+
+// checkJoined 检查账号是否已是目标群组成员，不会执行入群操作
+// 机器人目标需要按启动参数启动，始终返回 false
+func (r *AgentRunner) checkJoined(ctx context.Context, accountID string, target string) (bool, error) {
+	joined := false
+	task := &GenericTask{
+		Type: "check_membership",
+		ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
+			api := client.API()
+
+			if r.isInviteLink(target) {
+				hash := r.extractInviteHash(target)
+				if hash == "" {
+					return fmt.Errorf("invalid invite link format")
+				}
+				invite, err := api.MessagesCheckChatInvite(ctx, hash)
+				if err != nil {
+					return err
+				}
+				_, joined = invite.(*tg.ChatInviteAlready)
+				return nil
+			}
+
+			username, _ := parseDeepLink(target)
+			if username == "" {
+				return fmt.Errorf("invalid group username or link")
+			}
+			resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
+				Username: username,
+			})
+			if err != nil {
+				return fmt.Errorf("resolve username failed: %w", err)
+			}
+			if len(resolved.Chats) > 0 {
+				if channel, ok := resolved.Chats[0].(*tg.Channel); ok {
+					joined = !channel.Left
+				}
+			}
+			return nil
+		},
+	}
+	if err := r.connectionPool.ExecuteTaskWithContext(ctx, accountID, task); err != nil {
+		return false, err
+	}
+	return joined, nil
+}
@@ -2,7 +2,7 @@ package telegram
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -20,6 +20,13 @@ import (
 // AIService AI服务接口 (本地定义以避免循环引用)
 type AIService interface {
 	AgentDecision(ctx context.Context, req *models.AgentDecisionRequest) (*models.AgentDecisionResponse, error)
+	GenerateImage(ctx context.Context, prompt string) (string, error)
+}
+
+// AgentMemoryStore 智能体记忆存储接口 (本地定义以避免循环引用)
+type AgentMemoryStore interface {
+	Get(accountID uint64, groupKey string) (*models.AgentMemory, error)
+	Save(memory *models.AgentMemory) error
 }
 
 // AgentRunner 智能体集群运行器
@@ -32,13 +39,25 @@ type AgentRunner struct {
 	rnd            *rand.Rand
 	ctx            context.Context // 运行上下文
 
-	// 消息缓存: accountID -> []ChatMessage
-	messageCache map[string][]models.ChatMessage
-	cacheMu      sync.RWMutex
+	// 消息缓存: 可由调度器注入全局共享的缓存，键为 taskID:accountID
+	messageCache *MessageCache
 
 	// 消息触发通道
 	messageTrigger chan string // accountID
 
+	// 触发合并: 同一账号已在队列中等待时，新消息只计数不重复入队
+	pendingTriggers  map[string]bool
+	triggerMu        sync.Mutex
+	triggerCoalesced int
+	triggerDropped   int
+
+	// 决策并发限制: 超过上限的触发最多排队等待 decisionWait，仍无空位则丢弃
+	decisionSem       chan struct{}
+	decisionWait      time.Duration
+	decisionsInFlight int32
+	decisionsPeak     int32
+	decisionsDropped  int32
+
 	// 频率限制
 	lastSpeakTime     map[string]time.Time // accountID -> 上次发言时间
 	lastSpeakMu       sync.RWMutex
@@ -46,68 +65,151 @@ type AgentRunner struct {
 	globalLastSpeak   time.Time     // 全局上次发言时间
 	globalSpeakMu     sync.Mutex
 	minGlobalInterval time.Duration // 全局最小发言间隔
+
+	sendDelay SendDelay // 发言前等待时间的分布模型
+
+	// 场景内智能体的 Telegram 用户ID: tg_user_id -> accountID，用于过滤智能体之间的消息
+	agentUserIDs map[int64]uint64
+	agentUserMu  sync.RWMutex
+
+	// 跨场景记忆 (场景开启 enable_memory 且设置了存储时生效)
+	memoryStore    AgentMemoryStore
+	memoryMaxChars int
+	memoryTTL      time.Duration
+	memories       map[string]string   // accountID -> 运行前已有的记忆摘要
+	spoken         map[string][]string // accountID -> 本次运行的发言
+	memoryMu       sync.Mutex
+
+	// 目标完成检测 (场景开启 goal_detection 时生效)
+	goalCompletedAt map[string]time.Time // accountID -> 达成目标时间
+	goalMu          sync.Mutex
+	goalsDone       chan struct{} // 所有设置了目标的智能体都达成时关闭
 }
 
+// defaultTriggerQueueSize 消息触发队列默认容量
+const defaultTriggerQueueSize = 100
+
+// 决策并发限制默认值
+const (
+	defaultMaxConcurrentDecisions = 3
+	defaultDecisionQueueWait      = 5 * time.Second
+)
+
+// 智能体未指定 delay_seconds 时的默认发言等待及抖动
+const (
+	defaultAgentDelay       = 4 * time.Second
+	defaultAgentDelayJitter = 2 * time.Second
+)
+
 // NewAgentRunner 创建智能体运行器
 func NewAgentRunner(task *models.Task, aiService AIService, pool *ConnectionPool) (*AgentRunner, error) {
 	// 解析场景配置
-	configBytes, err := json.Marshal(task.Config)
+	scenario, err := models.ParseAgentScenario(task.Config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal task config: %w", err)
+		return nil, err
 	}
 
-	var scenario models.AgentScenario
-	if err := json.Unmarshal(configBytes, &scenario); err != nil {
-		return nil, fmt.Errorf("failed to parse agent scenario: %w", err)
+	sendDelay, err := SendDelayFromConfig(task.Config)
+	if err != nil {
+		return nil, err
+	}
+	_, hasJitter := task.Config["delay_jitter_seconds"]
+	_, hasIntervalJitter := task.Config["interval_jitter_seconds"]
+	if !hasJitter && !hasIntervalJitter {
+		sendDelay.Jitter = defaultAgentDelayJitter
 	}
 
 	return &AgentRunner{
-		task:           task,
-		scenario:       &scenario,
-		aiService:      aiService,
-		connectionPool: pool,
-		logger:         logger.Get().Named("agent_runner"),
-		rnd:            rand.New(rand.NewSource(time.Now().UnixNano())),
-		messageCache:   make(map[string][]models.ChatMessage),
-		messageTrigger: make(chan string, 100), // 缓冲通道，避免阻塞
+		task:            task,
+		scenario:        scenario,
+		aiService:       aiService,
+		connectionPool:  pool,
+		logger:          logger.Get().Named("agent_runner"),
+		rnd:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		messageCache:    NewMessageCache(0),
+		messageTrigger:  make(chan string, defaultTriggerQueueSize), // 缓冲通道，避免阻塞
+		decisionSem:     make(chan struct{}, decisionLimit(scenario, 0)),
+		decisionWait:    defaultDecisionQueueWait,
+		pendingTriggers: make(map[string]bool),
 		// 频率限制配置
 		lastSpeakTime:     make(map[string]time.Time),
 		minSpeakInterval:  100 * time.Second, // 单个账号至少间隔30秒
 		minGlobalInterval: 60 * time.Second,  // 全局至少间隔10秒
+		sendDelay:         sendDelay,
+		agentUserIDs:      make(map[int64]uint64),
+		memories:          make(map[string]string),
+		spoken:            make(map[string][]string),
+		goalCompletedAt:   make(map[string]time.Time),
+		goalsDone:         make(chan struct{}),
 	}, nil
 }
 
+// SetTriggerQueueSize 设置消息触发队列容量，需在 Run 之前调用
+// 由于同一账号的触发会被合并，容量不会小于智能体数量
+func (r *AgentRunner) SetTriggerQueueSize(size int) {
+	if size <= 0 {
+		size = defaultTriggerQueueSize
+	}
+	if size < len(r.scenario.Agents) {
+		size = len(r.scenario.Agents)
+	}
+	r.messageTrigger = make(chan string, size)
+}
+
+// SetMessageCache 设置共享的消息缓存，使所有场景受同一总容量约束，需在 Run 之前调用
+func (r *AgentRunner) SetMessageCache(cache *MessageCache) {
+	if cache != nil {
+		r.messageCache = cache
+	}
+}
+
+// cacheKey 消息缓存键，区分不同场景中的同一账号
+func (r *AgentRunner) cacheKey(accountID string) string {
+	return fmt.Sprintf("%d:%s", r.task.ID, accountID)
+}
+
+// releaseMessageCache 场景结束时释放本场景的消息缓存
+func (r *AgentRunner) releaseMessageCache() {
+	keys := make([]string, 0, len(r.scenario.Agents))
+	for _, agent := range r.scenario.Agents {
+		keys = append(keys, r.cacheKey(fmt.Sprintf("%d", agent.AccountID)))
+	}
+	r.messageCache.Delete(keys...)
+}
+
+// SetMemoryStore 设置智能体记忆存储，maxChars 为摘要最大字符数，ttl 为记忆有效期，需在 Run 之前调用
+func (r *AgentRunner) SetMemoryStore(store AgentMemoryStore, maxChars int, ttl time.Duration) {
+	r.memoryStore = store
+	r.memoryMaxChars = maxChars
+	r.memoryTTL = ttl
+}
+
 // Run 运行智能体场景
 func (r *AgentRunner) Run(ctx context.Context) error {
 	r.ctx = ctx
 	startTime := time.Now()
+	defer r.recordTriggerStats()
+	defer r.recordGoalCompletions()
+	defer r.releaseMessageCache()
+	if r.memoryEnabled() {
+		r.loadMemories()
+		defer r.saveMemories()
+	}
 	r.logger.Info("Starting agent swarm scenario",
 		zap.String("scenario", r.scenario.Name),
 		zap.String("topic", r.scenario.Topic),
 		zap.Int("agent_count", len(r.scenario.Agents)),
-		zap.Int("duration_seconds", r.scenario.Duration))
+		zap.Int("duration_seconds", r.scenario.Duration),
+		zap.String("delay_distribution", r.sendDelay.String()))
 
 	// 首先让所有智能体加入目标群组
 	if r.scenario.Topic != "" {
-		r.logger.Info("Ensuring all agents join the target group", zap.String("topic", r.scenario.Topic))
-		for _, agent := range r.scenario.Agents {
-			accountIDStr := fmt.Sprintf("%d", agent.AccountID)
-			if err := r.ensureJoinGroup(ctx, accountIDStr, r.scenario.Topic); err != nil {
-				r.logger.Warn("Failed to join group for agent",
-					zap.Uint64("account_id", agent.AccountID),
-					zap.String("topic", r.scenario.Topic),
-					zap.Error(err))
-				// 继续尝试其他账号，不中断整个任务
-			} else {
-				r.logger.Info("Agent joined group successfully",
-					zap.Uint64("account_id", agent.AccountID),
-					zap.String("topic", r.scenario.Topic))
-			}
-			// 加入群组之间稍微等待，避免频率限制
-			time.Sleep(2 * time.Second)
-		}
+		r.joinAgents(ctx)
 	}
 
+	// 缓存各智能体的 Telegram 用户ID，避免智能体互相触发
+	r.loadAgentUserIDs(ctx)
+
 	// 注册消息监听（无论账号是否忙碌，场景任务需要监听消息）
 	registeredCount := 0
 	for _, agent := range r.scenario.Agents {
@@ -137,6 +239,18 @@ func (r *AgentRunner) Run(ctx context.Context) error {
 	timer := time.NewTimer(duration)
 	defer timer.Stop()
 
+	// 活跃窗口检查
+	windowTicker := time.NewTicker(time.Minute)
+	defer windowTicker.Stop()
+	inWindow := r.scenario.InActiveWindow(time.Now())
+	if len(r.scenario.ActiveWindows) > 0 {
+		r.logger.Info("Scenario active windows configured",
+			zap.String("scenario", r.scenario.Name),
+			zap.Any("windows", r.scenario.ActiveWindows),
+			zap.String("timezone", r.scenario.Location().String()),
+			zap.Bool("in_window", inWindow))
+	}
+
 	messageCount := 0
 	for {
 		select {
@@ -152,13 +266,39 @@ func (r *AgentRunner) Run(ctx context.Context) error {
 				zap.Duration("total_duration", time.Since(startTime)),
 				zap.Int("messages_processed", messageCount))
 			return nil
+		case <-r.goalsDone:
+			r.logger.Info("All agent goals completed, ending scenario early",
+				zap.String("scenario", r.scenario.Name),
+				zap.Duration("total_duration", time.Since(startTime)),
+				zap.Int("messages_processed", messageCount))
+			return nil
+		case <-windowTicker.C:
+			active := r.scenario.InActiveWindow(time.Now())
+			if active != inWindow {
+				inWindow = active
+				if active {
+					r.logger.Info("Entering active window, agents resume speaking",
+						zap.String("scenario", r.scenario.Name))
+				} else {
+					r.logger.Info("Leaving active window, agents idle until next window",
+						zap.String("scenario", r.scenario.Name))
+				}
+			}
 		case accountID := <-r.messageTrigger:
+			r.triggerMu.Lock()
+			delete(r.pendingTriggers, accountID)
+			r.triggerMu.Unlock()
+			if !inWindow {
+				r.logger.Debug("Outside active window, ignoring message trigger",
+					zap.String("account_id", accountID))
+				continue
+			}
 			messageCount++
 			r.logger.Info("Message trigger received, scheduling agent decision",
 				zap.String("account_id", accountID),
 				zap.Int("message_count", messageCount))
-			// 异步执行决策，避免阻塞消息处理
-			go r.triggerAgentDecision(ctx, accountID)
+			// 异步执行决策，避免阻塞消息处理；并发数受场景决策上限约束
+			go r.runLimitedDecision(ctx, accountID)
 		}
 	}
 }
@@ -180,6 +320,13 @@ func (r *AgentRunner) triggerAgentDecision(ctx context.Context, accountID string
 		return
 	}
 
+	// 已达成目标的智能体不再决策，节省 AI 调用
+	if r.goalCompleted(accountID) {
+		r.logger.Debug("Agent goal already completed, skipping",
+			zap.String("account_id", accountID))
+		return
+	}
+
 	// 检查全局发言频率
 	r.globalSpeakMu.Lock()
 	timeSinceGlobalSpeak := time.Since(r.globalLastSpeak)
@@ -298,10 +445,15 @@ func (r *AgentRunner) executeAgentLoop(ctx context.Context, agent *models.AgentC
 	}
 
 	decisionReq := &models.AgentDecisionRequest{
-		ScenarioTopic: r.scenario.Topic,
-		AgentPersona:  personaDesc,
-		AgentGoal:     agent.Goal,
-		ChatHistory:   history,
+		ScenarioTopic:   r.scenario.Topic,
+		AgentPersona:    personaDesc,
+		AgentGoal:       agent.Goal,
+		ChatHistory:     history,
+		Memory:          r.agentMemory(accountIDStr),
+		GoalDetection:   r.goalTracked(agent),
+		PostProcess:     r.scenario.PostProcess,
+		ImageGenEnabled: agent.ImageGenEnabled,
+		AISampling:      r.scenario.AISampling,
 	}
 
 	decision, err := r.aiService.AgentDecision(ctx, decisionReq)
@@ -313,6 +465,11 @@ func (r *AgentRunner) executeAgentLoop(ctx context.Context, agent *models.AgentC
 		return fmt.Errorf("AI decision failed: %w", err)
 	}
 
+	// 目标达成时仍发送本次决定的发言，之后不再触发
+	if decision.GoalComplete && r.goalTracked(agent) {
+		r.markGoalComplete(agent)
+	}
+
 	if !decision.ShouldSpeak {
 		r.logger.Debug("Agent decided to stay silent",
 			zap.Uint64("account_id", agent.AccountID),
@@ -331,16 +488,21 @@ func (r *AgentRunner) executeAgentLoop(ctx context.Context, agent *models.AgentC
 
 	// 3. Act (行动)
 	// 模拟延迟
-	delay := time.Duration(decision.DelaySeconds) * time.Second
-	if delay == 0 {
-		delay = time.Duration(r.rnd.Intn(5)+2) * time.Second
+	interval := time.Duration(decision.DelaySeconds) * time.Second
+	if interval == 0 {
+		interval = defaultAgentDelay
 	}
+	delay := r.sendDelay.Next(r.rnd, interval)
 
 	// 模拟输入状态
 	r.simulateTyping(ctx, accountIDStr, delay)
 
-	// 执行发送文本消息
-	err = r.sendTextMessage(ctx, accountIDStr, decision.Content, 0)
+	// 执行发送：需要配图时先生成图片，生成失败退回纯文字
+	if decision.Action == agentActionGeneratePhoto && agent.ImageGenEnabled {
+		err = r.sendGeneratedPhoto(ctx, accountIDStr, decision)
+	} else {
+		err = r.sendTextMessage(ctx, accountIDStr, decision.Content, 0)
+	}
 	if err == nil {
 		// 发送成功，更新发言时间
 		now := time.Now()
@@ -353,6 +515,8 @@ func (r *AgentRunner) executeAgentLoop(ctx context.Context, agent *models.AgentC
 		r.globalLastSpeak = now
 		r.globalSpeakMu.Unlock()
 
+		r.rememberSpoken(accountIDStr, decision.Content)
+
 		r.logger.Info("Agent message sent successfully",
 			zap.Uint64("account_id", agent.AccountID),
 			zap.String("persona", agent.Persona.Name),
@@ -361,12 +525,116 @@ func (r *AgentRunner) executeAgentLoop(ctx context.Context, agent *models.AgentC
 	return err
 }
 
+// memoryEnabled 是否启用跨场景记忆
+func (r *AgentRunner) memoryEnabled() bool {
+	return r.scenario.EnableMemory && r.memoryStore != nil && r.scenario.Topic != ""
+}
+
+// memoryGroupKey 记忆所属群组标识
+func (r *AgentRunner) memoryGroupKey() string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.scenario.Topic), "@"))
+}
+
+// loadMemories 加载各智能体在目标群组的记忆，过期的记忆视为空
+func (r *AgentRunner) loadMemories() {
+	groupKey := r.memoryGroupKey()
+	for _, agent := range r.scenario.Agents {
+		memory, err := r.memoryStore.Get(agent.AccountID, groupKey)
+		if err != nil {
+			r.logger.Warn("Failed to load agent memory",
+				zap.Uint64("account_id", agent.AccountID),
+				zap.String("group", groupKey),
+				zap.Error(err))
+			continue
+		}
+		if memory == nil || memory.Summary == "" {
+			continue
+		}
+		if r.memoryTTL > 0 && time.Since(memory.UpdatedAt) > r.memoryTTL {
+			r.logger.Debug("Agent memory expired, starting fresh",
+				zap.Uint64("account_id", agent.AccountID),
+				zap.Time("updated_at", memory.UpdatedAt))
+			continue
+		}
+		r.memories[fmt.Sprintf("%d", agent.AccountID)] = memory.Summary
+	}
+	r.logger.Info("Agent memories loaded",
+		zap.String("group", groupKey),
+		zap.Int("loaded", len(r.memories)))
+}
+
+// agentMemory 返回智能体的记忆摘要
+func (r *AgentRunner) agentMemory(accountID string) string {
+	r.memoryMu.Lock()
+	defer r.memoryMu.Unlock()
+	return r.memories[accountID]
+}
+
+// rememberSpoken 记录本次运行中的发言，运行结束后写入记忆
+func (r *AgentRunner) rememberSpoken(accountID, content string) {
+	if !r.memoryEnabled() || strings.TrimSpace(content) == "" {
+		return
+	}
+	r.memoryMu.Lock()
+	r.spoken[accountID] = append(r.spoken[accountID], strings.TrimSpace(content))
+	r.memoryMu.Unlock()
+}
+
+// saveMemories 将本次发言追加到记忆摘要并保存，超出长度时丢弃最早的内容
+func (r *AgentRunner) saveMemories() {
+	groupKey := r.memoryGroupKey()
+	date := time.Now().Format("2006-01-02")
+
+	r.memoryMu.Lock()
+	defer r.memoryMu.Unlock()
+
+	for _, agent := range r.scenario.Agents {
+		accountID := fmt.Sprintf("%d", agent.AccountID)
+		lines := r.spoken[accountID]
+		if len(lines) == 0 {
+			continue
+		}
+
+		entry := fmt.Sprintf("[%s] %s", date, strings.Join(lines, " / "))
+		summary := entry
+		if previous := r.memories[accountID]; previous != "" {
+			summary = previous + "\n" + entry
+		}
+		summary = trimMemory(summary, r.memoryMaxChars)
+
+		if err := r.memoryStore.Save(&models.AgentMemory{
+			AccountID: agent.AccountID,
+			GroupKey:  groupKey,
+			Summary:   summary,
+		}); err != nil {
+			r.logger.Warn("Failed to save agent memory",
+				zap.Uint64("account_id", agent.AccountID),
+				zap.String("group", groupKey),
+				zap.Error(err))
+			continue
+		}
+		r.memories[accountID] = summary
+		delete(r.spoken, accountID)
+	}
+}
+
+// trimMemory 保留摘要末尾最多 maxChars 个字符，尽量从整行处截断
+func trimMemory(summary string, maxChars int) string {
+	runes := []rune(summary)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return summary
+	}
+	trimmed := string(runes[len(runes)-maxChars:])
+	if idx := strings.Index(trimmed, "\n"); idx >= 0 && idx < len(trimmed)-1 {
+		trimmed = trimmed[idx+1:]
+	}
+	return trimmed
+}
+
 // fetchChatHistory 获取聊天记录
 func (r *AgentRunner) fetchChatHistory(ctx context.Context, accountID string) ([]models.ChatMessage, error) {
 	// 1. 尝试从缓存获取
-	r.cacheMu.RLock()
-	cached, exists := r.messageCache[accountID]
-	r.cacheMu.RUnlock()
+	cached, exists := r.messageCache.Get(r.cacheKey(accountID))
 
 	if exists && len(cached) > 0 {
 		// 返回最近的20条
@@ -383,7 +651,7 @@ func (r *AgentRunner) fetchChatHistory(ctx context.Context, accountID string) ([
 		Type: "fetch_history",
 		ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
 			api := client.API()
-			peer, err := r.resolvePeer(ctx, api, r.scenario.Topic)
+			peer, err := r.resolvePeer(ctx, api, accountID, r.scenario.Topic)
 			if err != nil {
 				return err
 			}
@@ -449,15 +717,13 @@ func (r *AgentRunner) fetchChatHistory(ctx context.Context, accountID string) ([
 		},
 	}
 
-	err := r.connectionPool.ExecuteTask(accountID, task)
+	err := r.connectionPool.ExecuteTaskWithContext(ctx, accountID, task)
 	if err != nil {
 		return nil, err
 	}
 
 	// 更新缓存
-	r.cacheMu.Lock()
-	r.messageCache[accountID] = history
-	r.cacheMu.Unlock()
+	r.messageCache.Set(r.cacheKey(accountID), history)
 
 	// 返回最近的20条
 	if len(history) > 20 {
@@ -570,16 +836,8 @@ func (r *AgentRunner) processNewMessage(accountID string, msg *tg.Message, users
 		}
 	}
 
-	r.cacheMu.Lock()
-	// 追加新消息
-	r.messageCache[accountID] = append(r.messageCache[accountID], chatMsg)
-
-	// 限制缓存大小 (例如保留最近100条)
-	if len(r.messageCache[accountID]) > 100 {
-		r.messageCache[accountID] = r.messageCache[accountID][len(r.messageCache[accountID])-100:]
-	}
-	cacheSize := len(r.messageCache[accountID])
-	r.cacheMu.Unlock()
+	// 追加新消息，单账号及总容量的淘汰由缓存负责
+	cacheSize := r.messageCache.Append(r.cacheKey(accountID), chatMsg)
 
 	r.logger.Info("New message cached",
 		zap.String("account_id", accountID),
@@ -598,10 +856,10 @@ func (r *AgentRunner) processNewMessage(accountID string, msg *tg.Message, users
 		return
 	}
 
-	// 检查是否是自己发送的消息（避免自己触发自己）
+	// 检查是否是场景内智能体发送的消息（避免智能体之间互相触发）
 	isOwnMessage := r.isOwnMessage(accountID, senderUserID)
 	if isOwnMessage {
-		r.logger.Debug("Skipping own message",
+		r.logger.Debug("Skipping message from scenario agent",
 			zap.String("account_id", accountID),
 			zap.Int64("sender_user_id", senderUserID))
 		return
@@ -615,35 +873,98 @@ func (r *AgentRunner) processNewMessage(accountID string, msg *tg.Message, users
 	}
 
 	// 触发智能体决策
+	r.enqueueTrigger(accountID)
+}
+
+// enqueueTrigger 将账号加入决策触发队列
+// 账号已有待处理的触发时合并为一次，保证繁忙群组中每个账号最终都能得到决策机会
+func (r *AgentRunner) enqueueTrigger(accountID string) {
+	r.triggerMu.Lock()
+	defer r.triggerMu.Unlock()
+
+	if r.pendingTriggers[accountID] {
+		r.triggerCoalesced++
+		r.logger.Debug("Message trigger coalesced with pending one",
+			zap.String("account_id", accountID))
+		return
+	}
+
 	select {
 	case r.messageTrigger <- accountID:
+		r.pendingTriggers[accountID] = true
 		r.logger.Debug("Message trigger sent",
 			zap.String("account_id", accountID))
 	default:
+		r.triggerDropped++
 		r.logger.Warn("Message trigger channel full, skipping",
-			zap.String("account_id", accountID))
+			zap.String("account_id", accountID),
+			zap.Int("dropped_total", r.triggerDropped))
 	}
 }
 
-// isOwnMessage 检查消息是否是自己发送的
-func (r *AgentRunner) isOwnMessage(accountID string, senderUserID int64) bool {
-	// 遍历所有智能体，检查发送者是否是其中之一
+// recordTriggerStats 将触发队列的合并/丢弃计数写入任务结果
+func (r *AgentRunner) recordTriggerStats() {
+	r.triggerMu.Lock()
+	coalesced, dropped := r.triggerCoalesced, r.triggerDropped
+	r.triggerMu.Unlock()
+
+	if r.task.Result == nil {
+		r.task.Result = make(models.TaskResult)
+	}
+	r.task.Result["triggers_coalesced"] = coalesced
+	r.task.Result["triggers_dropped"] = dropped
+	r.task.Result["decisions_dropped"], r.task.Result["decisions_peak"] = r.decisionStats()
+}
+
+// loadAgentUserIDs 从账号记录中读取各智能体的 tg_user_id，缺失时通过客户端获取自身信息
+func (r *AgentRunner) loadAgentUserIDs(ctx context.Context) {
 	for _, agent := range r.scenario.Agents {
-		// 需要获取账号的 TG User ID 来比较
-		// 这里简单处理：如果 accountID 对应的账号发送了消息，就认为是自己的消息
-		// 实际上需要从账号信息中获取 tg_user_id
-		if fmt.Sprintf("%d", agent.AccountID) == accountID {
-			// 这个账号收到了消息，检查发送者是否是任何一个智能体账号
-			for _, a := range r.scenario.Agents {
-				// 这里需要账号的 tg_user_id，暂时跳过精确检查
-				// 如果发送者 ID 和任何智能体账号匹配，就认为是自己的消息
-				_ = a
+		var userID int64
+		if r.connectionPool.accountRepo != nil {
+			if account, err := r.connectionPool.accountRepo.GetByID(agent.AccountID); err == nil && account.TgUserID != nil {
+				userID = *account.TgUserID
+			}
+		}
+
+		if userID == 0 {
+			task := &GenericTask{
+				Type: "get_self",
+				ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
+					self, err := client.Self(ctx)
+					if err != nil {
+						return err
+					}
+					userID = self.ID
+					return nil
+				},
+			}
+			if err := r.connectionPool.ExecuteTaskWithContext(ctx, fmt.Sprintf("%d", agent.AccountID), task); err != nil {
+				r.logger.Warn("Failed to resolve agent tg_user_id, its messages may trigger other agents",
+					zap.Uint64("account_id", agent.AccountID),
+					zap.Error(err))
+				continue
 			}
 		}
+
+		r.agentUserMu.Lock()
+		r.agentUserIDs[userID] = agent.AccountID
+		r.agentUserMu.Unlock()
+	}
+
+	r.logger.Info("Agent user IDs cached",
+		zap.Int("resolved", len(r.agentUserIDs)),
+		zap.Int("total_agents", len(r.scenario.Agents)))
+}
+
+// isOwnMessage 检查消息是否由场景内的智能体（包括自己）发送
+func (r *AgentRunner) isOwnMessage(accountID string, senderUserID int64) bool {
+	if senderUserID == 0 {
+		return false
 	}
-	// 暂时返回 false，让所有消息都触发决策
-	// TODO: 实现精确的自己消息检测
-	return false
+	r.agentUserMu.RLock()
+	_, ok := r.agentUserIDs[senderUserID]
+	r.agentUserMu.RUnlock()
+	return ok
 }
 
 // simulateTyping 模拟输入状态
@@ -652,7 +973,7 @@ func (r *AgentRunner) simulateTyping(ctx context.Context, accountID string, dura
 		Type: "simulate_typing",
 		ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
 			api := client.API()
-			peer, err := r.resolvePeer(ctx, api, r.scenario.Topic)
+			peer, err := r.resolvePeer(ctx, api, accountID, r.scenario.Topic)
 			if err != nil {
 				return err
 			}
@@ -663,7 +984,7 @@ func (r *AgentRunner) simulateTyping(ctx context.Context, accountID string, dura
 			return err
 		},
 	}
-	r.connectionPool.ExecuteTask(accountID, task)
+	r.connectionPool.ExecuteTaskWithContext(ctx, accountID, task)
 	time.Sleep(duration)
 }
 
@@ -673,14 +994,25 @@ func (r *AgentRunner) sendTextMessage(ctx context.Context, accountID string, con
 		Type: "send_text",
 		ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
 			api := client.API()
-			peer, err := r.resolvePeer(ctx, api, r.scenario.Topic)
+			peer, err := r.resolvePeer(ctx, api, accountID, r.scenario.Topic)
 			if err != nil {
 				return err
 			}
 
+			text, entities, err := FormatMessage(content, r.scenario.ParseMode)
+			if err != nil {
+				// AI 生成内容格式不合法时退回纯文本发送
+				r.logger.Warn("Malformed message formatting, sending as plain text",
+					zap.String("account_id", accountID),
+					zap.String("parse_mode", r.scenario.ParseMode),
+					zap.Error(err))
+				text, entities = content, nil
+			}
+
 			req := &tg.MessagesSendMessageRequest{
 				Peer:     peer,
-				Message:  content,
+				Message:  text,
+				Entities: entities,
 				RandomID: time.Now().UnixNano(),
 			}
 			if replyTo != 0 {
@@ -691,34 +1023,72 @@ func (r *AgentRunner) sendTextMessage(ctx context.Context, accountID string, con
 			return err
 		},
 	}
-	return r.connectionPool.ExecuteTask(accountID, task)
+	return r.connectionPool.ExecuteTaskWithContext(ctx, accountID, task)
 }
 
-// resolvePeer 解析目标Peer
-func (r *AgentRunner) resolvePeer(ctx context.Context, api *tg.Client, target string) (tg.InputPeerClass, error) {
-	// Simple username resolution
-	cleanTarget := strings.TrimPrefix(target, "@")
-	cleanTarget = strings.TrimPrefix(cleanTarget, "https://t.me/")
-	cleanTarget = strings.TrimPrefix(cleanTarget, "t.me/")
+// agentActionGeneratePhoto 决策要求生成图片并发送
+const agentActionGeneratePhoto = "generate_photo"
 
-	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-		Username: cleanTarget,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve peer %s: %w", target, err)
+// sendGeneratedPhoto 按决策生成图片并发送，内容作为图片说明
+// 未配置 key、额度不足或描述违规时改为发送文字，避免发出占位图或失效链接
+func (r *AgentRunner) sendGeneratedPhoto(ctx context.Context, accountID string, decision *models.AgentDecisionResponse) error {
+	prompt := decision.ImagePrompt
+	if prompt == "" {
+		prompt = decision.Content
 	}
 
-	if len(resolved.Chats) > 0 {
-		if chat, ok := resolved.Chats[0].(*tg.Chat); ok {
-			return &tg.InputPeerChat{ChatID: chat.ID}, nil
-		} else if channel, ok := resolved.Chats[0].(*tg.Channel); ok {
-			return &tg.InputPeerChannel{
-				ChannelID:  channel.ID,
-				AccessHash: channel.AccessHash,
-			}, nil
+	url, err := r.aiService.GenerateImage(ctx, prompt)
+	if err != nil {
+		var imageErr *models.ImageGenerationError
+		reason := "error"
+		if errors.As(err, &imageErr) {
+			reason = imageErr.Code
 		}
+		if strings.TrimSpace(decision.Content) == "" {
+			return fmt.Errorf("image generation failed and no text to fall back to: %w", err)
+		}
+		r.logger.Warn("Image generation failed, sending text instead",
+			zap.String("account_id", accountID),
+			zap.String("reason", reason),
+			zap.Error(err))
+		return r.sendTextMessage(ctx, accountID, decision.Content, 0)
 	}
-	return nil, fmt.Errorf("peer not found: %s", target)
+
+	task := &GenericTask{
+		Type: "send_photo",
+		ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
+			api := client.API()
+			peer, err := r.resolvePeer(ctx, api, accountID, r.scenario.Topic)
+			if err != nil {
+				return err
+			}
+
+			text, entities, err := FormatMessage(decision.Content, r.scenario.ParseMode)
+			if err != nil {
+				text, entities = decision.Content, nil
+			}
+
+			_, err = api.MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+				Peer:     peer,
+				Media:    &tg.InputMediaPhotoExternal{URL: url},
+				Message:  text,
+				Entities: entities,
+				RandomID: time.Now().UnixNano(),
+			})
+			return err
+		},
+	}
+	return r.connectionPool.ExecuteTaskWithContext(ctx, accountID, task)
+}
+
+// resolvePeer 解析目标Peer，结果按账号缓存，避免每次发言、输入状态都重新解析
+// 启动参数只在 ensureJoinGroup 中使用一次，这里只解析目标本身
+func (r *AgentRunner) resolvePeer(ctx context.Context, api *tg.Client, accountID, target string) (tg.InputPeerClass, error) {
+	peer, err := r.connectionPool.ResolvePeer(ctx, accountID, api, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve peer %s: %w", target, err)
+	}
+	return peer, nil
 }
 
 // GenericTask 通用任务封装
@@ -742,8 +1112,9 @@ func (t *GenericTask) GetType() string {
 	return t.Type
 }
 
-// ensureJoinGroup 确保账号加入目标群组
-func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, target string) error {
+// ensureJoinGroup 确保账号加入目标群组，返回入群结果 (joined/already_member)
+func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, target string) (string, error) {
+	outcome := AgentJoinJoined
 	task := &GenericTask{
 		Type: "join_group",
 		ExecuteFunc: func(ctx context.Context, client *gotd_telegram.Client) error {
@@ -760,6 +1131,7 @@ func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, tar
 					// 如果已经是成员，忽略错误
 					if strings.Contains(err.Error(), "USER_ALREADY_PARTICIPANT") {
 						r.logger.Debug("Already a member of the group", zap.String("account_id", accountID))
+						outcome = AgentJoinAlreadyMember
 						return nil
 					}
 					return err
@@ -768,7 +1140,7 @@ func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, tar
 			}
 
 			// 处理公开用户名/链接
-			username := r.extractUsername(target)
+			username, startParam := parseDeepLink(target)
 			if username == "" {
 				return fmt.Errorf("invalid group username or link")
 			}
@@ -781,11 +1153,19 @@ func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, tar
 				return fmt.Errorf("resolve username failed: %w", err)
 			}
 
+			// 机器人目标：按启动参数启动机器人
+			if bot := resolvedBot(resolved); bot != nil {
+				_, err := startBotPeer(ctx, api, bot, startParam)
+				return err
+			}
+
 			// 加入频道/超级群
 			if len(resolved.Chats) > 0 {
 				if channel, ok := resolved.Chats[0].(*tg.Channel); ok {
 					// 检查是否已经是成员
-					if channel.Left {
+					if !channel.Left {
+						outcome = AgentJoinAlreadyMember
+					} else {
 						// 尝试加入
 						_, err = api.ChannelsJoinChannel(ctx, &tg.InputChannel{
 							ChannelID:  channel.ID,
@@ -794,6 +1174,7 @@ func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, tar
 						if err != nil {
 							// 如果已经是成员，忽略错误
 							if strings.Contains(err.Error(), "USER_ALREADY_PARTICIPANT") {
+								outcome = AgentJoinAlreadyMember
 								return nil
 							}
 							return err
@@ -807,7 +1188,10 @@ func (r *AgentRunner) ensureJoinGroup(ctx context.Context, accountID string, tar
 			return fmt.Errorf("group not found")
 		},
 	}
-	return r.connectionPool.ExecuteTask(accountID, task)
+	if err := r.connectionPool.ExecuteTaskWithContext(ctx, accountID, task); err != nil {
+		return AgentJoinFailed, err
+	}
+	return outcome, nil
 }
 
 // isInviteLink 检查是否为邀请链接
@@ -827,14 +1211,3 @@ func (r *AgentRunner) extractInviteHash(input string) string {
 	}
 	return ""
 }
-
-// extractUsername 提取用户名
-func (r *AgentRunner) extractUsername(input string) string {
-	// 移除 https://t.me/ 或 t.me/
-	input = strings.TrimPrefix(input, "https://")
-	input = strings.TrimPrefix(input, "http://")
-	input = strings.TrimPrefix(input, "t.me/")
-	// 移除 @ 前缀
-	input = strings.TrimPrefix(input, "@")
-	return input
-}
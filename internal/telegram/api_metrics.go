@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/metrics"
+)
+
+// API 调用链路，用于区分代理侧还是 Telegram 侧的延迟
+const (
+	apiRouteProxy  = "proxy"
+	apiRouteDirect = "direct"
+)
+
+// SetAPICallMetrics 设置是否统计每次 MTProto 调用耗时，以及慢调用日志阈值（0 表示不记录慢调用）
+// 只对之后新建的连接生效
+func (cp *ConnectionPool) SetAPICallMetrics(enabled bool, slowThreshold time.Duration) {
+	cp.apiMetricsEnabled = enabled
+	cp.slowCallThreshold = slowThreshold
+}
+
+// apiMetricsMiddleware 统计每次 API 调用的耗时并记录慢调用，日志带账号ID、方法名和链路
+func (cp *ConnectionPool) apiMetricsMiddleware(accountID, route string) telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			start := time.Now()
+			err := next.Invoke(ctx, input, output)
+			duration := time.Since(start)
+
+			method := apiMethodName(input)
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			metrics.TelegramAPICallsTotal.WithLabelValues(method, status).Inc()
+			metrics.TelegramAPICallDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+
+			if cp.slowCallThreshold > 0 && duration >= cp.slowCallThreshold {
+				cp.logger.Warn("Slow Telegram API call",
+					zap.String("account_id", accountID),
+					zap.String("method", method),
+					zap.String("route", route),
+					zap.Duration("duration", duration),
+					zap.Error(err))
+			}
+			return err
+		}
+	})
+}
+
+// apiMethodName 返回 MTProto 方法名，如 messages.sendMessage
+func apiMethodName(input bin.Encoder) string {
+	if named, ok := input.(interface{ TypeName() string }); ok {
+		return named.TypeName()
+	}
+	return fmt.Sprintf("%T", input)
+}
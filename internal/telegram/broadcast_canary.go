@@ -0,0 +1,150 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// 群发金丝雀检查结论
+const (
+	CanaryVerdictPassed  = "passed"  // 未出现新的限制，继续发送剩余群组
+	CanaryVerdictAborted = "aborted" // 出现新的限制或无法确认，中止剩余群组
+)
+
+// SpamBot 反馈的账号限制状态，按严重程度递增
+const (
+	SpamBotStatusNormal        = "normal"
+	SpamBotStatusLimited       = "limited"
+	SpamBotStatusBidirectional = "bidirectional"
+	SpamBotStatusFrozen        = "frozen"
+)
+
+// defaultCanaryPause 金丝雀批次发送后的默认暂停时长
+const defaultCanaryPause = 60 * time.Second
+
+// BroadcastCanary 群发金丝雀配置：先发送少量群组，暂停后检查账号是否出现新的限制，再决定是否继续
+type BroadcastCanary struct {
+	Count int // 金丝雀批次群组数，0 表示关闭
+	Pause time.Duration
+}
+
+// BroadcastCanaryFromConfig 从任务配置读取 canary_count / canary_pause_seconds
+func BroadcastCanaryFromConfig(config map[string]interface{}) (BroadcastCanary, error) {
+	c := BroadcastCanary{Pause: defaultCanaryPause}
+	if val, ok := config["canary_count"].(float64); ok {
+		if val < 0 {
+			return c, fmt.Errorf("canary_count must not be negative")
+		}
+		c.Count = int(val)
+	}
+	if val, ok := config["canary_pause_seconds"].(float64); ok {
+		if val < 0 {
+			return c, fmt.Errorf("canary_pause_seconds must not be negative")
+		}
+		c.Pause = time.Duration(val * float64(time.Second))
+	}
+	return c, nil
+}
+
+// classifySpamBotReply 将 SpamBot 回复归类为限制状态
+func classifySpamBotReply(reply string) string {
+	lower := strings.ToLower(reply)
+	switch {
+	case spamBotIsFrozen(lower):
+		return SpamBotStatusFrozen
+	case spamBotIsBidirectional(lower):
+		return SpamBotStatusBidirectional
+	case spamBotReportsNoLimits(lower):
+		return SpamBotStatusNormal
+	default:
+		return SpamBotStatusLimited
+	}
+}
+
+// spamBotSeverity 限制状态的严重程度，用于判断是否出现了新的限制
+func spamBotSeverity(status string) int {
+	switch status {
+	case SpamBotStatusNormal:
+		return 0
+	case SpamBotStatusLimited:
+		return 1
+	case SpamBotStatusBidirectional:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// canaryVerdict 返回同一任务中已得出的金丝雀结论，尚未检查时为空
+func (t *BroadcastTask) canaryVerdict() string {
+	if canary, ok := t.task.Result["canary"].(map[string]interface{}); ok {
+		verdict, _ := canary["verdict"].(string)
+		return verdict
+	}
+	return ""
+}
+
+// canaryBaseline 发送金丝雀批次前记录账号当前的限制状态，已有的限制不视为新限制
+// 查询失败时按正常状态处理，任何限制都会在检查时被视为新限制
+func (t *BroadcastTask) canaryBaseline(ctx context.Context, api *tg.Client, addLog func(string)) string {
+	reply, err := querySpamBot(ctx, api)
+	if err != nil {
+		addLog(fmt.Sprintf("金丝雀基线检查失败，按正常状态处理: %v", err))
+		return SpamBotStatusNormal
+	}
+	status := classifySpamBotReply(reply)
+	addLog(fmt.Sprintf("金丝雀基线状态: %s", status))
+	return status
+}
+
+// runCanaryCheck 金丝雀批次发送后暂停并重新检查账号，返回是否继续发送
+// 检查失败时无法确认账号安全，按中止处理
+func (t *BroadcastTask) runCanaryCheck(ctx context.Context, api *tg.Client, canary BroadcastCanary, baseline string, canaryGroups []string, addLog func(string)) bool {
+	addLog(fmt.Sprintf("金丝雀批次已发送 %d 个群组，暂停 %s 后检查账号状态", len(canaryGroups), canary.Pause))
+
+	result := map[string]interface{}{
+		"canary_count":    canary.Count,
+		"canary_groups":   canaryGroups,
+		"baseline_status": baseline,
+	}
+	defer func() {
+		result["checked_at"] = time.Now().Unix()
+		t.task.Result["canary"] = result
+	}()
+
+	select {
+	case <-ctx.Done():
+		result["verdict"] = CanaryVerdictAborted
+		result["reason"] = ctx.Err().Error()
+		addLog("任务已取消，中止群发")
+		return false
+	case <-time.After(canary.Pause):
+	}
+
+	reply, err := querySpamBot(ctx, api)
+	if err != nil {
+		result["verdict"] = CanaryVerdictAborted
+		result["reason"] = fmt.Sprintf("spambot check failed: %v", err)
+		addLog(fmt.Sprintf("金丝雀检查失败，无法确认账号状态，中止剩余群组: %v", err))
+		return false
+	}
+
+	status := classifySpamBotReply(reply)
+	result["status"] = status
+	result["spambot_response"] = reply
+
+	if spamBotSeverity(status) > spamBotSeverity(baseline) {
+		result["verdict"] = CanaryVerdictAborted
+		result["reason"] = fmt.Sprintf("new restriction: %s -> %s", baseline, status)
+		addLog(fmt.Sprintf("金丝雀检查发现新的限制 (%s -> %s)，中止剩余群组", baseline, status))
+		return false
+	}
+
+	result["verdict"] = CanaryVerdictPassed
+	addLog(fmt.Sprintf("金丝雀检查通过 (状态: %s)，继续发送剩余群组", status))
+	return true
+}
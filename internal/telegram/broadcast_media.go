@@ -0,0 +1,257 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 群发媒体类型
+const (
+	BroadcastMediaPhoto    = "photo"
+	BroadcastMediaDocument = "document"
+)
+
+// maxAlbumSize Telegram 单个相册最多包含的媒体数
+const maxAlbumSize = 10
+
+// broadcastMediaDir 群发媒体文件目录，任务中的 file 只能引用该目录下的文件，为空时不支持 file
+var (
+	broadcastMediaMu  sync.RWMutex
+	broadcastMediaDir string
+)
+
+// SetBroadcastMediaDir 设置群发媒体文件目录，启动时调用一次
+func SetBroadcastMediaDir(dir string) {
+	broadcastMediaMu.Lock()
+	defer broadcastMediaMu.Unlock()
+	broadcastMediaDir = dir
+}
+
+// resolveBroadcastMediaFile 将任务中的相对文件名解析为媒体目录下的路径，
+// 解析符号链接后仍须位于媒体目录内，防止读取服务器上的其他文件
+func resolveBroadcastMediaFile(name string) (string, error) {
+	broadcastMediaMu.RLock()
+	dir := broadcastMediaDir
+	broadcastMediaMu.RUnlock()
+	if dir == "" {
+		return "", errors.New("file media is disabled: media_dir is not configured")
+	}
+	if !models.IsValidMediaFileName(name) {
+		return "", fmt.Errorf("invalid media file %q: must be a relative path inside media_dir", name)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid media_dir: %w", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, filepath.Clean(name)))
+	if err != nil {
+		return "", fmt.Errorf("media file %q not found: %w", name, err)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid media file %q: outside media_dir", name)
+	}
+	return path, nil
+}
+
+// BroadcastMedia 群发的单个媒体，URL 为外部链接，File 为媒体目录下文件的相对路径，二者填一个
+type BroadcastMedia struct {
+	Type    string // photo/document，默认 photo
+	URL     string
+	File    string
+	Caption string
+}
+
+// BroadcastMediaFromConfig 从任务配置读取 media 列表，未配置时返回 nil
+// 每个媒体的说明文字都会按 parse_mode 预先校验格式
+func BroadcastMediaFromConfig(config map[string]interface{}) ([]BroadcastMedia, error) {
+	raw, exists := config["media"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("media must be a non-empty list")
+	}
+	if len(list) > maxAlbumSize {
+		return nil, fmt.Errorf("media supports at most %d items", maxAlbumSize)
+	}
+
+	parseMode, _ := config["parse_mode"].(string)
+	media := make([]BroadcastMedia, 0, len(list))
+	for i, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("media[%d]: must be an object", i)
+		}
+		m := BroadcastMedia{Type: BroadcastMediaPhoto}
+		if v, _ := entry["type"].(string); v != "" {
+			m.Type = v
+		}
+		m.URL, _ = entry["url"].(string)
+		m.File, _ = entry["file"].(string)
+		m.Caption, _ = entry["caption"].(string)
+
+		if m.Type != BroadcastMediaPhoto && m.Type != BroadcastMediaDocument {
+			return nil, fmt.Errorf("media[%d]: unsupported type %q", i, m.Type)
+		}
+		if (m.URL == "") == (m.File == "") {
+			return nil, fmt.Errorf("media[%d]: exactly one of url or file is required", i)
+		}
+		if m.File != "" && !models.IsValidMediaFileName(m.File) {
+			return nil, fmt.Errorf("media[%d]: file must be a relative path without ..", i)
+		}
+		if _, _, err := FormatMessage(m.Caption, parseMode); err != nil {
+			return nil, fmt.Errorf("media[%d]: %w", i, err)
+		}
+		media = append(media, m)
+	}
+	return media, nil
+}
+
+// mediaUploadError 媒体上传失败，与发送失败分开记录
+type mediaUploadError struct {
+	Index int
+	Err   error
+}
+
+func (e *mediaUploadError) Error() string {
+	return fmt.Sprintf("media[%d] upload failed: %v", e.Index, e.Err)
+}
+
+func (e *mediaUploadError) Unwrap() error {
+	return e.Err
+}
+
+// sendBroadcastMedia 将媒体逐个上传（MessagesUploadMedia）后发送到群组，多个媒体以相册发送（MessagesSendMultiMedia）
+// message 不为空时作为第一个媒体的说明文字，其余媒体使用各自的说明文字；使用 Telegram 定时消息时返回定时消息ID
+func (t *BroadcastTask) sendBroadcastMedia(ctx context.Context, api *tg.Client, group interface{}, media []BroadcastMedia, message string, entities []tg.MessageEntityClass, parseMode string, explicitPeer tg.InputPeerClass) (int, error) {
+	inputPeer, err := t.resolveBroadcastPeer(ctx, api, group, explicitPeer)
+	if err != nil {
+		return 0, err
+	}
+
+	items := make([]tg.InputSingleMedia, 0, len(media))
+	for i, m := range media {
+		inputMedia, err := uploadBroadcastMedia(ctx, api, inputPeer, m)
+		if err != nil {
+			t.invalidatePeer(inputPeer, err)
+			return 0, &mediaUploadError{Index: i, Err: err}
+		}
+
+		item := tg.InputSingleMedia{Media: inputMedia, RandomID: time.Now().UnixNano() + int64(i)}
+		if i == 0 && message != "" {
+			item.Message = message
+			item.SetEntities(entities)
+		} else if m.Caption != "" {
+			caption, captionEntities, _ := FormatMessage(m.Caption, parseMode)
+			item.Message = caption
+			item.SetEntities(captionEntities)
+		}
+		items = append(items, item)
+	}
+
+	var updates tg.UpdatesClass
+	if len(items) == 1 {
+		// 相册至少需要两个媒体，单个媒体直接发送
+		req := &tg.MessagesSendMediaRequest{
+			Peer:     inputPeer,
+			Media:    items[0].Media,
+			Message:  items[0].Message,
+			RandomID: items[0].RandomID,
+		}
+		if entities, ok := items[0].GetEntities(); ok {
+			req.SetEntities(entities)
+		}
+		if t.scheduleDate > 0 {
+			req.SetScheduleDate(t.scheduleDate)
+		}
+		updates, err = api.MessagesSendMedia(ctx, req)
+	} else {
+		req := &tg.MessagesSendMultiMediaRequest{
+			Peer:       inputPeer,
+			MultiMedia: items,
+		}
+		if t.scheduleDate > 0 {
+			req.SetScheduleDate(t.scheduleDate)
+		}
+		updates, err = api.MessagesSendMultiMedia(ctx, req)
+	}
+	if err != nil {
+		// access hash 失效时清除缓存，下次发送重新解析
+		t.invalidatePeer(inputPeer, err)
+		return 0, err
+	}
+	if t.scheduleDate > 0 {
+		return scheduledMessageID(updates), nil
+	}
+	return 0, nil
+}
+
+// uploadBroadcastMedia 通过 MessagesUploadMedia 上传媒体，返回可在相册中引用的已上传媒体
+func uploadBroadcastMedia(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, m BroadcastMedia) (tg.InputMediaClass, error) {
+	var source tg.InputMediaClass
+	if m.URL != "" {
+		if m.Type == BroadcastMediaDocument {
+			source = &tg.InputMediaDocumentExternal{URL: m.URL}
+		} else {
+			source = &tg.InputMediaPhotoExternal{URL: m.URL}
+		}
+	} else {
+		path, err := resolveBroadcastMediaFile(m.File)
+		if err != nil {
+			return nil, err
+		}
+		file, err := uploader.NewUploader(api).FromPath(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload file %s: %w", filepath.Base(m.File), err)
+		}
+		if m.Type == BroadcastMediaDocument {
+			mimeType := mime.TypeByExtension(filepath.Ext(m.File))
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
+			}
+			source = &tg.InputMediaUploadedDocument{
+				File:     file,
+				MimeType: mimeType,
+				Attributes: []tg.DocumentAttributeClass{
+					&tg.DocumentAttributeFilename{FileName: filepath.Base(m.File)},
+				},
+			}
+		} else {
+			source = &tg.InputMediaUploadedPhoto{File: file}
+		}
+	}
+
+	uploaded, err := api.MessagesUploadMedia(ctx, &tg.MessagesUploadMediaRequest{
+		Peer:  peer,
+		Media: source,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := uploaded.(type) {
+	case *tg.MessageMediaPhoto:
+		if photo, ok := v.Photo.(*tg.Photo); ok {
+			return &tg.InputMediaPhoto{ID: photo.AsInput()}, nil
+		}
+	case *tg.MessageMediaDocument:
+		if doc, ok := v.Document.(*tg.Document); ok {
+			return &tg.InputMediaDocument{ID: doc.AsInput()}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected uploaded media type: %T", uploaded)
+}
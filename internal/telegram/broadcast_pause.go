@@ -0,0 +1,56 @@
+package telegram
+
+import (
+	"context"
+	"time"
+
+	"tg_cloud_server/internal/models"
+)
+
+// pausedGroupsKey 群发暂停时当前账号尚未发送的群组
+const pausedGroupsKey = "paused_remaining_groups"
+
+// SetInterruptContext 设置任务的暂停/停止信号，取消后发送循环在当前群组完成后结束并记录剩余群组
+func (t *BroadcastTask) SetInterruptContext(ctx context.Context) {
+	t.interruptCtx = ctx
+}
+
+// interrupted 是否收到了暂停/停止信号
+func (t *BroadcastTask) interrupted() bool {
+	if t.interruptCtx == nil {
+		return false
+	}
+	return t.interruptCtx.Err() != nil
+}
+
+// sleep 等待发送间隔，期间收到暂停/停止信号时返回 false
+func (t *BroadcastTask) sleep(d time.Duration) bool {
+	if t.interruptCtx == nil {
+		time.Sleep(d)
+		return true
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-t.interruptCtx.Done():
+		return false
+	}
+}
+
+// recordPausedGroups 记录暂停时尚未发送的群组
+func (t *BroadcastTask) recordPausedGroups(groups []interface{}) {
+	t.task.Result[pausedGroupsKey] = append([]interface{}(nil), groups...)
+}
+
+// takePausedGroups 取出暂停时记录的群组，没有时返回 false
+func (t *BroadcastTask) takePausedGroups() ([]interface{}, bool) {
+	groups, ok := t.task.Result[pausedGroupsKey].([]interface{})
+	delete(t.task.Result, pausedGroupsKey)
+	return groups, ok && len(groups) > 0
+}
+
+// HasPausedGroups 任务结果中是否有群发暂停时未发送的群组
+func HasPausedGroups(task *models.Task) bool {
+	groups, ok := task.Result[pausedGroupsKey].([]interface{})
+	return ok && len(groups) > 0
+}
@@ -1,9 +1,12 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,6 +18,7 @@ import (
 	"go.uber.org/zap"
 
 	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/common/metrics"
 	"tg_cloud_server/internal/models"
 	"tg_cloud_server/internal/repository"
 )
@@ -33,14 +37,39 @@ const (
 // 添加别名以保持向下兼容
 const StatusError = StatusConnectionError
 
-// ManagedConnection 托管连接封装
-// 重连相关常量
+// 重连相关默认值，可通过 ConnectionPoolConfig 覆盖
 const (
-	MaxReconnectAttempts  = 3                // 最大重连次数
-	InitialReconnectDelay = 10 * time.Second // 初始重连延迟
-	MaxReconnectDelay     = 30 * time.Second // 最大重连延迟
+	DefaultMaxReconnectAttempts  = 3                // 最大重连次数
+	DefaultInitialReconnectDelay = 10 * time.Second // 初始重连延迟
+	DefaultMaxReconnectDelay     = 30 * time.Second // 最大重连延迟
 )
 
+// ConnectionPoolConfig 连接池配置，零值字段使用默认值
+type ConnectionPoolConfig struct {
+	MaxIdle               time.Duration // 空闲连接超时
+	MaxReconnectAttempts  int           // 连接断开后的最大重连次数
+	InitialReconnectDelay time.Duration // 第一次重连前的等待时间，之后按指数退避
+	MaxReconnectDelay     time.Duration // 重连等待时间上限
+}
+
+// withDefaults 为未设置的字段填充默认值
+func (c ConnectionPoolConfig) withDefaults() ConnectionPoolConfig {
+	if c.MaxReconnectAttempts <= 0 {
+		c.MaxReconnectAttempts = DefaultMaxReconnectAttempts
+	}
+	if c.InitialReconnectDelay <= 0 {
+		c.InitialReconnectDelay = DefaultInitialReconnectDelay
+	}
+	if c.MaxReconnectDelay <= 0 {
+		c.MaxReconnectDelay = DefaultMaxReconnectDelay
+	}
+	if c.MaxReconnectDelay < c.InitialReconnectDelay {
+		c.MaxReconnectDelay = c.InitialReconnectDelay
+	}
+	return c
+}
+
+// ManagedConnection 托管连接封装
 type ManagedConnection struct {
 	client          *telegram.Client
 	config          *ClientConfig
@@ -52,10 +81,15 @@ type ManagedConnection struct {
 	reconnectCount  int           // 重连次数计数器
 	lastReconnectAt time.Time     // 上次重连时间
 	stateChangeCh   chan struct{} // 状态变更通知通道
-	mu              sync.Mutex
-	ctx             context.Context
-	cancel          context.CancelFunc
-	logger          *zap.Logger
+	slotFreedCh     chan struct{} // 任务槽位释放通知通道
+	// intentionalShutdown 主动关闭标记（移除/强制重连/锁定账号时设置），设置后不再自动重连
+	intentionalShutdown bool
+	mu                  sync.Mutex
+	ctx                 context.Context
+	cancel              context.CancelFunc
+	logger              *zap.Logger
+	sessionStorage      *DatabaseSessionStorage
+	done                chan struct{} // maintainConnection 退出时关闭
 }
 
 // notifyStateChange 通知状态变更
@@ -67,6 +101,21 @@ func (c *ManagedConnection) notifyStateChange() {
 	}
 }
 
+// shutdown 主动关闭连接，阻止后续自动重连
+func (c *ManagedConnection) shutdown() {
+	c.mu.Lock()
+	c.intentionalShutdown = true
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// isIntentionalShutdown 是否已被主动关闭
+func (c *ManagedConnection) isIntentionalShutdown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.intentionalShutdown
+}
+
 // ClientConfig 客户端配置
 type ClientConfig struct {
 	AppID       int
@@ -74,6 +123,7 @@ type ClientConfig struct {
 	Phone       string
 	SessionData []byte
 	ProxyConfig *ProxyConfig
+	Device      DeviceConfig
 }
 
 // ProxyConfig 代理配置
@@ -92,26 +142,64 @@ type ConnectionPool struct {
 	mu             sync.RWMutex
 	maxIdle        time.Duration
 	cleanupTicker  *time.Ticker
+	flushTimeout   time.Duration // 关闭时等待Session落盘的时间
 	logger         *zap.Logger
 	appID          int
 	appHash        string
 	accountRepo    repository.AccountRepository
 	proxyRepo      repository.ProxyRepository
 	updateHandlers map[string]telegram.UpdateHandler
+	listeners      updateListeners // 临时更新监听器（如验证码任务），与 updateHandlers 并存
+
+	statusDebouncer *connectionStatusDebouncer // 在线状态写入防抖
+
+	apiMetricsEnabled bool          // 是否统计 API 调用耗时
+	slowCallThreshold time.Duration // 慢调用日志阈值
+
+	alwaysRecreateOnUpdate bool // 配置更新时无论是否影响连接都重建连接
+
+	defaultDevice DeviceConfig // 账号未指定设备信息时使用的默认值
+
+	taskSlotWait time.Duration // 账号忙碌时等待任务槽位的最长时间
+
+	warm warmPool // 按使用热度保持的预热连接
+
+	autoAcceptTOS bool // 连接建立时自动接受待接受的服务条款
+
+	reconnectJitter       float64       // 重连延迟的随机抖动比例（0-1）
+	maxReconnectAttempts  int           // 最大重连次数
+	initialReconnectDelay time.Duration // 初始重连延迟
+	maxReconnectDelay     time.Duration // 最大重连延迟
+
+	floodWaitRetryThreshold time.Duration       // 不超过该时长的 FLOOD_WAIT 自动等待重试，0 表示不重试
+	accountErrorHandler     AccountErrorHandler // 连接和任务失败时更新账号风控状态，nil 时只标记警告
+
+	peerResolver *PeerResolver // 按账号缓存的目标解析结果
 }
 
 // NewConnectionPool 创建新的连接池
-func NewConnectionPool(appID int, appHash string, maxIdle time.Duration, accountRepo repository.AccountRepository, proxyRepo repository.ProxyRepository) *ConnectionPool {
+func NewConnectionPool(appID int, appHash string, poolConfig ConnectionPoolConfig, accountRepo repository.AccountRepository, proxyRepo repository.ProxyRepository) *ConnectionPool {
+	poolConfig = poolConfig.withDefaults()
 	cp := &ConnectionPool{
 		connections:    make(map[string]*ManagedConnection),
 		configs:        make(map[string]*ClientConfig),
-		maxIdle:        maxIdle,
+		maxIdle:        poolConfig.MaxIdle,
+		flushTimeout:   5 * time.Second,
 		logger:         logger.Get().Named("connection_pool"),
 		appID:          appID,
 		appHash:        appHash,
 		accountRepo:    accountRepo,
 		proxyRepo:      proxyRepo,
 		updateHandlers: make(map[string]telegram.UpdateHandler),
+
+		statusDebouncer: newConnectionStatusDebouncer(3 * time.Second),
+		peerResolver:    NewPeerResolver(defaultPeerCacheTTL),
+
+		maxReconnectAttempts:  poolConfig.MaxReconnectAttempts,
+		initialReconnectDelay: poolConfig.InitialReconnectDelay,
+		maxReconnectDelay:     poolConfig.MaxReconnectDelay,
+
+		floodWaitRetryThreshold: defaultFloodWaitRetryThreshold,
 	}
 
 	// 启动清理定时器
@@ -121,6 +209,56 @@ func NewConnectionPool(appID int, appHash string, maxIdle time.Duration, account
 	return cp
 }
 
+// SetShutdownFlushTimeout 设置关闭时等待Session落盘的时间
+func (cp *ConnectionPool) SetShutdownFlushTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		cp.flushTimeout = timeout
+	}
+}
+
+// SetReconnectJitter 设置重连延迟的随机抖动百分比，实际延迟在 ±percent% 内随机，
+// 避免共用代理恢复后所有账号在同一时刻重连
+func (cp *ConnectionPool) SetReconnectJitter(percent int) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	cp.reconnectJitter = float64(percent) / 100
+}
+
+// SetPeerCacheTTL 设置目标解析结果（含 access hash）的缓存有效期
+func (cp *ConnectionPool) SetPeerCacheTTL(ttl time.Duration) {
+	if ttl > 0 {
+		cp.peerResolver.ttl = ttl
+	}
+}
+
+// SetPeerAccessHashStore 设置 access hash 的持久化存储，数字ID目标在服务重启后仍可直接发送
+func (cp *ConnectionPool) SetPeerAccessHashStore(store repository.PeerAccessHashRepository) {
+	cp.peerResolver.SetStore(store)
+}
+
+// PeerResolver 返回连接池按账号缓存的目标解析器
+func (cp *ConnectionPool) PeerResolver() *PeerResolver {
+	return cp.peerResolver
+}
+
+// ResolvePeer 使用指定账号的连接解析目标，结果按账号缓存
+func (cp *ConnectionPool) ResolvePeer(ctx context.Context, accountID string, api *tg.Client, target string) (tg.InputPeerClass, error) {
+	return cp.peerResolver.Resolve(ctx, api, accountID, target)
+}
+
+// jitterDelay 对延迟施加随机抖动
+func (cp *ConnectionPool) jitterDelay(delay time.Duration) time.Duration {
+	if cp.reconnectJitter <= 0 {
+		return delay
+	}
+	factor := 1 + (rand.Float64()*2-1)*cp.reconnectJitter
+	return time.Duration(float64(delay) * factor)
+}
+
 // GetOrCreateConnection 获取或创建连接 (核心方法)
 func (cp *ConnectionPool) GetOrCreateConnection(accountID string, config *ClientConfig) (*ManagedConnection, error) {
 	cp.mu.Lock()
@@ -155,7 +293,7 @@ func (cp *ConnectionPool) GetOrCreateConnection(accountID string, config *Client
 	// 检查是否已存在旧连接，如果存在，先取消它以便通知等待者
 	if oldConn, exists := cp.connections[accountID]; exists {
 		cp.logger.Info("Canceling old connection before creating new one", zap.String("account_id", accountID))
-		oldConn.cancel()
+		oldConn.shutdown()
 		// 不需要 delete，因为下面会直接覆盖
 	}
 
@@ -188,6 +326,18 @@ func (cp *ConnectionPool) createNewConnection(accountID string, config *ClientCo
 	options := telegram.Options{
 		SessionStorage: sessionStorage,
 		UpdateHandler:  cp.createUpdateDispatcher(accountID),
+		Device:         config.Device.options(),
+	}
+
+	if cp.apiMetricsEnabled {
+		route := apiRouteDirect
+		if config.ProxyConfig != nil {
+			route = apiRouteProxy
+		}
+		options.Middlewares = append(options.Middlewares, cp.apiMetricsMiddleware(accountID, route))
+	}
+	if cp.floodWaitRetryThreshold > 0 {
+		options.Middlewares = append(options.Middlewares, cp.floodWaitMiddleware(accountID))
 	}
 
 	// 配置代理 (固定绑定)
@@ -226,15 +376,18 @@ func (cp *ConnectionPool) createNewConnection(accountID string, config *ClientCo
 	client := telegram.NewClient(cp.appID, cp.appHash, options)
 
 	conn := &ManagedConnection{
-		client:        client,
-		config:        config,
-		status:        StatusConnecting,
-		stateChangeCh: make(chan struct{}, 1),
-		lastUsed:      time.Now(),
-		isActive:      true,
-		ctx:           ctx,
-		cancel:        cancel,
-		logger:        cp.logger.Named(accountID),
+		client:         client,
+		config:         config,
+		status:         StatusConnecting,
+		stateChangeCh:  make(chan struct{}, 1),
+		slotFreedCh:    make(chan struct{}, 1),
+		lastUsed:       time.Now(),
+		isActive:       true,
+		ctx:            ctx,
+		cancel:         cancel,
+		logger:         cp.logger.Named(accountID),
+		sessionStorage: sessionStorage,
+		done:           make(chan struct{}),
 	}
 
 	// 异步建立连接
@@ -248,6 +401,8 @@ func (cp *ConnectionPool) createNewConnection(accountID string, config *ClientCo
 
 // maintainConnection 维护连接状态
 func (cp *ConnectionPool) maintainConnection(accountID string, conn *ManagedConnection) {
+	defer close(conn.done)
+
 	conn.logger.Info("Starting connection maintenance",
 		zap.String("account_id", accountID),
 		zap.String("phone", conn.config.Phone),
@@ -269,10 +424,17 @@ func (cp *ConnectionPool) maintainConnection(accountID string, conn *ManagedConn
 		conn.notifyStateChange() // 通知状态变更
 		conn.mu.Unlock()
 
+		connectTime := time.Since(startTime)
+		route := apiRouteDirect
+		if conn.config.ProxyConfig != nil {
+			route = apiRouteProxy
+		}
+		metrics.TelegramConnectDuration.WithLabelValues(route).Observe(connectTime.Seconds())
+
 		conn.logger.Info("Connection established successfully",
 			zap.String("account_id", accountID),
 			zap.String("phone", conn.config.Phone),
-			zap.Duration("connect_time", time.Since(startTime)))
+			zap.Duration("connect_time", connectTime))
 
 		// 连接成功，更新账号状态为正常
 		cp.updateAccountStatusOnSuccess(accountID)
@@ -294,7 +456,12 @@ func (cp *ConnectionPool) maintainConnection(accountID string, conn *ManagedConn
 		return ctx.Err()
 	})
 
-	if err != nil && err != context.Canceled {
+	if err != nil && err != context.Canceled && conn.isIntentionalShutdown() {
+		conn.logger.Info("Connection stopped intentionally, skipping reconnect",
+			zap.String("account_id", accountID),
+			zap.Error(err),
+			zap.Duration("session_duration", time.Since(startTime)))
+	} else if err != nil && err != context.Canceled {
 		conn.logger.Error("Connection error occurred",
 			zap.Error(err),
 			zap.String("error_type", fmt.Sprintf("%T", err)),
@@ -330,6 +497,12 @@ func (cp *ConnectionPool) maintainConnection(accountID string, conn *ManagedConn
 // scheduleReconnect 调度重连（带重试次数限制和指数退避）
 func (cp *ConnectionPool) scheduleReconnect(accountID string, conn *ManagedConnection) {
 	conn.mu.Lock()
+	if conn.intentionalShutdown {
+		conn.mu.Unlock()
+		cp.logger.Info("Connection was stopped intentionally, reconnect suppressed",
+			zap.String("account_id", accountID))
+		return
+	}
 	conn.reconnectCount++
 	currentAttempt := conn.reconnectCount
 	conn.lastReconnectAt = time.Now()
@@ -339,10 +512,11 @@ func (cp *ConnectionPool) scheduleReconnect(accountID string, conn *ManagedConne
 		zap.String("account_id", accountID),
 		zap.String("phone", conn.config.Phone),
 		zap.Int("attempt", currentAttempt),
-		zap.Int("max_attempts", MaxReconnectAttempts))
+		zap.Int("max_attempts", cp.maxReconnectAttempts))
 
 	// 检查是否超过最大重连次数
-	if currentAttempt > MaxReconnectAttempts {
+	if currentAttempt > cp.maxReconnectAttempts {
+		metrics.TelegramReconnectAttemptsTotal.WithLabelValues(accountID, "gave_up").Inc()
 		cp.logger.Error("Max reconnect attempts reached, giving up",
 			zap.String("account_id", accountID),
 			zap.String("phone", conn.config.Phone),
@@ -360,11 +534,16 @@ func (cp *ConnectionPool) scheduleReconnect(accountID string, conn *ManagedConne
 		return
 	}
 
-	// 计算指数退避延迟: 30s, 60s, 120s, 240s, 300s(max)
-	delay := InitialReconnectDelay * time.Duration(1<<(currentAttempt-1))
-	if delay > MaxReconnectDelay {
-		delay = MaxReconnectDelay
+	// 计算指数退避延迟: 初始延迟每次翻倍，不超过最大延迟
+	delay := cp.initialReconnectDelay
+	for i := 1; i < currentAttempt && delay < cp.maxReconnectDelay; i++ {
+		delay *= 2
+	}
+	if delay > cp.maxReconnectDelay {
+		delay = cp.maxReconnectDelay
 	}
+	delay = cp.jitterDelay(delay)
+	metrics.TelegramReconnectAttemptsTotal.WithLabelValues(accountID, "scheduled").Inc()
 
 	// 设置状态为重连中，以便任务可以等待
 	conn.mu.Lock()
@@ -376,7 +555,7 @@ func (cp *ConnectionPool) scheduleReconnect(accountID string, conn *ManagedConne
 		zap.String("account_id", accountID),
 		zap.String("phone", conn.config.Phone),
 		zap.Int("attempt", currentAttempt),
-		zap.Int("max_attempts", MaxReconnectAttempts),
+		zap.Int("max_attempts", cp.maxReconnectAttempts),
 		zap.Duration("delay", delay),
 		zap.Time("next_attempt_at", time.Now().Add(delay)))
 
@@ -384,6 +563,13 @@ func (cp *ConnectionPool) scheduleReconnect(accountID string, conn *ManagedConne
 		cp.mu.Lock()
 		defer cp.mu.Unlock()
 
+		// 延迟期间被主动关闭则放弃重连
+		if conn.isIntentionalShutdown() {
+			conn.logger.Info("Connection stopped during reconnect delay, reconnect suppressed",
+				zap.Int("attempt", currentAttempt))
+			return
+		}
+
 		// 检查连接是否仍然存在且需要重连
 		if currentConn, exists := cp.connections[accountID]; exists && currentConn == conn {
 			if config, configExists := cp.configs[accountID]; configExists {
@@ -408,6 +594,11 @@ func (cp *ConnectionPool) scheduleReconnect(accountID string, conn *ManagedConne
 
 // ExecuteTask 执行任务 (复用连接)
 func (cp *ConnectionPool) ExecuteTask(accountID string, task TaskInterface) error {
+	return cp.ExecuteTaskWithContext(context.Background(), accountID, task)
+}
+
+// ExecuteTaskWithContext 执行任务 (复用连接)，ctx 取消时停止等待任务槽位并取消任务执行
+func (cp *ConnectionPool) ExecuteTaskWithContext(ctx context.Context, accountID string, task TaskInterface) error {
 	taskStartTime := time.Now()
 	taskType := task.GetType()
 
@@ -415,6 +606,8 @@ func (cp *ConnectionPool) ExecuteTask(accountID string, task TaskInterface) erro
 		zap.String("account_id", accountID),
 		zap.String("task_type", taskType))
 
+	cp.recordAccountUsage(accountID)
+
 	config, exists := cp.configs[accountID]
 	if !exists {
 		// 动态加载账号配置
@@ -452,17 +645,15 @@ func (cp *ConnectionPool) ExecuteTask(accountID string, task TaskInterface) erro
 			return fmt.Errorf("failed to get connection: %w", err)
 		}
 
-		// 确保单任务执行
-		conn.mu.Lock()
-		if conn.taskRunning {
-			conn.mu.Unlock()
-			cp.logger.Warn("Account is busy with another task",
+		// 确保单任务执行，账号忙碌时排队等待槽位
+		if err := conn.acquireTaskSlot(ctx, cp.taskSlotWait); err != nil {
+			cp.logger.Warn("Failed to acquire task slot",
 				zap.String("account_id", accountID),
-				zap.String("task_type", taskType))
-			return errors.New("account is busy with another task")
+				zap.String("task_type", taskType),
+				zap.Duration("waited", time.Since(taskStartTime)),
+				zap.Error(err))
+			return err
 		}
-		conn.taskRunning = true
-		conn.mu.Unlock()
 
 		// 等待连接建立完成
 		cp.logger.Debug("Waiting for connection to be ready",
@@ -481,9 +672,7 @@ func (cp *ConnectionPool) ExecuteTask(accountID string, task TaskInterface) erro
 		}
 
 		// 等待失败，释放占用状态
-		conn.mu.Lock()
-		conn.taskRunning = false
-		conn.mu.Unlock()
+		conn.releaseTaskSlot()
 
 		// 检查是否是因为连接被替换（这是正常的重连流程）
 		if strings.Contains(err.Error(), "connection was replaced") || strings.Contains(err.Error(), "please retry") {
@@ -526,8 +715,6 @@ func (cp *ConnectionPool) ExecuteTask(accountID string, task TaskInterface) erro
 	// 直接执行任务逻辑
 	taskExecStartTime := time.Now()
 	taskErr := func() error {
-		ctx := context.Background()
-
 		// 安全检查：确保 client 不为 nil
 		if conn.client == nil {
 			cp.logger.Error("Connection client is nil",
@@ -559,9 +746,13 @@ func (cp *ConnectionPool) ExecuteTask(accountID string, task TaskInterface) erro
 	totalDuration := time.Since(taskStartTime)
 
 	// 释放任务运行状态
-	conn.mu.Lock()
-	conn.taskRunning = false
-	conn.mu.Unlock()
+	conn.releaseTaskSlot()
+
+	taskStatus := "success"
+	if taskErr != nil {
+		taskStatus = "failed"
+	}
+	metrics.TelegramPoolTaskDuration.WithLabelValues(taskType, taskStatus).Observe(totalDuration.Seconds())
 
 	// 根据任务执行结果更新账号状态
 	if taskErr != nil {
@@ -672,21 +863,77 @@ func (cp *ConnectionPool) IsAccountBusy(accountID string) bool {
 	return false
 }
 
-// UpdateConfig 更新账号配置
-func (cp *ConnectionPool) UpdateConfig(accountID string, config *ClientConfig) {
+// SetAlwaysRecreateOnConfigUpdate 设置配置更新时是否总是重建连接
+// 默认只有代理、Session、手机号、设备信息等影响连接的字段变化时才重建，其余情况原地更新缓存配置
+func (cp *ConnectionPool) SetAlwaysRecreateOnConfigUpdate(always bool) {
+	cp.alwaysRecreateOnUpdate = always
+}
+
+// UpdateConfig 更新账号配置，返回是否关闭了现有连接（下次使用时重新建立）
+func (cp *ConnectionPool) UpdateConfig(accountID string, config *ClientConfig) bool {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
 	cp.configs[accountID] = config
 
-	// 如果连接存在，标记需要重建
-	if conn, exists := cp.connections[accountID]; exists {
-		cp.logger.Info("Configuration updated, will recreate connection",
+	conn, exists := cp.connections[accountID]
+	if !exists {
+		return false
+	}
+
+	conn.mu.Lock()
+	changed := connectionConfigChanged(conn.config, config)
+	if !changed && !cp.alwaysRecreateOnUpdate {
+		conn.config = config
+		conn.mu.Unlock()
+		cp.logger.Debug("Configuration updated in place, connection reused",
 			zap.String("account_id", accountID))
+		return false
+	}
+	conn.mu.Unlock()
 
-		conn.cancel()
-		delete(cp.connections, accountID)
+	cp.logger.Info("Configuration updated, will recreate connection",
+		zap.String("account_id", accountID),
+		zap.Bool("connection_changed", changed))
+
+	conn.shutdown()
+	delete(cp.connections, accountID)
+	return true
+}
+
+// ReloadConfig 从数据库重新加载账号配置并更新连接池，返回是否关闭了现有连接
+// 账号没有缓存配置也没有连接时不做任何处理，下次使用时会按最新数据加载
+func (cp *ConnectionPool) ReloadConfig(accountID string) (bool, error) {
+	cp.mu.RLock()
+	_, hasConfig := cp.configs[accountID]
+	_, hasConn := cp.connections[accountID]
+	cp.mu.RUnlock()
+	if !hasConfig && !hasConn {
+		return false, nil
+	}
+
+	config, err := cp.loadAccountConfig(accountID)
+	if err != nil {
+		return false, err
+	}
+	return cp.UpdateConfig(accountID, config), nil
+}
+
+// connectionConfigChanged 判断配置变化是否影响连接（应用凭据、手机号、设备信息、Session、代理）
+func connectionConfigChanged(old, new *ClientConfig) bool {
+	if old == nil || new == nil {
+		return old != new
 	}
+	if old.AppID != new.AppID || old.AppHash != new.AppHash || old.Phone != new.Phone || old.Device != new.Device {
+		return true
+	}
+	if !bytes.Equal(old.SessionData, new.SessionData) {
+		return true
+	}
+	if old.ProxyConfig == nil || new.ProxyConfig == nil {
+		return old.ProxyConfig != new.ProxyConfig
+	}
+	return *old.ProxyConfig != *new.ProxyConfig
 }
 
 // RemoveConnection 移除连接
@@ -696,7 +943,7 @@ func (cp *ConnectionPool) RemoveConnection(accountID string) {
 
 	if conn, exists := cp.connections[accountID]; exists {
 		conn.logger.Info("Removing connection")
-		conn.cancel()
+		conn.shutdown()
 		delete(cp.connections, accountID)
 		// 确保更新在线状态为离线
 		go cp.updateConnectionStatus(accountID, false)
@@ -706,6 +953,26 @@ func (cp *ConnectionPool) RemoveConnection(accountID string) {
 	delete(cp.updateHandlers, accountID)
 }
 
+// ForceReconnect 强制重建连接（旧连接主动关闭，不触发自动重连）
+func (cp *ConnectionPool) ForceReconnect(accountID string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	config, exists := cp.configs[accountID]
+	if !exists {
+		return fmt.Errorf("no configuration found for account %s", accountID)
+	}
+
+	if conn, exists := cp.connections[accountID]; exists {
+		conn.logger.Info("Forcing reconnect")
+		conn.shutdown()
+		delete(cp.connections, accountID)
+	}
+
+	_, err := cp.createNewConnection(accountID, config)
+	return err
+}
+
 // SetUpdateHandler 设置账号的更新处理器
 func (cp *ConnectionPool) SetUpdateHandler(accountID string, handler telegram.UpdateHandler) {
 	cp.mu.Lock()
@@ -720,6 +987,7 @@ func (cp *ConnectionPool) createUpdateDispatcher(accountID string) telegram.Upda
 		handler, exists := cp.updateHandlers[accountID]
 		cp.mu.RUnlock()
 
+		cp.notifyListeners(ctx, accountID, u)
 		if exists && handler != nil {
 			return handler.Handle(ctx, u)
 		}
@@ -731,6 +999,7 @@ func (cp *ConnectionPool) createUpdateDispatcher(accountID string) telegram.Upda
 func (cp *ConnectionPool) cleanupLoop() {
 	for range cp.cleanupTicker.C {
 		cp.cleanupIdleConnections()
+		cp.peerResolver.Prune()
 	}
 }
 
@@ -747,12 +1016,17 @@ func (cp *ConnectionPool) cleanupIdleConnections() {
 		isIdle := !conn.taskRunning && now.Sub(conn.lastUsed) > cp.maxIdle
 		conn.mu.Unlock()
 
+		// 预热集合中的账号保持连接
+		if isIdle && cp.isWarmAccount(accountID) {
+			continue
+		}
+
 		if isIdle {
 			cp.logger.Info("Cleaning up idle connection",
 				zap.String("account_id", accountID),
 				zap.Duration("idle_time", now.Sub(conn.lastUsed)))
 
-			conn.cancel()
+			conn.shutdown()
 			toRemove = append(toRemove, accountID)
 		}
 	}
@@ -800,6 +1074,7 @@ func (cp *ConnectionPool) loadAccountConfig(accountID string) (*ClientConfig, er
 		AppHash:     cp.appHash,
 		Phone:       account.Phone,
 		SessionData: nil, // 不预加载，由 DatabaseSessionStorage 统一处理
+		Device:      cp.accountDevice(account),
 	}
 
 	// 如果账号绑定了代理，加载代理配置
@@ -811,12 +1086,17 @@ func (cp *ConnectionPool) loadAccountConfig(accountID string) (*ClientConfig, er
 				zap.Uint64("proxy_id", *account.ProxyID),
 				zap.Error(err))
 		} else if proxy != nil {
+			// 密码引用解析失败时不能退回直连，否则会暴露真实IP
+			password, err := proxy.ResolvePassword()
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve proxy password: %w", err)
+			}
 			config.ProxyConfig = &ProxyConfig{
 				Protocol: string(proxy.Protocol),
 				IP:       proxy.IP,
 				Port:     proxy.Port,
 				Username: proxy.Username,
-				Password: proxy.Password,
+				Password: password,
 			}
 			cp.logger.Info("Proxy configuration loaded for account",
 				zap.String("account_id", accountID),
@@ -838,6 +1118,33 @@ func (cp *ConnectionPool) loadAccountConfig(accountID string) (*ClientConfig, er
 	return config, nil
 }
 
+// GetRuntimeSettings 获取连接池实际使用的设置
+func (cp *ConnectionPool) GetRuntimeSettings() map[string]interface{} {
+	cp.statusDebouncer.mu.Lock()
+	statusDebounce := cp.statusDebouncer.delay
+	cp.statusDebouncer.mu.Unlock()
+
+	return map[string]interface{}{
+		"idle_timeout":               cp.maxIdle.String(),
+		"shutdown_flush_timeout":     cp.flushTimeout.String(),
+		"status_debounce":            statusDebounce.String(),
+		"api_metrics":                cp.apiMetricsEnabled,
+		"slow_call_threshold":        cp.slowCallThreshold.String(),
+		"always_recreate":            cp.alwaysRecreateOnUpdate,
+		"task_slot_wait":             cp.taskSlotWait.String(),
+		"warm_target":                cp.warm.target,
+		"warm_interval":              cp.warm.interval.String(),
+		"auto_accept_tos":            cp.autoAcceptTOS,
+		"reconnect_jitter":           cp.reconnectJitter,
+		"max_reconnect_attempts":     cp.maxReconnectAttempts,
+		"initial_reconnect_delay":    cp.initialReconnectDelay.String(),
+		"max_reconnect_delay":        cp.maxReconnectDelay.String(),
+		"flood_wait_retry_threshold": cp.floodWaitRetryThreshold.String(),
+		"peer_cache_ttl":             cp.peerResolver.ttl.String(),
+		"default_device_model":       cp.defaultDevice.DeviceModel,
+	}
+}
+
 // GetStats 获取连接池统计信息
 func (cp *ConnectionPool) GetStats() map[string]interface{} {
 	cp.mu.RLock()
@@ -848,6 +1155,7 @@ func (cp *ConnectionPool) GetStats() map[string]interface{} {
 		"active_connections":    0,
 		"busy_connections":      0,
 		"connections_by_status": make(map[string]int),
+		"warm_target":           cp.warm.target,
 	}
 
 	for _, conn := range cp.connections {
@@ -884,50 +1192,37 @@ func (cp *ConnectionPool) updateAccountInfoFromTelegram(accountID string, conn *
 	// 直接使用已建立的连接和 API 客户端，不再调用 Run()
 	api := conn.client.API()
 
-	// 获取当前用户信息
-	users, err := api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+	info, err := FetchAccountInfo(ctx, api)
 	if err != nil {
 		cp.logger.Warn("Failed to get user info from Telegram",
 			zap.String("account_id", accountID),
 			zap.Error(err))
-		// 检查是否是账号被禁用等严重错误，需要更新账号状态
-		errorStr := strings.ToUpper(err.Error())
-		if strings.Contains(errorStr, "USER_DEACTIVATED") ||
-			strings.Contains(errorStr, "AUTH_KEY_UNREGISTERED") ||
-			strings.Contains(errorStr, "PHONE_NUMBER_BANNED") ||
-			strings.Contains(errorStr, "SESSION_REVOKED") {
-			account, getErr := cp.accountRepo.GetByID(accountIDNum)
-			if getErr == nil {
-				account.Status = models.AccountStatusDead
-				now := time.Now()
-				account.LastCheckAt = &now
-				if updateErr := cp.accountRepo.Update(account); updateErr != nil {
-					cp.logger.Error("Failed to update account status to dead",
-						zap.String("account_id", accountID),
-						zap.Error(updateErr))
-				} else {
-					cp.logger.Info("Account marked as dead due to Telegram error",
-						zap.String("account_id", accountID),
-						zap.String("phone", account.Phone),
-						zap.String("error_type", errorStr))
-				}
-			}
-		}
+		cp.markAccountDeadOnAuthError(accountIDNum, err)
 		return
 	}
 
-	if len(users) == 0 {
-		cp.logger.Warn("No user info returned from Telegram",
-			zap.String("account_id", accountID))
+	if _, err := cp.saveAccountInfo(accountIDNum, info); err != nil {
 		return
 	}
 
+	cp.handleTermsOfService(ctx, accountIDNum, api)
+}
+
+// FetchAccountInfo 获取当前登录账号的用户ID、手机号、用户名、姓名、简介和头像
+func FetchAccountInfo(ctx context.Context, api *tg.Client) (*models.TelegramAccountInfo, error) {
+	// 获取当前用户信息
+	users, err := api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no user info returned from Telegram")
+	}
+
 	// 提取用户信息
 	user, ok := users[0].(*tg.User)
 	if !ok {
-		cp.logger.Warn("Unexpected user type from Telegram",
-			zap.String("account_id", accountID))
-		return
+		return nil, fmt.Errorf("unexpected user type from Telegram: %T", users[0])
 	}
 
 	// 准备更新数据
@@ -972,13 +1267,50 @@ func (cp *ConnectionPool) updateAccountInfoFromTelegram(accountID string, conn *
 		}
 	}
 
+	return info, nil
+}
+
+// markAccountDeadOnAuthError 账号被注销、封禁或授权失效时将账号标记为死亡
+func (cp *ConnectionPool) markAccountDeadOnAuthError(accountID uint64, err error) {
+	// 检查是否是账号被禁用等严重错误，需要更新账号状态
+	errorStr := strings.ToUpper(err.Error())
+	if !strings.Contains(errorStr, "USER_DEACTIVATED") &&
+		!strings.Contains(errorStr, "AUTH_KEY_UNREGISTERED") &&
+		!strings.Contains(errorStr, "PHONE_NUMBER_BANNED") &&
+		!strings.Contains(errorStr, "SESSION_REVOKED") {
+		return
+	}
+
+	account, getErr := cp.accountRepo.GetByID(accountID)
+	if getErr != nil {
+		return
+	}
+	account.Status = models.AccountStatusDead
+	now := time.Now()
+	account.LastCheckAt = &now
+	if updateErr := cp.accountRepo.Update(account); updateErr != nil {
+		cp.logger.Error("Failed to update account status to dead",
+			zap.Uint64("account_id", accountID),
+			zap.Error(updateErr))
+	} else {
+		cp.logger.Info("Account marked as dead due to Telegram error",
+			zap.Uint64("account_id", accountID),
+			zap.String("phone", account.Phone),
+			zap.String("error_type", errorStr))
+	}
+}
+
+// saveAccountInfo 将从 Telegram 获取的账号信息写入数据库，返回更新后的账号
+func (cp *ConnectionPool) saveAccountInfo(accountIDNum uint64, info *models.TelegramAccountInfo) (*models.TGAccount, error) {
+	accountID := strconv.FormatUint(accountIDNum, 10)
+
 	// 更新到数据库
 	account, err := cp.accountRepo.GetByID(accountIDNum)
 	if err != nil {
 		cp.logger.Error("Failed to get account from database",
 			zap.String("account_id", accountID),
 			zap.Error(err))
-		return
+		return nil, err
 	}
 
 	// 验证账号 ID 匹配，防止更新错误的账号
@@ -986,7 +1318,7 @@ func (cp *ConnectionPool) updateAccountInfoFromTelegram(accountID string, conn *
 		cp.logger.Error("Account ID mismatch! This should never happen!",
 			zap.String("expected_account_id", accountID),
 			zap.Uint64("actual_account_id", account.ID))
-		return
+		return nil, fmt.Errorf("account id mismatch: expected %d, got %d", accountIDNum, account.ID)
 	}
 
 	// 记录更新前的信息用于调试
@@ -1025,7 +1357,7 @@ func (cp *ConnectionPool) updateAccountInfoFromTelegram(accountID string, conn *
 		cp.logger.Error("Failed to update account info to database",
 			zap.String("account_id", accountID),
 			zap.Error(err))
-		return
+		return nil, err
 	}
 
 	cp.logger.Info("Account info updated from Telegram successfully",
@@ -1034,6 +1366,8 @@ func (cp *ConnectionPool) updateAccountInfoFromTelegram(accountID string, conn *
 		zap.Any("tg_user_id", info.TgUserID),
 		zap.Any("username", info.Username),
 		zap.Any("first_name", info.FirstName))
+
+	return account, nil
 }
 
 // updateAccountStatusOnSuccess 连接或任务成功时更新账号状态
@@ -1048,14 +1382,12 @@ func (cp *ConnectionPool) updateAccountStatusOnSuccess(accountID string) {
 		return
 	}
 
+	// 只更新相关字段，不整行保存，避免覆盖风控服务同时写入的冷却状态
+	cp.accountRepo.UpdateLastUsed(accountIDNum)
+
 	// 如果账号状态是警告或新建，更新为正常
 	if account.Status == models.AccountStatusWarning || account.Status == models.AccountStatusNew {
-		account.Status = models.AccountStatusNormal
-		now := time.Now()
-		account.LastCheckAt = &now
-		account.LastUsedAt = &now
-
-		if err := cp.accountRepo.Update(account); err != nil {
+		if err := cp.accountRepo.UpdateStatus(accountIDNum, models.AccountStatusNormal); err != nil {
 			cp.logger.Error("Failed to update account status to normal",
 				zap.String("account_id", accountID),
 				zap.Error(err))
@@ -1063,15 +1395,28 @@ func (cp *ConnectionPool) updateAccountStatusOnSuccess(accountID string) {
 			cp.logger.Info("Account status updated to normal",
 				zap.String("account_id", accountID))
 		}
-	} else {
-		// 只更新最后使用时间
-		now := time.Now()
-		account.LastUsedAt = &now
-		account.LastCheckAt = &now
-		cp.accountRepo.Update(account)
 	}
 }
 
+// AccountErrorHandler 根据 Telegram 错误更新账号风控状态（封禁、限流冷却、受限），由风控服务实现
+// (本地定义以避免循环引用)
+type AccountErrorHandler interface {
+	HandleTelegramError(ctx context.Context, accountID uint64, err error)
+}
+
+// SetAccountErrorHandler 设置账号错误处理器，连接和任务失败时的账号状态变更统一交给风控服务
+func (cp *ConnectionPool) SetAccountErrorHandler(handler AccountErrorHandler) {
+	cp.accountErrorHandler = handler
+}
+
+// handleAccountError 将错误交给风控服务更新账号状态
+func (cp *ConnectionPool) handleAccountError(accountID uint64, err error) {
+	if cp.accountErrorHandler == nil {
+		return
+	}
+	cp.accountErrorHandler.HandleTelegramError(context.Background(), accountID, err)
+}
+
 // updateAccountStatusOnError 连接失败时更新账号状态
 func (cp *ConnectionPool) updateAccountStatusOnError(accountID string, err error) {
 	accountIDNum, parseErr := strconv.ParseUint(accountID, 10, 64)
@@ -1079,110 +1424,34 @@ func (cp *ConnectionPool) updateAccountStatusOnError(accountID string, err error
 		return
 	}
 
+	cp.handleAccountError(accountIDNum, err)
+
+	// 风控服务未变更状态的其他连接错误，正常账号标记为警告
 	account, getErr := cp.accountRepo.GetByID(accountIDNum)
 	if getErr != nil {
 		return
 	}
-
-	// 根据错误类型判断是否需要更新状态
-	errorStr := strings.ToUpper(err.Error())
-
-	// 检查是否是严重错误（账号被封禁等）
-	if strings.Contains(errorStr, "AUTH_KEY_UNREGISTERED") ||
-		strings.Contains(errorStr, "USER_DEACTIVATED") ||
-		strings.Contains(errorStr, "PHONE_NUMBER_BANNED") {
-		account.Status = models.AccountStatusDead
-		cp.logger.Warn("Account marked as dead due to critical error",
-			zap.String("account_id", accountID),
-			zap.Error(err))
-	} else if strings.Contains(errorStr, "FLOOD_WAIT") ||
-		strings.Contains(errorStr, "SLOWMODE_WAIT") {
-		// 触发限流，设置为冷却状态
-		account.Status = models.AccountStatusCooling
-		cp.logger.Warn("Account marked as cooling due to rate limit",
-			zap.String("account_id", accountID),
-			zap.Error(err))
-	} else if account.Status == models.AccountStatusNormal || account.Status == models.AccountStatusNew {
-		// 其他错误，设置为警告状态
-		account.Status = models.AccountStatusWarning
-		cp.logger.Warn("Account marked as warning due to error",
-			zap.String("account_id", accountID),
-			zap.Error(err))
+	if account.Status != models.AccountStatusNormal && account.Status != models.AccountStatusNew {
+		return
 	}
-
-	now := time.Now()
-	account.LastCheckAt = &now
-
-	if updateErr := cp.accountRepo.Update(account); updateErr != nil {
+	if updateErr := cp.accountRepo.UpdateStatus(accountIDNum, models.AccountStatusWarning); updateErr != nil {
 		cp.logger.Error("Failed to update account status on error",
 			zap.String("account_id", accountID),
 			zap.Error(updateErr))
+		return
 	}
+	cp.logger.Warn("Account marked as warning due to error",
+		zap.String("account_id", accountID),
+		zap.Error(err))
 }
 
-// updateAccountStatusOnTaskError 任务执行失败时更新账号状态
+// updateAccountStatusOnTaskError 任务执行失败时由风控服务更新账号状态，其他错误不改变状态，可能是临时性问题
 func (cp *ConnectionPool) updateAccountStatusOnTaskError(accountID string, err error) {
 	accountIDNum, parseErr := strconv.ParseUint(accountID, 10, 64)
 	if parseErr != nil {
 		return
 	}
-
-	account, getErr := cp.accountRepo.GetByID(accountIDNum)
-	if getErr != nil {
-		return
-	}
-
-	errorStr := strings.ToUpper(err.Error())
-
-	// 检查是否是严重错误
-	if strings.Contains(errorStr, "AUTH_KEY_UNREGISTERED") ||
-		strings.Contains(errorStr, "USER_DEACTIVATED") ||
-		strings.Contains(errorStr, "PHONE_NUMBER_BANNED") {
-		account.Status = models.AccountStatusDead
-		cp.logger.Warn("Account marked as dead due to task error",
-			zap.String("account_id", accountID),
-			zap.Error(err))
-	} else if strings.Contains(errorStr, "FLOOD_WAIT") ||
-		strings.Contains(errorStr, "SLOWMODE_WAIT") ||
-		strings.Contains(errorStr, "PEER_FLOOD") {
-		// 触发限流，设置为冷却状态
-		account.Status = models.AccountStatusCooling
-		cp.logger.Warn("Account marked as cooling due to task error",
-			zap.String("account_id", accountID),
-			zap.Error(err))
-	} else if strings.Contains(errorStr, "CHAT_WRITE_FORBIDDEN") ||
-		strings.Contains(errorStr, "USER_RESTRICTED") ||
-		strings.Contains(errorStr, "CHAT_RESTRICTED") {
-		account.Status = models.AccountStatusRestricted
-		cp.logger.Warn("Account marked as restricted due to task error",
-			zap.String("account_id", accountID),
-			zap.Error(err))
-	}
-	// 其他错误不改变状态，可能是临时性问题
-
-	now := time.Now()
-	account.LastCheckAt = &now
-
-	if updateErr := cp.accountRepo.Update(account); updateErr != nil {
-		cp.logger.Error("Failed to update account status on task error",
-			zap.String("account_id", accountID),
-			zap.Error(updateErr))
-	}
-}
-
-// updateConnectionStatus 更新账号在线状态
-func (cp *ConnectionPool) updateConnectionStatus(accountID string, isOnline bool) {
-	accountIDNum, err := strconv.ParseUint(accountID, 10, 64)
-	if err != nil {
-		return
-	}
-
-	if err := cp.accountRepo.UpdateConnectionStatus(accountIDNum, isOnline); err != nil {
-		cp.logger.Error("Failed to update connection status",
-			zap.String("account_id", accountID),
-			zap.Bool("is_online", isOnline),
-			zap.Error(err))
-	}
+	cp.handleAccountError(accountIDNum, err)
 }
 
 // CheckConnection 主动检查账号连接状态
@@ -1204,12 +1473,16 @@ func (cp *ConnectionPool) CheckConnection(accountID uint64) error {
 	if account.ProxyID != nil {
 		proxy, err := cp.proxyRepo.GetByID(*account.ProxyID)
 		if err == nil && proxy.IsActive {
+			password, err := proxy.ResolvePassword()
+			if err != nil {
+				return fmt.Errorf("failed to resolve proxy password: %w", err)
+			}
 			config.ProxyConfig = &ProxyConfig{
 				Protocol: string(proxy.Protocol),
 				IP:       proxy.IP,
 				Port:     proxy.Port,
 				Username: proxy.Username,
-				Password: proxy.Password,
+				Password: password,
 			}
 		}
 	}
@@ -1273,19 +1546,87 @@ func (cp *ConnectionPool) CheckConnection(accountID uint64) error {
 }
 
 // Close 关闭连接池
-func (cp *ConnectionPool) Close() {
+// 先在 ctx 期限内等待正在执行任务的连接完成任务，避免发送到一半被中断；
+// 关闭前将各连接的Session写入数据库，取消后在 flushTimeout 内等待客户端退出并再次落盘，
+// 避免重启后Session回退
+func (cp *ConnectionPool) Close(ctx context.Context) {
 	cp.logger.Info("Closing connection pool")
 
 	cp.cleanupTicker.Stop()
+	if cp.warm.stop != nil {
+		close(cp.warm.stop)
+	}
+
+	if busy := cp.drainTasks(ctx); len(busy) > 0 {
+		cp.logger.Warn("Force cancelling connections with running tasks after drain window",
+			zap.Strings("account_ids", busy))
+	}
 
 	cp.mu.Lock()
-	defer cp.mu.Unlock()
+	conns := cp.connections
+	cp.connections = make(map[string]*ManagedConnection)
+	cp.configs = make(map[string]*ClientConfig)
+	cp.mu.Unlock()
 
-	for accountID, conn := range cp.connections {
+	flushCtx, cancel := context.WithTimeout(context.Background(), cp.flushTimeout)
+	defer cancel()
+
+	for accountID, conn := range conns {
+		if err := conn.sessionStorage.Flush(flushCtx); err != nil {
+			cp.logger.Warn("Failed to persist session before close",
+				zap.String("account_id", accountID),
+				zap.Error(err))
+		}
 		cp.logger.Debug("Closing connection", zap.String("account_id", accountID))
-		conn.cancel()
+		conn.shutdown()
 	}
 
-	cp.connections = make(map[string]*ManagedConnection)
-	cp.configs = make(map[string]*ClientConfig)
+	for accountID, conn := range conns {
+		select {
+		case <-conn.done:
+		case <-flushCtx.Done():
+			cp.logger.Warn("Timed out waiting for connection to close",
+				zap.String("account_id", accountID))
+		}
+		// 客户端退出过程中可能再次写入Session，重试之前失败的落盘
+		if err := conn.sessionStorage.Flush(context.Background()); err != nil {
+			cp.logger.Error("Failed to persist session on close",
+				zap.String("account_id", accountID),
+				zap.Error(err))
+		}
+	}
+
+	cp.flushConnectionStatus()
+
+	cp.logger.Info("Connection pool closed", zap.Int("connections", len(conns)))
+}
+
+// drainTasks 等待所有连接上正在执行的任务结束，ctx 到期时返回仍在执行任务的账号
+func (cp *ConnectionPool) drainTasks(ctx context.Context) []string {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var busy []string
+		cp.mu.RLock()
+		for accountID, conn := range cp.connections {
+			conn.mu.Lock()
+			if conn.taskRunning {
+				busy = append(busy, accountID)
+			}
+			conn.mu.Unlock()
+		}
+		cp.mu.RUnlock()
+
+		if len(busy) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			sort.Strings(busy)
+			return busy
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// connectionStatusDebouncer 在线状态防抖：状态需稳定一段时间才写入数据库，
+// 代理不稳定导致的快速上下线会被合并，减少数据库写入和界面抖动
+type connectionStatusDebouncer struct {
+	delay     time.Duration
+	mu        sync.Mutex
+	pending   map[string]*pendingStatus // accountID -> 等待写入的状态
+	persisted map[string]bool           // accountID -> 最近一次写入的状态
+}
+
+// pendingStatus 等待写入的状态
+type pendingStatus struct {
+	online bool
+	timer  *time.Timer
+}
+
+func newConnectionStatusDebouncer(delay time.Duration) *connectionStatusDebouncer {
+	return &connectionStatusDebouncer{
+		delay:     delay,
+		pending:   make(map[string]*pendingStatus),
+		persisted: make(map[string]bool),
+	}
+}
+
+// SetConnectionStatusDebounce 设置在线状态防抖时间，0 表示每次变化立即写入
+func (cp *ConnectionPool) SetConnectionStatusDebounce(delay time.Duration) {
+	cp.statusDebouncer.mu.Lock()
+	defer cp.statusDebouncer.mu.Unlock()
+	cp.statusDebouncer.delay = delay
+}
+
+// updateConnectionStatus 更新账号在线状态，开启防抖时仅在状态稳定后写入
+func (cp *ConnectionPool) updateConnectionStatus(accountID string, isOnline bool) {
+	d := cp.statusDebouncer
+	d.mu.Lock()
+
+	if d.delay <= 0 {
+		d.persisted[accountID] = isOnline
+		d.mu.Unlock()
+		cp.persistConnectionStatus(accountID, isOnline)
+		return
+	}
+
+	if p, exists := d.pending[accountID]; exists {
+		p.timer.Stop()
+		delete(d.pending, accountID)
+	}
+
+	// 在防抖窗口内又回到了已写入的状态，视为抖动直接丢弃
+	if persisted, ok := d.persisted[accountID]; ok && persisted == isOnline {
+		d.mu.Unlock()
+		return
+	}
+
+	p := &pendingStatus{online: isOnline}
+	p.timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		if d.pending[accountID] != p {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.pending, accountID)
+		d.persisted[accountID] = p.online
+		d.mu.Unlock()
+
+		cp.persistConnectionStatus(accountID, p.online)
+	})
+	d.pending[accountID] = p
+	d.mu.Unlock()
+}
+
+// flushConnectionStatus 立即写入所有等待中的状态，关闭连接池时调用
+func (cp *ConnectionPool) flushConnectionStatus() {
+	d := cp.statusDebouncer
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[string]*pendingStatus)
+	for accountID, p := range pending {
+		p.timer.Stop()
+		d.persisted[accountID] = p.online
+	}
+	d.mu.Unlock()
+
+	for accountID, p := range pending {
+		cp.persistConnectionStatus(accountID, p.online)
+	}
+}
+
+// persistConnectionStatus 将在线状态写入数据库
+func (cp *ConnectionPool) persistConnectionStatus(accountID string, isOnline bool) {
+	accountIDNum, err := strconv.ParseUint(accountID, 10, 64)
+	if err != nil {
+		return
+	}
+
+	if err := cp.accountRepo.UpdateConnectionStatus(accountIDNum, isOnline); err != nil {
+		cp.logger.Error("Failed to update connection status",
+			zap.String("account_id", accountID),
+			zap.Bool("is_online", isOnline),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,275 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 会话读取条数限制
+const (
+	DefaultConversationLimit = 50
+	MaxConversationLimit     = 100
+)
+
+// GetConversationTask 读取账号与指定会话（用户/群组/频道）的最近消息，不标记已读
+type GetConversationTask struct {
+	peer     string
+	limit    int
+	offsetID int
+	Result   *models.Conversation
+}
+
+// NewGetConversationTask 创建会话读取任务，offsetID 为0时从最新消息开始
+func NewGetConversationTask(peer string, limit, offsetID int) *GetConversationTask {
+	if limit <= 0 {
+		limit = DefaultConversationLimit
+	}
+	if limit > MaxConversationLimit {
+		limit = MaxConversationLimit
+	}
+	return &GetConversationTask{peer: strings.TrimSpace(peer), limit: limit, offsetID: offsetID}
+}
+
+// Execute 解析会话并获取消息，账号无权访问时只标记 Restricted，不返回错误
+func (t *GetConversationTask) Execute(ctx context.Context, api *tg.Client) error {
+	t.Result = &models.Conversation{Peer: t.peer, Messages: []models.ConversationMessage{}}
+
+	inputPeer, err := t.resolvePeer(ctx, api)
+	if err != nil {
+		if t.markRestricted(err) {
+			return nil
+		}
+		return err
+	}
+	if t.Result.Restricted {
+		return nil
+	}
+
+	history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+		Peer:     inputPeer,
+		OffsetID: t.offsetID,
+		Limit:    t.limit,
+	})
+	if err != nil {
+		if t.markRestricted(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get history: %w", err)
+	}
+
+	modified, ok := history.AsModified()
+	if !ok {
+		return nil
+	}
+
+	names := make(map[int64]string)
+	for _, u := range modified.GetUsers() {
+		if user, ok := u.(*tg.User); ok {
+			names[user.ID] = userDisplayName(user)
+		}
+	}
+	for _, c := range modified.GetChats() {
+		_, title := describeChat(c)
+		names[c.GetID()] = title
+	}
+
+	for _, m := range modified.GetMessages() {
+		msg := t.convertMessage(m, names)
+		if msg == nil {
+			continue
+		}
+		t.Result.Messages = append(t.Result.Messages, *msg)
+	}
+
+	// 返回满一页时，以最早一条消息ID作为下一页的偏移
+	if n := len(t.Result.Messages); n >= t.limit {
+		t.Result.NextOffsetID = t.Result.Messages[n-1].ID
+	}
+	return nil
+}
+
+// resolvePeer 解析会话目标，支持用户名、公开链接和已加入群组的邀请链接
+func (t *GetConversationTask) resolvePeer(ctx context.Context, api *tg.Client) (tg.InputPeerClass, error) {
+	if t.peer == "" {
+		return nil, fmt.Errorf("empty peer")
+	}
+
+	name := strings.TrimPrefix(t.peer, "@")
+	name = strings.TrimPrefix(name, "https://")
+	name = strings.TrimPrefix(name, "http://")
+	name = strings.TrimPrefix(name, "t.me/")
+
+	if hash, ok := inviteHash(name); ok {
+		invite, err := api.MessagesCheckChatInvite(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		already, ok := invite.(*tg.ChatInviteAlready)
+		if !ok {
+			t.Result.Restricted = true
+			t.Result.Reason = "account is not a member of this chat"
+			return nil, nil
+		}
+		return t.chatPeer(already.Chat)
+	}
+
+	if isNumeric(name) {
+		return nil, fmt.Errorf("numeric IDs cannot be resolved without access hash")
+	}
+
+	username, _ := parseDeepLink(t.peer)
+	if username == "" {
+		return nil, fmt.Errorf("invalid peer %q", t.peer)
+	}
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: username})
+	if err != nil {
+		return nil, fmt.Errorf("resolve username failed: %w", err)
+	}
+
+	switch p := resolved.Peer.(type) {
+	case *tg.PeerUser:
+		for _, u := range resolved.Users {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				t.Result.PeerType = "user"
+				t.Result.Title = userDisplayName(user)
+				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
+			}
+		}
+	default:
+		for _, c := range resolved.Chats {
+			if c.GetID() == peerID(resolved.Peer) {
+				return t.chatPeer(c)
+			}
+		}
+	}
+	return nil, fmt.Errorf("peer not found")
+}
+
+// chatPeer 群组/频道的 InputPeer，被封禁或内容受限时标记 Restricted
+func (t *GetConversationTask) chatPeer(chat tg.ChatClass) (tg.InputPeerClass, error) {
+	t.Result.PeerType, t.Result.Title = describeChat(chat)
+
+	switch c := chat.(type) {
+	case *tg.Chat:
+		return &tg.InputPeerChat{ChatID: c.ID}, nil
+	case *tg.Channel:
+		if c.Restricted {
+			t.Result.Restricted = true
+			t.Result.Reason = "channel content is restricted"
+			if len(c.RestrictionReason) > 0 {
+				t.Result.Reason = fmt.Sprintf("channel content is restricted: %s", c.RestrictionReason[0].Text)
+			}
+			return nil, nil
+		}
+		return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
+	case *tg.ChatForbidden, *tg.ChannelForbidden:
+		t.Result.Restricted = true
+		t.Result.Reason = "account has no access to this chat"
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported chat type %T", chat)
+}
+
+// serviceActionName 将服务消息的动作转换为稳定可读的名称，取自 TL 类型名，如 messageActionChatAddUser -> chat_add_user
+func serviceActionName(action tg.MessageActionClass) string {
+	if action == nil {
+		return "unknown"
+	}
+	name := strings.TrimPrefix(action.TypeName(), "messageAction")
+
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// markRestricted 无权访问类错误转为 Restricted 结果，返回是否已处理
+func (t *GetConversationTask) markRestricted(err error) bool {
+	errStr := strings.ToUpper(err.Error())
+	for _, code := range []string{"CHANNEL_PRIVATE", "CHAT_FORBIDDEN", "CHANNEL_PUBLIC_GROUP_NA", "USER_BANNED_IN_CHANNEL", "CHAT_ADMIN_REQUIRED"} {
+		if strings.Contains(errStr, code) {
+			t.Result.Restricted = true
+			t.Result.Reason = code
+			return true
+		}
+	}
+	return false
+}
+
+// convertMessage 转换单条消息，空消息返回 nil
+func (t *GetConversationTask) convertMessage(m tg.MessageClass, names map[int64]string) *models.ConversationMessage {
+	switch msg := m.(type) {
+	case *tg.Message:
+		result := &models.ConversationMessage{
+			ID:       msg.ID,
+			Out:      msg.Out,
+			Text:     msg.Message,
+			Date:     int64(msg.Date),
+			HasMedia: msg.Media != nil,
+		}
+		// 私聊和频道广播消息没有 FromID，发送者即会话本身
+		sender := msg.PeerID
+		if msg.FromID != nil {
+			sender = msg.FromID
+		}
+		if sender != nil && !msg.Out {
+			result.SenderID = peerID(sender)
+			result.SenderName = names[result.SenderID]
+		}
+		return result
+	case *tg.MessageService:
+		result := &models.ConversationMessage{
+			ID:      msg.ID,
+			Out:     msg.Out,
+			Text:    serviceActionName(msg.Action),
+			Date:    int64(msg.Date),
+			Service: true,
+		}
+		if msg.FromID != nil {
+			result.SenderID = peerID(msg.FromID)
+			result.SenderName = names[result.SenderID]
+		}
+		return result
+	}
+	return nil
+}
+
+// GetType 获取任务类型
+func (t *GetConversationTask) GetType() string {
+	return "get_conversation"
+}
+
+// peerID 返回 Peer 对应的用户/群组/频道ID
+func peerID(peer tg.PeerClass) int64 {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		return p.UserID
+	case *tg.PeerChat:
+		return p.ChatID
+	case *tg.PeerChannel:
+		return p.ChannelID
+	}
+	return 0
+}
+
+// userDisplayName 用户显示名称，没有名字时使用用户名
+func userDisplayName(user *tg.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Username
+	}
+	return name
+}
@@ -0,0 +1,75 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// parseDeepLink 解析用户名或公开链接，返回用户名和 ?start= 启动参数
+// 支持 @name、t.me/name?start=xxx、https://telegram.me/name 以及 tg://resolve?domain=name&start=xxx
+func parseDeepLink(target string) (username, startParam string) {
+	s := strings.TrimPrefix(strings.TrimSpace(target), "@")
+
+	if strings.HasPrefix(s, "tg://") {
+		if u, err := url.Parse(s); err == nil {
+			q := u.Query()
+			return q.Get("domain"), q.Get("start")
+		}
+		return "", ""
+	}
+
+	s = strings.TrimPrefix(s, "https://")
+	s = strings.TrimPrefix(s, "http://")
+	s = strings.TrimPrefix(s, "t.me/")
+	s = strings.TrimPrefix(s, "telegram.me/")
+
+	if idx := strings.IndexByte(s, '?'); idx != -1 {
+		if q, err := url.ParseQuery(s[idx+1:]); err == nil {
+			startParam = q.Get("start")
+		}
+		s = s[:idx]
+	}
+	// 去掉消息链接等附加路径 (t.me/name/123)
+	if idx := strings.IndexByte(s, '/'); idx != -1 {
+		s = s[:idx]
+	}
+	return s, startParam
+}
+
+// resolvedBot 返回用户名解析结果中的机器人用户，目标不是机器人时返回 nil
+func resolvedBot(resolved *tg.ContactsResolvedPeer) *tg.User {
+	peer, ok := resolved.Peer.(*tg.PeerUser)
+	if !ok {
+		return nil
+	}
+	for _, u := range resolved.Users {
+		if user, ok := u.(*tg.User); ok && user.ID == peer.UserID && user.Bot {
+			return user
+		}
+	}
+	return nil
+}
+
+// startBotPeer 返回机器人的 Peer，有启动参数时先以该参数启动机器人（推荐/验证类机器人依赖该参数）
+func startBotPeer(ctx context.Context, api *tg.Client, bot *tg.User, startParam string) (tg.InputPeerClass, error) {
+	peer := &tg.InputPeerUser{UserID: bot.ID, AccessHash: bot.AccessHash}
+	if startParam == "" {
+		return peer, nil
+	}
+
+	_, err := api.MessagesStartBot(ctx, &tg.MessagesStartBotRequest{
+		Bot:        &tg.InputUser{UserID: bot.ID, AccessHash: bot.AccessHash},
+		Peer:       peer,
+		RandomID:   time.Now().UnixNano(),
+		StartParam: startParam,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start bot %s with start parameter: %w", bot.Username, err)
+	}
+	return peer, nil
+}
@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"hash/fnv"
+
+	"github.com/gotd/td/telegram"
+
+	"tg_cloud_server/internal/models"
+)
+
+// DeviceConfig 建立连接时上报给 Telegram 的设备信息
+type DeviceConfig struct {
+	DeviceModel   string
+	SystemVersion string
+	AppVersion    string
+	LangCode      string
+}
+
+// devicePresets 常见的真实客户端设备组合，账号未指定时从中挑选，避免所有连接使用相同的 gotd 默认值
+var devicePresets = []DeviceConfig{
+	{DeviceModel: "iPhone 15 Pro", SystemVersion: "iOS 17.5.1", AppVersion: "10.14.1"},
+	{DeviceModel: "iPhone 14", SystemVersion: "iOS 17.4", AppVersion: "10.13"},
+	{DeviceModel: "iPhone 13", SystemVersion: "iOS 16.7.8", AppVersion: "10.12.2"},
+	{DeviceModel: "iPhone 12 mini", SystemVersion: "iOS 17.2.1", AppVersion: "10.11.1"},
+	{DeviceModel: "Samsung Galaxy S23", SystemVersion: "SDK 34", AppVersion: "10.14.5"},
+	{DeviceModel: "Samsung Galaxy A54", SystemVersion: "SDK 33", AppVersion: "10.13.4"},
+	{DeviceModel: "Xiaomi Redmi Note 12", SystemVersion: "SDK 33", AppVersion: "10.12.0"},
+	{DeviceModel: "Google Pixel 7", SystemVersion: "SDK 34", AppVersion: "10.14.3"},
+	{DeviceModel: "OnePlus 11", SystemVersion: "SDK 34", AppVersion: "10.13.2"},
+	{DeviceModel: "Desktop", SystemVersion: "Windows 10", AppVersion: "5.1.7 x64"},
+	{DeviceModel: "Desktop", SystemVersion: "Windows 11", AppVersion: "5.2.3 x64"},
+	{DeviceModel: "MacBook Pro", SystemVersion: "macOS 14.5", AppVersion: "10.14"},
+}
+
+// countryLangCodes 手机号国家到常用语言的映射，未列出的国家使用英语
+var countryLangCodes = map[string]string{
+	"CN": "zh", "TW": "zh", "HK": "zh",
+	"RU": "ru", "UA": "uk", "BY": "ru", "KZ": "ru",
+	"ID": "id", "VN": "vi", "TH": "th", "MY": "ms",
+	"BR": "pt", "PT": "pt", "ES": "es", "MX": "es", "AR": "es", "CO": "es",
+	"DE": "de", "FR": "fr", "IT": "it", "TR": "tr", "IR": "fa",
+	"SA": "ar", "EG": "ar", "AE": "ar", "JP": "ja", "KR": "ko", "IN": "en",
+}
+
+// SetDefaultDevice 设置连接池默认设备信息，账号未单独指定的字段使用该值，仍为空时按账号从预设中挑选
+func (cp *ConnectionPool) SetDefaultDevice(device DeviceConfig) {
+	cp.defaultDevice = device
+}
+
+// accountDevice 计算账号使用的设备信息：账号配置 > 连接池默认 > 按手机号固定挑选的预设
+// 预设按手机号哈希选择，同一账号每次连接上报的设备保持一致
+func (cp *ConnectionPool) accountDevice(account *models.TGAccount) DeviceConfig {
+	h := fnv.New32a()
+	h.Write([]byte(account.Phone))
+	preset := devicePresets[h.Sum32()%uint32(len(devicePresets))]
+	preset.LangCode = "en"
+	if lang, ok := countryLangCodes[account.CountryCode]; ok {
+		preset.LangCode = lang
+	}
+
+	pick := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	return DeviceConfig{
+		DeviceModel:   pick(account.DeviceModel, cp.defaultDevice.DeviceModel, preset.DeviceModel),
+		SystemVersion: pick(account.SystemVersion, cp.defaultDevice.SystemVersion, preset.SystemVersion),
+		AppVersion:    pick(account.AppVersion, cp.defaultDevice.AppVersion, preset.AppVersion),
+		LangCode:      pick(account.LangCode, cp.defaultDevice.LangCode, preset.LangCode),
+	}
+}
+
+// options 转换为 gotd 的设备配置，为空时返回零值（使用 gotd 默认值）
+func (d DeviceConfig) options() telegram.DeviceConfig {
+	return telegram.DeviceConfig{
+		DeviceModel:    d.DeviceModel,
+		SystemVersion:  d.SystemVersion,
+		AppVersion:     d.AppVersion,
+		SystemLangCode: d.LangCode,
+		LangCode:       d.LangCode,
+	}
+}
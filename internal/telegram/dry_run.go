@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// DryRunFromConfig 是否为试运行：只解析目标并检查成员关系，不发送任何消息
+func DryRunFromConfig(config map[string]interface{}) bool {
+	dryRun, _ := config["dry_run"].(bool)
+	return dryRun
+}
+
+// DryRunResult 试运行时单个目标的检查结果
+type DryRunResult struct {
+	models.RecipientPreview
+	AccountID  string `json:"account_id,omitempty"`
+	Member     bool   `json:"member"`
+	CannotPost string `json:"cannot_post,omitempty"` // 已加入但无法发言的原因
+	WouldSend  bool   `json:"would_send"`
+}
+
+// dryRunTargets 逐个解析目标并检查成员关系；toUser 为 true 时只有用户目标可以发送（私信），
+// 否则群组/频道需要已加入且能发言（群发）
+func dryRunTargets(ctx context.Context, api *tg.Client, resolver *peerResolution, targets []interface{}, toUser bool, addLog func(string)) []DryRunResult {
+	preview := &PreviewRecipientsTask{}
+	results := make([]DryRunResult, 0, len(targets))
+	for i, target := range targets {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return results
+			case <-time.After(previewResolveInterval):
+			}
+		}
+
+		name := strings.TrimSpace(fmt.Sprintf("%v", target))
+		if f, ok := target.(float64); ok {
+			name = fmt.Sprintf("%d", int64(f))
+		}
+		result := DryRunResult{
+			RecipientPreview: preview.previewTarget(ctx, api, name),
+			AccountID:        resolver.peerAccountID,
+		}
+		result.Member = result.Status == models.RecipientStatusMember
+
+		switch {
+		case toUser:
+			result.WouldSend = result.Status == models.RecipientStatusResolved && result.PeerType == "user"
+		case result.Status == models.RecipientStatusResolved:
+			// 机器人/用户目标
+			result.WouldSend = true
+		case result.Member:
+			if peer, err := resolver.resolveTarget(ctx, api, name); err == nil {
+				if reason, err := checkCanPost(ctx, api, peer); err == nil {
+					result.CannotPost = reason
+				}
+			}
+			result.WouldSend = result.CannotPost == ""
+		}
+
+		if result.WouldSend {
+			addLog(fmt.Sprintf("[试运行] %s: 可发送 (%s)", name, result.Status))
+		} else {
+			addLog(fmt.Sprintf("[试运行] %s: 无法发送 (%s%s)", name, result.Status, dryRunDetail(result)))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// dryRunDetail 返回无法发送的补充说明
+func dryRunDetail(result DryRunResult) string {
+	if result.CannotPost != "" {
+		return ", " + result.CannotPost
+	}
+	if result.Error != "" {
+		return ", " + result.Error
+	}
+	return ""
+}
+
+// recordDryRun 将试运行结果追加到任务结果中，多个账号的结果合并统计
+func recordDryRun(result models.TaskResult, entries []DryRunResult) {
+	existing, _ := result["dry_run_results"].([]interface{})
+	wouldSend, _ := result["would_send_count"].(int)
+	if v, ok := result["would_send_count"].(float64); ok {
+		wouldSend = int(v)
+	}
+	wouldFail, _ := result["would_fail_count"].(int)
+	if v, ok := result["would_fail_count"].(float64); ok {
+		wouldFail = int(v)
+	}
+
+	for _, entry := range entries {
+		existing = append(existing, entry)
+		if entry.WouldSend {
+			wouldSend++
+		} else {
+			wouldFail++
+		}
+	}
+	result["dry_run"] = true
+	result["dry_run_results"] = existing
+	result["would_send_count"] = wouldSend
+	result["would_fail_count"] = wouldFail
+}
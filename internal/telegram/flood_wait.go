@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+)
+
+// FLOOD_WAIT 自动重试参数
+const (
+	defaultFloodWaitRetryThreshold = 60 * time.Second // 不超过该时长的 FLOOD_WAIT 在连接层等待后自动重试
+	floodWaitMaxRetries            = 3                // 单次调用最多自动重试次数
+)
+
+// floodWaitPattern 匹配 FLOOD_WAIT_123、FLOOD_WAIT (123)、FLOOD_PREMIUM_WAIT_123 等格式中的等待秒数
+var floodWaitPattern = regexp.MustCompile(`FLOOD_(?:PREMIUM_)?WAIT[_\s]*\(?(\d+)`)
+
+// ParseFloodWaitSeconds 从错误文本中解析 FLOOD_WAIT 的等待秒数，连接层和风控服务共用
+func ParseFloodWaitSeconds(text string) (int, bool) {
+	match := floodWaitPattern.FindStringSubmatch(strings.ToUpper(text))
+	if match == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return seconds, true
+}
+
+// FloodWaitDuration 解析 FLOOD_WAIT 错误中服务端要求的等待时间，调度器据此安排任务重新执行
+func FloodWaitDuration(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	if wait, ok := tgerr.AsFloodWait(err); ok {
+		return wait, true
+	}
+	// 错误链中没有 RPC 错误（如已被格式化为字符串）时按文本解析
+	seconds, ok := ParseFloodWaitSeconds(err.Error())
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// SetFloodWaitRetry 设置 FLOOD_WAIT 自动重试阈值：等待时间不超过阈值时在连接层等待后重试该次调用，
+// 超过阈值时返回错误，由任务失败流程将账号置为冷却。0 表示不自动重试，只对之后新建的连接生效
+func (cp *ConnectionPool) SetFloodWaitRetry(threshold time.Duration) {
+	if threshold < 0 {
+		threshold = 0
+	}
+	cp.floodWaitRetryThreshold = threshold
+}
+
+// floodWaitSelfHandled 自行处理 FLOOD_WAIT 的调用不在连接层自动重试：邀请链接加群有独立的等待重试策略，
+// 发送类调用由任务按发送节奏和账号冷却处理，连接层静默延迟重发会打乱发送间隔并占住任务槽位
+func floodWaitSelfHandled(input bin.Encoder) bool {
+	switch input.(type) {
+	case *tg.MessagesImportChatInviteRequest,
+		*tg.MessagesSendMessageRequest,
+		*tg.MessagesSendMediaRequest,
+		*tg.MessagesSendMultiMediaRequest,
+		*tg.MessagesForwardMessagesRequest:
+		return true
+	}
+	return false
+}
+
+// floodWaitMiddleware 遇到较短的 FLOOD_WAIT 时按服务端给出的时间等待后重试单次调用，自行处理限流的调用除外
+func (cp *ConnectionPool) floodWaitMiddleware(accountID string) telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			if floodWaitSelfHandled(input) {
+				return next.Invoke(ctx, input, output)
+			}
+			for attempt := 0; ; attempt++ {
+				err := next.Invoke(ctx, input, output)
+				if err == nil || attempt >= floodWaitMaxRetries {
+					return err
+				}
+				wait, ok := tgerr.AsFloodWait(err)
+				if !ok || wait > cp.floodWaitRetryThreshold {
+					return err
+				}
+
+				cp.logger.Info("FLOOD_WAIT received, retrying after wait",
+					zap.String("account_id", accountID),
+					zap.String("method", apiMethodName(input)),
+					zap.Duration("wait", wait),
+					zap.Int("attempt", attempt+1))
+
+				timer := time.NewTimer(wait + time.Second)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return errors.Join(err, ctx.Err())
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 多次调用时内置回复应大致均匀分布，而不是因为按时间取模总落在同一条上
+func TestGroupChatSimpleResponseDistribution(t *testing.T) {
+	task := NewGroupChatTask(&models.Task{Config: models.TaskConfig{}}, nil)
+	msg := &tg.Message{Message: "hello everyone"}
+	responses := []string{"Hello there! 👋", "Hi! How's everyone? 😊", "Hey! 🙋‍♂️"}
+
+	const calls = 6000
+	counts := make(map[string]int)
+	for i := 0; i < calls; i++ {
+		counts[task.generateSimpleAIResponse(msg, map[string]interface{}{})]++
+	}
+
+	if len(counts) != len(responses) {
+		t.Fatalf("got %d distinct responses, want %d: %v", len(counts), len(responses), counts)
+	}
+	expected := float64(calls) / float64(len(responses))
+	for _, r := range responses {
+		if diff := math.Abs(float64(counts[r]) - expected); diff > expected*0.1 {
+			t.Errorf("response %q chosen %d times, want about %.0f", r, counts[r], expected)
+		}
+	}
+}
+
+// 回复概率应接近 response_rate
+func TestGroupChatShouldRespondRate(t *testing.T) {
+	task := NewGroupChatTask(&models.Task{Config: models.TaskConfig{}}, nil)
+	msg := &tg.Message{Message: "anything"}
+	aiConfig := map[string]interface{}{"response_rate": 0.3}
+
+	const calls = 10000
+	responded := 0
+	for i := 0; i < calls; i++ {
+		if task.shouldRespondSimple(msg, aiConfig) {
+			responded++
+		}
+	}
+
+	if rate := float64(responded) / calls; math.Abs(rate-0.3) > 0.03 {
+		t.Errorf("respond rate = %.3f, want about 0.3", rate)
+	}
+}
@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// groupSendCounterTTL 每日计数键的有效期，跨过零点后旧键自然过期
+const groupSendCounterTTL = 48 * time.Hour
+
+// GroupSendLimiter 基于 Redis 按 (账号, 群组) 统计每日群发次数，所有群发任务共享计数
+type GroupSendLimiter struct {
+	client     *redis.Client
+	defaultCap int // 每个账号每天向同一群组发送的默认上限，<=0 表示不限制（仍然计数）
+}
+
+// NewGroupSendLimiter 创建群组每日发送次数限制器
+func NewGroupSendLimiter(client *redis.Client, defaultCap int) *GroupSendLimiter {
+	return &GroupSendLimiter{client: client, defaultCap: defaultCap}
+}
+
+// DefaultCap 返回默认的每日上限
+func (l *GroupSendLimiter) DefaultCap() int {
+	return l.defaultCap
+}
+
+// Reserve 预占一次发送名额，超过上限时不占用名额并返回 false；返回值 count 为预占后（或当前）的当日次数
+// 发送失败时需调用 Release 归还名额
+func (l *GroupSendLimiter) Reserve(ctx context.Context, accountID uint64, group string, cap int) (bool, int64, error) {
+	key := groupSendCounterKey(accountID, group, time.Now())
+	count, err := l.client.Incr(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if count == 1 {
+		l.client.Expire(ctx, key, groupSendCounterTTL)
+	}
+	if cap > 0 && count > int64(cap) {
+		l.client.Decr(ctx, key)
+		return false, count - 1, nil
+	}
+	return true, count, nil
+}
+
+// Release 归还 Reserve 预占的名额
+func (l *GroupSendLimiter) Release(ctx context.Context, accountID uint64, group string) error {
+	return l.client.Decr(ctx, groupSendCounterKey(accountID, group, time.Now())).Err()
+}
+
+// groupSendCounterKey 每日计数键，群组标识统一为小写且去掉链接前缀，同一群组的不同写法共享计数
+func groupSendCounterKey(accountID uint64, group string, day time.Time) string {
+	normalized := strings.ToLower(strings.TrimSpace(group))
+	for _, prefix := range []string{"https://", "http://", "t.me/", "@"} {
+		normalized = strings.TrimPrefix(normalized, prefix)
+	}
+	return fmt.Sprintf("broadcast:group_sends:%s:%d:%s", day.Format("20060102"), accountID, normalized)
+}
@@ -0,0 +1,26 @@
+package telegram
+
+import (
+	"context"
+
+	"github.com/gotd/td/tg"
+)
+
+// HeartbeatTask 将账号设为在线，用于让长期闲置的账号在 Telegram 中保持活跃
+type HeartbeatTask struct{}
+
+// NewHeartbeatTask 创建在线心跳任务
+func NewHeartbeatTask() *HeartbeatTask {
+	return &HeartbeatTask{}
+}
+
+// Execute 调用 account.updateStatus(offline=false)
+func (t *HeartbeatTask) Execute(ctx context.Context, api *tg.Client) error {
+	_, err := api.AccountUpdateStatus(ctx, false)
+	return err
+}
+
+// GetType 获取任务类型
+func (t *HeartbeatTask) GetType() string {
+	return "heartbeat"
+}
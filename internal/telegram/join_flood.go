@@ -0,0 +1,102 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// JoinRetryPolicy 邀请链接加群（MessagesImportChatInvite）的限流处理，与发送消息的限制分开配置
+type JoinRetryPolicy struct {
+	Interval   time.Duration // 相邻两次加群之间的最小间隔
+	MaxRetries int           // 遇到 FLOOD_WAIT 时的最大重试次数，0 表示不重试
+	MaxWait    time.Duration // 单次愿意等待的最长时间，超过则放弃该群组
+}
+
+// DefaultJoinRetryPolicy 未配置时使用的加群限流处理
+var DefaultJoinRetryPolicy = JoinRetryPolicy{
+	Interval:   5 * time.Second,
+	MaxRetries: 2,
+	MaxWait:    2 * time.Minute,
+}
+
+// SetJoinRetryPolicy 设置邀请链接加群的间隔和 FLOOD_WAIT 重试策略，任务配置 join_interval_seconds 可覆盖间隔
+func (t *BroadcastTask) SetJoinRetryPolicy(policy JoinRetryPolicy) {
+	t.joinRetry = policy
+}
+
+// waitJoinInterval 距上次加群不足间隔时等待，任务取消时返回错误
+func (t *BroadcastTask) waitJoinInterval(ctx context.Context) error {
+	interval := t.joinRetry.Interval
+	if v, ok := t.task.Config["join_interval_seconds"].(float64); ok && v >= 0 {
+		interval = time.Duration(v * float64(time.Second))
+	}
+	if t.lastJoinAt.IsZero() || interval <= 0 {
+		return nil
+	}
+	wait := time.Until(t.lastJoinAt.Add(interval))
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// importChatInvite 通过邀请链接加群，遇到 FLOOD_WAIT 时在允许范围内等待后重试，并记录每次限流
+func (t *BroadcastTask) importChatInvite(ctx context.Context, api *tg.Client, group, hash string) (tg.UpdatesClass, error) {
+	if err := t.waitJoinInterval(ctx); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		t.lastJoinAt = time.Now()
+		updates, err := api.MessagesImportChatInvite(ctx, hash)
+		if err == nil {
+			return updates, nil
+		}
+
+		wait, ok := tgerr.AsFloodWait(err)
+		if !ok {
+			return nil, err
+		}
+		retry := attempt < t.joinRetry.MaxRetries && wait <= t.joinRetry.MaxWait
+		t.recordJoinFloodWait(group, wait, attempt+1, retry)
+		if !retry {
+			return nil, fmt.Errorf("join flood wait %s exceeds retry policy: %w", wait, err)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// recordJoinFloodWait 在任务结果中记录加群限流，便于调整加群列表的节奏
+func (t *BroadcastTask) recordJoinFloodWait(group string, wait time.Duration, attempt int, retried bool) {
+	floodWaits, _ := t.task.Result["join_flood_waits"].([]interface{})
+	t.task.Result["join_flood_waits"] = append(floodWaits, map[string]interface{}{
+		"group":        group,
+		"wait_seconds": int(wait.Seconds()),
+		"attempt":      attempt,
+		"retried":      retried,
+		"at":           time.Now().Unix(),
+	})
+
+	total := int(wait.Seconds())
+	switch v := t.task.Result["join_flood_wait_seconds"].(type) {
+	case int:
+		total += v
+	case float64:
+		total += int(v)
+	}
+	t.task.Result["join_flood_wait_seconds"] = total
+}
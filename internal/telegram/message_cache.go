@@ -0,0 +1,167 @@
+package telegram
+
+import (
+	"container/list"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/common/metrics"
+	"tg_cloud_server/internal/models"
+)
+
+// 消息缓存默认容量
+const (
+	defaultMessageCacheMaxMessages = 5000 // 所有场景、所有账号合计
+	maxCachedMessagesPerKey        = 100  // 单个账号最多保留的消息数
+)
+
+// MessageCache 智能体消息缓存，所有场景任务共享一个总容量
+// 超出容量时按 LRU 淘汰最久未访问账号的缓存，被淘汰的账号下次读取时会重新拉取聊天记录
+type MessageCache struct {
+	mu          sync.Mutex
+	maxMessages int
+	entries     map[string]*list.Element
+	lru         *list.List // 队头为最近访问
+	total       int
+	logger      *zap.Logger
+}
+
+// messageCacheEntry 单个账号的缓存
+type messageCacheEntry struct {
+	key      string
+	messages []models.ChatMessage
+}
+
+// NewMessageCache 创建消息缓存，maxMessages 为所有账号合计的最大消息数
+func NewMessageCache(maxMessages int) *MessageCache {
+	if maxMessages <= 0 {
+		maxMessages = defaultMessageCacheMaxMessages
+	}
+	return &MessageCache{
+		maxMessages: maxMessages,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		logger:      logger.Get().Named("message_cache"),
+	}
+}
+
+// Get 获取缓存的消息副本
+func (c *MessageCache) Get(key string) ([]models.ChatMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*messageCacheEntry)
+	return append([]models.ChatMessage(nil), entry.messages...), true
+}
+
+// Set 替换账号的缓存消息
+func (c *MessageCache) Set(key string, messages []models.ChatMessage) {
+	if len(messages) > maxCachedMessagesPerKey {
+		messages = messages[len(messages)-maxCachedMessagesPerKey:]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(key)
+	c.total += len(messages) - len(entry.messages)
+	entry.messages = append([]models.ChatMessage(nil), messages...)
+	c.evict(key)
+}
+
+// Append 追加一条消息，返回该账号当前缓存的消息数
+func (c *MessageCache) Append(key string, msg models.ChatMessage) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(key)
+	entry.messages = append(entry.messages, msg)
+	c.total++
+	if over := len(entry.messages) - maxCachedMessagesPerKey; over > 0 {
+		entry.messages = entry.messages[over:]
+		c.total -= over
+	}
+	c.evict(key)
+
+	if elem, ok := c.entries[key]; ok {
+		return len(elem.Value.(*messageCacheEntry).messages)
+	}
+	return 0
+}
+
+// Delete 删除指定账号的缓存，场景结束时释放
+func (c *MessageCache) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+	}
+	metrics.AgentMessageCacheSize.Set(float64(c.total))
+}
+
+// Size 返回缓存的消息总数
+func (c *MessageCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// entry 获取或创建账号缓存，并标记为最近访问（调用方需持有锁）
+func (c *MessageCache) entry(key string) *messageCacheEntry {
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*messageCacheEntry)
+	}
+	entry := &messageCacheEntry{key: key}
+	c.entries[key] = c.lru.PushFront(entry)
+	return entry
+}
+
+// remove 移除账号缓存（调用方需持有锁）
+func (c *MessageCache) remove(elem *list.Element) {
+	entry := elem.Value.(*messageCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.total -= len(entry.messages)
+}
+
+// evict 超出总容量时淘汰最久未访问的账号缓存，current 为正在写入的账号，
+// 只剩它自己时改为丢弃其最早的消息（调用方需持有锁）
+func (c *MessageCache) evict(current string) {
+	for c.total > c.maxMessages {
+		elem := c.lru.Back()
+		entry := elem.Value.(*messageCacheEntry)
+
+		if entry.key == current {
+			over := c.total - c.maxMessages
+			entry.messages = entry.messages[over:]
+			c.total -= over
+			metrics.AgentMessageCacheEvictions.Add(float64(over))
+			c.logger.Debug("Trimmed message cache entry",
+				zap.String("key", entry.key),
+				zap.Int("evicted", over),
+				zap.Int("remaining", len(entry.messages)))
+			break
+		}
+
+		evicted := len(entry.messages)
+		c.remove(elem)
+		metrics.AgentMessageCacheEvictions.Add(float64(evicted))
+		c.logger.Debug("Evicted least recently used message cache entry",
+			zap.String("key", entry.key),
+			zap.Int("evicted", evicted),
+			zap.Int("total", c.total),
+			zap.Int("max_messages", c.maxMessages))
+	}
+	metrics.AgentMessageCacheSize.Set(float64(c.total))
+}
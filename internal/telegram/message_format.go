@@ -0,0 +1,197 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/tg"
+)
+
+// 消息格式解析模式
+const (
+	ParseModeNone     = "none"     // 纯文本发送
+	ParseModeMarkdown = "markdown" // **粗体** *斜体* __下划线__ ~~删除线~~ ||剧透|| `代码` ```代码块``` [文本](链接)
+	ParseModeHTML     = "html"     // Telegram 支持的 HTML 子集
+)
+
+// ValidateParseMode 校验解析模式是否受支持，空值视为 none
+func ValidateParseMode(mode string) error {
+	switch mode {
+	case "", ParseModeNone, ParseModeMarkdown, ParseModeHTML:
+		return nil
+	}
+	return fmt.Errorf("invalid parse_mode: %s", mode)
+}
+
+// FormatMessage 按解析模式将消息转换为纯文本和实体列表
+// 格式错误（如未闭合的标记、非法 HTML）返回 error，避免把标记符号原样发出
+func FormatMessage(text, mode string) (string, []tg.MessageEntityClass, error) {
+	switch mode {
+	case "", ParseModeNone:
+		return text, nil, nil
+	case ParseModeHTML:
+		var b entity.Builder
+		if err := html.HTML(strings.NewReader(text), &b, html.Options{}); err != nil {
+			return "", nil, fmt.Errorf("malformed html: %w", err)
+		}
+		msg, entities := b.Complete()
+		return msg, entities, nil
+	case ParseModeMarkdown:
+		msg, entities, err := parseMarkdown(text)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed markdown: %w", err)
+		}
+		return msg, entities, nil
+	}
+	return "", nil, fmt.Errorf("invalid parse_mode: %s", mode)
+}
+
+// markdownMarkers 成对出现的样式标记，长标记需排在前面以优先匹配
+var markdownMarkers = []string{"**", "__", "~~", "||", "*"}
+
+// openMarker 尚未闭合的样式标记
+type openMarker struct {
+	marker string
+	offset int // UTF-16 偏移
+	pos    int // 原文中的字节位置，用于报错
+}
+
+// markdownWriter 累积输出文本并以 UTF-16 计算实体偏移
+type markdownWriter struct {
+	out    strings.Builder
+	offset int
+}
+
+func (w *markdownWriter) write(s string) {
+	w.out.WriteString(s)
+	w.offset += len(utf16.Encode([]rune(s)))
+}
+
+// parseMarkdown 解析简化的 Markdown 语法，支持嵌套样式和反斜杠转义
+func parseMarkdown(text string) (string, []tg.MessageEntityClass, error) {
+	var (
+		w        markdownWriter
+		stack    []openMarker
+		entities []tg.MessageEntityClass
+	)
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		// 反斜杠转义下一个字符
+		if rest[0] == '\\' && len(rest) > 1 {
+			r := []rune(rest[1:])[0]
+			w.write(string(r))
+			i += 1 + len(string(r))
+			continue
+		}
+
+		// 代码块与行内代码，内部不再解析样式
+		if strings.HasPrefix(rest, "```") {
+			end := strings.Index(rest[3:], "```")
+			if end < 0 {
+				return "", nil, fmt.Errorf("unclosed ``` at position %d", i)
+			}
+			content := rest[3 : 3+end]
+			language := ""
+			if nl := strings.IndexByte(content, '\n'); nl >= 0 && !strings.ContainsAny(content[:nl], " \t") {
+				language, content = content[:nl], content[nl+1:]
+			}
+			start := w.offset
+			w.write(content)
+			entities = append(entities, &tg.MessageEntityPre{Offset: start, Length: w.offset - start, Language: language})
+			i += 3 + end + 3
+			continue
+		}
+		if rest[0] == '`' {
+			end := strings.IndexByte(rest[1:], '`')
+			if end < 0 {
+				return "", nil, fmt.Errorf("unclosed ` at position %d", i)
+			}
+			start := w.offset
+			w.write(rest[1 : 1+end])
+			entities = append(entities, &tg.MessageEntityCode{Offset: start, Length: w.offset - start})
+			i += 1 + end + 1
+			continue
+		}
+
+		// [文本](链接)，不匹配时按普通字符处理
+		if rest[0] == '[' {
+			if closeText := strings.Index(rest, "]("); closeText > 0 {
+				if closeURL := strings.IndexByte(rest[closeText+2:], ')'); closeURL > 0 {
+					label := rest[1:closeText]
+					url := rest[closeText+2 : closeText+2+closeURL]
+					start := w.offset
+					w.write(label)
+					entities = append(entities, &tg.MessageEntityTextURL{Offset: start, Length: w.offset - start, URL: url})
+					i += closeText + 2 + closeURL + 1
+					continue
+				}
+			}
+		}
+
+		matched := ""
+		for _, marker := range markdownMarkers {
+			if strings.HasPrefix(rest, marker) {
+				matched = marker
+				break
+			}
+		}
+		if matched == "" {
+			r := []rune(rest)[0]
+			w.write(string(r))
+			i += len(string(r))
+			continue
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].marker == matched {
+			open := stack[n-1]
+			stack = stack[:n-1]
+			if length := w.offset - open.offset; length > 0 {
+				entities = append(entities, markdownEntity(matched, open.offset, length))
+			}
+		} else {
+			for _, open := range stack {
+				if open.marker == matched {
+					return "", nil, fmt.Errorf("improperly nested %s at position %d", matched, i)
+				}
+			}
+			stack = append(stack, openMarker{marker: matched, offset: w.offset, pos: i})
+		}
+		i += len(matched)
+	}
+
+	if len(stack) > 0 {
+		open := stack[len(stack)-1]
+		return "", nil, fmt.Errorf("unclosed %s at position %d", open.marker, open.pos)
+	}
+
+	sort.SliceStable(entities, func(i, j int) bool {
+		a, b := entities[i], entities[j]
+		if a.GetOffset() != b.GetOffset() {
+			return a.GetOffset() < b.GetOffset()
+		}
+		return a.GetLength() > b.GetLength()
+	})
+	return w.out.String(), entities, nil
+}
+
+// markdownEntity 根据标记创建对应实体
+func markdownEntity(marker string, offset, length int) tg.MessageEntityClass {
+	switch marker {
+	case "**":
+		return &tg.MessageEntityBold{Offset: offset, Length: length}
+	case "__":
+		return &tg.MessageEntityUnderline{Offset: offset, Length: length}
+	case "~~":
+		return &tg.MessageEntityStrike{Offset: offset, Length: length}
+	case "||":
+		return &tg.MessageEntitySpoiler{Offset: offset, Length: length}
+	default:
+		return &tg.MessageEntityItalic{Offset: offset, Length: length}
+	}
+}
@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"fmt"
+	"math/rand"
+
+	"tg_cloud_server/internal/models"
+)
+
+// MessagePool 预先审核的消息池，每个目标从中随机选取一条
+// 只在分配次数最少的消息中随机，使各消息的使用次数保持均匀
+type MessagePool struct {
+	messages []string
+	counts   []int
+}
+
+// MessagePoolFromConfig 从任务配置读取 message_pool，未配置时返回 nil
+// 每条消息都会按 parse_mode 预先校验格式
+func MessagePoolFromConfig(config map[string]interface{}) (*MessagePool, error) {
+	raw, exists := config["message_pool"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("message_pool must be a non-empty list")
+	}
+
+	parseMode, _ := config["parse_mode"].(string)
+	messages := make([]string, 0, len(list))
+	for i, item := range list {
+		message, _ := item.(string)
+		if message == "" {
+			return nil, fmt.Errorf("message_pool[%d]: message is empty", i)
+		}
+		if _, _, err := FormatMessage(message, parseMode); err != nil {
+			return nil, fmt.Errorf("message_pool[%d]: %w", i, err)
+		}
+		messages = append(messages, message)
+	}
+	return &MessagePool{messages: messages, counts: make([]int, len(messages))}, nil
+}
+
+// Size 消息池中的消息数
+func (p *MessagePool) Size() int {
+	return len(p.messages)
+}
+
+// LoadCounts 从任务结果恢复各消息的已分配次数，多账号执行同一任务时分布仍保持均匀
+func (p *MessagePool) LoadCounts(result models.TaskResult) {
+	switch saved := result["message_pool_counts"].(type) {
+	case []int:
+		copy(p.counts, saved)
+	case []interface{}:
+		for i, v := range saved {
+			if n, ok := v.(float64); ok && i < len(p.counts) {
+				p.counts[i] = int(n)
+			}
+		}
+	}
+}
+
+// SaveCounts 将各消息的已分配次数写入任务结果
+func (p *MessagePool) SaveCounts(result models.TaskResult) {
+	result["message_pool_counts"] = append([]int(nil), p.counts...)
+}
+
+// Pick 在分配次数最少的消息中随机选取一条，返回消息下标和内容
+func (p *MessagePool) Pick(rnd *rand.Rand) (int, string) {
+	least := p.counts[0]
+	for _, n := range p.counts[1:] {
+		if n < least {
+			least = n
+		}
+	}
+	var candidates []int
+	for i, n := range p.counts {
+		if n == least {
+			candidates = append(candidates, i)
+		}
+	}
+	index := candidates[rnd.Intn(len(candidates))]
+	p.counts[index]++
+	return index, p.messages[index]
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// MessageStep 消息序列中的一步，如先打招呼、等待后再发送正文
+type MessageStep struct {
+	Text      string
+	Entities  []tg.MessageEntityClass
+	Delay     time.Duration // 发送本步前距上一步的等待时间，第一步忽略
+	Template  string        // 格式化前的原始消息，按目标替换变量后重新格式化
+	ParseMode string
+}
+
+// Render 替换消息中的 {{变量名}} 后重新解析格式，没有变量时返回原步骤
+func (s MessageStep) Render(vars map[string]string) (MessageStep, error) {
+	if len(vars) == 0 || s.Template == "" {
+		return s, nil
+	}
+	text, entities, err := FormatMessage(models.ReplaceTemplateVariables(s.Template, vars), s.ParseMode)
+	if err != nil {
+		return s, err
+	}
+	s.Text, s.Entities = text, entities
+	return s, nil
+}
+
+// MessageSequenceFromConfig 读取 sequence 配置: [{"message": "...", "delay_seconds": 30, "parse_mode": "markdown"}]
+// 步骤未指定 parse_mode 时使用任务级 parse_mode；未配置 sequence 时返回 nil
+func MessageSequenceFromConfig(config map[string]interface{}) ([]MessageStep, error) {
+	raw, exists := config["sequence"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("sequence must be a non-empty list")
+	}
+
+	defaultParseMode, _ := config["parse_mode"].(string)
+	steps := make([]MessageStep, 0, len(list))
+	for i, item := range list {
+		stepConfig, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("sequence step %d: invalid format", i+1)
+		}
+
+		message, _ := stepConfig["message"].(string)
+		if message == "" {
+			return nil, fmt.Errorf("sequence step %d: message is empty", i+1)
+		}
+		parseMode := defaultParseMode
+		if mode, ok := stepConfig["parse_mode"].(string); ok && mode != "" {
+			parseMode = mode
+		}
+		text, entities, err := FormatMessage(message, parseMode)
+		if err != nil {
+			return nil, fmt.Errorf("sequence step %d: %w", i+1, err)
+		}
+
+		step := MessageStep{Text: text, Entities: entities, Template: message, ParseMode: parseMode}
+		if delay, ok := stepConfig["delay_seconds"].(float64); ok {
+			if delay < 0 {
+				return nil, fmt.Errorf("sequence step %d: delay_seconds must not be negative", i+1)
+			}
+			step.Delay = time.Duration(delay * float64(time.Second))
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
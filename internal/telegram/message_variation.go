@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 消息变体模式
+const (
+	VariationModeNone    = "none"    // 不做变体
+	VariationModeSpintax = "spintax" // {a|b|c} 语法随机展开
+	VariationModeAI      = "ai"      // AI 预生成变体，轮询使用
+)
+
+// defaultVariationCount AI 模式默认生成的变体数量
+const defaultVariationCount = 5
+
+// VariationGenerator 消息变体生成接口 (本地定义以避免循环引用)
+type VariationGenerator interface {
+	GenerateVariations(ctx context.Context, template string, count int, sampling models.AISampling) ([]string, error)
+}
+
+// ExpandSpintax 展开 spintax 语法，支持嵌套，如 "{你好|嗨}，{欢迎|{很高兴|开心}见到你}"
+// 未闭合的花括号按原样保留
+func ExpandSpintax(text string, rnd *rand.Rand) string {
+	for {
+		end := strings.Index(text, "}")
+		if end < 0 {
+			return text
+		}
+		start := strings.LastIndex(text[:end], "{")
+		if start < 0 {
+			// 孤立的右括号，保留并继续处理后续部分
+			return text[:end+1] + ExpandSpintax(text[end+1:], rnd)
+		}
+
+		options := strings.Split(text[start+1:end], "|")
+		choice := options[rnd.Intn(len(options))]
+		text = text[:start] + choice + text[end+1:]
+	}
+}
@@ -0,0 +1,473 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/logger"
+	"tg_cloud_server/internal/models"
+	"tg_cloud_server/internal/repository"
+)
+
+// 解析结果缓存默认有效期
+const defaultPeerCacheTTL = 5 * time.Minute
+
+// 按数字ID查找时最多扫描的对话页数（每页100个）
+const (
+	peerDialogPageSize = 100
+	peerDialogMaxPages = 5
+)
+
+// ResolvePeer 解析目标为 InputPeer，支持:
+//
+//	@username、username、t.me/username、https://t.me/username?start=xxx
+//	t.me/+hash、t.me/joinchat/hash（账号需已在群内）
+//	数字ID：-100 开头为频道/超级群，- 开头为普通群，其余按用户/群组ID在对话列表中查找
+//
+// 频道、超级群和用户需要 access hash，数字ID只能解析账号对话列表中已有的目标
+func ResolvePeer(ctx context.Context, api *tg.Client, target string) (tg.InputPeerClass, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("empty target")
+	}
+
+	name := strings.TrimPrefix(target, "@")
+	for _, prefix := range []string{"https://", "http://", "t.me/", "telegram.me/"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+
+	if hash, ok := inviteHash(name); ok {
+		return resolveInvitePeer(ctx, api, hash)
+	}
+	if isNumeric(name) {
+		id, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer id %s: %w", name, err)
+		}
+		return resolveNumericPeer(ctx, api, id)
+	}
+
+	username, _ := parseDeepLink(target)
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: username})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", username, err)
+	}
+
+	switch p := resolved.Peer.(type) {
+	case *tg.PeerUser:
+		for _, u := range resolved.Users {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				if user.Deleted {
+					return nil, errTargetDeactivated
+				}
+				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
+			}
+		}
+	case *tg.PeerChat:
+		return findChatPeer(resolved.Chats, p.ChatID)
+	case *tg.PeerChannel:
+		return findChatPeer(resolved.Chats, p.ChannelID)
+	}
+	return nil, fmt.Errorf("peer not found: %s", username)
+}
+
+// resolveInvitePeer 通过邀请链接获取已加入群组的 Peer
+func resolveInvitePeer(ctx context.Context, api *tg.Client, hash string) (tg.InputPeerClass, error) {
+	invite, err := api.MessagesCheckChatInvite(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invite link: %w", err)
+	}
+	switch v := invite.(type) {
+	case *tg.ChatInviteAlready:
+		return inputPeerFromChat(v.Chat)
+	case *tg.ChatInvitePeek:
+		return inputPeerFromChat(v.Chat)
+	default:
+		return nil, fmt.Errorf("account has not joined the invite link chat, join it first")
+	}
+}
+
+// resolveNumericPeer 在对话列表中查找数字ID对应的 Peer，以获取频道和用户的 access hash
+// 普通群不需要 access hash，未在对话列表中找到时直接按普通群处理
+func resolveNumericPeer(ctx context.Context, api *tg.Client, id int64) (tg.InputPeerClass, error) {
+	channelID, chatID, userID := int64(0), int64(0), int64(0)
+	switch s := strconv.FormatInt(id, 10); {
+	case strings.HasPrefix(s, "-100"):
+		channelID, _ = strconv.ParseInt(s[len("-100"):], 10, 64)
+	case id < 0:
+		chatID = -id
+	default:
+		// 未带前缀的ID可能是用户、普通群或频道
+		channelID, chatID, userID = id, id, id
+	}
+	// 普通群不需要 access hash
+	if channelID == 0 && userID == 0 {
+		return &tg.InputPeerChat{ChatID: chatID}, nil
+	}
+
+	req := &tg.MessagesGetDialogsRequest{OffsetPeer: &tg.InputPeerEmpty{}, Limit: peerDialogPageSize}
+	for page := 0; page < peerDialogMaxPages; page++ {
+		result, err := api.MessagesGetDialogs(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dialogs: %w", err)
+		}
+		dialogs, ok := result.AsModified()
+		if !ok {
+			break
+		}
+
+		for _, c := range dialogs.GetChats() {
+			switch chat := c.(type) {
+			case *tg.Channel:
+				if channelID != 0 && chat.ID == channelID {
+					return &tg.InputPeerChannel{ChannelID: chat.ID, AccessHash: chat.AccessHash}, nil
+				}
+			case *tg.Chat:
+				if chatID != 0 && chat.ID == chatID {
+					return &tg.InputPeerChat{ChatID: chat.ID}, nil
+				}
+			}
+		}
+		for _, u := range dialogs.GetUsers() {
+			if user, ok := u.(*tg.User); ok && userID != 0 && user.ID == userID {
+				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
+			}
+		}
+
+		if _, complete := result.(*tg.MessagesDialogs); complete || len(dialogs.GetDialogs()) < peerDialogPageSize {
+			break
+		}
+		if !nextDialogPage(req, dialogs) {
+			break
+		}
+	}
+
+	if chatID != 0 {
+		return &tg.InputPeerChat{ChatID: chatID}, nil
+	}
+	return nil, fmt.Errorf("peer %d not found in dialogs, resolve it by username first", id)
+}
+
+// nextDialogPage 以当前页最后一个对话设置下一页的偏移
+func nextDialogPage(req *tg.MessagesGetDialogsRequest, dialogs tg.ModifiedMessagesDialogs) bool {
+	list := dialogs.GetDialogs()
+	if len(list) == 0 {
+		return false
+	}
+	last, ok := list[len(list)-1].(*tg.Dialog)
+	if !ok {
+		return false
+	}
+
+	var offsetPeer tg.InputPeerClass
+	switch p := last.Peer.(type) {
+	case *tg.PeerUser:
+		for _, u := range dialogs.GetUsers() {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				offsetPeer = &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}
+			}
+		}
+	case *tg.PeerChat:
+		offsetPeer = &tg.InputPeerChat{ChatID: p.ChatID}
+	case *tg.PeerChannel:
+		for _, c := range dialogs.GetChats() {
+			if channel, ok := c.(*tg.Channel); ok && channel.ID == p.ChannelID {
+				offsetPeer = &tg.InputPeerChannel{ChannelID: channel.ID, AccessHash: channel.AccessHash}
+			}
+		}
+	}
+	if offsetPeer == nil {
+		return false
+	}
+
+	offsetDate := 0
+	for _, m := range dialogs.GetMessages() {
+		if msg, ok := m.(*tg.Message); ok && msg.ID == last.TopMessage {
+			offsetDate = msg.Date
+		}
+	}
+	req.OffsetPeer = offsetPeer
+	req.OffsetID = last.TopMessage
+	req.OffsetDate = offsetDate
+	return true
+}
+
+// findChatPeer 在群组列表中查找指定ID的 Peer
+func findChatPeer(chats []tg.ChatClass, id int64) (tg.InputPeerClass, error) {
+	for _, c := range chats {
+		if c.GetID() == id {
+			return inputPeerFromChat(c)
+		}
+	}
+	return nil, fmt.Errorf("chat %d not found", id)
+}
+
+// inputPeerFromChat 将群组转换为 InputPeer
+func inputPeerFromChat(chat tg.ChatClass) (tg.InputPeerClass, error) {
+	switch c := chat.(type) {
+	case *tg.Chat:
+		return &tg.InputPeerChat{ChatID: c.ID}, nil
+	case *tg.Channel:
+		return &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}, nil
+	default:
+		return nil, fmt.Errorf("unsupported chat type: %T", chat)
+	}
+}
+
+// IsPeerInvalidError 判断是否为 access hash 失效导致的错误，需要重新解析目标
+func IsPeerInvalidError(err error) bool {
+	return tgerr.Is(err, "PEER_ID_INVALID", "CHANNEL_INVALID", "USER_ID_INVALID")
+}
+
+// PeerResolver 按账号缓存 ResolvePeer 的结果，避免重复调用 ContactsResolveUsername 和扫描对话列表
+// access hash 与账号绑定，不同账号之间不能共用缓存；设置持久化存储后，数字ID在服务重启后仍可直接解析
+type PeerResolver struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]peerCacheEntry
+	store   repository.PeerAccessHashRepository // 可为 nil
+	logger  *zap.Logger
+}
+
+// peerCacheEntry 缓存的解析结果
+type peerCacheEntry struct {
+	peer      tg.InputPeerClass
+	expiresAt time.Time
+}
+
+// NewPeerResolver 创建带缓存的解析器，ttl 为解析结果的有效期
+func NewPeerResolver(ttl time.Duration) *PeerResolver {
+	if ttl <= 0 {
+		ttl = defaultPeerCacheTTL
+	}
+	return &PeerResolver{
+		ttl:     ttl,
+		entries: make(map[string]peerCacheEntry),
+		logger:  logger.Get().Named("peer_resolver"),
+	}
+}
+
+// SetStore 设置 access hash 的持久化存储，解析成功的用户/频道都会保存
+func (r *PeerResolver) SetStore(store repository.PeerAccessHashRepository) {
+	r.store = store
+}
+
+// Resolve 解析目标，优先使用该账号未过期的缓存；解析失败不缓存
+// 解析器为 nil 时不使用缓存
+func (r *PeerResolver) Resolve(ctx context.Context, api *tg.Client, accountID, target string) (tg.InputPeerClass, error) {
+	if r == nil {
+		return ResolvePeer(ctx, api, target)
+	}
+
+	target = strings.TrimSpace(target)
+	key := accountID + "|" + target
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.peer, nil
+	}
+
+	// 数字ID优先使用持久化的 access hash
+	if peer := r.loadStored(accountID, target); peer != nil {
+		r.remember(accountID, key, peer, false)
+		return peer, nil
+	}
+
+	peer, err := ResolvePeer(ctx, api, target)
+	if err != nil {
+		return nil, err
+	}
+	r.remember(accountID, key, peer, true)
+	return peer, nil
+}
+
+// remember 缓存解析结果，同时按数字ID缓存，后续按ID发送时无需再扫描对话列表
+func (r *PeerResolver) remember(accountID, key string, peer tg.InputPeerClass, persist bool) {
+	expiresAt := time.Now().Add(r.ttl)
+	r.mu.Lock()
+	r.entries[key] = peerCacheEntry{peer: peer, expiresAt: expiresAt}
+	if id := peerIDString(peer); id != "" {
+		r.entries[accountID+"|"+id] = peerCacheEntry{peer: peer, expiresAt: expiresAt}
+	}
+	r.mu.Unlock()
+
+	if !persist || r.store == nil {
+		return
+	}
+	entry := storedPeer(peer)
+	if entry == nil {
+		return
+	}
+	id, err := strconv.ParseUint(accountID, 10, 64)
+	if err != nil {
+		return
+	}
+	entry.AccountID = id
+	if err := r.store.Save(entry); err != nil {
+		r.logger.Warn("Failed to save peer access hash",
+			zap.String("account_id", accountID),
+			zap.Int64("peer_id", entry.PeerID),
+			zap.Error(err))
+	}
+}
+
+// loadStored 从持久化存储中查找数字ID对应的用户/频道，未找到时返回 nil
+// -100 开头的ID为频道，未带前缀的ID依次按用户和频道查找
+func (r *PeerResolver) loadStored(accountID, target string) tg.InputPeerClass {
+	if r.store == nil || !isNumeric(target) {
+		return nil
+	}
+	account, err := strconv.ParseUint(accountID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []models.PeerAccessHash
+	if strings.HasPrefix(target, "-100") {
+		id, err := strconv.ParseInt(target[len("-100"):], 10, 64)
+		if err != nil {
+			return nil
+		}
+		candidates = append(candidates, models.PeerAccessHash{PeerType: models.PeerTypeChannel, PeerID: id})
+	} else if !strings.HasPrefix(target, "-") {
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return nil
+		}
+		candidates = append(candidates,
+			models.PeerAccessHash{PeerType: models.PeerTypeUser, PeerID: id},
+			models.PeerAccessHash{PeerType: models.PeerTypeChannel, PeerID: id})
+	}
+
+	for _, c := range candidates {
+		entry, err := r.store.Get(account, c.PeerType, c.PeerID)
+		if err != nil {
+			r.logger.Warn("Failed to load peer access hash",
+				zap.String("account_id", accountID),
+				zap.Int64("peer_id", c.PeerID),
+				zap.Error(err))
+			return nil
+		}
+		if entry == nil {
+			continue
+		}
+		if entry.PeerType == models.PeerTypeUser {
+			return &tg.InputPeerUser{UserID: entry.PeerID, AccessHash: entry.AccessHash}
+		}
+		return &tg.InputPeerChannel{ChannelID: entry.PeerID, AccessHash: entry.AccessHash}
+	}
+	return nil
+}
+
+// Invalidate 移除账号对该目标缓存和持久化的 access hash，Telegram 返回 PEER_ID_INVALID 等错误时调用，
+// 下次解析会重新通过用户名或对话列表获取
+func (r *PeerResolver) Invalidate(accountID string, peer tg.InputPeerClass) {
+	if r == nil {
+		return
+	}
+	id := peerIDString(peer)
+	if id == "" {
+		return
+	}
+
+	prefix := accountID + "|"
+	r.mu.Lock()
+	for key, entry := range r.entries {
+		if strings.HasPrefix(key, prefix) && peerIDString(entry.peer) == id {
+			delete(r.entries, key)
+		}
+	}
+	r.mu.Unlock()
+
+	if r.store == nil {
+		return
+	}
+	entry := storedPeer(peer)
+	if entry == nil {
+		return
+	}
+	account, err := strconv.ParseUint(accountID, 10, 64)
+	if err != nil {
+		return
+	}
+	if err := r.store.Delete(account, entry.PeerType, entry.PeerID); err != nil {
+		r.logger.Warn("Failed to delete peer access hash",
+			zap.String("account_id", accountID),
+			zap.Int64("peer_id", entry.PeerID),
+			zap.Error(err))
+	}
+}
+
+// storedPeer 将需要 access hash 的 Peer 转换为持久化记录，普通群不需要保存
+func storedPeer(peer tg.InputPeerClass) *models.PeerAccessHash {
+	switch p := peer.(type) {
+	case *tg.InputPeerUser:
+		return &models.PeerAccessHash{PeerType: models.PeerTypeUser, PeerID: p.UserID, AccessHash: p.AccessHash}
+	case *tg.InputPeerChannel:
+		return &models.PeerAccessHash{PeerType: models.PeerTypeChannel, PeerID: p.ChannelID, AccessHash: p.AccessHash}
+	}
+	return nil
+}
+
+// Prune 清理过期的缓存
+func (r *PeerResolver) Prune() {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, entry := range r.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// peerIDString 返回 Peer 的数字ID表示（频道 -100xxx，普通群 -xxx，用户 xxx）
+func peerIDString(peer tg.InputPeerClass) string {
+	switch p := peer.(type) {
+	case *tg.InputPeerChannel:
+		return fmt.Sprintf("-100%d", p.ChannelID)
+	case *tg.InputPeerChat:
+		return fmt.Sprintf("-%d", p.ChatID)
+	case *tg.InputPeerUser:
+		return strconv.FormatInt(p.UserID, 10)
+	}
+	return ""
+}
+
+// peerResolution 执行器的 Peer 解析，注入连接池的解析器后按账号缓存结果
+type peerResolution struct {
+	peerResolver  *PeerResolver
+	peerAccountID string
+}
+
+// SetPeerResolver 设置带缓存的 Peer 解析器及当前执行账号
+func (p *peerResolution) SetPeerResolver(resolver *PeerResolver, accountID string) {
+	p.peerResolver = resolver
+	p.peerAccountID = accountID
+}
+
+// resolveTarget 解析目标，未设置解析器时不使用缓存
+func (p *peerResolution) resolveTarget(ctx context.Context, api *tg.Client, target string) (tg.InputPeerClass, error) {
+	return p.peerResolver.Resolve(ctx, api, p.peerAccountID, target)
+}
+
+// invalidatePeer 移除失效的 access hash，返回是否值得重新解析后重试
+func (p *peerResolution) invalidatePeer(peer tg.InputPeerClass, err error) bool {
+	if p.peerResolver == nil || !IsPeerInvalidError(err) {
+		return false
+	}
+	p.peerResolver.Invalidate(p.peerAccountID, peer)
+	return true
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// 加群后无法发言的原因
+const (
+	CannotPostAnnouncementOnly = "announcement_only" // 频道仅管理员可发布
+	CannotPostBanned           = "banned"            // 账号在群内被禁言
+	CannotPostMembersMuted     = "members_muted"     // 群组禁止普通成员发言
+	CannotPostNotMember        = "not_member"        // 账号不在群内
+)
+
+// StatusJoinButCannotPost 加群成功但无法发言时群组的结果状态
+const StatusJoinButCannotPost = "join_but_cannot_post"
+
+// checkCanPost 获取加入后的群组信息，根据管理员身份、个人禁言和群组默认权限判断账号能否发言
+// 返回空字符串表示可以发言；无法获取群组信息时返回错误，由调用方决定是否仍尝试发送
+func checkCanPost(ctx context.Context, api *tg.Client, peer tg.InputPeerClass) (string, error) {
+	switch p := peer.(type) {
+	case *tg.InputPeerChannel:
+		chats, err := api.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+			&tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get channel: %w", err)
+		}
+		for _, c := range chats.GetChats() {
+			if channel, ok := c.(*tg.Channel); ok && channel.ID == p.ChannelID {
+				return channelPostRestriction(channel), nil
+			}
+		}
+		return "", fmt.Errorf("channel %d not found", p.ChannelID)
+	case *tg.InputPeerChat:
+		chats, err := api.MessagesGetChats(ctx, []int64{p.ChatID})
+		if err != nil {
+			return "", fmt.Errorf("failed to get chat: %w", err)
+		}
+		for _, c := range chats.GetChats() {
+			if chat, ok := c.(*tg.Chat); ok && chat.ID == p.ChatID {
+				return chatPostRestriction(chat), nil
+			}
+		}
+		return "", fmt.Errorf("chat %d not found", p.ChatID)
+	}
+	return "", nil
+}
+
+// channelPostRestriction 判断频道/超级群中账号的发言限制
+func channelPostRestriction(channel *tg.Channel) string {
+	if channel.Left {
+		return CannotPostNotMember
+	}
+	if channel.Creator {
+		return ""
+	}
+	if admin, ok := channel.GetAdminRights(); ok {
+		// 频道中只有具备发布权限的管理员可以发言，超级群管理员不受默认权限限制
+		if !channel.Broadcast || admin.PostMessages {
+			return ""
+		}
+	}
+	if channel.Broadcast {
+		return CannotPostAnnouncementOnly
+	}
+	if banned, ok := channel.GetBannedRights(); ok && sendBanned(banned) {
+		return CannotPostBanned
+	}
+	if rights, ok := channel.GetDefaultBannedRights(); ok && sendBanned(rights) {
+		return CannotPostMembersMuted
+	}
+	return ""
+}
+
+// chatPostRestriction 判断普通群中账号的发言限制
+func chatPostRestriction(chat *tg.Chat) string {
+	if chat.Left || chat.Deactivated {
+		return CannotPostNotMember
+	}
+	if chat.Creator {
+		return ""
+	}
+	if _, ok := chat.GetAdminRights(); ok {
+		return ""
+	}
+	if rights, ok := chat.GetDefaultBannedRights(); ok && sendBanned(rights) {
+		return CannotPostMembersMuted
+	}
+	return ""
+}
+
+// sendBanned 权限中是否禁止发送文字消息，已过期的限制视为无效
+func sendBanned(rights tg.ChatBannedRights) bool {
+	if rights.UntilDate != 0 && time.Unix(int64(rights.UntilDate), 0).Before(time.Now()) {
+		return false
+	}
+	return rights.ViewMessages || rights.SendMessages || rights.SendPlain
+}
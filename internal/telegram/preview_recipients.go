@@ -0,0 +1,191 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// previewResolveInterval 解析目标之间的间隔，避免触发频率限制
+const previewResolveInterval = 300 * time.Millisecond
+
+// PreviewRecipientsTask 解析目标列表并检查成员关系，不发送任何消息
+type PreviewRecipientsTask struct {
+	targets []string
+	Results []models.RecipientPreview
+}
+
+// NewPreviewRecipientsTask 创建目标预览任务
+func NewPreviewRecipientsTask(targets []string) *PreviewRecipientsTask {
+	return &PreviewRecipientsTask{targets: targets}
+}
+
+// Execute 逐个解析目标
+func (t *PreviewRecipientsTask) Execute(ctx context.Context, api *tg.Client) error {
+	t.Results = make([]models.RecipientPreview, 0, len(t.targets))
+
+	for i, target := range t.targets {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(previewResolveInterval):
+			}
+		}
+		t.Results = append(t.Results, t.previewTarget(ctx, api, strings.TrimSpace(target)))
+	}
+	return nil
+}
+
+// previewTarget 解析单个目标
+func (t *PreviewRecipientsTask) previewTarget(ctx context.Context, api *tg.Client, target string) models.RecipientPreview {
+	result := models.RecipientPreview{Target: target, Status: models.RecipientStatusInvalid}
+	if target == "" {
+		result.Error = "empty target"
+		return result
+	}
+
+	name := target
+	name = strings.TrimPrefix(name, "@")
+	name = strings.TrimPrefix(name, "https://")
+	name = strings.TrimPrefix(name, "http://")
+	name = strings.TrimPrefix(name, "t.me/")
+
+	// 邀请链接：t.me/joinchat/<hash> 或 t.me/+<hash>
+	if hash, ok := inviteHash(name); ok {
+		invite, err := api.MessagesCheckChatInvite(ctx, hash)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		switch v := invite.(type) {
+		case *tg.ChatInviteAlready:
+			result.Status = models.RecipientStatusMember
+			result.PeerType, result.Title = describeChat(v.Chat)
+		case *tg.ChatInvitePeek:
+			result.Status = models.RecipientStatusNotMember
+			result.PeerType, result.Title = describeChat(v.Chat)
+		case *tg.ChatInvite:
+			result.Status = models.RecipientStatusNotMember
+			result.PeerType = "chat"
+			if v.Channel {
+				result.PeerType = "channel"
+			}
+			result.Title = v.Title
+		}
+		return result
+	}
+
+	// 数字ID只能通过对话列表解析，找到即说明账号在该群组/频道中或与该用户有对话
+	if isNumeric(name) {
+		peer, err := ResolvePeer(ctx, api, name)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		switch peer.(type) {
+		case *tg.InputPeerUser:
+			result.Status = models.RecipientStatusResolved
+			result.PeerType = "user"
+		case *tg.InputPeerChannel:
+			result.Status = models.RecipientStatusMember
+			result.PeerType = "channel"
+		default:
+			result.Status = models.RecipientStatusResolved
+			result.PeerType = "chat"
+		}
+		return result
+	}
+
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: name})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if len(resolved.Chats) > 0 {
+		result.PeerType, result.Title = describeChat(resolved.Chats[0])
+		if chatLeft(resolved.Chats[0]) {
+			result.Status = models.RecipientStatusNotMember
+		} else {
+			result.Status = models.RecipientStatusMember
+		}
+		return result
+	}
+
+	if len(resolved.Users) > 0 {
+		result.Status = models.RecipientStatusResolved
+		result.PeerType = "user"
+		if user, ok := resolved.Users[0].(*tg.User); ok {
+			result.Title = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		}
+		return result
+	}
+
+	result.Error = "peer not found"
+	return result
+}
+
+// GetType 获取任务类型
+func (t *PreviewRecipientsTask) GetType() string {
+	return "preview_recipients"
+}
+
+// inviteHash 从链接中提取邀请 hash
+func inviteHash(name string) (string, bool) {
+	if idx := strings.Index(name, "joinchat/"); idx >= 0 {
+		hash := name[idx+len("joinchat/"):]
+		return hash, hash != ""
+	}
+	if strings.HasPrefix(name, "+") && len(name) > 1 {
+		return name[1:], true
+	}
+	return "", false
+}
+
+// isNumeric 判断是否为数字ID
+func isNumeric(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// describeChat 返回群组类型和标题
+func describeChat(chat tg.ChatClass) (string, string) {
+	switch c := chat.(type) {
+	case *tg.Chat:
+		return "chat", c.Title
+	case *tg.Channel:
+		return "channel", c.Title
+	case *tg.ChatForbidden:
+		return "chat", c.Title
+	case *tg.ChannelForbidden:
+		return "channel", c.Title
+	default:
+		return fmt.Sprintf("%T", chat), ""
+	}
+}
+
+// chatLeft 判断账号是否不在群组中
+func chatLeft(chat tg.ChatClass) bool {
+	switch c := chat.(type) {
+	case *tg.Chat:
+		return c.Left
+	case *tg.Channel:
+		return c.Left
+	default:
+		return true
+	}
+}
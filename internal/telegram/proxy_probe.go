@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/dcs"
+)
+
+// ProbeAccountProxy 使用账号会话通过指定代理建立临时连接并获取自身信息，返回耗时
+// 会话只保存在内存中，不写回数据库，也不影响连接池中的连接和账号的代理绑定；proxyConfig 为 nil 时直连
+func (cp *ConnectionPool) ProbeAccountProxy(ctx context.Context, accountID uint64, proxyConfig *ProxyConfig) (time.Duration, error) {
+	account, err := cp.accountRepo.GetByID(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get account: %w", err)
+	}
+	if account.SessionData == "" {
+		return 0, fmt.Errorf("account has no session data")
+	}
+
+	// 数据库存储的是 base64 编码的 gotd JSON 格式 session
+	data, err := base64.StdEncoding.DecodeString(account.SessionData)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode session data: %w", err)
+	}
+	storage := new(session.StorageMemory)
+	if err := storage.StoreSession(ctx, data); err != nil {
+		return 0, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	options := telegram.Options{
+		SessionStorage: storage,
+		NoUpdates:      true,
+		Device:         cp.accountDevice(account).options(),
+	}
+	if proxyConfig != nil {
+		proxyDialer, err := createProxyDialer(proxyConfig)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create proxy dialer: %w", err)
+		}
+		adapter := &proxyDialerAdapter{dialer: proxyDialer}
+		options.Resolver = dcs.Plain(dcs.PlainOptions{
+			Dial: adapter.DialContext,
+		})
+	}
+
+	client := telegram.NewClient(cp.appID, cp.appHash, options)
+
+	start := time.Now()
+	err = client.Run(ctx, func(ctx context.Context) error {
+		_, err := client.Self(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
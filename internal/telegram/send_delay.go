@@ -0,0 +1,119 @@
+package telegram
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// 发送间隔分布模型
+const (
+	DelayDistributionUniform = "uniform" // 在 [间隔-抖动, 间隔+抖动] 内均匀分布，抖动为0时即固定间隔
+	DelayDistributionNormal  = "normal"  // 以间隔为均值的正态分布
+	DelayDistributionPoisson = "poisson" // 泊松过程，间隔服从以间隔为均值的指数分布
+)
+
+// poissonMaxFactor 泊松分布的最大间隔倍数，避免极端长尾导致任务长时间停滞
+const poissonMaxFactor = 5
+
+// SendDelay 发送间隔模型，群发/私信/智能体发言共用，保证各发送路径的拟人化逻辑一致
+type SendDelay struct {
+	Distribution string
+	Jitter       time.Duration // uniform 抖动范围
+	StdDev       time.Duration // normal 标准差，为0时取间隔的1/4
+}
+
+// SendDelayFromConfig 从任务配置读取 delay_distribution / delay_jitter_seconds / delay_stddev_seconds
+// interval_jitter_seconds 为 delay_jitter_seconds 的别名，两者都配置时以 delay_jitter_seconds 为准
+func SendDelayFromConfig(config map[string]interface{}) (SendDelay, error) {
+	d := SendDelay{Distribution: DelayDistributionUniform}
+	if val, ok := config["delay_distribution"].(string); ok && val != "" {
+		d.Distribution = val
+	}
+	if val, ok := config["delay_jitter_seconds"].(float64); ok {
+		d.Jitter = time.Duration(val * float64(time.Second))
+	} else if val, ok := config["interval_jitter_seconds"].(float64); ok {
+		d.Jitter = time.Duration(val * float64(time.Second))
+	}
+	if val, ok := config["delay_stddev_seconds"].(float64); ok {
+		d.StdDev = time.Duration(val * float64(time.Second))
+	}
+	return d, d.Validate()
+}
+
+// Validate 校验分布模型及参数
+func (d SendDelay) Validate() error {
+	switch d.Distribution {
+	case "", DelayDistributionUniform, DelayDistributionNormal, DelayDistributionPoisson:
+	default:
+		return fmt.Errorf("invalid delay_distribution: %s", d.Distribution)
+	}
+	if d.Jitter < 0 {
+		return fmt.Errorf("delay_jitter_seconds / interval_jitter_seconds must not be negative")
+	}
+	if d.StdDev < 0 {
+		return fmt.Errorf("delay_stddev_seconds must not be negative")
+	}
+	return nil
+}
+
+// Next 按分布模型生成一次以 interval 为基准的发送间隔，结果不小于0
+func (d SendDelay) Next(rnd *rand.Rand, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	var delay time.Duration
+	switch d.Distribution {
+	case DelayDistributionNormal:
+		stddev := d.StdDev
+		if stddev == 0 {
+			stddev = interval / 4
+		}
+		delay = interval + time.Duration(rnd.NormFloat64()*float64(stddev))
+	case DelayDistributionPoisson:
+		delay = time.Duration(rnd.ExpFloat64() * float64(interval))
+		if delay > interval*poissonMaxFactor {
+			delay = interval * poissonMaxFactor
+		}
+	default:
+		delay = interval
+		if d.Jitter > 0 {
+			delay += time.Duration((rnd.Float64()*2 - 1) * float64(d.Jitter))
+		}
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// String 返回分布模型描述，用于日志
+func (d SendDelay) String() string {
+	switch d.Distribution {
+	case DelayDistributionNormal:
+		if d.StdDev == 0 {
+			return "normal(stddev=interval/4)"
+		}
+		return fmt.Sprintf("normal(stddev=%s)", d.StdDev)
+	case DelayDistributionPoisson:
+		return "poisson"
+	default:
+		return fmt.Sprintf("uniform(jitter=%s)", d.Jitter)
+	}
+}
+
+// RandomOrderFromConfig 读取 random_order，开启后发送前打乱目标顺序，默认按配置顺序发送
+func RandomOrderFromConfig(config map[string]interface{}) bool {
+	val, _ := config["random_order"].(bool)
+	return val
+}
+
+// ShuffleTargets 返回打乱顺序后的目标副本，不修改原配置
+func ShuffleTargets(rnd *rand.Rand, targets []interface{}) []interface{} {
+	shuffled := make([]interface{}, len(targets))
+	copy(shuffled, targets)
+	rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	return shuffled
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 	"encoding/base64"
+	"sync"
 
 	"github.com/gotd/td/session"
 	"go.uber.org/zap"
@@ -16,6 +17,8 @@ type DatabaseSessionStorage struct {
 	accountID   uint64
 	accountRepo repository.AccountRepository
 	data        []byte
+	dirty       bool // 内存中的数据尚未成功写入数据库
+	mu          sync.Mutex
 	logger      *zap.Logger
 }
 
@@ -31,6 +34,9 @@ func NewDatabaseSessionStorage(accountID uint64, accountRepo repository.AccountR
 
 // LoadSession 加载Session数据
 func (s *DatabaseSessionStorage) LoadSession(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// 如果内存中有数据，直接返回（优先使用）
 	if s.data != nil {
 		s.logger.Debug("Loading session from memory",
@@ -76,8 +82,29 @@ func (s *DatabaseSessionStorage) LoadSession(ctx context.Context) ([]byte, error
 
 // StoreSession 存储Session数据
 func (s *DatabaseSessionStorage) StoreSession(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// 更新内存缓存
 	s.data = data
+	s.dirty = true
+	return s.persistLocked()
+}
+
+// Flush 将内存中未持久化的Session写入数据库
+func (s *DatabaseSessionStorage) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty || s.data == nil {
+		return nil
+	}
+	return s.persistLocked()
+}
+
+// persistLocked 写入数据库，调用方需持有锁
+func (s *DatabaseSessionStorage) persistLocked() error {
+	data := s.data
 
 	// gotd传入的data是JSON格式的session数据，将其编码为base64字符串存储
 	encodedData := base64.StdEncoding.EncodeToString(data)
@@ -88,6 +115,7 @@ func (s *DatabaseSessionStorage) StoreSession(ctx context.Context, data []byte)
 			zap.Error(err))
 		return err
 	}
+	s.dirty = false
 
 	s.logger.Debug("Gotd session encoded and saved to database",
 		zap.Uint64("account_id", s.accountID),
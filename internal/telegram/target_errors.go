@@ -0,0 +1,55 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+)
+
+// 发送目标失败原因，记录在每个目标的结果中，便于用户清理目标列表
+const (
+	TargetFailureNotOccupied = "username_not_occupied" // 用户名从未被注册
+	TargetFailureInvalid     = "username_invalid"      // 用户名格式非法
+	TargetFailureDeactivated = "user_deactivated"      // 目标账号已注销
+	TargetFailurePrivacy     = "privacy_restricted"    // 目标隐私设置或拉黑导致无法发送
+	TargetFailureOther       = "other"                 // 其他原因，可重试
+)
+
+// errTargetDeactivated 解析到的用户已注销
+var errTargetDeactivated = errors.New("target account is deleted (USER_DEACTIVATED)")
+
+// ClassifyTargetError 将发送目标的错误归类为具体原因
+func ClassifyTargetError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, errTargetDeactivated) {
+		return TargetFailureDeactivated
+	}
+
+	errStr := strings.ToUpper(err.Error())
+	switch {
+	case strings.Contains(errStr, "USERNAME_NOT_OCCUPIED"):
+		return TargetFailureNotOccupied
+	case strings.Contains(errStr, "USERNAME_INVALID"):
+		return TargetFailureInvalid
+	case strings.Contains(errStr, "USER_DEACTIVATED"),
+		strings.Contains(errStr, "INPUT_USER_DEACTIVATED"):
+		return TargetFailureDeactivated
+	case strings.Contains(errStr, "USER_PRIVACY_RESTRICTED"),
+		strings.Contains(errStr, "PRIVACY_PREMIUM_REQUIRED"),
+		strings.Contains(errStr, "USER_IS_BLOCKED"),
+		strings.Contains(errStr, "YOU_BLOCKED_USER"),
+		strings.Contains(errStr, "CHANNEL_PRIVATE"):
+		return TargetFailurePrivacy
+	}
+	return TargetFailureOther
+}
+
+// IsDeadTarget 判断目标是否已永久失效（不存在或已注销），重试没有意义
+func IsDeadTarget(reason string) bool {
+	switch reason {
+	case TargetFailureNotOccupied, TargetFailureInvalid, TargetFailureDeactivated:
+		return true
+	}
+	return false
+}
@@ -2,11 +2,17 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"go.uber.org/zap"
+
+	"tg_cloud_server/internal/common/logger"
 	"tg_cloud_server/internal/models"
 
 	gotd_telegram "github.com/gotd/td/telegram"
@@ -27,7 +33,8 @@ type AdvancedTaskInterface interface {
 
 // AccountCheckTask 账号检查任务
 type AccountCheckTask struct {
-	task *models.Task
+	task          *models.Task
+	autoAcceptTOS bool // 检查到待接受的服务条款时自动接受
 }
 
 // NewAccountCheckTask 创建账号检查任务
@@ -35,6 +42,11 @@ func NewAccountCheckTask(task *models.Task) *AccountCheckTask {
 	return &AccountCheckTask{task: task}
 }
 
+// SetAutoAcceptTOS 设置检查到待接受的服务条款时是否自动接受
+func (t *AccountCheckTask) SetAutoAcceptTOS(autoAccept bool) {
+	t.autoAcceptTOS = autoAccept
+}
+
 // Execute 执行账号检查
 func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 	// 初始化检查结果
@@ -52,6 +64,10 @@ func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 
 	addLog("开始执行账号检查任务...")
 
+	// 多个账号依次共用同一个 task.Result，清除上一个账号的服务条款结果，检查跳过时不会误用
+	delete(t.task.Result, "tos_pending")
+	delete(t.task.Result, "tos_accepted")
+
 	checkResults := make(map[string]interface{})
 	checkScore := 100.0
 	var issues []string
@@ -127,6 +143,35 @@ func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 		addLog("应用配置获取成功")
 	}
 
+	// 服务条款检查：有待接受的条款时部分操作会被拒绝
+	addLog("正在检查服务条款...")
+	tos, err := CheckTermsOfService(ctx, api, t.autoAcceptTOS)
+	switch {
+	case tos == nil:
+		checkResults["tos_check"] = "skipped"
+		addLog(fmt.Sprintf("服务条款检查失败 (跳过): %v", err))
+	case tos.Accepted:
+		checkResults["tos_check"] = "passed"
+		checkResults["tos_accepted"] = true
+		checkResults["tos_pending"] = false
+		addLog(fmt.Sprintf("已自动接受新的服务条款 (%s)", tos.TermsID))
+	case tos.Pending:
+		checkScore -= 10
+		issues = append(issues, "账号有待接受的服务条款")
+		suggestions = append(suggestions, "在官方客户端接受服务条款，或开启自动接受")
+		checkResults["tos_check"] = "pending"
+		checkResults["tos_pending"] = true
+		checkResults["tos_accepted"] = false
+		if err != nil {
+			checkResults["tos_error"] = err.Error()
+		}
+		addLog("账号有待接受的服务条款，需要手动接受")
+	default:
+		checkResults["tos_check"] = "passed"
+		checkResults["tos_pending"] = false
+		checkResults["tos_accepted"] = false
+	}
+
 	// 5. 2FA 检查 (可选)
 	if check2FA, ok := t.task.Config["check_2fa"].(bool); ok && check2FA {
 		addLog("正在检查 2FA 状态...")
@@ -169,7 +214,7 @@ func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 	// 6. SpamBot 检查 (可选)
 	if checkSpamBot, ok := t.task.Config["check_spam_bot"].(bool); ok && checkSpamBot {
 		addLog("正在执行 SpamBot 检查...")
-		messageText, err := t.checkSpamBot(ctx, api)
+		messageText, err := querySpamBot(ctx, api)
 		if err != nil {
 			checkScore -= 20
 			issues = append(issues, fmt.Sprintf("SpamBot检查失败: %v", err))
@@ -185,46 +230,11 @@ func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 			messageTextLower := strings.ToLower(messageText)
 
 			// 检查双向限制
-			bidirectionalKeywords := []string{
-				"restricted from",
-				"can't message people",
-				"cannot message people",
-				"can't send messages",
-				"cannot send messages",
-				"messaging strangers",
-				"marked as spam",
-			}
-
-			isBidirectional := false
-			for _, keyword := range bidirectionalKeywords {
-				if strings.Contains(messageTextLower, keyword) {
-					isBidirectional = true
-					break
-				}
-			}
+			isBidirectional := spamBotIsBidirectional(messageTextLower)
 			checkResults["is_bidirectional"] = isBidirectional
 
 			// 检查冻结状态
-			frozenKeywords := []string{
-				"account was blocked",
-				"account has been blocked",
-				"blocked for violations",
-				"permanently blocked",
-				"blocked.{1,20}cannot be restored", // Go的strings.Contains不支持正则，这里简化处理，稍后用正则
-				"account is limited",
-				"permanently limited",
-				"violated the terms of service",
-			}
-
-			// 使用正则进行更精确的匹配
-			isFrozen := false
-			for _, keyword := range frozenKeywords {
-				matched, _ := regexp.MatchString(keyword, messageTextLower)
-				if matched {
-					isFrozen = true
-					break
-				}
-			}
+			isFrozen := spamBotIsFrozen(messageTextLower)
 			checkResults["is_frozen"] = isFrozen
 
 			if isFrozen {
@@ -249,7 +259,7 @@ func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 				suggestions = append(suggestions, "建议将账号状态设置为: 双向 (Two-way)")
 				checkResults["suggested_status"] = "two_way"
 				addLog("检测结果: 账号处于双向限制状态")
-			} else if strings.Contains(messageTextLower, "good news, no limits are currently applied") {
+			} else if spamBotReportsNoLimits(messageTextLower) {
 				// 账号正常
 				addLog("检测结果: 账号状态正常")
 			} else {
@@ -317,12 +327,18 @@ func (t *AccountCheckTask) Execute(ctx context.Context, api *tg.Client) error {
 	if val, ok := checkResults["spam_bot_error"]; ok {
 		t.task.Result["spam_bot_error"] = val
 	}
+	if val, ok := checkResults["tos_pending"]; ok {
+		t.task.Result["tos_pending"] = val
+	}
+	if val, ok := checkResults["tos_accepted"]; ok {
+		t.task.Result["tos_accepted"] = val
+	}
 
 	return nil
 }
 
-// checkSpamBot 检查 SpamBot 状态
-func (t *AccountCheckTask) checkSpamBot(ctx context.Context, api *tg.Client) (string, error) {
+// querySpamBot 向 SpamBot 发送 /start 并返回其回复，账号检查和群发金丝雀检查共用
+func querySpamBot(ctx context.Context, api *tg.Client) (string, error) {
 	// 解析 SpamBot
 	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
 		Username: "SpamBot",
@@ -391,7 +407,7 @@ func (t *AccountCheckTask) checkSpamBot(ctx context.Context, api *tg.Client) (st
 
 			if messages, ok := history.(*tg.MessagesMessages); ok {
 				if len(messages.Messages) > 0 {
-					if msg, ok := messages.Messages[0].(*tg.Message); ok {
+					if msg, ok := messages.Messages[0].(*tg.Message); ok && !msg.Out {
 						// 检查是否是最近的消息 (例如最近1分钟内)
 						if time.Since(time.Unix(int64(msg.Date), 0)) < 1*time.Minute {
 							return msg.Message, nil
@@ -400,7 +416,7 @@ func (t *AccountCheckTask) checkSpamBot(ctx context.Context, api *tg.Client) (st
 				}
 			} else if messagesSlice, ok := history.(*tg.MessagesMessagesSlice); ok {
 				if len(messagesSlice.Messages) > 0 {
-					if msg, ok := messagesSlice.Messages[0].(*tg.Message); ok {
+					if msg, ok := messagesSlice.Messages[0].(*tg.Message); ok && !msg.Out {
 						if time.Since(time.Unix(int64(msg.Date), 0)) < 1*time.Minute {
 							return msg.Message, nil
 						}
@@ -411,6 +427,54 @@ func (t *AccountCheckTask) checkSpamBot(ctx context.Context, api *tg.Client) (st
 	}
 }
 
+// SpamBot 回复中表示双向限制的关键词
+var spamBotBidirectionalKeywords = []string{
+	"restricted from",
+	"can't message people",
+	"cannot message people",
+	"can't send messages",
+	"cannot send messages",
+	"messaging strangers",
+	"marked as spam",
+}
+
+// SpamBot 回复中表示冻结的正则
+var spamBotFrozenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`account was blocked`),
+	regexp.MustCompile(`account has been blocked`),
+	regexp.MustCompile(`blocked for violations`),
+	regexp.MustCompile(`permanently blocked`),
+	regexp.MustCompile(`blocked.{1,20}cannot be restored`),
+	regexp.MustCompile(`account is limited`),
+	regexp.MustCompile(`permanently limited`),
+	regexp.MustCompile(`violated the terms of service`),
+}
+
+// spamBotIsBidirectional 判断 SpamBot 回复（小写）是否表示双向限制
+func spamBotIsBidirectional(lower string) bool {
+	for _, keyword := range spamBotBidirectionalKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// spamBotIsFrozen 判断 SpamBot 回复（小写）是否表示账号冻结
+func spamBotIsFrozen(lower string) bool {
+	for _, re := range spamBotFrozenPatterns {
+		if re.MatchString(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// spamBotReportsNoLimits 判断 SpamBot 回复（小写）是否表示账号无任何限制
+func spamBotReportsNoLimits(lower string) bool {
+	return strings.Contains(lower, "good news, no limits are currently applied")
+}
+
 // GetType 获取任务类型
 func (t *AccountCheckTask) GetType() string {
 	return "account_check"
@@ -418,7 +482,9 @@ func (t *AccountCheckTask) GetType() string {
 
 // PrivateMessageTask 私信任务
 type PrivateMessageTask struct {
-	task *models.Task
+	peerResolution
+	task         *models.Task
+	scheduleDate int // Telegram 定时发送时间（unix 秒），0 表示立即发送
 }
 
 // NewPrivateMessageTask 创建私信任务
@@ -453,10 +519,31 @@ func (t *PrivateMessageTask) Execute(ctx context.Context, api *tg.Client) error
 		return fmt.Errorf("invalid or empty targets configuration")
 	}
 
-	// 获取消息内容
-	message, ok := config["message"].(string)
-	if !ok || message == "" {
-		return fmt.Errorf("invalid or empty message configuration")
+	// 获取消息内容：配置了 sequence 时按序列逐步发送，配置了 message_pool 时每个目标从池中选取，否则发送单条 message
+	steps, err := MessageSequenceFromConfig(config)
+	if err != nil {
+		return err
+	}
+	parseMode, _ := config["parse_mode"].(string)
+	var pool *MessagePool
+	if steps == nil {
+		pool, err = MessagePoolFromConfig(config)
+		if err != nil {
+			return err
+		}
+	}
+	if steps == nil && pool == nil {
+		message, ok := config["message"].(string)
+		if !ok || message == "" {
+			return fmt.Errorf("invalid or empty message configuration")
+		}
+
+		// 解析消息格式，格式错误直接失败，避免把标记符号原样发出
+		text, entities, err := FormatMessage(message, parseMode)
+		if err != nil {
+			return err
+		}
+		steps = []MessageStep{{Text: text, Entities: entities, Template: message, ParseMode: parseMode}}
 	}
 
 	// 获取发送间隔 (防止频繁发送被限制)
@@ -467,23 +554,124 @@ func (t *PrivateMessageTask) Execute(ctx context.Context, api *tg.Client) error
 		}
 	}
 
-	addLog(fmt.Sprintf("开始执行私信任务，目标用户数: %d，间隔: %d秒", len(targets), intervalSec))
+	sendDelay, err := SendDelayFromConfig(config)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if RandomOrderFromConfig(config) {
+		targets = ShuffleTargets(rnd, targets)
+	}
+
+	t.scheduleDate, err = TelegramScheduleDateFromConfig(config)
+	if err != nil {
+		return err
+	}
+
+	if pool != nil {
+		pool.LoadCounts(t.task.Result)
+		addLog(fmt.Sprintf("开始执行私信任务，目标用户数: %d，消息池: %d 条，间隔: %d秒，间隔分布: %s", len(targets), pool.Size(), intervalSec, sendDelay))
+	} else {
+		addLog(fmt.Sprintf("开始执行私信任务，目标用户数: %d，消息步骤数: %d，间隔: %d秒，间隔分布: %s", len(targets), len(steps), intervalSec, sendDelay))
+	}
+	if t.scheduleDate > 0 {
+		addLog(fmt.Sprintf("使用 Telegram 定时消息，发送时间: %s", time.Unix(int64(t.scheduleDate), 0).Format("2006-01-02 15:04:05")))
+	}
+
+	// 试运行：只解析目标，不发送消息
+	if DryRunFromConfig(config) {
+		addLog("试运行模式：只解析目标，不发送消息")
+		usernames := make([]interface{}, 0, len(targets))
+		for _, target := range targets {
+			if parsed, err := models.MessageTargetFromConfig(target); err == nil {
+				usernames = append(usernames, parsed.Username)
+			} else {
+				usernames = append(usernames, target)
+			}
+		}
+		recordDryRun(t.task.Result, dryRunTargets(ctx, api, &t.peerResolution, usernames, true, addLog))
+		return nil
+	}
 
 	sentCount := 0
 	failedCount := 0
 	var errors []string
 	var sentTargets []string
+	var deadTargets []string                      // 不存在或已注销的目标，建议从列表中移除
+	failureReasons := make(map[string]int)        // 失败原因统计
 	targetResults := make(map[string]interface{}) // 记录每个目标的详细结果
 
+	// finish 记录已结束序列的目标结果
+	finish := func(seq *messageSequence) {
+		username := seq.username
+		result := map[string]interface{}{
+			"duration": time.Since(seq.startedAt).String(),
+			"message":  renderedMessage(seq.steps),
+		}
+		if len(seq.steps) > 1 {
+			result["steps"] = seq.results
+		}
+		if seq.poolIndex >= 0 {
+			result["pool_index"] = seq.poolIndex
+		}
+
+		if seq.err != nil {
+			reason := ClassifyTargetError(seq.err)
+			errors = append(errors, fmt.Sprintf("failed to send to %s: %v", username, seq.err))
+			result["status"] = "failed"
+			result["reason"] = reason
+			result["error"] = seq.err.Error()
+			targetResults[username] = result
+			failureReasons[reason]++
+			if IsDeadTarget(reason) {
+				deadTargets = append(deadTargets, username)
+			}
+			failedCount++
+			addLog(fmt.Sprintf("发送失败 [%s] (%s): %v", username, reason, seq.err))
+			return
+		}
+
+		sentCount++
+		sentTargets = append(sentTargets, username)
+		result["status"] = "success"
+		if id, ok := seq.results[0]["scheduled_message_id"]; ok {
+			result["scheduled_message_id"] = id
+		}
+		targetResults[username] = result
+		addLog(fmt.Sprintf("发送成功: %s", username))
+	}
+
+	// 多步序列的后续步骤按各自的发送时间排队，不阻塞其他目标
+	var pending []*messageSequence
+	sendDue := func() {
+		remaining := pending[:0]
+		for _, seq := range pending {
+			if !time.Now().Before(seq.dueAt) {
+				t.advanceSequence(ctx, api, seq, sendDelay, rnd, addLog)
+			}
+			if seq.done() {
+				finish(seq)
+			} else {
+				remaining = append(remaining, seq)
+			}
+		}
+		pending = remaining
+	}
+
 	// 发送私信给每个目标用户
 	for i, target := range targets {
 		// 添加发送间隔（除了第一个消息）
 		if i > 0 && intervalSec > 0 {
-			time.Sleep(time.Duration(intervalSec) * time.Second)
+			select {
+			case <-ctx.Done():
+			case <-time.After(sendDelay.Next(rnd, time.Duration(intervalSec)*time.Second)):
+			}
 		}
+		sendDue()
 
-		username, ok := target.(string)
-		if !ok {
+		// 目标可以是用户名，或带模板变量的 {"username": "...", "vars": {...}}
+		messageTarget, err := models.MessageTargetFromConfig(target)
+		if err != nil {
 			errorMsg := fmt.Sprintf("invalid target format: %v", target)
 			errors = append(errors, errorMsg)
 			targetResults[fmt.Sprintf("target_%d", i+1)] = map[string]interface{}{
@@ -496,29 +684,58 @@ func (t *PrivateMessageTask) Execute(ctx context.Context, api *tg.Client) error
 			continue
 		}
 
-		// 尝试通过用户名解析
-		sendStartTime := time.Now()
-		err := t.sendPrivateMessage(ctx, api, username, message)
-		sendDuration := time.Since(sendStartTime)
+		targetSteps := steps
+		poolIndex := -1
+		if pool != nil {
+			var message string
+			poolIndex, message = pool.Pick(rnd)
+			// 消息池中的消息已在读取配置时校验过格式
+			text, entities, _ := FormatMessage(message, parseMode)
+			targetSteps = []MessageStep{{Text: text, Entities: entities, Template: message, ParseMode: parseMode}}
+		}
 
+		username := messageTarget.Username
+		targetSteps, err = renderMessageSteps(targetSteps, messageTarget.Vars)
 		if err != nil {
-			errorMsg := fmt.Sprintf("failed to send to %s: %v", username, err)
+			errorMsg := fmt.Sprintf("failed to render message for %s: %v", username, err)
 			errors = append(errors, errorMsg)
 			targetResults[username] = map[string]interface{}{
-				"status":   "failed",
-				"error":    err.Error(),
-				"duration": sendDuration.String(),
+				"status": "failed",
+				"reason": "template_error",
+				"error":  err.Error(),
 			}
+			failureReasons["template_error"]++
 			failedCount++
-			addLog(fmt.Sprintf("发送失败 [%s]: %v", username, err))
+			addLog(fmt.Sprintf("消息模板渲染失败 [%s]: %v", username, err))
+			continue
+		}
+
+		seq := newMessageSequence(username, targetSteps, poolIndex)
+		t.advanceSequence(ctx, api, seq, sendDelay, rnd, addLog)
+		if seq.done() {
+			finish(seq)
 		} else {
-			sentCount++
-			sentTargets = append(sentTargets, username)
-			targetResults[username] = map[string]interface{}{
-				"status":   "success",
-				"duration": sendDuration.String(),
+			pending = append(pending, seq)
+		}
+	}
+
+	// 所有目标的首条消息发送完后，按发送时间依次发送剩余步骤
+	for len(pending) > 0 {
+		next := pending[0].dueAt
+		for _, seq := range pending[1:] {
+			if seq.dueAt.Before(next) {
+				next = seq.dueAt
+			}
+		}
+		select {
+		case <-ctx.Done():
+			for _, seq := range pending {
+				seq.fail(ctx.Err())
+				finish(seq)
 			}
-			addLog(fmt.Sprintf("发送成功: %s", username))
+			pending = nil
+		case <-time.After(time.Until(next)):
+			sendDue()
 		}
 	}
 
@@ -528,51 +745,158 @@ func (t *PrivateMessageTask) Execute(ctx context.Context, api *tg.Client) error
 	t.task.Result["errors"] = errors
 	t.task.Result["sent_targets"] = sentTargets
 	t.task.Result["target_results"] = targetResults // 添加每个目标的详细结果
+	t.task.Result["failure_reasons"] = failureReasons
+	t.task.Result["dead_targets"] = deadTargets
 	t.task.Result["total_targets"] = len(targets)
 	t.task.Result["success_rate"] = float64(sentCount) / float64(len(targets))
 	t.task.Result["send_time"] = time.Now().Unix()
+	if t.scheduleDate > 0 {
+		t.task.Result["telegram_schedule_date"] = t.scheduleDate
+	}
+	if pool != nil {
+		pool.SaveCounts(t.task.Result)
+	}
 
 	addLog(fmt.Sprintf("任务执行完成: 成功 %d, 失败 %d", sentCount, failedCount))
 
 	return nil
 }
 
-// sendPrivateMessage 发送私信给指定用户
-func (t *PrivateMessageTask) sendPrivateMessage(ctx context.Context, api *tg.Client, username, message string) error {
-	// 移除用户名前的@符号（如果有的话）
-	cleanUsername := username
-	if len(username) > 0 && username[0] == '@' {
-		cleanUsername = username[1:]
+// renderMessageSteps 用目标的模板变量渲染每一步消息，没有变量时原样返回
+func renderMessageSteps(steps []MessageStep, vars map[string]string) ([]MessageStep, error) {
+	if len(vars) == 0 {
+		return steps, nil
+	}
+	rendered := make([]MessageStep, len(steps))
+	for i, step := range steps {
+		r, err := step.Render(vars)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		rendered[i] = r
+	}
+	return rendered, nil
+}
+
+// renderedMessage 返回实际发送的消息文本，多步序列返回每一步的文本
+func renderedMessage(steps []MessageStep) interface{} {
+	if len(steps) == 1 {
+		return steps[0].Text
+	}
+	texts := make([]string, len(steps))
+	for i, step := range steps {
+		texts[i] = step.Text
 	}
+	return texts
+}
 
-	// 通过用户名解析
-	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-		Username: cleanUsername,
-	})
-	if err != nil {
-		return fmt.Errorf("username not found: %w", err)
+// messageSequence 单个目标的消息序列发送进度
+type messageSequence struct {
+	username  string
+	steps     []MessageStep
+	poolIndex int                      // 消息池下标，未使用消息池时为 -1
+	results   []map[string]interface{} // 每一步的状态: success / failed / skipped
+	peer      *tg.InputPeerUser
+	next      int       // 下一步的下标
+	dueAt     time.Time // 下一步的发送时间
+	startedAt time.Time
+	err       error
+}
+
+// newMessageSequence 创建消息序列，所有步骤初始为 skipped
+func newMessageSequence(username string, steps []MessageStep, poolIndex int) *messageSequence {
+	results := make([]map[string]interface{}, len(steps))
+	for i := range steps {
+		results[i] = map[string]interface{}{"step": i + 1, "status": "skipped"}
 	}
+	return &messageSequence{
+		username:  username,
+		steps:     steps,
+		poolIndex: poolIndex,
+		results:   results,
+		startedAt: time.Now(),
+	}
+}
 
-	// 从解析结果中获取用户信息
-	if len(resolved.Users) > 0 {
-		if user, ok := resolved.Users[0].(*tg.User); ok {
-			inputPeer := &tg.InputPeerUser{
-				UserID:     user.ID,
-				AccessHash: user.AccessHash,
-			}
+// done 序列已全部发送或已失败
+func (s *messageSequence) done() bool {
+	return s.err != nil || s.next >= len(s.steps)
+}
 
-			// 发送消息
-			_, err = api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
-				Peer:     inputPeer,
-				Message:  message,
-				RandomID: time.Now().UnixNano(), // 防止重复消息
-			})
+// fail 将当前步骤标记为失败并结束序列，后续步骤保持 skipped
+func (s *messageSequence) fail(err error) {
+	s.results[s.next]["status"] = "failed"
+	s.results[s.next]["error"] = err.Error()
+	if len(s.steps) > 1 {
+		err = fmt.Errorf("step %d: %w", s.next+1, err)
+	}
+	s.err = err
+}
 
-			return err
+// advanceSequence 发送序列中已到发送时间的步骤，连续发送无延迟的步骤；
+// 遇到需要等待的步骤时记录其发送时间后返回，由调用方到时再继续，某一步失败时停止该目标后续步骤
+func (t *PrivateMessageTask) advanceSequence(ctx context.Context, api *tg.Client, seq *messageSequence, sendDelay SendDelay, rnd *rand.Rand, addLog func(string)) {
+	if seq.peer == nil {
+		peer, err := t.resolveUser(ctx, api, seq.username)
+		if err != nil {
+			seq.results[0]["status"] = "failed"
+			seq.results[0]["error"] = err.Error()
+			seq.err = err
+			return
 		}
+		seq.peer = peer
 	}
 
-	return fmt.Errorf("user not found: %s", username)
+	for !seq.done() {
+		step := seq.steps[seq.next]
+		req := &tg.MessagesSendMessageRequest{
+			Peer:     seq.peer,
+			Message:  step.Text,
+			Entities: step.Entities,
+			RandomID: time.Now().UnixNano(), // 防止重复消息
+		}
+		if t.scheduleDate > 0 {
+			req.SetScheduleDate(t.scheduleDate)
+		}
+		updates, err := api.MessagesSendMessage(ctx, req)
+		if err != nil {
+			seq.fail(err)
+			return
+		}
+		seq.results[seq.next]["status"] = "success"
+		seq.results[seq.next]["sent_at"] = time.Now().Unix()
+		if t.scheduleDate > 0 {
+			seq.results[seq.next]["scheduled_message_id"] = scheduledMessageID(updates)
+		}
+		if len(seq.steps) > 1 {
+			addLog(fmt.Sprintf("[%s] 第 %d/%d 步发送成功", seq.username, seq.next+1, len(seq.steps)))
+		}
+		seq.next++
+
+		if !seq.done() && seq.steps[seq.next].Delay > 0 {
+			delay := sendDelay.Next(rnd, seq.steps[seq.next].Delay)
+			seq.dueAt = time.Now().Add(delay)
+			addLog(fmt.Sprintf("[%s] %s 后发送第 %d 步", seq.username, delay.Round(time.Second), seq.next+1))
+			return
+		}
+	}
+}
+
+// resolveUser 解析私信目标（用户名、t.me 链接或对话列表中的用户ID）
+func (t *PrivateMessageTask) resolveUser(ctx context.Context, api *tg.Client, username string) (*tg.InputPeerUser, error) {
+	peer, err := t.resolveTarget(ctx, api, username)
+	if err != nil {
+		if errors.Is(err, errTargetDeactivated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("username not found: %w", err)
+	}
+
+	user, ok := peer.(*tg.InputPeerUser)
+	if !ok {
+		return nil, fmt.Errorf("user not found: %s", username)
+	}
+	return user, nil
 }
 
 // GetType 获取任务类型
@@ -582,16 +906,34 @@ func (t *PrivateMessageTask) GetType() string {
 
 // BroadcastTask 群发任务
 type BroadcastTask struct {
-	task *models.Task
+	peerResolution
+	task               *models.Task
+	variationGenerator VariationGenerator // AI 变体模式使用，可为 nil
+	scheduleDate       int                // Telegram 定时发送时间（unix 秒），0 表示立即发送
+	groupLimiter       *GroupSendLimiter  // 每日同一群组发送次数限制，可为 nil
+	accountID          uint64             // 当前执行账号，用于按账号统计群组发送次数
+	joinRetry          JoinRetryPolicy    // 邀请链接加群的间隔和限流重试
+	lastJoinAt         time.Time          // 上次通过邀请链接加群的时间
+	interruptCtx       context.Context    // 任务被暂停或停止时取消，发送循环据此提前结束，可为 nil
 }
 
 // NewBroadcastTask 创建群发任务
-func NewBroadcastTask(task *models.Task) *BroadcastTask {
-	return &BroadcastTask{task: task}
+func NewBroadcastTask(task *models.Task, variationGenerator VariationGenerator) *BroadcastTask {
+	return &BroadcastTask{task: task, variationGenerator: variationGenerator, joinRetry: DefaultJoinRetryPolicy}
+}
+
+// SetGroupSendLimiter 设置每日同一群组发送次数限制器及当前执行账号
+func (t *BroadcastTask) SetGroupSendLimiter(limiter *GroupSendLimiter, accountID uint64) {
+	t.groupLimiter = limiter
+	t.accountID = accountID
 }
 
 // Execute 执行群发消息
 func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
+	// 发送循环在群组之间自行响应暂停/停止，正在发送的群组不随任务上下文取消而中断
+	if t.interruptCtx != nil {
+		ctx = context.WithoutCancel(ctx)
+	}
 	config := t.task.Config
 
 	// 验证配置完整性
@@ -605,17 +947,48 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 		return fmt.Errorf("invalid or empty groups configuration")
 	}
 
-	// 获取消息内容
-	message, ok := config["message"].(string)
-	if !ok || message == "" {
+	// 获取消息内容：配置了 message_pool 时每个群组从池中选取，覆盖单条 message
+	pool, err := MessagePoolFromConfig(config)
+	if err != nil {
+		return err
+	}
+	message, _ := config["message"].(string)
+	// 配置了 media 时发送图片/文件（多个为相册），message 可为空，此时使用各媒体自身的说明文字
+	media, err := BroadcastMediaFromConfig(config)
+	if err != nil {
+		return err
+	}
+	if pool == nil && message == "" && len(media) == 0 {
 		return fmt.Errorf("invalid or empty message configuration")
 	}
 
+	// 获取消息变体模式
+	variationMode := VariationModeNone
+	if val, ok := config["variation_mode"].(string); ok && val != "" {
+		variationMode = val
+	}
+	if variationMode != VariationModeNone && variationMode != VariationModeSpintax && variationMode != VariationModeAI {
+		return fmt.Errorf("invalid variation_mode: %s", variationMode)
+	}
+
+	// 获取消息格式解析模式，并预先校验模板格式
+	parseMode, _ := config["parse_mode"].(string)
+	if pool == nil {
+		if _, _, err := FormatMessage(message, parseMode); err != nil {
+			return err
+		}
+	}
+
 	// 获取自动加群配置
 	autoJoin := false
 	if val, ok := config["auto_join"].(bool); ok {
 		autoJoin = val
 	}
+	// 加群后检查账号能否发言，无法发言的群组直接跳过，避免注定失败的发送触发限制
+	verifyCanPost := true
+	if val, ok := config["verify_can_post"].(bool); ok {
+		verifyCanPost = val
+	}
 
 	// 获取单号限制
 	limitPerAccount := 0
@@ -631,9 +1004,14 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 	startIndex := 0
 	if val, ok := t.task.Result["next_group_index"].(float64); ok {
 		startIndex = int(val)
+	} else if val, ok := t.task.Result["next_group_index"].(int); ok {
+		startIndex = val
 	}
 
-	if limitPerAccount > 0 {
+	if paused, ok := t.takePausedGroups(); ok {
+		// 从暂停恢复：只发送暂停时该账号尚未发送的群组
+		targetGroups = paused
+	} else if limitPerAccount > 0 {
 		endIndex := startIndex + limitPerAccount
 		if endIndex > len(groups) {
 			endIndex = len(groups)
@@ -651,9 +1029,32 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 		targetGroups = groups
 	}
 
+	// 之前的账号因频道数量上限未能处理的群组，优先由当前账号接手
+	if deferred := t.takeDeferredGroups(); len(deferred) > 0 {
+		queued := make(map[string]bool, len(targetGroups))
+		for _, group := range targetGroups {
+			queued[fmt.Sprintf("%v", group)] = true
+		}
+		var extra []interface{}
+		for _, group := range deferred {
+			if !queued[fmt.Sprintf("%v", group)] {
+				extra = append(extra, group)
+			}
+		}
+		targetGroups = append(extra, targetGroups...)
+	}
+	delete(t.task.Result, "channel_limit_reached")
+	delete(t.task.Result, "write_restricted")
+
 	// 记录本次执行的范围，便于调试
 	t.task.Result[fmt.Sprintf("account_range_%d", time.Now().UnixNano())] = fmt.Sprintf("%d-%d", startIndex, startIndex+len(targetGroups))
 
+	// 账号被禁言时的处理策略: stop 立即停止使用该账号并将剩余群组交给其他账号, continue 继续尝试剩余群组
+	writeForbiddenPolicy := WriteForbiddenPolicyStop
+	if val, ok := config["write_forbidden_policy"].(string); ok && val != "" {
+		writeForbiddenPolicy = val
+	}
+
 	// 获取发送间隔 (防止被限制)
 	intervalSec := 3 // 默认3秒间隔，群发更谨慎
 	if interval, exists := config["interval_seconds"]; exists {
@@ -661,6 +1062,27 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 			intervalSec = int(intervalFloat)
 		}
 	}
+	sendDelay, err := SendDelayFromConfig(config)
+	if err != nil {
+		return err
+	}
+	canary, err := BroadcastCanaryFromConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// 单个账号每天向同一群组发送的上限，任务配置优先，0 表示不限制
+	groupDailyCap := 0
+	if t.groupLimiter != nil {
+		groupDailyCap = t.groupLimiter.DefaultCap()
+		if val, ok := config["max_messages_per_group_per_day"].(float64); ok && val >= 0 {
+			groupDailyCap = int(val)
+		}
+	}
+	t.scheduleDate, err = TelegramScheduleDateFromConfig(config)
+	if err != nil {
+		return err
+	}
 
 	// 初始化日志
 	var logs []string
@@ -678,27 +1100,142 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 		t.task.Result["logs"] = logs
 	}
 
-	addLog(fmt.Sprintf("开始执行群发任务，目标群组数: %d", len(targetGroups)))
+	if DryRunFromConfig(config) {
+		// 试运行：检查当前账号负责的群组能否发送，不加群、不发送消息
+		addLog(fmt.Sprintf("试运行模式：检查 %d 个群组，不加群、不发送消息", len(targetGroups)))
+		recordDryRun(t.task.Result, dryRunTargets(ctx, api, &t.peerResolution, targetGroups, false, addLog))
+		return nil
+	}
+
+	addLog(fmt.Sprintf("开始执行群发任务，目标群组数: %d，间隔: %d秒，间隔分布: %s", len(targetGroups), intervalSec, sendDelay))
+	if t.scheduleDate > 0 {
+		addLog(fmt.Sprintf("使用 Telegram 定时消息，发送时间: %s", time.Unix(int64(t.scheduleDate), 0).Format("2006-01-02 15:04:05")))
+	}
+
+	// AI 模式预先生成变体，发送时轮询使用，避免每次发送等待 AI；消息池本身即为多条消息，不再生成变体
+	var variations []string
+	if pool != nil {
+		pool.LoadCounts(t.task.Result)
+		addLog(fmt.Sprintf("使用消息池，共 %d 条消息", pool.Size()))
+		if variationMode == VariationModeAI {
+			addLog("已配置消息池，忽略 AI 变体模式")
+		}
+	} else if variationMode == VariationModeAI && message != "" {
+		variations = t.prepareVariations(ctx, message, addLog)
+		if len(variations) == 0 {
+			addLog("AI 变体生成失败，使用原始消息")
+		} else {
+			addLog(fmt.Sprintf("已准备 %d 条消息变体", len(variations)))
+		}
+	}
+	if len(media) > 0 {
+		addLog(fmt.Sprintf("发送媒体消息，每个群组 %d 个媒体", len(media)))
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if RandomOrderFromConfig(config) {
+		// 只打乱当前账号负责的群组，不影响各账号之间的分配
+		targetGroups = ShuffleTargets(rnd, targetGroups)
+		addLog("已打乱群组发送顺序")
+	}
+
+	// 金丝雀模式：同一任务只检查一次，已中止时当前账号不再发送
+	canaryPending := false
+	var canaryBaseline string
+	var canaryGroups []string
+	var canaryAborted []interface{} // 因金丝雀检查未通过而未发送的群组
+	if canary.Count > 0 && len(targetGroups) > 0 {
+		switch t.canaryVerdict() {
+		case CanaryVerdictAborted:
+			canaryAborted = targetGroups
+			targetGroups = nil
+			addLog(fmt.Sprintf("金丝雀检查未通过，跳过当前账号的 %d 个群组", len(canaryAborted)))
+		case "":
+			canaryPending = true
+			canaryBaseline = t.canaryBaseline(ctx, api, addLog)
+		}
+	}
 
 	sentCount := 0
 	failedCount := 0
 	var errors []string
 	var sentGroups []string
+	scheduledMessages := make(map[string]interface{}) // 使用 Telegram 定时消息时各群组的定时消息ID
+	failedGroups := make(map[string]interface{})      // 发送失败的群组及具体原因
+	var deferredGroups []interface{}                  // 因频道数量上限无法加入、留给其他账号的群组
+	atChannelLimit := false
+	var restrictedGroups []interface{} // 账号被禁言后未处理、留给其他账号的群组
+	writeRestricted := false
+	capSkipped := make(map[string]interface{})          // 当日已达发送上限而跳过的群组及当日已发送次数
+	groupPoolIndex := make(map[string]int)              // 使用消息池时各群组选取的消息下标
+	cannotPost := make(map[string]interface{})          // 已加入但无法发言而跳过的群组
+	mediaUploadFailures := make(map[string]interface{}) // 媒体上传失败的群组及失败的媒体下标
 
 	// 发送消息到每个群组
 	for i, group := range targetGroups {
-		// 添加发送间隔（除了第一个消息）
+		// 任务被暂停或停止，记录尚未发送的群组，恢复时继续
+		if t.interrupted() {
+			t.recordPausedGroups(targetGroups[i:])
+			addLog(fmt.Sprintf("任务已暂停，剩余 %d 个群组将在恢复后继续发送", len(targetGroups)-i))
+			break
+		}
+
+		// 金丝雀批次发送完毕，检查通过后才继续
+		if canaryPending && len(canaryGroups) >= canary.Count {
+			canaryPending = false
+			if !t.runCanaryCheck(ctx, api, canary, canaryBaseline, canaryGroups, addLog) {
+				canaryAborted = append(canaryAborted, targetGroups[i:]...)
+				break
+			}
+		}
+
+		// 当日向该群组的发送次数已达上限则跳过；Redis 不可用时不阻断发送
+		groupKey := fmt.Sprintf("%v", group)
+		reserved := false
+		if t.groupLimiter != nil {
+			allowed, count, err := t.groupLimiter.Reserve(ctx, t.accountID, groupKey, groupDailyCap)
+			if err != nil {
+				addLog(fmt.Sprintf("群组发送次数统计失败 [%v]: %v，继续发送", group, err))
+			} else if !allowed {
+				capSkipped[groupKey] = count
+				addLog(fmt.Sprintf("跳过群组 [%v]: 当日已发送 %d 次，达到上限 %d", group, count, groupDailyCap))
+				continue
+			} else {
+				reserved = true
+			}
+		}
+
+		// 添加发送间隔（除了第一个消息），等待期间暂停则立即停止
 		if i > 0 && intervalSec > 0 {
-			time.Sleep(time.Duration(intervalSec) * time.Second)
+			if !t.sleep(sendDelay.Next(rnd, time.Duration(intervalSec)*time.Second)) {
+				if reserved {
+					t.groupLimiter.Release(ctx, t.accountID, groupKey)
+				}
+				t.recordPausedGroups(targetGroups[i:])
+				addLog(fmt.Sprintf("任务已暂停，剩余 %d 个群组将在恢复后继续发送", len(targetGroups)-i))
+				break
+			}
 		}
 
 		var explicitPeer tg.InputPeerClass
 		var joinErr error
 
-		// 如果开启了自动加群，尝试先加入
+		// 如果开启了自动加群，尝试先加入；达到频道上限后只处理已加入的群组
 		if autoJoin {
-			addLog(fmt.Sprintf("尝试自动加入群组: %v", group))
-			explicitPeer, joinErr = t.joinGroup(ctx, api, group)
+			if !atChannelLimit {
+				addLog(fmt.Sprintf("尝试自动加入群组: %v", group))
+			}
+			explicitPeer, joinErr = t.joinGroup(ctx, api, group, !atChannelLimit)
+			if joinErr == errChannelsTooMuch {
+				if !atChannelLimit {
+					atChannelLimit = true
+					addLog("账号已达到频道/群组数量上限 (CHANNELS_TOO_MUCH)，停止自动加群，剩余未加入的群组将交给其他账号")
+				}
+				deferredGroups = append(deferredGroups, group)
+				if reserved {
+					t.groupLimiter.Release(ctx, t.accountID, groupKey)
+				}
+				continue
+			}
 			if joinErr != nil {
 				// 记录加群失败，但仍尝试发送（可能已经在群里了）
 				addLog(fmt.Sprintf("自动加群失败: %v, 尝试直接发送", joinErr))
@@ -706,22 +1243,95 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 				addLog(fmt.Sprintf("自动加群成功: %v", group))
 				// 加群成功后稍微等待一下，确保状态同步
 				time.Sleep(1 * time.Second)
+
+				if verifyCanPost && explicitPeer != nil {
+					restriction, err := checkCanPost(ctx, api, explicitPeer)
+					if err != nil {
+						addLog(fmt.Sprintf("发言权限检查失败 [%v]: %v，继续尝试发送", group, err))
+					} else if restriction != "" {
+						cannotPost[groupKey] = map[string]interface{}{
+							"status": StatusJoinButCannotPost,
+							"reason": restriction,
+						}
+						addLog(fmt.Sprintf("跳过群组 [%v]: 已加入但无法发言 (%s)", group, restriction))
+						if reserved {
+							t.groupLimiter.Release(ctx, t.accountID, groupKey)
+						}
+						continue
+					}
+				}
 			}
 		}
 
-		err := t.sendBroadcastMessage(ctx, api, group, message, explicitPeer)
+		groupMessage := message
+		if pool != nil {
+			var poolIndex int
+			poolIndex, groupMessage = pool.Pick(rnd)
+			groupPoolIndex[groupKey] = poolIndex
+		}
+		switch variationMode {
+		case VariationModeSpintax:
+			groupMessage = ExpandSpintax(groupMessage, rnd)
+		case VariationModeAI:
+			if len(variations) > 0 {
+				groupMessage = variations[(startIndex+i)%len(variations)]
+			}
+		}
+
+		var scheduledID int
+		text, entities, err := FormatMessage(groupMessage, parseMode)
+		if err == nil {
+			if len(media) > 0 {
+				scheduledID, err = t.sendBroadcastMedia(ctx, api, group, media, text, entities, parseMode, explicitPeer)
+			} else {
+				scheduledID, err = t.sendBroadcastMessage(ctx, api, group, text, entities, explicitPeer)
+			}
+		}
+		if canaryPending {
+			canaryGroups = append(canaryGroups, fmt.Sprintf("%v", group))
+		}
 		if err != nil {
-			errMsg := fmt.Sprintf("发送失败 [%v]: %v", group, err)
+			if reserved {
+				t.groupLimiter.Release(ctx, t.accountID, groupKey)
+			}
+			reason := ClassifyTargetError(err)
+			errMsg := fmt.Sprintf("发送失败 [%v] (%s): %v", group, reason, err)
 			addLog(errMsg)
 			errors = append(errors, errMsg)
+			failedGroups[fmt.Sprintf("%v", group)] = map[string]interface{}{
+				"reason": reason,
+				"error":  err.Error(),
+			}
+			if uploadErr, ok := err.(*mediaUploadError); ok {
+				mediaUploadFailures[groupKey] = map[string]interface{}{
+					"media_index": uploadErr.Index,
+					"error":       uploadErr.Err.Error(),
+				}
+			}
 			failedCount++
+
+			// 账号被禁言后继续发送只会逐个失败，停止使用该账号
+			if writeForbiddenPolicy == WriteForbiddenPolicyStop && isWriteRestrictedError(err) {
+				writeRestricted = true
+				restrictedGroups = append(restrictedGroups, targetGroups[i+1:]...)
+				addLog(fmt.Sprintf("账号已被限制发言，停止使用该账号，剩余 %d 个群组将交给其他账号", len(restrictedGroups)))
+				break
+			}
 		} else {
 			addLog(fmt.Sprintf("发送成功: %v", group))
 			sentCount++
 			sentGroups = append(sentGroups, fmt.Sprintf("%v", group))
+			if t.scheduleDate > 0 {
+				scheduledMessages[fmt.Sprintf("%v", group)] = scheduledID
+			}
 		}
 	}
 
+	// 群组不足一个金丝雀批次时，发送完后仍做检查，为后续账号给出结论
+	if canaryPending && len(canaryGroups) > 0 && !writeRestricted {
+		t.runCanaryCheck(ctx, api, canary, canaryBaseline, canaryGroups, addLog)
+	}
+
 	// 更新任务结果
 	if t.task.Result == nil {
 		t.task.Result = make(models.TaskResult)
@@ -732,6 +1342,7 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 	t.task.Result["errors"] = errors
 	t.task.Result["logs"] = logs
 	t.task.Result["sent_groups"] = sentGroups
+	t.task.Result["failed_groups"] = failedGroups
 	t.task.Result["total_groups"] = len(targetGroups)
 	if len(targetGroups) > 0 {
 		t.task.Result["success_rate"] = float64(sentCount) / float64(len(targetGroups))
@@ -739,14 +1350,141 @@ func (t *BroadcastTask) Execute(ctx context.Context, api *tg.Client) error {
 		t.task.Result["success_rate"] = 0
 	}
 	t.task.Result["send_time"] = time.Now().Unix()
+	if t.scheduleDate > 0 {
+		t.task.Result["telegram_schedule_date"] = t.scheduleDate
+		t.task.Result["scheduled_messages"] = scheduledMessages
+	}
+	if atChannelLimit {
+		t.task.Result["channel_limit_reached"] = true
+		t.task.Result["channel_limit_deferred_groups"] = deferredGroups
+		addLog(fmt.Sprintf("因频道数量上限转交其他账号的群组数: %d", len(deferredGroups)))
+	}
+	if writeRestricted {
+		t.task.Result["write_restricted"] = true
+		t.task.Result["write_restricted_deferred_groups"] = restrictedGroups
+	}
+	if len(capSkipped) > 0 {
+		t.task.Result["group_cap_skipped"] = capSkipped
+		t.task.Result["group_daily_cap"] = groupDailyCap
+		addLog(fmt.Sprintf("因达到单群每日发送上限跳过的群组数: %d", len(capSkipped)))
+	} else {
+		delete(t.task.Result, "group_cap_skipped")
+	}
+	if len(cannotPost) > 0 {
+		t.task.Result["cannot_post_groups"] = cannotPost
+		addLog(fmt.Sprintf("已加入但无法发言而跳过的群组数: %d", len(cannotPost)))
+	} else {
+		delete(t.task.Result, "cannot_post_groups")
+	}
+	if len(mediaUploadFailures) > 0 {
+		t.task.Result["media_upload_failures"] = mediaUploadFailures
+		addLog(fmt.Sprintf("媒体上传失败的群组数: %d", len(mediaUploadFailures)))
+	} else {
+		delete(t.task.Result, "media_upload_failures")
+	}
+	if pool != nil {
+		pool.SaveCounts(t.task.Result)
+		// 多个账号执行同一任务时合并各自记录的消息下标
+		merged := make(map[string]interface{})
+		if existing, ok := t.task.Result["group_pool_index"].(map[string]interface{}); ok {
+			for group, index := range existing {
+				merged[group] = index
+			}
+		}
+		for group, index := range groupPoolIndex {
+			merged[group] = index
+		}
+		t.task.Result["group_pool_index"] = merged
+	}
+	if len(canaryAborted) > 0 {
+		existing, _ := t.task.Result["canary_aborted_groups"].([]interface{})
+		t.task.Result["canary_aborted_groups"] = append(existing, canaryAborted...)
+	}
+	if floodWaits, ok := t.task.Result["join_flood_waits"].([]interface{}); ok && len(floodWaits) > 0 {
+		addLog(fmt.Sprintf("邀请链接加群触发限流 %d 次，累计等待 %v 秒，建议放慢加群节奏（join_interval_seconds）", len(floodWaits), t.task.Result["join_flood_wait_seconds"]))
+	}
 
 	addLog(fmt.Sprintf("任务执行完成: 成功 %d, 失败 %d", sentCount, failedCount))
 
 	return nil
 }
 
+// takeDeferredGroups 取出之前账号因频道上限或被禁言留下的群组
+func (t *BroadcastTask) takeDeferredGroups() []interface{} {
+	deferred, _ := t.task.Result["channel_limit_deferred_groups"].([]interface{})
+	delete(t.task.Result, "channel_limit_deferred_groups")
+	if restricted, ok := t.task.Result["write_restricted_deferred_groups"].([]interface{}); ok {
+		deferred = append(deferred, restricted...)
+	}
+	delete(t.task.Result, "write_restricted_deferred_groups")
+	return deferred
+}
+
+// prepareVariations 获取 AI 消息变体，同一任务的多个账号共享已生成的变体
+func (t *BroadcastTask) prepareVariations(ctx context.Context, message string, addLog func(string)) []string {
+	var variations []string
+	switch cached := t.task.Result["message_variations"].(type) {
+	case []string:
+		variations = cached
+	case []interface{}:
+		for _, v := range cached {
+			if str, ok := v.(string); ok && str != "" {
+				variations = append(variations, str)
+			}
+		}
+	}
+	if len(variations) > 0 || t.variationGenerator == nil {
+		return variations
+	}
+
+	count := defaultVariationCount
+	if val, ok := t.task.Config["variation_count"].(float64); ok && val > 0 {
+		count = int(val)
+	}
+
+	// 任务配置已在创建时校验，这里解析失败时按默认参数生成
+	sampling, _ := models.AISamplingFromConfig(t.task.Config)
+	generated, err := t.variationGenerator.GenerateVariations(ctx, message, count, sampling)
+	if err != nil {
+		logger.Get().Warn("Failed to generate message variations",
+			zap.Uint64("task_id", t.task.ID),
+			zap.Uint64("account_id", t.accountID),
+			zap.Int("count", count),
+			zap.Error(err))
+		addLog(fmt.Sprintf("AI 变体生成出错: %v", err))
+		return nil
+	}
+	postProcess, _ := models.AIPostProcessFromConfig(t.task.Config)
+	for _, v := range generated {
+		if v = strings.TrimSpace(postProcess.Apply(v)); v != "" {
+			variations = append(variations, v)
+		}
+	}
+	t.task.Result["message_variations"] = variations
+	return variations
+}
+
+// 群发账号被禁言时的处理策略
+const (
+	WriteForbiddenPolicyStop     = "stop"     // 立即停止使用该账号，剩余群组交给其他账号
+	WriteForbiddenPolicyContinue = "continue" // 继续尝试剩余群组
+)
+
+// isWriteRestrictedError 判断发送失败是否因账号被限制发言
+func isWriteRestrictedError(err error) bool {
+	errStr := strings.ToUpper(err.Error())
+	return strings.Contains(errStr, "CHAT_WRITE_FORBIDDEN") ||
+		strings.Contains(errStr, "USER_RESTRICTED") ||
+		strings.Contains(errStr, "CHAT_RESTRICTED") ||
+		strings.Contains(errStr, "USER_BANNED_IN_CHANNEL")
+}
+
+// errChannelsTooMuch 账号加入的频道/超级群数量已达 Telegram 上限 (约500个)
+var errChannelsTooMuch = fmt.Errorf("account has reached the channel limit (CHANNELS_TOO_MUCH)")
+
 // joinGroup 尝试加入群组，并返回 InputPeer
-func (t *BroadcastTask) joinGroup(ctx context.Context, api *tg.Client, group interface{}) (tg.InputPeerClass, error) {
+// allowJoin 为 false 时只返回已加入群组的 Peer，需要新加入的群组返回 errChannelsTooMuch
+func (t *BroadcastTask) joinGroup(ctx context.Context, api *tg.Client, group interface{}, allowJoin bool) (tg.InputPeerClass, error) {
 	groupStr, ok := group.(string)
 	if !ok {
 		return nil, nil // 非字符串无法通过此方法加入
@@ -764,8 +1502,14 @@ func (t *BroadcastTask) joinGroup(ctx context.Context, api *tg.Client, group int
 		if hash == "" {
 			return nil, fmt.Errorf("invalid join link")
 		}
-		updates, err := api.MessagesImportChatInvite(ctx, hash)
+		if !allowJoin {
+			return nil, errChannelsTooMuch
+		}
+		updates, err := t.importChatInvite(ctx, api, groupStr, hash)
 		if err != nil {
+			if strings.Contains(err.Error(), "CHANNELS_TOO_MUCH") {
+				return nil, errChannelsTooMuch
+			}
 			if strings.Contains(err.Error(), "USER_ALREADY_PARTICIPANT") {
 				// 如果已经在群里，我们无法直接获取 InputPeer，因为 CheckChatInvite 不返回 ID
 				// 只能返回 nil，让 sendBroadcastMessage 尝试通过其他方式（如 Dialogs）解决
@@ -779,12 +1523,8 @@ func (t *BroadcastTask) joinGroup(ctx context.Context, api *tg.Client, group int
 		return t.extractInputPeerFromUpdates(updates)
 	}
 
-	// 移除其他链接前缀
-	if len(cleanGroupname) > 13 && cleanGroupname[:13] == "https://t.me/" {
-		cleanGroupname = cleanGroupname[13:]
-	} else if len(cleanGroupname) > 5 && cleanGroupname[:5] == "t.me/" {
-		cleanGroupname = cleanGroupname[5:]
-	}
+	// 移除其他链接前缀，保留机器人深链接的启动参数
+	cleanGroupname, startParam := parseDeepLink(cleanGroupname)
 
 	// 解析用户名
 	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
@@ -794,6 +1534,11 @@ func (t *BroadcastTask) joinGroup(ctx context.Context, api *tg.Client, group int
 		return nil, fmt.Errorf("failed to resolve group: %w", err)
 	}
 
+	// 机器人目标：按启动参数启动机器人
+	if bot := resolvedBot(resolved); bot != nil {
+		return startBotPeer(ctx, api, bot, startParam)
+	}
+
 	// 尝试加入
 	if len(resolved.Chats) > 0 {
 		if channel, ok := resolved.Chats[0].(*tg.Channel); ok {
@@ -810,8 +1555,14 @@ func (t *BroadcastTask) joinGroup(ctx context.Context, api *tg.Client, group int
 				}, nil
 			}
 
+			if !allowJoin {
+				return nil, errChannelsTooMuch
+			}
 			_, err := api.ChannelsJoinChannel(ctx, inputChannel)
 			if err != nil {
+				if strings.Contains(err.Error(), "CHANNELS_TOO_MUCH") {
+					return nil, errChannelsTooMuch
+				}
 				return nil, err
 			}
 
@@ -856,73 +1607,80 @@ func (t *BroadcastTask) extractInputPeerFromChat(chat tg.ChatClass) (tg.InputPee
 	return nil, fmt.Errorf("unknown chat type")
 }
 
-// sendBroadcastMessage 发送群发消息到指定群组
-func (t *BroadcastTask) sendBroadcastMessage(ctx context.Context, api *tg.Client, group interface{}, message string, explicitPeer tg.InputPeerClass) error {
-	var inputPeer tg.InputPeerClass
-
-	// 如果提供了明确的 Peer (通常来自 joinGroup)，直接使用
+// resolveBroadcastPeer 解析群发目标，提供了明确的 Peer (通常来自 joinGroup) 时直接使用
+func (t *BroadcastTask) resolveBroadcastPeer(ctx context.Context, api *tg.Client, group interface{}, explicitPeer tg.InputPeerClass) (tg.InputPeerClass, error) {
 	if explicitPeer != nil {
-		inputPeer = explicitPeer
-	} else {
-		switch v := group.(type) {
-		case int64:
-			inputPeer = &tg.InputPeerChat{ChatID: v}
-		case float64:
-			inputPeer = &tg.InputPeerChat{ChatID: int64(v)}
-		case string:
-			// 如果是字符串，尝试解析为群组用户名
-			cleanGroupname := v
-			if len(v) > 0 && v[0] == '@' {
-				cleanGroupname = v[1:]
-			}
-
-			// 移除可能的链接前缀
-			if len(cleanGroupname) > 13 && cleanGroupname[:13] == "https://t.me/" {
-				cleanGroupname = cleanGroupname[13:]
-			} else if len(cleanGroupname) > 5 && cleanGroupname[:5] == "t.me/" {
-				cleanGroupname = cleanGroupname[5:]
-			}
-
-			// 移除 joinchat 前缀
-			if strings.Contains(cleanGroupname, "joinchat/") {
-				return fmt.Errorf("cannot send message to invite link directly, please ensure auto_join is enabled and successful")
-			}
+		return explicitPeer, nil
+	}
+
+	switch v := group.(type) {
+	case int64:
+		// 数字ID通过对话列表解析，超级群/频道需要 access hash
+		return t.resolveTarget(ctx, api, strconv.FormatInt(v, 10))
+	case float64:
+		return t.resolveTarget(ctx, api, strconv.FormatInt(int64(v), 10))
+	case string:
+		// 邀请链接需要先通过 auto_join 加入
+		if strings.Contains(v, "joinchat/") {
+			return nil, fmt.Errorf("cannot send message to invite link directly, please ensure auto_join is enabled and successful")
+		}
 
+		// 带启动参数的机器人深链接需要先启动机器人，不走缓存
+		if cleanGroupname, startParam := parseDeepLink(v); startParam != "" {
 			resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
 				Username: cleanGroupname,
 			})
 			if err != nil {
-				return fmt.Errorf("group not found: %w", err)
+				return nil, fmt.Errorf("group not found: %w", err)
 			}
-
-			// 从解析结果中获取群组信息
-			if len(resolved.Chats) > 0 {
-				if chat, ok := resolved.Chats[0].(*tg.Chat); ok {
-					inputPeer = &tg.InputPeerChat{ChatID: chat.ID}
-				} else if channel, ok := resolved.Chats[0].(*tg.Channel); ok {
-					inputPeer = &tg.InputPeerChannel{
-						ChannelID:  channel.ID,
-						AccessHash: channel.AccessHash,
-					}
-				} else {
-					return fmt.Errorf("unsupported chat type")
-				}
-			} else {
-				return fmt.Errorf("group not found: %s", cleanGroupname)
+			if bot := resolvedBot(resolved); bot != nil {
+				return startBotPeer(ctx, api, bot, startParam)
 			}
-		default:
-			return fmt.Errorf("unsupported group identifier type: %T", group)
 		}
+
+		peer, err := t.resolveTarget(ctx, api, v)
+		if err != nil {
+			return nil, fmt.Errorf("group not found: %w", err)
+		}
+		return peer, nil
+	default:
+		return nil, fmt.Errorf("unsupported group identifier type: %T", group)
+	}
+}
+
+// sendBroadcastMessage 发送群发消息到指定群组，使用 Telegram 定时消息时返回定时消息ID
+func (t *BroadcastTask) sendBroadcastMessage(ctx context.Context, api *tg.Client, group interface{}, message string, entities []tg.MessageEntityClass, explicitPeer tg.InputPeerClass) (int, error) {
+	inputPeer, err := t.resolveBroadcastPeer(ctx, api, group, explicitPeer)
+	if err != nil {
+		return 0, err
 	}
 
 	// 发送消息
-	_, err := api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+	req := &tg.MessagesSendMessageRequest{
 		Peer:     inputPeer,
 		Message:  message,
+		Entities: entities,
 		RandomID: time.Now().UnixNano(),
-	})
-
-	return err
+	}
+	if t.scheduleDate > 0 {
+		req.SetScheduleDate(t.scheduleDate)
+	}
+	updates, err := api.MessagesSendMessage(ctx, req)
+	if err != nil && explicitPeer == nil && t.invalidatePeer(inputPeer, err) {
+		// 缓存的 access hash 已失效，重新解析后重试一次
+		if req.Peer, err = t.resolveBroadcastPeer(ctx, api, group, nil); err != nil {
+			return 0, err
+		}
+		req.RandomID = time.Now().UnixNano()
+		updates, err = api.MessagesSendMessage(ctx, req)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if t.scheduleDate > 0 {
+		return scheduledMessageID(updates), nil
+	}
+	return 0, nil
 }
 
 // GetType 获取任务类型
@@ -932,7 +1690,9 @@ func (t *BroadcastTask) GetType() string {
 
 // VerifyCodeTask 验证码接收任务
 type VerifyCodeTask struct {
-	task *models.Task
+	task           *models.Task
+	connectionPool *ConnectionPool // 用于注册消息推送监听，为 nil 时只轮询对话
+	accountID      string
 }
 
 // NewVerifyCodeTask 创建验证码接收任务
@@ -993,40 +1753,62 @@ func (t *VerifyCodeTask) Execute(ctx context.Context, api *tg.Client) error {
 	addLog(fmt.Sprintf("监听发送者: %v", senders))
 
 	startTime := time.Now()
+	deadline := startTime.Add(time.Duration(timeoutSec) * time.Second)
 	var verifyCode string
 	var receivedAt time.Time
 	var senderInfo string
+	receivedVia := ""
+
+	// 优先通过消息推送接收验证码，推送监听在任务结束或超时时移除
+	pushed := make(chan verifyCodeMatch, 1)
+	listening := false
+	if t.connectionPool != nil && t.accountID != "" {
+		remove := t.connectionPool.AddUpdateListener(t.accountID, t.pushHandler(senders, startTime, pushed))
+		defer remove()
+		listening = true
+		addLog("已注册消息推送监听")
+	}
+
+	// 推送在等待时间内未到达时开始轮询对话，轮询期间推送仍然有效
+	if listening {
+		select {
+		case m := <-pushed:
+			verifyCode, senderInfo, receivedAt, receivedVia = m.code, m.sender, m.receivedAt, "push"
+		case <-time.After(verifyCodePushWait):
+			addLog(fmt.Sprintf("%s 内未收到推送，开始轮询对话", verifyCodePushWait))
+		case <-ctx.Done():
+		}
+	}
 
-	// 轮询检查新消息
 	lastLogTime := time.Now()
-	for time.Since(startTime) < time.Duration(timeoutSec)*time.Second {
+	for verifyCode == "" && ctx.Err() == nil && time.Now().Before(deadline) {
 		// 每30秒打印一次心跳日志
 		if time.Since(lastLogTime) > 30*time.Second {
 			addLog(fmt.Sprintf("正在监听中... (已等待 %d 秒)", int(time.Since(startTime).Seconds())))
 			lastLogTime = time.Now()
 		}
 
-		// 获取最新对话
+		// 获取最新对话，检查每个对话的最新消息
 		dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
 			Limit: 20,
 		})
-		if err != nil {
-			time.Sleep(2 * time.Second)
-			continue
-		}
-
-		// 检查每个对话的最新消息
-		code, sender, receivedTime, found := t.searchVerifyCode(dialogs, senders, startTime)
-		if found {
-			verifyCode = code
-			senderInfo = sender
-			receivedAt = receivedTime
-			addLog(fmt.Sprintf("成功接收到验证码: %s (来自: %s)", code, sender))
-			break
+		if err == nil {
+			if code, sender, receivedTime, found := t.searchVerifyCode(dialogs, senders, startTime); found {
+				verifyCode, senderInfo, receivedAt, receivedVia = code, sender, receivedTime, "poll"
+				break
+			}
 		}
 
 		// 等待2秒后再次检查
-		time.Sleep(2 * time.Second)
+		select {
+		case m := <-pushed:
+			verifyCode, senderInfo, receivedAt, receivedVia = m.code, m.sender, m.receivedAt, "push"
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+		}
+	}
+	if verifyCode != "" {
+		addLog(fmt.Sprintf("成功接收到验证码: %s (来自: %s)", verifyCode, senderInfo))
 	}
 
 	// 更新任务结果
@@ -1034,6 +1816,7 @@ func (t *VerifyCodeTask) Execute(ctx context.Context, api *tg.Client) error {
 		t.task.Result["verify_code"] = verifyCode
 		t.task.Result["sender"] = senderInfo
 		t.task.Result["received_at"] = receivedAt.Unix()
+		t.task.Result["received_via"] = receivedVia
 		t.task.Result["status"] = "received"
 	} else {
 		t.task.Result["verify_code"] = ""
@@ -1052,38 +1835,8 @@ func (t *VerifyCodeTask) searchVerifyCode(dialogs tg.MessagesDialogsClass, sende
 	if messagesDialogs, ok := dialogs.(*tg.MessagesDialogs); ok {
 		for _, message := range messagesDialogs.Messages {
 			if msg, ok := message.(*tg.Message); ok {
-				// 检查消息时间是否在任务开始后
-				msgTime := time.Unix(int64(msg.Date), 0)
-				if msgTime.Before(startTime) {
-					continue
-				}
-
-				// 检查发送者
-				var msgSender string
-				if msg.FromID != nil {
-					if peerUser, ok := msg.FromID.(*tg.PeerUser); ok {
-						msgSender = fmt.Sprintf("%d", peerUser.UserID)
-					}
-				} else {
-					msgSender = "777000" // Telegram系统消息
-				}
-
-				// 验证发送者是否在白名单中
-				senderMatched := false
-				for _, allowedSender := range senders {
-					if msgSender == allowedSender {
-						senderMatched = true
-						break
-					}
-				}
-
-				if !senderMatched {
-					continue
-				}
-
-				// 解析验证码
-				if extractedCode := t.extractVerificationCode(msg.Message); extractedCode != "" {
-					return extractedCode, msgSender, msgTime, true
+				if m, ok := t.matchMessage(msg, senders, startTime); ok {
+					return m.code, m.sender, m.receivedAt, true
 				}
 			}
 		}
@@ -1092,79 +1845,55 @@ func (t *VerifyCodeTask) searchVerifyCode(dialogs tg.MessagesDialogsClass, sende
 	return "", "", time.Time{}, false
 }
 
-// extractVerificationCode 从消息文本中提取验证码
-func (t *VerifyCodeTask) extractVerificationCode(message string) string {
-	// 常见的验证码模式
-	patterns := []string{
-		"code", "verification", "verify", "login", "telegram",
-		"验证码", "验证", "登录", "代码",
+// matchMessage 检查消息是否为任务开始后白名单发送者发来的验证码
+func (t *VerifyCodeTask) matchMessage(msg *tg.Message, senders []string, startTime time.Time) (verifyCodeMatch, bool) {
+	// 检查消息时间是否在任务开始后
+	msgTime := time.Unix(int64(msg.Date), 0)
+	if msgTime.Before(startTime) {
+		return verifyCodeMatch{}, false
 	}
 
-	// 简单的数字提取逻辑 (4-8位数字)
-	var digits []rune
-	for _, char := range message {
-		if char >= '0' && char <= '9' {
-			digits = append(digits, char)
-		}
-	}
+	return t.matchText(msg.Message, verifyCodeSender(msg), msgTime, senders)
+}
 
-	// 检查是否包含验证码关键词
-	messageContainsPattern := false
-	for _, pattern := range patterns {
-		if t.containsIgnoreCase(message, pattern) {
-			messageContainsPattern = true
-			break
+// verifyCodeSender 返回消息发送者ID，私聊消息的 FromID 为空，发送者为对话用户
+// 推送的消息对象可能被其他处理器共用，这里只读取不修改
+func verifyCodeSender(msg *tg.Message) string {
+	if msg.FromID != nil {
+		if peerUser, ok := msg.FromID.(*tg.PeerUser); ok {
+			return fmt.Sprintf("%d", peerUser.UserID)
 		}
+		return ""
 	}
-
-	// 如果包含关键词且数字长度合适
-	if messageContainsPattern && len(digits) >= 4 && len(digits) <= 8 {
-		return string(digits)
+	if peerUser, ok := msg.PeerID.(*tg.PeerUser); ok {
+		return fmt.Sprintf("%d", peerUser.UserID)
 	}
-
-	return ""
+	return "777000" // Telegram系统消息
 }
 
-// containsIgnoreCase 不区分大小写的包含检查
-func (t *VerifyCodeTask) containsIgnoreCase(text, pattern string) bool {
-	textLower := t.toLowerCase(text)
-	patternLower := t.toLowerCase(pattern)
-
-	return t.contains(textLower, patternLower)
-}
-
-// toLowerCase 转换为小写
-func (t *VerifyCodeTask) toLowerCase(s string) string {
-	result := make([]rune, len(s))
-	for i, r := range s {
-		if r >= 'A' && r <= 'Z' {
-			result[i] = r + 32
-		} else {
-			result[i] = r
+// matchText 发送者在白名单中且文本包含验证码时返回匹配结果
+func (t *VerifyCodeTask) matchText(text, msgSender string, msgTime time.Time, senders []string) (verifyCodeMatch, bool) {
+	senderMatched := false
+	for _, allowedSender := range senders {
+		if msgSender == allowedSender {
+			senderMatched = true
+			break
 		}
 	}
-	return string(result)
-}
-
-// contains 检查字符串是否包含子字符串
-func (t *VerifyCodeTask) contains(s, substr string) bool {
-	if len(substr) > len(s) {
-		return false
+	if !senderMatched {
+		return verifyCodeMatch{}, false
 	}
 
-	for i := 0; i <= len(s)-len(substr); i++ {
-		match := true
-		for j := 0; j < len(substr); j++ {
-			if s[i+j] != substr[j] {
-				match = false
-				break
-			}
-		}
-		if match {
-			return true
-		}
+	// 解析验证码
+	if extractedCode := t.extractVerificationCode(text); extractedCode != "" {
+		return verifyCodeMatch{code: extractedCode, sender: msgSender, receivedAt: msgTime}, true
 	}
-	return false
+	return verifyCodeMatch{}, false
+}
+
+// extractVerificationCode 从消息文本中提取验证码
+func (t *VerifyCodeTask) extractVerificationCode(message string) string {
+	return ExtractVerificationCode(message)
 }
 
 // GetType 获取任务类型
@@ -1172,14 +1901,26 @@ func (t *VerifyCodeTask) GetType() string {
 	return "verify_code"
 }
 
+// GroupChatResponder 群聊回复生成接口 (本地定义以避免循环引用)
+type GroupChatResponder interface {
+	GenerateGroupChatReply(ctx context.Context, req *models.GroupChatReplyRequest) (string, error)
+}
+
 // GroupChatTask AI炒群任务
 type GroupChatTask struct {
-	task *models.Task
+	peerResolution
+	task      *models.Task
+	aiService GroupChatResponder // 为 nil 或调用失败时使用内置回复
+	rnd       *rand.Rand         // 回复概率判断和内置回复选择
 }
 
 // NewGroupChatTask 创建AI炒群任务
-func NewGroupChatTask(task *models.Task) *GroupChatTask {
-	return &GroupChatTask{task: task}
+func NewGroupChatTask(task *models.Task, aiService GroupChatResponder) *GroupChatTask {
+	return &GroupChatTask{
+		task:      task,
+		aiService: aiService,
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
 }
 
 // Execute 执行AI炒群
@@ -1209,29 +1950,23 @@ func (t *GroupChatTask) Execute(ctx context.Context, api *tg.Client) error {
 	var inputPeer tg.InputPeerClass
 	var targetGroupName string
 
-	if groupID, ok := config["group_id"].(float64); ok && groupID > 0 {
-		inputPeer = &tg.InputPeerChat{ChatID: int64(groupID)}
+	if groupID, ok := config["group_id"].(float64); ok && groupID != 0 {
 		targetGroupName = fmt.Sprintf("ID: %d", int64(groupID))
+		peer, err := t.resolveTarget(ctx, api, strconv.FormatInt(int64(groupID), 10))
+		if err != nil {
+			addLog(fmt.Sprintf("无法解析群组 %s: %v", targetGroupName, err))
+			return fmt.Errorf("failed to resolve group: %w", err)
+		}
+		inputPeer = peer
 	} else if groupName, ok := config["group_name"].(string); ok && groupName != "" {
 		targetGroupName = groupName
 		// 解析群组用户名
-		resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
-			Username: groupName,
-		})
+		peer, err := t.resolveTarget(ctx, api, groupName)
 		if err != nil {
 			addLog(fmt.Sprintf("无法解析群组 %s: %v", groupName, err))
 			return fmt.Errorf("failed to resolve group: %w", err)
 		}
-		if len(resolved.Chats) > 0 {
-			if chat, ok := resolved.Chats[0].(*tg.Chat); ok {
-				inputPeer = &tg.InputPeerChat{ChatID: chat.ID}
-			} else if channel, ok := resolved.Chats[0].(*tg.Channel); ok {
-				inputPeer = &tg.InputPeerChannel{
-					ChannelID:  channel.ID,
-					AccessHash: channel.AccessHash,
-				}
-			}
-		}
+		inputPeer = peer
 	} else {
 		return fmt.Errorf("missing group_id or group_name configuration")
 	}
@@ -1276,16 +2011,17 @@ func (t *GroupChatTask) Execute(ctx context.Context, api *tg.Client) error {
 		return fmt.Errorf("failed to get chat history: %w", err)
 	}
 
-	// 分析群聊上下文并可能发送回复
-	if messages, ok := history.(*tg.MessagesMessages); ok {
-		addLog(fmt.Sprintf("获取到 %d 条历史消息，正在分析...", len(messages.Messages)))
-		for _, msg := range messages.Messages {
+	// 分析群聊上下文并可能发送回复（超级群返回 MessagesChannelMessages）
+	if messages, ok := history.AsModified(); ok {
+		addLog(fmt.Sprintf("获取到 %d 条历史消息，正在分析...", len(messages.GetMessages())))
+		chatHistory := groupChatHistory(messages)
+		for _, msg := range messages.GetMessages() {
 			if message, ok := msg.(*tg.Message); ok {
 				messagesProcessed++
 
 				// 简单的回复逻辑 - 如果消息包含关键词且随机数允许
 				if t.shouldRespondSimple(message, aiConfig) {
-					response := t.generateSimpleAIResponse(message, aiConfig)
+					response := t.generateResponse(ctx, message, aiConfig, chatHistory, targetGroupName, addLog)
 					if response != "" {
 						addLog(fmt.Sprintf("触发回复规则 (原文: %s...)", t.truncateString(message.Message, 20)))
 						_, err = api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
@@ -1339,7 +2075,7 @@ func (t *GroupChatTask) shouldRespondSimple(msg *tg.Message, aiConfig map[string
 	}
 
 	// 基础概率检查
-	if t.simpleRandom() > responseRate {
+	if t.rnd.Float64() > responseRate {
 		return false
 	}
 
@@ -1354,12 +2090,84 @@ func (t *GroupChatTask) shouldRespondSimple(msg *tg.Message, aiConfig map[string
 			}
 		}
 		// 如果有关键词配置但都不匹配，降低概率
-		return t.simpleRandom() < 0.1
+		return t.rnd.Float64() < 0.1
 	}
 
 	return true
 }
 
+// generateResponse 调用 AI 服务根据最近聊天和 ai_config 中的人设生成回复，失败时使用内置回复
+func (t *GroupChatTask) generateResponse(ctx context.Context, msg *tg.Message, aiConfig map[string]interface{}, history []models.ChatMessage, groupName string, addLog func(string)) string {
+	if t.aiService == nil {
+		return t.generateSimpleAIResponse(msg, aiConfig)
+	}
+
+	req := &models.GroupChatReplyRequest{
+		GroupName:    groupName,
+		ResponseType: "casual",
+		MaxLength:    50,
+		ChatHistory:  history,
+	}
+	if v, ok := aiConfig["persona"].(string); ok && v != "" {
+		req.Persona = v
+	} else if v, ok := aiConfig["personality"].(string); ok {
+		req.Persona = v
+	}
+	if v, ok := aiConfig["topic"].(string); ok {
+		req.GroupTopic = v
+	}
+	if v, ok := aiConfig["response_type"].(string); ok && v != "" {
+		req.ResponseType = v
+	}
+	if v, ok := aiConfig["max_length"].(float64); ok && v > 0 {
+		req.MaxLength = int(v)
+	}
+	// 任务配置已在创建时校验，这里解析失败时按默认参数生成
+	req.AISampling, _ = models.AISamplingFromConfig(t.task.Config)
+	req.PostProcess, _ = models.AIPostProcessFromConfig(t.task.Config)
+
+	reply, err := t.aiService.GenerateGroupChatReply(ctx, req)
+	if reply = strings.TrimSpace(reply); err != nil || reply == "" {
+		addLog(fmt.Sprintf("AI 生成回复失败，使用内置回复: %v", err))
+		return t.generateSimpleAIResponse(msg, aiConfig)
+	}
+	return reply
+}
+
+// groupChatHistory 将历史消息转换为按时间先后排列的聊天记录，供 AI 生成回复
+func groupChatHistory(messages tg.ModifiedMessagesMessages) []models.ChatMessage {
+	names := make(map[int64]string)
+	bots := make(map[int64]bool)
+	for _, u := range messages.GetUsers() {
+		if user, ok := u.(*tg.User); ok {
+			name := user.Username
+			if name == "" {
+				name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			}
+			names[user.ID] = name
+			bots[user.ID] = user.Bot
+		}
+	}
+
+	list := messages.GetMessages()
+	history := make([]models.ChatMessage, 0, len(list))
+	// 历史消息为新消息在前，倒序遍历
+	for i := len(list) - 1; i >= 0; i-- {
+		msg, ok := list[i].(*tg.Message)
+		if !ok || msg.Message == "" {
+			continue
+		}
+		entry := models.ChatMessage{Message: msg.Message, Timestamp: time.Unix(int64(msg.Date), 0)}
+		if from, ok := msg.FromID.(*tg.PeerUser); ok {
+			entry.UserID = from.UserID
+			entry.Username = names[from.UserID]
+			entry.IsBot = bots[from.UserID]
+		}
+		history = append(history, entry)
+	}
+	return history
+}
+
 // generateSimpleAIResponse 生成简单的AI回复
 func (t *GroupChatTask) generateSimpleAIResponse(msg *tg.Message, aiConfig map[string]interface{}) string {
 	personality := "friendly"
@@ -1374,43 +2182,31 @@ func (t *GroupChatTask) generateSimpleAIResponse(msg *tg.Message, aiConfig map[s
 	// 根据消息内容选择回复
 	if t.contains(msgLower, "hello") || t.contains(msgLower, "hi") || t.contains(msgLower, "你好") {
 		responses := []string{"Hello there! 👋", "Hi! How's everyone? 😊", "Hey! 🙋‍♂️"}
-		return responses[t.simpleRandomInt(len(responses))]
+		return responses[t.rnd.Intn(len(responses))]
 	}
 
 	if t.contains(msgLower, "thank") || t.contains(msgLower, "谢谢") || t.contains(msgLower, "thx") {
 		responses := []string{"You're welcome! 😊", "No problem! 👍", "Happy to help! 🤝"}
-		return responses[t.simpleRandomInt(len(responses))]
+		return responses[t.rnd.Intn(len(responses))]
 	}
 
 	if t.contains(msgLower, "?") || t.contains(msgLower, "？") || t.contains(msgLower, "问") {
 		responses := []string{"That's a good question! 🤔", "Interesting point! 💭", "Let me think about that... 🧠"}
-		return responses[t.simpleRandomInt(len(responses))]
+		return responses[t.rnd.Intn(len(responses))]
 	}
 
 	// 根据个性选择默认回复
 	switch personality {
 	case "friendly":
 		responses := []string{"I agree! 👌", "That's so true! ✨", "Absolutely! 💯", "Makes sense! 🎯"}
-		return responses[t.simpleRandomInt(len(responses))]
+		return responses[t.rnd.Intn(len(responses))]
 	case "professional":
 		responses := []string{"I concur.", "That's correct.", "Understood.", "Good point."}
-		return responses[t.simpleRandomInt(len(responses))]
+		return responses[t.rnd.Intn(len(responses))]
 	default:
 		responses := []string{"👍", "😊", "Indeed", "Right!", "Cool! 😎"}
-		return responses[t.simpleRandomInt(len(responses))]
-	}
-}
-
-// 简单的随机数函数
-func (t *GroupChatTask) simpleRandom() float64 {
-	return float64((time.Now().UnixNano() % 100)) / 100.0
-}
-
-func (t *GroupChatTask) simpleRandomInt(max int) int {
-	if max <= 0 {
-		return 0
+		return responses[t.rnd.Intn(len(responses))]
 	}
-	return int(time.Now().UnixNano() % int64(max))
 }
 
 // containsIgnoreCase 不区分大小写的包含检查 (GroupChatTask版本)
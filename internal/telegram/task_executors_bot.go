@@ -0,0 +1,370 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"tg_cloud_server/internal/models"
+
+	"github.com/gotd/td/tg"
+)
+
+// BotInteractionTask 通用机器人交互任务：启动机器人、等待消息、按文本点击按钮并记录完整交互过程
+// 用于与接码等服务类机器人交互，SpamBot 检查是其特例
+type BotInteractionTask struct {
+	task *models.Task
+
+	peer       tg.InputPeerClass
+	transcript []map[string]interface{}
+}
+
+// NewBotInteractionTask 创建机器人交互任务
+func NewBotInteractionTask(task *models.Task) *BotInteractionTask {
+	return &BotInteractionTask{task: task}
+}
+
+// Execute 执行机器人交互
+// 配置: bot_username 机器人用户名, start_param 启动参数, buttons 依次点击的按钮文本, timeout_seconds 每步等待超时
+func (t *BotInteractionTask) Execute(ctx context.Context, api *tg.Client) error {
+	config := t.task.Config
+	if config == nil {
+		return fmt.Errorf("task config is nil")
+	}
+
+	var logs []string
+	if t.task.Result == nil {
+		t.task.Result = make(models.TaskResult)
+	}
+
+	addLog := func(msg string) {
+		logEntry := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
+		logs = append(logs, logEntry)
+		t.task.Result["logs"] = logs
+	}
+
+	botUsername, _ := config["bot_username"].(string)
+	botUsername = strings.TrimPrefix(strings.TrimSpace(botUsername), "@")
+	if botUsername == "" {
+		return fmt.Errorf("missing bot_username configuration")
+	}
+	startParam, _ := config["start_param"].(string)
+
+	var buttons []string
+	if list, ok := config["buttons"].([]interface{}); ok {
+		for _, b := range list {
+			if text, ok := b.(string); ok && strings.TrimSpace(text) != "" {
+				buttons = append(buttons, strings.TrimSpace(text))
+			}
+		}
+	}
+
+	timeoutSec := 30 // 默认每步等待30秒
+	if timeout, exists := config["timeout_seconds"]; exists {
+		if timeoutFloat, ok := timeout.(float64); ok && timeoutFloat > 0 {
+			timeoutSec = int(timeoutFloat)
+		}
+	}
+	timeout := time.Duration(timeoutSec) * time.Second
+
+	t.transcript = nil
+	defer func() {
+		t.task.Result["transcript"] = t.transcript
+	}()
+
+	addLog(fmt.Sprintf("开始与机器人 @%s 交互，待点击按钮: %v", botUsername, buttons))
+
+	botUser, err := t.resolveBot(ctx, api, botUsername)
+	if err != nil {
+		addLog(fmt.Sprintf("解析机器人失败: %v", err))
+		return err
+	}
+
+	// 记录交互开始前的最新消息ID，只处理之后的新消息
+	lastID, err := t.latestMessageID(ctx, api)
+	if err != nil {
+		addLog(fmt.Sprintf("获取对话历史失败: %v", err))
+		return fmt.Errorf("failed to get bot history: %w", err)
+	}
+
+	// 启动机器人
+	_, err = api.MessagesStartBot(ctx, &tg.MessagesStartBotRequest{
+		Bot:        botUser,
+		Peer:       t.peer,
+		RandomID:   time.Now().UnixNano(),
+		StartParam: startParam,
+	})
+	if err != nil {
+		// StartBot 失败时退化为直接发送 /start
+		_, err = api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+			Peer:     t.peer,
+			Message:  strings.TrimSpace("/start " + startParam),
+			RandomID: time.Now().UnixNano(),
+		})
+		if err != nil {
+			addLog(fmt.Sprintf("启动机器人失败: %v", err))
+			return fmt.Errorf("failed to start bot: %w", err)
+		}
+	}
+	t.record("out", strings.TrimSpace("/start "+startParam), nil)
+	addLog("已发送 /start，等待机器人回复...")
+
+	// 依次等待包含目标按钮的消息并点击
+	var current *tg.Message
+	for i, buttonText := range buttons {
+		msg, err := t.waitForMessage(ctx, api, lastID, current, buttonText, timeout)
+		if err != nil {
+			addLog(fmt.Sprintf("等待按钮 [%s] 超时: %v", buttonText, err))
+			t.task.Result["status"] = "timeout"
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if msg.ID > lastID {
+			lastID = msg.ID
+		}
+		current = msg
+
+		if err := t.clickButton(ctx, api, msg, buttonText, addLog); err != nil {
+			addLog(fmt.Sprintf("点击按钮 [%s] 失败: %v", buttonText, err))
+			t.task.Result["status"] = "failed"
+			return fmt.Errorf("step %d: failed to click %q: %w", i+1, buttonText, err)
+		}
+	}
+
+	// 捕获最终结果消息
+	final, err := t.waitForMessage(ctx, api, lastID, current, "", timeout)
+	if err != nil {
+		addLog(fmt.Sprintf("未收到最终回复: %v", err))
+		t.task.Result["status"] = "timeout"
+		return fmt.Errorf("no final response from bot: %w", err)
+	}
+
+	t.task.Result["final_message"] = final.Message
+	t.task.Result["status"] = "success"
+	t.task.Result["executed_at"] = time.Now().Unix()
+	addLog(fmt.Sprintf("交互完成，最终回复: %s", final.Message))
+
+	return nil
+}
+
+// resolveBot 解析机器人用户名
+func (t *BotInteractionTask) resolveBot(ctx context.Context, api *tg.Client, username string) (*tg.InputUser, error) {
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{
+		Username: username,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve bot %s: %w", username, err)
+	}
+
+	for _, u := range resolved.Users {
+		if user, ok := u.(*tg.User); ok && user.Bot {
+			t.peer = &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}
+			return &tg.InputUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a bot", username)
+}
+
+// latestMessageID 获取与机器人对话中的最新消息ID
+func (t *BotInteractionTask) latestMessageID(ctx context.Context, api *tg.Client) (int, error) {
+	messages, err := t.recentMessages(ctx, api, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) > 0 {
+		return messages[0].ID, nil
+	}
+	return 0, nil
+}
+
+// recentMessages 获取与机器人对话的最近消息（新消息在前）
+func (t *BotInteractionTask) recentMessages(ctx context.Context, api *tg.Client, limit int) ([]*tg.Message, error) {
+	history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+		Peer:  t.peer,
+		Limit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	modified, ok := history.AsModified()
+	if !ok {
+		return nil, nil
+	}
+
+	var messages []*tg.Message
+	for _, m := range modified.GetMessages() {
+		if msg, ok := m.(*tg.Message); ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+// waitForMessage 等待机器人发来新消息或编辑上一条消息
+// buttonText 非空时要求消息中包含匹配的按钮
+func (t *BotInteractionTask) waitForMessage(ctx context.Context, api *tg.Client, afterID int, previous *tg.Message, buttonText string, timeout time.Duration) (*tg.Message, error) {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			if buttonText != "" {
+				return nil, fmt.Errorf("timeout waiting for button %q", buttonText)
+			}
+			return nil, fmt.Errorf("timeout waiting for bot message")
+		case <-ticker.C:
+			messages, err := t.recentMessages(ctx, api, 10)
+			if err != nil {
+				continue
+			}
+
+			// 从旧到新检查，保证按顺序记录
+			for i := len(messages) - 1; i >= 0; i-- {
+				msg := messages[i]
+				if msg.Out {
+					continue
+				}
+
+				isNew := msg.ID > afterID
+				isEdited := previous != nil && msg.ID == previous.ID && msg.EditDate > previous.EditDate
+				if !isNew && !isEdited {
+					continue
+				}
+
+				if buttonText != "" && !t.hasButton(msg, buttonText) {
+					continue
+				}
+
+				direction := "in"
+				if isEdited && !isNew {
+					direction = "edited"
+				}
+				t.record(direction, msg.Message, t.buttonTexts(msg))
+				return msg, nil
+			}
+		}
+	}
+}
+
+// clickButton 点击消息中匹配的按钮，内联按钮通过回调应答，普通键盘按钮以发送文本的方式点击
+func (t *BotInteractionTask) clickButton(ctx context.Context, api *tg.Client, msg *tg.Message, buttonText string, addLog func(string)) error {
+	switch markup := msg.ReplyMarkup.(type) {
+	case *tg.ReplyInlineMarkup:
+		for _, row := range markup.Rows {
+			for _, button := range row.Buttons {
+				callback, ok := button.(*tg.KeyboardButtonCallback)
+				if !ok || !matchButton(callback.Text, buttonText) {
+					continue
+				}
+
+				answer, err := api.MessagesGetBotCallbackAnswer(ctx, &tg.MessagesGetBotCallbackAnswerRequest{
+					Peer:  t.peer,
+					MsgID: msg.ID,
+					Data:  callback.Data,
+				})
+				t.record("click", callback.Text, nil)
+				if err != nil {
+					return err
+				}
+
+				addLog(fmt.Sprintf("已点击按钮: %s", callback.Text))
+				if answer.Message != "" {
+					t.record("callback_answer", answer.Message, nil)
+					addLog(fmt.Sprintf("按钮回调应答: %s", answer.Message))
+				}
+				return nil
+			}
+		}
+	case *tg.ReplyKeyboardMarkup:
+		for _, row := range markup.Rows {
+			for _, button := range row.Buttons {
+				if !matchButton(button.GetText(), buttonText) {
+					continue
+				}
+
+				_, err := api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+					Peer:     t.peer,
+					Message:  button.GetText(),
+					RandomID: time.Now().UnixNano(),
+				})
+				t.record("click", button.GetText(), nil)
+				if err != nil {
+					return err
+				}
+				addLog(fmt.Sprintf("已点击键盘按钮: %s", button.GetText()))
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("button %q not found", buttonText)
+}
+
+// hasButton 判断消息中是否包含匹配的可点击按钮
+func (t *BotInteractionTask) hasButton(msg *tg.Message, buttonText string) bool {
+	switch markup := msg.ReplyMarkup.(type) {
+	case *tg.ReplyInlineMarkup:
+		for _, row := range markup.Rows {
+			for _, button := range row.Buttons {
+				if callback, ok := button.(*tg.KeyboardButtonCallback); ok && matchButton(callback.Text, buttonText) {
+					return true
+				}
+			}
+		}
+	case *tg.ReplyKeyboardMarkup:
+		for _, row := range markup.Rows {
+			for _, button := range row.Buttons {
+				if matchButton(button.GetText(), buttonText) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// buttonTexts 提取消息中所有按钮的文本，用于记录交互过程
+func (t *BotInteractionTask) buttonTexts(msg *tg.Message) []string {
+	var rows []tg.KeyboardButtonRow
+	switch markup := msg.ReplyMarkup.(type) {
+	case *tg.ReplyInlineMarkup:
+		rows = markup.Rows
+	case *tg.ReplyKeyboardMarkup:
+		rows = markup.Rows
+	}
+
+	var texts []string
+	for _, row := range rows {
+		for _, button := range row.Buttons {
+			texts = append(texts, button.GetText())
+		}
+	}
+	return texts
+}
+
+// record 记录一条交互
+func (t *BotInteractionTask) record(direction, text string, buttons []string) {
+	entry := map[string]interface{}{
+		"direction": direction,
+		"text":      text,
+		"time":      time.Now().Format("15:04:05"),
+	}
+	if len(buttons) > 0 {
+		entry["buttons"] = buttons
+	}
+	t.transcript = append(t.transcript, entry)
+}
+
+// matchButton 按钮文本匹配（忽略大小写的包含匹配）
+func matchButton(text, want string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(want))
+}
+
+// GetType 获取任务类型
+func (t *BotInteractionTask) GetType() string {
+	return "bot_interaction"
+}
@@ -0,0 +1,234 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg_cloud_server/internal/models"
+
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/tg"
+)
+
+// ClearHistoryTask 清空账号对话记录任务，用于账号转售前的隐私清理
+type ClearHistoryTask struct {
+	task *models.Task
+}
+
+// NewClearHistoryTask 创建清空对话记录任务
+func NewClearHistoryTask(task *models.Task) *ClearHistoryTask {
+	return &ClearHistoryTask{task: task}
+}
+
+// Execute 遍历账号所有对话并逐个清空历史记录
+func (t *ClearHistoryTask) Execute(ctx context.Context, api *tg.Client) error {
+	var logs []string
+	if t.task.Result == nil {
+		t.task.Result = make(models.TaskResult)
+	}
+
+	addLog := func(msg string) {
+		logEntry := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
+		logs = append(logs, logEntry)
+		t.task.Result["logs"] = logs
+	}
+
+	config := t.task.Config
+
+	// 是否同时删除对方的消息（仅私聊和普通群有效）
+	revoke, _ := config["revoke"].(bool)
+
+	intervalSec := 1 // 默认1秒间隔
+	if interval, exists := config["interval_seconds"]; exists {
+		if intervalFloat, ok := interval.(float64); ok {
+			intervalSec = int(intervalFloat)
+		}
+	}
+
+	// 需要保留的对话（用户名或ID）
+	preserve := make(map[string]bool)
+	if peers, ok := config["preserve_peers"].([]interface{}); ok {
+		for _, p := range peers {
+			switch v := p.(type) {
+			case string:
+				v = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(v), "@"))
+				if v != "" {
+					preserve[v] = true
+				}
+			case float64:
+				preserve[strconv.FormatInt(int64(v), 10)] = true
+			}
+		}
+	}
+
+	addLog("正在获取对话列表...")
+
+	// 先完整获取对话列表，避免边删除边分页导致遗漏
+	var elems []dialogs.Elem
+	err := dialogs.NewQueryBuilder(api).GetDialogs().BatchSize(100).ForEach(ctx, func(ctx context.Context, elem dialogs.Elem) error {
+		elems = append(elems, elem)
+		return nil
+	})
+	if err != nil {
+		addLog(fmt.Sprintf("获取对话列表失败: %v", err))
+		return fmt.Errorf("failed to get dialogs: %w", err)
+	}
+
+	addLog(fmt.Sprintf("共 %d 个对话，revoke: %v，间隔: %d秒，保留对话数: %d", len(elems), revoke, intervalSec, len(preserve)))
+
+	clearedCount := 0
+	preservedCount := 0
+	skippedCount := 0
+	failedCount := 0
+
+	for i, elem := range elems {
+		if ctx.Err() != nil {
+			addLog("任务已取消，停止清理")
+			break
+		}
+
+		name, keys := t.describePeer(elem)
+		if t.isPreserved(keys, preserve) {
+			preservedCount++
+			addLog(fmt.Sprintf("保留对话: %s", name))
+			continue
+		}
+
+		if i > 0 && intervalSec > 0 {
+			select {
+			case <-ctx.Done():
+				addLog("任务已取消，停止清理")
+			case <-time.After(time.Duration(intervalSec) * time.Second):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		var clearErr error
+		switch peer := elem.Peer.(type) {
+		case *tg.InputPeerChannel:
+			channel, ok := elem.Entities.Channel(peer.ChannelID)
+			if ok && channel.Broadcast {
+				// 频道无法清空历史，只能退出
+				skippedCount++
+				addLog(fmt.Sprintf("跳过频道: %s (频道不支持清空历史)", name))
+				continue
+			}
+			clearErr = t.clearChannelHistory(ctx, api, peer)
+		default:
+			clearErr = t.clearHistory(ctx, api, elem.Peer, revoke)
+		}
+
+		if clearErr != nil {
+			failedCount++
+			addLog(fmt.Sprintf("清空失败: %s: %v", name, clearErr))
+			continue
+		}
+
+		clearedCount++
+		addLog(fmt.Sprintf("已清空: %s", name))
+	}
+
+	t.task.Result["total_dialogs"] = len(elems)
+	t.task.Result["cleared_count"] = clearedCount
+	t.task.Result["preserved_count"] = preservedCount
+	t.task.Result["skipped_count"] = skippedCount
+	t.task.Result["failed_count"] = failedCount
+	t.task.Result["executed_at"] = time.Now().Unix()
+
+	addLog(fmt.Sprintf("清理完成: 清空 %d，保留 %d，跳过 %d，失败 %d", clearedCount, preservedCount, skippedCount, failedCount))
+
+	if clearedCount == 0 && failedCount > 0 {
+		t.task.Result["status"] = "failed"
+		return fmt.Errorf("failed to clear any dialog history")
+	}
+
+	t.task.Result["status"] = "success"
+	return nil
+}
+
+// clearHistory 清空私聊/普通群历史，服务端每次只删除一部分，需循环直到 offset 为0
+func (t *ClearHistoryTask) clearHistory(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, revoke bool) error {
+	for {
+		affected, err := api.MessagesDeleteHistory(ctx, &tg.MessagesDeleteHistoryRequest{
+			Peer:   peer,
+			Revoke: revoke,
+		})
+		if err != nil {
+			return err
+		}
+		if affected.Offset <= 0 {
+			return nil
+		}
+	}
+}
+
+// clearChannelHistory 清空超级群中自己可见的历史记录
+func (t *ClearHistoryTask) clearChannelHistory(ctx context.Context, api *tg.Client, peer *tg.InputPeerChannel) error {
+	_, err := api.ChannelsDeleteHistory(ctx, &tg.ChannelsDeleteHistoryRequest{
+		Channel: &tg.InputChannel{
+			ChannelID:  peer.ChannelID,
+			AccessHash: peer.AccessHash,
+		},
+	})
+	return err
+}
+
+// describePeer 返回对话的展示名称以及用于匹配保留列表的标识（ID、用户名）
+func (t *ClearHistoryTask) describePeer(elem dialogs.Elem) (string, []string) {
+	switch peer := elem.Peer.(type) {
+	case *tg.InputPeerSelf:
+		return "收藏夹", []string{"self"}
+	case *tg.InputPeerUser:
+		keys := []string{strconv.FormatInt(peer.UserID, 10)}
+		name := fmt.Sprintf("用户 %d", peer.UserID)
+		if user, ok := elem.Entities.User(peer.UserID); ok {
+			if user.Username != "" {
+				keys = append(keys, strings.ToLower(user.Username))
+				name = fmt.Sprintf("用户 @%s", user.Username)
+			} else if user.FirstName != "" {
+				name = fmt.Sprintf("用户 %s", user.FirstName)
+			}
+		}
+		return name, keys
+	case *tg.InputPeerChat:
+		keys := []string{strconv.FormatInt(peer.ChatID, 10)}
+		name := fmt.Sprintf("群组 %d", peer.ChatID)
+		if chat, ok := elem.Entities.Chat(peer.ChatID); ok && chat.Title != "" {
+			name = fmt.Sprintf("群组 %s", chat.Title)
+		}
+		return name, keys
+	case *tg.InputPeerChannel:
+		keys := []string{strconv.FormatInt(peer.ChannelID, 10)}
+		name := fmt.Sprintf("超级群 %d", peer.ChannelID)
+		if channel, ok := elem.Entities.Channel(peer.ChannelID); ok {
+			if channel.Username != "" {
+				keys = append(keys, strings.ToLower(channel.Username))
+			}
+			if channel.Title != "" {
+				name = fmt.Sprintf("超级群 %s", channel.Title)
+			}
+		}
+		return name, keys
+	}
+	return "未知对话", nil
+}
+
+// isPreserved 判断对话是否在保留列表中
+func (t *ClearHistoryTask) isPreserved(keys []string, preserve map[string]bool) bool {
+	for _, key := range keys {
+		if preserve[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetType 获取任务类型
+func (t *ClearHistoryTask) GetType() string {
+	return "clear_history"
+}
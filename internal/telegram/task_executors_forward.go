@@ -0,0 +1,233 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"tg_cloud_server/internal/models"
+
+	"github.com/gotd/td/tg"
+)
+
+// 转发来源署名
+const (
+	ForwardAttributionPreserved = "preserved" // 显示 "Forwarded from X"
+	ForwardAttributionHidden    = "hidden"    // 以原创消息形式出现
+)
+
+// ForwardMessageTask 将来源会话中的消息转发到多个目标会话
+// drop_author 隐藏转发来源，drop_media_captions 同时去掉媒体说明文字
+type ForwardMessageTask struct {
+	task *models.Task
+}
+
+// NewForwardMessageTask 创建消息转发任务
+func NewForwardMessageTask(task *models.Task) *ForwardMessageTask {
+	return &ForwardMessageTask{task: task}
+}
+
+// Execute 解析来源会话后逐个目标转发，单个目标失败不影响其他目标
+func (t *ForwardMessageTask) Execute(ctx context.Context, api *tg.Client) error {
+	var logs []string
+	if t.task.Result == nil {
+		t.task.Result = make(models.TaskResult)
+	}
+
+	addLog := func(msg string) {
+		logEntry := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
+		logs = append(logs, logEntry)
+		t.task.Result["logs"] = logs
+	}
+
+	config := t.task.Config
+	if config == nil {
+		return fmt.Errorf("task config is nil")
+	}
+
+	source, _ := config["source"].(string)
+	if strings.TrimSpace(source) == "" {
+		return fmt.Errorf("invalid or empty source configuration")
+	}
+
+	var messageIDs []int
+	if ids, ok := config["message_ids"].([]interface{}); ok {
+		for _, id := range ids {
+			if v, ok := id.(float64); ok && v > 0 {
+				messageIDs = append(messageIDs, int(v))
+			}
+		}
+	}
+	if len(messageIDs) == 0 {
+		return fmt.Errorf("invalid or empty message_ids configuration")
+	}
+
+	targets, ok := config["targets"].([]interface{})
+	if !ok || len(targets) == 0 {
+		return fmt.Errorf("invalid or empty targets configuration")
+	}
+
+	dropAuthor, _ := config["drop_author"].(bool)
+	dropMediaCaptions, _ := config["drop_media_captions"].(bool)
+	// 去掉说明文字必须同时隐藏来源，Telegram 才会生效
+	if dropMediaCaptions {
+		dropAuthor = true
+	}
+
+	intervalSec := 2 // 默认2秒间隔
+	if interval, exists := config["interval_seconds"]; exists {
+		if intervalFloat, ok := interval.(float64); ok {
+			intervalSec = int(intervalFloat)
+		}
+	}
+
+	attribution := ForwardAttributionPreserved
+	if dropAuthor {
+		attribution = ForwardAttributionHidden
+	}
+	t.task.Result["drop_author"] = dropAuthor
+	t.task.Result["drop_media_captions"] = dropMediaCaptions
+	t.task.Result["attribution"] = attribution
+
+	fromPeer, err := t.resolvePeer(ctx, api, source)
+	if err != nil {
+		addLog(fmt.Sprintf("解析来源会话失败: %s: %v", source, err))
+		return fmt.Errorf("failed to resolve source: %w", err)
+	}
+
+	addLog(fmt.Sprintf("来源: %s，消息数: %d，目标数: %d，来源署名: %s", source, len(messageIDs), len(targets), attribution))
+
+	results := make(map[string]interface{}, len(targets))
+	successCount := 0
+	failedCount := 0
+
+	for i, target := range targets {
+		if ctx.Err() != nil {
+			addLog("任务已取消，停止转发")
+			break
+		}
+
+		targetStr, ok := target.(string)
+		if !ok || strings.TrimSpace(targetStr) == "" {
+			continue
+		}
+
+		if i > 0 && intervalSec > 0 {
+			time.Sleep(time.Duration(intervalSec) * time.Second)
+		}
+
+		forwarded, err := t.forward(ctx, api, fromPeer, targetStr, messageIDs, dropAuthor, dropMediaCaptions)
+		if err != nil {
+			failedCount++
+			results[targetStr] = map[string]interface{}{
+				"status": "failed",
+				"error":  err.Error(),
+			}
+			addLog(fmt.Sprintf("转发失败: %s: %v", targetStr, err))
+			continue
+		}
+
+		successCount++
+		results[targetStr] = map[string]interface{}{
+			"status":      "success",
+			"message_ids": forwarded,
+			"attribution": attribution,
+		}
+		addLog(fmt.Sprintf("已转发到 %s (%d 条)", targetStr, len(forwarded)))
+	}
+
+	t.task.Result["forward_results"] = results
+	t.task.Result["success_count"] = successCount
+	t.task.Result["failed_count"] = failedCount
+	t.task.Result["executed_at"] = time.Now().Unix()
+
+	addLog(fmt.Sprintf("转发完成: 成功 %d，失败 %d", successCount, failedCount))
+
+	if successCount == 0 && failedCount > 0 {
+		t.task.Result["status"] = "failed"
+		return fmt.Errorf("failed to forward to any target")
+	}
+
+	t.task.Result["status"] = "success"
+	return nil
+}
+
+// forward 转发到单个目标，返回目标会话中新消息的ID
+func (t *ForwardMessageTask) forward(ctx context.Context, api *tg.Client, fromPeer tg.InputPeerClass, target string, messageIDs []int, dropAuthor, dropMediaCaptions bool) ([]int, error) {
+	toPeer, err := t.resolvePeer(ctx, api, target)
+	if err != nil {
+		return nil, err
+	}
+
+	randomIDs := make([]int64, len(messageIDs))
+	base := time.Now().UnixNano()
+	for i := range randomIDs {
+		randomIDs[i] = base + int64(i)
+	}
+
+	updates, err := api.MessagesForwardMessages(ctx, &tg.MessagesForwardMessagesRequest{
+		FromPeer:          fromPeer,
+		ID:                messageIDs,
+		RandomID:          randomIDs,
+		ToPeer:            toPeer,
+		DropAuthor:        dropAuthor,
+		DropMediaCaptions: dropMediaCaptions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var forwarded []int
+	if u, ok := updates.(*tg.Updates); ok {
+		for _, update := range u.Updates {
+			if idUpdate, ok := update.(*tg.UpdateMessageID); ok {
+				forwarded = append(forwarded, idUpdate.ID)
+			}
+		}
+	}
+	return forwarded, nil
+}
+
+// resolvePeer 解析会话，支持 me（收藏夹）、用户名和公开链接
+func (t *ForwardMessageTask) resolvePeer(ctx context.Context, api *tg.Client, target string) (tg.InputPeerClass, error) {
+	if strings.EqualFold(strings.TrimSpace(target), "me") {
+		return &tg.InputPeerSelf{}, nil
+	}
+
+	username, _ := parseDeepLink(target)
+	if username == "" {
+		return nil, fmt.Errorf("invalid peer %q", target)
+	}
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: username})
+	if err != nil {
+		return nil, fmt.Errorf("resolve username failed: %w", err)
+	}
+
+	switch p := resolved.Peer.(type) {
+	case *tg.PeerUser:
+		for _, u := range resolved.Users {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}, nil
+			}
+		}
+	default:
+		for _, c := range resolved.Chats {
+			if c.GetID() != peerID(resolved.Peer) {
+				continue
+			}
+			switch chat := c.(type) {
+			case *tg.Chat:
+				return &tg.InputPeerChat{ChatID: chat.ID}, nil
+			case *tg.Channel:
+				return &tg.InputPeerChannel{ChannelID: chat.ID, AccessHash: chat.AccessHash}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("peer not found: %s", target)
+}
+
+// GetType 获取任务类型
+func (t *ForwardMessageTask) GetType() string {
+	return "forward_message"
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,17 +12,30 @@ import (
 	"github.com/gotd/td/tg"
 )
 
+// 单个群组的加群结果
+const (
+	JoinStatusJoined        = "joined"         // 新加入
+	JoinStatusAlreadyMember = "already_member" // 已是成员
+	JoinStatusRequested     = "requested"      // 已提交入群申请，等待管理员审核
+	JoinStatusFloodWait     = "flood_wait"     // 触发限流，本次执行停止，重新执行时从该群组继续
+	JoinStatusSkipped       = "skipped"        // 账号已达频道数量上限，未尝试加入
+	JoinStatusFailed        = "failed"
+)
+
 // JoinGroupTask 批量加群任务
 type JoinGroupTask struct {
-	task *models.Task
+	task      *models.Task
+	accountID string
 }
 
-// NewJoinGroupTask 创建批量加群任务
-func NewJoinGroupTask(task *models.Task) *JoinGroupTask {
-	return &JoinGroupTask{task: task}
+// NewJoinGroupTask 创建批量加群任务，加群进度按账号记录在任务结果中
+func NewJoinGroupTask(task *models.Task, accountID uint64) *JoinGroupTask {
+	return &JoinGroupTask{task: task, accountID: strconv.FormatUint(accountID, 10)}
 }
 
 // Execute 执行批量加群
+// 每个群组的结果累计在 group_results 中，进度按账号记录在 join_progress，
+// 重新执行时从上次停止的群组继续；账号达到频道数量上限后不再尝试剩余群组
 func (t *JoinGroupTask) Execute(ctx context.Context, api *tg.Client) error {
 	config := t.task.Config
 
@@ -56,90 +70,236 @@ func (t *JoinGroupTask) Execute(ctx context.Context, api *tg.Client) error {
 		}
 	}
 
-	addLog(fmt.Sprintf("开始执行批量加群任务，目标群组数: %d，间隔: %d秒", len(groups), intervalSec))
+	// 读取当前账号的进度，上次已全部完成时重新开始
+	startIndex, groupResults := t.loadProgress(len(groups))
+	if startIndex > 0 {
+		addLog(fmt.Sprintf("继续上次的加群进度，从第 %d/%d 个群组开始，间隔: %d秒", startIndex+1, len(groups), intervalSec))
+	} else {
+		addLog(fmt.Sprintf("开始执行批量加群任务，目标群组数: %d，间隔: %d秒", len(groups), intervalSec))
+	}
+	delete(t.task.Result, "channel_limit_reached")
+	delete(t.task.Result, "flood_wait_seconds")
 
-	successCount := 0
-	failedCount := 0
 	var errors []string
-	var joinedGroups []string
-	groupResults := make(map[string]interface{})
+	atChannelLimit := false
+	nextIndex := startIndex
 
 	// 遍历群组进行加入
-	for i, group := range groups {
+	for i := startIndex; i < len(groups); i++ {
+		group := groups[i]
+		groupStr := fmt.Sprintf("%v", group)
+
+		if atChannelLimit {
+			groupResults[groupStr] = map[string]interface{}{
+				"status": JoinStatusSkipped,
+				"reason": "channel_limit",
+			}
+			nextIndex = i + 1
+			continue
+		}
+
 		// 添加间隔（除了第一个）
-		if i > 0 && intervalSec > 0 {
-			time.Sleep(time.Duration(intervalSec) * time.Second)
+		if i > startIndex && intervalSec > 0 {
+			select {
+			case <-ctx.Done():
+				t.saveProgress(nextIndex, groupResults)
+				return ctx.Err()
+			case <-time.After(time.Duration(intervalSec) * time.Second):
+			}
 		}
 
-		groupStr, ok := group.(string)
+		groupName, ok := group.(string)
 		if !ok {
 			errorMsg := fmt.Sprintf("invalid group format: %v", group)
 			errors = append(errors, errorMsg)
-			failedCount++
+			groupResults[groupStr] = map[string]interface{}{
+				"status": JoinStatusFailed,
+				"reason": "invalid_format",
+				"error":  errorMsg,
+			}
+			nextIndex = i + 1
 			addLog(fmt.Sprintf("群组格式错误: %v", group))
 			continue
 		}
 
-		// 记录开始时间
-		startTime := time.Now()
-
 		// 执行加入逻辑
-		err := t.joinGroup(ctx, api, groupStr)
+		startTime := time.Now()
+		status, err := t.joinGroup(ctx, api, groupName)
 		duration := time.Since(startTime)
 
+		result := map[string]interface{}{
+			"status":   status,
+			"duration": duration.String(),
+			"at":       time.Now().Unix(),
+		}
 		if err != nil {
-			errorMsg := fmt.Sprintf("failed to join %s: %v", groupStr, err)
-			errors = append(errors, errorMsg)
-			groupResults[groupStr] = map[string]interface{}{
-				"status":   "failed",
-				"error":    err.Error(),
-				"duration": duration.String(),
+			result["error"] = err.Error()
+		}
+
+		switch {
+		case err == nil:
+			nextIndex = i + 1
+			switch status {
+			case JoinStatusAlreadyMember:
+				addLog(fmt.Sprintf("已是成员: %s", groupName))
+			case JoinStatusRequested:
+				addLog(fmt.Sprintf("已提交入群申请: %s", groupName))
+			default:
+				addLog(fmt.Sprintf("加入成功: %s", groupName))
 			}
-			failedCount++
-			addLog(fmt.Sprintf("加入失败 [%s]: %v", groupStr, err))
-		} else {
-			successCount++
+
+		case strings.Contains(err.Error(), "CHANNELS_TOO_MUCH"):
+			// 账号加入的频道已达上限，继续尝试只会逐个失败
+			atChannelLimit = true
+			nextIndex = i + 1
+			result["status"] = JoinStatusSkipped
+			result["reason"] = "channel_limit"
+			errors = append(errors, fmt.Sprintf("failed to join %s: %v", groupName, err))
+			addLog("账号已达到频道/群组数量上限 (CHANNELS_TOO_MUCH)，停止加入剩余群组")
+
+		default:
+			if wait, ok := FloodWaitDuration(err); ok {
+				// 限流时停止本次执行，保留进度，重新执行时从该群组继续
+				result["status"] = JoinStatusFloodWait
+				result["wait_seconds"] = int(wait.Seconds())
+				groupResults[groupName] = result
+				errors = append(errors, fmt.Sprintf("failed to join %s: %v", groupName, err))
+				t.task.Result["flood_wait_seconds"] = int(wait.Seconds())
+				addLog(fmt.Sprintf("加群触发限流 [%s]，需等待 %s，停止本次执行，剩余 %d 个群组待重新执行", groupName, wait, len(groups)-i))
+				t.saveProgress(nextIndex, groupResults)
+				t.writeResult(groups, groupResults, errors, addLog)
+				// 返回限流错误，由风控服务冷却账号，整体重试按服务端要求的时间等待
+				return fmt.Errorf("join group %s: %w", groupName, err)
+			}
+
+			nextIndex = i + 1
+			result["status"] = JoinStatusFailed
+			result["reason"] = ClassifyTargetError(err)
+			errors = append(errors, fmt.Sprintf("failed to join %s: %v", groupName, err))
+			addLog(fmt.Sprintf("加入失败 [%s]: %v", groupName, err))
+		}
+
+		groupResults[groupName] = result
+		t.saveProgress(nextIndex, groupResults)
+	}
+
+	t.saveProgress(nextIndex, groupResults)
+	if atChannelLimit {
+		t.task.Result["channel_limit_reached"] = true
+	}
+	t.writeResult(groups, groupResults, errors, addLog)
+
+	return nil
+}
+
+// loadProgress 读取当前账号上次执行的进度和群组结果
+func (t *JoinGroupTask) loadProgress(total int) (int, map[string]interface{}) {
+	groupResults := make(map[string]interface{})
+	progress, _ := t.task.Result["join_progress"].(map[string]interface{})
+	entry, _ := progress[t.accountID].(map[string]interface{})
+	if entry == nil {
+		return 0, groupResults
+	}
+
+	next := 0
+	switch v := entry["next_index"].(type) {
+	case float64:
+		next = int(v)
+	case int:
+		next = v
+	}
+	if next <= 0 || next >= total {
+		return 0, groupResults
+	}
+	if saved, ok := entry["group_results"].(map[string]interface{}); ok {
+		for group, result := range saved {
+			groupResults[group] = result
+		}
+	}
+	return next, groupResults
+}
+
+// saveProgress 记录当前账号的进度，同一任务的多个账号各自独立
+func (t *JoinGroupTask) saveProgress(next int, groupResults map[string]interface{}) {
+	progress, _ := t.task.Result["join_progress"].(map[string]interface{})
+	if progress == nil {
+		progress = make(map[string]interface{})
+	}
+	progress[t.accountID] = map[string]interface{}{
+		"next_index":    next,
+		"group_results": groupResults,
+	}
+	t.task.Result["join_progress"] = progress
+	t.task.Result["next_group_index"] = next
+}
+
+// writeResult 按群组结果汇总，字段与群发任务保持一致
+func (t *JoinGroupTask) writeResult(groups []interface{}, groupResults map[string]interface{}, errors []string, addLog func(string)) {
+	var joinedGroups []string
+	failedGroups := make(map[string]interface{})
+	statusCounts := make(map[string]int)
+	for _, group := range groups {
+		groupStr := fmt.Sprintf("%v", group)
+		result, ok := groupResults[groupStr].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status, _ := result["status"].(string)
+		statusCounts[status]++
+		switch status {
+		case JoinStatusJoined, JoinStatusAlreadyMember, JoinStatusRequested:
 			joinedGroups = append(joinedGroups, groupStr)
-			groupResults[groupStr] = map[string]interface{}{
-				"status":   "success",
-				"duration": duration.String(),
+		default:
+			failedGroups[groupStr] = map[string]interface{}{
+				"reason": result["reason"],
+				"error":  result["error"],
+				"status": status,
 			}
-			addLog(fmt.Sprintf("加入成功: %s", groupStr))
 		}
 	}
 
-	// 更新任务结果
-	t.task.Result["joined_count"] = successCount
-	t.task.Result["failed_count"] = failedCount
+	t.task.Result["joined_count"] = len(joinedGroups)
+	t.task.Result["failed_count"] = len(failedGroups)
+	t.task.Result["status_counts"] = statusCounts
 	t.task.Result["errors"] = errors
 	t.task.Result["joined_groups"] = joinedGroups
+	t.task.Result["failed_groups"] = failedGroups
 	t.task.Result["group_results"] = groupResults
 	t.task.Result["total_groups"] = len(groups)
-	t.task.Result["success_rate"] = float64(successCount) / float64(len(groups))
+	t.task.Result["success_rate"] = float64(len(joinedGroups)) / float64(len(groups))
 	t.task.Result["completion_time"] = time.Now().Unix()
 
-	addLog(fmt.Sprintf("任务执行完成: 成功 %d, 失败 %d", successCount, failedCount))
-
-	return nil
+	addLog(fmt.Sprintf("任务执行完成: 已加入 %d（新加入 %d，已是成员 %d，申请中 %d），失败 %d",
+		len(joinedGroups), statusCounts[JoinStatusJoined], statusCounts[JoinStatusAlreadyMember],
+		statusCounts[JoinStatusRequested], len(failedGroups)))
 }
 
-// joinGroup 加入单个群组
-func (t *JoinGroupTask) joinGroup(ctx context.Context, api *tg.Client, groupInput string) error {
+// joinGroup 加入单个群组，返回加群结果
+func (t *JoinGroupTask) joinGroup(ctx context.Context, api *tg.Client, groupInput string) (string, error) {
 	// 1. 处理 Invite Link (t.me/+hash 或 t.me/joinchat/hash)
 	if t.isInviteLink(groupInput) {
 		hash := t.extractInviteHash(groupInput)
 		if hash == "" {
-			return fmt.Errorf("invalid invite link format")
+			return JoinStatusFailed, fmt.Errorf("invalid invite link format")
 		}
 
 		_, err := api.MessagesImportChatInvite(ctx, hash)
-		return err
+		if err != nil {
+			if strings.Contains(err.Error(), "USER_ALREADY_PARTICIPANT") {
+				return JoinStatusAlreadyMember, nil
+			}
+			if strings.Contains(err.Error(), "INVITE_REQUEST_SENT") {
+				return JoinStatusRequested, nil
+			}
+			return JoinStatusFailed, err
+		}
+		return JoinStatusJoined, nil
 	}
 
 	// 2. 处理公开用户名/链接
-	username := t.extractUsername(groupInput)
+	username, startParam := parseDeepLink(groupInput)
 	if username == "" {
-		return fmt.Errorf("invalid group username or link")
+		return JoinStatusFailed, fmt.Errorf("invalid group username or link")
 	}
 
 	// 解析用户名
@@ -147,7 +307,15 @@ func (t *JoinGroupTask) joinGroup(ctx context.Context, api *tg.Client, groupInpu
 		Username: username,
 	})
 	if err != nil {
-		return fmt.Errorf("resolve username failed: %w", err)
+		return JoinStatusFailed, fmt.Errorf("resolve username failed: %w", err)
+	}
+
+	// 机器人深链接 (t.me/bot?start=xxx)：按启动参数启动机器人
+	if bot := resolvedBot(resolved); bot != nil {
+		if _, err := startBotPeer(ctx, api, bot, startParam); err != nil {
+			return JoinStatusFailed, err
+		}
+		return JoinStatusJoined, nil
 	}
 
 	// 加入频道/超级群
@@ -160,19 +328,28 @@ func (t *JoinGroupTask) joinGroup(ctx context.Context, api *tg.Client, groupInpu
 					ChannelID:  channel.ID,
 					AccessHash: channel.AccessHash,
 				})
-				return err
+				if err != nil {
+					if strings.Contains(err.Error(), "USER_ALREADY_PARTICIPANT") {
+						return JoinStatusAlreadyMember, nil
+					}
+					if strings.Contains(err.Error(), "INVITE_REQUEST_SENT") {
+						return JoinStatusRequested, nil
+					}
+					return JoinStatusFailed, err
+				}
+				return JoinStatusJoined, nil
 			}
 			// 已经是成员，视为成功
-			return nil
+			return JoinStatusAlreadyMember, nil
 		}
 		// 普通群组通常不能通过 resolve username 直接加入，除非被邀请，
 		// 但如果 resolve 成功，它通常是公开群，应该作为 channel 处理 (supergroup is a channel in API)
 		// 如果是 Chat 类型，通常意味着它是 basic group，且你已经在里面了或者它是通过其他方式获取的。
 		// 公开群在 API 中基本都是 Channel (Supergroup)。
-		return fmt.Errorf("target is not a channel or supergroup")
+		return JoinStatusFailed, fmt.Errorf("target is not a channel or supergroup")
 	}
 
-	return fmt.Errorf("group not found")
+	return JoinStatusFailed, fmt.Errorf("group not found")
 }
 
 // isInviteLink 检查是否为邀请链接
@@ -193,25 +370,6 @@ func (t *JoinGroupTask) extractInviteHash(input string) string {
 	return ""
 }
 
-// extractUsername 提取用户名
-func (t *JoinGroupTask) extractUsername(input string) string {
-	// 移除 https://t.me/ 或 t.me/
-	input = strings.TrimPrefix(input, "https://")
-	input = strings.TrimPrefix(input, "http://")
-	input = strings.TrimPrefix(input, "t.me/")
-	input = strings.TrimPrefix(input, "@")
-
-	// 移除可能的参数
-	if idx := strings.Index(input, "?"); idx != -1 {
-		input = input[:idx]
-	}
-	if idx := strings.Index(input, "/"); idx != -1 {
-		input = input[:idx]
-	}
-
-	return input
-}
-
 // contains 检查字符串包含 (复用 VerifyCodeTask 的逻辑，或者重新实现)
 func (t *JoinGroupTask) contains(s, substr string) bool {
 	return strings.Contains(s, substr)
@@ -0,0 +1,310 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// 群成员私信的成员数量限制
+const (
+	DefaultMemberMessageLimit = 200  // 每次运行默认处理的成员数
+	MaxMemberMessageLimit     = 1000 // 每次运行最多处理的成员数
+	memberPageSize            = 200  // ChannelsGetParticipants 单页上限
+	memberHistoryScanLimit    = 500  // 无权查看成员列表时扫描的最近消息数
+)
+
+// 成员来源
+const (
+	MemberSourceParticipants = "participants" // 成员列表
+	MemberSourceHistory      = "history"      // 无权查看成员列表时从最近发言者中收集
+)
+
+// MemberMessageTask 收集账号所在群组的成员并逐个私信
+// 超级群按页获取成员，成员列表被隐藏（CHAT_ADMIN_REQUIRED）时退回到最近发言者
+type MemberMessageTask struct {
+	task *models.Task
+}
+
+// NewMemberMessageTask 创建群成员私信任务
+func NewMemberMessageTask(task *models.Task) *MemberMessageTask {
+	return &MemberMessageTask{task: task}
+}
+
+// groupMember 待私信的群成员
+type groupMember struct {
+	peer     *tg.InputPeerUser
+	username string
+}
+
+// Execute 收集成员后按间隔逐个发送，单个成员失败不影响其他成员
+func (t *MemberMessageTask) Execute(ctx context.Context, api *tg.Client) error {
+	var logs []string
+	if t.task.Result == nil {
+		t.task.Result = make(models.TaskResult)
+	}
+
+	addLog := func(msg string) {
+		logEntry := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
+		logs = append(logs, logEntry)
+		t.task.Result["logs"] = logs
+	}
+
+	config := t.task.Config
+	if config == nil {
+		return fmt.Errorf("task config is nil")
+	}
+
+	group, _ := config["group"].(string)
+	if strings.TrimSpace(group) == "" {
+		return fmt.Errorf("invalid or empty group configuration")
+	}
+
+	message, _ := config["message"].(string)
+	if message == "" {
+		return fmt.Errorf("invalid or empty message configuration")
+	}
+	parseMode, _ := config["parse_mode"].(string)
+	text, entities, err := FormatMessage(message, parseMode)
+	if err != nil {
+		return err
+	}
+
+	limit := DefaultMemberMessageLimit
+	if val, ok := config["max_members"].(float64); ok && val > 0 {
+		limit = int(val)
+	}
+	if limit > MaxMemberMessageLimit {
+		limit = MaxMemberMessageLimit
+	}
+
+	intervalSec := 5 // 默认5秒间隔
+	if interval, exists := config["interval_seconds"]; exists {
+		if intervalFloat, ok := interval.(float64); ok {
+			intervalSec = int(intervalFloat)
+		}
+	}
+	sendDelay, err := SendDelayFromConfig(config)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	members, source, err := t.collectMembers(ctx, api, group, limit)
+	if err != nil {
+		addLog(fmt.Sprintf("获取群成员失败: %s: %v", group, err))
+		return fmt.Errorf("failed to collect members: %w", err)
+	}
+	t.task.Result["member_source"] = source
+	t.task.Result["member_count"] = len(members)
+	t.task.Result["member_limit"] = limit
+	if source == MemberSourceHistory {
+		addLog("账号无权查看完整成员列表 (CHAT_ADMIN_REQUIRED)，改为从最近发言者中收集")
+	}
+	addLog(fmt.Sprintf("群组: %s，收集到成员 %d 个（上限 %d），间隔: %d秒，间隔分布: %s", group, len(members), limit, intervalSec, sendDelay))
+
+	results := make(map[string]interface{}, len(members))
+	failureReasons := make(map[string]int)
+	sentCount := 0
+	failedCount := 0
+
+	for i, member := range members {
+		if ctx.Err() != nil {
+			addLog("任务已取消，停止发送")
+			break
+		}
+		if i > 0 && intervalSec > 0 {
+			time.Sleep(sendDelay.Next(rnd, time.Duration(intervalSec)*time.Second))
+		}
+
+		key := strconv.FormatInt(member.peer.UserID, 10)
+		_, err := api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+			Peer:     member.peer,
+			Message:  text,
+			Entities: entities,
+			RandomID: time.Now().UnixNano(),
+		})
+		if err != nil {
+			reason := ClassifyTargetError(err)
+			failureReasons[reason]++
+			failedCount++
+			results[key] = map[string]interface{}{
+				"username": member.username,
+				"status":   "failed",
+				"reason":   reason,
+				"error":    err.Error(),
+			}
+			addLog(fmt.Sprintf("发送失败 [%s] (%s): %v", key, reason, err))
+			continue
+		}
+
+		sentCount++
+		results[key] = map[string]interface{}{
+			"username": member.username,
+			"status":   "success",
+			"sent_at":  time.Now().Unix(),
+		}
+	}
+
+	t.task.Result["member_results"] = results
+	t.task.Result["failure_reasons"] = failureReasons
+	t.task.Result["sent_count"] = sentCount
+	t.task.Result["failed_count"] = failedCount
+	t.task.Result["executed_at"] = time.Now().Unix()
+
+	addLog(fmt.Sprintf("任务执行完成: 成功 %d, 失败 %d", sentCount, failedCount))
+
+	if sentCount == 0 && failedCount > 0 {
+		return fmt.Errorf("failed to message any member")
+	}
+	return nil
+}
+
+// collectMembers 收集最多 limit 个可私信的成员（排除自己、机器人和已注销账号），返回成员及来源
+func (t *MemberMessageTask) collectMembers(ctx context.Context, api *tg.Client, group string, limit int) ([]groupMember, string, error) {
+	username, _ := parseDeepLink(group)
+	if username == "" {
+		return nil, "", fmt.Errorf("invalid group %q", group)
+	}
+	resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: username})
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve username failed: %w", err)
+	}
+
+	collector := newMemberCollector(limit)
+	for _, c := range resolved.Chats {
+		if c.GetID() != peerID(resolved.Peer) {
+			continue
+		}
+		switch chat := c.(type) {
+		case *tg.Chat:
+			full, err := api.MessagesGetFullChat(ctx, chat.ID)
+			if err != nil {
+				return nil, "", err
+			}
+			collector.addUsers(full.Users)
+			return collector.members, MemberSourceParticipants, nil
+		case *tg.Channel:
+			channel := &tg.InputChannel{ChannelID: chat.ID, AccessHash: chat.AccessHash}
+			err := t.collectParticipants(ctx, api, channel, collector)
+			if err != nil && strings.Contains(err.Error(), "CHAT_ADMIN_REQUIRED") {
+				peer := &tg.InputPeerChannel{ChannelID: chat.ID, AccessHash: chat.AccessHash}
+				if err := t.collectRecentSenders(ctx, api, peer, collector); err != nil {
+					return nil, "", err
+				}
+				return collector.members, MemberSourceHistory, nil
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			return collector.members, MemberSourceParticipants, nil
+		}
+	}
+	return nil, "", fmt.Errorf("group not found: %s", group)
+}
+
+// collectParticipants 按页获取超级群成员，直到达到上限或没有更多成员
+func (t *MemberMessageTask) collectParticipants(ctx context.Context, api *tg.Client, channel *tg.InputChannel, collector *memberCollector) error {
+	for offset := 0; !collector.full(); {
+		participants, err := api.ChannelsGetParticipants(ctx, &tg.ChannelsGetParticipantsRequest{
+			Channel: channel,
+			Filter:  &tg.ChannelParticipantsRecent{},
+			Offset:  offset,
+			Limit:   memberPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		page, ok := participants.(*tg.ChannelsChannelParticipants)
+		if !ok || len(page.Participants) == 0 {
+			return nil
+		}
+		collector.addUsers(page.Users)
+		offset += len(page.Participants)
+		if offset >= page.Count {
+			return nil
+		}
+	}
+	return nil
+}
+
+// collectRecentSenders 从最近消息的发送者中收集成员，用于成员列表被隐藏的群组
+func (t *MemberMessageTask) collectRecentSenders(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, collector *memberCollector) error {
+	for offsetID, scanned := 0, 0; !collector.full() && scanned < memberHistoryScanLimit; {
+		history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:     peer,
+			OffsetID: offsetID,
+			Limit:    100,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get history: %w", err)
+		}
+		modified, ok := history.AsModified()
+		if !ok || len(modified.GetMessages()) == 0 {
+			return nil
+		}
+
+		users := make(map[int64]tg.UserClass)
+		for _, u := range modified.GetUsers() {
+			users[u.GetID()] = u
+		}
+		for _, m := range modified.GetMessages() {
+			offsetID = m.GetID()
+			scanned++
+			msg, ok := m.(*tg.Message)
+			if !ok || msg.FromID == nil {
+				continue
+			}
+			if from, ok := msg.FromID.(*tg.PeerUser); ok {
+				if user, ok := users[from.UserID]; ok {
+					collector.addUsers([]tg.UserClass{user})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// memberCollector 去重收集成员，达到上限后忽略后续成员
+type memberCollector struct {
+	limit   int
+	seen    map[int64]bool
+	members []groupMember
+}
+
+func newMemberCollector(limit int) *memberCollector {
+	return &memberCollector{limit: limit, seen: make(map[int64]bool)}
+}
+
+func (c *memberCollector) full() bool {
+	return len(c.members) >= c.limit
+}
+
+func (c *memberCollector) addUsers(users []tg.UserClass) {
+	for _, u := range users {
+		if c.full() {
+			return
+		}
+		user, ok := u.(*tg.User)
+		if !ok || user.Self || user.Bot || user.Deleted || c.seen[user.ID] {
+			continue
+		}
+		c.seen[user.ID] = true
+		c.members = append(c.members, groupMember{
+			peer:     &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash},
+			username: user.Username,
+		})
+	}
+}
+
+// GetType 获取任务类型
+func (t *MemberMessageTask) GetType() string {
+	return "member_message"
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gotd/td/tg"
+
+	"tg_cloud_server/internal/models"
+)
+
+// RefreshInfoTask 刷新账号资料任务：重新获取用户名、姓名、简介和头像并写入数据库，不做账号检查评分
+type RefreshInfoTask struct {
+	task           *models.Task
+	connectionPool *ConnectionPool
+	accountID      uint64
+	Account        *models.TGAccount // 执行成功后为更新后的账号
+}
+
+// NewRefreshInfoTask 创建刷新账号资料任务
+func NewRefreshInfoTask(task *models.Task, connectionPool *ConnectionPool, accountID uint64) *RefreshInfoTask {
+	return &RefreshInfoTask{task: task, connectionPool: connectionPool, accountID: accountID}
+}
+
+// Execute 获取并保存账号资料
+func (t *RefreshInfoTask) Execute(ctx context.Context, api *tg.Client) error {
+	if t.task.Result == nil {
+		t.task.Result = make(models.TaskResult)
+	}
+
+	info, err := FetchAccountInfo(ctx, api)
+	if err != nil {
+		t.connectionPool.markAccountDeadOnAuthError(t.accountID, err)
+		return fmt.Errorf("failed to get account info: %w", err)
+	}
+
+	account, err := t.connectionPool.saveAccountInfo(t.accountID, info)
+	if err != nil {
+		return fmt.Errorf("failed to save account info: %w", err)
+	}
+	t.Account = account
+
+	result := map[string]interface{}{
+		"phone":        account.Phone,
+		"refreshed_at": time.Now().Unix(),
+	}
+	if account.TgUserID != nil {
+		result["tg_user_id"] = *account.TgUserID
+	}
+	if account.Username != nil {
+		result["username"] = *account.Username
+	}
+	if account.FirstName != nil {
+		result["first_name"] = *account.FirstName
+	}
+	if account.LastName != nil {
+		result["last_name"] = *account.LastName
+	}
+	if account.PhotoURL != nil {
+		result["photo_id"] = *account.PhotoURL
+	}
+	// 多账号任务按账号记录结果
+	t.task.Result[strconv.FormatUint(t.accountID, 10)] = result
+	return nil
+}
+
+// GetType 获取任务类型
+func (t *RefreshInfoTask) GetType() string {
+	return "refresh_info"
+}
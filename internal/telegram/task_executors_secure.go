@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tg_cloud_server/internal/models"
+
+	"github.com/gotd/td/tg"
+)
+
+// SecureAccountTask 一键加固账号任务：踢出除当前会话外的所有设备，并可选设置/修改2FA
+// 用于疑似被盗账号的标准处置流程
+type SecureAccountTask struct {
+	task *models.Task
+}
+
+// NewSecureAccountTask 创建一键加固账号任务
+func NewSecureAccountTask(task *models.Task) *SecureAccountTask {
+	return &SecureAccountTask{task: task}
+}
+
+// Execute 依次执行各加固步骤，单步失败不影响后续步骤，全部执行完后汇总结果
+func (t *SecureAccountTask) Execute(ctx context.Context, api *tg.Client) error {
+	if t.task.Result == nil {
+		t.task.Result = make(models.TaskResult)
+	}
+
+	var logs []string
+	addLog := func(msg string) {
+		logEntry := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
+		logs = append(logs, logEntry)
+		t.task.Result["logs"] = logs
+	}
+
+	steps := make(map[string]interface{})
+	var failedSteps []string
+
+	// runStep 复用已有任务执行器，合并其日志并记录该步骤结果
+	runStep := func(name, title string, executor TaskInterface) {
+		addLog(fmt.Sprintf("开始步骤: %s", title))
+		delete(t.task.Result, "logs") // 子执行器会覆盖 logs，执行后再合并
+		err := executor.Execute(ctx, api)
+
+		if stepLogs, ok := t.task.Result["logs"].([]string); ok {
+			logs = append(logs, stepLogs...)
+		}
+		t.task.Result["logs"] = logs
+
+		if err != nil {
+			steps[name] = map[string]interface{}{"status": "failed", "error": err.Error()}
+			failedSteps = append(failedSteps, name)
+			addLog(fmt.Sprintf("步骤失败: %s: %v", title, err))
+			return
+		}
+		steps[name] = map[string]interface{}{"status": "success"}
+		addLog(fmt.Sprintf("步骤完成: %s", title))
+	}
+
+	addLog("开始执行账号加固任务...")
+
+	// 1. 踢出其他所有设备
+	runStep("terminate_sessions", "踢出其他设备", NewTerminateSessionsTask(t.task))
+	if step, ok := steps["terminate_sessions"].(map[string]interface{}); ok {
+		if count, ok := t.task.Result["terminated_count"]; ok {
+			step["terminated_count"] = count
+		}
+	}
+
+	// 2. 可选：设置或修改 2FA 密码
+	newPassword, _ := t.task.Config["new_password"].(string)
+	if newPassword != "" {
+		runStep("update_2fa", "设置 2FA 密码", NewUpdate2FATask(t.task))
+		if step, ok := steps["update_2fa"].(map[string]interface{}); ok && step["status"] == "success" {
+			t.task.Result["has_2fa"] = true
+			t.task.Result["two_fa_password"] = newPassword
+		}
+	} else {
+		steps["update_2fa"] = map[string]interface{}{"status": "skipped"}
+		addLog("未配置新 2FA 密码，跳过 2FA 设置")
+	}
+
+	t.task.Result["steps"] = steps
+	t.task.Result["executed_at"] = time.Now().Unix()
+
+	if len(failedSteps) > 0 {
+		t.task.Result["status"] = "failed"
+		addLog(fmt.Sprintf("账号加固未完全成功，失败步骤: %v", failedSteps))
+		return fmt.Errorf("secure account steps failed: %v", failedSteps)
+	}
+
+	t.task.Result["status"] = "success"
+	addLog("账号加固完成")
+	return nil
+}
+
+// GetType 获取任务类型
+func (t *SecureAccountTask) GetType() string {
+	return "secure_account"
+}
@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 场景任务与普通任务共用账号的约定：
+// 场景任务的更新处理器注册在连接池上，与任务槽位无关，槽位只约束 MTProto 调用的执行；
+// 智能体的每次发言、入群检查都是一次独立的短 ExecuteTask，普通任务（如账号检查）在其间隙占用槽位，
+// 账号忙碌时双方在槽位上排队等待 taskSlotWait，而不是直接返回 "account is busy"，执行上保持串行。
+// 智能体和普通任务都通过 ExecuteTaskWithContext 传入各自的上下文（场景上下文、任务上下文），
+// 场景停止或任务被停止、暂停时立即放弃等待，不会拖到 taskSlotWait 超时。
+
+// SetTaskSlotWait 设置账号忙碌时 ExecuteTask 等待任务槽位的最长时间，0 表示立即返回忙碌错误
+func (cp *ConnectionPool) SetTaskSlotWait(wait time.Duration) {
+	cp.taskSlotWait = wait
+}
+
+// TaskSlotWait 返回账号忙碌时等待任务槽位的最长时间
+func (cp *ConnectionPool) TaskSlotWait() time.Duration {
+	return cp.taskSlotWait
+}
+
+// errAccountBusy 等待任务槽位超时
+var errAccountBusy = errors.New("account is busy with another task")
+
+// acquireTaskSlot 占用连接的任务槽位，槽位被占用时最多等待 wait，
+// 超时返回 errAccountBusy，等待期间 ctx 取消时返回 ctx.Err()
+func (c *ManagedConnection) acquireTaskSlot(ctx context.Context, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		if !c.taskRunning {
+			c.taskRunning = true
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errAccountBusy
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-c.slotFreedCh:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+	}
+}
+
+// releaseTaskSlot 释放任务槽位并唤醒一个等待者
+func (c *ManagedConnection) releaseTaskSlot() {
+	c.mu.Lock()
+	c.taskRunning = false
+	c.mu.Unlock()
+
+	select {
+	case c.slotFreedCh <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,153 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func newSlotTestConnection() *ManagedConnection {
+	return &ManagedConnection{slotFreedCh: make(chan struct{}, 1)}
+}
+
+// 普通任务占用槽位期间，智能体的短任务排队等待，槽位释放后立即执行
+func TestAcquireTaskSlotQueuesBehindRunningTask(t *testing.T) {
+	conn := newSlotTestConnection()
+	if err := conn.acquireTaskSlot(context.Background(), 0); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	var order []string
+	var mu sync.Mutex
+	record := func(name string) {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := conn.acquireTaskSlot(context.Background(), time.Second)
+		if err == nil {
+			record("agent")
+			conn.releaseTaskSlot()
+		}
+		done <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	record("regular")
+	conn.releaseTaskSlot()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("queued acquire: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued acquire not woken after release")
+	}
+
+	if len(order) != 2 || order[0] != "regular" || order[1] != "agent" {
+		t.Fatalf("execution order = %v, want [regular agent]", order)
+	}
+}
+
+func TestAcquireTaskSlotTimesOut(t *testing.T) {
+	conn := newSlotTestConnection()
+	if err := conn.acquireTaskSlot(context.Background(), 0); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	start := time.Now()
+	err := conn.acquireTaskSlot(context.Background(), 50*time.Millisecond)
+	if !errors.Is(err, errAccountBusy) {
+		t.Fatalf("err = %v, want errAccountBusy", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Fatalf("returned after %v, before the wait elapsed", waited)
+	}
+}
+
+// 场景停止（上下文取消）时智能体立即放弃等待，不占用槽位
+func TestAcquireTaskSlotStopsOnContextCancel(t *testing.T) {
+	conn := newSlotTestConnection()
+	if err := conn.acquireTaskSlot(context.Background(), 0); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- conn.acquireTaskSlot(ctx, time.Minute) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire still waiting after context cancel")
+	}
+
+	conn.releaseTaskSlot()
+	if err := conn.acquireTaskSlot(context.Background(), 0); err != nil {
+		t.Fatalf("slot not free after release: %v", err)
+	}
+}
+
+// slotTestTask 记录是否被执行的空任务
+type slotTestTask struct {
+	executed bool
+}
+
+func (t *slotTestTask) Execute(ctx context.Context, api *tg.Client) error {
+	t.executed = true
+	return nil
+}
+
+func (t *slotTestTask) GetType() string { return "slot_test" }
+
+// 调度器以任务上下文调用 ExecuteTaskWithContext：任务被停止或暂停时不再等满 taskSlotWait
+func TestExecuteTaskWithContextStopsSlotWaitOnCancel(t *testing.T) {
+	conn := newSlotTestConnection()
+	conn.isActive = true
+	conn.status = StatusConnected
+	if err := conn.acquireTaskSlot(context.Background(), 0); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	cp := &ConnectionPool{
+		connections:  map[string]*ManagedConnection{"1": conn},
+		configs:      map[string]*ClientConfig{"1": {Phone: "+15550000000"}},
+		logger:       zap.NewNop(),
+		taskSlotWait: time.Minute,
+	}
+
+	task := &slotTestTask{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cp.ExecuteTaskWithContext(ctx, "1", task) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ExecuteTaskWithContext still waiting for the slot after cancel")
+	}
+	if task.executed {
+		t.Fatal("task executed after its context was cancelled")
+	}
+}
@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// Telegram 定时消息允许的时间窗口：至少在当前时间之后，最多365天
+const (
+	minTelegramScheduleAhead = 10 * time.Second
+	maxTelegramScheduleAhead = 365 * 24 * time.Hour
+)
+
+// ValidateTelegramScheduleDate 校验定时发送时间是否在 Telegram 允许的窗口内
+func ValidateTelegramScheduleDate(at time.Time) error {
+	now := time.Now()
+	if at.Before(now.Add(minTelegramScheduleAhead)) {
+		return fmt.Errorf("telegram schedule date must be at least %s in the future", minTelegramScheduleAhead)
+	}
+	if at.After(now.Add(maxTelegramScheduleAhead)) {
+		return fmt.Errorf("telegram schedule date must be within %d days", int(maxTelegramScheduleAhead.Hours()/24))
+	}
+	return nil
+}
+
+// TelegramScheduleDateFromConfig 读取 use_telegram_schedule / telegram_schedule_date，未启用时返回0
+// 执行时再次校验，避免任务延迟执行时把已过期的时间交给 Telegram
+func TelegramScheduleDateFromConfig(config map[string]interface{}) (int, error) {
+	if enabled, _ := config["use_telegram_schedule"].(bool); !enabled {
+		return 0, nil
+	}
+	date, ok := config["telegram_schedule_date"].(float64)
+	if !ok || date <= 0 {
+		return 0, fmt.Errorf("missing telegram_schedule_date for use_telegram_schedule")
+	}
+	if err := ValidateTelegramScheduleDate(time.Unix(int64(date), 0)); err != nil {
+		return 0, err
+	}
+	return int(date), nil
+}
+
+// scheduledMessageID 从发送结果中提取 Telegram 定时消息ID，未找到时返回0
+func scheduledMessageID(updates tg.UpdatesClass) int {
+	var list []tg.UpdateClass
+	switch u := updates.(type) {
+	case *tg.Updates:
+		list = u.Updates
+	case *tg.UpdatesCombined:
+		list = u.Updates
+	case *tg.UpdateShort:
+		list = []tg.UpdateClass{u.Update}
+	}
+
+	for _, update := range list {
+		if scheduled, ok := update.(*tg.UpdateNewScheduledMessage); ok {
+			return scheduled.Message.GetID()
+		}
+	}
+	for _, update := range list {
+		if msgID, ok := update.(*tg.UpdateMessageID); ok {
+			return msgID.ID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// TermsOfServiceResult 服务条款检查结果
+type TermsOfServiceResult struct {
+	Pending  bool   // 是否有待接受的服务条款
+	Accepted bool   // 本次是否已自动接受
+	TermsID  string // 服务条款ID
+}
+
+// CheckTermsOfService 检查账号是否有待接受的服务条款，autoAccept 为 true 时自动接受
+// 未接受的服务条款会导致部分操作被拒绝，新导入的账号较常见
+func CheckTermsOfService(ctx context.Context, api *tg.Client, autoAccept bool) (*TermsOfServiceResult, error) {
+	update, err := api.HelpGetTermsOfServiceUpdate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get terms of service update: %w", err)
+	}
+
+	tos, ok := update.(*tg.HelpTermsOfServiceUpdate)
+	if !ok {
+		return &TermsOfServiceResult{}, nil
+	}
+
+	result := &TermsOfServiceResult{Pending: true, TermsID: tos.TermsOfService.ID.Data}
+	if !autoAccept {
+		return result, nil
+	}
+
+	if _, err := api.HelpAcceptTermsOfService(ctx, tos.TermsOfService.ID); err != nil {
+		return result, fmt.Errorf("failed to accept terms of service: %w", err)
+	}
+	result.Pending = false
+	result.Accepted = true
+	return result, nil
+}
+
+// SetAutoAcceptTOS 设置连接建立时是否自动接受待接受的服务条款，关闭时仅标记账号需要手动处理
+func (cp *ConnectionPool) SetAutoAcceptTOS(autoAccept bool) {
+	cp.autoAcceptTOS = autoAccept
+}
+
+// AutoAcceptTOS 返回是否自动接受服务条款
+func (cp *ConnectionPool) AutoAcceptTOS() bool {
+	return cp.autoAcceptTOS
+}
+
+// handleTermsOfService 连接建立后检查服务条款，并记录账号的待接受/已接受状态
+func (cp *ConnectionPool) handleTermsOfService(ctx context.Context, accountID uint64, api *tg.Client) {
+	result, err := CheckTermsOfService(ctx, api, cp.autoAcceptTOS)
+	if err != nil {
+		cp.logger.Warn("Terms of service check failed",
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+		if result == nil {
+			return
+		}
+	}
+
+	var acceptedAt *time.Time
+	if result.Accepted {
+		now := time.Now()
+		acceptedAt = &now
+		cp.logger.Info("Terms of service accepted automatically",
+			zap.Uint64("account_id", accountID),
+			zap.String("terms_id", result.TermsID))
+	} else if result.Pending {
+		cp.logger.Warn("Account has pending terms of service",
+			zap.Uint64("account_id", accountID),
+			zap.String("terms_id", result.TermsID))
+	}
+
+	if err := cp.accountRepo.UpdateTOSStatus(accountID, result.Pending, acceptedAt); err != nil {
+		cp.logger.Error("Failed to update terms of service status",
+			zap.Uint64("account_id", accountID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// updateListeners 账号的临时更新监听器，与 SetUpdateHandler 设置的处理器并存，互不覆盖
+type updateListeners struct {
+	mu        sync.RWMutex
+	nextID    uint64
+	byAccount map[string]map[uint64]telegram.UpdateHandler
+}
+
+// AddUpdateListener 为账号添加临时更新监听器，返回的函数用于移除监听器，任务结束时必须调用
+func (cp *ConnectionPool) AddUpdateListener(accountID string, handler telegram.UpdateHandler) func() {
+	l := &cp.listeners
+	l.mu.Lock()
+	if l.byAccount == nil {
+		l.byAccount = make(map[string]map[uint64]telegram.UpdateHandler)
+	}
+	if l.byAccount[accountID] == nil {
+		l.byAccount[accountID] = make(map[uint64]telegram.UpdateHandler)
+	}
+	l.nextID++
+	id := l.nextID
+	l.byAccount[accountID][id] = handler
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			delete(l.byAccount[accountID], id)
+			if len(l.byAccount[accountID]) == 0 {
+				delete(l.byAccount, accountID)
+			}
+		})
+	}
+}
+
+// notifyListeners 将更新分发给账号的临时监听器，监听器的错误不影响主处理器
+func (cp *ConnectionPool) notifyListeners(ctx context.Context, accountID string, u tg.UpdatesClass) {
+	l := &cp.listeners
+	l.mu.RLock()
+	handlers := make([]telegram.UpdateHandler, 0, len(l.byAccount[accountID]))
+	for _, h := range l.byAccount[accountID] {
+		handlers = append(handlers, h)
+	}
+	l.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h.Handle(ctx, u); err != nil {
+			cp.logger.Debug("Update listener returned error",
+				zap.String("account_id", accountID),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"regexp"
+	"strings"
+)
+
+// verifyCodeKeywords 验证码消息中常见的关键词（小写），验证码取离关键词最近的数字
+var verifyCodeKeywords = []string{
+	"code", "verification", "verify", "login", "telegram",
+	"验证码", "验证", "登录", "代码",
+	"код", "código", "codice", "kod", "mã", "کد",
+}
+
+var (
+	verifyCodeDigitsRe = regexp.MustCompile(`\d+`)
+	// verifyCodeDurationRe 数字后紧跟时间单位时视为有效期（如 "60 min"、"5分钟"），不是验证码
+	verifyCodeDurationRe = regexp.MustCompile(`^\s?(?:minutes?|mins?|seconds?|secs?|hours?|hrs?|days?|minutos?|segundos?|horas?|minuti|secondi|ore|минут[аы]?|мин|секунд[аы]?|час(?:а|ов)?|分钟|分|秒|小时|天|m|s|h)(?:[^a-z]|$)`)
+)
+
+const (
+	minVerifyCodeLength = 4
+	maxVerifyCodeLength = 8
+)
+
+// ExtractVerificationCode 从消息文本中提取验证码：消息需包含验证码关键词，
+// 验证码为独立的 4-8 位数字，可用短横线或空格分隔（如 "123-456"），时长类数字（如 "60 min"）会被忽略；
+// 有多个候选时取离关键词最近的一个，未找到时返回空字符串
+func ExtractVerificationCode(message string) string {
+	text := strings.ToLower(message)
+
+	var keywords []int
+	for _, keyword := range verifyCodeKeywords {
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], keyword)
+			if i < 0 {
+				break
+			}
+			keywords = append(keywords, offset+i)
+			offset += i + len(keyword)
+		}
+	}
+	if len(keywords) == 0 {
+		return ""
+	}
+
+	best, bestDistance := "", -1
+	runs := verifyCodeDigitsRe.FindAllStringIndex(text, -1)
+	for i := 0; i < len(runs); i++ {
+		start, end := runs[i][0], runs[i][1]
+		// 小数、版本号等带小数点的数字不是验证码
+		if start > 0 && (text[start-1] == '.' || text[start-1] == ',') && start > 1 && isASCIIDigit(text[start-2]) {
+			continue
+		}
+		if verifyCodeDurationRe.MatchString(text[end:]) {
+			continue
+		}
+		code := text[start:end]
+
+		// 合并以单个短横线或空格分隔的下一段数字
+		if i+1 < len(runs) && runs[i+1][0] == end+1 && (text[end] == '-' || text[end] == ' ') {
+			next := text[runs[i+1][0]:runs[i+1][1]]
+			if len(code) >= 2 && len(next) >= 2 && len(code)+len(next) <= maxVerifyCodeLength &&
+				!verifyCodeDurationRe.MatchString(text[runs[i+1][1]:]) {
+				code += next
+				i++
+			}
+		}
+
+		if len(code) < minVerifyCodeLength || len(code) > maxVerifyCodeLength {
+			continue
+		}
+		distance := nearestDistance(keywords, start)
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = code, distance
+		}
+	}
+	return best
+}
+
+// nearestDistance 返回 pos 与最近的关键词位置之间的距离
+func nearestDistance(positions []int, pos int) int {
+	nearest := -1
+	for _, p := range positions {
+		d := pos - p
+		if d < 0 {
+			d = -d
+		}
+		if nearest < 0 || d < nearest {
+			nearest = d
+		}
+	}
+	return nearest
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,41 @@
+package telegram
+
+import "testing"
+
+func TestExtractVerificationCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name: "telegram login message",
+			message: "Login code: 52814. Do not give this code to anyone, even if they say they are from Telegram!\n\n" +
+				"This code can be used to log in to your Telegram account. We never ask it for anything else.\n\n" +
+				"If you didn't request this code by trying to log in on another device, simply ignore this message.",
+			want: "52814",
+		},
+		{name: "dash separated", message: "Your code is 12-345", want: "12345"},
+		{name: "space separated", message: "Your verification code: 123 456", want: "123456"},
+		{name: "validity after code", message: "Your login code is 4821, valid for 10 minutes", want: "4821"},
+		{name: "duration before code", message: "Code expires in 1440 minutes: 83920", want: "83920"},
+		{name: "duration only", message: "Your code will arrive in 3600 seconds", want: ""},
+		{name: "duration with short unit", message: "Login code valid 3600s", want: ""},
+		{name: "chinese", message: "您的验证码：482913，5分钟内有效", want: "482913"},
+		{name: "chinese duration only", message: "验证码将在 1200秒 后发送", want: ""},
+		{name: "russian", message: "Код для входа в Telegram: 71234. Не давайте код никому.", want: "71234"},
+		{name: "spanish", message: "Código de inicio de sesión: 39201", want: "39201"},
+		{name: "no keyword", message: "Meet me at 1234 Main St", want: ""},
+		{name: "decimal number", message: "Telegram 1.2345 released", want: ""},
+		{name: "too long", message: "login code 123456789", want: ""},
+		{name: "nearest to keyword", message: "Order 5555 shipped. Your code: 8642", want: "8642"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractVerificationCode(tt.message); got != tt.want {
+				t.Errorf("ExtractVerificationCode(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
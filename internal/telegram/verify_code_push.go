@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+// verifyCodePushWait 等待推送的时间，超过后开始轮询对话
+const verifyCodePushWait = 5 * time.Second
+
+// verifyCodeMatch 匹配到的验证码
+type verifyCodeMatch struct {
+	code       string
+	sender     string
+	receivedAt time.Time
+}
+
+// SetUpdateSource 设置接收消息推送的连接池和账号，验证码到达时立即返回，无需等待轮询
+func (t *VerifyCodeTask) SetUpdateSource(pool *ConnectionPool, accountID string) {
+	t.connectionPool = pool
+	t.accountID = accountID
+}
+
+// pushHandler 创建监听新消息推送的处理器，匹配到验证码时写入 found（只保留第一个）
+func (t *VerifyCodeTask) pushHandler(senders []string, startTime time.Time, found chan<- verifyCodeMatch) telegram.UpdateHandler {
+	deliver := func(m verifyCodeMatch) {
+		select {
+		case found <- m:
+		default:
+		}
+	}
+
+	return telegram.UpdateHandlerFunc(func(ctx context.Context, u tg.UpdatesClass) error {
+		var updates []tg.UpdateClass
+		switch v := u.(type) {
+		case *tg.Updates:
+			updates = v.Updates
+		case *tg.UpdatesCombined:
+			updates = v.Updates
+		case *tg.UpdateShort:
+			updates = []tg.UpdateClass{v.Update}
+		case *tg.UpdateShortMessage:
+			// 私聊消息的简短推送，发送者即对话用户
+			if !v.Out {
+				msgTime := time.Unix(int64(v.Date), 0)
+				if !msgTime.Before(startTime) {
+					if m, ok := t.matchText(v.Message, fmt.Sprintf("%d", v.UserID), msgTime, senders); ok {
+						deliver(m)
+					}
+				}
+			}
+			return nil
+		}
+
+		for _, update := range updates {
+			newMessage, ok := update.(*tg.UpdateNewMessage)
+			if !ok {
+				continue
+			}
+			msg, ok := newMessage.Message.(*tg.Message)
+			if !ok || msg.Out {
+				continue
+			}
+			if m, ok := t.matchMessage(msg, senders, startTime); ok {
+				deliver(m)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,331 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 预热连接池参数
+const (
+	warmUsageHalfLife   = 6 * time.Hour // 使用热度的半衰期，越久未使用的账号热度越低
+	warmConnectPerRound = 5             // 每轮最多新建的预热连接数，避免集中建连
+
+	warmUpConcurrency  = 5                      // WarmUp 同时建立连接的账号数
+	warmUpPollInterval = 500 * time.Millisecond // WarmUp 检查连接状态的间隔
+)
+
+// accountUsage 账号的使用热度，每次执行任务加 1，并按半衰期随时间衰减
+type accountUsage struct {
+	score    float64
+	lastUsed time.Time
+}
+
+// decayed 返回衰减到 now 时的热度
+func (u *accountUsage) decayed(now time.Time) float64 {
+	elapsed := now.Sub(u.lastUsed)
+	return u.score * math.Pow(0.5, float64(elapsed)/float64(warmUsageHalfLife))
+}
+
+// warmPool 预热连接池状态：目标连接数、当前需保持的账号集合及使用热度
+type warmPool struct {
+	target   int
+	interval time.Duration
+	stop     chan struct{}
+
+	mu    sync.Mutex
+	usage map[string]*accountUsage
+	hot   map[string]bool
+}
+
+// SetWarmPool 设置预热连接数并启动维护循环：按使用频率和最近使用时间挑选最热的 target 个账号保持连接，
+// 这些账号不会因空闲被清理，超出部分仍按 idle_timeout 清理。target 小于等于0表示不预热
+func (cp *ConnectionPool) SetWarmPool(target int, interval time.Duration) {
+	if target <= 0 || cp.warm.stop != nil {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	cp.warm.target = target
+	cp.warm.interval = interval
+	cp.warm.stop = make(chan struct{})
+	go cp.warmPoolLoop()
+
+	cp.logger.Info("Warm pool enabled",
+		zap.Int("target", target),
+		zap.Duration("interval", interval))
+}
+
+// recordAccountUsage 记录账号的一次使用，用于预热排序
+func (cp *ConnectionPool) recordAccountUsage(accountID string) {
+	cp.warm.mu.Lock()
+	defer cp.warm.mu.Unlock()
+
+	if cp.warm.usage == nil {
+		cp.warm.usage = make(map[string]*accountUsage)
+	}
+	now := time.Now()
+	usage, ok := cp.warm.usage[accountID]
+	if !ok {
+		usage = &accountUsage{}
+		cp.warm.usage[accountID] = usage
+	}
+	usage.score = usage.decayed(now) + 1
+	usage.lastUsed = now
+}
+
+// isWarmAccount 账号是否在当前预热集合中
+func (cp *ConnectionPool) isWarmAccount(accountID string) bool {
+	cp.warm.mu.Lock()
+	defer cp.warm.mu.Unlock()
+	return cp.warm.hot[accountID]
+}
+
+// warmPoolLoop 定期维护预热连接，连接池关闭时退出
+func (cp *ConnectionPool) warmPoolLoop() {
+	ticker := time.NewTicker(cp.warm.interval)
+	defer ticker.Stop()
+
+	cp.maintainWarmPool()
+	for {
+		select {
+		case <-ticker.C:
+			cp.maintainWarmPool()
+		case <-cp.warm.stop:
+			return
+		}
+	}
+}
+
+// maintainWarmPool 计算最热的账号集合，并为其中尚未连接的账号建立连接
+func (cp *ConnectionPool) maintainWarmPool() {
+	hot := cp.hottestAccounts(cp.warm.target)
+
+	hotSet := make(map[string]bool, len(hot))
+	for _, accountID := range hot {
+		hotSet[accountID] = true
+	}
+	cp.warm.mu.Lock()
+	cp.warm.hot = hotSet
+	cp.warm.mu.Unlock()
+
+	connected := 0
+	for _, accountID := range hot {
+		if connected >= warmConnectPerRound {
+			break
+		}
+		if cp.hasActiveConnection(accountID) {
+			continue
+		}
+
+		cp.mu.RLock()
+		config, exists := cp.configs[accountID]
+		cp.mu.RUnlock()
+		if !exists {
+			var err error
+			config, err = cp.loadAccountConfig(accountID)
+			if err != nil {
+				cp.logger.Debug("Skipping warm connection",
+					zap.String("account_id", accountID),
+					zap.Error(err))
+				continue
+			}
+		}
+
+		if cp.warmConnection(accountID, config) {
+			connected++
+		}
+	}
+
+	if connected > 0 {
+		cp.logger.Info("Warm pool connections created",
+			zap.Int("created", connected),
+			zap.Int("hot_accounts", len(hot)),
+			zap.Int("target", cp.warm.target))
+	}
+}
+
+// hottestAccounts 按热度返回最多 limit 个账号。内存中没有使用记录的账号（如服务刚启动）
+// 从数据库中按最近使用时间补充，热度按距上次使用的时间衰减
+func (cp *ConnectionPool) hottestAccounts(limit int) []string {
+	now := time.Now()
+	scores := make(map[string]float64)
+
+	cp.warm.mu.Lock()
+	for accountID, usage := range cp.warm.usage {
+		scores[accountID] = usage.decayed(now)
+	}
+	cp.warm.mu.Unlock()
+
+	if len(scores) < limit {
+		accounts, err := cp.accountRepo.GetRecentlyUsedAccounts(limit)
+		if err != nil {
+			cp.logger.Warn("Failed to load recently used accounts for warm pool", zap.Error(err))
+		}
+		for _, account := range accounts {
+			accountID := strconv.FormatUint(account.ID, 10)
+			if _, ok := scores[accountID]; ok || account.LastUsedAt == nil {
+				continue
+			}
+			seed := accountUsage{score: 1, lastUsed: *account.LastUsedAt}
+			scores[accountID] = seed.decayed(now)
+		}
+	}
+
+	ranked := make([]string, 0, len(scores))
+	for accountID := range scores {
+		ranked = append(ranked, accountID)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// hasActiveConnection 账号是否已有活跃连接（含连接中、重连中）
+func (cp *ConnectionPool) hasActiveConnection(accountID string) bool {
+	cp.mu.RLock()
+	conn, exists := cp.connections[accountID]
+	cp.mu.RUnlock()
+	if !exists {
+		return false
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.isActive && (conn.status == StatusConnected || conn.status == StatusConnecting || conn.status == StatusReconnecting)
+}
+
+// warmConnection 为账号建立预热连接，不计入使用次数；已有活跃连接时返回 false
+func (cp *ConnectionPool) warmConnection(accountID string, config *ClientConfig) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if oldConn, exists := cp.connections[accountID]; exists {
+		oldConn.mu.Lock()
+		active := oldConn.isActive && (oldConn.status == StatusConnected || oldConn.status == StatusConnecting || oldConn.status == StatusReconnecting)
+		oldConn.mu.Unlock()
+		if active {
+			return false
+		}
+		oldConn.shutdown()
+	}
+
+	if _, err := cp.createNewConnection(accountID, config); err != nil {
+		cp.logger.Warn("Failed to create warm connection",
+			zap.String("account_id", accountID),
+			zap.Error(err))
+		return false
+	}
+	cp.logger.Debug("Warm connection created", zap.String("account_id", accountID))
+	return true
+}
+
+// WarmUp 为指定账号预先建立连接并等待连接就绪（如定时群发开始前），已有连接直接复用，
+// 不占用任务槽位，不影响正在执行的任务。返回每个账号的结果，成功为 nil
+func (cp *ConnectionPool) WarmUp(ctx context.Context, accountIDs []uint64) map[uint64]error {
+	results := make(map[uint64]error, len(accountIDs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, warmUpConcurrency)
+
+	for _, id := range accountIDs {
+		if _, seen := results[id]; seen {
+			continue
+		}
+		results[id] = nil
+
+		wg.Add(1)
+		go func(id uint64) {
+			defer wg.Done()
+
+			var err error
+			select {
+			case sem <- struct{}{}:
+				err = cp.warmUpAccount(ctx, strconv.FormatUint(id, 10))
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			mu.Lock()
+			results[id] = err
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range results {
+		if err != nil {
+			failed++
+		}
+	}
+	cp.logger.Info("Warm up finished",
+		zap.Int("accounts", len(results)),
+		zap.Int("failed", failed))
+
+	return results
+}
+
+// warmUpAccount 获取或创建账号连接并等待连接就绪。轮询状态而不是读取 stateChangeCh，
+// 避免抢走正在等待连接的任务的状态通知
+func (cp *ConnectionPool) warmUpAccount(ctx context.Context, accountID string) error {
+	cp.mu.RLock()
+	config, exists := cp.configs[accountID]
+	cp.mu.RUnlock()
+	if !exists {
+		var err error
+		config, err = cp.loadAccountConfig(accountID)
+		if err != nil {
+			return fmt.Errorf("failed to load account configuration: %w", err)
+		}
+	}
+
+	conn, err := cp.GetOrCreateConnection(accountID, config)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	ticker := time.NewTicker(warmUpPollInterval)
+	defer ticker.Stop()
+	for {
+		conn.mu.Lock()
+		status := conn.status
+		conn.mu.Unlock()
+
+		switch status {
+		case StatusConnected:
+			if conn.client != nil && conn.client.API() != nil {
+				return nil
+			}
+		case StatusConnectionError:
+			return fmt.Errorf("connection error")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-conn.ctx.Done():
+			// 连接被重连替换时改为等待新连接
+			cp.mu.RLock()
+			current, ok := cp.connections[accountID]
+			cp.mu.RUnlock()
+			if !ok || current == conn {
+				return fmt.Errorf("connection replaced or canceled")
+			}
+			conn = current
+		case <-ticker.C:
+		}
+	}
+}
@@ -197,9 +197,21 @@ export function useTaskLogs(options: UseTaskLogsOptions): UseTaskLogsReturn {
     return unsubscribe;
   }, [taskId]);
 
-  // 监听实时日志推送
+  // 监听实时日志推送（单条 task_log，合并窗口内的多条为 task_logs）
   useEffect(() => {
-    const unsubscribe = wsManager.subscribe("task_log", (message: WSMessage) => {
+    const appendLogs = (entries: TaskLogEntry[]) => {
+      setLogs((prevLogs) => {
+        const newLogs = [...prevLogs, ...entries];
+        // 限制最大数量
+        if (newLogs.length > maxLogs) {
+          return newLogs.slice(newLogs.length - maxLogs);
+        }
+        return newLogs;
+      });
+      setHasNewLogs(true);
+    };
+
+    const unsubscribeLog = wsManager.subscribe("task_log", (message: WSMessage) => {
       console.log("[useTaskLogs] Received task_log:", message);
       const data = message.data as {
         task_id: number;
@@ -208,19 +220,26 @@ export function useTaskLogs(options: UseTaskLogsOptions): UseTaskLogsReturn {
 
       if (data.task_id === taskId && data.log) {
         console.log("[useTaskLogs] Adding new log:", data.log);
-        setLogs((prevLogs) => {
-          const newLogs = [...prevLogs, data.log];
-          // 限制最大数量
-          if (newLogs.length > maxLogs) {
-            return newLogs.slice(newLogs.length - maxLogs);
-          }
-          return newLogs;
-        });
-        setHasNewLogs(true);
+        appendLogs([data.log]);
       }
     });
 
-    return unsubscribe;
+    const unsubscribeLogs = wsManager.subscribe("task_logs", (message: WSMessage) => {
+      console.log("[useTaskLogs] Received task_logs:", message);
+      const data = message.data as {
+        task_id: number;
+        logs: TaskLogEntry[];
+      };
+
+      if (data.task_id === taskId && data.logs?.length) {
+        appendLogs(data.logs);
+      }
+    });
+
+    return () => {
+      unsubscribeLog();
+      unsubscribeLogs();
+    };
   }, [taskId, maxLogs]);
 
   // 监听错误消息